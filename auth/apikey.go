@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/sha256"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// APIKeys authenticates static API keys. Keys are held hashed, so looking
+// one up does not leak how much of it matched.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]*database.Principal
+}
+
+// NewAPIKeys returns an Authenticator for keys, which maps each key to the
+// principal it authenticates.
+func NewAPIKeys(keys map[string]*database.Principal) *APIKeys {
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]*database.Principal, len(keys))}
+	for key, p := range keys {
+		a.keys[sha256.Sum256([]byte(key))] = p
+	}
+	return a
+}
+
+// Authenticate ignores tokens that are not one of the keys, which may be
+// meant for another Authenticator in a Chain.
+func (a *APIKeys) Authenticate(c Credentials) (*database.Principal, error) {
+	if c.Token == "" {
+		return nil, nil
+	}
+	return a.keys[sha256.Sum256([]byte(c.Token))], nil
+}
@@ -0,0 +1,59 @@
+// Package auth identifies the callers of the network servers, by API key,
+// bearer JWT or TLS client certificate, as a database.Principal whose role
+// then limits what their requests may do.
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Credentials are what a caller presented with a request.
+type Credentials struct {
+	// Token is the bearer token or API key.
+	Token string
+	// Certificates is the verified client certificate chain, leaf first.
+	Certificates []*x509.Certificate
+}
+
+// Authenticator identifies a caller. It returns neither a principal nor an
+// error when the credentials it checks are absent, so authenticators can be
+// chained, and an error when they are present but invalid.
+type Authenticator interface {
+	Authenticate(c Credentials) (*database.Principal, error)
+}
+
+type chain []Authenticator
+
+// Chain tries authenticators in order, returning the first principal one
+// of them identifies or the first error.
+func Chain(a ...Authenticator) Authenticator {
+	return chain(a)
+}
+
+func (c chain) Authenticate(cred Credentials) (*database.Principal, error) {
+	for _, a := range c {
+		if p, err := a.Authenticate(cred); p != nil || err != nil {
+			return p, err
+		}
+	}
+	return nil, nil
+}
+
+// Require returns the principal a identifies from c, failing with an error
+// matching ErrUnauthenticated when there is none.
+func Require(a Authenticator, c Credentials) (*database.Principal, error) {
+	p, err := a.Authenticate(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if p == nil {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+var testSecret = []byte("secret")
+
+func token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAPIKeys(t *testing.T) {
+	alice := &database.Principal{Name: "alice", Role: database.ReadOnlyRole("reader")}
+	a := NewAPIKeys(map[string]*database.Principal{"k1": alice})
+	if p, err := a.Authenticate(Credentials{Token: "k1"}); err != nil || p != alice {
+		t.Errorf("Authenticate(k1) = %v, %v", p, err)
+	}
+	if p, err := a.Authenticate(Credentials{Token: "k2"}); err != nil || p != nil {
+		t.Errorf("Authenticate of an unknown key = %v, %v; want neither", p, err)
+	}
+	if _, err := Require(a, Credentials{Token: "k2"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Require of an unknown key = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	reader := database.ReadOnlyRole("reader")
+	j := &JWT{Key: testSecret, Methods: []string{"HS256"}, Issuer: "owndb", Roles: map[string]*database.Role{"reader": reader}}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	p, err := j.Authenticate(Credentials{Token: token(t, jwt.MapClaims{"sub": "bob", "iss": "owndb", "exp": exp, "role": "reader"})})
+	if err != nil || p == nil || p.Name != "bob" || p.Role != reader {
+		t.Fatalf("Authenticate = %+v, %v", p, err)
+	}
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired":        {"sub": "bob", "iss": "owndb", "exp": time.Now().Add(-time.Hour).Unix(), "role": "reader"},
+		"no expiry":      {"sub": "bob", "iss": "owndb", "role": "reader"},
+		"wrong issuer":   {"sub": "bob", "iss": "other", "exp": exp, "role": "reader"},
+		"no subject":     {"iss": "owndb", "exp": exp, "role": "reader"},
+		"unknown role":   {"sub": "bob", "iss": "owndb", "exp": exp, "role": "root"},
+		"no role":        {"sub": "bob", "iss": "owndb", "exp": exp},
+		"role of a list": {"sub": "bob", "iss": "owndb", "exp": exp, "role": []string{"reader"}},
+	} {
+		if p, err := j.Authenticate(Credentials{Token: token(t, claims)}); err == nil {
+			t.Errorf("%s token authenticated %+v", name, p)
+		}
+	}
+
+	other, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "bob", "iss": "owndb", "exp": exp}).SignedString([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Authenticate(Credentials{Token: other}); err == nil {
+		t.Error("token signed with another key authenticated")
+	}
+	if p, err := j.Authenticate(Credentials{Token: "an-api-key"}); p != nil || err != nil {
+		t.Errorf("Authenticate of an API key = %v, %v; want neither", p, err)
+	}
+}
+
+func TestClientCerts(t *testing.T) {
+	admin := database.AdminRole("admin")
+	a := &ClientCerts{Roles: map[string]*database.Role{"ops": admin}}
+	cert := func(cn string) []*x509.Certificate {
+		return []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}}
+	}
+	if p, err := a.Authenticate(Credentials{Certificates: cert("ops")}); err != nil || p.Name != "ops" || p.Role != admin {
+		t.Errorf("Authenticate(ops) = %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate(Credentials{Certificates: cert("intern")}); err == nil {
+		t.Error("certificate without a role authenticated")
+	}
+	a.DefaultRole = database.ReadOnlyRole("reader")
+	if p, err := a.Authenticate(Credentials{Certificates: cert("intern")}); err != nil || p.Role != a.DefaultRole {
+		t.Errorf("Authenticate with a default role = %+v, %v", p, err)
+	}
+	if p, err := a.Authenticate(Credentials{}); p != nil || err != nil {
+		t.Errorf("Authenticate without a certificate = %v, %v", p, err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	alice := &database.Principal{Name: "alice"}
+	a := Chain(&JWT{Key: testSecret, Methods: []string{"HS256"}}, NewAPIKeys(map[string]*database.Principal{"k1": alice}))
+	if p, err := a.Authenticate(Credentials{Token: "k1"}); err != nil || p != alice {
+		t.Errorf("Authenticate(k1) through the chain = %v, %v", p, err)
+	}
+	if _, err := a.Authenticate(Credentials{Token: "a.b.c"}); err == nil {
+		t.Error("invalid JWT passed on down the chain")
+	}
+	if _, err := Require(a, Credentials{}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Require without credentials = %v, want ErrUnauthenticated", err)
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// ClientCerts authenticates TLS client certificates, which the server's TLS
+// configuration must already have verified. The principal is named by the
+// certificate's subject common name.
+type ClientCerts struct {
+	// Roles maps common names to roles. Other certificates get DefaultRole,
+	// and are refused if it is nil.
+	Roles       map[string]*database.Role
+	DefaultRole *database.Role
+}
+
+func (a *ClientCerts) Authenticate(c Credentials) (*database.Principal, error) {
+	if len(c.Certificates) == 0 {
+		return nil, nil
+	}
+	name := c.Certificates[0].Subject.CommonName
+	role, ok := a.Roles[name]
+	if !ok {
+		role = a.DefaultRole
+	}
+	if role == nil {
+		return nil, fmt.Errorf("no role for client certificate %q", name)
+	}
+	return &database.Principal{Name: name, Role: role}, nil
+}
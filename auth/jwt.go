@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// JWT authenticates bearer JSON Web Tokens. The principal is named by the
+// token's subject and gets the role named by its role claim.
+type JWT struct {
+	// Key verifies the signatures: a []byte secret for HMAC, or an
+	// *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+	Key interface{}
+	// Methods lists the signing methods accepted, such as "HS256", so a
+	// key cannot be used with an algorithm it was not meant for.
+	Methods []string
+	// Issuer and Audience, when set, must match the token's.
+	Issuer   string
+	Audience string
+	// RoleClaim names the claim holding the role, "role" by default.
+	RoleClaim string
+	// Roles maps the role names tokens may carry to roles. Tokens without
+	// a role claim get DefaultRole, and are refused if it is nil.
+	Roles       map[string]*database.Role
+	DefaultRole *database.Role
+}
+
+// Authenticate ignores tokens that are not shaped like a JWT, which may be
+// API keys meant for another Authenticator in a Chain.
+func (j *JWT) Authenticate(c Credentials) (*database.Principal, error) {
+	if strings.Count(c.Token, ".") != 2 {
+		return nil, nil
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(j.Methods), jwt.WithExpirationRequired()}
+	if j.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.Issuer))
+	}
+	if j.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.Audience))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(c.Token, claims, func(*jwt.Token) (interface{}, error) { return j.Key, nil }, opts...); err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	roleClaim := j.RoleClaim
+	if roleClaim == "" {
+		roleClaim = "role"
+	}
+	role := j.DefaultRole
+	if v, ok := claims[roleClaim]; ok {
+		name, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("token claim %s is not a string", roleClaim)
+		}
+		if role, ok = j.Roles[name]; !ok {
+			return nil, fmt.Errorf("token has unknown role %q", name)
+		}
+	}
+	if role == nil {
+		return nil, errors.New("token grants no role")
+	}
+	return &database.Principal{Name: subject, Role: role}, nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider makes an Authenticator from its configuration, whose meaning is
+// up to the provider: a file, a URL, a list of settings.
+type Provider func(config string) (Authenticator, error)
+
+var providers = struct {
+	sync.RWMutex
+	m map[string]Provider
+}{m: make(map[string]Provider)}
+
+// Register makes an authentication provider available by name to Open,
+// typically from the init function of the package offering it. It panics if
+// the name is taken or p is nil.
+func Register(name string, p Provider) {
+	providers.Lock()
+	defer providers.Unlock()
+	if p == nil {
+		panic("auth: Register provider is nil")
+	}
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("auth: invalid provider name %q", name))
+	}
+	if _, ok := providers.m[name]; ok {
+		panic(fmt.Sprintf("auth: provider %s registered twice", name))
+	}
+	providers.m[name] = p
+}
+
+// Open makes an Authenticator from a spec of a registered provider name,
+// optionally followed by a colon and its configuration.
+func Open(spec string) (Authenticator, error) {
+	name, config, _ := strings.Cut(spec, ":")
+	providers.RLock()
+	p, ok := providers.m[name]
+	providers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown authentication provider %q - registered: %s", name, strings.Join(Providers(), ", "))
+	}
+	a, err := p(config)
+	if err != nil {
+		return nil, fmt.Errorf("authentication provider %s: %w", name, err)
+	}
+	return a, nil
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	names := make([]string, 0, len(providers.m))
+	for name := range providers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
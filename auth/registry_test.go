@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestRegistry(t *testing.T) {
+	// Registrations cannot be undone, so every run takes its own name.
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	failure := errors.New("bad config")
+	Register(name, func(config string) (Authenticator, error) {
+		if config == "fail" {
+			return nil, failure
+		}
+		return NewAPIKeys(map[string]*database.Principal{config: {Name: "ci"}}), nil
+	})
+	if !slices.Contains(Providers(), name) {
+		t.Errorf("Providers = %q, want %s", Providers(), name)
+	}
+
+	a, err := Open(name + ":k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := a.Authenticate(Credentials{Token: "k1"}); err != nil || p == nil || p.Name != "ci" {
+		t.Errorf("Authenticate = %v, %v", p, err)
+	}
+	if _, err := Open(name + ":fail"); !errors.Is(err, failure) || !strings.Contains(err.Error(), name) {
+		t.Errorf("Open of a failing provider = %v, want its error naming it", err)
+	}
+	if _, err := Open("nope:x"); err == nil || !strings.Contains(err.Error(), name) {
+		t.Errorf("Open of an unknown provider = %v, want the registered ones listed", err)
+	}
+
+	for what, register := range map[string]func(){
+		"Register of a nil provider":      func() { Register(name+"-nil", nil) },
+		"Register of a taken name":        func() { Register(name, func(string) (Authenticator, error) { return nil, nil }) },
+		"Register of a name with a colon": func() { Register("a:b", func(string) (Authenticator, error) { return nil, nil }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", what)
+				}
+			}()
+			register()
+		}()
+	}
+}
@@ -0,0 +1,127 @@
+// Package certs serves TLS certificates to the network servers from PEM
+// files, picking up a rotated certificate without a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the certificate loaded from a certificate and key file
+// pair. Servers get it through GetCertificate, so a Reload takes effect
+// for the next handshake.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// Load reads the certificate and key, failing if they do not make a pair.
+func Load(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On failure the previous certificate stays
+// in use.
+func (r *Reloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", r.certFile, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Watch reloads the files whenever their modification time changes,
+// checking every interval until ctx is done. Failed reloads are passed to
+// onError, if set, and retried at the next change.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.latestModTime()
+		r.mutex.RLock()
+		changed := err == nil && !modTime.Equal(r.modTime)
+		r.mutex.RUnlock()
+		if changed {
+			err = r.Reload()
+		}
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			// Skip this version of the files rather than retrying a
+			// half-written pair on every tick.
+			r.mutex.Lock()
+			r.modTime = modTime
+			r.mutex.Unlock()
+		}
+	}
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// Config returns a server configuration serving r's certificate over TLS
+// 1.2 or later.
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: r.GetCertificate}
+}
+
+// ClientCAs reads a PEM bundle of certificate authorities for verifying
+// client certificates.
+func ClientCAs(file string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("no certificates in " + file)
+	}
+	return pool, nil
+}
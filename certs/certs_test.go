@@ -0,0 +1,162 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a self-signed certificate for cn and its key to
+// cert.pem and key.pem in dir.
+func writePair(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{cn},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "one")
+	r, err := Load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, r); cn != "one" {
+		t.Fatalf("serving %s, want one", cn)
+	}
+
+	writePair(t, dir, "two")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, r); cn != "two" {
+		t.Errorf("serving %s after Reload, want two", cn)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("Reload of a broken key succeeded")
+	}
+	if cn := commonName(t, r); cn != "two" {
+		t.Errorf("serving %s after a failed Reload, want two", cn)
+	}
+	if _, err := Load(certFile, keyFile); err == nil {
+		t.Error("Load of a broken key succeeded")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "one")
+	r, err := Load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 5*time.Millisecond, func(err error) { t.Errorf("reloading: %v", err) })
+
+	writePair(t, dir, "two")
+	// Make sure the rewrite shows as a change on coarse file systems.
+	later := time.Now().Add(time.Second)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; commonName(t, r) != "two"; i++ {
+		if i == 200 {
+			t.Fatal("rotated certificate never picked up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "localhost")
+	r, err := Load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := ClientCAs(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if _, err := ClientCAs(keyFile); err == nil {
+		t.Error("ClientCAs of a key file succeeded")
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// Aggregate runs a pipeline over a collection on the server.
+func (c *Client) Aggregate(collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return c.AggregateContext(context.Background(), collection, pipeline)
+}
+
+func (c *Client) AggregateContext(ctx context.Context, collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	b, err := json.Marshal(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/aggregate", b)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	err = dec.Decode(&body)
+	return body.Items, err
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestAggregate(t *testing.T) {
+	ts, db := serve(t)
+	c := newClient(t, ts.URL, "admin")
+	for key, age := range map[string]int{"a": 3, "b": 4} {
+		if err := db.Write("users", key, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs, err := c.Aggregate("users", []database.Stage{{Group: &database.Group{Fields: map[string]database.Accumulator{"sum": {Op: "sum", Field: "age"}}}}})
+	if err != nil || len(docs) != 1 || fmt.Sprint(docs[0]["sum"]) != "7" {
+		t.Errorf("Aggregate = %v, %v", docs, err)
+	}
+	if _, err := c.Aggregate("users", []database.Stage{{}}); !errors.Is(err, database.ErrInvalidPipeline) {
+		t.Errorf("Aggregate of an invalid pipeline = %v, want ErrInvalidPipeline", err)
+	}
+}
+
+func TestAggregateFilter(t *testing.T) {
+	ts, db := serve(t)
+	c := newClient(t, ts.URL, "admin")
+	for key, age := range map[string]int{"a": 30, "b": 20} {
+		if err := db.Write("users", key, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs, err := c.Aggregate("users", []database.Stage{{Filter: "doc.age > 25"}})
+	if err != nil || len(docs) != 1 || docs[0]["_key"] != "a" {
+		t.Errorf("Aggregate with a filter = %v, %v", docs, err)
+	}
+	if _, err := c.Aggregate("users", []database.Stage{{Filter: "doc.x >"}}); !errors.Is(err, database.ErrInvalidExpr) {
+		t.Errorf("Aggregate with a bad filter = %v, want ErrInvalidExpr", err)
+	}
+}
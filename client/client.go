@@ -0,0 +1,387 @@
+// Package client talks to a dbserver over HTTP with the same methods as an
+// embedded database.Driver, so code can move from one to the other by
+// swapping the constructor:
+//
+//	db, err := client.New("https://db.internal:8080", token)
+//	err = db.Write("users", "john", user)
+//
+// Requests are retried with exponential backoff on 429 Too Many Requests
+// and 502, 503 and 504, and those that are safe to send twice, reads and
+// unconditional PUTs, on network errors too. Connections to the server are
+// pooled. Writes made on a follower of a cluster follow its redirect to the
+// leader. NewCluster takes the servers of several nodes of a cluster, sends
+// writes to the leader and reads where the ReadPreference says, and fails
+// over to the other nodes when one goes away:
+//
+//	db, err := client.NewCluster([]string{"http://db1:8080", "http://db2:8080", "http://db3:8080"}, token)
+//	db.ReadPreference = client.ReadReplicaPreferred
+//	err = db.ReadContext(client.WithReadPreference(ctx, client.ReadPrimary), "users", "john", &user)
+//
+// Errors match the database errors the server reported, such as
+// fs.ErrNotExist for a missing record. A Client and a Driver both implement
+// database.Database.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 5 * time.Second
+	// pageSize is the server's largest page.
+	pageSize = 1000
+)
+
+var _ database.Database = (*Client)(nil)
+
+type Client struct {
+	endpoints endpoints
+	token     string
+
+	// HTTPClient sends the requests. The default keeps up to 16 idle
+	// connections to the server for reuse.
+	HTTPClient *http.Client
+	// MaxRetries is how many times a failed request is retried, waiting
+	// RetryBackoff before the first retry and twice as long each time after.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// ReadPreference is where reads go when the client knows several
+	// nodes, unless their context says otherwise with WithReadPreference.
+	ReadPreference ReadPreference
+}
+
+// New returns a client for the server at baseURL, such as
+// "http://localhost:8080". A token, if not empty, is sent as a bearer
+// token and may be an API key or a JWT.
+func New(baseURL, token string) (*Client, error) {
+	base, err := parseBase(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+	c := &Client{
+		endpoints:    endpoints{urls: []string{base}, down: map[string]time.Time{}},
+		token:        token,
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+	}
+	c.HTTPClient = &http.Client{Transport: transport, CheckRedirect: c.redirect}
+	return c, nil
+}
+
+// redirect follows the redirects of cluster followers to their leader,
+// sending the token on, which the HTTP client drops for other hosts.
+func (c *Client) redirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return nil
+}
+
+func (c *Client) Write(collection string, resource string, v interface{}) error {
+	return c.WriteContext(context.Background(), collection, resource, v)
+}
+
+func (c *Client) WriteContext(ctx context.Context, collection string, resource string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPut, recordPath(collection, resource), b)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) Read(collection string, resource string, v interface{}) error {
+	return c.ReadContext(context.Background(), collection, resource, v)
+}
+
+func (c *Client) ReadContext(ctx context.Context, collection string, resource string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	resp, err := c.do(ctx, http.MethodGet, recordPath(collection, resource), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ReadResolved reads a record with its references resolved depth deep, as
+// Driver.ReadResolved does. The server allows a depth of at most 8.
+func (c *Client) ReadResolved(collection string, resource string, v interface{}, depth int) error {
+	return c.ReadResolvedContext(context.Background(), collection, resource, v, depth)
+}
+
+func (c *Client) ReadResolvedContext(ctx context.Context, collection string, resource string, v interface{}, depth int) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	resp, err := c.do(ctx, http.MethodGet, recordPath(collection, resource)+"?resolve="+strconv.Itoa(depth), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ReadAll returns every record of a collection as JSON, in key order.
+func (c *Client) ReadAll(collection string) ([]string, error) {
+	return c.ReadAllContext(context.Background(), collection)
+}
+
+func (c *Client) ReadAllContext(ctx context.Context, collection string) ([]string, error) {
+	records := []string{}
+	err := c.list(ctx, collection, 0, func(key string, value json.RawMessage) {
+		records = append(records, string(value))
+	})
+	return records, err
+}
+
+// ReadAllResolved is ReadAll with references resolved as by ReadResolved.
+func (c *Client) ReadAllResolved(collection string, depth int) ([]string, error) {
+	return c.ReadAllResolvedContext(context.Background(), collection, depth)
+}
+
+func (c *Client) ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error) {
+	records := []string{}
+	err := c.list(ctx, collection, depth, func(key string, value json.RawMessage) {
+		records = append(records, string(value))
+	})
+	return records, err
+}
+
+func (c *Client) Keys(collection string) ([]string, error) {
+	return c.KeysContext(context.Background(), collection)
+}
+
+func (c *Client) KeysContext(ctx context.Context, collection string) ([]string, error) {
+	keys := []string{}
+	err := c.list(ctx, collection, 0, func(key string, _ json.RawMessage) {
+		keys = append(keys, key)
+	})
+	return keys, err
+}
+
+// Delete removes a record.
+func (c *Client) Delete(collection string, resource string) error {
+	return c.DeleteContext(context.Background(), collection, resource)
+}
+
+func (c *Client) DeleteContext(ctx context.Context, collection string, resource string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - nothing to delete")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - use DropCollection to remove a whole collection")
+	}
+	resp, err := c.do(ctx, http.MethodDelete, recordPath(collection, resource), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// DropCollection deletes a whole collection, as Driver.DropCollection
+// does.
+func (c *Client) DropCollection(ctx context.Context, collection string, dryRun bool) (*database.DeleteReport, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - nothing to drop")
+	}
+	path := "/collections/" + url.PathEscape(collection)
+	if dryRun {
+		path += "?dry_run=true"
+	}
+	resp, err := c.do(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var report database.DeleteReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+type page struct {
+	Items []struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	} `json:"items"`
+	Next string `json:"next"`
+}
+
+// list calls fn for every record of collection, a page at a time.
+func (c *Client) list(ctx context.Context, collection string, resolve int, fn func(key string, value json.RawMessage)) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to read records")
+	}
+	after := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		if resolve > 0 {
+			query.Set("resolve", strconv.Itoa(resolve))
+		}
+		resp, err := c.do(ctx, http.MethodGet, "/collections/"+url.PathEscape(collection)+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		var p page
+		err = json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, item := range p.Items {
+			fn(item.Key, item.Value)
+		}
+		if p.Next == "" {
+			return nil
+		}
+		after = p.Next
+	}
+}
+
+func recordPath(collection, resource string) string {
+	return "/collections/" + url.PathEscape(collection) + "/" + url.PathEscape(resource)
+}
+
+// do sends a request, retrying failures that may pass, and returns the
+// response if it succeeded. The caller closes its body.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	return c.doHeader(ctx, method, path, body, nil)
+}
+
+// doHeader is do with extra request headers.
+func (c *Client) doHeader(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	backoff := c.RetryBackoff
+	read := isRead(method, path)
+	for attempt := 0; ; attempt++ {
+		base := c.endpoint(ctx, read)
+		req, err := http.NewRequestWithContext(ctx, method, base+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		wait := backoff
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			c.markDown(base)
+			// The request may have reached the server, so only one that
+			// does the same the second time is sent again.
+			if !read && !idempotent(method, header) {
+				return nil, err
+			}
+		case resp.StatusCode < 400:
+			if !read {
+				c.learnPrimary(resp, base)
+			}
+			return resp, nil
+		case retryable(resp.StatusCode):
+			if !read {
+				c.forgetPrimary(base)
+			}
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = max(wait, time.Duration(s)*time.Second)
+			}
+			err = responseError(resp)
+		default:
+			return nil, responseError(resp)
+		}
+
+		if attempt >= c.MaxRetries {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(wait, maxRetryBackoff)):
+		}
+		backoff *= 2
+	}
+}
+
+// idempotent reports whether a write leaves the same state if it is sent
+// twice. A DELETE sent again fails with not found for a record the first
+// removed, a conditional PUT with a failed precondition and a POST may
+// insert or run anything twice.
+func idempotent(method string, header http.Header) bool {
+	return method == http.MethodPut && header.Get("If-Match") == "" && header.Get("If-None-Match") == ""
+}
+
+func retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// responseError reads the {"error": "..."} body of a failed response and
+// closes it.
+func responseError(resp *http.Response) error {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(b))
+	if json.Unmarshal(b, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	if msg == "" {
+		msg = resp.Status
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: msg}
+}
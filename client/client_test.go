@@ -0,0 +1,352 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/server"
+)
+
+// serve runs a server for a fresh database with an admin key "admin" and a
+// read-only key "reader".
+func serve(t *testing.T) (*httptest.Server, *database.Driver) {
+	t.Helper()
+	return serveWith(t, &database.Options{TTLSweepInterval: -1})
+}
+
+func serveWith(t *testing.T, opts *database.Options) (*httptest.Server, *database.Driver) {
+	t.Helper()
+	return serveIn(t, t.TempDir(), opts)
+}
+
+// serveIn is serveWith keeping the database in dir.
+func serveIn(t *testing.T, dir string, opts *database.Options) (*httptest.Server, *database.Driver) {
+	t.Helper()
+	db, err := database.New(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := server.New(db)
+	s.Auth = auth.NewAPIKeys(map[string]*database.Principal{
+		"admin":  {Name: "admin", Role: database.AdminRole("admin")},
+		"reader": {Name: "reader", Role: database.ReadOnlyRole("reader")},
+	})
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts, db
+}
+
+func newClient(t *testing.T, url, token string) *Client {
+	t.Helper()
+	c, err := New(url, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestRecords(t *testing.T) {
+	ts, db := serve(t)
+	c := newClient(t, ts.URL, "admin")
+
+	// Keys are escaped in the path.
+	for _, key := range []string{"ada", "bob", "jo hn/x"} {
+		if err := c.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var v map[string]string
+	if err := c.Read("users", "jo hn/x", &v); err != nil || v["name"] != "jo hn/x" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+	if err := db.Read("users", "ada", &v); err != nil || v["name"] != "ada" {
+		t.Fatalf("record written through the client = %v, %v", v, err)
+	}
+
+	all, err := c.ReadAll("users")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ReadAll = %q, %v", all, err)
+	}
+	keys, err := c.Keys("users")
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "ada,bob,jo hn/x" {
+		t.Fatalf("Keys = %q, %v", keys, err)
+	}
+
+	if err := c.Delete("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Read("users", "bob", &v); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read of a deleted record = %v, want fs.ErrNotExist", err)
+	}
+	if err := c.Delete("users", "bob"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete of a deleted record = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	ts, db := serve(t)
+	c := newClient(t, ts.URL, "admin")
+	for _, key := range []string{"ada", "bob"} {
+		if err := db.Write("users", key, map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := c.DropCollection(context.Background(), "users", true)
+	if err != nil || !report.DryRun || report.Count() != 2 {
+		t.Fatalf("DropCollection dry run = %+v, %v", report, err)
+	}
+	report, err = c.DropCollection(context.Background(), "users", false)
+	if err != nil || report.DryRun || strings.Join(report.Records["users"], ",") != "ada,bob" {
+		t.Fatalf("DropCollection = %+v, %v", report, err)
+	}
+	if _, err := db.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := c.DropCollection(context.Background(), "users", false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DropCollection of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+	if err := c.Delete("users", ""); err == nil {
+		t.Error("Delete without a key succeeded")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ts, _ := serve(t)
+
+	err := newClient(t, ts.URL, "reader").Write("users", "ada", 1)
+	if !errors.Is(err, database.ErrPermissionDenied) {
+		t.Errorf("Write with a read-only key = %v, want ErrPermissionDenied", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusForbidden {
+		t.Errorf("error = %#v, want a 403 Error", err)
+	}
+	if err := newClient(t, ts.URL, "nope").Write("users", "ada", 1); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Write with an unknown key = %v, want auth.ErrUnauthenticated", err)
+	}
+	if _, err := New("localhost:8080", ""); err == nil {
+		t.Error("New accepted a URL without a scheme")
+	}
+}
+
+// fullBackend is storage without any room left.
+type fullBackend struct {
+	database.Backend
+}
+
+func (fullBackend) Available() (int64, error) {
+	return 0, nil
+}
+
+func TestDiskFull(t *testing.T) {
+	ts, _ := serveWith(t, &database.Options{Backend: fullBackend{database.NewMemoryBackend()}, MinFreeSpace: 1, TTLSweepInterval: -1})
+	err := newClient(t, ts.URL, "admin").Write("users", "ada", 1)
+	if !errors.Is(err, database.ErrDiskFull) || errors.Is(err, database.ErrQuotaExceeded) {
+		t.Errorf("Write to a full disk = %v, want ErrDiskFull", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("error = %#v, want a 507 Error", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ts, _ := serve(t)
+	c := newClient(t, ts.URL, "admin")
+
+	events, stop, err := c.Watch("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("posts", "p", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("users", "ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []database.Event{
+		{Op: database.EventPut, Collection: "users", Key: "ada"},
+		{Op: database.EventDelete, Collection: "users", Key: "ada"},
+	} {
+		select {
+		case e := <-events:
+			if e != want {
+				t.Errorf("event %+v, want %+v", e, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	stop()
+	for range events {
+	}
+}
+
+func TestWatchExternal(t *testing.T) {
+	dir := t.TempDir()
+	ts, _ := serveIn(t, dir, &database.Options{WatchExternal: true, TTLSweepInterval: -1})
+	c := newClient(t, ts.URL, "admin")
+
+	events, stop, err := c.Watch("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if err := os.MkdirAll(filepath.Join(dir, "users"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "users", "ada.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := database.Event{Op: database.EventPut, Collection: "users", Key: "ada", External: true}
+	select {
+	case e := <-events:
+		if e != want {
+			t.Errorf("event %+v, want %+v", e, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event, want %+v", want)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	leader, db := serve(t)
+	// A follower of a cluster redirecting writes to its leader, on another
+	// host the token must still be sent to.
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, leader.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(follower.Close)
+
+	if err := newClient(t, follower.URL, "admin").Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := db.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Errorf("record written through the redirect = %v, %v", v, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"name":"ada"}`))
+	}))
+	defer ts.Close()
+
+	var v map[string]string
+	if err := newClient(t, ts.URL, "").Read("users", "ada", &v); err != nil || v["name"] != "ada" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("sent %d times, want 3", n)
+	}
+
+	hits.Store(-100)
+	c := newClient(t, ts.URL, "")
+	c.MaxRetries = 1
+	if err := c.Read("users", "ada", &v); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Read after running out of retries = %v, want the 503 error", err)
+	}
+	if n := hits.Load(); n != -98 {
+		t.Errorf("sent %d times, want 2", n+100)
+	}
+}
+
+// dropServer counts the requests it gets and drops the connection of each
+// without answering, as a server that crashed while handling it would.
+func dropServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits
+}
+
+func TestRetryOnNetworkError(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		send  func(c *Client) error
+		sends int32
+	}{
+		{"read", func(c *Client) error { var v any; return c.Read("users", "ada", &v) }, defaultMaxRetries + 1},
+		{"write", func(c *Client) error { return c.Write("users", "ada", 1) }, defaultMaxRetries + 1},
+		{"delete", func(c *Client) error { return c.Delete("users", "ada") }, 1},
+		{"write if revision", func(c *Client) error { _, err := c.WriteIfRevision("users", "ada", 1, "1"); return err }, 1},
+		{"create", func(c *Client) error { _, err := c.WriteIfRevision("users", "ada", 1, ""); return err }, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, hits := dropServer(t)
+			c, err := New(ts.URL, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.RetryBackoff = time.Millisecond
+			if err := tc.send(c); err == nil {
+				t.Fatal("no error from a server that drops every connection")
+			}
+			if n := hits.Load(); n != tc.sends {
+				t.Errorf("sent %d times, want %d", n, tc.sends)
+			}
+		})
+	}
+}
+
+func TestReadResolved(t *testing.T) {
+	ts, db := serve(t)
+	c := newClient(t, ts.URL, "admin")
+	if err := db.Write("users", "Waqar", map[string]string{"city": "Karachi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write("users/Waqar/orders", "o1", map[string]interface{}{"by": database.RefTo("users", "Waqar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var o struct {
+		By map[string]string `json:"by"`
+	}
+	if err := c.ReadResolved("users/Waqar/orders", "o1", &o, 1); err != nil || o.By["city"] != "Karachi" {
+		t.Errorf("ReadResolved = %+v, %v", o, err)
+	}
+	all, err := c.ReadAllResolved("users/Waqar/orders", 2)
+	if err != nil || len(all) != 1 || !strings.Contains(all[0], "Karachi") {
+		t.Errorf("ReadAllResolved = %q, %v", all, err)
+	}
+	if err := c.ReadResolved("users/Waqar/orders", "nope", &o, 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadResolved of a missing record = %v, want fs.ErrNotExist", err)
+	}
+	if err := c.ReadResolved("users/Waqar/orders", "o1", &o, 9); err == nil {
+		t.Error("ReadResolved past the server's depth limit succeeded")
+	}
+}
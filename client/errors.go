@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+// Error is a request the server refused. It unwraps to the database error
+// its status stands for, so errors.Is works as it does with a Driver.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusBadRequest:
+		// A stage of a pipeline fails with an invalid expression or name,
+		// which the error matches as well.
+		var errs []error
+		for _, err := range []error{database.ErrInvalidPipeline, database.ErrInvalidExpr, database.ErrInvalidName, database.ErrInvalidCursor, database.ErrInvalidQuery} {
+			if strings.Contains(e.Message, err.Error()) {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	case http.StatusUnauthorized:
+		return auth.ErrUnauthenticated
+	case http.StatusForbidden:
+		if strings.Contains(e.Message, database.ErrReadOnly.Error()) {
+			return database.ErrReadOnly
+		}
+		return database.ErrPermissionDenied
+	case http.StatusConflict:
+		if strings.Contains(e.Message, database.ErrAppendOnly.Error()) {
+			return database.ErrAppendOnly
+		}
+		return database.ErrKeyCollision
+	case http.StatusPreconditionFailed:
+		return database.ErrConflict
+	case http.StatusTooManyRequests:
+		if strings.Contains(e.Message, limit.ErrTooManyActive.Error()) {
+			return limit.ErrTooManyActive
+		}
+		return limit.ErrRateLimited
+	case http.StatusUnprocessableEntity:
+		if strings.Contains(e.Message, database.ErrSchemaViolation.Error()) {
+			return database.ErrSchemaViolation
+		}
+		return database.ErrProcedureFailed
+	case http.StatusInsufficientStorage:
+		if strings.Contains(e.Message, database.ErrDiskFull.Error()) {
+			return database.ErrDiskFull
+		}
+		return database.ErrQuotaExceeded
+	case http.StatusServiceUnavailable:
+		return database.ErrClosed
+	}
+	return nil
+}
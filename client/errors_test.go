@@ -0,0 +1,29 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestErrorUnwrap(t *testing.T) {
+	e := &Error{StatusCode: http.StatusBadRequest, Message: "invalid cursor: cursor of collection users used to list groups"}
+	if !errors.Is(e, database.ErrInvalidCursor) {
+		t.Errorf("%v does not match ErrInvalidCursor", e)
+	}
+	if errors.Is(e, database.ErrInvalidName) {
+		t.Errorf("%v matches ErrInvalidName", e)
+	}
+
+	e = &Error{StatusCode: http.StatusConflict, Message: "record users/ada is already written: collection is append-only"}
+	if !errors.Is(e, database.ErrAppendOnly) || errors.Is(e, database.ErrKeyCollision) {
+		t.Errorf("%v does not match only ErrAppendOnly", e)
+	}
+
+	e = &Error{StatusCode: http.StatusUnprocessableEntity, Message: "record users/ada: record does not match the collection schema"}
+	if !errors.Is(e, database.ErrSchemaViolation) || errors.Is(e, database.ErrProcedureFailed) {
+		t.Errorf("%v does not match only ErrSchemaViolation", e)
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+func (c *Client) Procedures() ([]string, error) {
+	return c.ProceduresContext(context.Background())
+}
+
+func (c *Client) ProceduresContext(ctx context.Context) ([]string, error) {
+	var body struct {
+		Procedures []string `json:"procedures"`
+	}
+	err := c.getJSON(ctx, "/procedures", &body)
+	return body.Procedures, err
+}
+
+// Call runs a procedure on the server with args and decodes its result
+// into result, unless result is nil.
+func (c *Client) Call(name string, args interface{}, result interface{}) error {
+	return c.CallContext(context.Background(), name, args, result)
+}
+
+func (c *Client) CallContext(ctx context.Context, name string, args interface{}, result interface{}) error {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/procedures/"+url.PathEscape(name), b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || result == nil {
+		return err
+	}
+	return json.Unmarshal(body.Result, result)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestCall(t *testing.T) {
+	ts, _ := serveWith(t, &database.Options{
+		Procedures: []database.Procedure{
+			{Name: "inc", Collections: []string{"counters"}, Source: `
+				local r = db.read("counters", "n") or {v = 0}
+				r.v = r.v + (args and args.by or 1)
+				db.write("counters", "n", r)
+				return r`},
+			{Name: "fail", Source: `error("nope")`},
+		},
+		TTLSweepInterval: -1,
+	})
+	c := newClient(t, ts.URL, "admin")
+
+	var r struct{ V int }
+	if err := c.Call("inc", map[string]int{"by": 5}, &r); err != nil || r.V != 5 {
+		t.Errorf("Call = %+v, %v", r, err)
+	}
+	if err := c.Call("inc", nil, &r); err != nil || r.V != 6 {
+		t.Errorf("Call without arguments = %+v, %v", r, err)
+	}
+	if err := c.Call("inc", nil, nil); err != nil {
+		t.Errorf("Call ignoring the result = %v", err)
+	}
+	if err := c.Call("fail", nil, nil); !errors.Is(err, database.ErrProcedureFailed) {
+		t.Errorf("Call of a failing procedure = %v, want ErrProcedureFailed", err)
+	}
+	if err := c.Call("none", nil, nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Call of an unknown procedure = %v, want fs.ErrNotExist", err)
+	}
+	if names, err := c.Procedures(); err != nil || strings.Join(names, ",") != "fail,inc" {
+		t.Errorf("Procedures = %q, %v", names, err)
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func (c *Client) SaveQuery(name string, q database.Query) error {
+	return c.SaveQueryContext(context.Background(), name, q)
+}
+
+func (c *Client) SaveQueryContext(ctx context.Context, name string, q database.Query) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPut, "/queries/"+url.PathEscape(name), b)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) SavedQuery(name string) (database.Query, error) {
+	return c.SavedQueryContext(context.Background(), name)
+}
+
+func (c *Client) SavedQueryContext(ctx context.Context, name string) (database.Query, error) {
+	var q database.Query
+	err := c.getJSON(ctx, "/queries/"+url.PathEscape(name), &q)
+	return q, err
+}
+
+func (c *Client) SavedQueries() ([]string, error) {
+	return c.SavedQueriesContext(context.Background())
+}
+
+func (c *Client) SavedQueriesContext(ctx context.Context) ([]string, error) {
+	var body struct {
+		Queries []string `json:"queries"`
+	}
+	err := c.getJSON(ctx, "/queries", &body)
+	return body.Queries, err
+}
+
+func (c *Client) DeleteQuery(name string) error {
+	return c.DeleteQueryContext(context.Background(), name)
+}
+
+func (c *Client) DeleteQueryContext(ctx context.Context, name string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/queries/"+url.PathEscape(name), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// RunQuery runs a query saved on the server.
+func (c *Client) RunQuery(name string) ([]database.QueryResult, error) {
+	return c.RunQueryContext(context.Background(), name)
+}
+
+func (c *Client) RunQueryContext(ctx context.Context, name string) ([]database.QueryResult, error) {
+	var body struct {
+		Items []database.QueryResult `json:"items"`
+	}
+	err := c.getJSON(ctx, "/queries/"+url.PathEscape(name)+"/results", &body)
+	return body.Items, err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
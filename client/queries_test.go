@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestSavedQueries(t *testing.T) {
+	ts, _ := serve(t)
+	c := newClient(t, ts.URL, "admin")
+	for key, city := range map[string]string{"a": "Karachi", "b": "Lahore", "c": "Karachi"} {
+		if err := c.Write("users", key, map[string]interface{}{"name": key, "age": 30, "address": map[string]string{"city": city}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := database.Query{Collection: "users", Where: map[string][]string{"address.city": {"Karachi"}}, Fields: []string{"name"}}
+	if err := c.SaveQuery("karachi", q); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := c.SavedQueries(); err != nil || strings.Join(names, ",") != "karachi" {
+		t.Errorf("SavedQueries = %q, %v", names, err)
+	}
+	if saved, err := c.SavedQuery("karachi"); err != nil || saved.Collection != "users" || saved.Where["address.city"][0] != "Karachi" {
+		t.Errorf("SavedQuery = %+v, %v", saved, err)
+	}
+	results, err := c.RunQuery("karachi")
+	if err != nil || len(results) != 2 || results[0].Key != "a" || len(results[1].Value) != 1 {
+		t.Errorf("RunQuery = %+v, %v", results, err)
+	}
+
+	if err := c.SaveQuery("bad/name", q); !errors.Is(err, database.ErrInvalidName) {
+		t.Errorf("SaveQuery with a bad name = %v, want ErrInvalidName", err)
+	}
+	if err := c.SaveQuery("bad", database.Query{Collection: "users", Limit: -1}); !errors.Is(err, database.ErrInvalidQuery) {
+		t.Errorf("SaveQuery of a bad query = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := c.RunQuery("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RunQuery of a missing query = %v, want fs.ErrNotExist", err)
+	}
+	if err := c.DeleteQuery("karachi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteQuery("karachi"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteQuery of a deleted query = %v, want fs.ErrNotExist", err)
+	}
+}
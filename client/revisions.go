@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ReadRevision reads a record and returns its revision, as
+// Driver.ReadRevision does, from the ETag the server sends with it.
+func (c *Client) ReadRevision(collection string, resource string, v interface{}) (string, error) {
+	return c.ReadRevisionContext(context.Background(), collection, resource, v)
+}
+
+func (c *Client) ReadRevisionContext(ctx context.Context, collection string, resource string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return "", fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	resp, err := c.do(ctx, http.MethodGet, recordPath(collection, resource), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return revision(resp), nil
+}
+
+// WriteIfRevision replaces a record provided it is still at revision rev,
+// or creates it if rev is empty, and returns its new revision, as
+// Driver.WriteIfRevision does. It fails with an error matching
+// database.ErrConflict otherwise.
+func (c *Client) WriteIfRevision(collection string, resource string, v interface{}, rev string) (string, error) {
+	return c.WriteIfRevisionContext(context.Background(), collection, resource, v, rev)
+}
+
+func (c *Client) WriteIfRevisionContext(ctx context.Context, collection string, resource string, v interface{}, rev string) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return "", fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.doHeader(ctx, http.MethodPut, recordPath(collection, resource), b, precondition(rev))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return revision(resp), nil
+}
+
+// DeleteIfRevision deletes a record provided it is still at revision rev,
+// as Driver.DeleteIfRevision does.
+func (c *Client) DeleteIfRevision(collection string, resource string, rev string) error {
+	return c.DeleteIfRevisionContext(context.Background(), collection, resource, rev)
+}
+
+func (c *Client) DeleteIfRevisionContext(ctx context.Context, collection string, resource string, rev string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - nothing to delete")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to delete record (no name)")
+	}
+	resp, err := c.doHeader(ctx, http.MethodDelete, recordPath(collection, resource), nil, precondition(rev))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// precondition returns the header asking the server to only change a
+// record at revision rev, or a missing one if rev is empty.
+func precondition(rev string) http.Header {
+	if rev == "" {
+		return http.Header{"If-None-Match": {"*"}}
+	}
+	return http.Header{"If-Match": {`"` + rev + `"`}}
+}
+
+func revision(resp *http.Response) string {
+	return strings.Trim(resp.Header.Get("ETag"), `"`)
+}
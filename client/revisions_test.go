@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestRevisions(t *testing.T) {
+	ts, _ := serveWith(t, &database.Options{
+		FieldKey:         make([]byte, 32),
+		Collections:      map[string]database.CollectionOptions{"secrets": {EncryptedFields: []string{"n"}}},
+		TTLSweepInterval: -1,
+	})
+	c := newClient(t, ts.URL, "admin")
+
+	// Records with encrypted fields keep their revision between reads.
+	for _, collection := range []string{"tallies", "secrets"} {
+		rev, err := c.WriteIfRevision(collection, "a", map[string]int{"n": 0}, "")
+		if err != nil || rev == "" {
+			t.Fatalf("WriteIfRevision creating in %s = %q, %v", collection, rev, err)
+		}
+		if _, err := c.WriteIfRevision(collection, "a", map[string]int{"n": 0}, ""); !errors.Is(err, database.ErrConflict) {
+			t.Errorf("WriteIfRevision creating an existing record = %v, want ErrConflict", err)
+		}
+		var v map[string]int
+		if got, err := c.ReadRevision(collection, "a", &v); err != nil || got != rev {
+			t.Errorf("ReadRevision = %q, %v; want %q", got, err, rev)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					var v map[string]int
+					rev, err := c.ReadRevision(collection, "a", &v)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					v["n"]++
+					_, err = c.WriteIfRevision(collection, "a", v, rev)
+					if err == nil {
+						return
+					}
+					if !errors.Is(err, database.ErrConflict) {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		rev, err = c.ReadRevision(collection, "a", &v)
+		if err != nil || v["n"] != 20 {
+			t.Fatalf("ReadRevision after the increments = %v, %v; want 20", v, err)
+		}
+
+		if err := c.DeleteIfRevision(collection, "a", "stale"); !errors.Is(err, database.ErrConflict) {
+			t.Errorf("DeleteIfRevision at a stale revision = %v, want ErrConflict", err)
+		}
+		if err := c.DeleteIfRevision(collection, "a", rev); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ReadRevision(collection, "a", &v); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("ReadRevision of a deleted record = %v, want fs.ErrNotExist", err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// downTime is how long an endpoint that could not be reached is passed
+// over for the others.
+const downTime = 10 * time.Second
+
+// ReadPreference is where a client sends its reads when it knows several
+// nodes of a cluster. Writes always go to the primary, the cluster leader.
+type ReadPreference int
+
+const (
+	// ReadPrimary reads from the primary, seeing every write made.
+	ReadPrimary ReadPreference = iota
+	// ReadPrimaryPreferred reads from the primary, or from a replica while
+	// the primary cannot be reached.
+	ReadPrimaryPreferred
+	// ReadReplica spreads the reads over the replicas, which may not have
+	// applied the latest writes yet, and uses the primary only when there
+	// are no replicas.
+	ReadReplica
+	// ReadReplicaPreferred spreads the reads over the replicas, or reads
+	// from the primary while no replica can be reached.
+	ReadReplicaPreferred
+)
+
+type readPreferenceKey struct{}
+
+// WithReadPreference returns a context under which the reads of a client
+// follow p rather than its ReadPreference.
+func WithReadPreference(ctx context.Context, p ReadPreference) context.Context {
+	return context.WithValue(ctx, readPreferenceKey{}, p)
+}
+
+// endpoints are the servers a client knows, and what it learned of them.
+type endpoints struct {
+	mutex sync.Mutex
+	urls  []string
+	// primary is the URL of the leader, empty until found.
+	primary string
+	// down holds until when each unreachable endpoint is passed over.
+	down map[string]time.Time
+	// next is the replica to read from next.
+	next int
+}
+
+// NewCluster returns a client for the servers of the nodes of a cluster at
+// baseURLs, sending writes to the leader and reads as its ReadPreference
+// says, and moving on to the other nodes when one cannot be reached.
+func NewCluster(baseURLs []string, token string) (*Client, error) {
+	if len(baseURLs) == 0 {
+		return nil, errors.New("missing server URL")
+	}
+	c, err := New(baseURLs[0], token)
+	if err != nil {
+		return nil, err
+	}
+	for _, base := range baseURLs[1:] {
+		u, err := parseBase(base)
+		if err != nil {
+			return nil, err
+		}
+		c.endpoints.urls = append(c.endpoints.urls, u)
+	}
+	return c, nil
+}
+
+// endpoint returns the server to send a request to: the primary for a
+// write, and the node its read preference picks for a read.
+func (c *Client) endpoint(ctx context.Context, read bool) string {
+	e := &c.endpoints
+	if len(e.urls) == 1 {
+		return e.urls[0]
+	}
+	primary := c.primary(ctx)
+	pref := ReadPrimary
+	if read {
+		pref = c.ReadPreference
+		if p, ok := ctx.Value(readPreferenceKey{}).(ReadPreference); ok {
+			pref = p
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var replicas []string
+	for _, u := range e.urls {
+		if u != primary {
+			replicas = append(replicas, u)
+		}
+	}
+	if len(replicas) > 0 {
+		n := e.next % len(replicas)
+		replicas = slices.Concat(replicas[n:], replicas[:n])
+	}
+	var candidates []string
+	switch pref {
+	case ReadPrimaryPreferred:
+		candidates = append([]string{primary}, replicas...)
+	case ReadReplica:
+		candidates = replicas
+		if len(candidates) == 0 {
+			candidates = []string{primary}
+		}
+	case ReadReplicaPreferred:
+		candidates = append(replicas, primary)
+	default:
+		candidates = []string{primary}
+	}
+	if pref == ReadReplica || pref == ReadReplicaPreferred {
+		e.next++
+	}
+	now := time.Now()
+	for _, u := range candidates {
+		if now.After(e.down[u]) {
+			return u
+		}
+	}
+	return candidates[0]
+}
+
+// primary returns the URL of the cluster leader, asking the nodes for it
+// when it is not known, or the first node that can be reached when none
+// knows, which redirects writes once a leader is elected.
+func (c *Client) primary(ctx context.Context) string {
+	e := &c.endpoints
+	e.mutex.Lock()
+	primary := e.primary
+	urls := c.upFirst()
+	e.mutex.Unlock()
+	if primary != "" {
+		return primary
+	}
+
+	for _, u := range urls {
+		leader, err := c.leader(ctx, u)
+		if err != nil {
+			continue
+		}
+		if leader == "" {
+			// Not a cluster, or no leader yet.
+			return u
+		}
+		e.mutex.Lock()
+		e.primary = leader
+		e.mutex.Unlock()
+		return leader
+	}
+	return urls[0]
+}
+
+// leader asks the node at base for the URL of the leader: empty if it has
+// none or is not part of a cluster.
+func (c *Client) leader(ctx context.Context, base string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/cluster", nil)
+	if err != nil {
+		return "", err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.markDown(base)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp)
+	}
+	var members []struct {
+		API    string `json:"api"`
+		Leader bool   `json:"leader"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil {
+		return "", err
+	}
+	for _, m := range members {
+		if m.Leader && m.API != "" {
+			return strings.TrimSuffix(m.API, "/"), nil
+		}
+	}
+	return "", nil
+}
+
+// upFirst returns the endpoints, those that can be reached first. The
+// caller holds the mutex.
+func (c *Client) upFirst() []string {
+	e := &c.endpoints
+	now := time.Now()
+	var up, down []string
+	for _, u := range e.urls {
+		if now.After(e.down[u]) {
+			up = append(up, u)
+		} else {
+			down = append(down, u)
+		}
+	}
+	return append(up, down...)
+}
+
+// markDown passes over an endpoint that could not be reached for a while,
+// and forgets it as the primary so another is looked for.
+func (c *Client) markDown(base string) {
+	e := &c.endpoints
+	if len(e.urls) == 1 {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.down[base] = time.Now().Add(downTime)
+	if e.primary == base {
+		e.primary = ""
+	}
+}
+
+// forgetPrimary drops the primary when it refused a write, so the next
+// is sent wherever the nodes say the leader now is.
+func (c *Client) forgetPrimary(base string) {
+	e := &c.endpoints
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.primary == base {
+		e.primary = ""
+	}
+}
+
+// learnPrimary records the server a write was redirected to as the
+// primary.
+func (c *Client) learnPrimary(resp *http.Response, base string) {
+	if len(c.endpoints.urls) == 1 {
+		return
+	}
+	u := resp.Request.URL
+	if leader := u.Scheme + "://" + u.Host; leader != base {
+		e := &c.endpoints
+		e.mutex.Lock()
+		e.primary = leader
+		e.mutex.Unlock()
+	}
+}
+
+// isRead reports whether a request only reads, and may be served by a
+// replica.
+func isRead(method, path string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		p, _, _ := strings.Cut(path, "?")
+		return strings.HasPrefix(p, "/collections/") && strings.HasSuffix(p, "/aggregate")
+	}
+	return false
+}
+
+func parseBase(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid server URL %q - want http:// or https://", baseURL)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
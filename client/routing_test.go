@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeCluster is the servers of the nodes of a cluster replicating one
+// record. Followers redirect writes to the leader, as cluster nodes do,
+// and every node counts the record requests it serves.
+type fakeCluster struct {
+	mutex sync.Mutex
+	// leader is the URL of the leader, and members whether the nodes
+	// serve /cluster.
+	leader  string
+	members bool
+	value   []byte
+	hits    map[string]int
+	servers map[string]*httptest.Server
+	urls    []string
+}
+
+func newFakeCluster(t *testing.T, names ...string) *fakeCluster {
+	f := &fakeCluster{members: true, hits: map[string]int{}, servers: map[string]*httptest.Server{}}
+	for _, name := range names {
+		var self string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.serve(w, r, name, self)
+		}))
+		t.Cleanup(ts.Close)
+		self = ts.URL
+		f.servers[name] = ts
+		f.urls = append(f.urls, ts.URL)
+	}
+	f.leader = f.urls[0]
+	return f
+}
+
+func (f *fakeCluster) serve(w http.ResponseWriter, r *http.Request, name, self string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if r.URL.Path == "/cluster" {
+		if !f.members {
+			http.NotFound(w, r)
+			return
+		}
+		var members []map[string]any
+		for _, u := range f.urls {
+			members = append(members, map[string]any{"api": u, "leader": u == f.leader})
+		}
+		json.NewEncoder(w).Encode(members)
+		return
+	}
+
+	f.hits[name+" "+r.Method]++
+	switch {
+	case r.Method == http.MethodPut && self != f.leader:
+		http.Redirect(w, r, f.leader+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	case r.Method == http.MethodPut:
+		f.value, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	case f.value == nil:
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	default:
+		w.Write(f.value)
+	}
+}
+
+// take returns the hits counted since the last call.
+func (f *fakeCluster) take() map[string]int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	hits := f.hits
+	f.hits = map[string]int{}
+	return hits
+}
+
+func newClusterClient(t *testing.T, urls ...string) *Client {
+	t.Helper()
+	c, err := NewCluster(urls, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RetryBackoff = 0
+	return c
+}
+
+func TestClusterRouting(t *testing.T) {
+	f := newFakeCluster(t, "a", "b", "c")
+	c := newClusterClient(t, f.urls[2], f.urls[1], f.urls[0])
+	c.ReadPreference = ReadReplica
+
+	for i := 0; i < 6; i++ {
+		if err := c.Write("users", "ada", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := f.take(); hits["a PUT"] != 6 || len(hits) != 1 {
+		t.Errorf("writes served as %v, want all 6 by the leader a", hits)
+	}
+	for i := 0; i < 6; i++ {
+		var v int
+		if err := c.Read("users", "ada", &v); err != nil || v != 5 {
+			t.Fatalf("Read = %d, %v", v, err)
+		}
+	}
+	if hits := f.take(); hits["b GET"] != 3 || hits["c GET"] != 3 {
+		t.Errorf("replica reads served as %v, want 3 by each of b and c", hits)
+	}
+	var v int
+	if err := c.ReadContext(WithReadPreference(context.Background(), ReadPrimary), "users", "ada", &v); err != nil {
+		t.Fatal(err)
+	}
+	if hits := f.take(); hits["a GET"] != 1 || len(hits) != 1 {
+		t.Errorf("read from the primary served as %v, want by a", hits)
+	}
+
+	// The leader goes away and b is elected.
+	f.servers["a"].Close()
+	f.mutex.Lock()
+	f.leader = f.urls[1]
+	f.mutex.Unlock()
+	if err := c.Write("users", "ada", 9); err != nil {
+		t.Fatal(err)
+	}
+	if hits := f.take(); hits["b PUT"] != 1 {
+		t.Errorf("write after the failover served as %v, want by the new leader b", hits)
+	}
+	c.ReadPreference = ReadPrimaryPreferred
+	if err := c.Read("users", "ada", &v); err != nil || v != 9 {
+		t.Fatalf("Read after the failover = %d, %v", v, err)
+	}
+	if hits := f.take(); hits["b GET"] != 1 {
+		t.Errorf("read from the new primary served as %v, want by b", hits)
+	}
+}
+
+func TestClusterLearnsRedirect(t *testing.T) {
+	f := newFakeCluster(t, "a", "b")
+	f.members = false
+	c := newClusterClient(t, f.urls[1], f.urls[0])
+
+	// Without membership to ask, the first write goes to b, which
+	// redirects it to the leader, for the next to go there.
+	for i := 0; i < 2; i++ {
+		if err := c.Write("users", "ada", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := f.take(); hits["b PUT"] != 1 || hits["a PUT"] != 2 {
+		t.Errorf("writes served as %v, want one redirected by b and both by a", hits)
+	}
+}
+
+func TestNewCluster(t *testing.T) {
+	if _, err := NewCluster(nil, ""); err == nil {
+		t.Error("NewCluster accepted no servers")
+	}
+	if _, err := NewCluster([]string{"http://a", "b:8080"}, ""); err == nil {
+		t.Error("NewCluster accepted a URL without a scheme")
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// watchBuffer matches the Driver's, so a consumer falls behind at the same
+// point.
+const watchBuffer = 256
+
+// Watch streams the changes made to collection, or to every collection if
+// it is empty, like Driver.Watch: the channel is closed when stop is
+// called, the server drops the watcher for falling behind, or the
+// connection ends. Re-read the collection then to get back in sync.
+func (c *Client) Watch(collection string) (events <-chan database.Event, stop func(), err error) {
+	return c.WatchContext(context.Background(), collection)
+}
+
+func (c *Client) WatchContext(ctx context.Context, collection string) (events <-chan database.Event, stop func(), err error) {
+	path := "/watch"
+	if collection != "" {
+		path += "/" + url.PathEscape(collection)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	ch := make(chan database.Event, watchBuffer)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "event: reset" {
+				return
+			}
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var e struct {
+				Op         string `json:"op"`
+				Collection string `json:"collection"`
+				Key        string `json:"key"`
+				External   bool   `json:"external"`
+			}
+			if json.Unmarshal([]byte(data), &e) != nil {
+				return
+			}
+			event := database.Event{Op: database.EventPut, Collection: e.Collection, Key: e.Key, External: e.External}
+			if e.Op == database.EventDelete.String() {
+				event.Op = database.EventDelete
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, cancel, nil
+}
@@ -0,0 +1,372 @@
+// Package cluster replicates a database over several nodes with Raft: one
+// node, elected leader, takes the writes into its log, and every node
+// applies them to its own store once a majority has them, so the cluster
+// keeps serving while a minority of its nodes is down.
+//
+// A Node is a database.Database. Its writes go through the log and fail
+// with a *LeaderError off the leader; its reads are served from the local
+// store, and may miss the latest writes on followers. The records and
+// saved queries written through Nodes are replicated; everything else,
+// such as attachments and expiry, stays on the node it was made on.
+//
+// The first node bootstraps the cluster, and the others join it through
+// the HTTP server of any member, which should mount Handler:
+//
+//	node, err := cluster.Open(db, cluster.Config{ID: "a", Dir: "raft", Addr: "10.0.0.1:7000", API: "http://10.0.0.1:8080", Bootstrap: true})
+//	node, err := cluster.Open(db, cluster.Config{ID: "b", Dir: "raft", Addr: "10.0.0.2:7000", API: "http://10.0.0.2:8080", Join: []string{"http://10.0.0.1:8080"}})
+//
+// Every node probes the others through their servers, so ClusterStatus
+// reports which are down and how far behind each is, and Ready whether the
+// node follows a leader closely enough to serve.
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	defaultApplyTimeout = 10 * time.Second
+	retainSnapshots     = 2
+	maxPool             = 3
+	transportTimeout    = 10 * time.Second
+)
+
+var ErrNotLeader = errors.New("not the cluster leader")
+
+// LeaderError refuses a write made on a node that is not the leader,
+// naming the leader if there is one.
+type LeaderError struct {
+	// Leader is the leader, empty while one is elected.
+	Leader Member
+}
+
+func (e *LeaderError) Error() string {
+	if e.Leader.ID == "" {
+		return ErrNotLeader.Error() + " - no leader elected"
+	}
+	return fmt.Sprintf("%s - the leader is %s", ErrNotLeader, e.Leader.ID)
+}
+
+func (e *LeaderError) Unwrap() error {
+	return ErrNotLeader
+}
+
+// LeaderURL returns the URL of the leader's HTTP server, for the write to
+// be made there instead.
+func (e *LeaderError) LeaderURL() string {
+	return e.Leader.API
+}
+
+// Member is a node of the cluster.
+type Member struct {
+	ID string `json:"id"`
+	// Addr is where the node takes Raft messages, as host:port.
+	Addr string `json:"addr"`
+	// API is the URL of the node's HTTP server, if it has one.
+	API string `json:"api,omitempty"`
+	// Leader and Voter report the node's part in the cluster, in Members.
+	Leader bool `json:"leader,omitempty"`
+	Voter  bool `json:"voter,omitempty"`
+}
+
+type Config struct {
+	// ID names the node in the cluster. It must stay the same when the node
+	// restarts.
+	ID string
+	// Dir keeps the Raft log and snapshots, apart from the database.
+	Dir string
+	// Addr is the address to take Raft messages on, as host:port.
+	Addr string
+	// Advertise is the address the other nodes reach Addr at, Addr by
+	// default, which must then name a host.
+	Advertise string
+	// API is the URL of the node's HTTP server, which writes made on
+	// followers are redirected from.
+	API string
+	// Bootstrap starts a new cluster with the node as its only member. It
+	// is ignored once the node has state.
+	Bootstrap bool
+	// Join lists the URLs of the HTTP servers of members to ask to add a
+	// new node to the cluster through, in turn until one does. It is
+	// ignored once the node has state.
+	Join []string
+	// Token, if not empty, is sent as a bearer token to the servers of the
+	// other members, to join and probe them.
+	Token string
+	// ProbeInterval is how often the other members are asked for their
+	// status, 1s by default, and FailureTimeout how long one may go without
+	// answering before it is reported unhealthy, 5s by default. Followers
+	// not hearing from the leader for FailureTimeout are not ready.
+	ProbeInterval  time.Duration
+	FailureTimeout time.Duration
+	// MaxLag is how many committed entries the node may have yet to apply
+	// and still be ready, 1000 by default.
+	MaxLag uint64
+	// ApplyTimeout is how long a write waits to be committed, 10s by
+	// default.
+	ApplyTimeout time.Duration
+	// LogOutput takes the logs of Raft, stderr by default.
+	LogOutput io.Writer
+}
+
+// Node is a member of a cluster, replicating writes to the database it
+// was opened on. The database must use the JSON codec, as Export does, and
+// be written only through the Node.
+type Node struct {
+	db           *database.Driver
+	id           string
+	raft         *raft.Raft
+	fsm          *fsm
+	store        *boltStore
+	transport    *raft.NetworkTransport
+	logger       hclog.Logger
+	applyTimeout time.Duration
+	done         chan struct{}
+
+	probes         probes
+	probeInterval  time.Duration
+	failureTimeout time.Duration
+	maxLag         uint64
+}
+
+var _ database.Database = (*Node)(nil)
+
+// Open starts a node replicating to db, which it brings up to date with
+// the cluster: a node restarting loads its latest snapshot into db before
+// applying the log after it.
+func Open(db *database.Driver, config Config) (*Node, error) {
+	if config.ID == "" {
+		return nil, fmt.Errorf("missing node ID")
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("missing cluster directory - no place to keep the Raft log")
+	}
+	if config.Addr == "" {
+		return nil, fmt.Errorf("missing cluster address")
+	}
+	if config.Bootstrap && len(config.Join) > 0 {
+		return nil, fmt.Errorf("a node bootstrapping a cluster cannot join another")
+	}
+	if config.ApplyTimeout == 0 {
+		config.ApplyTimeout = defaultApplyTimeout
+	}
+	if config.ProbeInterval == 0 {
+		config.ProbeInterval = defaultProbeInterval
+	}
+	if config.FailureTimeout == 0 {
+		config.FailureTimeout = defaultFailureTimeout
+	}
+	if config.MaxLag == 0 {
+		config.MaxLag = defaultMaxLag
+	}
+	if config.LogOutput == nil {
+		config.LogOutput = os.Stderr
+	}
+	if config.Advertise == "" {
+		config.Advertise = config.Addr
+	}
+	advertise, err := net.ResolveTCPAddr("tcp", config.Advertise)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster advertise address: %w", err)
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	rc := raft.DefaultConfig()
+	rc.LocalID = raft.ServerID(config.ID)
+	rc.Logger = hclog.New(&hclog.LoggerOptions{Name: "raft", Level: hclog.Warn, Output: config.LogOutput})
+
+	n := &Node{
+		db:             db,
+		id:             config.ID,
+		logger:         hclog.New(&hclog.LoggerOptions{Name: "cluster", Level: hclog.Info, Output: config.LogOutput}),
+		applyTimeout:   config.ApplyTimeout,
+		done:           make(chan struct{}),
+		probes:         probes{members: make(map[string]*probe)},
+		probeInterval:  config.ProbeInterval,
+		failureTimeout: config.FailureTimeout,
+		maxLag:         config.MaxLag,
+	}
+	n.fsm = &fsm{db: db, dir: config.Dir, members: make(map[string]Member)}
+	if n.store, err = openBoltStore(filepath.Join(config.Dir, "raft.db")); err != nil {
+		return nil, err
+	}
+	snapshots, err := raft.NewFileSnapshotStore(config.Dir, retainSnapshots, config.LogOutput)
+	if err != nil {
+		n.store.Close()
+		return nil, err
+	}
+	if n.transport, err = raft.NewTCPTransport(config.Addr, advertise, maxPool, transportTimeout, config.LogOutput); err != nil {
+		n.store.Close()
+		return nil, err
+	}
+
+	existing, err := raft.HasExistingState(n.store, n.store, snapshots)
+	if err != nil {
+		n.close()
+		return nil, err
+	}
+	if config.Bootstrap && !existing {
+		err := raft.BootstrapCluster(rc, n.store, n.store, snapshots, n.transport, raft.Configuration{
+			Servers: []raft.Server{{ID: rc.LocalID, Address: n.transport.LocalAddr()}},
+		})
+		if err != nil {
+			n.close()
+			return nil, err
+		}
+	}
+	if n.raft, err = raft.NewRaft(rc, n.fsm, n.store, n.store, snapshots, n.transport); err != nil {
+		n.close()
+		return nil, err
+	}
+	self := Member{ID: config.ID, Addr: string(n.transport.LocalAddr()), API: config.API}
+	go n.announce(self)
+	go n.probe(config.ProbeInterval, config.Token)
+	if len(config.Join) > 0 && !existing {
+		go n.join(config.Join, config.Token, self)
+	}
+	return n, nil
+}
+
+// announce records the member's API URL in the cluster whenever the node
+// is elected leader and it has changed, so followers can redirect to it.
+func (n *Node) announce(self Member) {
+	leader := n.raft.LeaderCh()
+	for {
+		select {
+		case <-n.done:
+			return
+		case elected := <-leader:
+			if !elected {
+				continue
+			}
+			if m, ok := n.fsm.member(self.ID); ok && m == self {
+				continue
+			}
+			b, _ := json.Marshal(command{Op: opMember, Member: &self})
+			n.raft.Apply(b, n.applyTimeout)
+		}
+	}
+}
+
+// Close stops the node, leaving the database open.
+func (n *Node) Close() error {
+	close(n.done)
+	err := n.raft.Shutdown().Error()
+	return errors.Join(err, n.close())
+}
+
+func (n *Node) close() error {
+	var errs []error
+	if n.transport != nil {
+		errs = append(errs, n.transport.Close())
+	}
+	return errors.Join(append(errs, n.store.Close())...)
+}
+
+// IsLeader reports whether the node is the leader.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Leader returns the leader, or false while one is elected.
+func (n *Node) Leader() (Member, bool) {
+	addr, id := n.raft.LeaderWithID()
+	if id == "" {
+		return Member{}, false
+	}
+	m, _ := n.fsm.member(string(id))
+	m.ID, m.Addr, m.Leader, m.Voter = string(id), string(addr), true, true
+	return m, true
+}
+
+// Members returns the nodes of the cluster, sorted by ID.
+func (n *Node) Members() ([]Member, error) {
+	f := n.raft.GetConfiguration()
+	if err := f.Error(); err != nil {
+		return nil, err
+	}
+	_, leader := n.raft.LeaderWithID()
+	var members []Member
+	for _, s := range f.Configuration().Servers {
+		m, _ := n.fsm.member(string(s.ID))
+		m.ID, m.Addr = string(s.ID), string(s.Address)
+		m.Leader = s.ID == leader
+		m.Voter = s.Suffrage == raft.Voter
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members, nil
+}
+
+// AddMember adds a node to the cluster as a voter, or updates its
+// addresses. It must be called on the leader.
+func (n *Node) AddMember(m Member) error {
+	if m.ID == "" || m.Addr == "" {
+		return fmt.Errorf("missing member ID or address")
+	}
+	if err := n.raft.AddVoter(raft.ServerID(m.ID), raft.ServerAddress(m.Addr), 0, n.applyTimeout).Error(); err != nil {
+		return n.leaderError(err)
+	}
+	_, err := n.apply(command{Op: opMember, Member: &Member{ID: m.ID, Addr: m.Addr, API: m.API}})
+	return err
+}
+
+// RemoveMember removes a node from the cluster. It must be called on the
+// leader.
+func (n *Node) RemoveMember(id string) error {
+	if err := n.raft.RemoveServer(raft.ServerID(id), 0, n.applyTimeout).Error(); err != nil {
+		return n.leaderError(err)
+	}
+	_, err := n.apply(command{Op: opForgetMember, Name: id})
+	return err
+}
+
+// apply commits a command through the log and returns what applying it
+// returned on this node.
+func (n *Node) apply(cmd command) (string, error) {
+	r, err := n.applyResult(cmd)
+	return r.rev, err
+}
+
+// applyResult is apply returning all of the result, such as the report of
+// a dropped collection.
+func (n *Node) applyResult(cmd command) (result, error) {
+	if !n.IsLeader() {
+		return result{}, n.leaderError(raft.ErrNotLeader)
+	}
+	b, err := json.Marshal(cmd)
+	if err != nil {
+		return result{}, err
+	}
+	f := n.raft.Apply(b, n.applyTimeout)
+	if err := f.Error(); err != nil {
+		return result{}, n.leaderError(err)
+	}
+	r := f.Response().(result)
+	return r, r.err
+}
+
+// leaderError turns the errors of Raft refusing a change off the leader
+// into a *LeaderError.
+func (n *Node) leaderError(err error) error {
+	if !errors.Is(err, raft.ErrNotLeader) && !errors.Is(err, raft.ErrLeadershipLost) && !errors.Is(err, raft.ErrLeadershipTransferInProgress) {
+		return err
+	}
+	leader, _ := n.Leader()
+	return &LeaderError{Leader: leader}
+}
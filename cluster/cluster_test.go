@@ -0,0 +1,199 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/dbtest"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !cond(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// openDB opens a database of its own, closed when the test ends.
+func openDB(t *testing.T) *database.Driver {
+	t.Helper()
+	return dbtest.NewWithOptions(t, &database.Options{TTLSweepInterval: -1})
+}
+
+// openNode opens the node id on db, keeping its Raft state in root. The
+// config sets the rest; its Addr defaults to a free port.
+func openNode(t *testing.T, db *database.Driver, root, id string, config Config) *Node {
+	t.Helper()
+	config.ID, config.Dir, config.LogOutput = id, filepath.Join(root, "raft-"+id), io.Discard
+	if config.Addr == "" {
+		config.Addr = freeAddr(t)
+	}
+	n, err := Open(db, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// member returns the Member the leader adds n as.
+func member(n *Node, api string) Member {
+	return Member{ID: n.id, Addr: string(n.transport.LocalAddr()), API: api}
+}
+
+func TestCluster(t *testing.T) {
+	root := t.TempDir()
+	var (
+		nodes []*Node
+		dbs   []*database.Driver
+	)
+	for i := 0; i < 3; i++ {
+		db := openDB(t)
+		dbs = append(dbs, db)
+		nodes = append(nodes, openNode(t, db, root, fmt.Sprint("n", i), Config{API: fmt.Sprintf("http://node%d", i), Bootstrap: i == 0}))
+		if i == 0 {
+			waitFor(t, "a leader", nodes[0].IsLeader)
+			if err := nodes[0].Write("users", "a", map[string]int{"n": 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := nodes[0].SaveQuery("all", database.Query{Collection: "users"}); err != nil {
+				t.Fatal(err)
+			}
+			// Members joining later catch up from the snapshot and the
+			// log after it.
+			if err := nodes[0].raft.Snapshot().Error(); err != nil {
+				t.Fatal(err)
+			}
+			if err := nodes[0].Write("users", "b", map[string]int{"n": 2}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := 1; i < 3; i++ {
+		if err := nodes[0].AddMember(member(nodes[i], fmt.Sprintf("http://node%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < 3; i++ {
+		waitFor(t, "replication", func() bool {
+			keys, _ := dbs[i].Keys("users")
+			return len(keys) == 2
+		})
+		if q, err := dbs[i].SavedQuery("all"); err != nil || q.Collection != "users" {
+			t.Errorf("saved query on node %d = %+v, %v", i, q, err)
+		}
+	}
+	if members, err := nodes[1].Members(); err != nil || len(members) != 3 || !members[0].Leader {
+		t.Errorf("Members = %+v, %v; want 3, n0 leading", members, err)
+	}
+
+	rev, err := nodes[0].WriteIfRevision("users", "c", map[string]int{"n": 3}, "")
+	if err != nil || rev == "" {
+		t.Fatalf("WriteIfRevision = %q, %v", rev, err)
+	}
+	if _, err := nodes[0].WriteIfRevision("users", "c", map[string]int{"n": 4}, ""); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("WriteIfRevision of an existing record = %v, want ErrConflict", err)
+	}
+
+	err = nodes[1].Write("users", "x", 1)
+	var leader *LeaderError
+	if !errors.As(err, &leader) || !errors.Is(err, ErrNotLeader) || leader.LeaderURL() != "http://node0" {
+		t.Errorf("Write on a follower = %v, want a LeaderError naming http://node0", err)
+	}
+
+	// The others elect a new leader.
+	nodes[0].Close()
+	waitFor(t, "a new leader", func() bool { return nodes[1].IsLeader() || nodes[2].IsLeader() })
+	next, other := nodes[1], dbs[2]
+	if nodes[2].IsLeader() {
+		next, other = nodes[2], dbs[1]
+	}
+	if err := next.Delete("users", "a"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the delete replicated", func() bool {
+		keys, _ := other.Keys("users")
+		return len(keys) == 2
+	})
+
+	// The old leader catches up when it comes back.
+	n0 := openNode(t, dbs[0], root, "n0", Config{Addr: string(nodes[0].transport.LocalAddr())})
+	waitFor(t, "the restarted node catching up", func() bool {
+		keys, _ := dbs[0].Keys("users")
+		return len(keys) == 2
+	})
+	for _, n := range []*Node{n0, nodes[1], nodes[2]} {
+		n.Close()
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	root := t.TempDir()
+	db := openDB(t)
+	n := openNode(t, db, root, "n0", Config{Bootstrap: true})
+	defer n.Close()
+	waitFor(t, "a leader", n.IsLeader)
+	for _, key := range []string{"a", "b"} {
+		if err := n.Write("users", key, map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := n.DropCollection(context.Background(), "users", true)
+	if err != nil || !report.DryRun || report.Count() != 2 {
+		t.Fatalf("DropCollection dry run = %+v, %v", report, err)
+	}
+	report, err = n.DropCollection(context.Background(), "users", false)
+	if err != nil || report.DryRun || report.Count() != 2 {
+		t.Fatalf("DropCollection = %+v, %v", report, err)
+	}
+	if _, err := db.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+
+	// Deletes without a key, logged before opDrop, still drop the
+	// collection.
+	if err := n.Write("users", "c", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.applyResult(command{Op: opDelete, Collection: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys after a delete without a key = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	root := t.TempDir()
+	db := openDB(t)
+	for _, config := range []Config{
+		{Dir: root, Addr: "127.0.0.1:0"},
+		{ID: "a", Addr: "127.0.0.1:0"},
+		{ID: "a", Dir: root},
+		{ID: "a", Dir: root, Addr: "127.0.0.1:0", Bootstrap: true, Join: []string{"http://a"}},
+	} {
+		if _, err := Open(db, config); err == nil {
+			t.Errorf("Open(%+v) succeeded", config)
+		}
+	}
+}
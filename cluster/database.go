@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func (n *Node) Write(collection, resource string, v interface{}) error {
+	return n.WriteContext(context.Background(), collection, resource, v)
+}
+
+// WriteContext writes a record through the log, as the principal in ctx.
+func (n *Node) WriteContext(ctx context.Context, collection, resource string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = n.apply(command{Op: opWrite, Collection: collection, Key: resource, Value: b, Principal: database.PrincipalFrom(ctx)})
+	return err
+}
+
+func (n *Node) WriteIfRevision(collection, resource string, v interface{}, rev string) (string, error) {
+	return n.WriteIfRevisionContext(context.Background(), collection, resource, v, rev)
+}
+
+func (n *Node) WriteIfRevisionContext(ctx context.Context, collection, resource string, v interface{}, rev string) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return n.apply(command{Op: opWriteIf, Collection: collection, Key: resource, Value: b, Rev: rev, Principal: database.PrincipalFrom(ctx)})
+}
+
+func (n *Node) Delete(collection, resource string) error {
+	return n.DeleteContext(context.Background(), collection, resource)
+}
+
+func (n *Node) DeleteContext(ctx context.Context, collection, resource string) error {
+	_, err := n.apply(command{Op: opDelete, Collection: collection, Key: resource, Principal: database.PrincipalFrom(ctx)})
+	return err
+}
+
+// DropCollection drops a collection through the log. A dry run is answered
+// by the local store.
+func (n *Node) DropCollection(ctx context.Context, collection string, dryRun bool) (*database.DeleteReport, error) {
+	if dryRun {
+		return n.db.DropCollection(ctx, collection, true)
+	}
+	r, err := n.applyResult(command{Op: opDrop, Collection: collection, Principal: database.PrincipalFrom(ctx)})
+	return r.report, err
+}
+
+func (n *Node) DeleteIfRevision(collection, resource, rev string) error {
+	return n.DeleteIfRevisionContext(context.Background(), collection, resource, rev)
+}
+
+func (n *Node) DeleteIfRevisionContext(ctx context.Context, collection, resource, rev string) error {
+	_, err := n.apply(command{Op: opDeleteIf, Collection: collection, Key: resource, Rev: rev, Principal: database.PrincipalFrom(ctx)})
+	return err
+}
+
+func (n *Node) SaveQuery(name string, q database.Query) error {
+	return n.SaveQueryContext(context.Background(), name, q)
+}
+
+func (n *Node) SaveQueryContext(ctx context.Context, name string, q database.Query) error {
+	_, err := n.apply(command{Op: opSaveQuery, Name: name, Query: &q, Principal: database.PrincipalFrom(ctx)})
+	return err
+}
+
+func (n *Node) DeleteQuery(name string) error {
+	return n.DeleteQueryContext(context.Background(), name)
+}
+
+func (n *Node) DeleteQueryContext(ctx context.Context, name string) error {
+	_, err := n.apply(command{Op: opDeleteQuery, Name: name, Principal: database.PrincipalFrom(ctx)})
+	return err
+}
+
+// The reads are served by the local store.
+
+func (n *Node) Read(collection, resource string, v interface{}) error {
+	return n.db.Read(collection, resource, v)
+}
+
+func (n *Node) ReadContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return n.db.ReadContext(ctx, collection, resource, v)
+}
+
+func (n *Node) ReadResolved(collection, resource string, v interface{}, depth int) error {
+	return n.db.ReadResolved(collection, resource, v, depth)
+}
+
+func (n *Node) ReadResolvedContext(ctx context.Context, collection, resource string, v interface{}, depth int) error {
+	return n.db.ReadResolvedContext(ctx, collection, resource, v, depth)
+}
+
+func (n *Node) ReadAll(collection string) ([]string, error) {
+	return n.db.ReadAll(collection)
+}
+
+func (n *Node) ReadAllContext(ctx context.Context, collection string) ([]string, error) {
+	return n.db.ReadAllContext(ctx, collection)
+}
+
+func (n *Node) ReadAllResolved(collection string, depth int) ([]string, error) {
+	return n.db.ReadAllResolved(collection, depth)
+}
+
+func (n *Node) ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error) {
+	return n.db.ReadAllResolvedContext(ctx, collection, depth)
+}
+
+func (n *Node) Keys(collection string) ([]string, error) {
+	return n.db.Keys(collection)
+}
+
+func (n *Node) KeysContext(ctx context.Context, collection string) ([]string, error) {
+	return n.db.KeysContext(ctx, collection)
+}
+
+func (n *Node) ReadRevision(collection, resource string, v interface{}) (string, error) {
+	return n.db.ReadRevision(collection, resource, v)
+}
+
+func (n *Node) ReadRevisionContext(ctx context.Context, collection, resource string, v interface{}) (string, error) {
+	return n.db.ReadRevisionContext(ctx, collection, resource, v)
+}
+
+func (n *Node) Aggregate(collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return n.db.Aggregate(collection, pipeline)
+}
+
+func (n *Node) AggregateContext(ctx context.Context, collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return n.db.AggregateContext(ctx, collection, pipeline)
+}
+
+func (n *Node) SavedQuery(name string) (database.Query, error) {
+	return n.db.SavedQuery(name)
+}
+
+func (n *Node) SavedQueryContext(ctx context.Context, name string) (database.Query, error) {
+	return n.db.SavedQueryContext(ctx, name)
+}
+
+func (n *Node) SavedQueries() ([]string, error) {
+	return n.db.SavedQueries()
+}
+
+func (n *Node) SavedQueriesContext(ctx context.Context) ([]string, error) {
+	return n.db.SavedQueriesContext(ctx)
+}
+
+func (n *Node) RunQuery(name string) ([]database.QueryResult, error) {
+	return n.db.RunQuery(name)
+}
+
+func (n *Node) RunQueryContext(ctx context.Context, name string) ([]database.QueryResult, error) {
+	return n.db.RunQueryContext(ctx, name)
+}
+
+// Watch streams the changes applied to the local store, which every node
+// sees as the log is applied.
+func (n *Node) Watch(collection string) (events <-chan database.Event, stop func(), err error) {
+	return n.db.Watch(collection)
+}
+
+func (n *Node) WatchContext(ctx context.Context, collection string) (events <-chan database.Event, stop func(), err error) {
+	return n.db.WatchContext(ctx, collection)
+}
@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// The operations of commands.
+const (
+	opWrite        = "write"
+	opWriteIf      = "write-if"
+	opDelete       = "delete"
+	opDeleteIf     = "delete-if"
+	opDrop         = "drop"
+	opSaveQuery    = "save-query"
+	opDeleteQuery  = "delete-query"
+	opMember       = "member"
+	opForgetMember = "forget-member"
+)
+
+// command is a mutation in the Raft log, which every node applies to its
+// store. The principal that made it is applied as, so it is authorized
+// the same way everywhere.
+type command struct {
+	Op         string              `json:"op"`
+	Collection string              `json:"collection,omitempty"`
+	Key        string              `json:"key,omitempty"`
+	Value      json.RawMessage     `json:"value,omitempty"`
+	Rev        string              `json:"rev,omitempty"`
+	Name       string              `json:"name,omitempty"`
+	Query      *database.Query     `json:"query,omitempty"`
+	Principal  *database.Principal `json:"principal,omitempty"`
+	Member     *Member             `json:"member,omitempty"`
+}
+
+// result is what applying a command returned.
+type result struct {
+	rev    string
+	report *database.DeleteReport
+	err    error
+}
+
+// fsm applies the Raft log to the store of the node.
+type fsm struct {
+	db *database.Driver
+	// dir holds the snapshots being taken.
+	dir string
+
+	mutex   sync.Mutex
+	members map[string]Member
+}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	var cmd command
+	if err := json.Unmarshal(l.Data, &cmd); err != nil {
+		return result{err: fmt.Errorf("invalid command at index %d: %w", l.Index, err)}
+	}
+	ctx := context.Background()
+	if cmd.Principal != nil {
+		ctx = database.WithPrincipal(ctx, cmd.Principal)
+	}
+
+	var r result
+	switch cmd.Op {
+	case opWrite:
+		r.err = f.db.WriteContext(ctx, cmd.Collection, cmd.Key, cmd.Value)
+	case opWriteIf:
+		r.rev, r.err = f.db.WriteIfRevisionContext(ctx, cmd.Collection, cmd.Key, cmd.Value, cmd.Rev)
+	case opDelete:
+		if cmd.Key == "" {
+			// Logged before whole collections were dropped with opDrop.
+			r.report, r.err = f.db.DropCollection(ctx, cmd.Collection, false)
+			break
+		}
+		r.err = f.db.DeleteContext(ctx, cmd.Collection, cmd.Key)
+	case opDrop:
+		r.report, r.err = f.db.DropCollection(ctx, cmd.Collection, false)
+	case opDeleteIf:
+		r.err = f.db.DeleteIfRevisionContext(ctx, cmd.Collection, cmd.Key, cmd.Rev)
+	case opSaveQuery:
+		r.err = f.db.SaveQueryContext(ctx, cmd.Name, *cmd.Query)
+	case opDeleteQuery:
+		r.err = f.db.DeleteQueryContext(ctx, cmd.Name)
+	case opMember:
+		f.mutex.Lock()
+		f.members[cmd.Member.ID] = *cmd.Member
+		f.mutex.Unlock()
+	case opForgetMember:
+		f.mutex.Lock()
+		delete(f.members, cmd.Name)
+		f.mutex.Unlock()
+	default:
+		r.err = fmt.Errorf("unknown command %q at index %d", cmd.Op, l.Index)
+	}
+	return r
+}
+
+func (f *fsm) member(id string) (Member, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	m, ok := f.members[id]
+	return m, ok
+}
+
+// snapshotHeader is the first line of a snapshot, before the records as
+// Export writes them.
+type snapshotHeader struct {
+	Members map[string]Member         `json:"members"`
+	Queries map[string]database.Query `json:"queries"`
+}
+
+// Snapshot exports the store into a temp file, as the log goes on being
+// applied while Raft persists it.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	header := snapshotHeader{Queries: make(map[string]database.Query)}
+	f.mutex.Lock()
+	header.Members = make(map[string]Member, len(f.members))
+	for id, m := range f.members {
+		header.Members[id] = m
+	}
+	f.mutex.Unlock()
+	names, err := f.db.SavedQueries()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if header.Queries[name], err = f.db.SavedQuery(name); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.CreateTemp(f.dir, "snapshot-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	s := &snapshot{file: file}
+	w := bufio.NewWriter(file)
+	if err := json.NewEncoder(w).Encode(header); err != nil {
+		s.Release()
+		return nil, err
+	}
+	if _, err := f.db.Export(w); err != nil {
+		s.Release()
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		s.Release()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Restore replaces the store with a snapshot, as a node does far behind
+// the leader or restarting.
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if err := f.clear(); err != nil {
+		return err
+	}
+	for name, q := range header.Queries {
+		if err := f.db.SaveQuery(name, q); err != nil {
+			return err
+		}
+	}
+	if _, err := f.db.Import(io.MultiReader(dec.Buffered(), rc)); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.members = header.Members
+	if f.members == nil {
+		f.members = make(map[string]Member)
+	}
+	return nil
+}
+
+// clear deletes every collection and saved query of the store.
+func (f *fsm) clear() error {
+	collections, err := f.db.Collections()
+	if err != nil {
+		return err
+	}
+	namespaces, err := f.db.Namespaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range namespaces {
+		ns := f.db.Namespace(name)
+		names, err := ns.Collections()
+		if err != nil {
+			return err
+		}
+		for _, c := range names {
+			if _, err := ns.DropCollection(c, false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range collections {
+		if _, err := f.db.DropCollection(context.Background(), c, false); err != nil {
+			return err
+		}
+	}
+	names, err := f.db.SavedQueries()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := f.db.DeleteQuery(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot is a store exported by fsm.Snapshot.
+type snapshot struct {
+	file *os.File
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if _, err := io.Copy(sink, s.file); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
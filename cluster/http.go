@@ -0,0 +1,160 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// Handler serves the membership of the cluster, to mount next to the
+// routes of the node's HTTP server:
+//
+//	GET    /cluster                the members, as JSON
+//	GET    /cluster/status         the ClusterStatus, as JSON
+//	GET    /cluster/node           the NodeStatus of this node, as JSON
+//	POST   /cluster/members        add the member in the JSON body
+//	DELETE /cluster/members/{id}   remove a member
+//
+// Changes made on a follower are redirected to the leader, and need a
+// principal with every permission on every collection when the server
+// authenticates requests.
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cluster", n.serveMembers)
+	mux.HandleFunc("GET /cluster/status", n.serveClusterStatus)
+	mux.HandleFunc("GET /cluster/node", n.serveStatus)
+	mux.HandleFunc("POST /cluster/members", n.serveAdd)
+	mux.HandleFunc("DELETE /cluster/members/{id}", n.serveRemove)
+	return mux
+}
+
+func (n *Node) serveMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := n.Members()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (n *Node) serveClusterStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := n.ClusterStatus()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (n *Node) serveStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, n.Status())
+}
+
+func (n *Node) serveAdd(w http.ResponseWriter, r *http.Request) {
+	if !admin(w, r) {
+		return
+	}
+	var m Member
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if m.ID == "" || m.Addr == "" {
+		writeError(w, http.StatusBadRequest, "missing member ID or address")
+		return
+	}
+	n.serveChange(w, r, n.AddMember(m))
+}
+
+func (n *Node) serveRemove(w http.ResponseWriter, r *http.Request) {
+	if !admin(w, r) {
+		return
+	}
+	n.serveChange(w, r, n.RemoveMember(r.PathValue("id")))
+}
+
+func (n *Node) serveChange(w http.ResponseWriter, r *http.Request, err error) {
+	var leader *LeaderError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.As(err, &leader) && leader.LeaderURL() != "":
+		http.Redirect(w, r, strings.TrimSuffix(leader.LeaderURL(), "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	case errors.As(err, &leader):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// admin reports whether the principal of a request, if any, may change the
+// membership, writing the refusal if not.
+func admin(w http.ResponseWriter, r *http.Request) bool {
+	p := database.PrincipalFrom(r.Context())
+	if p == nil || p.Role.Allows("*", database.PermAll) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, fmt.Sprintf("%s: %s cannot change the cluster", database.ErrPermissionDenied, p.Name))
+	return false
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Join asks the cluster, through the HTTP server of any of its nodes at
+// url, to add a member. A token, if not empty, is sent as a bearer token.
+func Join(ctx context.Context, url, token string, m Member) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/cluster/members", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{CheckRedirect: func(next *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		// Sent on to the leader, which the client drops it for.
+		if token != "" {
+			next.Header.Set("Authorization", "Bearer "+token)
+		}
+		return nil
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(msg, &body) == nil && body.Error != "" {
+			return fmt.Errorf("joining the cluster: %s", body.Error)
+		}
+		return fmt.Errorf("joining the cluster: %s", resp.Status)
+	}
+	return nil
+}
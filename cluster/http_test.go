@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// lateHandler serves the Handler of a node opened after its server, which
+// the node needs the URL of.
+type lateHandler struct {
+	h http.Handler
+}
+
+func (l *lateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.h.ServeHTTP(w, r)
+}
+
+// serveNode opens the node id with a server mounting its Handler.
+func serveNode(t *testing.T, root, id string, config Config) (*Node, *httptest.Server) {
+	t.Helper()
+	h := &lateHandler{}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	config.API = ts.URL
+	n := openNode(t, openDB(t), root, id, config)
+	h.h = n.Handler()
+	return n, ts
+}
+
+func TestJoin(t *testing.T) {
+	root := t.TempDir()
+	a, tsA := serveNode(t, root, "a", Config{Bootstrap: true})
+	defer a.Close()
+	waitFor(t, "a leader", a.IsLeader)
+	b, tsB := serveNode(t, root, "b", Config{})
+	defer b.Close()
+	c, _ := serveNode(t, root, "c", Config{})
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := Join(ctx, tsA.URL, "", member(b, "")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "b to know the leader's server", func() bool {
+		m, ok := b.Leader()
+		return ok && m.API == tsA.URL
+	})
+	// Through the follower, which redirects to the leader.
+	if err := Join(ctx, tsB.URL, "", member(c, "")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(tsB.URL + "/cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var members []Member
+	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil || len(members) != 3 {
+		t.Errorf("GET /cluster = %+v, %v; want 3 members", members, err)
+	}
+
+	if err := Join(ctx, tsA.URL, "", Member{ID: "d"}); err == nil || !strings.Contains(err.Error(), "missing member ID or address") {
+		t.Errorf("Join without an address = %v", err)
+	}
+}
+
+func TestHandlerAdmin(t *testing.T) {
+	root := t.TempDir()
+	n := openNode(t, openDB(t), root, "a", Config{Bootstrap: true})
+	defer n.Close()
+
+	reader := &database.Principal{Name: "reader", Role: database.ReadOnlyRole("reader")}
+	r := httptest.NewRequest("DELETE", "/cluster/members/b", nil)
+	r = r.WithContext(database.WithPrincipal(r.Context(), reader))
+	rec := httptest.NewRecorder()
+	n.Handler().ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("membership change by a reader = %d %s, want 403", rec.Code, rec.Body)
+	}
+}
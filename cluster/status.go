@@ -0,0 +1,263 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	defaultProbeInterval  = time.Second
+	defaultFailureTimeout = 5 * time.Second
+	defaultMaxLag         = 1000
+	// joinRetry is how long to wait before asking to join again.
+	joinRetry = 2 * time.Second
+)
+
+// NodeStatus is the replication state of one node, as it reports it.
+type NodeStatus struct {
+	ID string `json:"id"`
+	// State is leader, follower or candidate.
+	State string `json:"state"`
+	Term  uint64 `json:"term"`
+	// LastIndex is the last entry of its log, CommitIndex the last it
+	// knows a majority has and AppliedIndex the last in its store.
+	LastIndex    uint64 `json:"last_index"`
+	CommitIndex  uint64 `json:"commit_index"`
+	AppliedIndex uint64 `json:"applied_index"`
+	// LastContact is when a follower last heard from the leader.
+	LastContact time.Time `json:"last_contact,omitzero"`
+}
+
+// MemberStatus is a member of the cluster as a node sees it.
+type MemberStatus struct {
+	Member
+	// Healthy reports that the node answered a probe within the failure
+	// timeout, or is the node itself.
+	Healthy bool `json:"healthy"`
+	// LastSeen is when it last answered.
+	LastSeen time.Time `json:"last_seen,omitzero"`
+	// Error is why the last probe failed, if it did.
+	Error string `json:"error,omitempty"`
+	// Status is what it last reported, nil if it never answered.
+	Status *NodeStatus `json:"status,omitempty"`
+	// Lag is how many committed entries it has yet to apply to its store,
+	// against the commit index of the leader.
+	Lag uint64 `json:"lag"`
+}
+
+// ClusterStatus is the state of the cluster as a node sees it.
+type ClusterStatus struct {
+	Self    NodeStatus     `json:"self"`
+	Leader  string         `json:"leader,omitempty"`
+	Members []MemberStatus `json:"members"`
+}
+
+// Status returns the replication state of the node.
+func (n *Node) Status() NodeStatus {
+	s := NodeStatus{
+		ID:           n.id,
+		State:        strings.ToLower(n.raft.State().String()),
+		Term:         n.raft.CurrentTerm(),
+		LastIndex:    n.raft.LastIndex(),
+		CommitIndex:  n.raft.CommitIndex(),
+		AppliedIndex: n.raft.AppliedIndex(),
+	}
+	if s.State != "leader" {
+		s.LastContact = n.raft.LastContact()
+	}
+	return s
+}
+
+// ClusterStatus returns the members of the cluster with their health and
+// replication lag, as the node last probed them.
+func (n *Node) ClusterStatus() (ClusterStatus, error) {
+	members, err := n.Members()
+	if err != nil {
+		return ClusterStatus{}, err
+	}
+	status := ClusterStatus{Self: n.Status()}
+	// Lag is counted against the leader's commit index, or the node's own
+	// when the leader has not been heard from.
+	commit := status.Self.CommitIndex
+	n.probes.mutex.Lock()
+	defer n.probes.mutex.Unlock()
+	for _, m := range members {
+		if m.Leader {
+			status.Leader = m.ID
+			if p, ok := n.probes.members[m.ID]; ok && p.status != nil {
+				commit = max(commit, p.status.CommitIndex)
+			}
+		}
+	}
+	for _, m := range members {
+		ms := MemberStatus{Member: m}
+		if m.ID == n.id {
+			self := status.Self
+			ms.Healthy, ms.LastSeen, ms.Status = true, time.Now(), &self
+		} else if p, ok := n.probes.members[m.ID]; ok {
+			ms.Healthy = time.Since(p.seen) < n.failureTimeout
+			ms.LastSeen, ms.Error, ms.Status = p.seen, p.err, p.status
+		}
+		if ms.Status != nil && ms.Status.AppliedIndex < commit {
+			ms.Lag = commit - ms.Status.AppliedIndex
+		}
+		status.Members = append(status.Members, ms)
+	}
+	return status, nil
+}
+
+// Ready reports whether the node can serve: a leader is elected, heard
+// from within the failure timeout, and the node has applied all but at
+// most the maximum lag of the entries it knows are committed.
+func (n *Node) Ready(ctx context.Context) error {
+	s := n.Status()
+	if _, ok := n.Leader(); !ok {
+		return fmt.Errorf("%w: no cluster leader elected", database.ErrNotReady)
+	}
+	if s.State != "leader" && time.Since(s.LastContact) > n.failureTimeout {
+		return fmt.Errorf("%w: no contact with the cluster leader since %s", database.ErrNotReady, s.LastContact.Format(time.RFC3339))
+	}
+	if lag := s.CommitIndex - min(s.AppliedIndex, s.CommitIndex); lag > n.maxLag {
+		return fmt.Errorf("%w: %d committed entries behind", database.ErrNotReady, lag)
+	}
+	return nil
+}
+
+// probes are the results of probing the other members.
+type probes struct {
+	mutex   sync.Mutex
+	members map[string]*probe
+}
+
+type probe struct {
+	seen   time.Time
+	err    string
+	status *NodeStatus
+	// down is set once the member is reported unreachable.
+	down bool
+}
+
+// probe asks the other members for their status every interval, through
+// their HTTP servers, until the node is closed.
+func (n *Node) probe(interval time.Duration, token string) {
+	client := &http.Client{Timeout: interval}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		}
+		members, err := n.Members()
+		if err != nil {
+			continue
+		}
+		var wg sync.WaitGroup
+		for _, m := range members {
+			if m.ID == n.id || m.API == "" {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				status, err := fetchStatus(client, m.API, token)
+				n.recordProbe(m.ID, status, err)
+			}()
+		}
+		wg.Wait()
+		n.forgetProbes(members)
+	}
+}
+
+func fetchStatus(client *http.Client, api, token string) (*NodeStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(api, "/")+"/cluster/node", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status probe returned %s", resp.Status)
+	}
+	var s NodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// recordProbe records the result of probing a member, logging when it
+// goes down or comes back.
+func (n *Node) recordProbe(id string, status *NodeStatus, err error) {
+	n.probes.mutex.Lock()
+	defer n.probes.mutex.Unlock()
+	p, ok := n.probes.members[id]
+	if !ok {
+		p = &probe{}
+		n.probes.members[id] = p
+	}
+	if err != nil {
+		p.err = err.Error()
+		if !p.down && time.Since(p.seen) >= n.failureTimeout {
+			p.down = true
+			n.logger.Warn("cluster member unreachable", "id", id, "error", err)
+		}
+		return
+	}
+	if p.down {
+		p.down = false
+		n.logger.Info("cluster member reachable again", "id", id)
+	}
+	p.seen, p.err, p.status = time.Now(), "", status
+}
+
+// forgetProbes drops the probes of nodes no longer members.
+func (n *Node) forgetProbes(members []Member) {
+	current := make(map[string]bool, len(members))
+	for _, m := range members {
+		current[m.ID] = true
+	}
+	n.probes.mutex.Lock()
+	defer n.probes.mutex.Unlock()
+	for id := range n.probes.members {
+		if !current[id] {
+			delete(n.probes.members, id)
+		}
+	}
+}
+
+// join asks to be added to the cluster through each of urls in turn until
+// one lets the node in, or it is closed.
+func (n *Node) join(urls []string, token string, self Member) {
+	for {
+		for _, url := range urls {
+			ctx, cancel := context.WithTimeout(context.Background(), n.applyTimeout)
+			err := Join(ctx, url, token, self)
+			cancel()
+			if err == nil {
+				n.logger.Info("joined the cluster", "through", url)
+				return
+			}
+			n.logger.Warn("joining the cluster failed", "through", url, "error", err)
+		}
+		select {
+		case <-n.done:
+			return
+		case <-time.After(joinRetry):
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestClusterStatus(t *testing.T) {
+	root := t.TempDir()
+	probing := Config{ProbeInterval: 50 * time.Millisecond, FailureTimeout: 500 * time.Millisecond}
+	boot := probing
+	boot.Bootstrap = true
+	a, tsA := serveNode(t, root, "a", boot)
+	waitFor(t, "a leader", a.IsLeader)
+	joining := probing
+	// The first server to ask is down, so the next is tried.
+	joining.Join = []string{"http://127.0.0.1:1", tsA.URL}
+	b, tsB := serveNode(t, root, "b", joining)
+	c, tsC := serveNode(t, root, "c", joining)
+	waitFor(t, "b and c to join", func() bool {
+		members, _ := a.Members()
+		return len(members) == 3
+	})
+
+	for i := 0; i < 20; i++ {
+		if err := a.Write("users", fmt.Sprint("u", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "every member healthy and caught up", func() bool {
+		s, _ := b.ClusterStatus()
+		for _, m := range s.Members {
+			if !m.Healthy || m.Lag != 0 || m.Status == nil {
+				return false
+			}
+		}
+		return len(s.Members) == 3 && s.Leader == "a"
+	})
+	for _, n := range []*Node{a, b, c} {
+		if err := n.Ready(context.Background()); err != nil {
+			t.Errorf("node %s not ready: %v", n.id, err)
+		}
+	}
+
+	resp, err := http.Get(tsB.URL + "/cluster/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s ClusterStatus
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+	if err != nil || s.Self.ID != "b" || s.Self.State != "follower" || len(s.Members) != 3 {
+		t.Errorf("GET /cluster/status = %+v, %v", s, err)
+	}
+
+	c.Close()
+	tsC.Close()
+	waitFor(t, "c reported unhealthy", func() bool {
+		s, _ := a.ClusterStatus()
+		for _, m := range s.Members {
+			if m.ID == "c" {
+				return !m.Healthy && m.Error != ""
+			}
+		}
+		return false
+	})
+
+	// Without a majority, b loses the leader.
+	a.Close()
+	waitFor(t, "b not ready", func() bool {
+		return errors.Is(b.Ready(context.Background()), database.ErrNotReady)
+	})
+	b.Close()
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/raft"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	logsBucket   = []byte("logs")
+	stableBucket = []byte("stable")
+)
+
+// errKeyNotFound is what Raft expects of a missing stable key, by its text.
+var errKeyNotFound = errors.New("not found")
+
+// boltStore keeps the Raft log and the node's term and vote in a bolt
+// file, next to the snapshots.
+type boltStore struct {
+	db *bolt.DB
+}
+
+var (
+	_ raft.LogStore    = (*boltStore)(nil)
+	_ raft.StableStore = (*boltStore)(nil)
+)
+
+func openBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(logsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(stableBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func indexKey(i uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, i)
+}
+
+func (s *boltStore) FirstIndex() (uint64, error) {
+	var i uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(logsBucket).Cursor().First(); k != nil {
+			i = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return i, err
+}
+
+func (s *boltStore) LastIndex() (uint64, error) {
+	var i uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(logsBucket).Cursor().Last(); k != nil {
+			i = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return i, err
+}
+
+func (s *boltStore) GetLog(index uint64, log *raft.Log) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(logsBucket).Get(indexKey(index))
+		if b == nil {
+			return raft.ErrLogNotFound
+		}
+		return json.Unmarshal(b, log)
+	})
+}
+
+func (s *boltStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+func (s *boltStore) StoreLogs(logs []*raft.Log) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(logsBucket)
+		for _, log := range logs {
+			b, err := json.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(indexKey(log.Index), b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) DeleteRange(min, max uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(logsBucket)
+		// Deleting under a cursor would skip the entries after each one.
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.Seek(indexKey(min)); k != nil && binary.BigEndian.Uint64(k) <= max; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Set(key, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stableBucket).Put(key, value)
+	})
+}
+
+func (s *boltStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(stableBucket).Get(key)
+		if b == nil {
+			return errKeyNotFound
+		}
+		value = append([]byte(nil), b...)
+		return nil
+	})
+	return value, err
+}
+
+func (s *boltStore) SetUint64(key []byte, value uint64) error {
+	return s.Set(key, indexKey(value))
+}
+
+func (s *boltStore) GetUint64(key []byte) (uint64, error) {
+	b, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
@@ -0,0 +1,889 @@
+// Command dbcli inspects and edits a database directory.
+//
+//	dbcli [-dir DIR] [-master-key-file F] [-field-key-file F] <command> [args]
+//
+//	put [-compression gzip|zstd|none] <collection> <key> [file]
+//	                                 write a record from a JSON file or stdin
+//	get <collection> <key>           print a record
+//	delete <collection> <key>...     delete records, printing how many there were
+//	delete-where [-dry-run] <collection> <expression>
+//	                                 delete the records matching an expression, or print them
+//	drop [-dry-run] <collection>     delete a collection with its sub-collections, or print its records
+//	list <collection>                print the keys of a collection
+//	collections                      print the collections
+//	save-query <name> [file]         save a query from a JSON file or stdin
+//	delete-query <name>              delete a saved query
+//	manifest <collection>            print the manifest of a collection
+//	set-manifest <collection> [file] declare a collection from a JSON manifest file or stdin
+//	queries                          print the saved queries
+//	find <collection> <expression>   print the records matching an expression as JSON lines
+//	run <name>                       print the results of a saved query as JSON lines
+//	aggregate <collection> [file]    run a JSON pipeline from a file or stdin, print JSON lines
+//	export [-o file] [-anonymize rules] [-salt-file F] [collection...]
+//	                                 write records as JSON lines, anonymized by a rules file like
+//	                                 {"users": {"name": "fake-name", "email": "hash"}}
+//	export [-o file] [-format jsonl|csv] [-filter EXPR] [-keys K,...] [-fields F,...] [-omit F,...] [collection...]
+//	                                 write only the records and fields picked, as JSON lines or CSV
+//	import [-collection C [-format jsonl|csv] [-key-field F]] [file]
+//	                                 write records from an export, or JSON lines or CSV
+//	                                 records into collection C keyed by field F
+//	backup <file>                    write a snapshot of the database
+//	verify-backup <file>             check a snapshot is complete and every record in it reads
+//	restore [-dry-run] <file>        unpack a snapshot into an empty -dir, or print the files it
+//	                                 would write and those of -dir it would replace
+//	restore [-dry-run] [-overwrite] [-collections C,...] [-keys GLOB,...] [-filter EXPR] [-into FROM=TO,...] <file>
+//	                                 restore only the records picked into the database in -dir,
+//	                                 keeping those it holds unless -overwrite, or print them
+//	rebalance [-by key|collection] <dir>...
+//	                                 move the records of a sharded database to the shards they
+//	                                 belong on after adding or removing one
+//	merge [-on-conflict skip|overwrite|rename] [-suffix S] <dir>
+//	                                 fold the database in dir into -dir, keeping, replacing or
+//	                                 renaming with the suffix the records both hold differently
+//	diff [-json] <a> <b>             print the records added, removed and changed from a to b,
+//	                                 each a database directory or a backup file
+//	verify                           check that every record reads back
+//	compact                          remove temp files, expired records and leftovers
+//	shell [-read-only]               explore the database interactively
+//
+// Backups are encrypted, and restored, with -backup-passphrase-file or
+// -backup-key-file. Key files hold the key hex-encoded. A file argument of
+// "-" or none means stdin or stdout.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/shard"
+)
+
+var (
+	dir           = flag.String("dir", "./", "database directory")
+	masterKeyFile = flag.String("master-key-file", "", "file holding the hex-encoded master key of an encrypted database")
+	fieldKeyFile  = flag.String("field-key-file", "", "file holding the hex-encoded field encryption key")
+
+	backupPassphraseFile = flag.String("backup-passphrase-file", "", "file holding the passphrase backups are encrypted with")
+	backupKeyFile        = flag.String("backup-key-file", "", "file holding the hex-encoded key backups are encrypted with")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dbcli [flags] put|get|delete|delete-where|drop|list|collections|save-query|delete-query|manifest|set-manifest|queries|find|run|aggregate|export|import|backup|verify-backup|restore|rebalance|merge|diff|verify|compact|shell [args]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func readKey(file string) ([]byte, error) {
+	if file == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(b)))
+}
+
+// backupEncryption returns the encryption the backup flags set.
+func backupEncryption() (database.BackupEncryption, error) {
+	var e database.BackupEncryption
+	if *backupPassphraseFile != "" {
+		b, err := os.ReadFile(*backupPassphraseFile)
+		if err != nil {
+			return e, fmt.Errorf("reading backup passphrase: %w", err)
+		}
+		e.Passphrase = strings.TrimRight(string(b), "\r\n")
+	}
+	key, err := readKey(*backupKeyFile)
+	if err != nil {
+		return e, fmt.Errorf("reading backup key: %w", err)
+	}
+	e.Key = key
+	return e, nil
+}
+
+func open(readOnly bool) (*database.Driver, error) {
+	opts, err := options(readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return database.New(*dir, opts)
+}
+
+// options returns the Options the flags set.
+func options(readOnly bool) (*database.Options, error) {
+	masterKey, err := readKey(*masterKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading master key: %w", err)
+	}
+	fieldKey, err := readKey(*fieldKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading field key: %w", err)
+	}
+	return &database.Options{
+		ReadOnly:         readOnly,
+		MasterKey:        masterKey,
+		FieldKey:         fieldKey,
+		TTLSweepInterval: -1,
+	}, nil
+}
+
+// printResults writes query results to stdout as JSON lines.
+func printResults(results []database.QueryResult, err error) error {
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, result := range results {
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func input(args []string) (io.ReadCloser, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(args[0])
+}
+
+// list splits a comma-separated flag value, nil if it is empty.
+func list(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func output(file string) (io.WriteCloser, error) {
+	if file == "" || file == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(file)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func need(args []string, min, max int, usage string) error {
+	if len(args) < min || len(args) > max {
+		return fmt.Errorf("usage: dbcli %s", usage)
+	}
+	return nil
+}
+
+func run(command string, args []string) (err error) {
+	readOnly := true
+	switch command {
+	case "put", "delete", "delete-where", "drop", "import", "compact", "save-query", "delete-query", "set-manifest":
+		readOnly = false
+	case "shell":
+		fs := flag.NewFlagSet("shell", flag.ExitOnError)
+		fs.BoolVar(&readOnly, "read-only", false, "open the database read-only")
+		fs.Parse(args)
+	case "restore":
+		return restore(args)
+	case "verify-backup":
+		return verifyBackup(args)
+	case "rebalance":
+		return rebalance(args)
+	case "merge":
+		return merge(args)
+	case "diff":
+		return diff(args)
+	case "get", "list", "collections", "queries", "manifest", "find", "run", "aggregate", "export", "backup", "verify":
+	default:
+		usage()
+	}
+
+	db, err := open(readOnly)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	switch command {
+	case "shell":
+		return runShell(db)
+
+	case "put":
+		fs := flag.NewFlagSet("put", flag.ExitOnError)
+		compression := fs.String("compression", "", "store the record with gzip, zstd or none rather than the collection's compression")
+		fs.Parse(args)
+		if err := need(fs.Args(), 2, 3, "put [-compression C] <collection> <key> [file]"); err != nil {
+			return err
+		}
+		r, err := input(fs.Args()[2:])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		var raw json.RawMessage
+		if err := json.NewDecoder(r).Decode(&raw); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		// Encoded with the collection's codec, whichever it is.
+		opts := database.RawOptions{Codec: database.JSONCodec{}, Compression: database.Compression(*compression)}
+		return db.WriteRawWith(context.Background(), fs.Arg(0), fs.Arg(1), bytes.NewReader(raw), opts)
+
+	case "get":
+		if err := need(args, 2, 2, "get <collection> <key>"); err != nil {
+			return err
+		}
+		return db.ReadTo(args[0], args[1], os.Stdout)
+
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("usage: dbcli delete <collection> <key>...")
+		}
+		n, err := db.DeleteMany(args[0], args[1:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Deleted %d records\n", n)
+
+	case "delete-where", "drop":
+		fs := flag.NewFlagSet(command, flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "print what would be deleted, deleting nothing")
+		fs.Parse(args)
+		var report *database.DeleteReport
+		var err error
+		if command == "drop" {
+			if err := need(fs.Args(), 1, 1, "drop [-dry-run] <collection>"); err != nil {
+				return err
+			}
+			report, err = db.DropCollection(context.Background(), fs.Arg(0), *dryRun)
+		} else {
+			if err := need(fs.Args(), 2, 2, "delete-where [-dry-run] <collection> <expression>"); err != nil {
+				return err
+			}
+			report, err = db.DeleteWhere(context.Background(), database.Query{Collection: fs.Arg(0), Filter: fs.Arg(1)}, *dryRun)
+		}
+		if err != nil {
+			return err
+		}
+		printDeleteReport(report)
+
+	case "list":
+		if err := need(args, 1, 1, "list <collection>"); err != nil {
+			return err
+		}
+		keys, err := db.Keys(args[0])
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+
+	case "collections":
+		if err := need(args, 0, 0, "collections"); err != nil {
+			return err
+		}
+		collections, err := db.Collections()
+		if err != nil {
+			return err
+		}
+		for _, c := range collections {
+			fmt.Println(c)
+		}
+
+	case "save-query":
+		if err := need(args, 1, 2, "save-query <name> [file]"); err != nil {
+			return err
+		}
+		r, err := input(args[1:])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		var q database.Query
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&q); err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		return db.SaveQuery(args[0], q)
+
+	case "manifest":
+		if err := need(args, 1, 1, "manifest <collection>"); err != nil {
+			return err
+		}
+		m, err := db.Manifest(args[0])
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		return enc.Encode(m)
+
+	case "set-manifest":
+		if err := need(args, 1, 2, "set-manifest <collection> [file]"); err != nil {
+			return err
+		}
+		r, err := input(args[1:])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		var m database.Manifest
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return fmt.Errorf("invalid manifest: %w", err)
+		}
+		return db.SetManifest(args[0], m)
+
+	case "delete-query":
+		if err := need(args, 1, 1, "delete-query <name>"); err != nil {
+			return err
+		}
+		return db.DeleteQuery(args[0])
+
+	case "queries":
+		if err := need(args, 0, 0, "queries"); err != nil {
+			return err
+		}
+		names, err := db.SavedQueries()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+
+	case "find":
+		if err := need(args, 2, 2, "find <collection> <expression>"); err != nil {
+			return err
+		}
+		return printResults(db.Find(database.Query{Collection: args[0], Filter: args[1]}))
+
+	case "run":
+		if err := need(args, 1, 1, "run <name>"); err != nil {
+			return err
+		}
+		return printResults(db.RunQuery(args[0]))
+
+	case "aggregate":
+		if err := need(args, 1, 2, "aggregate <collection> [file]"); err != nil {
+			return err
+		}
+		r, err := input(args[1:])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		var pipeline []database.Stage
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&pipeline); err != nil {
+			return fmt.Errorf("invalid pipeline: %w", err)
+		}
+		docs, err := db.Aggregate(args[0], pipeline)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, doc := range docs {
+			if err := enc.Encode(doc); err != nil {
+				return err
+			}
+		}
+
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		out := fs.String("o", "", "file to write, stdout by default")
+		rules := fs.String("anonymize", "", "JSON file mapping collections to field transforms to anonymize the export with")
+		saltFile := fs.String("salt-file", "", "file holding the hex-encoded salt anonymized values are hashed with, random by default")
+		format := fs.String("format", "jsonl", "format to write: jsonl, or csv")
+		filter := fs.String("filter", "", "export only the records this expression is true for")
+		keys := fs.String("keys", "", "comma-separated keys of the only records to export")
+		fields := fs.String("fields", "", "comma-separated fields, with dots for nested fields, to export only")
+		omit := fs.String("omit", "", "comma-separated fields to leave out")
+		fs.Parse(args)
+		opts := database.ExportOptions{Collections: fs.Args(), Filter: *filter, Keys: list(*keys), Fields: list(*fields), Omit: list(*omit)}
+		switch *format {
+		case "jsonl":
+		case "csv":
+			opts.Format = database.ExportCSV
+		default:
+			return fmt.Errorf("unknown export format %q - must be jsonl or csv", *format)
+		}
+		selective := opts.Format != database.ExportJSONLines || opts.Filter != "" || opts.Keys != nil || opts.Fields != nil || opts.Omit != nil
+		if *rules != "" && selective {
+			return errors.New("-anonymize exports whole records as JSON lines - drop -format, -filter, -keys, -fields and -omit")
+		}
+		var a database.Anonymization
+		if *rules != "" {
+			b, err := os.ReadFile(*rules)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(b, &a); err != nil {
+				return fmt.Errorf("reading %s: %w", *rules, err)
+			}
+		}
+		salt, err := readKey(*saltFile)
+		if err != nil {
+			return err
+		}
+		w, err := output(*out)
+		if err != nil {
+			return err
+		}
+		var n int
+		switch {
+		case a != nil:
+			n, err = db.ExportAnonymized(w, a, salt, fs.Args()...)
+		case selective:
+			n, err = db.ExportWith(w, opts)
+		default:
+			n, err = db.Export(w, fs.Args()...)
+		}
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d records\n", n)
+
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		into := fs.String("collection", "", "collection to import JSON lines or CSV records into, rather than an export")
+		format := fs.String("format", "jsonl", "format of the records imported into -collection: jsonl or csv")
+		keyField := fs.String("key-field", "", "field holding the keys of the records imported into -collection, generated if empty")
+		fs.Parse(args)
+		if err := need(fs.Args(), 0, 1, "import [-collection C [-format jsonl|csv] [-key-field F]] [file]"); err != nil {
+			return err
+		}
+		r, err := input(fs.Args())
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if *into == "" {
+			n, err := db.Import(r)
+			fmt.Fprintf(os.Stderr, "Imported %d records\n", n)
+			return err
+		}
+		return importInto(db, *into, *format, *keyField, r)
+
+	case "backup":
+		if err := need(args, 1, 1, "backup <file>"); err != nil {
+			return err
+		}
+		encryption, err := backupEncryption()
+		if err != nil {
+			return err
+		}
+		w, err := output(args[0])
+		if err != nil {
+			return err
+		}
+		err = db.BackupWith(w, database.BackupOptions{Encryption: encryption})
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		return err
+
+	case "verify":
+		if err := need(args, 0, 0, "verify"); err != nil {
+			return err
+		}
+		report, err := db.Verify()
+		if err != nil {
+			return err
+		}
+		for _, issue := range report.Issues {
+			fmt.Printf("%s: %s\n", issue.Path, issue.Problem)
+		}
+		fmt.Fprintf(os.Stderr, "Checked %d records, found %d issues\n", report.Records, len(report.Issues))
+		if len(report.Issues) > 0 {
+			return errors.New("verification failed")
+		}
+
+	case "compact":
+		if err := need(args, 0, 0, "compact"); err != nil {
+			return err
+		}
+		report, err := db.Compact()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d temp files, %d expired records, %d orphaned sidecars and %d empty collections, reclaiming %d bytes\n",
+			report.TempFiles, report.ExpiredRecords, report.OrphanedSidecars, report.EmptyCollections, report.ReclaimedBytes)
+	}
+	return nil
+}
+
+// verifyBackup checks a backup reads through, against its manifest, with
+// the keys of the database it was taken of.
+func verifyBackup(args []string) error {
+	if err := need(args, 1, 1, "verify-backup <file>"); err != nil {
+		return err
+	}
+	opts, err := options(true)
+	if err != nil {
+		return err
+	}
+	encryption, err := backupEncryption()
+	if err != nil {
+		return err
+	}
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	report, err := database.VerifyBackup(r, database.VerifyBackupOptions{Encryption: encryption, Options: opts})
+	if err != nil {
+		return err
+	}
+	for _, issue := range report.Issues {
+		fmt.Printf("%s: %s\n", issue.Path, issue.Problem)
+	}
+	fmt.Fprintf(os.Stderr, "Checked %d files and %d records, found %d issues\n", report.Files, report.Records, len(report.Issues))
+	if len(report.Issues) > 0 {
+		return errors.New("backup verification failed")
+	}
+	return nil
+}
+
+func restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the files the backup holds and those of -dir it would replace, writing nothing")
+	overwrite := fs.Bool("overwrite", false, "replace the records -dir holds with those picked")
+	collections := fs.String("collections", "", "comma-separated collections to restore the records of into -dir")
+	keys := fs.String("keys", "", "comma-separated globs of the keys of the records to restore into -dir")
+	filter := fs.String("filter", "", "restore the records matching this expression into -dir")
+	into := fs.String("into", "", "comma-separated from=to collections to restore records into")
+	fs.Parse(args)
+	if err := need(fs.Args(), 1, 1, "restore [-dry-run] [-overwrite] [-collections C,...] [-keys GLOB,...] [-filter EXPR] [-into FROM=TO,...] <file>"); err != nil {
+		return err
+	}
+	encryption, err := backupEncryption()
+	if err != nil {
+		return err
+	}
+	if *overwrite || *collections != "" || *keys != "" || *filter != "" || *into != "" {
+		opts := database.RestoreRecordsOptions{Collections: list(*collections), Keys: list(*keys), Filter: *filter,
+			Overwrite: *overwrite, DryRun: *dryRun, Encryption: encryption}
+		for _, pair := range list(*into) {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("-into %q is not from=to", pair)
+			}
+			if opts.Rename == nil {
+				opts.Rename = make(map[string]string)
+			}
+			opts.Rename[from] = to
+		}
+		return restoreRecords(fs.Args(), opts)
+	}
+	if !*dryRun {
+		if entries, err := os.ReadDir(*dir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("%s is not empty - restore into an empty directory", *dir)
+		}
+		if err := os.MkdirAll(*dir, 0755); err != nil {
+			return err
+		}
+	}
+	r, err := input(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	report, err := database.RestoreWith(database.NewFileBackend(*dir), r, database.RestoreOptions{DryRun: *dryRun, Encryption: encryption})
+	if err != nil || !*dryRun {
+		return err
+	}
+	replaced := make(map[string]bool, len(report.Replaced))
+	for _, name := range report.Replaced {
+		replaced[name] = true
+	}
+	for _, name := range report.Written {
+		if replaced[name] {
+			fmt.Println("replace", name)
+		} else {
+			fmt.Println("write  ", name)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Would write %d files, replacing %d\n", len(report.Written), len(report.Replaced))
+	return nil
+}
+
+// restoreRecords restores the records opts picks into the database in -dir.
+func restoreRecords(args []string, opts database.RestoreRecordsOptions) (err error) {
+	db, err := open(opts.DryRun)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	report, err := db.RestoreRecords(context.Background(), r, opts)
+	if err != nil {
+		return err
+	}
+	for _, name := range report.Restored {
+		fmt.Println("restore", name)
+	}
+	for _, name := range report.Skipped {
+		fmt.Println("skip   ", name)
+	}
+	verb := "Restored"
+	if report.DryRun {
+		verb = "Would restore"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d records, skipped %d that exist\n", verb, len(report.Restored), len(report.Skipped))
+	return nil
+}
+
+// printDeleteReport prints the records a delete removed, or would remove.
+func printDeleteReport(report *database.DeleteReport) {
+	collections := make([]string, 0, len(report.Records))
+	for c := range report.Records {
+		collections = append(collections, c)
+	}
+	sort.Strings(collections)
+	for _, c := range collections {
+		for _, key := range report.Records[c] {
+			fmt.Printf("%s/%s\n", c, key)
+		}
+	}
+	verb := "Deleted"
+	if report.DryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d records\n", verb, report.Count())
+}
+
+func rebalance(args []string) error {
+	fs := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	by := fs.String("by", "key", "what places records on shards: key or collection")
+	fs.Parse(args)
+	if err := need(fs.Args(), 1, math.MaxInt, "rebalance [-by key|collection] <dir>..."); err != nil {
+		return err
+	}
+	var placement shard.Placement
+	switch *by {
+	case "key":
+		placement = shard.ByKey
+	case "collection":
+		placement = shard.ByCollection
+	default:
+		return fmt.Errorf("unknown placement %q - must be key or collection", *by)
+	}
+	opts, err := options(false)
+	if err != nil {
+		return err
+	}
+	var shards []shard.Shard
+	for _, dir := range fs.Args() {
+		shards = append(shards, shard.Shard{Dir: dir})
+	}
+	db, err := shard.Open(shards, &shard.Options{Placement: placement, Database: opts})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	report, err := db.Rebalance(context.Background())
+	fmt.Printf("Scanned %d records, moved %d\n", report.Scanned, report.Moved)
+	return err
+}
+
+// importInto imports records into a collection, reporting progress and
+// the records that failed on stderr.
+func importInto(db *database.Driver, collection, format, keyField string, r io.Reader) error {
+	opts := database.ImportOptions{KeyField: keyField}
+	switch format {
+	case "jsonl":
+		opts.Format = database.ImportJSONLines
+	case "csv":
+		opts.Format = database.ImportCSV
+	default:
+		return fmt.Errorf("unknown import format %q - must be jsonl or csv", format)
+	}
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			opts.Size = info.Size()
+		}
+	}
+	opts.Progress = func(p database.ImportProgress) {
+		fmt.Fprintf(os.Stderr, "\r%d records, %d failed, %d bytes", p.Records, p.Failed, p.Bytes)
+		if p.ETA > 0 {
+			fmt.Fprintf(os.Stderr, ", %v left", p.ETA.Round(time.Second))
+		}
+	}
+	report, err := db.ImportInto(collection, r, opts)
+	fmt.Fprintln(os.Stderr)
+	if report != nil {
+		for _, e := range report.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d records, %d failed\n", report.Written, len(report.Errors))
+	}
+	return err
+}
+
+func merge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	onConflict := fs.String("on-conflict", "skip", "what to do with records both databases hold differently: skip, overwrite or rename")
+	suffix := fs.String("suffix", "-merged", "appended to the keys of records renamed on conflict")
+	fs.Parse(args)
+	if err := need(fs.Args(), 1, 1, "merge [-on-conflict skip|overwrite|rename] [-suffix S] <dir>"); err != nil {
+		return err
+	}
+	var strategy database.MergeStrategy
+	switch *onConflict {
+	case "skip":
+		strategy = database.MergeSkip
+	case "overwrite":
+		strategy = database.MergeOverwrite
+	case "rename":
+		strategy = database.MergeRename(*suffix)
+	default:
+		return fmt.Errorf("unknown conflict strategy %q - must be skip, overwrite or rename", *onConflict)
+	}
+	opts, err := options(true)
+	if err != nil {
+		return err
+	}
+	other, err := database.New(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	defer other.Close()
+	db, err := open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	report, err := db.Merge(other, strategy)
+	if report != nil {
+		fmt.Printf("Added %d records, %d identical, %d skipped, %d replaced, %d renamed\n", report.Added, report.Identical, report.Skipped, report.Replaced, report.Renamed)
+	}
+	return err
+}
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+	if err := need(fs.Args(), 2, 2, "diff [-json] <a> <b>"); err != nil {
+		return err
+	}
+	a, err := openSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openSnapshot(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	report, err := a.Diff(b)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, r := range report.Added {
+		fmt.Printf("+ %s/%s\n", r.Collection, r.Key)
+	}
+	for _, r := range report.Removed {
+		fmt.Printf("- %s/%s\n", r.Collection, r.Key)
+	}
+	for _, r := range report.Changed {
+		fmt.Printf("~ %s/%s\n", r.Collection, r.Key)
+		for _, f := range r.Fields {
+			switch {
+			case f.Old == nil:
+				fmt.Printf("    + %s: %s\n", f.Path, f.New)
+			case f.New == nil:
+				fmt.Printf("    - %s: %s\n", f.Path, f.Old)
+			default:
+				fmt.Printf("    ~ %s: %s -> %s\n", f.Path, f.Old, f.New)
+			}
+		}
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(report.Added), len(report.Removed), len(report.Changed))
+	return nil
+}
+
+// openSnapshot opens the database directory at p read-only, or restores
+// the backup file at p into memory.
+func openSnapshot(p string) (*database.Driver, error) {
+	opts, err := options(true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return database.New(p, opts)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	encryption, err := backupEncryption()
+	if err != nil {
+		return nil, err
+	}
+	backend := database.NewMemoryBackend()
+	if _, err := database.RestoreWith(backend, f, database.RestoreOptions{Encryption: encryption}); err != nil {
+		return nil, fmt.Errorf("reading backup %s: %w", p, err)
+	}
+	opts.Backend = backend
+	return database.New(p, opts)
+}
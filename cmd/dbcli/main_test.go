@@ -0,0 +1,459 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dbcli runs a command against the database in dbDir with stdin as its
+// input and returns what it printed to stdout.
+func dbcli(t *testing.T, dbDir, stdin string, args ...string) (string, error) {
+	t.Helper()
+	in, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	in.Seek(0, 0)
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	stdinWas, stdoutWas, dirWas := os.Stdin, os.Stdout, *dir
+	os.Stdin, os.Stdout, *dir = in, out, dbDir
+	defer func() { os.Stdin, os.Stdout, *dir = stdinWas, stdoutWas, dirWas }()
+	err = run(args[0], args[1:])
+
+	b, rerr := os.ReadFile(out.Name())
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return string(b), err
+}
+
+func TestRecords(t *testing.T) {
+	dbDir := t.TempDir()
+	for _, key := range []string{"ada", "bob"} {
+		if _, err := dbcli(t, dbDir, `{"name": "`+key+`"}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := dbcli(t, dbDir, "", "get", "users", "ada"); err != nil || !strings.Contains(out, `"ada"`) {
+		t.Errorf("get = %q, %v", out, err)
+	}
+	if out, err := dbcli(t, dbDir, "", "list", "users"); err != nil || out != "ada\nbob\n" {
+		t.Errorf("list = %q, %v", out, err)
+	}
+	if out, err := dbcli(t, dbDir, "", "collections"); err != nil || out != "users\n" {
+		t.Errorf("collections = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, "", "delete", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := dbcli(t, dbDir, "", "list", "users"); out != "bob\n" {
+		t.Errorf("list after delete = %q", out)
+	}
+	if _, err := dbcli(t, dbDir, "", "get", "users", "ada"); err == nil {
+		t.Error("get of a deleted record succeeded")
+	}
+	if _, err := dbcli(t, dbDir, "{", "put", "users", "cy"); err == nil {
+		t.Error("put of invalid JSON succeeded")
+	}
+	if _, err := dbcli(t, dbDir, "", "get", "users"); err == nil || !strings.HasPrefix(err.Error(), "usage:") {
+		t.Errorf("get without a key = %v, want usage", err)
+	}
+	if _, err := dbcli(t, dbDir, "", "delete", "users"); err == nil || !strings.HasPrefix(err.Error(), "usage:") {
+		t.Errorf("delete without a key = %v, want usage", err)
+	}
+	if _, err := dbcli(t, dbDir, "", "delete", "users", "bob", "nobody"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := dbcli(t, dbDir, "", "list", "users"); out != "" {
+		t.Errorf("list after deleting the rest = %q, want nothing", out)
+	}
+}
+
+func TestPutCompression(t *testing.T) {
+	dbDir := t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"name": "ada"}`, "put", "-compression", "gzip", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dbDir, "users", "ada.json.gz")); err != nil {
+		t.Errorf("record not stored compressed: %v", err)
+	}
+	if out, err := dbcli(t, dbDir, "", "get", "users", "ada"); err != nil || !strings.Contains(out, `"ada"`) {
+		t.Errorf("get of a compressed record = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, `{}`, "put", "-compression", "lz4", "users", "bob"); err == nil {
+		t.Error("put with an unknown compression succeeded")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	if _, err := dbcli(t, from, `{"name": "ada"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	export, err := dbcli(t, from, "", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, to, export, "import"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := dbcli(t, to, "", "get", "users", "ada"); err != nil || !strings.Contains(out, `"ada"`) {
+		t.Errorf("get of an imported record = %q, %v", out, err)
+	}
+}
+
+func TestImportInto(t *testing.T) {
+	dbDir := t.TempDir()
+	if _, err := dbcli(t, dbDir, "id,name\nada,Ada\nbob,Bob\n", "import", "-collection", "users", "-format", "csv", "-key-field", "id"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := dbcli(t, dbDir, "", "get", "users", "bob"); err != nil || !strings.Contains(out, `"Bob"`) {
+		t.Errorf("get of an imported record = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, "", "import", "-collection", "users", "-format", "xml"); err == nil {
+		t.Error("import of an unknown format succeeded")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ours, theirs := t.TempDir(), t.TempDir()
+	for _, put := range []struct{ dir, key, value string }{
+		{ours, "ada", `{"n": 1}`},
+		{theirs, "ada", `{"n": 2}`},
+		{theirs, "bob", `{"n": 3}`},
+	} {
+		if _, err := dbcli(t, put.dir, put.value, "put", "users", put.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := dbcli(t, ours, "", "merge", "-on-conflict", "rename", "-suffix", "-b", theirs)
+	if err != nil || !strings.Contains(out, "Added 1 records, 0 identical, 0 skipped, 0 replaced, 1 renamed") {
+		t.Errorf("merge = %q, %v", out, err)
+	}
+	if out, err := dbcli(t, ours, "", "get", "users", "ada-b"); err != nil || !strings.Contains(out, "2") {
+		t.Errorf("get of a renamed record = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, ours, "", "merge", "-on-conflict", "coin-toss", theirs); err == nil {
+		t.Error("merge accepted an unknown conflict strategy")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dbDir := t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"name": "ada"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := dbcli(t, dbDir, "", "backup", backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, dbDir, `{"name": "Ada"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, dbDir, `{"name": "bob"}`, "put", "users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := dbcli(t, dbDir, "", "diff", backup, dbDir)
+	want := "+ users/bob\n~ users/ada\n    ~ name: \"ada\" -> \"Ada\"\n1 added, 0 removed, 1 changed\n"
+	if err != nil || out != want {
+		t.Errorf("diff = %q, %v; want %q", out, err, want)
+	}
+	out, err = dbcli(t, dbDir, "", "diff", "-json", dbDir, backup)
+	if err != nil || !strings.Contains(out, `"removed": [`) || !strings.Contains(out, `"key": "bob"`) {
+		t.Errorf("diff -json = %q, %v", out, err)
+	}
+}
+
+func TestExportWith(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, country := range map[string]string{"ada": "PK", "bob": "US"} {
+		if _, err := dbcli(t, dbDir, `{"country": "`+country+`", "phone": "123"}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := dbcli(t, dbDir, "", "export", "-format", "csv", "-filter", "doc.country == 'PK'", "-omit", "phone", "users")
+	if want := "_collection,_key,country\nusers,ada,PK\n"; err != nil || out != want {
+		t.Errorf("export -format csv = %q, %v; want %q", out, err, want)
+	}
+	if _, err := dbcli(t, dbDir, "", "export", "-anonymize", "rules.json", "-keys", "ada"); err == nil || !strings.Contains(err.Error(), "-anonymize exports whole records") {
+		t.Errorf("export -anonymize of picked keys = %v, want it refused", err)
+	}
+}
+
+func TestExportAnonymized(t *testing.T) {
+	dbDir, files := t.TempDir(), t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"name": "Ada Lovelace", "email": "ada@example.org"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	rules := filepath.Join(files, "rules.json")
+	salt := filepath.Join(files, "salt")
+	if err := os.WriteFile(rules, []byte(`{"users": {"name": "fake-name", "email": "hash"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(salt, []byte("00ff\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dbcli(t, dbDir, "", "export", "-anonymize", rules, "-salt-file", salt)
+	if err != nil || !strings.Contains(out, `"users"`) || strings.Contains(out, "Lovelace") || strings.Contains(out, "ada@") {
+		t.Errorf("export -anonymize = %q, %v", out, err)
+	}
+	if again, _ := dbcli(t, dbDir, "", "export", "-anonymize", rules, "-salt-file", salt); again != out {
+		t.Errorf("exports with the same salt differ: %q and %q", out, again)
+	}
+}
+
+func TestBackupVerify(t *testing.T) {
+	dbDir := t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"name": "ada"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := dbcli(t, dbDir, "", "backup", backup); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(backup); err != nil || fi.Size() == 0 {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if _, err := dbcli(t, dbDir, "", "verify"); err != nil {
+		t.Errorf("verify = %v", err)
+	}
+	if _, err := dbcli(t, dbDir, "", "verify-backup", backup); err != nil {
+		t.Errorf("verify-backup = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dbDir, "users", "ada.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(t.TempDir(), "corrupt.tar.gz")
+	if _, err := dbcli(t, dbDir, "", "backup", corrupt); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := dbcli(t, dbDir, "", "verify-backup", corrupt); err == nil || !strings.Contains(out, "users/ada.json: ") {
+		t.Errorf("verify-backup of a corrupt record = %q, %v", out, err)
+	}
+	b, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(corrupt, b[:len(b)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, dbDir, "", "verify-backup", corrupt); err == nil {
+		t.Error("verify-backup of a backup cut short succeeded")
+	}
+	if _, err := dbcli(t, dbDir, "", "verify-backup"); err == nil {
+		t.Error("verify-backup without a file succeeded")
+	}
+	if out, err := dbcli(t, dbDir, "", "verify"); err == nil || !strings.Contains(out, "ada.json") {
+		t.Errorf("verify of a corrupt record = %q, %v", out, err)
+	}
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	dbDir, restored := t.TempDir(), t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"name": "ada"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	files := t.TempDir()
+	passphrase := filepath.Join(files, "passphrase")
+	if err := os.WriteFile(passphrase, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(files, "backup.tar.gz.enc")
+
+	was := *backupPassphraseFile
+	defer func() { *backupPassphraseFile = was }()
+	*backupPassphraseFile = passphrase
+	if _, err := dbcli(t, dbDir, "", "backup", backup); err != nil {
+		t.Fatal(err)
+	}
+	*backupPassphraseFile = ""
+	if _, err := dbcli(t, restored, "", "restore", backup); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("restore without the passphrase = %v, want it refused", err)
+	}
+	*backupPassphraseFile = passphrase
+	if _, err := dbcli(t, restored, "", "restore", backup); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := dbcli(t, restored, "", "get", "users", "ada"); err != nil || !strings.Contains(out, `"ada"`) {
+		t.Errorf("get of a restored record = %q, %v", out, err)
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	dbDir, restored := t.TempDir(), t.TempDir()
+	for _, key := range []string{"ada", "bob"} {
+		if _, err := dbcli(t, dbDir, `{"name": "`+key+`"}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backup := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := dbcli(t, dbDir, "", "backup", backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, restored, `{"name": "old"}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dbcli(t, restored, "", "restore", "-dry-run", backup)
+	if err != nil || !strings.Contains(out, "replace users/ada.json") || !strings.Contains(out, "write   users/bob.json") {
+		t.Errorf("restore -dry-run = %q, %v", out, err)
+	}
+	if _, err := os.Stat(filepath.Join(restored, "users", "bob.json")); !os.IsNotExist(err) {
+		t.Errorf("restore -dry-run wrote bob: %v", err)
+	}
+}
+
+func TestRestoreRecords(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, age := range map[string]string{"ada": "36", "bob": "20"} {
+		if _, err := dbcli(t, dbDir, `{"age": `+age+`}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backup := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := dbcli(t, dbDir, "", "backup", backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, dbDir, `{"age": 37}`, "put", "users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := dbcli(t, dbDir, "", "restore", "-collections", "users", "-filter", "doc.age > 30", backup)
+	if err != nil || !strings.Contains(out, "skip    users/ada\n") {
+		t.Errorf("restore without -overwrite = %q, %v", out, err)
+	}
+	out, err = dbcli(t, dbDir, "", "restore", "-dry-run", "-overwrite", "-keys", "a*", backup)
+	if err != nil || !strings.Contains(out, "restore users/ada\n") {
+		t.Errorf("restore -dry-run -overwrite = %q, %v", out, err)
+	}
+	if out, _ := dbcli(t, dbDir, "", "get", "users", "ada"); !strings.Contains(out, "37") {
+		t.Errorf("restore -dry-run replaced ada: %q", out)
+	}
+	out, err = dbcli(t, dbDir, "", "restore", "-into", "users=people", backup)
+	if err != nil || !strings.Contains(out, "restore people/ada\n") || !strings.Contains(out, "restore people/bob\n") {
+		t.Errorf("restore -into = %q, %v", out, err)
+	}
+	if out, err := dbcli(t, dbDir, "", "get", "people", "ada"); err != nil || !strings.Contains(out, "36") {
+		t.Errorf("get of a restored record = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, "", "restore", "-into", "users", backup); err == nil {
+		t.Error("restore -into without a = succeeded")
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, age := range map[string]string{"ada": "36", "bob": "20", "cy": "50"} {
+		if _, err := dbcli(t, dbDir, `{"age": `+age+`}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dbcli(t, dbDir, `{}`, "put", "users/ada/posts", "p1"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dbcli(t, dbDir, "", "delete-where", "-dry-run", "users", "doc.age > 30")
+	if want := "users/ada\nusers/cy\n"; err != nil || out != want {
+		t.Errorf("delete-where -dry-run = %q, %v; want %q", out, err, want)
+	}
+	if _, err := dbcli(t, dbDir, "", "delete-where", "users", "doc.age > 40"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := dbcli(t, dbDir, "", "list", "users"); strings.Contains(out, "cy") || !strings.Contains(out, "ada") {
+		t.Errorf("list after delete-where = %q, want ada and bob", out)
+	}
+
+	out, err = dbcli(t, dbDir, "", "drop", "users")
+	if want := "users/ada\nusers/bob\nusers/ada/posts/p1\n"; err != nil || !strings.HasSuffix(out, want) {
+		t.Errorf("drop = %q, %v; want it to end in %q", out, err, want)
+	}
+	if _, err := dbcli(t, dbDir, "", "drop", "users"); err == nil {
+		t.Error("drop of a dropped collection succeeded")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dbDir := t.TempDir()
+	if _, err := dbcli(t, dbDir, `{"schema": "doc.age >= 0", "ttl": "1h"}`, "set-manifest", "users"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dbcli(t, dbDir, "", "manifest", "users")
+	if want := "{\n\t\"schema\": \"doc.age >= 0\",\n\t\"ttl\": \"1h\"\n}\n"; err != nil || out != want {
+		t.Errorf("manifest = %q, %v; want %q", out, err, want)
+	}
+	if _, err := dbcli(t, dbDir, `{"age": -1}`, "put", "users", "ada"); err == nil {
+		t.Error("put against the schema succeeded")
+	}
+	if _, err := dbcli(t, dbDir, `{"ttl": "1h", "colour": "red"}`, "set-manifest", "users"); err == nil {
+		t.Error("set-manifest of an unknown field succeeded")
+	}
+}
+
+func TestSavedQueries(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, city := range map[string]string{"ada": "Karachi", "bob": "Lahore"} {
+		if _, err := dbcli(t, dbDir, `{"name": "`+key+`", "city": "`+city+`"}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := `{"collection": "users", "where": {"city": ["Karachi"]}, "fields": ["name"]}`
+	if _, err := dbcli(t, dbDir, q, "save-query", "karachi"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := dbcli(t, dbDir, "", "queries"); err != nil || out != "karachi\n" {
+		t.Errorf("queries = %q, %v", out, err)
+	}
+	if out, err := dbcli(t, dbDir, "", "run", "karachi"); err != nil || out != `{"key":"ada","value":{"name":"ada"}}`+"\n" {
+		t.Errorf("run = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, `{"collection": "users", "order": "name"}`, "save-query", "bad"); err == nil {
+		t.Error("save-query of an unknown field succeeded")
+	}
+	if _, err := dbcli(t, dbDir, "", "delete-query", "karachi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbcli(t, dbDir, "", "run", "karachi"); err == nil {
+		t.Error("run of a deleted query succeeded")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, age := range map[string]string{"ada": "36", "bob": "20"} {
+		if _, err := dbcli(t, dbDir, `{"age": `+age+`}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := dbcli(t, dbDir, `[{"sort": ["-age"]}, {"project": ["_key"]}]`, "aggregate", "users")
+	if err != nil || out != "{\"_key\":\"ada\"}\n{\"_key\":\"bob\"}\n" {
+		t.Errorf("aggregate = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, `[{"unwind": "x"}]`, "aggregate", "users"); err == nil {
+		t.Error("aggregate of an unknown stage succeeded")
+	}
+}
+
+func TestFind(t *testing.T) {
+	dbDir := t.TempDir()
+	for key, age := range map[string]string{"ada": "36", "bob": "20"} {
+		if _, err := dbcli(t, dbDir, `{"age": `+age+`}`, "put", "users", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := dbcli(t, dbDir, "", "find", "users", "doc.age > 30"); err != nil || out != `{"key":"ada","value":{"age":36}}`+"\n" {
+		t.Errorf("find = %q, %v", out, err)
+	}
+	if _, err := dbcli(t, dbDir, "", "find", "users", "doc.age >"); err == nil {
+		t.Error("find with an invalid expression succeeded")
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/gql"
+)
+
+const (
+	shellPrompt = "db> "
+	historyFile = ".dbcli_history"
+	maxHistory  = 1000
+)
+
+const shellHelp = `collections                  list the collections
+keys <collection>            list the keys of a collection
+get <collection> <key>       print a record
+put <collection> <key> JSON  write a record
+delete <collection> <key>    delete a record
+drop <collection>            delete a collection with its sub-collections
+find <collection> EXPR       print the records matching an expression, e.g. find users doc.age > 25
+query { ... }                run a GraphQL query, e.g. { users(limit: 5) { _key name } }
+run <name>                   run a saved query
+help                         show this help
+exit                         leave the shell
+
+Keys with spaces can be written in double quotes. Tab completes commands,
+collections and keys.
+`
+
+var shellCommands = []string{"collections", "delete", "drop", "exit", "find", "get", "help", "keys", "put", "query", "run"}
+
+type shell struct {
+	db  *database.Driver
+	out io.Writer
+	// query is built on first use and dropped after writes, which may add
+	// collections or fields to the schema.
+	query *gql.Handler
+}
+
+func runShell(db *database.Driver) error {
+	s := &shell{db: db, out: os.Stdout}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if !s.exec(scanner.Text()) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, shellPrompt)
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		t.SetSize(width, height)
+	}
+	t.AutoCompleteCallback = s.complete
+	h := openHistory()
+	defer h.Close()
+	t.History = h
+	s.out = t
+
+	fmt.Fprintln(t, `Type "help" for the commands.`)
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !s.exec(line) {
+			return nil
+		}
+	}
+}
+
+// exec runs one shell line and reports whether the shell should go on.
+func (s *shell) exec(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if strings.HasPrefix(line, "{") {
+		line = "query " + line
+	}
+
+	args := splitArgs(line, 4)
+	var err error
+	switch args[0] {
+	case "exit", "quit":
+		return false
+	case "help":
+		fmt.Fprint(s.out, shellHelp)
+	case "collections":
+		err = s.collections()
+	case "keys", "ls":
+		if len(args) != 2 {
+			err = errors.New("usage: keys <collection>")
+			break
+		}
+		err = s.keys(args[1])
+	case "get":
+		if len(args) != 3 {
+			err = errors.New("usage: get <collection> <key>")
+			break
+		}
+		err = s.get(args[1], args[2])
+	case "put":
+		if len(args) != 4 {
+			err = errors.New("usage: put <collection> <key> JSON")
+			break
+		}
+		err = s.put(args[1], args[2], args[3])
+	case "delete":
+		if len(args) != 3 {
+			err = errors.New("usage: delete <collection> <key>")
+			break
+		}
+		if err = s.db.Delete(args[1], args[2]); err == nil {
+			s.query = nil
+		}
+	case "drop":
+		if len(args) != 2 {
+			err = errors.New("usage: drop <collection>")
+			break
+		}
+		var report *database.DeleteReport
+		if report, err = s.db.DropCollection(context.Background(), args[1], false); err == nil {
+			s.query = nil
+			fmt.Fprintf(s.out, "Dropped %d records\n", report.Count())
+		}
+	case "find":
+		if args = splitArgs(line, 3); len(args) != 3 {
+			err = errors.New("usage: find <collection> EXPR")
+			break
+		}
+		err = s.find(args[1], args[2])
+	case "query":
+		err = s.run(line)
+	case "run":
+		if len(args) != 2 {
+			err = errors.New("usage: run <name>")
+			break
+		}
+		err = s.runSaved(args[1])
+	default:
+		err = fmt.Errorf("unknown command %q - type \"help\" for the commands", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(s.out, "Error:", err)
+	}
+	return true
+}
+
+func (s *shell) collections() error {
+	collections, err := s.db.Collections()
+	if err != nil {
+		return err
+	}
+	for _, c := range collections {
+		fmt.Fprintln(s.out, c)
+	}
+	return nil
+}
+
+func (s *shell) keys(collection string) error {
+	keys, err := s.db.Keys(collection)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Fprintln(s.out, key)
+	}
+	return nil
+}
+
+func (s *shell) get(collection, key string) error {
+	var buf bytes.Buffer
+	if err := s.db.ReadTo(collection, key, &buf); err != nil {
+		return err
+	}
+	// Records are stored ending in a newline, which Indent would keep.
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, bytes.TrimSpace(buf.Bytes()), "", "  "); err != nil {
+		// Not JSON; show it as stored.
+		s.out.Write(buf.Bytes())
+		return nil
+	}
+	pretty.WriteByte('\n')
+	s.out.Write(pretty.Bytes())
+	return nil
+}
+
+func (s *shell) put(collection, key, value string) error {
+	if !json.Valid([]byte(value)) {
+		return errors.New("invalid JSON")
+	}
+	raw := database.RawOptions{Codec: database.JSONCodec{}}
+	if err := s.db.WriteRawWith(context.Background(), collection, key, strings.NewReader(value), raw); err != nil {
+		return err
+	}
+	s.query = nil
+	return nil
+}
+
+func (s *shell) run(query string) error {
+	if s.query == nil {
+		h, err := gql.NewHandler(s.db, nil)
+		if err != nil {
+			return err
+		}
+		s.query = h
+	}
+	result := s.query.Do(context.Background(), query, "", nil)
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%s\n", b)
+	return nil
+}
+
+func (s *shell) find(collection, expr string) error {
+	return s.printResults(s.db.Find(database.Query{Collection: collection, Filter: expr}))
+}
+
+func (s *shell) runSaved(name string) error {
+	return s.printResults(s.db.RunQuery(name))
+}
+
+func (s *shell) printResults(results []database.QueryResult, err error) error {
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%s\n", b)
+	return nil
+}
+
+// complete expands the word under the cursor on tab: the command, then the
+// collection, then the key.
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+
+	args, start := words(line)
+	word := strings.TrimPrefix(line[start:], `"`)
+	var candidates []string
+	switch {
+	case len(args) == 0:
+		candidates = shellCommands
+	case len(args) == 1 && args[0] == "run":
+		candidates, _ = s.db.SavedQueries()
+	case len(args) == 1 && args[0] != "help" && args[0] != "collections" && args[0] != "query":
+		candidates, _ = s.db.Collections()
+	case len(args) == 2 && (args[0] == "get" || args[0] == "put" || args[0] == "delete"):
+		candidates, _ = s.db.Keys(args[1])
+	}
+
+	var matched []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return "", 0, false
+	}
+
+	completion := matched[0]
+	for _, m := range matched[1:] {
+		completion = commonPrefix(completion, m)
+	}
+	if strings.ContainsAny(completion, ` "`) {
+		if len(matched) > 1 {
+			// A quoted prefix would have to be closed; leave it to the user.
+			return "", 0, false
+		}
+		completion = strconv.Quote(completion)
+	}
+	if len(matched) == 1 {
+		completion += " "
+	}
+	line = line[:start] + completion
+	return line, len(line), true
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// splitArgs splits a shell line on spaces into at most n fields, the last
+// of which keeps the rest of the line as typed. Fields may be double-quoted.
+func splitArgs(line string, n int) []string {
+	var args []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		if len(args) == n-1 {
+			args = append(args, strings.TrimSpace(line[i:]))
+			break
+		}
+		arg, next := nextArg(line, i)
+		args = append(args, arg)
+		i = next
+	}
+	return args
+}
+
+// words returns the complete fields of a partial shell line and the offset
+// at which the field being typed starts.
+func words(line string) ([]string, int) {
+	var args []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		arg, next := nextArg(line, i)
+		if next >= len(line) {
+			return args, i
+		}
+		args = append(args, arg)
+		i = next
+	}
+	return args, len(line)
+}
+
+func nextArg(line string, i int) (string, int) {
+	if line[i] == '"' {
+		if quoted, err := strconv.QuotedPrefix(line[i:]); err == nil {
+			arg, _ := strconv.Unquote(quoted)
+			return arg, i + len(quoted)
+		}
+		return line[i+1:], len(line)
+	}
+	end := strings.IndexByte(line[i:], ' ')
+	if end < 0 {
+		return line[i:], len(line)
+	}
+	return line[i : i+end], i + end
+}
+
+// history keeps the shell's lines across sessions in ~/.dbcli_history.
+type history struct {
+	lines []string
+	f     *os.File
+}
+
+func openHistory() *history {
+	h := &history{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	p := filepath.Join(home, historyFile)
+	if b, err := os.ReadFile(p); err == nil {
+		h.lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		if len(h.lines) == 1 && h.lines[0] == "" {
+			h.lines = nil
+		}
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	if h.f, err = os.OpenFile(p, flag, 0600); err == nil && flag&os.O_TRUNC != 0 {
+		for _, line := range h.lines {
+			fmt.Fprintln(h.f, line)
+		}
+	}
+	return h
+}
+
+func (h *history) Add(entry string) {
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == entry {
+		return
+	}
+	h.lines = append(h.lines, entry)
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[1:]
+	}
+	if h.f != nil {
+		fmt.Fprintln(h.f, entry)
+	}
+}
+
+func (h *history) Len() int { return len(h.lines) }
+
+func (h *history) At(idx int) string { return h.lines[len(h.lines)-1-idx] }
+
+func (h *history) Close() error {
+	if h.f == nil {
+		return nil
+	}
+	return h.f.Close()
+}
+
+var _ term.History = (*history)(nil)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func newShell(t *testing.T) (*shell, *strings.Builder) {
+	t.Helper()
+	db, err := database.New(t.TempDir(), &database.Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	out := &strings.Builder{}
+	return &shell{db: db, out: out}, out
+}
+
+func TestShell(t *testing.T) {
+	s, out := newShell(t)
+	for _, tt := range []struct{ line, want string }{
+		{`put users "ada l" {"name": "Ada", "age": 36}`, ""},
+		{`put users bob {"name": "Bob", "age": 20}`, ""},
+		{`collections`, "users\n"},
+		{`keys users`, "ada l\nbob\n"},
+		{`get users bob`, "{\n  \"age\": 20,\n  \"name\": \"Bob\"\n}\n"},
+		{`{ usersByKey(key: "bob") { name } }`, "{\n  \"data\": {\n    \"usersByKey\": {\n      \"name\": \"Bob\"\n    }\n  }\n}\n"},
+		{`delete users bob`, ""},
+		{`keys users`, "ada l\n"},
+		{`delete users`, "Error: usage: delete <collection> <key>\n"},
+		{`put users cy {`, "Error: invalid JSON\n"},
+		{`get users`, "Error: usage: get <collection> <key>\n"},
+		{`frobnicate`, "Error: unknown command \"frobnicate\" - type \"help\" for the commands\n"},
+	} {
+		out.Reset()
+		if !s.exec(tt.line) {
+			t.Fatalf("%s ended the shell", tt.line)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s printed %q, want %q", tt.line, out.String(), tt.want)
+		}
+	}
+
+	if err := s.db.SaveQuery("adults", database.Query{Collection: "users", Where: map[string][]string{"age": {"36"}}, Fields: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	s.exec(`run adults`)
+	if want := "[\n  {\n    \"key\": \"ada l\",\n    \"value\": {\n      \"name\": \"Ada\"\n    }\n  }\n]\n"; out.String() != want {
+		t.Errorf("run printed %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	s.exec(`find users doc.age > 30`)
+	if !strings.Contains(out.String(), `"ada l"`) {
+		t.Errorf("find printed %q", out.String())
+	}
+
+	out.Reset()
+	s.exec(`drop users`)
+	if want := "Dropped 1 records\n"; out.String() != want {
+		t.Errorf("drop printed %q, want %q", out.String(), want)
+	}
+	if s.exec("exit") {
+		t.Error("exit did not end the shell")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s, _ := newShell(t)
+	if err := s.db.SaveQuery("adults", database.Query{Collection: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	for collection, keys := range map[string][]string{"users": {"y z", "yak"}, "useless": {"q"}} {
+		for _, key := range keys {
+			if err := s.db.Write(collection, key, map[string]int{"a": 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, tt := range []struct {
+		line, want string
+		ok         bool
+	}{
+		{"g", "get ", true},
+		{"get u", "get use", true},
+		{"get users", "get users ", true},
+		{"get users ", "get users y", true},
+		{"get users ya", "get users yak ", true},
+		{`get users "y `, `get users "y z" `, true},
+		{"get user ", "", false},
+		{"run a", "run adults ", true},
+		{"help x", "", false},
+	} {
+		line, pos, ok := s.complete(tt.line, len(tt.line), '\t')
+		if line != tt.want || ok != tt.ok || pos != len(line) {
+			t.Errorf("complete(%q) = %q, %d, %v; want %q, %v", tt.line, line, pos, ok, tt.want, tt.ok)
+		}
+	}
+	if _, _, ok := s.complete("g", 0, '\t'); ok {
+		t.Error("completed with the cursor inside the line")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got := splitArgs(`put users "a b" {"x": 1}`, 4)
+	if want := []string{"put", "users", "a b", `{"x": 1}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitArgs = %q, want %q", got, want)
+	}
+	if got := splitArgs(`find users doc.age > 25`, 3); len(got) != 3 || got[2] != "doc.age > 25" {
+		t.Errorf("splitArgs = %q", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	h := openHistory()
+	for _, line := range []string{"collections", "keys users", "keys users"} {
+		h.Add(line)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(home, historyFile))
+	if err != nil || string(b) != "collections\nkeys users\n" {
+		t.Fatalf("history file = %q, %v", b, err)
+	}
+
+	h = openHistory()
+	defer h.Close()
+	if h.Len() != 2 || h.At(0) != "keys users" || h.At(1) != "collections" {
+		t.Errorf("history read back %q", h.lines)
+	}
+}
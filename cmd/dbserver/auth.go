@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// roles are the roles API keys and tokens may name.
+func roles() map[string]*database.Role {
+	return map[string]*database.Role{
+		"read":      database.ReadOnlyRole("read"),
+		"readwrite": {Name: "readwrite", Collections: map[string]database.Permission{"*": database.PermReadWrite}},
+		"admin":     database.AdminRole("admin"),
+	}
+}
+
+// loadAPIKeys reads lines of "<key> <name> <role>", skipping blank lines
+// and # comments.
+func loadAPIKeys(file string) (*auth.APIKeys, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	known := roles()
+	keys := make(map[string]*database.Principal)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: want <key> <name> <role>", file, n)
+		}
+		role, ok := known[fields[2]]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown role %q - use read, readwrite or admin", file, n, fields[2])
+		}
+		keys[fields[0]] = &database.Principal{Name: fields[1], Role: role}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return auth.NewAPIKeys(keys), nil
+}
+
+// newAuth returns the authenticator for the auth flags, or nil if none are
+// set. A clientCertRole accepts verified client certificates with that
+// role, and providers are specs for auth.Open.
+func newAuth(apiKeysFile, jwtSecretFile, jwtIssuer, jwtAudience, clientCertRole string, providers []string) (auth.Authenticator, error) {
+	var chain []auth.Authenticator
+	if apiKeysFile != "" {
+		keys, err := loadAPIKeys(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if jwtSecretFile != "" {
+		secret, err := os.ReadFile(jwtSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = bytes.TrimSpace(secret)
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("%s is empty", jwtSecretFile)
+		}
+		chain = append(chain, &auth.JWT{
+			Key:      secret,
+			Methods:  []string{"HS256", "HS384", "HS512"},
+			Issuer:   jwtIssuer,
+			Audience: jwtAudience,
+			Roles:    roles(),
+		})
+	}
+	if clientCertRole != "" {
+		role, ok := roles()[clientCertRole]
+		if !ok {
+			return nil, fmt.Errorf("unknown client certificate role %q - use read, readwrite or admin", clientCertRole)
+		}
+		chain = append(chain, &auth.ClientCerts{DefaultRole: role})
+	}
+	for _, spec := range providers {
+		a, err := auth.Open(spec)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, a)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return auth.Chain(chain...), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// backupFlags are the flags of scheduled backups.
+type backupFlags struct {
+	dir, target             string
+	keep, daily, weekly     int
+	passphraseFile, keyFile string
+}
+
+// policy returns the BackupPolicy the flags set.
+func (f backupFlags) policy() (database.BackupPolicy, error) {
+	p := database.BackupPolicy{Dir: f.dir, KeepLast: f.keep, KeepDaily: f.daily, KeepWeekly: f.weekly}
+	if f.target != "" {
+		target, err := backupTarget(f.target)
+		if err != nil {
+			return p, err
+		}
+		p.Target = target
+	}
+	if f.passphraseFile != "" {
+		b, err := os.ReadFile(f.passphraseFile)
+		if err != nil {
+			return p, fmt.Errorf("reading backup passphrase: %w", err)
+		}
+		p.Encryption.Passphrase = strings.TrimRight(string(b), "\r\n")
+	}
+	if f.keyFile != "" {
+		b, err := os.ReadFile(f.keyFile)
+		if err != nil {
+			return p, fmt.Errorf("reading backup key: %w", err)
+		}
+		if p.Encryption.Key, err = hex.DecodeString(strings.TrimSpace(string(b))); err != nil {
+			return p, fmt.Errorf("reading backup key: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// backupTarget returns the target of an s3://bucket/prefix or
+// sftp://user@host:port/dir URL. S3 takes its credentials, region and
+// endpoint from the AWS_ environment variables the AWS tools read.
+func backupTarget(raw string) (database.BackupTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("backup target: %w", err)
+	}
+	switch u.Scheme {
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		return database.NewS3BackupTarget(database.S3Config{
+			Endpoint:  endpoint,
+			Region:    region,
+			Bucket:    u.Host,
+			Prefix:    u.Path,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			// Endpoints other than AWS seldom serve bucket subdomains.
+			PathStyle: endpoint != "",
+		})
+	case "sftp":
+		cfg := database.SFTPConfig{Host: u.Hostname(), Dir: strings.TrimPrefix(u.Path, "/")}
+		if u.User != nil {
+			cfg.Host = u.User.Username() + "@" + cfg.Host
+		}
+		if port := u.Port(); port != "" {
+			if cfg.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("backup target: port %q", port)
+			}
+		}
+		return database.NewSFTPBackupTarget(cfg)
+	}
+	return nil, fmt.Errorf("backup target %q is not s3:// or sftp://", raw)
+}
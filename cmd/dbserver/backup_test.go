@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupFlags(t *testing.T) {
+	files := t.TempDir()
+	passphrase := filepath.Join(files, "passphrase")
+	key := filepath.Join(files, "key")
+	badKey := filepath.Join(files, "bad-key")
+	for name, content := range map[string]string{
+		passphrase: "hunter2\n",
+		key:        "0707070707070707070707070707070707070707070707070707070707070707\n",
+		badKey:     "not hex",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := backupFlags{dir: "backups", daily: 7, weekly: 4, passphraseFile: passphrase}.policy()
+	if err != nil || p.Dir != "backups" || p.KeepDaily != 7 || p.KeepWeekly != 4 || p.KeepLast != 0 || p.Encryption.Passphrase != "hunter2" {
+		t.Errorf("policy with a passphrase = %+v, %v", p, err)
+	}
+	p, err = backupFlags{dir: "backups", keep: 3, keyFile: key}.policy()
+	if err != nil || p.KeepLast != 3 || !bytes.Equal(p.Encryption.Key, bytes.Repeat([]byte{7}, 32)) {
+		t.Errorf("policy with a key = %+v, %v", p, err)
+	}
+	for _, f := range []backupFlags{
+		{passphraseFile: filepath.Join(files, "missing")},
+		{keyFile: filepath.Join(files, "missing")},
+		{keyFile: badKey},
+	} {
+		if _, err := f.policy(); err == nil {
+			t.Errorf("policy of %+v succeeded", f)
+		}
+	}
+}
+
+func TestBackupTarget(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	for _, raw := range []string{"s3://bucket/backups", "sftp://backup@host:2222/srv/backups", "sftp://host/backups"} {
+		if target, err := backupTarget(raw); err != nil || target == nil {
+			t.Errorf("backupTarget(%q) = %v, %v", raw, target, err)
+		}
+	}
+	for _, raw := range []string{"ftp://host/backups", "backups", "sftp:///backups", "sftp://host:ssh/backups", "%"} {
+		if _, err := backupTarget(raw); err == nil {
+			t.Errorf("backupTarget(%q) succeeded", raw)
+		}
+	}
+	if p, err := (backupFlags{dir: "backups", target: "sftp://host/backups"}).policy(); err != nil || p.Target == nil {
+		t.Errorf("policy with a target = %+v, %v", p, err)
+	}
+}
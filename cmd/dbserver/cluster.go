@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/cluster"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// clusterFlags are the -cluster- flags.
+type clusterFlags struct {
+	id, addr, advertise, dir, api, join, tokenFile string
+	bootstrap                                      bool
+}
+
+// start opens the cluster node serving db.
+func (f clusterFlags) start(db *database.Driver, httpAddr string, tls bool) (*cluster.Node, error) {
+	token := ""
+	if f.tokenFile != "" {
+		b, err := os.ReadFile(f.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading cluster token: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if f.api == "" {
+		var err error
+		if f.api, err = apiURL(httpAddr, tls); err != nil {
+			return nil, err
+		}
+	}
+
+	var join []string
+	if f.join != "" {
+		join = strings.Split(f.join, ",")
+	}
+	node, err := cluster.Open(db, cluster.Config{ID: f.id, Dir: f.dir, Addr: f.addr, Advertise: f.advertise, API: f.api, Bootstrap: f.bootstrap, Join: join, Token: token})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Clustering as %s on %s\n", f.id, f.addr)
+	return node, nil
+}
+
+// apiURL returns the URL of the server listening on addr, naming the host
+// when addr leaves it out.
+func apiURL(addr string, tls bool) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid -addr: %w", err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		if host, err = os.Hostname(); err != nil {
+			return "", err
+		}
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port), nil
+}
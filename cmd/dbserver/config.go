@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// loadConfig reads a config file, setting the flags its server section
+// names unless they were given on the command line, and -dir from its
+// directory, and returns the database options it sets.
+func loadConfig(path string) (*database.Options, error) {
+	c, err := database.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	if c.Dir != "" && !given["dir"] {
+		flag.Set("dir", c.Dir)
+	}
+	for name, v := range c.Server {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("config %s: unknown server setting %s", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, value := range values {
+			if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+				return nil, fmt.Errorf("config %s: server setting %s: %w", path, name, err)
+			}
+		}
+	}
+	return c.Options()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	// The flags of main the config sets, none of them given.
+	commandLine := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = commandLine })
+	flag.CommandLine = flag.NewFlagSet("dbserver", flag.ContinueOnError)
+	dir := flag.String("dir", "./", "")
+	addr := flag.String("addr", ":8080", "")
+
+	p := filepath.Join(t.TempDir(), "owndb.yaml")
+	if err := os.WriteFile(p, []byte("dir: data\nserver:\n  addr: \":9090\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(p); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(filepath.Dir(p), "data"); *dir != want || *addr != ":9090" {
+		t.Errorf("flags -dir %s -addr %s, want %s and :9090", *dir, *addr, want)
+	}
+
+	if err := os.WriteFile(p, []byte("server:\n  nope: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(p); err == nil {
+		t.Error("loadConfig accepted an unknown server setting")
+	}
+}
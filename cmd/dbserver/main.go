@@ -0,0 +1,277 @@
+// Command dbserver serves a database directory over HTTP and, optionally,
+// gRPC.
+package main
+
+import (
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/gql"
+	"github.com/siraiwaqarali/golang-own-database/limit"
+	"github.com/siraiwaqarali/golang-own-database/memcache"
+	"github.com/siraiwaqarali/golang-own-database/metrics"
+	"github.com/siraiwaqarali/golang-own-database/rpc"
+	"github.com/siraiwaqarali/golang-own-database/server"
+)
+
+func main() {
+	config := flag.String("config", "", "read database and server settings from this YAML or TOML file, overridden by OWNDB_ environment variables and flags")
+	dir := flag.String("dir", "./", "database directory")
+	addr := flag.String("addr", ":8080", "address to serve HTTP on")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve gRPC on, if any")
+	memcachedAddr := flag.String("memcached-addr", "", "address to serve the memcached protocol on, if any")
+	graphQL := flag.Bool("graphql", false, "serve a GraphQL endpoint at /graphql")
+	admin := flag.Bool("admin", false, "serve the admin dashboard at /admin/")
+	metricsFlag := flag.Bool("metrics", false, "serve Prometheus metrics at /metrics")
+	expvarFlag := flag.Bool("expvar", false, "serve runtime stats through expvar at /debug/vars")
+	debug := flag.Bool("debug", false, "serve pprof at /debug/pprof/ and lock and cache state at /debug/db")
+	readOnly := flag.Bool("read-only", false, "open the database read-only")
+	slowOp := flag.Duration("slow-op", 0, "log operations taking at least this long, e.g. 100ms")
+	logJSON := flag.Bool("log-json", false, "log structured JSON to stderr")
+	apiKeys := flag.String("api-keys", "", `require an API key from the file's "<key> <name> <read|readwrite|admin>" lines`)
+	jwtSecret := flag.String("jwt-secret-file", "", "accept HMAC-signed bearer JWTs verified with the secret in this file")
+	jwtIssuer := flag.String("jwt-issuer", "", "require JWTs to have this issuer")
+	jwtAudience := flag.String("jwt-audience", "", "require JWTs to have this audience")
+	tlsCert := flag.String("tls-cert", "", "serve every listener over TLS with this PEM certificate")
+	tlsKey := flag.String("tls-key", "", "PEM private key for -tls-cert")
+	tlsReload := flag.Duration("tls-reload", 0, "check the certificate files for rotation this often, e.g. 1m; SIGHUP also reloads them")
+	clientCA := flag.String("client-ca", "", "verify client certificates against the PEM CAs in this file and authenticate them by common name")
+	clientCertRole := flag.String("client-cert-role", "read", "role of clients authenticated by certificate: read, readwrite or admin")
+	compact := flag.String("compact", "", `compact the database on this schedule, e.g. "@daily" or "0 3 * * *"`)
+	verify := flag.String("verify", "", "verify the database on this schedule; failures make /readyz fail")
+	backup := flag.String("backup", "", "back the database up into -backup-dir or -backup-target on this schedule")
+	var backups backupFlags
+	flag.StringVar(&backups.dir, "backup-dir", "backups", "directory for scheduled backups")
+	flag.StringVar(&backups.target, "backup-target", "", "write scheduled backups to s3://bucket/prefix or sftp://user@host:port/dir instead of -backup-dir")
+	flag.IntVar(&backups.keep, "backup-keep", 0, "newest scheduled backups to keep; every one is kept if all -backup-keep flags are 0")
+	flag.IntVar(&backups.daily, "backup-keep-daily", 7, "days to keep the newest scheduled backup of")
+	flag.IntVar(&backups.weekly, "backup-keep-weekly", 4, "weeks to keep the newest scheduled backup of")
+	flag.StringVar(&backups.passphraseFile, "backup-passphrase-file", "", "encrypt scheduled backups with the passphrase in this file")
+	flag.StringVar(&backups.keyFile, "backup-key-file", "", "encrypt scheduled backups with the hex-encoded key in this file")
+	procedures := flag.String("procedures", "", `serve the Lua procedures in this directory's .lua files, each starting "-- collections: a, b"`)
+	codec := flag.String("codec", "", "encode records with this registered codec instead of json")
+	backend := flag.String("backend", "", `store records in this registered backend instead of -dir, e.g. "bolt:owndb.bolt"`)
+	extensions := flag.String("extensions", "", "comma-separated registered extensions to start with the database")
+	var clustering clusterFlags
+	flag.StringVar(&clustering.id, "cluster-id", "", "join a replicated cluster as the node with this ID")
+	flag.StringVar(&clustering.addr, "cluster-addr", "", "address to take Raft messages on, as host:port")
+	flag.StringVar(&clustering.advertise, "cluster-advertise", "", "address other nodes reach -cluster-addr at, -cluster-addr by default")
+	flag.StringVar(&clustering.dir, "cluster-dir", "", "directory for the Raft log and snapshots, outside -dir")
+	flag.StringVar(&clustering.api, "cluster-api", "", "URL other nodes redirect writes to this server at, from -addr and the host name by default")
+	flag.BoolVar(&clustering.bootstrap, "cluster-bootstrap", false, "start a new cluster with this node as its only member")
+	flag.StringVar(&clustering.join, "cluster-join", "", "comma-separated URLs of the servers of cluster members to join the cluster through")
+	flag.StringVar(&clustering.tokenFile, "cluster-token-file", "", "file holding the API key or JWT to join and probe the other cluster members with")
+	var authProviders specs
+	flag.Var(&authProviders, "auth", `also authenticate with this registered provider, as "<name>[:<config>]"; may be repeated`)
+	var limits limit.Limits
+	flag.Float64Var(&limits.Rate, "rate", 0, "requests per second admitted from all clients, 0 for no limit")
+	flag.IntVar(&limits.Burst, "burst", 0, "requests admitted at once above -rate, -rate by default")
+	flag.Float64Var(&limits.ClientRate, "client-rate", 0, "requests per second admitted from each client IP, 0 for no limit")
+	flag.IntVar(&limits.ClientBurst, "client-burst", 0, "requests admitted at once above -client-rate, -client-rate by default")
+	flag.IntVar(&limits.MaxActive, "max-active", 0, "requests served at once, 0 for no cap")
+	flag.Parse()
+
+	opts := &database.Options{}
+	if *config != "" {
+		var err error
+		if opts, err = loadConfig(*config); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	opts.ReadOnly = opts.ReadOnly || *readOnly
+	if clustering.id != "" {
+		// These would write to the store of one node only.
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{{"read-only", opts.ReadOnly}, {"grpc-addr", *grpcAddr != ""}, {"memcached-addr", *memcachedAddr != ""}, {"graphql", *graphQL}, {"procedures", *procedures != ""}}
+		for _, c := range conflicts {
+			if c.set {
+				fmt.Fprintf(os.Stderr, "Error: -%s cannot be used with -cluster-id\n", c.flag)
+				os.Exit(1)
+			}
+		}
+	}
+	if *slowOp != 0 {
+		opts.SlowOpThreshold = *slowOp
+	}
+	// Jitter spreads the load of several servers sharing a schedule.
+	if *compact != "" {
+		opts.Maintenance = append(opts.Maintenance, database.MaintenanceJob{Name: "compact", Schedule: *compact, Jitter: time.Minute, Task: database.CompactTask})
+	}
+	if *verify != "" {
+		opts.Maintenance = append(opts.Maintenance, database.MaintenanceJob{Name: "verify", Schedule: *verify, Jitter: time.Minute, Task: database.VerifyTask})
+	}
+	if *backup != "" {
+		policy, err := backups.policy()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		opts.Maintenance = append(opts.Maintenance, database.MaintenanceJob{Name: "backup", Schedule: *backup, Jitter: time.Minute, Task: database.BackupTaskWith(policy)})
+	}
+	if *procedures != "" {
+		procs, err := loadProcedures(*procedures)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		opts.Procedures = procs
+	}
+	if *codec != "" {
+		c, err := database.LookupCodec(*codec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		opts.Codec = c
+	}
+	if *backend != "" {
+		b, err := database.OpenBackend(*backend)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		opts.Backend = b
+	}
+	if *extensions != "" {
+		opts.Extensions = strings.Split(*extensions, ",")
+	}
+	if *logJSON {
+		opts.Logger = database.NewSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	}
+	db, err := database.New(*dir, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var limiter *limit.Limiter
+	if limits != (limit.Limits{}) {
+		limiter = limit.New(limits)
+	}
+	certRole := ""
+	if *clientCA != "" {
+		certRole = *clientCertRole
+	}
+	authenticator, err := newAuth(*apiKeys, *jwtSecret, *jwtIssuer, *jwtAudience, certRole, authProviders)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		db.Close()
+		os.Exit(1)
+	}
+	tlsConfig, err := newTLSConfig(ctx, *tlsCert, *tlsKey, *tlsReload, *clientCA, *apiKeys != "" || *jwtSecret != "" || len(authProviders) > 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		db.Close()
+		os.Exit(1)
+	}
+
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			db.Close()
+			os.Exit(1)
+		}
+		var unary []grpc.UnaryServerInterceptor
+		var stream []grpc.StreamServerInterceptor
+		if limiter != nil {
+			unary = append(unary, rpc.UnaryLimit(limiter))
+			stream = append(stream, rpc.StreamLimit(limiter))
+		}
+		if authenticator != nil {
+			unary = append(unary, rpc.UnaryAuth(authenticator))
+			stream = append(stream, rpc.StreamAuth(authenticator))
+		}
+		grpcOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+		if tlsConfig != nil {
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		g := grpc.NewServer(grpcOpts...)
+		rpc.RegisterDatabaseServer(g, rpc.NewServer(db))
+		go g.Serve(lis)
+		defer g.GracefulStop()
+		fmt.Printf("Serving gRPC on %s\n", *grpcAddr)
+	}
+
+	if *memcachedAddr != "" {
+		if authenticator != nil {
+			fmt.Fprintln(os.Stderr, "Warning: the memcached protocol is served without authentication")
+		}
+		mc := memcache.New(db, "")
+		mc.TLSConfig = tlsConfig
+		go func() {
+			if err := mc.ListenAndServe(ctx, *memcachedAddr); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+		}()
+		fmt.Printf("Serving memcached on %s\n", *memcachedAddr)
+	}
+
+	srv := server.New(db)
+	srv.Limiter = limiter
+	srv.Auth = authenticator
+	srv.TLSConfig = tlsConfig
+	if clustering.id != "" {
+		node, err := clustering.start(db, *addr, tlsConfig != nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			db.Close()
+			os.Exit(1)
+		}
+		defer node.Close()
+		srv.Writes = node
+		srv.Ready = node.Ready
+		h := node.Handler()
+		srv.Handle("/cluster", h)
+		srv.Handle("/cluster/", h)
+	}
+	if *metricsFlag {
+		srv.Handle("GET /metrics", metrics.New(db).Handler())
+	}
+	if *expvarFlag {
+		db.Publish("owndb")
+		srv.Handle("GET /debug/vars", expvar.Handler())
+	}
+	if *debug {
+		srv.EnableDebug()
+	}
+	if *graphQL {
+		h, err := gql.NewHandler(db, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			db.Close()
+			os.Exit(1)
+		}
+		srv.Handle("/graphql", h)
+	}
+	if *admin {
+		srv.EnableAdmin()
+	}
+
+	fmt.Printf("Serving %s on %s\n", *dir, *addr)
+	if err := srv.ListenAndServe(ctx, *addr); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		db.Close()
+		os.Exit(1)
+	}
+}
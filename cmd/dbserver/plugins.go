@@ -0,0 +1,24 @@
+package main
+
+// Plugins built into dbserver. Add a blank import of a package registering
+// a codec, backend, extension or authentication provider to build it in,
+// then pick it with -codec, -backend, -extensions or -auth.
+import (
+	"strings"
+
+	_ "github.com/siraiwaqarali/golang-own-database/examples/plugins/auditlog"
+	_ "github.com/siraiwaqarali/golang-own-database/examples/plugins/compactjson"
+	_ "github.com/siraiwaqarali/golang-own-database/examples/plugins/statictoken"
+)
+
+// specs collects the values of a repeated flag.
+type specs []string
+
+func (s *specs) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *specs) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// loadProcedures reads a procedure from every .lua file in dir, named after
+// the file. A first line of "-- collections: accounts, log" lists the
+// collections it uses.
+func loadProcedures(dir string) ([]database.Procedure, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	var procs []database.Procedure
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		p := database.Procedure{Name: strings.TrimSuffix(filepath.Base(file), ".lua"), Source: string(b)}
+		first, _, _ := bufio.NewReader(strings.NewReader(p.Source)).ReadLine()
+		if list, ok := strings.CutPrefix(strings.TrimSpace(string(first)), "-- collections:"); ok {
+			for _, c := range strings.Split(list, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					p.Collections = append(p.Collections, c)
+				}
+			}
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
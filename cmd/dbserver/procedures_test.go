@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProcedures(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"transfer.lua": "-- collections: accounts, log ,\nreturn 1\n",
+		"ping.lua":     "return 'pong'\n",
+		"notes.txt":    "not a procedure",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	procs, err := loadProcedures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 2 || procs[0].Name != "ping" || procs[1].Name != "transfer" {
+		t.Fatalf("loadProcedures = %+v", procs)
+	}
+	if len(procs[0].Collections) != 0 || strings.Join(procs[1].Collections, ",") != "accounts,log" {
+		t.Errorf("collections = %q, %q", procs[0].Collections, procs[1].Collections)
+	}
+	if procs[0].Source != "return 'pong'\n" {
+		t.Errorf("source = %q", procs[0].Source)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/certs"
+)
+
+// newTLSConfig returns the TLS configuration for the TLS flags, or nil if
+// none are set. The certificate is reloaded on SIGHUP and, with a reload
+// interval, when its files change, until ctx is done. Client certificates
+// are required unless optional, when clients may authenticate otherwise.
+func newTLSConfig(ctx context.Context, certFile, keyFile string, reload time.Duration, clientCA string, optional bool) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCA != "" {
+			return nil, errors.New("-client-ca needs -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("-tls-cert and -tls-key must be given together")
+	}
+
+	r, err := certs.Load(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	logError := func(err error) { fmt.Fprintln(os.Stderr, "Error: reloading certificate:", err) }
+	if reload > 0 {
+		go r.Watch(ctx, reload, logError)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := r.Reload(); err != nil {
+					logError(err)
+				}
+			}
+		}
+	}()
+
+	config := r.Config()
+	if clientCA != "" {
+		pool, err := certs.ClientCAs(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if optional {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return config, nil
+}
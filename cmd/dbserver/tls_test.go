@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewTLSConfigFlags(t *testing.T) {
+	ctx := context.Background()
+	if config, err := newTLSConfig(ctx, "", "", 0, "", false); config != nil || err != nil {
+		t.Errorf("newTLSConfig without TLS flags = %v, %v", config, err)
+	}
+	for _, tt := range []struct{ cert, key, clientCA string }{
+		{"cert.pem", "", ""},
+		{"", "key.pem", ""},
+		{"", "", "ca.pem"},
+		{"missing.pem", "missing.pem", ""},
+	} {
+		if _, err := newTLSConfig(ctx, tt.cert, tt.key, 0, tt.clientCA, false); err == nil {
+			t.Errorf("newTLSConfig(%q, %q, client CA %q) succeeded", tt.cert, tt.key, tt.clientCA)
+		}
+	}
+}
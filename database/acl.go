@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+type Permission uint8
+
+const (
+	PermRead Permission = 1 << iota
+	PermWrite
+	PermDelete
+
+	PermReadWrite = PermRead | PermWrite
+	PermAll       = PermRead | PermWrite | PermDelete
+)
+
+func (p Permission) String() string {
+	var names []string
+	if p&PermRead != 0 {
+		names = append(names, "read")
+	}
+	if p&PermWrite != 0 {
+		names = append(names, "write")
+	}
+	if p&PermDelete != 0 {
+		names = append(names, "delete")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "+")
+}
+
+// Role grants permissions per collection. Sub-collections without an entry
+// of their own take the entry of the collection at the root of their tree,
+// and the "*" entry applies to every collection left.
+type Role struct {
+	Name        string
+	Collections map[string]Permission
+}
+
+func ReadOnlyRole(name string) *Role {
+	return &Role{Name: name, Collections: map[string]Permission{"*": PermRead}}
+}
+
+func AdminRole(name string) *Role {
+	return &Role{Name: name, Collections: map[string]Permission{"*": PermAll}}
+}
+
+func (r *Role) Allows(collection string, p Permission) bool {
+	if r == nil {
+		return true
+	}
+	granted, ok := r.Collections[collection]
+	if !ok {
+		granted, ok = r.Collections[baseCollection(collection)]
+	}
+	if !ok {
+		granted, ok = r.Collections[rootCollection(collection)]
+	}
+	if !ok {
+		granted = r.Collections["*"]
+	}
+	return granted&p == p
+}
+
+func (d *Driver) authorize(collection string, p Permission) error {
+	if d.role.Allows(collection, p) {
+		return nil
+	}
+	if collection == "*" {
+		collection = "the database"
+	}
+	return fmt.Errorf("%w: role %s cannot %s %s", ErrPermissionDenied, d.role.Name, p, collection)
+}
+
+// Principal is an authenticated caller. Operations made through the Context
+// methods with a context carrying a Principal are limited by its Role on
+// top of the Driver's own, and report its Name to the hooks.
+type Principal struct {
+	Name string
+	// Role nil leaves only the Driver's role to decide.
+	Role *Role
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the Principal in ctx, or nil if there is none.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+func (d *Driver) authorizeContext(ctx context.Context, collection string, p Permission) error {
+	if err := d.authorize(collection, p); err != nil {
+		return err
+	}
+	principal := PrincipalFrom(ctx)
+	if principal == nil || principal.Role.Allows(collection, p) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (role %s) cannot %s %s", ErrPermissionDenied, principal.Name, principal.Role.Name, p, collection)
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoleAllows(t *testing.T) {
+	r := &Role{Name: "support", Collections: map[string]Permission{
+		"*":       PermRead,
+		"tickets": PermReadWrite,
+		"users":   0,
+	}}
+	for _, tc := range []struct {
+		collection string
+		p          Permission
+		want       bool
+	}{
+		{"orders", PermRead, true},
+		{"orders", PermWrite, false},
+		{"tickets", PermReadWrite, true},
+		{"tickets", PermDelete, false},
+		{"tickets/42/comments", PermWrite, true},
+		{".namespaces/acme/tickets", PermWrite, true},
+		{"users", PermRead, false},
+	} {
+		if got := r.Allows(tc.collection, tc.p); got != tc.want {
+			t.Errorf("Allows(%s, %s) = %v, want %v", tc.collection, tc.p, got, tc.want)
+		}
+	}
+	var none *Role
+	if !none.Allows("users", PermAll) {
+		t.Error("a nil role denied access")
+	}
+}
+
+func TestDriverRole(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err = New(dir, &Options{Role: ReadOnlyRole("reader"), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil {
+		t.Fatalf("Read as a reader: %v", err)
+	}
+	if err := d.Write("users", "bob", v); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Write as a reader = %v, want ErrPermissionDenied", err)
+	}
+	if err := d.Delete("users", "ada"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Delete as a reader = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestPrincipalRole(t *testing.T) {
+	d, err := NewMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	ctx := WithPrincipal(context.Background(), &Principal{Name: "ada", Role: ReadOnlyRole("reader")})
+	if PrincipalFrom(ctx).Name != "ada" {
+		t.Fatal("PrincipalFrom lost the principal")
+	}
+	if err := d.WriteContext(ctx, "users", "ada", 1); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("WriteContext as a reader = %v, want ErrPermissionDenied", err)
+	}
+	admin := WithPrincipal(context.Background(), &Principal{Name: "root", Role: AdminRole("admin")})
+	if err := d.WriteContext(admin, "users", "ada", 1); err != nil {
+		t.Errorf("WriteContext as an admin: %v", err)
+	}
+}
+
+func TestPrincipalRestricts(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutAttachment("users", "ada", "avatar", strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := WithPrincipal(context.Background(), &Principal{Name: "ada", Role: ReadOnlyRole("reader")})
+	writes := map[string]error{
+		"WriteRawContext":         d.WriteRawContext(reader, "users", "bob", strings.NewReader(`{}`)),
+		"TouchContext":            d.TouchContext(reader, "users", "ada", time.Hour),
+		"PutAttachmentContext":    d.PutAttachmentContext(reader, "users", "ada", "cv", strings.NewReader("pdf")),
+		"DeleteAttachmentContext": d.DeleteAttachmentContext(reader, "users", "ada", "avatar"),
+		"SetManifestContext":      d.SetManifestContext(reader, "users", Manifest{}),
+		"DropSeriesContext":       d.DropSeriesContext(reader, "cpu"),
+	}
+	_, writes["MigrateCompressionContext"] = d.MigrateCompressionContext(reader, "users", CompressionGzip, false)
+	_, writes["AcquireLeaseContext"] = d.AcquireLeaseContext(reader, "leader", time.Minute)
+	for name, err := range writes {
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s as a reader = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if _, err := d.ExpiresAtContext(reader, "users", "ada"); err != nil {
+		t.Errorf("ExpiresAtContext as a reader: %v", err)
+	}
+	if _, err := d.CollectionStatsContext(reader, "users"); err != nil {
+		t.Errorf("CollectionStatsContext as a reader: %v", err)
+	}
+
+	none := WithPrincipal(context.Background(), &Principal{Name: "eve", Role: &Role{Name: "none", Collections: map[string]Permission{}}})
+	reads := map[string]error{}
+	_, reads["ExpiresAtContext"] = d.ExpiresAtContext(none, "users", "ada")
+	_, reads["GetAttachmentContext"] = d.GetAttachmentContext(none, "users", "ada", "avatar")
+	_, reads["AttachmentsContext"] = d.AttachmentsContext(none, "users", "ada")
+	_, reads["SubCollectionsContext"] = d.SubCollectionsContext(none, "users", "ada")
+	_, reads["CollectionTreeContext"] = d.CollectionTreeContext(none, "users")
+	_, reads["ManifestContext"] = d.ManifestContext(none, "users")
+	_, _, reads["UsageContext"] = d.UsageContext(none, "users")
+	_, reads["CollectionStatsContext"] = d.CollectionStatsContext(none, "users")
+	_, reads["Queue.LenContext"] = d.Queue("jobs").LenContext(none)
+	_, reads["CountersContext"] = d.CountersContext(none)
+	for name, err := range reads {
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s without a grant = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// Stage is one step of an aggregation pipeline and sets exactly one of its
+// fields. Documents enter the pipeline in key order with their key in a
+// "_key" field.
+//
+//	[]Stage{
+//		{Match: map[string][]string{"active": {"true"}}},
+//		{Group: &Group{By: "address.city", Fields: map[string]Accumulator{
+//			"users": {Op: "count"},
+//			"age":   {Op: "avg", Field: "age"},
+//		}}},
+//		{Sort: []string{"-users"}},
+//		{Limit: 10},
+//	}
+type Stage struct {
+	// Match keeps the documents a Query with this Where would, Filter
+	// those for which the expression is true.
+	Match  map[string][]string `json:"match,omitempty"`
+	Filter string              `json:"filter,omitempty"`
+	// Project keeps only the listed fields, with dots for nested fields.
+	Project []string `json:"project,omitempty"`
+	Group   *Group   `json:"group,omitempty"`
+	// Sort orders the documents by the listed fields, each descending if
+	// it starts with "-". Missing fields sort first, then false and true,
+	// numbers, strings and anything else.
+	Sort  []string `json:"sort,omitempty"`
+	Limit int      `json:"limit,omitempty"`
+}
+
+// Group replaces the documents with one per distinct value of the By
+// field, or a single one for all of them if By is empty, in the order the
+// values first appear. Each has the value as "_key" and the Fields computed
+// over its documents.
+type Group struct {
+	By     string                 `json:"by,omitempty"`
+	Fields map[string]Accumulator `json:"fields,omitempty"`
+}
+
+// Accumulator computes a group field from a field of the group's
+// documents: "count" counts the documents, "sum" and "avg" add up the
+// numbers, "min" and "max" use the Sort order, and "first" and "last" take
+// the value of the first and last document having the field.
+type Accumulator struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+}
+
+func (s Stage) validate() error {
+	set := 0
+	for _, ok := range []bool{s.Match != nil, s.Filter != "", s.Project != nil, s.Group != nil, s.Sort != nil, s.Limit != 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("set exactly one of match, filter, project, group, sort and limit")
+	}
+	switch {
+	case s.Match != nil:
+		for field := range s.Match {
+			if err := validField(field); err != nil {
+				return err
+			}
+		}
+	case s.Filter != "":
+		_, err := Compile(s.Filter)
+		return err
+	case s.Project != nil:
+		return validFields(s.Project)
+	case s.Group != nil:
+		if s.Group.By != "" {
+			if err := validField(s.Group.By); err != nil {
+				return err
+			}
+		}
+		for name, acc := range s.Group.Fields {
+			if name == "" || name == "_key" {
+				return fmt.Errorf("%w: group field %q", ErrInvalidName, name)
+			}
+			switch acc.Op {
+			case "count":
+			case "sum", "avg", "min", "max", "first", "last":
+				if err := validField(acc.Field); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown accumulator %q for group field %s", acc.Op, name)
+			}
+		}
+	case s.Sort != nil:
+		for _, field := range s.Sort {
+			if err := validField(strings.TrimPrefix(field, "-")); err != nil {
+				return err
+			}
+		}
+	case s.Limit < 0:
+		return fmt.Errorf("invalid limit %d - must not be negative", s.Limit)
+	}
+	return nil
+}
+
+func validFields(fields []string) error {
+	for _, field := range fields {
+		if err := validField(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aggregate runs a pipeline over a collection and returns the documents
+// coming out of its last stage.
+func (d *Driver) Aggregate(collection string, pipeline []Stage) ([]map[string]interface{}, error) {
+	return d.AggregateContext(context.Background(), collection, pipeline)
+}
+
+// AggregateContext is Aggregate with a context to trace the operation in.
+func (d *Driver) AggregateContext(ctx context.Context, collection string, pipeline []Stage) (docs []map[string]interface{}, err error) {
+	op := d.startOp(ctx, "aggregate", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to read records")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	for i, s := range pipeline {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%w: stage %d: %w", ErrInvalidPipeline, i, err)
+		}
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	keys, err := d.liveKeys(collection)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	// A leading match or filter is applied while reading, so the rest of
+	// the pipeline only holds the documents it keeps.
+	withMeta := pipelineUsesMeta(pipeline)
+	match := func(map[string]interface{}) bool { return true }
+	if len(pipeline) > 0 && (pipeline[0].Match != nil || pipeline[0].Filter != "") {
+		match, _ = Query{Where: pipeline[0].Match, Filter: pipeline[0].Filter}.matcher()
+		pipeline = pipeline[1:]
+	}
+	docs = []map[string]interface{}{}
+	for _, key := range keys {
+		b, err := d.readRecord(collection, key)
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		op.addBytes(int64(len(b)))
+		doc, err := d.decodeDocument(collection, b)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			doc = make(map[string]interface{})
+		}
+		doc["_key"] = key
+		if withMeta {
+			if doc, err = d.addMeta(doc, collection, key, b); err != nil {
+				return nil, err
+			}
+		}
+		if match(doc) {
+			docs = append(docs, doc)
+		}
+	}
+
+	for _, s := range pipeline {
+		docs = s.apply(docs)
+	}
+	return docs, nil
+}
+
+// ApplyPipeline runs a pipeline over documents read already, as Aggregate
+// does over the records of a collection, for combining the documents of
+// several databases. They should come in key order with their key in a
+// "_key" field. The slice may be reused for the result.
+func ApplyPipeline(docs []map[string]interface{}, pipeline []Stage) ([]map[string]interface{}, error) {
+	for i, s := range pipeline {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%w: stage %d: %w", ErrInvalidPipeline, i, err)
+		}
+	}
+	for _, s := range pipeline {
+		docs = s.apply(docs)
+	}
+	return docs, nil
+}
+
+func (s Stage) apply(docs []map[string]interface{}) []map[string]interface{} {
+	switch {
+	case s.Match != nil, s.Filter != "":
+		match, _ := Query{Where: s.Match, Filter: s.Filter}.matcher()
+		kept := docs[:0]
+		for _, doc := range docs {
+			if match(doc) {
+				kept = append(kept, doc)
+			}
+		}
+		return kept
+	case s.Project != nil:
+		for i, doc := range docs {
+			docs[i] = project(doc, s.Project)
+		}
+	case s.Group != nil:
+		return s.Group.apply(docs)
+	case s.Sort != nil:
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, field := range s.Sort {
+				desc := strings.HasPrefix(field, "-")
+				a, _ := getField(docs[i], strings.TrimPrefix(field, "-"))
+				b, _ := getField(docs[j], strings.TrimPrefix(field, "-"))
+				if c := compareValues(a, b); c != 0 {
+					return c < 0 != desc
+				}
+			}
+			return false
+		})
+	case s.Limit < len(docs):
+		return docs[:s.Limit]
+	}
+	return docs
+}
+
+func (g *Group) apply(docs []map[string]interface{}) []map[string]interface{} {
+	type group struct {
+		doc  map[string]interface{}
+		docs []map[string]interface{}
+	}
+	var groups []*group
+	byValue := make(map[string]*group)
+	for _, doc := range docs {
+		var v interface{}
+		if g.By != "" {
+			v, _ = getField(doc, g.By)
+		}
+		id := formatField(v)
+		grp, ok := byValue[id]
+		if !ok {
+			grp = &group{doc: map[string]interface{}{"_key": v}}
+			byValue[id] = grp
+			groups = append(groups, grp)
+		}
+		grp.docs = append(grp.docs, doc)
+	}
+
+	out := make([]map[string]interface{}, 0, len(groups))
+	for _, grp := range groups {
+		for name, acc := range g.Fields {
+			grp.doc[name] = acc.apply(grp.docs)
+		}
+		out = append(out, grp.doc)
+	}
+	return out
+}
+
+func (a Accumulator) apply(docs []map[string]interface{}) interface{} {
+	if a.Op == "count" {
+		return len(docs)
+	}
+	var result interface{}
+	var sum float64
+	n := 0
+	for _, doc := range docs {
+		v, ok := getField(doc, a.Field)
+		if !ok {
+			continue
+		}
+		switch a.Op {
+		case "sum", "avg":
+			if f, ok := toFloat(v); ok {
+				sum += f
+				n++
+			}
+		case "min":
+			if n == 0 || compareValues(v, result) < 0 {
+				result = v
+			}
+			n++
+		case "max":
+			if n == 0 || compareValues(v, result) > 0 {
+				result = v
+			}
+			n++
+		case "first":
+			if n == 0 {
+				result = v
+			}
+			n++
+		case "last":
+			result = v
+		}
+	}
+	switch a.Op {
+	case "sum":
+		return sum
+	case "avg":
+		if n == 0 {
+			return nil
+		}
+		return sum / float64(n)
+	}
+	return result
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// compareValues orders field values as the Sort stage documents.
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch ra {
+	case 1:
+		ba, bb := a.(bool), b.(bool)
+		switch {
+		case ba == bb:
+			return 0
+		case bb:
+			return -1
+		}
+		return 1
+	case 2:
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(a.(string), b.(string))
+	case 4:
+		return strings.Compare(formatField(a), formatField(b))
+	}
+	return 0
+}
+
+func typeRank(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	if _, ok := v.(bool); ok {
+		return 1
+	}
+	if _, ok := toFloat(v); ok {
+		return 2
+	}
+	if _, ok := v.(string); ok {
+		return 3
+	}
+	return 4
+}
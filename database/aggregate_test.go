@@ -0,0 +1,80 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for key, u := range map[string]map[string]interface{}{
+		"a": {"name": "A", "active": true, "age": 30, "address": map[string]interface{}{"city": "Karachi"}},
+		"b": {"name": "B", "active": true, "age": 20, "address": map[string]interface{}{"city": "Lahore"}},
+		"c": {"name": "C", "active": true, "age": 40, "address": map[string]interface{}{"city": "Karachi"}},
+		"d": {"name": "D", "active": false, "age": 50},
+		"e": {"name": "E", "active": true, "age": "unknown"},
+	} {
+		if err := d.Write("users", key, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		pipeline string
+		want     string
+	}{
+		{"group", `[
+			{"match": {"active": ["true"]}},
+			{"group": {"by": "address.city", "fields": {
+				"n": {"op": "count"}, "avg": {"op": "avg", "field": "age"}, "max": {"op": "max", "field": "name"},
+				"first": {"op": "first", "field": "_key"}, "last": {"op": "last", "field": "_key"}}}},
+			{"sort": ["-n"]},
+			{"limit": 1}
+		]`, `[{"_key":"Karachi","avg":35,"first":"a","last":"c","max":"C","n":2}]`},
+		{"sort and project", `[{"match": {"active": ["false", "true"]}}, {"sort": ["-age"]}, {"project": ["_key", "age"]}]`,
+			`[{"_key":"e","age":"unknown"},{"_key":"d","age":50},{"_key":"c","age":40},{"_key":"a","age":30},{"_key":"b","age":20}]`},
+		{"group all", `[{"group": {"fields": {"total": {"op": "sum", "field": "age"}, "min": {"op": "min", "field": "age"}}}}]`,
+			`[{"_key":null,"min":20,"total":140}]`},
+		// Missing fields sort first.
+		{"missing fields", `[{"sort": ["address.city", "_key"]}, {"project": ["_key"]}, {"limit": 3}]`,
+			`[{"_key":"d"},{"_key":"e"},{"_key":"a"}]`},
+	} {
+		var pipeline []Stage
+		if err := json.Unmarshal([]byte(tc.pipeline), &pipeline); err != nil {
+			t.Fatal(err)
+		}
+		docs, err := d.Aggregate("users", pipeline)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if b, _ := json.Marshal(docs); string(b) != tc.want {
+			t.Errorf("%s = %s, want %s", tc.name, b, tc.want)
+		}
+	}
+
+	if docs, err := d.Aggregate("nothing", nil); err != nil || len(docs) != 0 {
+		t.Errorf("Aggregate of a missing collection = %v, %v", docs, err)
+	}
+
+	for _, pipeline := range [][]Stage{
+		{{}},
+		{{Limit: 1, Sort: []string{"age"}}},
+		{{Limit: -1}},
+		{{Project: []string{"a..b"}}},
+		{{Sort: []string{"-"}}},
+		{{Group: &Group{Fields: map[string]Accumulator{"_key": {Op: "count"}}}}},
+		{{Group: &Group{Fields: map[string]Accumulator{"n": {Op: "median", Field: "age"}}}}},
+		{{Group: &Group{Fields: map[string]Accumulator{"n": {Op: "sum"}}}}},
+	} {
+		if _, err := d.Aggregate("users", pipeline); !errors.Is(err, ErrInvalidPipeline) {
+			t.Errorf("Aggregate(%+v) = %v, want ErrInvalidPipeline", pipeline, err)
+		}
+	}
+}
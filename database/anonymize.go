@@ -0,0 +1,163 @@
+package database
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Transform replaces a field value in an anonymized export.
+type Transform string
+
+const (
+	// TransformHash replaces a value with a keyed hash of it, so equal
+	// values still match across records and collections.
+	TransformHash Transform = "hash"
+	// TransformRedact replaces a value with "[redacted]".
+	TransformRedact Transform = "redact"
+	// TransformDrop leaves the field out.
+	TransformDrop Transform = "drop"
+	// The fake transforms substitute a made-up value of the same kind,
+	// picked by a keyed hash of the original, so equal values get equal
+	// substitutes.
+	TransformFakeName    Transform = "fake-name"
+	TransformFakeEmail   Transform = "fake-email"
+	TransformFakePhone   Transform = "fake-phone"
+	TransformFakeAddress Transform = "fake-address"
+)
+
+func (t Transform) valid() bool {
+	switch t {
+	case TransformHash, TransformRedact, TransformDrop, TransformFakeName, TransformFakeEmail, TransformFakePhone, TransformFakeAddress:
+		return true
+	}
+	return false
+}
+
+// Anonymization maps collections to the transforms of their fields, which
+// are named with dots for nested fields. Like CollectionOptions, the
+// transforms of a collection apply to it in every namespace.
+type Anonymization map[string]map[string]Transform
+
+func (a Anonymization) fields(collection string) map[string]Transform {
+	if fields, ok := a[collection]; ok {
+		return fields
+	}
+	return a[baseCollection(collection)]
+}
+
+// ExportAnonymized is Export with the fields of a applied their
+// transforms, for sharing production-shaped data. Fields and collections
+// a leaves out are exported as they are, and so are keys, so collections
+// keyed by personal data need more than this. salt keys the hashes: exports
+// with the same salt hash values the same, and without one hashes only
+// match within an export.
+func (d *Driver) ExportAnonymized(w io.Writer, a Anonymization, salt []byte, collections ...string) (int, error) {
+	for collection, fields := range a {
+		for field, t := range fields {
+			if err := validField(field); err != nil {
+				return 0, fmt.Errorf("anonymization of %s: %w", collection, err)
+			}
+			if !t.valid() {
+				return 0, fmt.Errorf("unknown transform %q for field %s of %s", t, field, collection)
+			}
+		}
+	}
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return 0, err
+		}
+	}
+
+	return d.export(w, collections, nil, func(collection string, raw json.RawMessage) (json.RawMessage, error) {
+		fields := a.fields(collection)
+		if len(fields) == 0 {
+			return raw, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			// Not a document, so it has no fields to transform.
+			return raw, nil
+		}
+		names := make([]string, 0, len(fields))
+		for field := range fields {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			v, ok := getField(doc, field)
+			if !ok {
+				continue
+			}
+			if t := fields[field]; t == TransformDrop {
+				deleteField(doc, field)
+			} else {
+				setField(doc, field, anonymize(t, v, salt))
+			}
+		}
+		return json.Marshal(doc)
+	})
+}
+
+func deleteField(doc map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, parts[len(parts)-1])
+}
+
+var (
+	fakeFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Rowan", "Harper", "Emerson", "Finley", "Skyler", "Reese"}
+	fakeLastNames  = []string{"Smith", "Khan", "Garcia", "Chen", "Okafor", "Novak", "Silva", "Ahmed", "Kim", "Meyer", "Rossi", "Haddad", "Ivanova", "Tanaka", "Dubois", "Larsen"}
+	fakeStreets    = []string{"Oak", "Maple", "Cedar", "Elm", "Pine", "Birch", "Willow", "Lake", "Hill", "River", "Park", "Mill"}
+	fakeSuffixes   = []string{"Street", "Avenue", "Road", "Lane", "Drive", "Court"}
+	fakeCities     = []string{"Springfield", "Riverton", "Fairview", "Lakewood", "Greenville", "Milford", "Ashford", "Brookside"}
+)
+
+// anonymize applies a transform other than TransformDrop to a value.
+func anonymize(t Transform, v interface{}, salt []byte) interface{} {
+	b, _ := json.Marshal(v)
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(b)
+	sum := mac.Sum(nil)
+	// pick draws the next index below n from the hash.
+	next := sum
+	pick := func(n int) int {
+		i := binary.BigEndian.Uint32(next) % uint32(n)
+		next = next[4:]
+		return int(i)
+	}
+
+	switch t {
+	case TransformHash:
+		return hex.EncodeToString(sum[:16])
+	case TransformFakeName:
+		return fakeFirstNames[pick(len(fakeFirstNames))] + " " + fakeLastNames[pick(len(fakeLastNames))]
+	case TransformFakeEmail:
+		first, last := fakeFirstNames[pick(len(fakeFirstNames))], fakeLastNames[pick(len(fakeLastNames))]
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), pick(10000))
+	case TransformFakePhone:
+		// 555 is not a real area code.
+		return fmt.Sprintf("+1-555-%03d-%04d", pick(1000), pick(10000))
+	case TransformFakeAddress:
+		return fmt.Sprintf("%d %s %s, %s", 1+pick(9999), fakeStreets[pick(len(fakeStreets))], fakeSuffixes[pick(len(fakeSuffixes))], fakeCities[pick(len(fakeCities))])
+	}
+	return "[redacted]"
+}
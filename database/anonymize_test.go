@@ -0,0 +1,113 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testAnonymization = Anonymization{
+	"users": {
+		"name": TransformFakeName, "email": TransformFakeEmail, "phone": TransformFakePhone,
+		"address.street": TransformFakeAddress, "address.zip": TransformRedact, "ssn": TransformDrop,
+	},
+	"orders": {"email": TransformFakeEmail},
+	"counts": {"n": TransformHash},
+}
+
+// exportAnonymized returns the records of an anonymized export by
+// collection and key.
+func exportAnonymized(t *testing.T, d *Driver, salt []byte) (string, map[string]map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := d.ExportAnonymized(&buf, testAnonymization, salt); err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]map[string]interface{})
+	sc := bufio.NewScanner(strings.NewReader(buf.String()))
+	for sc.Scan() {
+		var r ExportRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("export line %s: %v", sc.Bytes(), err)
+		}
+		// Records that are not documents have no fields to transform.
+		var doc map[string]interface{}
+		json.Unmarshal(r.Value, &doc)
+		records[r.Collection+"/"+r.Key] = doc
+	}
+	return buf.String(), records
+}
+
+func TestExportAnonymized(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	jane := map[string]interface{}{
+		"name": "Jane Roe", "email": "jane@corp.com", "age": 31, "phone": "020 7946 0000", "ssn": "078-05-1120",
+		"address": map[string]string{"street": "1 Real Street", "zip": "12345"},
+	}
+	writes := []struct {
+		collection, key string
+		v               interface{}
+	}{
+		{"users", "1", jane},
+		{"users", "2", map[string]string{"name": "Jane Roe", "email": "jane@corp.com"}},
+		{"orders", "o", map[string]interface{}{"email": "jane@corp.com", "total": 12.5}},
+		{"counts", "n", 5},
+	}
+	for _, w := range writes {
+		if err := d.Write(w.collection, w.key, w.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Namespace("acme").Write("users", "3", map[string]string{"name": "Jane Roe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	salt := []byte("salt")
+	export, records := exportAnonymized(t, d, salt)
+	for _, leak := range []string{"Jane", "corp.com", "Real Street", "12345", "078-05-1120", "7946"} {
+		if strings.Contains(export, leak) {
+			t.Errorf("anonymized export holds %q:\n%s", leak, export)
+		}
+	}
+	user := records["users/1"]
+	if user == nil || user["age"] != 31.0 || user["address"].(map[string]interface{})["zip"] != "[redacted]" {
+		t.Errorf("anonymized user = %v", user)
+	}
+	if _, ok := user["ssn"]; ok {
+		t.Errorf("dropped field exported: %v", user)
+	}
+	if !strings.HasSuffix(user["email"].(string), "@example.com") || !strings.HasPrefix(user["phone"].(string), "+1-555-") {
+		t.Errorf("fake email and phone = %v, %v", user["email"], user["phone"])
+	}
+	// Equal values get equal substitutes, across collections and
+	// namespaces too.
+	if records["users/2"]["email"] != user["email"] || records["orders/o"]["email"] != user["email"] {
+		t.Errorf("fake emails differ: %v, %v, %v", user["email"], records["users/2"]["email"], records["orders/o"]["email"])
+	}
+	if !strings.Contains(export, `"value":5}`) {
+		t.Errorf("record that is not a document not exported as it is:\n%s", export)
+	}
+	if ns := records[".namespaces/acme/users/3"]; ns == nil || ns["name"] != user["name"] {
+		t.Errorf("namespaced user = %v, want the name anonymized alike", ns)
+	}
+	if again, _ := exportAnonymized(t, d, salt); again != export {
+		t.Error("exports with the same salt differ")
+	}
+	if other, _ := exportAnonymized(t, d, nil); other == export {
+		t.Error("an export with a random salt matches one with a given salt")
+	}
+
+	var buf bytes.Buffer
+	if _, err := d.ExportAnonymized(&buf, Anonymization{"users": {"name": "scramble"}}, nil); err == nil {
+		t.Error("ExportAnonymized with an unknown transform succeeded")
+	}
+	if _, err := d.ExportAnonymized(&buf, Anonymization{"users": {"a..b": TransformDrop}}, nil); err == nil {
+		t.Error("ExportAnonymized with an invalid field succeeded")
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrAppendOnly is returned when writing over, or deleting, a record of a
+// collection with CollectionOptions.AppendOnly.
+var ErrAppendOnly = errors.New("collection is append-only")
+
+// checkOverwrite fails with ErrAppendOnly if collection is append-only and
+// already holds the record at p. Callers hold the collection lock.
+func (d *Driver) checkOverwrite(collection, p string) error {
+	if !d.collectionOptions(collection).AppendOnly {
+		return nil
+	}
+	_, _, err := d.getLiveRecord(p)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("record %s is already written: %w", p, ErrAppendOnly)
+}
+
+// checkDelete fails with ErrAppendOnly if any of collections is
+// append-only.
+func (d *Driver) checkDelete(collections ...string) error {
+	for _, collection := range collections {
+		if d.collectionOptions(collection).AppendOnly {
+			return fmt.Errorf("cannot delete from collection %s: %w", collection, ErrAppendOnly)
+		}
+	}
+	return nil
+}
+
+// ForceDelete is Delete removing records of append-only collections too,
+// for the rare record that must go from a ledger anyway, such as one
+// written by mistake or that the law says to erase.
+func (d *Driver) ForceDelete(collection, resource string) error {
+	return d.ForceDeleteContext(context.Background(), collection, resource)
+}
+
+// ForceDeleteContext is ForceDelete with a context to trace the operation
+// in.
+func (d *Driver) ForceDeleteContext(ctx context.Context, collection, resource string) error {
+	return d.delete(ctx, collection, resource, true)
+}
+
+// ForceDropCollection is DropCollection removing append-only collections
+// too.
+func (d *Driver) ForceDropCollection(ctx context.Context, collection string) (*DeleteReport, error) {
+	return d.drop(ctx, collection, false, true)
+}
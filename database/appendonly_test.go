@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAppendOnly(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"audit": {AppendOnly: true}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("audit", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("audit", "a", map[string]int{"n": 2}); !errors.Is(err, ErrAppendOnly) {
+		t.Errorf("Write over a record = %v, want ErrAppendOnly", err)
+	}
+	if err := d.Delete("audit", "a"); !errors.Is(err, ErrAppendOnly) {
+		t.Errorf("Delete = %v, want ErrAppendOnly", err)
+	}
+	if _, err := d.DropCollection(context.Background(), "audit", false); !errors.Is(err, ErrAppendOnly) {
+		t.Errorf("DropCollection = %v, want ErrAppendOnly", err)
+	}
+	if _, err := d.Insert("audit", map[string]int{"n": 3}); err != nil {
+		t.Errorf("Insert = %v", err)
+	}
+	var v map[string]int
+	if err := d.Read("audit", "a", &v); err != nil || v["n"] != 1 {
+		t.Errorf("Read = %v, %v; want the first write", v, err)
+	}
+
+	if err := d.Write("other", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("other", "a", 2); err != nil {
+		t.Errorf("Write over a record of another collection = %v", err)
+	}
+}
+
+func TestAppendOnlySession(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"audit": {AppendOnly: true}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("audit", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := d.BeginContext(context.Background(), "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write("audit", "b", map[string]int{"n": 1})
+	s.Delete("audit", "a")
+	if err := s.Commit(); !errors.Is(err, ErrAppendOnly) {
+		t.Fatalf("Commit of a delete = %v, want ErrAppendOnly", err)
+	}
+	if keys, err := d.Keys("audit"); err != nil || len(keys) != 1 {
+		t.Errorf("Keys after a refused commit = %v, %v; want only a", keys, err)
+	}
+}
+
+func TestForceDelete(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"audit": {AppendOnly: true}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("audit", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ForceDelete("audit", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("audit", "a", map[string]int{"n": 2}); err != nil {
+		t.Errorf("Write of a force-deleted record = %v", err)
+	}
+	if _, err := d.ForceDropCollection(context.Background(), "audit"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Keys("audit"); err == nil {
+		t.Error("Keys of a force-dropped collection succeeded")
+	}
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Attachments of a record live in a directory next to it, named after the
+// record file stem plus this suffix. Record listings skip directories, so
+// they never show up as records.
+const attachmentsSuffix = ".attachments"
+
+func (d *Driver) attachmentsDir(collection, resource string) string {
+	return d.recordPath(collection, resource) + attachmentsSuffix
+}
+
+func (d *Driver) checkAttachment(ctx context.Context, collection, resource, name string, p Permission) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - unable to find attachment")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to find attachment (no record name)")
+	}
+	if name == "" {
+		return fmt.Errorf("missing attachment name")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	return d.authorizeContext(ctx, collection, p)
+}
+
+// PutAttachment stores the contents of r as the attachment name of an
+// existing record, replacing any attachment of that name. Backends that
+// support streaming never hold the whole attachment in memory, unless the
+// database is encrypted.
+func (d *Driver) PutAttachment(collection, resource, name string, r io.Reader) error {
+	return d.PutAttachmentContext(context.Background(), collection, resource, name, r)
+}
+
+// PutAttachmentContext is PutAttachment with a context carrying the caller.
+func (d *Driver) PutAttachmentContext(ctx context.Context, collection, resource, name string, r io.Reader) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := d.checkAttachment(ctx, collection, resource, name, PermWrite); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, _, err := d.getLiveRecord(d.recordPath(collection, resource)); err != nil {
+		if isNotExist(err) {
+			return fmt.Errorf("unable to find record %s/%s to attach %s to: %w", collection, resource, name, fs.ErrNotExist)
+		}
+		return err
+	}
+
+	// Attachment names are encoded like keys, which never start with a dot,
+	// so the temp file cannot clash with another attachment.
+	dir := d.attachmentsDir(collection, resource)
+	fnlPath := path.Join(dir, d.encodeKey(name))
+	tmpPath := path.Join(dir, "."+d.encodeKey(name)+".tmp")
+
+	if _, err := d.putStream(tmpPath, r); err != nil {
+		d.backend.Delete(tmpPath)
+		return err
+	}
+	return d.backend.Rename(tmpPath, fnlPath)
+}
+
+// GetAttachment opens the attachment name of a record. The caller must
+// close it. A missing attachment is reported with an error matching
+// fs.ErrNotExist.
+func (d *Driver) GetAttachment(collection, resource, name string) (io.ReadCloser, error) {
+	return d.GetAttachmentContext(context.Background(), collection, resource, name)
+}
+
+// GetAttachmentContext is GetAttachment with a context carrying the caller.
+func (d *Driver) GetAttachmentContext(ctx context.Context, collection, resource, name string) (io.ReadCloser, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.checkAttachment(ctx, collection, resource, name, PermRead); err != nil {
+		return nil, err
+	}
+
+	rc, err := d.getStream(path.Join(d.attachmentsDir(collection, resource), d.encodeKey(name)))
+	if isNotExist(err) {
+		return nil, fmt.Errorf("unable to find attachment %s of %s/%s: %w", name, collection, resource, fs.ErrNotExist)
+	}
+	return rc, err
+}
+
+// Attachments lists the attachment names of a record.
+func (d *Driver) Attachments(collection, resource string) ([]string, error) {
+	return d.AttachmentsContext(context.Background(), collection, resource)
+}
+
+// AttachmentsContext is Attachments with a context carrying the caller.
+func (d *Driver) AttachmentsContext(ctx context.Context, collection, resource string) ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.checkAttachment(ctx, collection, resource, "*", PermRead); err != nil {
+		return nil, err
+	}
+
+	files, err := d.backend.List(d.attachmentsDir(collection, resource))
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if isDirName(file) || strings.HasPrefix(file, ".") {
+			continue
+		}
+		names = append(names, decodeKey(file))
+	}
+	return names, nil
+}
+
+func (d *Driver) DeleteAttachment(collection, resource, name string) error {
+	return d.DeleteAttachmentContext(context.Background(), collection, resource, name)
+}
+
+// DeleteAttachmentContext is DeleteAttachment with a context carrying the
+// caller.
+func (d *Driver) DeleteAttachmentContext(ctx context.Context, collection, resource, name string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := d.checkAttachment(ctx, collection, resource, name, PermDelete); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	p := path.Join(d.attachmentsDir(collection, resource), d.encodeKey(name))
+	if err := d.backend.Delete(p); err != nil {
+		if isNotExist(err) {
+			return fmt.Errorf("unable to find attachment %s of %s/%s: %w", name, collection, resource, fs.ErrNotExist)
+		}
+		return err
+	}
+	return nil
+}
+
+// putStream writes r to p, streaming when the backend allows it, and
+// returns the number of bytes stored. Encryption seals whole buffers, so
+// encrypted databases always buffer.
+func (d *Driver) putStream(p string, r io.Reader) (int64, error) {
+	if sb, ok := d.backend.(StreamBackend); ok && d.keys == nil {
+		cr := &countingReader{r: r}
+		err := sb.PutStream(p, cr)
+		return cr.n, err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if b, err = d.encrypt(b); err != nil {
+		return 0, err
+	}
+	return int64(len(b)), d.backend.Put(p, b)
+}
+
+func (d *Driver) getStream(p string) (io.ReadCloser, error) {
+	if sb, ok := d.backend.(StreamBackend); ok && d.keys == nil {
+		return sb.GetStream(p)
+	}
+	b, err := d.backend.Get(p)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = d.decrypt(b); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
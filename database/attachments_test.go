@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAttachment(t *testing.T, d *Driver, collection, resource, name string) string {
+	t.Helper()
+	rc, err := d.GetAttachment(collection, resource, name)
+	if err != nil {
+		t.Fatalf("GetAttachment %s: %v", name, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestAttachments(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.PutAttachment("users", "ada", "avatar.png", strings.NewReader("png")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("PutAttachment to a missing record = %v, want fs.ErrNotExist", err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"avatar.png": "png", "cv/2024.pdf": "pdf"} {
+		if err := d.PutAttachment("users", "ada", name, strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readAttachment(t, d, "users", "ada", "cv/2024.pdf"); got != "pdf" {
+		t.Errorf("attachment = %q, want pdf", got)
+	}
+	if err := d.PutAttachment("users", "ada", "avatar.png", strings.NewReader("png2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAttachment(t, d, "users", "ada", "avatar.png"); got != "png2" {
+		t.Errorf("replaced attachment = %q, want png2", got)
+	}
+	names, err := d.Attachments("users", "ada")
+	if err != nil || !reflect.DeepEqual(names, []string{"avatar.png", "cv/2024.pdf"}) {
+		t.Errorf("Attachments = %q, %v", names, err)
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 1 {
+		t.Errorf("attachments listed as records: %v", keys)
+	}
+
+	if err := d.DeleteAttachment("users", "ada", "avatar.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetAttachment("users", "ada", "avatar.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetAttachment after deleting = %v, want fs.ErrNotExist", err)
+	}
+	if err := d.DeleteAttachment("users", "ada", "avatar.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second DeleteAttachment = %v, want fs.ErrNotExist", err)
+	}
+
+	if err := d.Delete("users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := d.Attachments("users", "ada"); len(names) != 0 {
+		t.Errorf("attachments outlived their record: %v", names)
+	}
+}
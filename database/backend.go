@@ -0,0 +1,392 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend is the raw storage the Driver runs on. Paths are slash-separated
+// and relative to the database root. List returns the names of the direct
+// children of dir, with a trailing "/" on sub-directories. Put creates any
+// missing parents, Delete removes a record or a whole directory, and Get,
+// List and Delete report missing paths with an error matching
+// fs.ErrNotExist.
+type Backend interface {
+	Put(path string, b []byte) error
+	Get(path string) ([]byte, error)
+	List(dir string) ([]string, error)
+	Delete(path string) error
+	Rename(from, to string) error
+}
+
+// StreamBackend is implemented by backends that can store and load data
+// without holding it all in memory. Other backends are handed whole buffers.
+type StreamBackend interface {
+	PutStream(path string, r io.Reader) error
+	GetStream(path string) (io.ReadCloser, error)
+}
+
+// StatBackend is implemented by backends that can report a stored file's
+// size and when it was last written without reading it.
+type StatBackend interface {
+	Stat(path string) (size int64, modTime time.Time, err error)
+}
+
+// AppendBackend is implemented by backends that can add to the end of a
+// stored file, creating it if need be, without rewriting it. Other backends
+// are handed the whole file again.
+type AppendBackend interface {
+	Append(path string, b []byte) error
+}
+
+// CreateBackend is implemented by backends that can store a file only if
+// it does not exist yet, as one atomic step even between processes sharing
+// the storage, reporting an existing one with an error matching
+// fs.ErrExist.
+type CreateBackend interface {
+	Create(path string, b []byte) error
+}
+
+type fileBackend struct {
+	root     string
+	dirMode  os.FileMode
+	fileMode os.FileMode
+	gid      int
+	// sync flushes every write to storage before it returns, for
+	// DurabilitySync.
+	sync bool
+	// changed, if set, is called with every path the backend writes,
+	// renames or deletes, once it is done with it.
+	changed func(path string)
+}
+
+func NewFileBackend(root string) Backend {
+	return newFileBackend(root, 0755, 0644, -1)
+}
+
+func newFileBackend(root string, dirMode, fileMode os.FileMode, gid int) *fileBackend {
+	return &fileBackend{root: filepath.Clean(root), dirMode: dirMode, fileMode: fileMode, gid: gid}
+}
+
+// mkdirAll is os.MkdirAll that also hands every directory it creates to
+// the configured group.
+func (f *fileBackend) mkdirAll(dir string) error {
+	if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := f.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, f.dirMode); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return f.chown(dir)
+}
+
+func (f *fileBackend) touch(paths ...string) {
+	if f.changed == nil {
+		return
+	}
+	for _, p := range paths {
+		f.changed(p)
+	}
+}
+
+func (f *fileBackend) chown(p string) error {
+	if f.gid < 0 {
+		return nil
+	}
+	return os.Chown(p, -1, f.gid)
+}
+
+func lookupGroup(group string) (int, error) {
+	if group == "" {
+		return -1, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		if g, err = user.LookupGroupId(group); err != nil {
+			return -1, fmt.Errorf("unknown group %q", group)
+		}
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return -1, fmt.Errorf("group ownership is not supported for group id %q", g.Gid)
+	}
+	return gid, nil
+}
+
+// path maps a backend path onto the file system, refusing anything that
+// would resolve outside the database directory.
+func (f *fileBackend) path(p string) (string, error) {
+	full, err := f.cleanPath(p)
+	if err != nil {
+		return "", err
+	}
+	return longPath(full), nil
+}
+
+// cleanPath is path before it is made a long path on Windows, so it can be
+// compared with the root.
+func (f *fileBackend) cleanPath(p string) (string, error) {
+	full := filepath.Join(f.root, filepath.FromSlash(p))
+	if rel, err := filepath.Rel(f.root, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: path %q escapes the database directory", ErrInvalidName, p)
+	}
+	return full, nil
+}
+
+func (f *fileBackend) Put(path string, b []byte) error {
+	p, err := f.path(path)
+	if err != nil {
+		return err
+	}
+	defer f.touch(path)
+	if err := f.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	if err := f.writeFile(p, b); err != nil {
+		return err
+	}
+	return f.chown(p)
+}
+
+func (f *fileBackend) writeFile(p string, b []byte) error {
+	if !f.sync {
+		return os.WriteFile(p, b, f.fileMode)
+	}
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	return f.close(file)
+}
+
+// close closes a file written to, flushing it first for DurabilitySync.
+func (f *fileBackend) close(file *os.File) error {
+	if f.sync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// syncDir flushes the entries of dir for DurabilitySync, so a rename or
+// delete in it survives a crash. Windows cannot flush directories.
+func (f *fileBackend) syncDir(dir string) error {
+	if !f.sync || runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (f *fileBackend) PutStream(path string, r io.Reader) error {
+	p, err := f.path(path)
+	if err != nil {
+		return err
+	}
+	defer f.touch(path)
+	if err := f.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := f.close(file); err != nil {
+		return err
+	}
+	return f.chown(p)
+}
+
+func (f *fileBackend) Create(path string, b []byte) error {
+	p, err := f.path(path)
+	if err != nil {
+		return err
+	}
+	if err := f.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	// The file is written under a temp name and linked into place, which
+	// fails if p exists, so a crash never leaves it there half written.
+	// The temp file is opened with the file mode, so the umask applies.
+	var file *os.File
+	for {
+		tmpPath := filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+"."+strconv.FormatUint(rand.Uint64(), 36)+".tmp")
+		file, err = os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.fileMode)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	if err := f.close(file); err != nil {
+		return err
+	}
+	if err := f.chown(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Link(tmpPath, p); err != nil {
+		return err
+	}
+	defer f.touch(path)
+	return f.syncDir(filepath.Dir(p))
+}
+
+func (f *fileBackend) Append(path string, b []byte) error {
+	p, err := f.path(path)
+	if err != nil {
+		return err
+	}
+	defer f.touch(path)
+	if err := f.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	_, statErr := os.Stat(p)
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, f.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	if err := f.close(file); err != nil {
+		return err
+	}
+	if os.IsNotExist(statErr) {
+		return f.chown(p)
+	}
+	return nil
+}
+
+func (f *fileBackend) GetStream(path string) (io.ReadCloser, error) {
+	p, err := f.path(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (f *fileBackend) Get(path string) ([]byte, error) {
+	p, err := f.path(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (f *fileBackend) Stat(path string) (int64, time.Time, error) {
+	p, err := f.path(path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return fi.Size(), fi.ModTime(), nil
+}
+
+func (f *fileBackend) List(dir string) ([]string, error) {
+	p, err := f.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *fileBackend) Delete(path string) error {
+	full, err := f.cleanPath(path)
+	if err != nil {
+		return err
+	}
+	if full == f.root {
+		return fmt.Errorf("refusing to delete the database directory")
+	}
+	p := longPath(full)
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+	defer f.touch(path)
+	if err := os.RemoveAll(p); err != nil {
+		return err
+	}
+	return f.syncDir(filepath.Dir(p))
+}
+
+func (f *fileBackend) Rename(from, to string) error {
+	src, err := f.path(from)
+	if err != nil {
+		return err
+	}
+	dst, err := f.path(to)
+	if err != nil {
+		return err
+	}
+	if err := f.mkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	defer f.touch(from, to)
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	return f.syncDir(filepath.Dir(dst))
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+func isDirName(name string) bool {
+	return strings.HasSuffix(name, "/")
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+type aferoBackend struct {
+	fs afero.Fs
+}
+
+func NewAferoBackend(fs afero.Fs) Backend {
+	return &aferoBackend{fs: fs}
+}
+
+func (a *aferoBackend) path(p string) string {
+	return filepath.FromSlash(p)
+}
+
+func (a *aferoBackend) Put(path string, b []byte) error {
+	p := a.path(path)
+	if err := a.fs.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return afero.WriteFile(a.fs, p, b, 0644)
+}
+
+func (a *aferoBackend) Create(path string, b []byte) error {
+	p := a.path(path)
+	if err := a.fs.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := a.fs.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (a *aferoBackend) Get(path string) ([]byte, error) {
+	return afero.ReadFile(a.fs, a.path(path))
+}
+
+func (a *aferoBackend) Stat(path string) (int64, time.Time, error) {
+	fi, err := a.fs.Stat(a.path(path))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return fi.Size(), fi.ModTime(), nil
+}
+
+func (a *aferoBackend) List(dir string) ([]string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := afero.ReadDir(a.fs, a.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (a *aferoBackend) Delete(path string) error {
+	p := a.path(path)
+	if _, err := a.fs.Stat(p); err != nil {
+		return err
+	}
+	return a.fs.RemoveAll(p)
+}
+
+func (a *aferoBackend) Rename(from, to string) error {
+	if err := a.fs.MkdirAll(filepath.Dir(a.path(to)), 0755); err != nil {
+		return err
+	}
+	return a.fs.Rename(a.path(from), a.path(to))
+}
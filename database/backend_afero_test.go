@@ -0,0 +1,30 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestAferoBackend(t *testing.T) {
+	testBackend(t, NewAferoBackend(afero.NewMemMapFs()))
+}
+
+func TestAferoBackendDriver(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	d, err := New("", &Options{Backend: NewAferoBackend(fs), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := afero.Exists(fs, "users/ada.json"); err != nil || !ok {
+		t.Fatalf("record not in the afero file system: %v", err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+}
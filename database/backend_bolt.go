@@ -0,0 +1,205 @@
+package database
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltFiles = []byte("files")
+	boltDirs  = []byte("dirs")
+)
+
+// BoltBackend keeps every record in a single bbolt file instead of one file
+// per record. Each Put, Delete and Rename is its own transaction, and
+// concurrent writers are coalesced with bolt's Batch so high-churn
+// collections share fsyncs.
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+func NewBoltBackend(file string) (*BoltBackend, error) {
+	db, err := bolt.Open(file, 0644, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltFiles); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltDirs)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+func dirPrefix(dir string) []byte {
+	if dir == "" {
+		return nil
+	}
+	return []byte(dir + "/")
+}
+
+func addParents(dirs *bolt.Bucket, p string) error {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if err := dirs.Put([]byte(dir), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltBackend) Put(p string, data []byte) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		if err := addParents(tx.Bucket(boltDirs), p); err != nil {
+			return err
+		}
+		return tx.Bucket(boltFiles).Put([]byte(p), data)
+	})
+}
+
+func (b *BoltBackend) Create(p string, data []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltFiles).Get([]byte(p)) != nil {
+			return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+		}
+		if err := addParents(tx.Bucket(boltDirs), p); err != nil {
+			return err
+		}
+		return tx.Bucket(boltFiles).Put([]byte(p), data)
+	})
+}
+
+func (b *BoltBackend) Get(p string) ([]byte, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltFiles).Get([]byte(p))
+		if v == nil {
+			return notExist("open", p)
+		}
+		data = append([]byte(nil), v...)
+		return nil
+	})
+	return data, err
+}
+
+func children(bucket *bolt.Bucket, prefix []byte, fn func(rest string)) {
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if rest := string(k[len(prefix):]); rest != "" && !strings.Contains(rest, "/") {
+			fn(rest)
+		}
+	}
+}
+
+func (b *BoltBackend) List(dir string) ([]string, error) {
+	var names []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		dirs := tx.Bucket(boltDirs)
+		if dir != "" && dirs.Get([]byte(dir)) == nil {
+			return notExist("readdir", dir)
+		}
+		prefix := dirPrefix(dir)
+		children(tx.Bucket(boltFiles), prefix, func(rest string) { names = append(names, rest) })
+		children(dirs, prefix, func(rest string) { names = append(names, rest+"/") })
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltBackend) Delete(p string) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		files, dirs := tx.Bucket(boltFiles), tx.Bucket(boltDirs)
+		if files.Get([]byte(p)) != nil {
+			return files.Delete([]byte(p))
+		}
+		if p != "" && dirs.Get([]byte(p)) == nil {
+			return notExist("remove", p)
+		}
+		if err := deletePrefix(files, dirPrefix(p)); err != nil {
+			return err
+		}
+		if err := deletePrefix(dirs, dirPrefix(p)); err != nil {
+			return err
+		}
+		if p == "" {
+			return nil
+		}
+		return dirs.Delete([]byte(p))
+	})
+}
+
+func movePrefix(bucket *bolt.Bucket, from, to []byte) error {
+	type kv struct{ k, v []byte }
+	var moved []kv
+	c := bucket.Cursor()
+	for k, v := c.Seek(from); k != nil && bytes.HasPrefix(k, from); k, v = c.Next() {
+		moved = append(moved, kv{append([]byte(nil), k...), append([]byte(nil), v...)})
+	}
+	for _, e := range moved {
+		if err := bucket.Delete(e.k); err != nil {
+			return err
+		}
+		if err := bucket.Put(append(append([]byte(nil), to...), e.k[len(from):]...), e.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltBackend) Rename(from, to string) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		files, dirs := tx.Bucket(boltFiles), tx.Bucket(boltDirs)
+		if err := addParents(dirs, to); err != nil {
+			return err
+		}
+		if v := files.Get([]byte(from)); v != nil {
+			v = append([]byte(nil), v...)
+			if err := files.Delete([]byte(from)); err != nil {
+				return err
+			}
+			return files.Put([]byte(to), v)
+		}
+		if from == "" || dirs.Get([]byte(from)) == nil {
+			return notExist("rename", from)
+		}
+		if err := movePrefix(files, dirPrefix(from), dirPrefix(to)); err != nil {
+			return err
+		}
+		if err := movePrefix(dirs, dirPrefix(from), dirPrefix(to)); err != nil {
+			return err
+		}
+		if err := dirs.Delete([]byte(from)); err != nil {
+			return err
+		}
+		return dirs.Put([]byte(to), []byte{})
+	})
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openBolt(t *testing.T, file string) *BoltBackend {
+	t.Helper()
+	b, err := NewBoltBackend(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBoltBackend(t *testing.T) {
+	b := openBolt(t, filepath.Join(t.TempDir(), "db.bolt"))
+	defer b.Close()
+	testBackend(t, b)
+}
+
+func TestBoltBackendReopen(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db.bolt")
+	b := openBolt(t, file)
+	d, err := New("", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	b.Close()
+
+	b = openBolt(t, file)
+	defer b.Close()
+	d, err = New("", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Fatalf("Read after reopening = %v, %v", v, err)
+	}
+}
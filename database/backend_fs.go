@@ -0,0 +1,94 @@
+package database
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// fsBackend serves a database from any fs.FS (embed.FS, zip archives,
+// fstest.MapFS...). io/fs has no write operations, so it is read-only.
+type fsBackend struct {
+	fsys fs.FS
+}
+
+func NewFSBackend(fsys fs.FS) Backend {
+	return &fsBackend{fsys: fsys}
+}
+
+// NewFromFS opens the database stored in fsys read-only, so a program can
+// ship a reference dataset inside its binary with embed and query it as
+// any other database. Use fs.Sub for one in a directory of an embed.FS:
+//
+//	//go:embed countries
+//	var files embed.FS
+//
+//	sub, _ := fs.Sub(files, "countries")
+//	db, err := database.NewFromFS(sub, nil)
+//
+// Options.Backend and Options.ReadOnly are set for it; writes fail with
+// ErrReadOnly.
+func NewFromFS(fsys fs.FS, options *Options) (*Driver, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	opts.Backend = NewFSBackend(fsys)
+	opts.ReadOnly = true
+	return New("", &opts)
+}
+
+func fsPath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (f *fsBackend) Put(path string, b []byte) error {
+	return ErrReadOnly
+}
+
+func (f *fsBackend) PutStream(path string, r io.Reader) error {
+	return ErrReadOnly
+}
+
+func (f *fsBackend) GetStream(path string) (io.ReadCloser, error) {
+	return f.fsys.Open(fsPath(path))
+}
+
+func (f *fsBackend) Get(path string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, fsPath(path))
+}
+
+func (f *fsBackend) Stat(path string) (int64, time.Time, error) {
+	fi, err := fs.Stat(f.fsys, fsPath(path))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return fi.Size(), fi.ModTime(), nil
+}
+
+func (f *fsBackend) List(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(f.fsys, fsPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *fsBackend) Delete(path string) error {
+	return ErrReadOnly
+}
+
+func (f *fsBackend) Rename(from, to string) error {
+	return ErrReadOnly
+}
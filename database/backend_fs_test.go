@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSBackend(t *testing.T) {
+	b := NewFSBackend(fstest.MapFS{
+		"users/ada.json": {Data: []byte(`{"name":"Ada"}`)},
+		"users/.keys/x":  {Data: []byte("key")},
+	})
+	if got, err := b.Get("users/ada.json"); err != nil || string(got) != `{"name":"Ada"}` {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if _, err := b.Get("users/bob.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get of a missing file = %v, want fs.ErrNotExist", err)
+	}
+	names, err := b.List("users")
+	if err != nil || len(names) != 2 || names[0] != ".keys/" || names[1] != "ada.json" {
+		t.Fatalf("List = %q, %v", names, err)
+	}
+	if err := b.Put("users/bob.json", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put = %v, want ErrReadOnly", err)
+	}
+	if err := b.Delete("users/ada.json"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete = %v, want ErrReadOnly", err)
+	}
+	if err := b.Rename("users/ada.json", "users/bob.json"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Rename = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestNewFromFS(t *testing.T) {
+	d, err := NewFromFS(fstest.MapFS{
+		"countries/pk.json":            {Data: []byte(`{"name":"Pakistan","region":"asia"}`)},
+		"countries/fr.json":            {Data: []byte(`{"name":"France","region":"europe"}`)},
+		"countries/pk/cities/khi.json": {Data: []byte(`{"name":"Karachi"}`)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var v map[string]string
+	if err := d.Read("countries", "pk", &v); err != nil || v["name"] != "Pakistan" {
+		t.Errorf("Read = %v, %v", v, err)
+	}
+	if keys, err := d.Keys("countries"); err != nil || len(keys) != 2 {
+		t.Errorf("Keys = %v, %v; want fr and pk", keys, err)
+	}
+	results, err := d.Find(Query{Collection: "countries", Where: map[string][]string{"region": {"europe"}}})
+	if err != nil || len(results) != 1 || results[0].Key != "fr" {
+		t.Errorf("Find = %v, %v; want fr", results, err)
+	}
+	if cities, err := d.ReadAll("countries/pk/cities"); err != nil || len(cities) != 1 {
+		t.Errorf("ReadAll of a subcollection = %v, %v", cities, err)
+	}
+	if err := d.Write("countries", "de", map[string]string{"name": "Germany"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write = %v, want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memoryBackend struct {
+	mutex sync.RWMutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func NewMemoryBackend() Backend {
+	return &memoryBackend{
+		files: make(map[string][]byte),
+		dirs:  map[string]bool{"": true},
+	}
+}
+
+// NewMemory opens a Driver whose collections live only in memory, for tests
+// that should not touch the disk.
+func NewMemory(options *Options) (*Driver, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	opts.Backend = NewMemoryBackend()
+	return New("memory", &opts)
+}
+
+func notExist(op, p string) error {
+	return &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+}
+
+func (m *memoryBackend) Put(p string, b []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for dir := path.Dir(p); dir != "." && !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	m.files[p] = append([]byte(nil), b...)
+	return nil
+}
+
+func (m *memoryBackend) Create(p string, b []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.files[p]; ok {
+		return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+	}
+	for dir := path.Dir(p); dir != "." && !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	m.files[p] = append([]byte(nil), b...)
+	return nil
+}
+
+func (m *memoryBackend) Append(p string, b []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for dir := path.Dir(p); dir != "." && !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	m.files[p] = append(m.files[p], b...)
+	return nil
+}
+
+func (m *memoryBackend) Get(p string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	b, ok := m.files[p]
+	if !ok {
+		return nil, notExist("open", p)
+	}
+	return append([]byte(nil), b...), nil
+}
+
+func (m *memoryBackend) List(dir string) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if !m.dirs[dir] {
+		return nil, notExist("readdir", dir)
+	}
+
+	prefix := dir
+	if prefix != "" {
+		prefix += "/"
+	}
+	var names []string
+	for p := range m.files {
+		if rest := strings.TrimPrefix(p, prefix); strings.HasPrefix(p, prefix) && !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	for d := range m.dirs {
+		if rest := strings.TrimPrefix(d, prefix); d != "" && strings.HasPrefix(d, prefix) && !strings.Contains(rest, "/") {
+			names = append(names, rest+"/")
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memoryBackend) Delete(p string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.files[p]; ok {
+		delete(m.files, p)
+		return nil
+	}
+	if !m.dirs[p] {
+		return notExist("remove", p)
+	}
+
+	prefix := p + "/"
+	if p == "" {
+		prefix = ""
+	}
+	for f := range m.files {
+		if strings.HasPrefix(f, prefix) {
+			delete(m.files, f)
+		}
+	}
+	for d := range m.dirs {
+		if d != "" && (d == p || strings.HasPrefix(d, prefix)) {
+			delete(m.dirs, d)
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) Rename(from, to string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if b, ok := m.files[from]; ok {
+		delete(m.files, from)
+		m.files[to] = b
+		return nil
+	}
+	if from == "" || !m.dirs[from] {
+		return notExist("rename", from)
+	}
+
+	for f, b := range m.files {
+		if strings.HasPrefix(f, from+"/") {
+			delete(m.files, f)
+			m.files[to+strings.TrimPrefix(f, from)] = b
+		}
+	}
+	for d := range m.dirs {
+		if d == from || strings.HasPrefix(d, from+"/") {
+			delete(m.dirs, d)
+			m.dirs[to+strings.TrimPrefix(d, from)] = true
+		}
+	}
+	for dir := path.Dir(to); dir != "." && !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend())
+}
+
+func TestMemoryBackendCopies(t *testing.T) {
+	b := NewMemoryBackend()
+	data := []byte("ada")
+	if err := b.Put("users/ada.json", data); err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 'x'
+	if got, _ := b.Get("users/ada.json"); string(got) != "ada" {
+		t.Errorf("Put kept the caller's buffer: %q", got)
+	}
+}
+
+func TestNewMemory(t *testing.T) {
+	d, err := NewMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+	if _, err := os.Stat("memory"); !os.IsNotExist(err) {
+		t.Errorf("NewMemory touched the disk: %v", err)
+	}
+}
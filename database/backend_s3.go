@@ -0,0 +1,449 @@
+package database
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Config points the S3 backend at a bucket on any S3-compatible object
+// store: AWS, MinIO, Ceph, or Google Cloud Storage through its XML API with
+// HMAC keys (Endpoint "https://storage.googleapis.com").
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Client    *http.Client
+}
+
+type s3Backend struct {
+	cfg    S3Config
+	client *http.Client
+	base   *url.URL
+
+	// ETags of objects this backend just uploaded, so Rename only copies
+	// the exact object written by the matching Put.
+	mutex sync.Mutex
+	etags map[string]string
+}
+
+func NewS3Backend(cfg S3Config) (Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("missing bucket - no place to store records")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3.amazonaws.com"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	base, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.PathStyle {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &s3Backend{cfg: cfg, client: client, base: base, etags: make(map[string]string)}, nil
+}
+
+func (s *s3Backend) key(p string) string {
+	if s.cfg.Prefix == "" {
+		return p
+	}
+	if p == "" {
+		return s.cfg.Prefix
+	}
+	return s.cfg.Prefix + "/" + p
+}
+
+func (s *s3Backend) objectURL(key string, query url.Values) *url.URL {
+	u := *s.base
+	u.Path = "/" + key
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+func (s *s3Backend) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, body, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+func (s *s3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3ResponseError(method, key string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return notExist(strings.ToLower(method), key)
+	}
+	var e s3Error
+	b, _ := io.ReadAll(resp.Body)
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3 %s %s: %s (%s)", method, key, e.Message, e.Code)
+	}
+	return fmt.Errorf("s3 %s %s: unexpected status %s", method, key, resp.Status)
+}
+
+func (s *s3Backend) Put(p string, b []byte) error {
+	key := s.key(p)
+	resp, err := s.do(http.MethodPut, key, nil, nil, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3ResponseError(http.MethodPut, key, resp)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		s.mutex.Lock()
+		s.etags[p] = etag
+		s.mutex.Unlock()
+	}
+	return nil
+}
+
+// Create relies on conditional writes, which S3 and most compatible stores
+// support.
+func (s *s3Backend) Create(p string, b []byte) error {
+	key := s.key(p)
+	resp, err := s.do(http.MethodPut, key, nil, http.Header{"If-None-Match": {"*"}}, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+	}
+	return s3ResponseError(http.MethodPut, key, resp)
+}
+
+func (s *s3Backend) Get(p string) ([]byte, error) {
+	key := s.key(p)
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3ResponseError(http.MethodGet, key, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns every key and, with a delimiter, every common prefix below
+// prefix, following continuation tokens.
+func (s *s3Backend) list(prefix, delimiter string) (keys, prefixes []string, err error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result s3ListResult
+		if resp.StatusCode != http.StatusOK {
+			err = s3ResponseError(http.MethodGet, prefix, resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, c := range result.CommonPrefixes {
+			prefixes = append(prefixes, c.Prefix)
+		}
+		if !result.IsTruncated {
+			return keys, prefixes, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *s3Backend) dirPrefix(dir string) string {
+	if key := s.key(dir); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+func (s *s3Backend) List(dir string) ([]string, error) {
+	prefix := s.dirPrefix(dir)
+	keys, prefixes, err := s.list(prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	if len(keys)+len(prefixes) == 0 && dir != "" {
+		return nil, notExist("readdir", dir)
+	}
+
+	names := make([]string, 0, len(keys)+len(prefixes))
+	for _, k := range keys {
+		if name := strings.TrimPrefix(k, prefix); name != "" {
+			names = append(names, name)
+		}
+	}
+	for _, p := range prefixes {
+		names = append(names, strings.TrimPrefix(p, prefix))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *s3Backend) exists(key string) (bool, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("s3 HEAD %s: unexpected status %s", key, resp.Status)
+}
+
+func (s *s3Backend) deleteKey(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3ResponseError(http.MethodDelete, key, resp)
+	}
+	return nil
+}
+
+// Delete removes the object at p, or every object below p when p names a
+// "directory" (a key prefix).
+func (s *s3Backend) Delete(p string) error {
+	key := s.key(p)
+	if ok, err := s.exists(key); err != nil {
+		return err
+	} else if ok {
+		return s.deleteKey(key)
+	}
+
+	keys, _, err := s.list(s.dirPrefix(p), "")
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return notExist("remove", p)
+	}
+	for _, k := range keys {
+		if err := s.deleteKey(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3Backend) copyKey(from, to, etag string) error {
+	header := http.Header{"X-Amz-Copy-Source": {"/" + s.cfg.Bucket + "/" + s3EscapePath(from)}}
+	if etag != "" {
+		header.Set("X-Amz-Copy-Source-If-Match", etag)
+	}
+	resp, err := s.do(http.MethodPut, to, nil, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3ResponseError(http.MethodPut, to, resp)
+	}
+	// CopyObject can fail after a 200 with an error document in the body.
+	b, _ := io.ReadAll(resp.Body)
+	var e s3Error
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3 copy %s to %s: %s (%s)", from, to, e.Message, e.Code)
+	}
+	return nil
+}
+
+// Rename is a server-side copy followed by a delete. Object stores have no
+// atomic rename, but each object PUT is atomic, so readers see either the
+// old or the new record, never a partial one.
+func (s *s3Backend) Rename(from, to string) error {
+	s.mutex.Lock()
+	etag := s.etags[from]
+	delete(s.etags, from)
+	s.mutex.Unlock()
+
+	fromKey, toKey := s.key(from), s.key(to)
+	ok := etag != ""
+	if !ok {
+		var err error
+		if ok, err = s.exists(fromKey); err != nil {
+			return err
+		}
+	}
+	if ok {
+		if err := s.copyKey(fromKey, toKey, etag); err != nil {
+			return err
+		}
+		return s.deleteKey(fromKey)
+	}
+
+	fromPrefix, toPrefix := s.dirPrefix(from), s.dirPrefix(to)
+	keys, _, err := s.list(fromPrefix, "")
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return notExist("rename", from)
+	}
+	for _, k := range keys {
+		dst := toPrefix + strings.TrimPrefix(k, fromPrefix)
+		if err := s.copyKey(k, dst, ""); err != nil {
+			return err
+		}
+		if err := s.deleteKey(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
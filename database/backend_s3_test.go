@@ -0,0 +1,250 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an S3 bucket in memory, answering the requests the S3 backend
+// and backup target make. Listings are paged two keys at a time to
+// exercise continuation.
+type fakeS3 struct {
+	t       *testing.T
+	mutex   sync.Mutex
+	objects map[string][]byte
+	// uploads are the parts of the multipart uploads in progress.
+	uploads map[string]map[int][]byte
+	started int
+	// failPart, if set, is a part number whose first upload fails.
+	failPart int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, S3Config) {
+	f := &fakeS3{t: t, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return f, S3Config{Endpoint: ts.URL, Bucket: "bucket", Prefix: "db", AccessKey: "key", SecretKey: "secret", PathStyle: true}
+}
+
+func s3ETag(b []byte) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(b))
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodGet && q.Get("list-type") == "2":
+		f.list(w, q)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.started++
+		id := fmt.Sprint(f.started)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		if n == f.failPart {
+			f.failPart = 0
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		parts[n] = body
+		w.Header().Set("ETag", s3ETag(body))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var complete s3CompleteUpload
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var b []byte
+		for _, part := range complete.Parts {
+			if part.ETag != s3ETag(parts[part.PartNumber]) {
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>part not uploaded</Message></Error>")
+				return
+			}
+			b = append(b, parts[part.PartNumber]...)
+		}
+		f.objects[key] = b
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult/>")
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, _ := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/bucket/"))
+		b, ok := f.objects[src]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if etag := r.Header.Get("X-Amz-Copy-Source-If-Match"); etag != "" && etag != s3ETag(b) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.objects[key] = b
+		fmt.Fprint(w, "<CopyObjectResult/>")
+	case r.Method == http.MethodPut:
+		if _, ok := f.objects[key]; ok && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", s3ETag(body))
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		b, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("unexpected %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	prefix, delimiter, after := q.Get("prefix"), q.Get("delimiter"), q.Get("continuation-token")
+	seen := map[string]bool{}
+	var entries []string
+	for k := range f.objects {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if i := strings.Index(k[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			k = k[:len(prefix)+i+1]
+		}
+		if !seen[k] && k > after {
+			seen[k] = true
+			entries = append(entries, k)
+		}
+	}
+	sort.Strings(entries)
+	truncated := len(entries) > 2
+	if truncated {
+		entries = entries[:2]
+	}
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, k := range entries {
+		if strings.HasSuffix(k, delimiter) && delimiter != "" {
+			fmt.Fprintf(w, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", k)
+		} else {
+			fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", k)
+		}
+	}
+	if truncated {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", entries[len(entries)-1])
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func TestS3Backend(t *testing.T) {
+	f, cfg := newFakeS3(t)
+	b, err := NewS3Backend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+	for k := range f.objects {
+		if !strings.HasPrefix(k, "db/") {
+			t.Errorf("object %s stored outside the prefix", k)
+		}
+	}
+}
+
+func TestS3BackendCreate(t *testing.T) {
+	_, cfg := newFakeS3(t)
+	b, err := NewS3Backend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb := b.(CreateBackend)
+	if err := cb.Create(".leases/job/1", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Create(".leases/job/1", []byte("two")); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Create of an existing object = %v, want fs.ErrExist", err)
+	}
+}
+
+func TestS3BackendRenameDirectory(t *testing.T) {
+	_, cfg := newFakeS3(t)
+	b, err := NewS3Backend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := b.Put("users/"+k+".json", []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Rename("users", "people"); err != nil {
+		t.Fatal(err)
+	}
+	names, err := b.List("people")
+	if err != nil || len(names) != 3 {
+		t.Fatalf("List after renaming = %q, %v", names, err)
+	}
+	if _, err := b.List("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("List of the renamed directory = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3BackendDriver(t *testing.T) {
+	_, cfg := newFakeS3(t)
+	b, err := NewS3Backend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New("", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"ada", "bob", "cy"} {
+		if err := d.Write("users", k, map[string]string{"name": k}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := d.Keys("users")
+	if err != nil || len(keys) != 3 {
+		t.Fatalf("Keys = %v, %v", keys, err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "bob", &v); err != nil || v["name"] != "bob" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+}
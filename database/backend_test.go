@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testBackend runs b through the contract of Backend.
+func testBackend(t *testing.T, b Backend) {
+	t.Helper()
+	if _, err := b.Get("users/ada.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get of a missing file = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := b.List("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("List of a missing directory = %v, want fs.ErrNotExist", err)
+	}
+
+	for path, data := range map[string]string{"users/ada.json": "ada", "users/bob.json": "bob", "users/.keys/ada.key": "key"} {
+		if err := b.Put(path, []byte(data)); err != nil {
+			t.Fatalf("Put %s: %v", path, err)
+		}
+	}
+	if got, err := b.Get("users/ada.json"); err != nil || string(got) != "ada" {
+		t.Fatalf("Get = %q, %v; want ada", got, err)
+	}
+	if err := b.Put("users/ada.json", []byte("ada2")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.Get("users/ada.json"); err != nil || string(got) != "ada2" {
+		t.Fatalf("Get after overwriting = %q, %v; want ada2", got, err)
+	}
+	names, err := b.List("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if want := []string{".keys/", "ada.json", "bob.json"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("List = %q, want %q", names, want)
+	}
+
+	if err := b.Rename("users/bob.json", "users/ada.json"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.Get("users/ada.json"); err != nil || string(got) != "bob" {
+		t.Fatalf("Get after renaming over = %q, %v; want bob", got, err)
+	}
+	if _, err := b.Get("users/bob.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get of a renamed file = %v, want fs.ErrNotExist", err)
+	}
+
+	if err := b.Delete("users/.keys"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get("users/.keys/ada.key"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get in a deleted directory = %v, want fs.ErrNotExist", err)
+	}
+	if err := b.Delete("users/ada.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete("users/ada.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Delete of a missing file = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	testBackend(t, NewFileBackend(t.TempDir()))
+}
+
+func TestCustomBackend(t *testing.T) {
+	b := NewFileBackend(t.TempDir())
+	d, err := New("ignored", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get("users/ada.json"); err != nil {
+		t.Fatalf("record not written to the backend: %v", err)
+	}
+	if _, err := os.Stat("ignored"); !os.IsNotExist(err) {
+		t.Errorf("dir used next to the backend: %v", err)
+	}
+}
+
+func TestFileBackendDeleteRefusesRoot(t *testing.T) {
+	dir := t.TempDir()
+	b := NewFileBackend(dir)
+	if err := b.Put("users/ada.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"", ".", "users/.."} {
+		if err := b.Delete(p); err == nil {
+			t.Errorf("Delete(%q) deleted the database directory", p)
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get("users/ada.json"); err != nil {
+		t.Errorf("record gone after refused deletes: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build unix
+
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestFileModes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	d, err := New(dir, &Options{DirMode: 0700, FileMode: 0600, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]os.FileMode{
+		dir:                                     0700 | os.ModeDir,
+		filepath.Join(dir, "users"):             0700 | os.ModeDir,
+		filepath.Join(dir, "users", "ada.json"): 0600,
+	} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != want {
+			t.Errorf("%s has mode %v, want %v", p, fi.Mode(), want)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	gid := os.Getgid()
+	dir := t.TempDir()
+	d, err := New(dir, &Options{Group: strconv.Itoa(gid), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "users", "ada.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Sys().(*syscall.Stat_t).Gid; int(got) != gid {
+		t.Errorf("record owned by group %d, want %d", got, gid)
+	}
+
+	if _, err := New(t.TempDir(), &Options{Group: "no-such-group-here"}); err == nil {
+		t.Error("opened with an unknown group")
+	}
+}
+
+func TestFileBackendCreateUmask(t *testing.T) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+	dir := t.TempDir()
+	b := newFileBackend(dir, 0755, 0644, -1)
+	if err := b.Create("leases/job", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "leases", "job"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 {
+		t.Errorf("created file has mode %v, want the umask applied: %v", fi.Mode(), os.FileMode(0600))
+	}
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// On Windows path returns long paths, which never equal the root, so Delete
+// has to check for the root before making one.
+func TestFileBackendDeleteRefusesLongRoot(t *testing.T) {
+	dir := t.TempDir()
+	b := newFileBackend(dir, 0755, 0644, -1)
+	if p, err := b.path(""); err != nil || !strings.HasPrefix(p, `\\?\`) {
+		t.Fatalf("path(\"\") = %q, %v; want a long path", p, err)
+	}
+	if err := b.Put("users/ada.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(""); err == nil {
+		t.Fatal("Delete(\"\") deleted the database directory")
+	}
+	if _, err := b.Get("users/ada.json"); err != nil {
+		t.Errorf("record gone after a refused delete: %v", err)
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	for p, want := range map[string]string{
+		`C:\db\users\ada.json`:       `\\?\C:\db\users\ada.json`,
+		`\\server\share\db\ada.json`: `\\?\UNC\server\share\db\ada.json`,
+		`\\?\C:\db`:                  `\\?\C:\db`,
+	} {
+		if got := longPath(p); got != want {
+			t.Errorf("longPath(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestLongRecordPath(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	key := strings.Repeat("k", 200)
+	collection := strings.Repeat("c", 100) + "/" + strings.Repeat("p", 100) + "/" + strings.Repeat("s", 100)
+	if err := d.Write(collection, key, 1); err != nil {
+		t.Fatalf("Write past MAX_PATH: %v", err)
+	}
+	var v int
+	if err := d.Read(collection, key, &v); err != nil || v != 1 {
+		t.Fatalf("Read past MAX_PATH = %v, %v", v, err)
+	}
+}
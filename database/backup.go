@@ -0,0 +1,213 @@
+package database
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// walk calls fn with the path of every file below dir, skipping temp files,
+// the lock file and the trash.
+func (d *Driver) walk(dir string, fn func(p string) error) error {
+	entries, err := d.backend.List(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		p := path.Join(dir, strings.TrimSuffix(entry, "/"))
+		switch {
+		case isDirName(entry) && p == trashDir:
+		case isDirName(entry):
+			if err := d.walk(p, fn); err != nil {
+				return err
+			}
+		case p == lockFileName || strings.HasSuffix(p, ".tmp"):
+		default:
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lockAll takes every collection lock, in a fixed order, and returns the
+// func that releases them.
+func (d *Driver) lockAll() (func(), error) {
+	collections, err := d.collectionPaths()
+	if err != nil {
+		return nil, err
+	}
+	queues, err := d.queueCollections()
+	if err != nil {
+		return nil, err
+	}
+	series, err := d.seriesCollections()
+	if err != nil {
+		return nil, err
+	}
+	collections = append(append(collections, queues...), series...)
+	return d.lockCollections(collections), nil
+}
+
+// Backup writes a gzipped tar of every file in the database to w, exactly
+// as stored, so encrypted databases stay encrypted and need the same master
+// key after Restore, followed by a manifest VerifyBackup checks it with.
+// Writes wait until the backup is done, which makes it a consistent
+// snapshot. w running out of space fails it with a DiskFullError.
+func (d *Driver) Backup(w io.Writer) error {
+	return d.BackupWith(w, BackupOptions{})
+}
+
+// BackupOptions tunes BackupWith.
+type BackupOptions struct {
+	// Encryption, if set, encrypts the backup, which then needs the same
+	// passphrase or key to restore.
+	Encryption BackupEncryption
+}
+
+// BackupWith is Backup with options.
+func (d *Driver) BackupWith(w io.Writer, opts BackupOptions) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if err := d.authorize("*", PermRead); err != nil {
+		return err
+	}
+	if err := opts.Encryption.validate(); err != nil {
+		return err
+	}
+
+	unlock, err := d.lockAll()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var sealed *sealingWriter
+	if opts.Encryption.set() {
+		if sealed, err = newSealingWriter(w, opts.Encryption); err != nil {
+			return d.noSpace("backup", err)
+		}
+		w = sealed
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	manifest := &BackupManifest{Created: now.UTC(), Files: make(map[string]string), Records: make(map[string]int)}
+	err = d.walk("", func(p string) error {
+		b, err := d.backend.Get(p)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: p, Mode: 0644, Size: int64(len(b)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = tw.Write(b); err != nil {
+			return err
+		}
+		manifest.add(d, p, b)
+		return nil
+	})
+	if err == nil {
+		err = writeBackupManifest(tw, manifest)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil && sealed != nil {
+		err = sealed.Close()
+	}
+	return d.noSpace("backup", err)
+}
+
+// Restore unpacks a Backup into b, which should be empty and not in use by
+// an open Driver. An encrypted backup fails with ErrBackupEncrypted; restore
+// it with RestoreWith.
+func Restore(b Backend, r io.Reader) error {
+	_, err := RestoreWith(b, r, RestoreOptions{})
+	return err
+}
+
+// RestoreOptions tunes RestoreWith.
+type RestoreOptions struct {
+	// DryRun writes no file, to check a backup reads and to see what
+	// restoring it over a backend that is not empty would replace.
+	DryRun bool
+	// Encryption opens a backup BackupWith encrypted. A wrong passphrase
+	// or key, or a backup tampered with, fails with ErrInvalidBackupKey,
+	// and a backup that is not encrypted fails with ErrBackupNotEncrypted
+	// when it is set.
+	Encryption BackupEncryption
+}
+
+// RestoreReport describes the files RestoreWith wrote, or would have
+// written on a dry run.
+type RestoreReport struct {
+	DryRun bool `json:"dryRun"`
+	// Written are the files of the backup, in its order, and Replaced
+	// those of them that were already in the backend, whose contents they
+	// replace.
+	Written  []string `json:"written"`
+	Replaced []string `json:"replaced"`
+}
+
+// RestoreWith is Restore with options, reporting the files it wrote.
+func RestoreWith(b Backend, r io.Reader, opts RestoreOptions) (*RestoreReport, error) {
+	r, err := openBackup(r, opts.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	dryRun := opts.DryRun
+	report := &RestoreReport{DryRun: dryRun, Written: []string{}, Replaced: []string{}}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return report, fmt.Errorf("%w: backup entry %q escapes the database directory", ErrInvalidName, hdr.Name)
+		}
+		if name == backupManifestFile {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return report, err
+		}
+		if _, err := b.Get(name); err == nil {
+			report.Replaced = append(report.Replaced, name)
+		} else if !isNotExist(err) {
+			return report, err
+		}
+		if !dryRun {
+			if err := b.Put(name, data); err != nil {
+				return report, err
+			}
+		}
+		report.Written = append(report.Written, name)
+	}
+}
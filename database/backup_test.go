@@ -0,0 +1,52 @@
+package database
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRestoreDryRun(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, key := range []string{"ada", "bob"} {
+		if err := d.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var backup bytes.Buffer
+	if err := d.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewMemoryBackend()
+	if err := b.Put("users/ada.json", []byte(`{"name":"old"}`)); err != nil {
+		t.Fatal(err)
+	}
+	report, err := RestoreWith(b, bytes.NewReader(backup.Bytes()), RestoreOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"users/ada.json", "users/bob.json"}; !report.DryRun || !reflect.DeepEqual(report.Written, want) {
+		t.Errorf("Written = %v, want %v", report.Written, want)
+	}
+	if want := []string{"users/ada.json"}; !reflect.DeepEqual(report.Replaced, want) {
+		t.Errorf("Replaced = %v, want %v", report.Replaced, want)
+	}
+	if got, _ := b.Get("users/ada.json"); string(got) != `{"name":"old"}` {
+		t.Errorf("dry run replaced ada with %s", got)
+	}
+	if _, err := b.Get("users/bob.json"); !isNotExist(err) {
+		t.Errorf("dry run wrote bob: %v", err)
+	}
+
+	if _, err := RestoreWith(b, bytes.NewReader(backup.Bytes()), RestoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get("users/bob.json"); !bytes.Contains(got, []byte("bob")) {
+		t.Errorf("restored bob = %s", got)
+	}
+}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted backups start with a header of this magic, a version, how the
+// archive key was derived, the PBKDF2 iterations and a random salt, then
+// hold the archive in chunks sealed with AES-256-GCM. Each chunk is sealed
+// with its number as nonce and the header, and whether it is the last, as
+// additional data, so chunks cannot be reordered, dropped or cut off
+// unnoticed.
+var encryptedBackupMagic = []byte("\x00odbbak")
+
+const (
+	encryptedBackupVersion = 1
+	backupKeyRaw           = 1
+	backupKeyPassphrase    = 2
+	backupKDFIterations    = 600000
+	// Headers are read before anything is authenticated, so the
+	// iterations they ask for are bounded: too few would make guessing
+	// the passphrase cheap, too many would stall the restore.
+	backupKDFMinIterations = 100000
+	backupKDFMaxIterations = 10 * backupKDFIterations
+	backupSaltSize         = 16
+	backupHeaderSize       = 7 + 1 + 1 + 4 + backupSaltSize
+	backupChunkSize        = 64 << 10
+)
+
+var (
+	// ErrBackupEncrypted is returned when restoring an encrypted backup
+	// without a passphrase or key.
+	ErrBackupEncrypted = errors.New("backup is encrypted - a passphrase or key is needed")
+	// ErrInvalidBackupKey is returned when the passphrase or key given
+	// does not open a backup, or the backup was tampered with.
+	ErrInvalidBackupKey = errors.New("invalid backup key - unable to decrypt backup")
+	// ErrBackupNotEncrypted is returned when a passphrase or key is given
+	// to open a backup that is not encrypted.
+	ErrBackupNotEncrypted = errors.New("backup is not encrypted - refusing it as a passphrase or key was given")
+)
+
+// BackupEncryption encrypts a backup, whether or not the database is
+// encrypted at rest: backups hold every record and are often kept in less
+// trusted places than the database. Set either field.
+type BackupEncryption struct {
+	// Passphrase derives the key with PBKDF2-SHA256 and a salt of each
+	// backup.
+	Passphrase string
+	// Key is 16, 24 or 32 bytes, as read from a key file.
+	Key []byte
+}
+
+func (k BackupEncryption) set() bool {
+	return k.Passphrase != "" || len(k.Key) > 0
+}
+
+func (k BackupEncryption) validate() error {
+	if k.Passphrase != "" && len(k.Key) > 0 {
+		return errors.New("backup passphrase and key are both set - pick one")
+	}
+	if len(k.Key) > 0 {
+		return checkKey(k.Key)
+	}
+	return nil
+}
+
+// aead derives the cipher of an archive with the header hdr.
+func (k BackupEncryption) aead(hdr []byte) (cipher.AEAD, error) {
+	salt := hdr[backupHeaderSize-backupSaltSize:]
+	var key []byte
+	var err error
+	switch hdr[8] {
+	case backupKeyPassphrase:
+		if k.Passphrase == "" {
+			return nil, fmt.Errorf("%w: the backup was encrypted with a passphrase", ErrInvalidBackupKey)
+		}
+		iterations := int(binary.BigEndian.Uint32(hdr[9:13]))
+		if iterations < backupKDFMinIterations || iterations > backupKDFMaxIterations {
+			return nil, fmt.Errorf("%w: %d key derivation iterations", ErrInvalidBackupKey, iterations)
+		}
+		key, err = pbkdf2.Key(sha256.New, k.Passphrase, salt, iterations, 32)
+	case backupKeyRaw:
+		if len(k.Key) == 0 {
+			return nil, fmt.Errorf("%w: the backup was encrypted with a key", ErrInvalidBackupKey)
+		}
+		// Keys are used for many backups; each gets its own.
+		key, err = hkdf.Key(sha256.New, k.Key, salt, "owndb backup", 32)
+	default:
+		return nil, fmt.Errorf("unknown backup key derivation %d", hdr[8])
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newHeader returns the header of a new archive encrypted with k.
+func (k BackupEncryption) newHeader() ([]byte, error) {
+	hdr := make([]byte, backupHeaderSize)
+	copy(hdr, encryptedBackupMagic)
+	hdr[7] = encryptedBackupVersion
+	hdr[8] = backupKeyRaw
+	if k.Passphrase != "" {
+		hdr[8] = backupKeyPassphrase
+		binary.BigEndian.PutUint32(hdr[9:13], backupKDFIterations)
+	}
+	if _, err := io.ReadFull(rand.Reader, hdr[backupHeaderSize-backupSaltSize:]); err != nil {
+		return nil, err
+	}
+	return hdr, nil
+}
+
+func chunkNonce(gcm cipher.AEAD, n uint64) []byte {
+	nonce := make([]byte, gcm.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], n)
+	return nonce
+}
+
+func chunkAAD(hdr []byte, last bool) []byte {
+	aad := append([]byte{}, hdr...)
+	if last {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// sealingWriter encrypts what is written to it in chunks. Close seals the
+// last chunk, without closing the writer underneath.
+type sealingWriter struct {
+	w   io.Writer
+	gcm cipher.AEAD
+	hdr []byte
+	buf []byte
+	n   uint64
+}
+
+func newSealingWriter(w io.Writer, k BackupEncryption) (*sealingWriter, error) {
+	hdr, err := k.newHeader()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := k.aead(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &sealingWriter{w: w, gcm: gcm, hdr: hdr, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+func (s *sealingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == backupChunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):backupChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *sealingWriter) flush(last bool) error {
+	sealed := s.gcm.Seal(nil, chunkNonce(s.gcm, s.n), s.buf, chunkAAD(s.hdr, last))
+	s.n++
+	s.buf = s.buf[:0]
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, err := s.w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+func (s *sealingWriter) Close() error {
+	return s.flush(true)
+}
+
+// openingReader decrypts an archive sealingWriter wrote, failing with
+// ErrInvalidBackupKey as soon as a chunk does not open.
+type openingReader struct {
+	r    io.Reader
+	gcm  cipher.AEAD
+	hdr  []byte
+	buf  []byte
+	n    uint64
+	done bool
+}
+
+func (o *openingReader) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *openingReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(o.r, size[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: backup is cut short", ErrInvalidBackupKey)
+		}
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > backupChunkSize+uint32(o.gcm.Overhead()) {
+		return fmt.Errorf("%w: chunk of %d bytes", ErrInvalidBackupKey, n)
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(o.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: backup is cut short", ErrInvalidBackupKey)
+		}
+		return err
+	}
+	nonce := chunkNonce(o.gcm, o.n)
+	o.n++
+	var err error
+	if o.buf, err = o.gcm.Open(nil, nonce, sealed, chunkAAD(o.hdr, false)); err == nil {
+		return nil
+	}
+	if o.buf, err = o.gcm.Open(nil, nonce, sealed, chunkAAD(o.hdr, true)); err == nil {
+		o.done = true
+		return nil
+	}
+	return ErrInvalidBackupKey
+}
+
+// openBackup returns the archive r holds, decrypting it with k if it is
+// encrypted. An archive that is not is read as is unless k is set: one
+// expected to be encrypted may have been swapped for a forged one.
+func openBackup(r io.Reader, k BackupEncryption) (io.Reader, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(encryptedBackupMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.Equal(magic, encryptedBackupMagic) {
+		if k.set() {
+			return nil, ErrBackupNotEncrypted
+		}
+		return br, nil
+	}
+	if !k.set() {
+		return nil, ErrBackupEncrypted
+	}
+	hdr := make([]byte, backupHeaderSize)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, fmt.Errorf("reading backup header: %w", err)
+	}
+	if hdr[7] != encryptedBackupVersion {
+		return nil, fmt.Errorf("unknown encrypted backup version %d", hdr[7])
+	}
+	gcm, err := k.aead(hdr)
+	if err != nil {
+		return nil, err
+	}
+	return &openingReader{r: br, gcm: gcm, hdr: hdr}, nil
+}
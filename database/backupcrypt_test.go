@@ -0,0 +1,134 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// writeNoise writes records of random-looking names, so their backup does
+// not compress to a single chunk.
+func writeNoise(t *testing.T, d *Driver, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		var name []byte
+		for j := 0; j < 20; j++ {
+			sum := sha256.Sum256([]byte(fmt.Sprint(i, j)))
+			name = append(name, sum[:]...)
+		}
+		if err := d.Write("users", fmt.Sprint(i), map[string]string{"name": fmt.Sprintf("%x", name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	writeNoise(t, d, 300)
+
+	for name, enc := range map[string]BackupEncryption{
+		"passphrase": {Passphrase: "hunter2"},
+		"key":        {Key: bytes.Repeat([]byte{1}, 32)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var backup bytes.Buffer
+			if err := d.BackupWith(&backup, BackupOptions{Encryption: enc}); err != nil {
+				t.Fatal(err)
+			}
+			if backup.Len() <= backupChunkSize {
+				t.Fatalf("backup of %d bytes fits a chunk", backup.Len())
+			}
+			if bytes.Contains(backup.Bytes(), []byte("users")) {
+				t.Error("encrypted backup holds the collection name in plain")
+			}
+
+			if err := Restore(NewMemoryBackend(), bytes.NewReader(backup.Bytes())); !errors.Is(err, ErrBackupEncrypted) {
+				t.Errorf("Restore without a key = %v, want ErrBackupEncrypted", err)
+			}
+			wrong := RestoreOptions{Encryption: BackupEncryption{Passphrase: "nope"}}
+			if _, err := RestoreWith(NewMemoryBackend(), bytes.NewReader(backup.Bytes()), wrong); !errors.Is(err, ErrInvalidBackupKey) {
+				t.Errorf("RestoreWith a wrong passphrase = %v, want ErrInvalidBackupKey", err)
+			}
+
+			b := NewMemoryBackend()
+			report, err := RestoreWith(b, bytes.NewReader(backup.Bytes()), RestoreOptions{Encryption: enc})
+			if err != nil || len(report.Written) != 300 {
+				t.Fatalf("RestoreWith = %d files, %v; want 300", len(report.Written), err)
+			}
+			restored, err := New("", &Options{Backend: b, TTLSweepInterval: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Close()
+			var got, want map[string]string
+			d.Read("users", "299", &want)
+			if err := restored.Read("users", "299", &got); err != nil || got["name"] != want["name"] {
+				t.Errorf("restored record = %v, %v", got, err)
+			}
+
+			cut := backup.Bytes()[:backup.Len()-10]
+			if _, err := RestoreWith(NewMemoryBackend(), bytes.NewReader(cut), RestoreOptions{Encryption: enc}); !errors.Is(err, ErrInvalidBackupKey) {
+				t.Errorf("RestoreWith of a cut backup = %v, want ErrInvalidBackupKey", err)
+			}
+			tampered := bytes.Clone(backup.Bytes())
+			tampered[len(tampered)/2] ^= 1
+			if _, err := RestoreWith(NewMemoryBackend(), bytes.NewReader(tampered), RestoreOptions{Encryption: enc}); !errors.Is(err, ErrInvalidBackupKey) {
+				t.Errorf("RestoreWith of a tampered backup = %v, want ErrInvalidBackupKey", err)
+			}
+		})
+	}
+}
+
+func TestBackupEncryptionErrors(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enc := range []BackupEncryption{
+		{Passphrase: "hunter2", Key: bytes.Repeat([]byte{1}, 32)},
+		{Key: []byte("short")},
+	} {
+		if err := d.BackupWith(&bytes.Buffer{}, BackupOptions{Encryption: enc}); err == nil {
+			t.Errorf("BackupWith(%+v) succeeded", enc)
+		}
+	}
+
+	// A key says the backup is encrypted, so a plain one is refused.
+	var plain bytes.Buffer
+	if err := d.Backup(&plain); err != nil {
+		t.Fatal(err)
+	}
+	opts := RestoreOptions{Encryption: BackupEncryption{Passphrase: "hunter2"}}
+	if _, err := RestoreWith(NewMemoryBackend(), bytes.NewReader(plain.Bytes()), opts); !errors.Is(err, ErrBackupNotEncrypted) {
+		t.Errorf("RestoreWith of a plain backup = %v, want ErrBackupNotEncrypted", err)
+	}
+	if report, err := RestoreWith(NewMemoryBackend(), &plain, RestoreOptions{}); err != nil || len(report.Written) != 1 {
+		t.Errorf("RestoreWith of a plain backup without a key = %+v, %v", report, err)
+	}
+
+	// The iterations a header asks for are bounded.
+	var sealed bytes.Buffer
+	if err := d.BackupWith(&sealed, BackupOptions{Encryption: opts.Encryption}); err != nil {
+		t.Fatal(err)
+	}
+	for _, iterations := range []uint32{1, backupKDFMaxIterations + 1, math.MaxUint32} {
+		forged := bytes.Clone(sealed.Bytes())
+		binary.BigEndian.PutUint32(forged[9:13], iterations)
+		if _, err := RestoreWith(NewMemoryBackend(), bytes.NewReader(forged), opts); !errors.Is(err, ErrInvalidBackupKey) {
+			t.Errorf("RestoreWith of %d iterations = %v, want ErrInvalidBackupKey", iterations, err)
+		}
+	}
+}
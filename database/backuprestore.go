@@ -0,0 +1,245 @@
+package database
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RestoreRecordsOptions picks the records RestoreRecords restores.
+type RestoreRecordsOptions struct {
+	// Collections are the collections restored, every one of the backup
+	// if empty. Sub-collections are collections of their own.
+	Collections []string
+	// Keys, if set, restores only the records whose key matches one of
+	// these path.Match globs, such as "2024-*".
+	Keys []string
+	// Filter, if set, restores only the records it matches, as Query
+	// filters them.
+	Filter string
+	// Rename maps a collection of the backup to the collection its records
+	// are restored into, to bring them back beside the records that
+	// replaced them.
+	Rename map[string]string
+	// Overwrite replaces records that exist. Without it they are kept and
+	// reported as skipped.
+	Overwrite bool
+	// DryRun writes nothing, reporting what would be restored.
+	DryRun     bool
+	Encryption BackupEncryption
+	// Options opens the database the backup holds, as for VerifyBackup.
+	// The codecs, field key and master key of d are used if it is nil.
+	Options *Options
+}
+
+// RestoreRecordsReport lists the records RestoreRecords restored, or would
+// have on a dry run, as collection/key paths of the collections they were
+// restored into.
+type RestoreRecordsReport struct {
+	DryRun   bool     `json:"dryRun"`
+	Restored []string `json:"restored"`
+	Skipped  []string `json:"skipped"`
+}
+
+// RestoreRecords restores the records of a Backup that opts picks into the
+// open database, writing each as Write would, so hooks, indexes and
+// changefeeds see them and every other record is left as it is. A record
+// keeps the expiry it had in the backup, and records that expired are not
+// restored. The collections picked are unpacked into memory first.
+func (d *Driver) RestoreRecords(ctx context.Context, archive io.Reader, opts RestoreRecordsOptions) (report *RestoreRecordsReport, err error) {
+	op := d.startOp(ctx, "restore", "", "")
+	defer op.end(&err)
+
+	check := d.checkWritable
+	if opts.DryRun {
+		check = d.checkOpen
+	}
+	if err := check(); err != nil {
+		return nil, err
+	}
+	for _, collection := range opts.Collections {
+		if err := validCollection(collection); err != nil {
+			return nil, err
+		}
+	}
+	for from, to := range opts.Rename {
+		if err := validCollection(from); err != nil {
+			return nil, err
+		}
+		if err := validCollection(to); err != nil {
+			return nil, err
+		}
+	}
+	for _, glob := range opts.Keys {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid key glob %q: %w", glob, err)
+		}
+	}
+	match := func(map[string]interface{}) bool { return true }
+	if opts.Filter != "" {
+		e, err := Compile(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		match = e.Match
+	}
+
+	backup, err := d.openBackupDriver(archive, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer backup.Close()
+
+	collections := opts.Collections
+	if len(collections) == 0 {
+		if collections, err = backup.collectionPaths(); err != nil {
+			return nil, err
+		}
+	}
+	report = &RestoreRecordsReport{DryRun: opts.DryRun, Restored: []string{}, Skipped: []string{}}
+	for _, collection := range collections {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if _, err := backup.backend.List(collection); isNotExist(err) {
+			return report, fmt.Errorf("unable to find collection %s in the backup: %w", collection, fs.ErrNotExist)
+		}
+		keys, err := backup.liveKeys(collection)
+		if err != nil {
+			return report, err
+		}
+		sort.Strings(keys)
+		into := collection
+		if to, ok := opts.Rename[collection]; ok {
+			into = to
+		}
+		for _, key := range keys {
+			if !matchKey(opts.Keys, key) {
+				continue
+			}
+			b, err := backup.readRecord(collection, key)
+			if isNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+			doc, err := backup.decodeDocument(collection, b)
+			if err != nil {
+				return report, fmt.Errorf("decoding %s/%s: %w", collection, key, err)
+			}
+			if !match(doc) {
+				continue
+			}
+			name := into + "/" + key
+			if !opts.Overwrite {
+				if _, err := d.readRecord(into, key); err == nil {
+					report.Skipped = append(report.Skipped, name)
+					continue
+				} else if !isNotExist(err) {
+					return report, err
+				}
+			}
+			if !opts.DryRun {
+				expires, err := backup.expiresAt(backup.recordPath(collection, key))
+				if err != nil {
+					return report, err
+				}
+				if err := d.write(ctx, into, key, doc, expires); err != nil {
+					return report, err
+				}
+			}
+			op.addBytes(int64(len(b)))
+			report.Restored = append(report.Restored, name)
+		}
+	}
+	return report, nil
+}
+
+func matchKey(globs []string, key string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// openBackupDriver unpacks the files at the root of a backup and those of
+// the collections opts picks into memory and opens them read-only.
+func (d *Driver) openBackupDriver(archive io.Reader, opts RestoreRecordsOptions) (*Driver, error) {
+	r, err := openBackup(archive, opts.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	defer gz.Close()
+
+	keep := func(name string) bool {
+		if len(opts.Collections) == 0 || !strings.Contains(name, "/") {
+			return true
+		}
+		for _, collection := range opts.Collections {
+			if path.Dir(name) == collection || strings.HasPrefix(path.Dir(name), collection+"/.") {
+				return true
+			}
+		}
+		return false
+	}
+	backend := NewMemoryBackend()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading backup: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || name == backupManifestFile || !keep(name) {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("%w: backup entry %q escapes the database directory", ErrInvalidName, hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading backup: %w", err)
+		}
+		if err := backend.Put(name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var o Options
+	if opts.Options != nil {
+		o = *opts.Options
+	} else {
+		o = Options{Codec: d.codec, Extension: d.ext, FieldKey: d.fieldKey, Collections: d.configured, Logger: d.log}
+		if d.keys != nil {
+			o.MasterKey = d.keys.master
+		}
+	}
+	o.Backend = backend
+	o.ReadOnly = true
+	o.TTLSweepInterval = -1
+	backup, err := New("backup", &o)
+	if err != nil {
+		return nil, fmt.Errorf("opening backup: %w", err)
+	}
+	return backup, nil
+}
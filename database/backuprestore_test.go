@@ -0,0 +1,120 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestoreRecords(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for key, age := range map[string]int{"a1": 30, "a2": 10, "b1": 50} {
+		if err := d.Write("users", key, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.WriteTTL("users", "t1", map[string]int{"age": 40}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteTTL("users", "t2", map[string]int{"age": 40}, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("orders", "o1", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	var backup bytes.Buffer
+	if err := d.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DropCollection(context.Background(), "users", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("orders", "o1", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+	restore := func(opts RestoreRecordsOptions) (*RestoreRecordsReport, error) {
+		return d.RestoreRecords(context.Background(), bytes.NewReader(backup.Bytes()), opts)
+	}
+
+	picked := RestoreRecordsOptions{Collections: []string{"users"}, Keys: []string{"a*", "t*"}, Filter: "doc.age > 20", DryRun: true}
+	report, err := restore(picked)
+	if err != nil || !report.DryRun || !reflect.DeepEqual(report.Restored, []string{"users/a1", "users/t1"}) {
+		t.Errorf("RestoreRecords dry run = %+v, %v; want a1 and t1", report, err)
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 0 {
+		t.Errorf("dry run restored %v", keys)
+	}
+
+	picked.DryRun = false
+	picked.Rename = map[string]string{"users": "users_old"}
+	report, err = restore(picked)
+	if err != nil || !reflect.DeepEqual(report.Restored, []string{"users_old/a1", "users_old/t1"}) {
+		t.Fatalf("RestoreRecords into users_old = %+v, %v; want a1 and t1", report, err)
+	}
+	if keys, err := d.Keys("users_old"); err != nil || !reflect.DeepEqual(keys, []string{"a1", "t1"}) {
+		t.Errorf("Keys of users_old = %v, %v; want a1 and t1", keys, err)
+	}
+	if expires, err := d.ExpiresAt("users_old", "t1"); err != nil || expires.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("ExpiresAt of a restored record = %v, %v; want its expiry in the backup", expires, err)
+	}
+
+	report, err = restore(RestoreRecordsOptions{})
+	want := []string{"users/a1", "users/a2", "users/b1", "users/t1"}
+	if err != nil || !reflect.DeepEqual(report.Restored, want) || !reflect.DeepEqual(report.Skipped, []string{"orders/o1"}) {
+		t.Errorf("RestoreRecords of everything = %+v, %v; want %v restored, t2 expired and o1 skipped", report, err, want)
+	}
+	var order map[string]int
+	if err := d.Read("orders", "o1", &order); err != nil || order["n"] != 2 {
+		t.Errorf("record kept = %v, %v; want the one written since", order, err)
+	}
+	if report, err := restore(RestoreRecordsOptions{Collections: []string{"orders"}, Overwrite: true}); err != nil || len(report.Restored) != 1 {
+		t.Errorf("RestoreRecords overwriting = %+v, %v", report, err)
+	}
+	if err := d.Read("orders", "o1", &order); err != nil || order["n"] != 1 {
+		t.Errorf("record overwritten = %v, %v; want the one of the backup", order, err)
+	}
+
+	if _, err := restore(RestoreRecordsOptions{Collections: []string{"none"}}); !IsNotFound(err) {
+		t.Errorf("RestoreRecords of a collection not in the backup = %v, want IsNotFound", err)
+	}
+	for name, opts := range map[string]RestoreRecordsOptions{
+		"a bad collection": {Collections: []string{"../users"}},
+		"a bad rename":     {Rename: map[string]string{"users": ""}},
+		"a bad glob":       {Keys: []string{"["}},
+		"a bad filter":     {Filter: "doc.age >"},
+	} {
+		if _, err := restore(opts); err == nil {
+			t.Errorf("RestoreRecords of %s succeeded", name)
+		}
+	}
+}
+
+func TestRestoreRecordsEncrypted(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	enc := BackupEncryption{Key: bytes.Repeat([]byte{7}, 32)}
+	var backup bytes.Buffer
+	if err := d.BackupWith(&backup, BackupOptions{Encryption: enc}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.RestoreRecords(context.Background(), bytes.NewReader(backup.Bytes()), RestoreRecordsOptions{}); err == nil {
+		t.Error("RestoreRecords of an encrypted backup without the key succeeded")
+	}
+	opts := RestoreRecordsOptions{Rename: map[string]string{"users": "people"}, Encryption: enc}
+	if report, err := d.RestoreRecords(context.Background(), bytes.NewReader(backup.Bytes()), opts); err != nil || !reflect.DeepEqual(report.Restored, []string{"people/ada"}) {
+		t.Errorf("RestoreRecords with the key = %+v, %v", report, err)
+	}
+}
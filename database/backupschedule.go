@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000Z"
+
+// BackupPolicy is where BackupTaskWith writes backups and which of them it
+// keeps. A backup is kept if any rule keeps it, and every backup is kept
+// if no rule is set.
+type BackupPolicy struct {
+	Dir string
+	// Target, if set, is where the backups are written in place of Dir,
+	// such as an S3 bucket or an SFTP server.
+	Target BackupTarget
+	// KeepLast keeps the newest backups.
+	KeepLast int
+	// KeepDaily, KeepWeekly and KeepMonthly keep the newest backup of each
+	// of the last days, ISO weeks and months, in UTC, there were backups
+	// in: 7 daily and 4 weekly keep a week of dailies and a month of
+	// weeklies.
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	// Encryption, if set, encrypts the backups, as BackupWith does.
+	Encryption BackupEncryption
+	// OnBackup, if set, is called after every backup the task takes, or
+	// fails to, for alerting on. It runs on the maintenance goroutine and
+	// should be quick.
+	OnBackup func(BackupResult)
+}
+
+// BackupResult is what a scheduled backup did.
+type BackupResult struct {
+	// File is the backup written, a path of Dir or a name of Target, empty
+	// if it failed.
+	File     string
+	Size     int64
+	Started  time.Time
+	Duration time.Duration
+	// Removed are the older backups rotated out.
+	Removed []string
+	Err     error
+}
+
+func (p BackupPolicy) rotates() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// BackupTaskWith returns a task writing a Backup to a timestamped file in
+// policy.Dir, or policy.Target, then deleting the older backups no rule of
+// the policy keeps. Writing to Dir, it fails with a DiskFullError without
+// starting when the directory has less room than the database takes.
+func BackupTaskWith(policy BackupPolicy) func(ctx context.Context, d *Driver) error {
+	return func(ctx context.Context, d *Driver) error {
+		result := BackupResult{Started: time.Now()}
+		err := d.scheduledBackup(ctx, policy, &result)
+		result.Duration = time.Since(result.Started)
+		result.Err = err
+		if err == nil {
+			d.logEvent(slog.LevelInfo, "Backup written", slog.String("file", result.File),
+				slog.Int64("bytes", result.Size), slog.Int("removed", len(result.Removed)))
+		}
+		if policy.OnBackup != nil {
+			policy.OnBackup(result)
+		}
+		return err
+	}
+}
+
+func (d *Driver) scheduledBackup(ctx context.Context, policy BackupPolicy, result *BackupResult) error {
+	if err := policy.Encryption.validate(); err != nil {
+		return err
+	}
+	target, dir := policy.Target, ""
+	if target == nil {
+		if err := os.MkdirAll(policy.Dir, 0755); err != nil {
+			return err
+		}
+		if err := d.checkBackupSpace(policy.Dir); err != nil {
+			return err
+		}
+		target, dir = DirTarget(policy.Dir), policy.Dir
+	}
+	// file names a backup of the target as results report it.
+	file := func(name string) string {
+		if dir == "" {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	name := "backup-" + result.Started.UTC().Format(backupTimeFormat) + ".tar.gz"
+	if policy.Encryption.set() {
+		name += ".enc"
+	}
+	size, err := d.backupTo(ctx, target, name, BackupOptions{Encryption: policy.Encryption})
+	if err != nil {
+		return err
+	}
+	result.File, result.Size = file(name), size
+	if !policy.rotates() {
+		return nil
+	}
+
+	backups, err := listBackups(ctx, target)
+	if err != nil {
+		return err
+	}
+	for _, b := range policy.expired(backups) {
+		if err := target.Remove(ctx, b.name); err != nil {
+			return err
+		}
+		result.Removed = append(result.Removed, file(b.name))
+	}
+	return nil
+}
+
+type backupFile struct {
+	name  string
+	taken time.Time
+}
+
+// listBackups returns the backups of target, newest first.
+func listBackups(ctx context.Context, target BackupTarget) ([]backupFile, error) {
+	names, err := target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backupFile
+	for _, name := range names {
+		stamp, ok := strings.CutPrefix(name, "backup-")
+		if !ok {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".enc"), ".tar.gz")
+		taken, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			// Not one of ours.
+			continue
+		}
+		backups = append(backups, backupFile{name: name, taken: taken})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].taken.After(backups[j].taken) })
+	return backups, nil
+}
+
+// expired returns the backups, newest first, that no rule of p keeps.
+func (p BackupPolicy) expired(backups []backupFile) []backupFile {
+	keep := make([]bool, len(backups))
+	for i := 0; i < p.KeepLast && i < len(backups); i++ {
+		keep[i] = true
+	}
+	// Keeps the newest backup of each of the last n periods.
+	byPeriod := func(n int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for i, b := range backups {
+			if len(seen) == n {
+				return
+			}
+			if key := period(b.taken); !seen[key] {
+				seen[key] = true
+				keep[i] = true
+			}
+		}
+	}
+	byPeriod(p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	byPeriod(p.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	byPeriod(p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+
+	var expired []backupFile
+	for i, b := range backups {
+		if !keep[i] {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackupPolicyExpired(t *testing.T) {
+	// Two backups a day, the newest first, for 60 days from Sunday the
+	// 29th of April 2029 back.
+	base := time.Date(2029, 4, 29, 12, 0, 0, 0, time.UTC)
+	var backups []backupFile
+	for i := 0; i < 120; i++ {
+		taken := base.Add(-time.Duration(i) * 12 * time.Hour)
+		backups = append(backups, backupFile{name: "backup-" + taken.Format(backupTimeFormat) + ".tar.gz", taken: taken})
+	}
+	kept := func(p BackupPolicy) []time.Time {
+		expired := make(map[string]bool)
+		for _, b := range p.expired(backups) {
+			expired[b.name] = true
+		}
+		var kept []time.Time
+		for _, b := range backups {
+			if !expired[b.name] {
+				kept = append(kept, b.taken)
+			}
+		}
+		return kept
+	}
+	day := func(n int) time.Time { return base.AddDate(0, 0, -n) }
+
+	for _, c := range []struct {
+		name   string
+		policy BackupPolicy
+		want   []time.Time
+	}{
+		{"last", BackupPolicy{KeepLast: 3}, []time.Time{day(0), day(0).Add(-12 * time.Hour), day(1)}},
+		{"daily", BackupPolicy{KeepDaily: 3}, []time.Time{day(0), day(1), day(2)}},
+		// Weeks start on Monday, so the 29th is the last of its week.
+		{"weekly", BackupPolicy{KeepWeekly: 3}, []time.Time{day(0), day(7), day(14)}},
+		{"monthly", BackupPolicy{KeepMonthly: 2}, []time.Time{day(0), day(29)}},
+		{"daily and weekly", BackupPolicy{KeepDaily: 2, KeepWeekly: 2}, []time.Time{day(0), day(1), day(7)}},
+	} {
+		if got := kept(c.policy); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("backups %s keeps = %v, want %v", c.name, got, c.want)
+		}
+	}
+	if expired := (BackupPolicy{}).expired(backups); len(expired) != len(backups) {
+		t.Errorf("expired without rules = %d backups; want all %d, the task keeping them without asking", len(expired), len(backups))
+	}
+}
+
+func TestBackupTaskWith(t *testing.T) {
+	dir := t.TempDir()
+	// Backups of one a day for the last 30 days, and a file of someone
+	// else's.
+	now := time.Now().UTC()
+	for i := 1; i <= 30; i++ {
+		name := "backup-" + now.AddDate(0, 0, -i).Format(backupTimeFormat) + ".tar.gz"
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "backup-notes.tar.gz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := BackupEncryption{Key: bytes.Repeat([]byte{7}, 32)}
+	var results []BackupResult
+	task := BackupTaskWith(BackupPolicy{Dir: dir, KeepDaily: 3, Encryption: enc, OnBackup: func(r BackupResult) { results = append(results, r) }})
+	if err := task(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("OnBackup called %d times, want once", len(results))
+	}
+	r := results[0]
+	if r.Err != nil || filepath.Dir(r.File) != dir || filepath.Ext(r.File) != ".enc" || r.Size == 0 || r.Started.IsZero() || len(r.Removed) != 28 {
+		t.Errorf("BackupResult = %+v; want an encrypted backup of dir and 28 others removed", r)
+	}
+	if fi, err := os.Stat(r.File); err != nil || fi.Size() != r.Size {
+		t.Errorf("backup written = %v, %v; want %d bytes", fi, err, r.Size)
+	}
+	backups, err := listBackups(context.Background(), DirTarget(dir))
+	if err != nil || len(backups) != 3 || filepath.Join(dir, backups[0].name) != r.File {
+		t.Errorf("backups left = %v, %v; want the new one and 2 days' more", backups, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backup-notes.tar.gz")); err != nil {
+		t.Errorf("rotation removed a file not a backup: %v", err)
+	}
+
+	f, err := os.Open(r.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if report, err := VerifyBackup(f, VerifyBackupOptions{Encryption: enc}); err != nil || len(report.Issues) != 0 || report.Records != 1 {
+		t.Errorf("VerifyBackup of the scheduled backup = %+v, %v; want 1 record and no issues", report, err)
+	}
+}
+
+func TestBackupTaskWithFailure(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	dir := t.TempDir()
+	var got BackupResult
+	policy := BackupPolicy{
+		Dir:        dir,
+		Encryption: BackupEncryption{Passphrase: "hunter2", Key: bytes.Repeat([]byte{7}, 32)},
+		OnBackup:   func(r BackupResult) { got = r },
+	}
+	err = BackupTaskWith(policy)(context.Background(), d)
+	if err == nil || !errors.Is(got.Err, err) || got.File != "" || got.Started.IsZero() {
+		t.Errorf("BackupTaskWith of a bad policy = %v, result %+v; want the error passed to OnBackup", err, got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed backup left %d files", len(entries))
+	}
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupTarget is where BackupTo writes backups, such as a directory, an
+// S3 bucket or an SFTP server, so that they leave the host as they are
+// taken rather than by a script copying them afterwards.
+type BackupTarget interface {
+	// Create starts writing the backup name, which appears under that
+	// name only once the upload is closed without error.
+	Create(ctx context.Context, name string) (BackupUpload, error)
+	// List returns the names of the backups in the target, in any order.
+	List(ctx context.Context) ([]string, error)
+	Remove(ctx context.Context, name string) error
+}
+
+// BackupUpload is a backup being written to a BackupTarget.
+type BackupUpload interface {
+	io.Writer
+	// Close completes the backup.
+	Close() error
+	// Abort discards what was written.
+	Abort() error
+}
+
+// BackupTo streams a backup, as BackupWith writes it, to target under
+// name, leaving nothing under that name if it fails.
+func (d *Driver) BackupTo(ctx context.Context, target BackupTarget, name string, opts BackupOptions) error {
+	_, err := d.backupTo(ctx, target, name, opts)
+	return err
+}
+
+// backupTo is BackupTo returning the size of the backup.
+func (d *Driver) backupTo(ctx context.Context, target BackupTarget, name string, opts BackupOptions) (int64, error) {
+	if err := validBackupName(name); err != nil {
+		return 0, err
+	}
+	upload, err := target.Create(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	w := &countingWriter{w: upload}
+	if err := d.BackupWith(w, opts); err != nil {
+		upload.Abort()
+		return 0, err
+	}
+	if err := upload.Close(); err != nil {
+		upload.Abort()
+		return 0, d.noSpace(name, err)
+	}
+	return w.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func validBackupName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%w: backup name %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// DirTarget writes backups as files of dir, which it creates if needed.
+func DirTarget(dir string) BackupTarget {
+	return dirTarget(dir)
+}
+
+type dirTarget string
+
+func (t dirTarget) Create(ctx context.Context, name string) (BackupUpload, error) {
+	if err := os.MkdirAll(string(t), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(string(t), ".backup-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &dirUpload{File: f, name: filepath.Join(string(t), name)}, nil
+}
+
+func (t dirTarget) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(string(t))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (t dirTarget) Remove(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(string(t), name))
+}
+
+// dirUpload is written to a temp file renamed into place once complete.
+type dirUpload struct {
+	*os.File
+	name string
+}
+
+func (u *dirUpload) Close() error {
+	if err := u.File.Close(); err != nil {
+		return err
+	}
+	return os.Rename(u.File.Name(), u.name)
+}
+
+func (u *dirUpload) Abort() error {
+	u.File.Close()
+	return os.Remove(u.File.Name())
+}
+
+// retryUpload runs fn up to three times, backing off between attempts, as
+// the remote targets resume an upload whose connection failed.
+func retryUpload(ctx context.Context, fn func() error) error {
+	wait := 500 * time.Millisecond
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// S3 takes parts of at least 5 MiB but the last.
+const s3PartSize = 8 << 20
+
+type s3Target struct {
+	s *s3Backend
+}
+
+// NewS3BackupTarget writes backups as objects below cfg.Prefix of a bucket,
+// uploading each in parts as it is taken. A part that fails is retried,
+// resuming the upload where it stopped rather than taking the backup
+// again, and a backup that fails has its parts removed.
+func NewS3BackupTarget(cfg S3Config) (BackupTarget, error) {
+	b, err := NewS3Backend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Target{s: b.(*s3Backend)}, nil
+}
+
+type s3InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteUpload struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+func (t *s3Target) Create(ctx context.Context, name string) (BackupUpload, error) {
+	key := t.s.key(name)
+	resp, err := t.s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3ResponseError(http.MethodPost, key, resp)
+	}
+	var result s3InitiateResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.UploadID == "" {
+		return nil, fmt.Errorf("s3 POST %s: no upload ID", key)
+	}
+	return &s3Upload{ctx: ctx, s: t.s, key: key, id: result.UploadID, buf: make([]byte, 0, s3PartSize)}, nil
+}
+
+func (t *s3Target) List(ctx context.Context) ([]string, error) {
+	prefix := t.s.dirPrefix("")
+	keys, _, err := t.s.list(prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if name := strings.TrimPrefix(k, prefix); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (t *s3Target) Remove(ctx context.Context, name string) error {
+	return t.s.deleteKey(t.s.key(name))
+}
+
+type s3Upload struct {
+	ctx   context.Context
+	s     *s3Backend
+	key   string
+	id    string
+	buf   []byte
+	parts []s3CompletePart
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(u.buf) == s3PartSize {
+			if err := u.flush(); err != nil {
+				return written, err
+			}
+		}
+		n := copy(u.buf[len(u.buf):s3PartSize], p)
+		u.buf = u.buf[:len(u.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// flush uploads the buffered part.
+func (u *s3Upload) flush() error {
+	number := len(u.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.id}}
+	var etag string
+	err := retryUpload(u.ctx, func() error {
+		resp, err := u.s.do(http.MethodPut, u.key, query, nil, u.buf)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return s3ResponseError(http.MethodPut, u.key, resp)
+		}
+		etag = resp.Header.Get("ETag")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	u.parts = append(u.parts, s3CompletePart{PartNumber: number, ETag: etag})
+	u.buf = u.buf[:0]
+	return nil
+}
+
+func (u *s3Upload) Close() error {
+	if len(u.buf) > 0 || len(u.parts) == 0 {
+		if err := u.flush(); err != nil {
+			return err
+		}
+	}
+	body, err := xml.Marshal(s3CompleteUpload{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	return retryUpload(u.ctx, func() error {
+		resp, err := u.s.do(http.MethodPost, u.key, url.Values{"uploadId": {u.id}}, nil, body)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return s3ResponseError(http.MethodPost, u.key, resp)
+		}
+		// Completing can fail after a 200 with an error document in the
+		// body, as CopyObject can.
+		b, _ := io.ReadAll(resp.Body)
+		var e s3Error
+		if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+			return fmt.Errorf("s3 complete %s: %s (%s)", u.key, e.Message, e.Code)
+		}
+		return nil
+	})
+}
+
+func (u *s3Upload) Abort() error {
+	resp, err := u.s.do(http.MethodDelete, u.key, url.Values{"uploadId": {u.id}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3ResponseError(http.MethodDelete, u.key, resp)
+	}
+	return nil
+}
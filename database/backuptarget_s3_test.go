@@ -0,0 +1,103 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestS3BackupTarget(t *testing.T) {
+	f, cfg := newFakeS3(t)
+	target, err := NewS3BackupTarget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Two parts, the second failing once.
+	f.failPart = 2
+	data := bytes.Repeat([]byte("0123456789"), s3PartSize/10+100)
+	upload, err := target.Create(ctx, "big.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b := data; len(b) > 0; b = b[min(len(b), 1<<20):] {
+		if _, err := upload.Write(b[:min(len(b), 1<<20)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := upload.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.objects["db/big.tar.gz"], data) || f.failPart != 0 || len(f.uploads) != 0 {
+		t.Errorf("uploaded %d bytes of %d, failed part %d, uploads left %d; want the upload resumed", len(f.objects["db/big.tar.gz"]), len(data), f.failPart, len(f.uploads))
+	}
+
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.BackupTo(ctx, target, "one.tar.gz", BackupOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := VerifyBackup(bytes.NewReader(f.objects["db/one.tar.gz"]), VerifyBackupOptions{}); err != nil || len(report.Issues) != 0 || report.Records != 1 {
+		t.Errorf("VerifyBackup of the uploaded backup = %+v, %v", report, err)
+	}
+	f.objects["db/sub/other.tar.gz"] = nil
+	names, err := target.List(ctx)
+	sort.Strings(names)
+	if err != nil || !reflect.DeepEqual(names, []string{"big.tar.gz", "one.tar.gz"}) {
+		t.Errorf("List = %q, %v; want the two backups", names, err)
+	}
+
+	bad := BackupOptions{Encryption: BackupEncryption{Key: []byte{1}}}
+	if err := d.BackupTo(ctx, target, "two.tar.gz", bad); err == nil || len(f.uploads) != 0 {
+		t.Errorf("BackupTo with a bad key = %v, uploads left %d; want the upload aborted", err, len(f.uploads))
+	}
+	if _, ok := f.objects["db/two.tar.gz"]; ok {
+		t.Error("failed backup left an object")
+	}
+	if err := d.BackupTo(ctx, target, "../two.tar.gz", BackupOptions{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("BackupTo of a path = %v, want ErrInvalidName", err)
+	}
+	if err := target.Remove(ctx, "big.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.objects["db/big.tar.gz"]; ok {
+		t.Error("Remove left the object")
+	}
+}
+
+func TestS3BackupTargetSchedule(t *testing.T) {
+	f, cfg := newFakeS3(t)
+	target, err := NewS3BackupTarget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	old := "backup-20200101T000000.000Z.tar.gz"
+	f.objects["db/"+old] = nil
+
+	var r BackupResult
+	task := BackupTaskWith(BackupPolicy{Target: target, KeepLast: 1, OnBackup: func(res BackupResult) { r = res }})
+	if err := task(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.objects["db/"+r.File]; !ok || r.Size != int64(len(f.objects["db/"+r.File])) {
+		t.Errorf("BackupResult = %+v; want the name of the object uploaded", r)
+	}
+	if !reflect.DeepEqual(r.Removed, []string{old}) {
+		t.Errorf("Removed = %q, want %q", r.Removed, old)
+	}
+}
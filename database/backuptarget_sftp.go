@@ -0,0 +1,583 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SFTPConfig is where an SFTP backup target writes.
+type SFTPConfig struct {
+	// Host is the server, as user@host or a Host of the ssh config.
+	Host string
+	Port int
+	// Dir is the directory of the backups, relative to the login
+	// directory unless absolute. It is created if needed.
+	Dir string
+	// IdentityFile is the private key to log in with, if not the one ssh
+	// picks.
+	IdentityFile string
+	// Command, if set, is run in place of ssh and speaks SFTP on its
+	// standard input and output, as `ssh -s host sftp` does.
+	Command []string
+}
+
+// The SFTP version 3 packets the target sends and reads.
+const (
+	sftpInit      = 1
+	sftpVersion   = 2
+	sftpOpen      = 3
+	sftpClose     = 4
+	sftpWrite     = 6
+	sftpOpendir   = 11
+	sftpReaddir   = 12
+	sftpRemove    = 13
+	sftpMkdir     = 14
+	sftpRename    = 18
+	sftpStatus    = 101
+	sftpHandle    = 102
+	sftpName      = 104
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+	sftpAttrSize  = 0x01
+	sftpAttrOwner = 0x02
+	sftpAttrPerm  = 0x04
+	sftpAttrTime  = 0x08
+	sftpAttrExt   = 0x80000000
+	sftpOK        = 0
+	sftpEOF       = 1
+	sftpNoFile    = 2
+)
+
+const (
+	// sftpChunkSize is the data of one write, which servers take at least
+	// 32 KiB of.
+	sftpChunkSize = 32 << 10
+	// sftpWindow is the writes sent before waiting for the first of them.
+	sftpWindow = 64
+)
+
+type sftpTarget struct {
+	cfg SFTPConfig
+}
+
+// NewSFTPBackupTarget writes backups as files of cfg.Dir on an SFTP server,
+// logging in with the system ssh, so keys, known hosts and the ssh config
+// work as they do for it. A backup is written under a temporary name and
+// renamed once complete. A connection lost while it is written is opened
+// again and the writes not yet acknowledged sent again, resuming the upload
+// rather than taking the backup again.
+func NewSFTPBackupTarget(cfg SFTPConfig) (BackupTarget, error) {
+	if cfg.Host == "" && len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("missing sftp host - no place to store backups")
+	}
+	cfg.Dir = strings.TrimSuffix(cfg.Dir, "/")
+	return &sftpTarget{cfg: cfg}, nil
+}
+
+func (t *sftpTarget) path(name string) string {
+	if t.cfg.Dir == "" {
+		return name
+	}
+	return t.cfg.Dir + "/" + name
+}
+
+func (t *sftpTarget) dial(ctx context.Context) (*sftpConn, error) {
+	args := t.cfg.Command
+	if len(args) == 0 {
+		args = []string{"ssh", "-o", "BatchMode=yes"}
+		if t.cfg.Port != 0 {
+			args = append(args, "-p", strconv.Itoa(t.cfg.Port))
+		}
+		if t.cfg.IdentityFile != "" {
+			args = append(args, "-i", t.cfg.IdentityFile)
+		}
+		args = append(args, "-s", t.cfg.Host, "sftp")
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &sftpConn{cmd: cmd, w: stdin, r: bufio.NewReader(stdout)}
+	cmd.Stderr = &c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	if err := c.init(); err != nil {
+		c.close()
+		return nil, c.failed(err)
+	}
+	return c, nil
+}
+
+func (t *sftpTarget) Create(ctx context.Context, name string) (BackupUpload, error) {
+	c, err := t.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mkdirAll(t.cfg.Dir)
+	u := &sftpUpload{ctx: ctx, t: t, conn: c, part: t.path("." + name + ".part"), name: t.path(name)}
+	if u.handle, err = c.open(u.part, sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc); err != nil {
+		c.close()
+		return nil, err
+	}
+	return u, nil
+}
+
+func (t *sftpTarget) List(ctx context.Context) ([]string, error) {
+	c, err := t.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	dir := t.cfg.Dir
+	if dir == "" {
+		dir = "."
+	}
+	names, err := c.readDir(dir)
+	if errors.Is(err, errSFTPNoFile) {
+		return nil, nil
+	}
+	return names, err
+}
+
+func (t *sftpTarget) Remove(ctx context.Context, name string) error {
+	c, err := t.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	if err := c.remove(t.path(name)); errors.Is(err, errSFTPNoFile) {
+		return notExist("remove", name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+type sftpChunk struct {
+	id   uint32
+	off  uint64
+	data []byte
+}
+
+type sftpUpload struct {
+	ctx    context.Context
+	t      *sftpTarget
+	conn   *sftpConn
+	handle string
+	part   string
+	name   string
+	off    uint64
+	// pending are the writes sent and not yet acknowledged.
+	pending []*sftpChunk
+}
+
+func (u *sftpUpload) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), sftpChunkSize)
+		c := &sftpChunk{off: u.off, data: bytes.Clone(p[:n])}
+		u.off += uint64(n)
+		u.pending = append(u.pending, c)
+		if err := u.do(func() error { return u.conn.write(u.handle, c) }); err != nil {
+			return written, err
+		}
+		for len(u.pending) >= sftpWindow {
+			if err := u.do(u.ack); err != nil {
+				return written, err
+			}
+		}
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// do runs fn, resuming the upload if the connection fails.
+func (u *sftpUpload) do(fn func() error) error {
+	err := fn()
+	var status *sftpStatusError
+	if err == nil || errors.As(err, &status) {
+		return err
+	}
+	return retryUpload(u.ctx, u.resume)
+}
+
+// resume opens the connection and the file again and sends the pending
+// writes again.
+func (u *sftpUpload) resume() error {
+	u.conn.close()
+	c, err := u.t.dial(u.ctx)
+	if err != nil {
+		return err
+	}
+	u.conn = c
+	if u.handle, err = c.open(u.part, sftpFlagWrite|sftpFlagCreat); err != nil {
+		return err
+	}
+	for _, chunk := range u.pending {
+		if err := c.write(u.handle, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ack waits for a pending write to be acknowledged.
+func (u *sftpUpload) ack() error {
+	id, err := u.conn.status()
+	if err != nil {
+		return err
+	}
+	for i, c := range u.pending {
+		if c.id == id {
+			u.pending = append(u.pending[:i], u.pending[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("sftp: reply to unknown request %d", id)
+}
+
+func (u *sftpUpload) Close() error {
+	for len(u.pending) > 0 {
+		if err := u.do(u.ack); err != nil {
+			return err
+		}
+	}
+	if err := u.conn.closeHandle(u.handle); err != nil {
+		return err
+	}
+	// Version 3 renames fail onto a file that exists.
+	if err := u.conn.remove(u.name); err != nil && !errors.Is(err, errSFTPNoFile) {
+		return err
+	}
+	if err := u.conn.rename(u.part, u.name); err != nil {
+		return err
+	}
+	return u.conn.close()
+}
+
+func (u *sftpUpload) Abort() error {
+	defer u.conn.close()
+	u.conn.closeHandle(u.handle)
+	return u.conn.remove(u.part)
+}
+
+var errSFTPNoFile = errors.New("no such file")
+
+// sftpStatusError is a request the server refused.
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (status %d)", e.msg, e.code)
+}
+
+func (e *sftpStatusError) Unwrap() error {
+	if e.code == sftpNoFile {
+		return errSFTPNoFile
+	}
+	return nil
+}
+
+// sftpConn is a session with an SFTP server. Its requests wait for their
+// replies, but for writes, whose replies are read by status.
+type sftpConn struct {
+	cmd    *exec.Cmd
+	w      io.WriteCloser
+	r      *bufio.Reader
+	stderr bytes.Buffer
+	id     uint32
+}
+
+func (c *sftpConn) close() error {
+	c.w.Close()
+	return c.cmd.Wait()
+}
+
+// failed adds what the command said to an error of the session.
+func (c *sftpConn) failed(err error) error {
+	if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+func (c *sftpConn) init() error {
+	if err := c.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return err
+	}
+	typ, b, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if typ != sftpVersion || len(b) < 4 {
+		return fmt.Errorf("sftp: unexpected reply %d to init", typ)
+	}
+	if v := binary.BigEndian.Uint32(b); v < 3 {
+		return fmt.Errorf("sftp: server speaks version %d", v)
+	}
+	return nil
+}
+
+func (c *sftpConn) send(typ byte, payload []byte) error {
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(payload)), uint32(1+len(payload)))
+	b = append(b, typ)
+	b = append(b, payload...)
+	_, err := c.w.Write(b)
+	return err
+}
+
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, c.failed(err)
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: packet of %d bytes", n)
+	}
+	b := make([]byte, n-1)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return 0, nil, c.failed(err)
+	}
+	return hdr[4], b, nil
+}
+
+// request sends a request and returns its id.
+func (c *sftpConn) request(typ byte, fields ...any) (uint32, error) {
+	c.id++
+	b := binary.BigEndian.AppendUint32(nil, c.id)
+	for _, f := range fields {
+		switch f := f.(type) {
+		case uint32:
+			b = binary.BigEndian.AppendUint32(b, f)
+		case uint64:
+			b = binary.BigEndian.AppendUint64(b, f)
+		case string:
+			b = sftpAppendString(b, []byte(f))
+		case []byte:
+			b = sftpAppendString(b, f)
+		}
+	}
+	return c.id, c.send(typ, b)
+}
+
+// call sends a request and reads its reply.
+func (c *sftpConn) call(typ byte, fields ...any) (byte, []byte, error) {
+	id, err := c.request(typ, fields...)
+	if err != nil {
+		return 0, nil, err
+	}
+	reply, b, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(b) < 4 || binary.BigEndian.Uint32(b) != id {
+		return 0, nil, fmt.Errorf("sftp: reply to another request")
+	}
+	b = b[4:]
+	if reply == sftpStatus {
+		if err := sftpStatusOf(b); err != nil {
+			return 0, nil, err
+		}
+	}
+	return reply, b, nil
+}
+
+// status reads the reply to a write, returning the id of the write.
+func (c *sftpConn) status() (uint32, error) {
+	typ, b, err := c.recv()
+	if err != nil {
+		return 0, err
+	}
+	if typ != sftpStatus || len(b) < 4 {
+		return 0, fmt.Errorf("sftp: unexpected reply %d to write", typ)
+	}
+	return binary.BigEndian.Uint32(b), sftpStatusOf(b[4:])
+}
+
+func sftpStatusOf(b []byte) error {
+	if len(b) < 4 {
+		return fmt.Errorf("sftp: short status")
+	}
+	code := binary.BigEndian.Uint32(b)
+	if code == sftpOK {
+		return nil
+	}
+	msg, _, _ := sftpString(b[4:])
+	return &sftpStatusError{code: code, msg: string(msg)}
+}
+
+func (c *sftpConn) handle(typ byte, fields ...any) (string, error) {
+	reply, b, err := c.call(typ, fields...)
+	if err != nil {
+		return "", err
+	}
+	if reply != sftpHandle {
+		return "", fmt.Errorf("sftp: unexpected reply %d", reply)
+	}
+	h, _, ok := sftpString(b)
+	if !ok {
+		return "", fmt.Errorf("sftp: short handle")
+	}
+	return string(h), nil
+}
+
+func (c *sftpConn) open(p string, flags uint32) (string, error) {
+	return c.handle(sftpOpen, p, flags, uint32(sftpAttrPerm), uint32(0600))
+}
+
+func (c *sftpConn) write(handle string, chunk *sftpChunk) error {
+	id, err := c.request(sftpWrite, handle, chunk.off, chunk.data)
+	chunk.id = id
+	return err
+}
+
+func (c *sftpConn) closeHandle(handle string) error {
+	_, _, err := c.call(sftpClose, handle)
+	return err
+}
+
+func (c *sftpConn) remove(p string) error {
+	_, _, err := c.call(sftpRemove, p)
+	return err
+}
+
+func (c *sftpConn) rename(from, to string) error {
+	_, _, err := c.call(sftpRename, from, to)
+	return err
+}
+
+// mkdirAll creates dir and its parents, leaving it to opening a file in it
+// to report a directory that could not be created.
+func (c *sftpConn) mkdirAll(dir string) {
+	if dir == "" || dir == "." || dir == "/" {
+		return
+	}
+	c.mkdirAll(path.Dir(dir))
+	c.call(sftpMkdir, dir, uint32(sftpAttrPerm), uint32(0755))
+}
+
+// readDir returns the names of the files of dir that are not directories
+// or dot-files.
+func (c *sftpConn) readDir(dir string) ([]string, error) {
+	h, err := c.handle(sftpOpendir, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(h)
+	var names []string
+	for {
+		reply, b, err := c.call(sftpReaddir, h)
+		var status *sftpStatusError
+		if errors.As(err, &status) && status.code == sftpEOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if reply != sftpName || len(b) < 4 {
+			return nil, fmt.Errorf("sftp: unexpected reply %d to readdir", reply)
+		}
+		count := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		for i := uint32(0); i < count; i++ {
+			var name []byte
+			var ok bool
+			if name, b, ok = sftpString(b); !ok {
+				return nil, fmt.Errorf("sftp: short name")
+			}
+			// The long name, as ls -l prints it.
+			if _, b, ok = sftpString(b); !ok {
+				return nil, fmt.Errorf("sftp: short name")
+			}
+			var perm uint32
+			if perm, b, ok = sftpAttrs(b); !ok {
+				return nil, fmt.Errorf("sftp: short attributes")
+			}
+			if perm&0170000 != 0040000 && !strings.HasPrefix(string(name), ".") {
+				names = append(names, string(name))
+			}
+		}
+	}
+}
+
+func sftpAppendString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func sftpString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+// sftpAttrs skips the attributes at the start of b, returning their
+// permissions.
+func sftpAttrs(b []byte) (perm uint32, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return 0, nil, false
+	}
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	skip := func(n int) bool {
+		if len(b) < n {
+			return false
+		}
+		b = b[n:]
+		return true
+	}
+	if flags&sftpAttrSize != 0 && !skip(8) {
+		return 0, nil, false
+	}
+	if flags&sftpAttrOwner != 0 && !skip(8) {
+		return 0, nil, false
+	}
+	if flags&sftpAttrPerm != 0 {
+		if len(b) < 4 {
+			return 0, nil, false
+		}
+		perm = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+	if flags&sftpAttrTime != 0 && !skip(8) {
+		return 0, nil, false
+	}
+	if flags&sftpAttrExt != 0 {
+		if len(b) < 4 {
+			return 0, nil, false
+		}
+		count := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		for i := uint32(0); i < 2*count; i++ {
+			if _, b, ok = sftpString(b); !ok {
+				return 0, nil, false
+			}
+		}
+	}
+	return perm, b, true
+}
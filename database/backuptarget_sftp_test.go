@@ -0,0 +1,260 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestSFTPServer is not a test but the SFTP server the tests run in place
+// of ssh, serving the directory $SFTP_TEST_ROOT. If $SFTP_TEST_DROP names
+// a file that does not exist, it creates it and drops the connection on
+// the 40th write, once.
+func TestSFTPServer(t *testing.T) {
+	root := os.Getenv("SFTP_TEST_ROOT")
+	if root == "" {
+		t.Skip("run by the SFTP target tests")
+	}
+	drop := 0
+	if marker := os.Getenv("SFTP_TEST_DROP"); marker != "" {
+		if _, err := os.Stat(marker); os.IsNotExist(err) {
+			os.WriteFile(marker, nil, 0644)
+			drop = 40
+		}
+	}
+	serveSFTP(root, os.Stdin, os.Stdout, drop)
+	os.Exit(0)
+}
+
+// serveSFTP answers the requests the SFTP target makes on in, exiting on
+// write number drop if it is not 0.
+func serveSFTP(root string, in io.Reader, out io.Writer, drop int) {
+	r, w := bufio.NewReader(in), bufio.NewWriter(out)
+	files := map[string]*os.File{}
+	dirs := map[string]string{}
+	listed := map[string]bool{}
+	handles, writes := 0, 0
+	reply := func(typ byte, b []byte) {
+		w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(b)+1)))
+		w.WriteByte(typ)
+		w.Write(b)
+		w.Flush()
+	}
+	status := func(id, code uint32) {
+		b := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, id), code)
+		b = sftpAppendString(b, []byte("failed"))
+		reply(sftpStatus, sftpAppendString(b, nil))
+	}
+	statusOf := func(id uint32, err error) {
+		switch {
+		case err == nil:
+			status(id, sftpOK)
+		case os.IsNotExist(err):
+			status(id, sftpNoFile)
+		default:
+			status(id, 4)
+		}
+	}
+	handle := func(id uint32) string {
+		handles++
+		h := fmt.Sprint(handles)
+		reply(sftpHandle, sftpAppendString(binary.BigEndian.AppendUint32(nil, id), []byte(h)))
+		return h
+	}
+	path := func(b []byte) (string, []byte) {
+		s, rest, _ := sftpString(b)
+		return filepath.Join(root, string(s)), rest
+	}
+
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return
+		}
+		if hdr[4] == sftpInit {
+			reply(sftpVersion, binary.BigEndian.AppendUint32(nil, 3))
+			continue
+		}
+		id, b := binary.BigEndian.Uint32(b), b[4:]
+		switch hdr[4] {
+		case sftpOpen:
+			name, rest := path(b)
+			flags := os.O_WRONLY | os.O_CREATE
+			if binary.BigEndian.Uint32(rest)&sftpFlagTrunc != 0 {
+				flags |= os.O_TRUNC
+			}
+			f, err := os.OpenFile(name, flags, 0600)
+			if err != nil {
+				statusOf(id, err)
+				continue
+			}
+			files[handle(id)] = f
+		case sftpWrite:
+			h, rest, _ := sftpString(b)
+			off := binary.BigEndian.Uint64(rest)
+			data, _, _ := sftpString(rest[8:])
+			if writes++; writes == drop {
+				os.Exit(1)
+			}
+			_, err := files[string(h)].WriteAt(data, int64(off))
+			statusOf(id, err)
+		case sftpClose:
+			h, _, _ := sftpString(b)
+			var err error
+			if f, ok := files[string(h)]; ok {
+				err = f.Close()
+				delete(files, string(h))
+			}
+			delete(dirs, string(h))
+			statusOf(id, err)
+		case sftpRemove:
+			name, _ := path(b)
+			statusOf(id, os.Remove(name))
+		case sftpMkdir:
+			name, _ := path(b)
+			statusOf(id, os.Mkdir(name, 0755))
+		case sftpRename:
+			from, rest := path(b)
+			to, _ := path(rest)
+			if _, err := os.Stat(to); err == nil {
+				status(id, 4)
+				continue
+			}
+			statusOf(id, os.Rename(from, to))
+		case sftpOpendir:
+			name, _ := path(b)
+			if _, err := os.Stat(name); err != nil {
+				statusOf(id, err)
+				continue
+			}
+			dirs[handle(id)] = name
+		case sftpReaddir:
+			h, _, _ := sftpString(b)
+			if listed[string(h)] {
+				status(id, sftpEOF)
+				continue
+			}
+			listed[string(h)] = true
+			entries, _ := os.ReadDir(dirs[string(h)])
+			b := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, id), uint32(len(entries)))
+			for _, e := range entries {
+				info, _ := e.Info()
+				perm := uint32(0100644)
+				if e.IsDir() {
+					perm = 040755
+				}
+				b = sftpAppendString(b, []byte(e.Name()))
+				b = sftpAppendString(b, []byte("-rw-r--r-- "+e.Name()))
+				b = binary.BigEndian.AppendUint32(b, sftpAttrSize|sftpAttrPerm|sftpAttrTime)
+				b = binary.BigEndian.AppendUint64(b, uint64(info.Size()))
+				b = binary.BigEndian.AppendUint32(b, perm)
+				b = binary.BigEndian.AppendUint64(b, 0)
+			}
+			reply(sftpName, b)
+		default:
+			status(id, 8)
+		}
+	}
+}
+
+func newSFTPTestTarget(t *testing.T, root, dir string) BackupTarget {
+	t.Helper()
+	t.Setenv("SFTP_TEST_ROOT", root)
+	target, err := NewSFTPBackupTarget(SFTPConfig{Dir: dir, Command: []string{os.Args[0], "-test.run=^TestSFTPServer$"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestSFTPBackupTarget(t *testing.T) {
+	root := t.TempDir()
+	target := newSFTPTestTarget(t, root, "backups/db")
+	ctx := context.Background()
+
+	// Enough writes for the connection to drop during the upload.
+	drop := filepath.Join(t.TempDir(), "dropped")
+	t.Setenv("SFTP_TEST_DROP", drop)
+	data := bytes.Repeat([]byte("0123456789"), 100*sftpChunkSize/10)
+	upload, err := target.Create(ctx, "big.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(drop); err != nil {
+		t.Fatalf("connection never dropped: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "backups/db/big.tar.gz")); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("uploaded %d bytes of %d, %v; want the upload resumed", len(b), len(data), err)
+	}
+
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	// The second backup under a name replaces the first.
+	for i := 0; i < 2; i++ {
+		if err := d.BackupTo(ctx, target, "big.tar.gz", BackupOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(filepath.Join(root, "backups/db/big.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if report, err := VerifyBackup(f, VerifyBackupOptions{}); err != nil || len(report.Issues) != 0 || report.Records != 1 {
+		t.Errorf("VerifyBackup of the uploaded backup = %+v, %v", report, err)
+	}
+
+	bad := BackupOptions{Encryption: BackupEncryption{Key: []byte{1}}}
+	if err := d.BackupTo(ctx, target, "two.tar.gz", bad); err == nil {
+		t.Error("BackupTo with a bad key succeeded")
+	}
+	if err := os.Mkdir(filepath.Join(root, "backups/db/sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	names, err := target.List(ctx)
+	if err != nil || !reflect.DeepEqual(names, []string{"big.tar.gz"}) {
+		t.Errorf("List = %q, %v; want only the backup, with the failed one removed", names, err)
+	}
+	if err := target.Remove(ctx, "big.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Remove(ctx, "big.tar.gz"); !os.IsNotExist(err) {
+		t.Errorf("Remove of a missing backup = %v, want it not to exist", err)
+	}
+	if names, err := newSFTPTestTarget(t, root, "none").List(ctx); err != nil || names != nil {
+		t.Errorf("List of a missing directory = %q, %v; want nothing", names, err)
+	}
+}
+
+func TestNewSFTPBackupTarget(t *testing.T) {
+	if _, err := NewSFTPBackupTarget(SFTPConfig{Dir: "backups"}); err == nil {
+		t.Error("NewSFTPBackupTarget without a host succeeded")
+	}
+	target := newSFTPTestTarget(t, filepath.Join(t.TempDir(), "missing"), "")
+	if _, err := target.Create(context.Background(), "one.tar.gz"); err == nil {
+		t.Error("Create in a missing directory succeeded")
+	}
+}
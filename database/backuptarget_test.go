@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirTarget(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	target := DirTarget(dir)
+	ctx := context.Background()
+	if names, err := target.List(ctx); err != nil || names != nil {
+		t.Errorf("List of a missing directory = %q, %v; want nothing", names, err)
+	}
+
+	upload, err := target.Create(ctx, "one.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload.Write([]byte("backup")); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := target.List(ctx); len(names) != 0 {
+		t.Errorf("List during an upload = %q, want nothing", names)
+	}
+	if err := upload.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "one.tar.gz")); err != nil || string(b) != "backup" {
+		t.Errorf("backup written = %q, %v", b, err)
+	}
+
+	upload, err = target.Create(ctx, "two.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload.Write([]byte("half"))
+	if err := upload.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := target.List(ctx); err != nil || !reflect.DeepEqual(names, []string{"one.tar.gz"}) {
+		t.Errorf("List = %q, %v; want only the backup closed", names, err)
+	}
+	if err := target.Remove(ctx, "one.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Remove(ctx, "one.tar.gz"); !os.IsNotExist(err) {
+		t.Errorf("Remove of a missing backup = %v, want it not to exist", err)
+	}
+}
+
+func TestBackupTo(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ctx := context.Background()
+	if err := d.BackupTo(ctx, DirTarget(dir), "one.tar.gz", BackupOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "one.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if report, err := VerifyBackup(f, VerifyBackupOptions{}); err != nil || report.Records != 1 {
+		t.Errorf("VerifyBackup = %+v, %v; want the record", report, err)
+	}
+
+	for _, name := range []string{"", ".hidden", "a/b", `a\b`, "../up"} {
+		if err := d.BackupTo(ctx, DirTarget(dir), name, BackupOptions{}); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("BackupTo of %q = %v, want ErrInvalidName", name, err)
+		}
+	}
+	bad := BackupOptions{Encryption: BackupEncryption{Key: []byte{1}}}
+	if err := d.BackupTo(ctx, DirTarget(dir), "two.tar.gz", bad); err == nil {
+		t.Error("BackupTo with a bad key succeeded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("failed backup left files: %v", entries)
+	}
+}
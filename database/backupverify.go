@@ -0,0 +1,228 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backups end with a manifest of the files they hold, which Restore leaves
+// out of the database.
+const backupManifestFile = ".backup.json"
+
+// BackupManifest is what a backup holds, to tell a complete and intact one
+// from one that is not.
+type BackupManifest struct {
+	Created time.Time `json:"created"`
+	// Files maps every file of the backup to the hex SHA-256 of its
+	// contents.
+	Files map[string]string `json:"files"`
+	// Records counts the record files of each collection, expired ones
+	// included.
+	Records map[string]int `json:"records"`
+}
+
+// add adds the file at p with contents b to the manifest.
+func (m *BackupManifest) add(d *Driver, p string, b []byte) {
+	sum := sha256.Sum256(b)
+	m.Files[p] = hex.EncodeToString(sum[:])
+	if collection, ok := d.recordCollection(p); ok {
+		m.Records[collection]++
+	}
+}
+
+// recordCollection returns the collection of the record file at p, or
+// false if p holds no record.
+func (d *Driver) recordCollection(p string) (string, bool) {
+	collection, file := path.Split(p)
+	collection = strings.TrimSuffix(collection, "/")
+	if collection == "" || validCollection(collection) != nil {
+		return "", false
+	}
+	_, ok := d.recordStem(collection, file)
+	return collection, ok
+}
+
+// BackupReport is what VerifyBackup found: the records it read and the
+// issues of the backup, as Verify reports those of a database, along with
+// files missing, changed or unaccounted for and record counts that differ
+// from the manifest.
+type BackupReport struct {
+	VerifyReport
+	// Created is when the backup was taken, zero if it has no manifest.
+	Created time.Time
+	Files   int
+}
+
+// VerifyBackupOptions tunes VerifyBackup.
+type VerifyBackupOptions struct {
+	Encryption BackupEncryption
+	// Options opens the database the backup holds to decode its records,
+	// and needs the MasterKey, FieldKey and Codec of the database backed
+	// up. Its Backend and ReadOnly are set by VerifyBackup.
+	Options *Options
+}
+
+// VerifyBackup reads a Backup through, checking every file against the
+// checksums of its manifest and every record count against the counts it
+// holds, then decodes every record as Verify does, so a bad backup is found
+// before it is needed. It fails if the archive cannot be read to the end,
+// as when it is cut short, and reports what is wrong with one that can.
+// The backup is unpacked in memory and writes nothing.
+func VerifyBackup(archive io.Reader, opts VerifyBackupOptions) (*BackupReport, error) {
+	r, err := openBackup(archive, opts.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	defer gz.Close()
+
+	backend := NewMemoryBackend()
+	report := &BackupReport{}
+	report.Issues = []Issue{}
+	sums := make(map[string]string)
+	var manifest *BackupManifest
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("reading backup: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return report, fmt.Errorf("%w: backup entry %q escapes the database directory", ErrInvalidName, hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return report, fmt.Errorf("reading backup: %w", err)
+		}
+		if name == backupManifestFile {
+			manifest = &BackupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				report.add(name, err.Error())
+				manifest = nil
+			}
+			continue
+		}
+		sum := sha256.Sum256(data)
+		sums[name] = hex.EncodeToString(sum[:])
+		if err := backend.Put(name, data); err != nil {
+			return report, err
+		}
+		report.Files++
+	}
+	if err := gz.Close(); err != nil {
+		return report, fmt.Errorf("reading backup: %w", err)
+	}
+
+	o := Options{}
+	if opts.Options != nil {
+		o = *opts.Options
+	}
+	o.Backend = backend
+	o.ReadOnly = true
+	o.TTLSweepInterval = -1
+	d, err := New("backup", &o)
+	if err != nil {
+		return report, fmt.Errorf("opening backup: %w", err)
+	}
+	defer d.Close()
+
+	if manifest == nil {
+		report.add(backupManifestFile, "missing - the backup cannot be checked for files it lost")
+	} else {
+		report.Created = manifest.Created
+		checkBackupManifest(d, manifest, sums, &report.VerifyReport)
+	}
+	verified, err := d.Verify()
+	if err != nil {
+		return report, err
+	}
+	report.Records = verified.Records
+	report.Issues = append(report.Issues, verified.Issues...)
+	return report, nil
+}
+
+// checkBackupManifest reports the files of d that differ from manifest,
+// with checksums sums, and the collections whose record counts do.
+func checkBackupManifest(d *Driver, manifest *BackupManifest, sums map[string]string, report *VerifyReport) {
+	records := make(map[string]int)
+	var names []string
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, ok := manifest.Files[name]
+		switch {
+		case !ok:
+			report.add(name, "not in the backup manifest")
+		case want != sums[name]:
+			report.add(name, "checksum does not match the backup manifest")
+		}
+		if collection, ok := d.recordCollection(name); ok {
+			records[collection]++
+		}
+	}
+	names = names[:0]
+	for name := range manifest.Files {
+		if _, ok := sums[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.add(name, "missing from the backup")
+	}
+
+	var collections []string
+	for collection := range manifest.Records {
+		collections = append(collections, collection)
+	}
+	for collection := range records {
+		if _, ok := manifest.Records[collection]; !ok {
+			collections = append(collections, collection)
+		}
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		if want, got := manifest.Records[collection], records[collection]; want != got {
+			report.add(collection, fmt.Sprintf("backup manifest counts %d records, backup holds %d", want, got))
+		}
+	}
+}
+
+// writeBackupManifest adds the manifest to the end of a backup.
+func writeBackupManifest(tw *tar.Writer, m *BackupManifest) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: backupManifestFile, Mode: 0644, Size: int64(buf.Len()), ModTime: m.Created, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// rewriteBackup rewrites the files of a plain backup with edit, which
+// returns their new contents, or false to leave them out. It adds extra
+// files at the end.
+func rewriteBackup(t *testing.T, backup []byte, edit func(name string, b []byte) ([]byte, bool), extra map[string]string) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(backup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	write := func(name string, b []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := edit(hdr.Name, b); ok {
+			write(hdr.Name, b)
+		}
+	}
+	for name, b := range extra {
+		write(name, []byte(b))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestVerifyBackup(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, key := range []string{"ada", "bob", "cy"} {
+		if err := d.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var backup bytes.Buffer
+	if err := d.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyBackup(bytes.NewReader(backup.Bytes()), VerifyBackupOptions{})
+	if err != nil || len(report.Issues) != 0 || report.Records != 3 || report.Files != 3 || report.Created.IsZero() {
+		t.Fatalf("VerifyBackup = %+v, %v; want 3 records and no issues", report, err)
+	}
+
+	b := NewMemoryBackend()
+	if err := Restore(b, bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get(backupManifestFile); !isNotExist(err) {
+		t.Errorf("Restore wrote the backup manifest: %v", err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		edit  func(name string, b []byte) ([]byte, bool)
+		extra map[string]string
+		want  []string
+	}{
+		{
+			name: "changed",
+			edit: func(name string, b []byte) ([]byte, bool) {
+				if name == "users/bob.json" {
+					return []byte("{"), true
+				}
+				return b, true
+			},
+			want: []string{"users/bob.json: checksum does not match"},
+		},
+		{
+			name: "lost",
+			edit: func(name string, b []byte) ([]byte, bool) { return b, name != "users/cy.json" },
+			want: []string{"users/cy.json: missing from the backup", "users: backup manifest counts 3 records, backup holds 2"},
+		},
+		{
+			name:  "added",
+			edit:  func(name string, b []byte) ([]byte, bool) { return b, true },
+			extra: map[string]string{"users/dee.json": `{"name":"dee"}`},
+			want:  []string{"users/dee.json: not in the backup manifest", "users: backup manifest counts 3 records, backup holds 4"},
+		},
+		{
+			name: "without a manifest",
+			edit: func(name string, b []byte) ([]byte, bool) { return b, name != backupManifestFile },
+			want: []string{backupManifestFile + ": missing"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			report, err := VerifyBackup(bytes.NewReader(rewriteBackup(t, backup.Bytes(), c.edit, c.extra)), VerifyBackupOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var issues []string
+			for _, issue := range report.Issues {
+				issues = append(issues, issue.Path+": "+issue.Problem)
+			}
+			for _, want := range c.want {
+				found := false
+				for _, issue := range issues {
+					found = found || strings.HasPrefix(issue, want)
+				}
+				if !found {
+					t.Errorf("issues = %q, want one starting %q", issues, want)
+				}
+			}
+		})
+	}
+
+	if _, err := VerifyBackup(bytes.NewReader(backup.Bytes()[:backup.Len()/2]), VerifyBackupOptions{}); err == nil {
+		t.Error("VerifyBackup of a backup cut short succeeded")
+	}
+}
+
+func TestVerifyEncryptedBackup(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{MasterKey: testMasterKey, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	enc := BackupEncryption{Passphrase: "hunter2"}
+	var backup bytes.Buffer
+	if err := d.BackupWith(&backup, BackupOptions{Encryption: enc}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyBackup(bytes.NewReader(backup.Bytes()), VerifyBackupOptions{}); !errors.Is(err, ErrBackupEncrypted) {
+		t.Errorf("VerifyBackup without the passphrase = %v, want ErrBackupEncrypted", err)
+	}
+	opts := VerifyBackupOptions{Encryption: enc, Options: &Options{MasterKey: testMasterKey}}
+	report, err := VerifyBackup(bytes.NewReader(backup.Bytes()), opts)
+	if err != nil || len(report.Issues) != 0 || report.Records != 1 {
+		t.Errorf("VerifyBackup = %+v, %v; want 1 record and no issues", report, err)
+	}
+	// Without the master key the records do not decode.
+	opts.Options = nil
+	if report, err := VerifyBackup(bytes.NewReader(backup.Bytes()), opts); err == nil && len(report.Issues) == 0 {
+		t.Error("VerifyBackup without the master key found no issues")
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrKeyCollision = errors.New("key collision")
+
+type KeyCase int
+
+const (
+	// KeyCaseDetect probes the file system at open and, if it folds case,
+	// rejects writes whose key differs only in case from an existing one.
+	KeyCaseDetect KeyCase = iota
+	// KeyCaseSensitive trusts the file system and never checks.
+	KeyCaseSensitive
+	// KeyCaseEncode percent-encodes upper-case letters, so keys differing
+	// only in case map to distinct files everywhere.
+	KeyCaseEncode
+)
+
+// caseInsensitive reports whether dir folds case, by creating a probe file
+// and looking it up again in lower case.
+func caseInsensitive(dir string) (bool, error) {
+	name := fmt.Sprintf(".CaseProbe-%d", time.Now().UnixNano())
+	probe := filepath.Join(dir, name)
+	f, err := os.OpenFile(probe, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+	defer os.Remove(probe)
+
+	_, err = os.Stat(filepath.Join(dir, strings.ToLower(name)))
+	return err == nil, nil
+}
+
+// checkKeyCase fails if stem case-folds to a different record already in
+// the collection, loading its case index. Writes that then succeed add
+// their stem with learnKeyCase. Callers hold the collection lock, which
+// also guards the collection's entry in the case index.
+func (d *Driver) checkKeyCase(collection, stem string) error {
+	if !d.foldsCase {
+		return nil
+	}
+	index, err := d.caseIndex(collection)
+	if err != nil {
+		return err
+	}
+	if existing, ok := index[strings.ToLower(stem)]; ok && existing != stem {
+		return fmt.Errorf("%w: key %q would overwrite %q on this case-insensitive file system",
+			ErrKeyCollision, decodeKey(stem), decodeKey(existing))
+	}
+	return nil
+}
+
+func (d *Driver) caseIndex(collection string) (map[string]string, error) {
+	d.mutex.Lock()
+	index, ok := d.caseIndexes[collection]
+	d.mutex.Unlock()
+	if ok {
+		return index, nil
+	}
+
+	index = make(map[string]string)
+	files, err := d.backend.List(collection)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if stem, ok := d.recordStem(collection, file); ok && !isDirName(file) {
+			index[strings.ToLower(stem)] = stem
+		}
+	}
+
+	d.mutex.Lock()
+	d.caseIndexes[collection] = index
+	d.mutex.Unlock()
+	return index, nil
+}
+
+func (d *Driver) forgetKeyCase(collection, stem string) {
+	if !d.foldsCase {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if stem == "" {
+		for c := range d.caseIndexes {
+			if c == collection || strings.HasPrefix(c, collection+"/") {
+				delete(d.caseIndexes, c)
+			}
+		}
+		return
+	}
+	if index, ok := d.caseIndexes[collection]; ok {
+		delete(index, strings.ToLower(stem))
+	}
+}
+
+// learnKeyCase adds stem to the case index of the collection if it is
+// loaded, for a record just written, by this process or another. Callers
+// hold the collection lock.
+func (d *Driver) learnKeyCase(collection, stem string) {
+	if !d.foldsCase {
+		return
+	}
+	d.mutex.Lock()
+	index, ok := d.caseIndexes[collection]
+	d.mutex.Unlock()
+	if ok {
+		index[strings.ToLower(stem)] = stem
+	}
+}
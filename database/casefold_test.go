@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	folds, err := caseInsensitive(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "linux" && folds {
+		t.Error("temp directory on Linux reported as case-insensitive")
+	}
+}
+
+func TestKeyCaseEncode(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{KeyCase: KeyCaseEncode, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, key := range []string{"Ada", "ada"} {
+		if err := d.Write("users", key, map[string]string{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"%41da.json", "ada.json"} {
+		if _, err := os.Stat(filepath.Join(dir, "users", file)); err != nil {
+			t.Errorf("missing %s: %v", file, err)
+		}
+	}
+	var v map[string]string
+	if err := d.Read("users", "Ada", &v); err != nil || v["key"] != "Ada" {
+		t.Errorf("Read Ada = %v, %v", v, err)
+	}
+}
+
+func TestKeyCollision(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// As if the directory were on a case-insensitive file system.
+	d.foldsCase = true
+
+	if err := d.Write("users", "Ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", 2); !errors.Is(err, ErrKeyCollision) {
+		t.Fatalf("Write of a key differing in case = %v, want ErrKeyCollision", err)
+	}
+	if err := d.Write("users", "Ada", 3); err != nil {
+		t.Errorf("overwriting the same key: %v", err)
+	}
+	if err := d.Delete("users", "Ada"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", 4); err != nil {
+		t.Errorf("Write once the other case was deleted: %v", err)
+	}
+}
+
+func TestKeyCollisionFailedWrite(t *testing.T) {
+	schema, err := Compile("doc.n > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"users": {Schema: schema}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.foldsCase = true
+
+	if err := d.Write("users", "Ada", map[string]int{"n": 0}); err == nil {
+		t.Fatal("Write of a record failing the schema succeeded")
+	}
+	if err := d.Write("users", "ada", map[string]int{"n": 1}); err != nil {
+		t.Errorf("Write after a failed write of a key differing in case: %v", err)
+	}
+}
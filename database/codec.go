@@ -0,0 +1,49 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Codec turns records into bytes and back. Extension is the file suffix
+// records written with the codec get, before any compression suffix.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+	Extension() string
+}
+
+// JSONCodec is the default codec: tab-indented JSON with a trailing newline.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, byte('\n')), nil
+}
+
+func (JSONCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+func (JSONCodec) Extension() string {
+	return ".json"
+}
+
+func validExtension(ext string) error {
+	if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext, "/\\\x00") {
+		return fmt.Errorf("invalid record extension %q - must be a dot followed by a name", ext)
+	}
+	for _, c := range compressions {
+		if c != CompressionNone && ext == c.ext() {
+			return fmt.Errorf("invalid record extension %q - reserved for compression", ext)
+		}
+	}
+	if ext == ".tmp" || ext == ".key" {
+		return fmt.Errorf("invalid record extension %q - reserved", ext)
+	}
+	return nil
+}
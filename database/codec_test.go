@@ -0,0 +1,206 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(b []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+func (gobCodec) Extension() string {
+	return ".gob"
+}
+
+// taggedCodec is JSON after a T, so its records are told apart from
+// those of the database's codec. Unlike gobCodec, it decodes documents.
+type taggedCodec struct{}
+
+func (taggedCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	return append([]byte("T"), b...), err
+}
+
+func (taggedCodec) Unmarshal(b []byte, v interface{}) error {
+	if !bytes.HasPrefix(b, []byte("T")) {
+		return errors.New("not a tagged record")
+	}
+	return json.Unmarshal(b[1:], v)
+}
+
+func (taggedCodec) Extension() string {
+	return ".tag"
+}
+
+func TestJSONCodec(t *testing.T) {
+	b, err := JSONCodec{}.Marshal(map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{\n\t\"n\": 1\n}\n" {
+		t.Errorf("Marshal = %q, want tab-indented JSON with a newline", b)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{Codec: gobCodec{}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	type user struct{ Name string }
+	if err := d.Write("users", "ada", user{"Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "ada.gob")); err != nil {
+		t.Fatalf("record not stored with the codec's extension: %v", err)
+	}
+	var got user
+	if err := d.Read("users", "ada", &got); err != nil || got.Name != "Ada" {
+		t.Fatalf("Read = %+v, %v", got, err)
+	}
+	if keys, err := d.Keys("users"); err != nil || len(keys) != 1 || keys[0] != "ada" {
+		t.Errorf("Keys = %v, %v", keys, err)
+	}
+}
+
+func TestExtension(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{Extension: ".rec", TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "ada.rec")); err != nil {
+		t.Errorf("record not stored with the extension: %v", err)
+	}
+
+	for _, ext := range []string{"rec", ".", ".gz", ".zst", ".tmp", ".key", "./x"} {
+		if _, err := NewMemory(&Options{Extension: ext}); err == nil {
+			t.Errorf("opened with extension %q", ext)
+		}
+	}
+}
+
+func TestCollectionCodec(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{
+		Collections:      map[string]CollectionOptions{"events": {Codec: taggedCodec{}, Compression: CompressionGzip}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("events", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"events/a.tag.gz", "users/ada.json"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("record not stored as %s: %v", f, err)
+		}
+	}
+	var v map[string]int
+	if err := d.Read("events", "a", &v); err != nil || v["n"] != 1 {
+		t.Errorf("Read = %v, %v", v, err)
+	}
+	if results, err := d.Find(Query{Collection: "events", Filter: "doc.n == 1"}); err != nil || len(results) != 1 {
+		t.Errorf("Find = %v, %v; want a", results, err)
+	}
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v; want no issues", report.Issues, err)
+	}
+}
+
+func TestWriteRawWith(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{
+		Collections:      map[string]CollectionOptions{"events": {Codec: taggedCodec{}, Compression: CompressionGzip}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// JSON content is stored encoded with the collection's codec.
+	opts := RawOptions{Codec: JSONCodec{}, Compression: "none"}
+	if err := d.WriteRawWith(context.Background(), "events", "b", strings.NewReader(`{"n": 3}`), opts); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "events", "b.tag"))
+	if err != nil {
+		t.Fatalf("record not stored uncompressed with the collection's codec: %v", err)
+	}
+	if string(b) != `T{"n":3}` {
+		t.Errorf("stored record = %q, want it encoded with the collection's codec", b)
+	}
+
+	if err := d.Write("events", "b", map[string]int{"n": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events", "b.tag.gz")); err != nil {
+		t.Errorf("next write not stored with the collection's compression: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events", "b.tag")); !os.IsNotExist(err) {
+		t.Errorf("uncompressed copy left after the next write: %v", err)
+	}
+	if err := d.WriteRawWith(context.Background(), "events", "c", strings.NewReader(`{`), opts); err == nil {
+		t.Error("WriteRawWith of invalid JSON succeeded")
+	}
+}
+
+func TestManifestCodec(t *testing.T) {
+	dir := t.TempDir()
+	gob := testName("codec")
+	RegisterCodec(gob, gobCodec{})
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetManifest("logs", Manifest{Codec: gob}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("logs", "x", map[string]int{"n": 5}); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err = New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var v map[string]int
+	if err := d.Read("logs", "x", &v); err != nil || v["n"] != 5 {
+		t.Errorf("Read after reopening = %v, %v", v, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "x.gob")); err != nil {
+		t.Errorf("record not stored with the manifest's codec: %v", err)
+	}
+}
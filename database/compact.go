@@ -0,0 +1,208 @@
+package database
+
+import (
+	"path"
+	"strings"
+)
+
+// CompactReport counts what Compact removed.
+type CompactReport struct {
+	TempFiles      int
+	ExpiredRecords int
+	// OrphanedSidecars counts expiry, metadata and field key sidecars, and
+	// attachment directories, whose record is gone.
+	OrphanedSidecars int
+	EmptyCollections int
+	ReclaimedBytes   int64
+}
+
+// Compact removes what interrupted and finished operations leave behind:
+// temp files of writes that never completed, expired records the sweeper
+// has not reached, leftovers of deleted records, and collections with
+// nothing left in them. Files it does not recognize are left for Verify to
+// report. Records are stored whole, one file each, so there is nothing to
+// rewrite.
+func (d *Driver) Compact() (*CompactReport, error) {
+	if err := d.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := d.authorize("*", PermDelete); err != nil {
+		return nil, err
+	}
+
+	report := &CompactReport{}
+	n, err := d.SweepExpired()
+	report.ExpiredRecords = n
+	if err != nil {
+		return report, err
+	}
+
+	collections, err := d.collectionPaths()
+	if err != nil {
+		return report, err
+	}
+	// Sub-collections go first, so the directories of their records are
+	// left empty for the parent collections to remove.
+	for i := len(collections) - 1; i >= 0; i-- {
+		if err := d.compactCollection(collections[i], report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (d *Driver) compactCollection(collection string, report *CompactReport) error {
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.backend.List(collection)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Every temp file is stale: writes make them under the collection lock.
+	stems := make(map[string]bool)
+	var dirs []string
+	for _, file := range files {
+		switch stem, isRecord := d.recordStem(collection, file); {
+		case isDirName(file):
+			dirs = append(dirs, strings.TrimSuffix(file, "/"))
+		case strings.HasSuffix(file, ".tmp"):
+			if err := d.compactRemove(path.Join(collection, file), false, &report.TempFiles, report); err != nil {
+				return err
+			}
+		case isRecord:
+			stems[stem] = true
+		}
+	}
+
+	for _, dir := range dirs {
+		p := path.Join(collection, dir)
+		switch {
+		case dir == recordKeysDir:
+			err = d.compactSidecars(p, ".key", stems, report)
+		case dir == ttlDir:
+			err = d.compactSidecars(p, ".ttl", stems, report)
+		case dir == metaDir:
+			err = d.compactSidecars(p, ".meta", stems, report)
+		case strings.HasSuffix(dir, attachmentsSuffix):
+			if !stems[strings.TrimSuffix(dir, attachmentsSuffix)] {
+				err = d.compactRemove(p, true, &report.OrphanedSidecars, report)
+			} else {
+				err = d.compactSidecars(p, "", nil, report)
+			}
+		case validParentKey(dir) == nil:
+			err = d.compactRecordDir(p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	left, err := d.backend.List(collection)
+	if err != nil {
+		return err
+	}
+	if len(left) == 0 {
+		if err := d.backend.Delete(collection); err != nil {
+			return err
+		}
+		report.EmptyCollections++
+		d.forgetKeyCase(collection, "")
+		d.forgetUsage(collection)
+	}
+	return nil
+}
+
+// compactSidecars removes the temp files in dir and, with stems, the files
+// named by ext after a record that is not among them. dir itself goes if
+// nothing is left in it.
+func (d *Driver) compactSidecars(dir, ext string, stems map[string]bool, report *CompactReport) error {
+	files, err := d.backend.List(dir)
+	if err != nil {
+		return err
+	}
+	left := len(files)
+	for _, file := range files {
+		p := path.Join(dir, file)
+		if isDirName(file) {
+			continue
+		}
+		if strings.HasSuffix(file, ".tmp") {
+			err = d.compactRemove(p, false, &report.TempFiles, report)
+			left--
+		} else if stem, ok := strings.CutSuffix(file, ext); ok && stems != nil && !stems[stem] {
+			err = d.compactRemove(p, false, &report.OrphanedSidecars, report)
+			left--
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if left == 0 {
+		return d.backend.Delete(dir)
+	}
+	return nil
+}
+
+// compactRecordDir removes the directory of a record once it has no
+// sub-collections left.
+func (d *Driver) compactRecordDir(dir string) error {
+	files, err := d.backend.List(dir)
+	if err != nil || len(files) > 0 {
+		return err
+	}
+	return d.backend.Delete(dir)
+}
+
+// compactRemove deletes the file or, with dir, directory at p, counting it
+// in counter and its size in the reclaimed bytes.
+func (d *Driver) compactRemove(p string, dir bool, counter *int, report *CompactReport) error {
+	size, err := d.storedSize(p, dir)
+	if err != nil {
+		return err
+	}
+	if err := d.backend.Delete(p); err != nil && !isNotExist(err) {
+		return err
+	}
+	*counter++
+	report.ReclaimedBytes += size
+	return nil
+}
+
+// storedSize returns the bytes stored at p, a file or, with dir, a
+// directory.
+func (d *Driver) storedSize(p string, dir bool) (int64, error) {
+	if dir {
+		files, err := d.backend.List(p)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, file := range files {
+			size, err := d.storedSize(path.Join(p, strings.TrimSuffix(file, "/")), isDirName(file))
+			if err != nil {
+				return 0, err
+			}
+			total += size
+		}
+		return total, nil
+	}
+
+	if sb, ok := d.backend.(StatBackend); ok {
+		size, _, err := sb.Stat(p)
+		if isNotExist(err) {
+			return 0, nil
+		}
+		return size, err
+	}
+	b, err := d.backend.Get(p)
+	if isNotExist(err) {
+		return 0, nil
+	}
+	return int64(len(b)), err
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutAttachment("users", "ada", "avatar.png", strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteTTL("users", "gone", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	write := func(name, data string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("users/bob.json.tmp", "{")
+	write("users/.ttl/ghost.ttl", time.Now().Add(time.Hour).Format(time.RFC3339Nano))
+	write("users/ghost.attachments/a.txt", "orphan")
+	write("users/notes.txt", "left for Verify")
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TempFiles != 1 || report.ExpiredRecords != 1 || report.OrphanedSidecars != 2 || report.EmptyCollections != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.ReclaimedBytes < int64(len("{")+len("orphan")) {
+		t.Errorf("reclaimed %d bytes", report.ReclaimedBytes)
+	}
+
+	for _, name := range []string{"users/bob.json.tmp", "users/.ttl/ghost.ttl", "users/ghost.attachments", "empty"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left after compacting: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "notes.txt")); err != nil {
+		t.Errorf("unknown file removed: %v", err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Errorf("Read after compacting = %v, %v", v, err)
+	}
+	if got := readAttachment(t, d, "users", "ada", "avatar.png"); got != "png" {
+		t.Errorf("attachment after compacting = %q", got)
+	}
+
+	if report, err := d.Compact(); err != nil || *report != (CompactReport{}) {
+		t.Errorf("second Compact = %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+var compressions = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func (c Compression) ext() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+func (c Compression) valid() bool {
+	for _, v := range compressions {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+// compressStream compresses r on the fly. Closing the result stops the
+// compressor if the reader is abandoned early.
+func compressStream(c Compression, r io.Reader) *compressedStream {
+	if c == CompressionNone {
+		return &compressedStream{ReadCloser: io.NopCloser(r)}
+	}
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var w io.WriteCloser
+		var err error
+		switch c {
+		case CompressionGzip:
+			w = gzip.NewWriter(pw)
+		case CompressionZstd:
+			w, err = zstd.NewWriter(pw)
+		default:
+			err = fmt.Errorf("unknown compression %q", c)
+		}
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return &compressedStream{ReadCloser: pr, done: done}
+}
+
+// compressedStream is the output of compressStream. Closing it stops the
+// compression, and wait returns once that no longer reads the input.
+type compressedStream struct {
+	io.ReadCloser
+	done chan struct{}
+}
+
+func (s *compressedStream) wait() {
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+func compress(c Compression, b []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return b, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(b, nil), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", c)
+}
+
+// decompress picks the algorithm from the file name, so records written
+// under a different collection setting are still readable.
+func decompress(name string, b []byte) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(name, CompressionGzip.ext()):
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case strings.HasSuffix(name, CompressionZstd.ext()):
+		return zstdDecoder.DecodeAll(b, nil)
+	}
+	return b, nil
+}
+
+// getRecord returns the file currently holding the record at p (without
+// extension), whichever compression it was written with, and its contents.
+func (d *Driver) getRecord(p string) (string, []byte, error) {
+	for _, c := range compressions {
+		file := d.recordFile(p, c)
+		b, err := d.backend.Get(file)
+		if err == nil {
+			return file, b, nil
+		}
+		if !isNotExist(err) {
+			return "", nil, err
+		}
+	}
+	return "", nil, fs.ErrNotExist
+}
+
+// openRecord is getRecord for streaming reads; the caller closes the
+// returned reader.
+func (d *Driver) openRecord(p string) (string, io.ReadCloser, error) {
+	for _, c := range compressions {
+		file := d.recordFile(p, c)
+		rc, err := d.getStream(file)
+		if err == nil {
+			return file, rc, nil
+		}
+		if !isNotExist(err) {
+			return "", nil, err
+		}
+	}
+	return "", nil, fs.ErrNotExist
+}
+
+// decompressStream is decompress for readers.
+func decompressStream(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, CompressionGzip.ext()):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(name, CompressionZstd.ext()):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// removeRecordFiles deletes every variant of the record at p except keep
+// and reports how many were removed.
+func (d *Driver) removeRecordFiles(p string, keep string) (int, error) {
+	n := 0
+	for _, c := range compressions {
+		file := d.recordFile(p, c)
+		if file == keep {
+			continue
+		}
+		if err := d.deleteFile(file); err != nil {
+			if isNotExist(err) {
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MigrateCompression rewrites every record of the collection with the given
+// compression and uses it for all further writes to that collection.
+func (d *Driver) MigrateCompression(collection string, c Compression) error {
+	_, err := d.MigrateCompressionWith(collection, c, false)
+	return err
+}
+
+// MigrationReport describes what MigrateCompressionWith rewrote, or would
+// have rewritten on a dry run.
+type MigrationReport struct {
+	DryRun     bool   `json:"dryRun"`
+	Collection string `json:"collection"`
+	// Rewritten are the keys of the records stored with another
+	// compression.
+	Rewritten []string `json:"rewritten"`
+}
+
+// MigrateCompressionWith is MigrateCompression reporting the records it
+// rewrote. A dry run only reports them, and keeps the compression of the
+// collection as it is.
+func (d *Driver) MigrateCompressionWith(collection string, c Compression, dryRun bool) (*MigrationReport, error) {
+	return d.MigrateCompressionContext(context.Background(), collection, c, dryRun)
+}
+
+// MigrateCompressionContext is MigrateCompressionWith with a context
+// carrying the caller.
+func (d *Driver) MigrateCompressionContext(ctx context.Context, collection string, c Compression, dryRun bool) (*MigrationReport, error) {
+	var err error
+	if dryRun {
+		err = d.checkOpen()
+	} else {
+		err = d.checkWritable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - nothing to migrate")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if !c.valid() {
+		return nil, fmt.Errorf("unknown compression %q", c)
+	}
+	if err := d.authorizeContext(ctx, collection, PermReadWrite); err != nil {
+		return nil, err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !dryRun {
+		// Compressed sizes change, so usage is walked again on the next
+		// write.
+		defer d.forgetUsage(collection)
+
+		d.mutex.Lock()
+		opts := d.collections[collection]
+		opts.Compression = c
+		d.collections[collection] = opts
+		m, declared := d.manifests[collection]
+		d.mutex.Unlock()
+
+		// Or the manifest would bring the old compression back.
+		if declared && m.Compression != CompressionNone {
+			if m.Compression = c; c == CompressionNone {
+				m.Compression = "none"
+			}
+			if err := d.storeManifest(collection, m); err != nil {
+				return nil, err
+			}
+			d.mutex.Lock()
+			d.manifests[collection] = m
+			d.mutex.Unlock()
+		}
+	}
+
+	files, err := d.backend.List(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{DryRun: dryRun, Collection: collection, Rewritten: []string{}}
+	for _, name := range files {
+		stem, ok := d.recordStem(collection, name)
+		if isDirName(name) || !ok {
+			continue
+		}
+		base := path.Join(collection, stem)
+		fnlPath := d.recordFile(base, c)
+		if path.Join(collection, name) == fnlPath {
+			continue
+		}
+		if dryRun {
+			report.Rewritten = append(report.Rewritten, decodeKey(stem))
+			continue
+		}
+
+		b, err := d.backend.Get(path.Join(collection, name))
+		if err != nil {
+			return report, err
+		}
+		if b, err = d.decodeRecord(name, b); err != nil {
+			return report, err
+		}
+		if b, err = d.encodeRecord(c, b); err != nil {
+			return report, err
+		}
+
+		tmpPath := fnlPath + ".tmp"
+		if err := d.backend.Put(tmpPath, b); err != nil {
+			return report, err
+		}
+		if err := d.backend.Rename(tmpPath, fnlPath); err != nil {
+			return report, err
+		}
+		if _, err := d.removeRecordFiles(base, fnlPath); err != nil {
+			return report, err
+		}
+		report.Rewritten = append(report.Rewritten, decodeKey(stem))
+		d.log.Debug("Migrated '%s' to %s compression\n", path.Join(collection, name), c)
+	}
+	sort.Strings(report.Rewritten)
+	return report, nil
+}
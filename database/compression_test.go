@@ -0,0 +1,81 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{
+		Collections:      map[string]CollectionOptions{"logs": {Compression: CompressionGzip}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("logs", "a", map[string]string{"msg": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "a.json.gz")); err != nil {
+		t.Errorf("record of a gzip collection not gzipped: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "ada.json")); err != nil {
+		t.Errorf("record of another collection compressed: %v", err)
+	}
+	var v map[string]string
+	if err := d.Read("logs", "a", &v); err != nil || v["msg"] != "hello" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+}
+
+func TestMigrateCompression(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, key := range []string{"a", "b"} {
+		if err := d.Write("logs", key, map[string]string{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := d.MigrateCompressionWith("logs", CompressionZstd, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rewritten) != 2 {
+		t.Errorf("dry run would rewrite %v, want a and b", report.Rewritten)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "a.json")); err != nil {
+		t.Fatalf("dry run rewrote a: %v", err)
+	}
+
+	if err := d.MigrateCompression("logs", CompressionZstd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "a.json")); !os.IsNotExist(err) {
+		t.Errorf("uncompressed a left after migrating: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "a.json.zst")); err != nil {
+		t.Errorf("a not migrated to zstd: %v", err)
+	}
+	if err := d.Write("logs", "c", map[string]string{"key": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "c.json.zst")); err != nil {
+		t.Errorf("write after migrating not zstd: %v", err)
+	}
+	var v map[string]string
+	if err := d.Read("logs", "b", &v); err != nil || v["key"] != "b" {
+		t.Fatalf("Read after migrating = %v, %v", v, err)
+	}
+}
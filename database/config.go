@@ -0,0 +1,297 @@
+package database
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// ConfigEnvPrefix starts the names of the environment variables overriding
+// config files: OWNDB_ and the name of a top-level setting in upper case,
+// as OWNDB_DIR or OWNDB_READ_ONLY, and OWNDB_SERVER_ and a flag of the
+// dbserver command, as OWNDB_SERVER_ADDR. Lists are comma-separated.
+const ConfigEnvPrefix = "OWNDB_"
+
+// Config is what a config file sets, in YAML with a .yaml or .yml
+// extension or TOML with a .toml one, using the names in the tags of its
+// fields. Settings left out keep the defaults of Options.
+type Config struct {
+	// Dir is the database directory, relative to the config file.
+	Dir string `yaml:"dir" toml:"dir"`
+	// Backend opens a registered backend instead, as OpenBackend does.
+	Backend    string     `yaml:"backend" toml:"backend"`
+	Durability Durability `yaml:"durability" toml:"durability"`
+	// Codec names a registered codec, as LookupCodec does.
+	Codec     string `yaml:"codec" toml:"codec"`
+	Extension string `yaml:"extension" toml:"extension"`
+	// Compression compresses the collections that do not set their own.
+	Compression    Compression `yaml:"compression" toml:"compression"`
+	ReadOnly       bool        `yaml:"read_only" toml:"read_only"`
+	WatchExternal  bool        `yaml:"watch_external" toml:"watch_external"`
+	RecordMetadata bool        `yaml:"record_metadata" toml:"record_metadata"`
+
+	MaxRecordSize        int64         `yaml:"max_record_size" toml:"max_record_size"`
+	MinFreeSpace         int64         `yaml:"min_free_space" toml:"min_free_space"`
+	MmapThreshold        int64         `yaml:"mmap_threshold" toml:"mmap_threshold"`
+	MapReduceWorkers     int           `yaml:"map_reduce_workers" toml:"map_reduce_workers"`
+	QueueMaxAttempts     int           `yaml:"queue_max_attempts" toml:"queue_max_attempts"`
+	TTLSweepInterval     time.Duration `yaml:"ttl_sweep_interval" toml:"ttl_sweep_interval"`
+	SlowOpThreshold      time.Duration `yaml:"slow_op_threshold" toml:"slow_op_threshold"`
+	CounterFlushInterval time.Duration `yaml:"counter_flush_interval" toml:"counter_flush_interval"`
+	SeriesRetention      time.Duration `yaml:"series_retention" toml:"series_retention"`
+	IdempotencyTTL       time.Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl"`
+	Extensions           []string      `yaml:"extensions" toml:"extensions"`
+
+	Collections map[string]CollectionConfig `yaml:"collections" toml:"collections"`
+
+	// Server holds the settings of the dbserver command, by the names of
+	// its flags, as addr or grpc-addr.
+	Server map[string]any `yaml:"server" toml:"server"`
+}
+
+// CollectionConfig is what a config file sets for a collection.
+type CollectionConfig struct {
+	// Codec names a registered codec, as LookupCodec does, the top-level
+	// one by default.
+	Codec string `yaml:"codec" toml:"codec"`
+	// Compression is the top-level one by default, and none turns it off.
+	Compression         Compression `yaml:"compression" toml:"compression"`
+	MaxRecords          int         `yaml:"max_records" toml:"max_records"`
+	MaxBytes            int64       `yaml:"max_bytes" toml:"max_bytes"`
+	EncryptedFields     []string    `yaml:"encrypted_fields" toml:"encrypted_fields"`
+	DeterministicFields []string    `yaml:"deterministic_fields" toml:"deterministic_fields"`
+	// IDs is uuidv7, ulid or sequence.
+	IDs        string          `yaml:"ids" toml:"ids"`
+	Retention  RetentionConfig `yaml:"retention" toml:"retention"`
+	AppendOnly bool            `yaml:"append_only" toml:"append_only"`
+	TTL        time.Duration   `yaml:"ttl" toml:"ttl"`
+	// Schema is an expression, as Compile takes it, records must match.
+	Schema string `yaml:"schema" toml:"schema"`
+}
+
+type RetentionConfig struct {
+	MaxAge  time.Duration `yaml:"max_age" toml:"max_age"`
+	Field   string        `yaml:"field" toml:"field"`
+	Archive string        `yaml:"archive" toml:"archive"`
+}
+
+// NewFromConfig opens the database a config file describes, as LoadConfig
+// reads it.
+func NewFromConfig(path string) (*Driver, error) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if c.Dir == "" && c.Backend == "" {
+		return nil, fmt.Errorf("config %s sets no database directory", path)
+	}
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(c.Dir, opts)
+}
+
+// LoadConfig reads a config file, failing on settings it does not know,
+// and applies the environment variables overriding it.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), c)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config %s: unknown setting %s", path, undecoded[0])
+		}
+	default:
+		return nil, fmt.Errorf("config %s: unknown format %q - must be .yaml, .yml or .toml", path, ext)
+	}
+	if c.Dir != "" && !filepath.IsAbs(c.Dir) {
+		c.Dir = filepath.Join(filepath.Dir(path), c.Dir)
+	}
+	if err := c.applyEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// applyEnv overrides the config with the variables of environ starting
+// with ConfigEnvPrefix.
+func (c *Config) applyEnv(environ []string) error {
+	v := reflect.ValueOf(c).Elem()
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if v.Field(i).Kind() != reflect.Map {
+			fields[strings.ToUpper(name)] = v.Field(i)
+		}
+	}
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, ConfigEnvPrefix)
+		if !ok {
+			continue
+		}
+		if flag, ok := strings.CutPrefix(name, "SERVER_"); ok {
+			if c.Server == nil {
+				c.Server = make(map[string]any)
+			}
+			c.Server[strings.ReplaceAll(strings.ToLower(flag), "_", "-")] = value
+			continue
+		}
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown setting in environment variable %s", key)
+		}
+		if err := setConfigField(field, value); err != nil {
+			return fmt.Errorf("invalid environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setConfigField(field reflect.Value, s string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if _, ok := field.Interface().(time.Duration); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Options returns the Options the config sets, looking up its codec and
+// opening its backend.
+func (c *Config) Options() (*Options, error) {
+	opts := &Options{
+		Extension:            c.Extension,
+		Durability:           c.Durability,
+		Compression:          c.Compression,
+		ReadOnly:             c.ReadOnly,
+		WatchExternal:        c.WatchExternal,
+		RecordMetadata:       c.RecordMetadata,
+		MaxRecordSize:        c.MaxRecordSize,
+		MinFreeSpace:         c.MinFreeSpace,
+		MmapThreshold:        c.MmapThreshold,
+		MapReduceWorkers:     c.MapReduceWorkers,
+		QueueMaxAttempts:     c.QueueMaxAttempts,
+		TTLSweepInterval:     c.TTLSweepInterval,
+		SlowOpThreshold:      c.SlowOpThreshold,
+		CounterFlushInterval: c.CounterFlushInterval,
+		SeriesRetention:      c.SeriesRetention,
+		IdempotencyTTL:       c.IdempotencyTTL,
+		Extensions:           c.Extensions,
+	}
+	if c.Codec != "" {
+		codec, err := LookupCodec(c.Codec)
+		if err != nil {
+			return nil, err
+		}
+		opts.Codec = codec
+	}
+	if len(c.Collections) > 0 {
+		opts.Collections = make(map[string]CollectionOptions, len(c.Collections))
+		for name, cc := range c.Collections {
+			o, err := cc.options(name, c.Compression)
+			if err != nil {
+				return nil, err
+			}
+			opts.Collections[name] = o
+		}
+	}
+	if c.Backend != "" {
+		b, err := OpenBackend(c.Backend)
+		if err != nil {
+			return nil, err
+		}
+		opts.Backend = b
+	}
+	return opts, nil
+}
+
+func (cc CollectionConfig) options(name string, compression Compression) (CollectionOptions, error) {
+	o := CollectionOptions{
+		Compression:         cc.Compression,
+		Quota:               Quota{MaxRecords: cc.MaxRecords, MaxBytes: cc.MaxBytes},
+		EncryptedFields:     cc.EncryptedFields,
+		DeterministicFields: cc.DeterministicFields,
+		Retention:           Retention{MaxAge: cc.Retention.MaxAge, Field: cc.Retention.Field, Archive: cc.Retention.Archive},
+		AppendOnly:          cc.AppendOnly,
+		TTL:                 cc.TTL,
+	}
+	switch o.Compression {
+	case CompressionNone:
+		o.Compression = compression
+	case "none":
+		o.Compression = CompressionNone
+	}
+	var err error
+	if o.IDs, err = parseIDScheme(cc.IDs, name); err != nil {
+		return CollectionOptions{}, err
+	}
+	if cc.Codec != "" {
+		if o.Codec, err = LookupCodec(cc.Codec); err != nil {
+			return CollectionOptions{}, fmt.Errorf("codec of collection %s: %w", name, err)
+		}
+	}
+	if cc.Schema != "" {
+		if o.Schema, err = Compile(cc.Schema); err != nil {
+			return CollectionOptions{}, fmt.Errorf("schema of collection %s: %w", name, err)
+		}
+	}
+	return o, nil
+}
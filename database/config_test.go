@@ -0,0 +1,139 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file named name in a new directory.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadConfig(t *testing.T) {
+	p := writeConfig(t, "owndb.yaml", `
+dir: data
+durability: sync
+compression: gzip
+ttl_sweep_interval: 2m
+collections:
+  users:
+    max_records: 10
+    ids: ulid
+server:
+  addr: ":9090"
+  auth: [a, b]
+`)
+	t.Setenv("OWNDB_SLOW_OP_THRESHOLD", "150ms")
+	t.Setenv("OWNDB_SERVER_GRPC_ADDR", ":9000")
+	c, err := LoadConfig(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(filepath.Dir(p), "data"); c.Dir != want {
+		t.Errorf("Dir = %s, want %s relative to the config file", c.Dir, want)
+	}
+	if c.Durability != DurabilitySync || c.Compression != CompressionGzip || c.TTLSweepInterval != 2*time.Minute {
+		t.Errorf("Config = %+v", c)
+	}
+	if users := c.Collections["users"]; users.MaxRecords != 10 || users.IDs != "ulid" {
+		t.Errorf("users config = %+v", users)
+	}
+	if c.SlowOpThreshold != 150*time.Millisecond || c.Server["grpc-addr"] != ":9000" {
+		t.Errorf("environment ignored: slow op threshold %v, server %v", c.SlowOpThreshold, c.Server)
+	}
+	if c.Server["addr"] != ":9090" {
+		t.Errorf("server settings = %v", c.Server)
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	p := writeConfig(t, "owndb.toml", `
+dir = "data"
+codec = "json"
+
+[collections.users]
+compression = "zstd"
+
+[collections.users.retention]
+max_age = "24h"
+
+[server]
+rate = 5
+`)
+	c, err := LoadConfig(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := c.Collections["users"]
+	if c.Codec != "json" || users.Compression != CompressionZstd || users.Retention.MaxAge != 24*time.Hour {
+		t.Errorf("Config = %+v", c)
+	}
+	if _, err := c.Options(); err != nil {
+		t.Errorf("Options = %v", err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, c := range []struct {
+		name, content, want string
+	}{
+		{"owndb.yaml", "bogus: 1\n", "bogus"},
+		{"owndb.toml", "bogus = 1\n", "unknown setting bogus"},
+		{"owndb.yaml", "durability: never\n", "unknown durability"},
+		{"owndb.json", "{}", "unknown format"},
+	} {
+		if _, err := LoadConfig(writeConfig(t, c.name, c.content)); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("LoadConfig of %s %q = %v, want an error about %s", c.name, c.content, err, c.want)
+		}
+	}
+
+	t.Setenv("OWNDB_NOPE", "1")
+	if _, err := LoadConfig(writeConfig(t, "owndb.yaml", "")); err == nil || !strings.Contains(err.Error(), "OWNDB_NOPE") {
+		t.Errorf("LoadConfig with an unknown environment variable = %v", err)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	p := writeConfig(t, "owndb.yaml", `
+dir: data
+durability: sync
+compression: gzip
+collections:
+  plain:
+    compression: none
+`)
+	d, err := NewFromConfig(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, collection := range []string{"users", "plain"} {
+		if err := d.Write(collection, "ada", map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(filepath.Dir(p), "data")
+	for _, f := range []string{"users/ada.json.gz", "plain/ada.json"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("record not written as %s: %v", f, err)
+		}
+	}
+
+	if _, err := NewFromConfig(writeConfig(t, "owndb.yaml", "compression: gzip\n")); err == nil {
+		t.Error("NewFromConfig opened a config without a directory")
+	}
+	p = writeConfig(t, "owndb.yaml", "dir: other\ncollections:\n  users:\n    codec: nope\n")
+	if _, err := NewFromConfig(p); err == nil || !strings.Contains(err.Error(), "codec of collection users") {
+		t.Errorf("NewFromConfig of an unknown collection codec = %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Counters live in one file at the root, encrypted like records when
+	// the database is.
+	countersFile = ".counters.json"
+	// countersCollection is what roles grant access to counters as.
+	countersCollection = ".counters"
+
+	defaultCounterFlushInterval = time.Second
+)
+
+// Counter is a named 64-bit integer that is cheap to change. Counters are
+// kept in memory and written out together every
+// Options.CounterFlushInterval and on Close, so a crash loses at most the
+// changes of the last interval.
+type Counter struct {
+	d    *Driver
+	name string
+}
+
+// counters holds the values of every counter once they are loaded.
+type counters struct {
+	mutex  sync.Mutex
+	values map[string]int64
+	dirty  bool
+	// always flushes after every change, for a negative flush interval.
+	always bool
+}
+
+func (d *Driver) Counter(name string) *Counter {
+	return &Counter{d: d, name: name}
+}
+
+func (c *Counter) Name() string {
+	return c.name
+}
+
+// Add adds delta, which may be negative, to the counter and returns its new
+// value. A counter that was never added to is zero.
+func (c *Counter) Add(delta int64) (int64, error) {
+	return c.AddContext(context.Background(), delta)
+}
+
+// AddContext is Add with a context carrying the caller.
+func (c *Counter) AddContext(ctx context.Context, delta int64) (int64, error) {
+	if err := c.d.checkWritable(); err != nil {
+		return 0, err
+	}
+	if err := c.check(ctx, PermWrite); err != nil {
+		return 0, err
+	}
+
+	cs := &c.d.counts
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if err := c.d.loadCounters(); err != nil {
+		return 0, err
+	}
+	cs.values[c.name] += delta
+	cs.dirty = true
+	if cs.always {
+		if err := c.d.saveCounters(); err != nil {
+			return 0, err
+		}
+	}
+	return cs.values[c.name], nil
+}
+
+// Get returns the value of the counter, including changes not written out
+// yet.
+func (c *Counter) Get() (int64, error) {
+	return c.GetContext(context.Background())
+}
+
+// GetContext is Get with a context carrying the caller.
+func (c *Counter) GetContext(ctx context.Context) (int64, error) {
+	if err := c.d.checkOpen(); err != nil {
+		return 0, err
+	}
+	if err := c.check(ctx, PermRead); err != nil {
+		return 0, err
+	}
+
+	cs := &c.d.counts
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if err := c.d.loadCounters(); err != nil {
+		return 0, err
+	}
+	return cs.values[c.name], nil
+}
+
+func (c *Counter) check(ctx context.Context, p Permission) error {
+	if err := validName("counter", c.name); err != nil {
+		return err
+	}
+	return c.d.authorizeContext(ctx, countersCollection, p)
+}
+
+// Counters returns the names of the counters, sorted.
+func (d *Driver) Counters() ([]string, error) {
+	return d.CountersContext(context.Background())
+}
+
+// CountersContext is Counters with a context carrying the caller.
+func (d *Driver) CountersContext(ctx context.Context) ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, countersCollection, PermRead); err != nil {
+		return nil, err
+	}
+
+	cs := &d.counts
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if err := d.loadCounters(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cs.values))
+	for name := range cs.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadCounters reads the counters the first time they are needed; callers
+// hold the counters lock.
+func (d *Driver) loadCounters() error {
+	cs := &d.counts
+	if cs.values != nil {
+		return nil
+	}
+	values := make(map[string]int64)
+	b, err := d.backend.Get(countersFile)
+	if err != nil && !isNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if b, err = d.decrypt(b); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &values); err != nil {
+			return fmt.Errorf("reading counters: %w", err)
+		}
+	}
+	cs.values = values
+	return nil
+}
+
+// saveCounters atomically replaces the counters file; callers hold the
+// counters lock.
+func (d *Driver) saveCounters() error {
+	cs := &d.counts
+	b, err := json.MarshalIndent(cs.values, "", "\t")
+	if err != nil {
+		return err
+	}
+	if b, err = d.encrypt(append(b, '\n')); err != nil {
+		return err
+	}
+	tmpPath := countersFile + ".tmp"
+	if err := d.backend.Put(tmpPath, b); err != nil {
+		return err
+	}
+	if err := d.backend.Rename(tmpPath, countersFile); err != nil {
+		return err
+	}
+	cs.dirty = false
+	return nil
+}
+
+// flushCounters writes the counters out if they changed since they last
+// were.
+func (d *Driver) flushCounters() error {
+	cs := &d.counts
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if !cs.dirty {
+		return nil
+	}
+	return d.saveCounters()
+}
+
+// startCounters flushes the counters every interval until the Driver is
+// closed, and once more then. A negative interval flushes every change as
+// it is made instead.
+func (d *Driver) startCounters(interval time.Duration) {
+	d.onClose(d.flushCounters)
+	if interval < 0 {
+		d.counts.always = true
+		return
+	}
+	d.goBackground(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := d.flushCounters(); err != nil {
+					d.logEvent(slog.LevelError, "Flushing counters failed", slog.Any("error", err))
+				}
+			}
+		}
+	})
+}
+
+// reencryptCounters rewrites the counters with the current data key.
+func (d *Driver) reencryptCounters() error {
+	cs := &d.counts
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if _, err := d.backend.Get(countersFile); isNotExist(err) {
+		return nil
+	}
+	if err := d.loadCounters(); err != nil {
+		return err
+	}
+	return d.saveCounters()
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{MasterKey: testMasterKey, CounterFlushInterval: 20 * time.Millisecond, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	views := d.Counter("page_views")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := views.Add(1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := views.Get(); err != nil || v != 10000 {
+		t.Fatalf("Get = %d, %v; want 10000", v, err)
+	}
+	if v, err := d.Counter("stock").Add(-3); err != nil || v != -3 {
+		t.Fatalf("Add(-3) = %d, %v", v, err)
+	}
+	if _, err := d.Counter("").Add(1); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Add to a counter without a name = %v, want ErrInvalidName", err)
+	}
+
+	// Rotating rewrites the counters, and closing flushes the last changes.
+	d = rotate(t, d, dir)
+	if v, err := d.Counter("page_views").Get(); err != nil || v != 10000 {
+		t.Errorf("Get after reopening = %d, %v; want 10000", v, err)
+	}
+	if v, err := d.Counter("stock").Add(1); err != nil || v != -2 {
+		t.Errorf("Add after reopening = %d, %v; want -2", v, err)
+	}
+	if names, err := d.Counters(); err != nil || len(names) != 2 || names[0] != "page_views" || names[1] != "stock" {
+		t.Errorf("Counters = %q, %v", names, err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := New(dir, &Options{MasterKey: testNextKey, ReadOnly: true, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if v, err := ro.Counter("stock").Get(); err != nil || v != -2 {
+		t.Errorf("Get of a read-only database = %d, %v; want -2", v, err)
+	}
+	if _, err := ro.Counter("stock").Add(1); err == nil {
+		t.Error("Add to a read-only database succeeded")
+	}
+}
+
+func TestCountersFlushEveryChange(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{CounterFlushInterval: -1, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Counter("hits").Add(5); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, countersFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values map[string]int64
+	if err := json.Unmarshal(b, &values); err != nil || values["hits"] != 5 {
+		t.Errorf("counters file = %s, %v; want the change written out", b, err)
+	}
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a scan of a collection's records in key order,
+// which FindPage moves along. It encodes as an opaque string a batch job
+// can checkpoint and resume from after a restart, in another process too:
+// every record present when the scan started is visited once unless it is
+// deleted first, however writes go on in the meantime. The zero Cursor
+// starts a scan.
+type Cursor struct {
+	// Collection is the collection scanned, empty before the scan starts.
+	Collection string
+	// After is the key of the last record visited, empty before the first.
+	After string
+	// Until is the snapshot the scan started on: the last key the
+	// collection held then. Records added since with later keys are left
+	// out, so scanning a collection that grows at the end, as it does with
+	// the keys of Insert, finishes.
+	Until string
+	// Done reports that the scan visited every record.
+	Done bool
+}
+
+// cursorVersion is the version of the encoding of cursors.
+const cursorVersion = 1
+
+type encodedCursor struct {
+	Version    int    `json:"v"`
+	Collection string `json:"c"`
+	After      string `json:"a,omitempty"`
+	Until      string `json:"u,omitempty"`
+	Done       bool   `json:"d,omitempty"`
+}
+
+// String encodes the cursor for ParseCursor.
+func (c Cursor) String() string {
+	b, _ := c.MarshalText()
+	return string(b)
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	if c.Collection == "" {
+		return []byte{}, nil
+	}
+	b, err := json.Marshal(encodedCursor{Version: cursorVersion, Collection: c.Collection, After: c.After, Until: c.Until, Done: c.Done})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, base64.RawURLEncoding.EncodedLen(len(b)))
+	base64.RawURLEncoding.Encode(out, b)
+	return out, nil
+}
+
+func (c *Cursor) UnmarshalText(text []byte) error {
+	parsed, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// ParseCursor decodes a cursor from its String, "" being the zero Cursor.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var e encodedCursor
+	if err := json.Unmarshal(b, &e); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if e.Version != cursorVersion {
+		return Cursor{}, fmt.Errorf("%w: unknown version %d", ErrInvalidCursor, e.Version)
+	}
+	if err := validCollection(e.Collection); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	return Cursor{Collection: e.Collection, After: e.After, Until: e.Until, Done: e.Done}, nil
+}
+
+// FindPage runs a query from a cursor, returning up to q.Limit results,
+// all that are left for a limit of zero, and the cursor to go on from.
+// Results are in key order, and the records q does not match are skipped
+// over as they are visited. Once the returned cursor is Done, the scan is
+// over.
+func (d *Driver) FindPage(q Query, c Cursor) ([]QueryResult, Cursor, error) {
+	return d.FindPageContext(context.Background(), q, c)
+}
+
+// FindPageContext is FindPage with a context to trace the operation in.
+func (d *Driver) FindPageContext(ctx context.Context, q Query, c Cursor) (results []QueryResult, next Cursor, err error) {
+	op := d.startOp(ctx, "find", q.Collection, "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, c, err
+	}
+	if err := q.validate(); err != nil {
+		return nil, c, err
+	}
+	if c.Collection != "" && c.Collection != q.Collection {
+		return nil, c, fmt.Errorf("%w: cursor of collection %s used to query %s", ErrInvalidCursor, c.Collection, q.Collection)
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermRead); err != nil {
+		return nil, c, err
+	}
+
+	match, err := q.matcher()
+	if err != nil {
+		return nil, c, err
+	}
+	withMeta := q.usesMeta()
+	keys, err := d.liveKeys(q.Collection)
+	if err != nil {
+		return nil, c, err
+	}
+	sort.Strings(keys)
+
+	next = c
+	if next.Collection == "" {
+		next.Collection = q.Collection
+		if len(keys) == 0 {
+			next.Done = true
+		} else {
+			next.Until = keys[len(keys)-1]
+		}
+	}
+	results = []QueryResult{}
+	if next.Done {
+		return results, next, nil
+	}
+
+	start := sort.SearchStrings(keys, next.After)
+	if start < len(keys) && keys[start] == next.After {
+		start++
+	}
+	end := sort.SearchStrings(keys, next.Until)
+	if end < len(keys) && keys[end] == next.Until {
+		end++
+	}
+	for _, key := range keys[start:max(start, end)] {
+		if q.Limit > 0 && len(results) == q.Limit {
+			return results, next, nil
+		}
+		next.After = key
+		b, err := d.readRecord(q.Collection, key)
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, c, err
+		}
+		op.addBytes(int64(len(b)))
+		doc, err := d.decodeDocument(q.Collection, b)
+		if err != nil {
+			return nil, c, err
+		}
+		if withMeta {
+			if doc, err = d.addMeta(doc, q.Collection, key, b); err != nil {
+				return nil, c, err
+			}
+		}
+		if !match(doc) {
+			continue
+		}
+		if q.Fields != nil {
+			doc = project(doc, q.Fields)
+		} else if withMeta {
+			delete(doc, metaField)
+		}
+		results = append(results, QueryResult{Key: key, Value: doc})
+	}
+	next.Done = true
+	return results, next, nil
+}
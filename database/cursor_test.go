@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindPage(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := d.Write("jobs", fmt.Sprintf("k%02d", i), map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := Query{Collection: "jobs", Limit: 3}
+	var (
+		c    Cursor
+		keys []string
+	)
+	for pages := 0; !c.Done; pages++ {
+		if pages > 5 {
+			t.Fatal("scan never ended")
+		}
+		// Resume from the encoded cursor, as a job restarting would.
+		if c, err = ParseCursor(c.String()); err != nil {
+			t.Fatal(err)
+		}
+		var results []QueryResult
+		if results, c, err = d.FindPage(q, c); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			keys = append(keys, r.Key)
+		}
+		if pages == 1 {
+			// A record past the end of the scan, one before the cursor
+			// and one deleted ahead of it.
+			for _, key := range []string{"k99", "k00a"} {
+				if err := d.Write("jobs", key, map[string]int{"n": 99}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.Delete("jobs", "k07"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := strings.Join(keys, ","); got != "k00,k01,k02,k03,k04,k05,k06,k08,k09" {
+		t.Errorf("scan visited %s", got)
+	}
+
+	if results, _, err := d.FindPage(q, c); len(results) != 0 || err != nil {
+		t.Errorf("FindPage from a done cursor = %v, %v; want no results", results, err)
+	}
+	if _, _, err := d.FindPage(Query{Collection: "other"}, c); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("FindPage with the cursor of another collection = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := ParseCursor("garbage!"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ParseCursor of garbage = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestFindPageFilter(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := 0; i < 6; i++ {
+		if err := d.Write("jobs", fmt.Sprintf("k%d", i), map[string]bool{"even": i%2 == 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, c, err := d.FindPage(Query{Collection: "jobs", Filter: "doc.even"}, Cursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || !c.Done {
+		t.Errorf("FindPage without a limit = %d results, done %v; want all 3 matches, done", len(results), c.Done)
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import "context"
+
+// Database is the part of the Driver a remote client offers too, for code
+// that should not care which it gets, and for unit tests to replace with
+// the mock and no-op implementations of package dbmock.
+type Database interface {
+	Write(collection, resource string, v interface{}) error
+	WriteContext(ctx context.Context, collection, resource string, v interface{}) error
+	Read(collection, resource string, v interface{}) error
+	ReadContext(ctx context.Context, collection, resource string, v interface{}) error
+	ReadResolved(collection, resource string, v interface{}, depth int) error
+	ReadResolvedContext(ctx context.Context, collection, resource string, v interface{}, depth int) error
+	ReadAll(collection string) ([]string, error)
+	ReadAllContext(ctx context.Context, collection string) ([]string, error)
+	ReadAllResolved(collection string, depth int) ([]string, error)
+	ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error)
+	Keys(collection string) ([]string, error)
+	KeysContext(ctx context.Context, collection string) ([]string, error)
+	Delete(collection, resource string) error
+	DeleteContext(ctx context.Context, collection, resource string) error
+	DropCollection(ctx context.Context, collection string, dryRun bool) (*DeleteReport, error)
+
+	ReadRevision(collection, resource string, v interface{}) (string, error)
+	ReadRevisionContext(ctx context.Context, collection, resource string, v interface{}) (string, error)
+	WriteIfRevision(collection, resource string, v interface{}, rev string) (string, error)
+	WriteIfRevisionContext(ctx context.Context, collection, resource string, v interface{}, rev string) (string, error)
+	DeleteIfRevision(collection, resource, rev string) error
+	DeleteIfRevisionContext(ctx context.Context, collection, resource, rev string) error
+
+	Aggregate(collection string, pipeline []Stage) ([]map[string]interface{}, error)
+	AggregateContext(ctx context.Context, collection string, pipeline []Stage) ([]map[string]interface{}, error)
+
+	SaveQuery(name string, q Query) error
+	SaveQueryContext(ctx context.Context, name string, q Query) error
+	SavedQuery(name string) (Query, error)
+	SavedQueryContext(ctx context.Context, name string) (Query, error)
+	SavedQueries() ([]string, error)
+	SavedQueriesContext(ctx context.Context) ([]string, error)
+	DeleteQuery(name string) error
+	DeleteQueryContext(ctx context.Context, name string) error
+	RunQuery(name string) ([]QueryResult, error)
+	RunQueryContext(ctx context.Context, name string) ([]QueryResult, error)
+
+	Watch(collection string) (events <-chan Event, stop func(), err error)
+	WatchContext(ctx context.Context, collection string) (events <-chan Event, stop func(), err error)
+}
+
+var _ Database = (*Driver)(nil)
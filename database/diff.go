@@ -0,0 +1,251 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// DiffReport is how one database differs from another, record by record.
+type DiffReport struct {
+	// Added are the records only the second database holds, Removed those
+	// only the first does, and Changed those both hold with other values.
+	Added   []DiffRecord `json:"added"`
+	Removed []DiffRecord `json:"removed"`
+	Changed []DiffRecord `json:"changed"`
+}
+
+// DiffRecord is a record that differs between two databases.
+type DiffRecord struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	// Value is the record added or removed.
+	Value json.RawMessage `json:"value,omitempty"`
+	// Fields are the changes to the fields of a changed record.
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is a field that differs between two versions of a record. Path
+// is dotted, with array elements named by their index, as in
+// "address.city" or "tags.2", and empty when the records are not both
+// objects. Old is empty for a field added and New for one removed.
+type FieldDiff struct {
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// Diff compares the databases in dirA and dirB, opened read only, and
+// reports the changes from A to B.
+func Diff(dirA, dirB string) (*DiffReport, error) {
+	a, err := New(dirA, &Options{ReadOnly: true, TTLSweepInterval: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	b, err := New(dirB, &Options{ReadOnly: true, TTLSweepInterval: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return a.Diff(b)
+}
+
+// Diff reports the changes from this database to other, in every
+// collection including namespaced and sub-collections, ordered by
+// collection and key. Like Export it needs the JSON codec. Comparing a
+// database with a Restore of a Backup of it answers what changed since.
+func (d *Driver) Diff(other *Driver) (*DiffReport, error) {
+	return d.DiffContext(context.Background(), other)
+}
+
+// DiffContext is Diff with a context to trace the operation in.
+func (d *Driver) DiffContext(ctx context.Context, other *Driver) (report *DiffReport, err error) {
+	op := d.startOp(ctx, "diff", "", "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := other.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, "*", PermRead); err != nil {
+		return nil, err
+	}
+	ours, err := d.collectionPaths()
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := other.collectionPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	report = &DiffReport{Added: []DiffRecord{}, Removed: []DiffRecord{}, Changed: []DiffRecord{}}
+	for _, collection := range union(ours, theirs) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := d.diffCollection(ctx, other, collection, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (d *Driver) diffCollection(ctx context.Context, other *Driver, collection string, report *DiffReport) error {
+	ours, err := d.liveKeys(collection)
+	if err != nil && !isNotExist(err) {
+		return err
+	}
+	theirs, err := other.liveKeys(collection)
+	if err != nil && !isNotExist(err) {
+		return err
+	}
+	for _, key := range union(ours, theirs) {
+		a, err := readCompact(ctx, d, collection, key)
+		if err != nil && !isNotExist(err) {
+			return err
+		}
+		b, err := readCompact(ctx, other, collection, key)
+		if err != nil && !isNotExist(err) {
+			return err
+		}
+		switch {
+		case a == nil && b == nil:
+			// Expired since it was listed.
+		case a == nil:
+			report.Added = append(report.Added, DiffRecord{Collection: collection, Key: key, Value: b})
+		case b == nil:
+			report.Removed = append(report.Removed, DiffRecord{Collection: collection, Key: key, Value: a})
+		case !bytes.Equal(a, b):
+			fields, err := diffFields(a, b)
+			if err != nil {
+				return err
+			}
+			if len(fields) > 0 {
+				report.Changed = append(report.Changed, DiffRecord{Collection: collection, Key: key, Fields: fields})
+			}
+		}
+	}
+	return nil
+}
+
+// diffFields returns the fields that differ between two JSON documents.
+func diffFields(a, b json.RawMessage) ([]FieldDiff, error) {
+	var old, new interface{}
+	if err := decodeExact(a, &old); err != nil {
+		return nil, err
+	}
+	if err := decodeExact(b, &new); err != nil {
+		return nil, err
+	}
+	var fields []FieldDiff
+	return fields, diffValue("", old, new, &fields)
+}
+
+func diffValue(path string, old, new interface{}, fields *[]FieldDiff) error {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			names := make([]string, 0, len(o)+len(n))
+			for name := range o {
+				names = append(names, name)
+			}
+			for name := range n {
+				names = append(names, name)
+			}
+			for _, name := range union(names) {
+				if err := diffMember(joinPath(path, name), o, n, name, fields); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			for i := 0; i < max(len(o), len(n)); i++ {
+				p := joinPath(path, strconv.Itoa(i))
+				switch {
+				case i >= len(n):
+					if err := addFieldDiff(fields, p, o[i], nil, true, false); err != nil {
+						return err
+					}
+				case i >= len(o):
+					if err := addFieldDiff(fields, p, nil, n[i], false, true); err != nil {
+						return err
+					}
+				default:
+					if err := diffValue(p, o[i], n[i], fields); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+	}
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	return addFieldDiff(fields, path, old, new, true, true)
+}
+
+func diffMember(path string, old, new map[string]interface{}, name string, fields *[]FieldDiff) error {
+	o, inOld := old[name]
+	n, inNew := new[name]
+	if inOld && inNew {
+		return diffValue(path, o, n, fields)
+	}
+	return addFieldDiff(fields, path, o, n, inOld, inNew)
+}
+
+func addFieldDiff(fields *[]FieldDiff, path string, old, new interface{}, hasOld, hasNew bool) error {
+	f := FieldDiff{Path: path}
+	var err error
+	if hasOld {
+		if f.Old, err = json.Marshal(old); err != nil {
+			return err
+		}
+	}
+	if hasNew {
+		if f.New, err = json.Marshal(new); err != nil {
+			return err
+		}
+	}
+	*fields = append(*fields, f)
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// decodeExact decodes JSON keeping numbers exact.
+func decodeExact(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// union returns the distinct strings of lists, sorted.
+func union(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var all []string
+	for _, list := range lists {
+		for _, s := range list {
+			if !seen[s] {
+				seen[s] = true
+				all = append(all, s)
+			}
+		}
+	}
+	sort.Strings(all)
+	return all
+}
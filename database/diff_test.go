@@ -0,0 +1,77 @@
+package database
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	root := t.TempDir()
+	for name, records := range map[string]map[string]string{
+		"a": {
+			"same":    `{"n":1}`,
+			"gone":    `{"n":2}`,
+			"changed": `{"name":"Ada","address":{"city":"London"},"tags":["a","b"],"age":36}`,
+			"number":  `7`,
+		},
+		"b": {
+			"same":    `{"n":1}`,
+			"new":     `{"n":3}`,
+			"changed": `{"name":"Ada","address":{"city":"Karachi"},"tags":["a"],"email":"ada@example.org","age":36.5}`,
+			"number":  `8`,
+		},
+	} {
+		d, err := New(filepath.Join(root, name), &Options{TTLSweepInterval: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, v := range records {
+			if err := d.Write("users", key, json.RawMessage(v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d.Close()
+	}
+
+	r, err := Diff(filepath.Join(root, "a"), filepath.Join(root, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Added) != 1 || r.Added[0].Key != "new" || string(r.Added[0].Value) != `{"n":3}` {
+		t.Errorf("Added = %+v, want users/new", r.Added)
+	}
+	if len(r.Removed) != 1 || r.Removed[0].Key != "gone" || string(r.Removed[0].Value) != `{"n":2}` {
+		t.Errorf("Removed = %+v, want users/gone", r.Removed)
+	}
+	if len(r.Changed) != 2 || r.Changed[0].Key != "changed" || r.Changed[1].Key != "number" {
+		t.Fatalf("Changed = %+v, want users/changed and users/number", r.Changed)
+	}
+	want := []FieldDiff{
+		{Path: "address.city", Old: json.RawMessage(`"London"`), New: json.RawMessage(`"Karachi"`)},
+		{Path: "age", Old: json.RawMessage(`36`), New: json.RawMessage(`36.5`)},
+		{Path: "email", New: json.RawMessage(`"ada@example.org"`)},
+		{Path: "tags.1", Old: json.RawMessage(`"b"`)},
+	}
+	if got := r.Changed[0].Fields; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed fields = %s, want %s", jsonString(got), jsonString(want))
+	}
+	if got := r.Changed[1].Fields; len(got) != 1 || got[0].Path != "" || string(got[0].Old) != "7" || string(got[0].New) != "8" {
+		t.Errorf("fields of a changed number = %s, want one without a path", jsonString(got))
+	}
+
+	d, err := New(filepath.Join(root, "a"), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if r, err := d.Diff(d); err != nil || len(r.Added)+len(r.Removed)+len(r.Changed) != 0 {
+		t.Errorf("Diff of a database with itself = %+v, %v; want no changes", r, err)
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
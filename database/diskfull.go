@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var ErrDiskFull = errors.New("disk full")
+
+// preflightSize is the smallest write checked for free space beforehand,
+// unless Options.MinFreeSpace asks for headroom. Smaller writes running out
+// of space are still caught by the file system.
+const preflightSize = 1 << 20
+
+// SpaceBackend is implemented by backends that can report how many bytes
+// their storage has free for new data, failing with errors.ErrUnsupported
+// where the platform cannot tell.
+type SpaceBackend interface {
+	Available() (int64, error)
+}
+
+// DiskFullError reports storage without room for a write or backup,
+// found by checking before it started or by the file system running out
+// part way. Either way the temp file of the write is removed and the
+// record it would have replaced is left as it was. It matches ErrDiskFull.
+type DiskFullError struct {
+	Path string
+	// Needed and Available are the bytes a check beforehand wanted and
+	// found, both zero when the file system ran out.
+	Needed    int64
+	Available int64
+	// Err is the file system's error, nil when found beforehand.
+	Err error
+}
+
+func (e *DiskFullError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("disk full writing %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("disk full - writing %s needs %d bytes free, %d available", e.Path, e.Needed, e.Available)
+}
+
+func (e *DiskFullError) Is(target error) bool {
+	return target == ErrDiskFull
+}
+
+func (e *DiskFullError) Unwrap() error {
+	return e.Err
+}
+
+func (f *fileBackend) Available() (int64, error) {
+	return freeSpace(f.root)
+}
+
+// checkSpace fails a write of size bytes to p with a DiskFullError if it
+// would leave less than Options.MinFreeSpace free. The size is taken
+// before compression, which errs on the side of failing.
+func (d *Driver) checkSpace(p string, size int64) error {
+	if size < preflightSize && d.minFreeSpace <= 0 {
+		return nil
+	}
+	sb, ok := d.backend.(SpaceBackend)
+	if !ok {
+		return nil
+	}
+	free, err := sb.Available()
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if free-size < d.minFreeSpace {
+		return d.diskFull(&DiskFullError{Path: p, Needed: size + d.minFreeSpace, Available: free})
+	}
+	return nil
+}
+
+// noSpace turns an error of the file system running out of space writing
+// p into a DiskFullError, and returns others as they are.
+func (d *Driver) noSpace(p string, err error) error {
+	if err == nil || !isNoSpace(err) {
+		return err
+	}
+	return d.diskFull(&DiskFullError{Path: p, Err: err})
+}
+
+func (d *Driver) diskFull(err *DiskFullError) error {
+	d.logEvent(slog.LevelError, "Disk full", slog.String("path", err.Path), slog.Int64("needed", err.Needed),
+		slog.Int64("available", err.Available))
+	return err
+}
+
+// checkBackupSpace fails with a DiskFullError if dir has less room than
+// the files of the database take, which a backup of them needs at most.
+func (d *Driver) checkBackupSpace(dir string) error {
+	free, err := freeSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	size, err := d.storedSize("", true)
+	if err != nil {
+		return err
+	}
+	if free-size < d.minFreeSpace {
+		return d.diskFull(&DiskFullError{Path: dir, Needed: size + d.minFreeSpace, Available: free})
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package database
+
+import "errors"
+
+func isNoSpace(err error) bool {
+	return false
+}
+
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// spaceBackend is a memory backend reporting the free space a test sets.
+type spaceBackend struct {
+	Backend
+	free atomic.Int64
+	err  error
+}
+
+func newSpaceBackend(free int64) *spaceBackend {
+	s := &spaceBackend{Backend: NewMemoryBackend()}
+	s.free.Store(free)
+	return s
+}
+
+func (s *spaceBackend) Available() (int64, error) {
+	return s.free.Load(), s.err
+}
+
+func TestDiskFullPreflight(t *testing.T) {
+	b := newSpaceBackend(4 << 20)
+	d, err := New("", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// Small writes are left to the storage to refuse.
+	b.free.Store(10)
+	if err := d.Write("notes", "small", strings.Repeat("x", 100)); err != nil {
+		t.Fatalf("small Write = %v, want it not checked", err)
+	}
+	b.free.Store(2 << 20)
+	err = d.Write("notes", "large", strings.Repeat("x", 3<<20))
+	var full *DiskFullError
+	if !errors.Is(err, ErrDiskFull) || !errors.As(err, &full) {
+		t.Fatalf("Write larger than the free space = %v, want a DiskFullError", err)
+	}
+	if full.Err != nil || full.Available != 2<<20 || full.Needed < 3<<20 || !strings.Contains(full.Path, "notes") {
+		t.Errorf("DiskFullError = %+v", full)
+	}
+	if keys, _ := d.Keys("notes"); len(keys) != 1 {
+		t.Errorf("Keys after a refused write = %q, want only the small record", keys)
+	}
+
+	b.err = errors.ErrUnsupported
+	if err := d.Write("notes", "large", strings.Repeat("x", 3<<20)); err != nil {
+		t.Errorf("Write to storage that cannot tell its free space = %v", err)
+	}
+}
+
+func TestMinFreeSpace(t *testing.T) {
+	b := newSpaceBackend(1 << 20)
+	d, err := New("", &Options{Backend: b, MinFreeSpace: 1 << 20, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	err = d.Write("notes", "a", 1)
+	var full *DiskFullError
+	if !errors.As(err, &full) || full.Needed <= 1<<20 {
+		t.Errorf("Write into the headroom = %v, want a DiskFullError", err)
+	}
+
+	// A backend that cannot tell its free space is not checked.
+	m, err := NewMemory(&Options{MinFreeSpace: 1 << 62, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.Write("notes", "a", 1); err != nil {
+		t.Errorf("Write to the memory backend = %v", err)
+	}
+}
+
+func TestBackupSpace(t *testing.T) {
+	if _, err := freeSpace(t.TempDir()); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("free space unknown on this platform")
+	}
+	d, err := New(t.TempDir(), &Options{MinFreeSpace: 1 << 62, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := BackupTask(t.TempDir(), 1)(context.Background(), d); !errors.Is(err, ErrDiskFull) {
+		t.Errorf("BackupTask with too little room = %v, want ErrDiskFull", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package database
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isNoSpace reports whether err is the file system running out of space,
+// or out of the user's disk quota.
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
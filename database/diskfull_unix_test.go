@@ -0,0 +1,98 @@
+//go:build unix
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// fullBackend is a file backend whose file system runs out of space part
+// way through every write while full is set.
+type fullBackend struct {
+	*fileBackend
+	full bool
+}
+
+func (f *fullBackend) Put(p string, b []byte) error {
+	if f.full {
+		f.fileBackend.Put(p, []byte("partial"))
+		return &fs.PathError{Op: "write", Path: p, Err: syscall.ENOSPC}
+	}
+	return f.fileBackend.Put(p, b)
+}
+
+func (f *fullBackend) PutStream(p string, r io.Reader) error {
+	if f.full {
+		f.fileBackend.Put(p, []byte("partial"))
+		io.Copy(io.Discard, r)
+		return &fs.PathError{Op: "write", Path: p, Err: syscall.ENOSPC}
+	}
+	return f.fileBackend.PutStream(p, r)
+}
+
+func TestDiskFull(t *testing.T) {
+	dir := t.TempDir()
+	b := &fullBackend{fileBackend: newFileBackend(dir, 0755, 0644, -1)}
+	d, err := New(dir, &Options{
+		Backend:          b,
+		FieldKey:         testFieldKey,
+		Collections:      map[string]CollectionOptions{"secrets": {EncryptedFields: []string{"v"}}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, collection := range []string{"users", "secrets"} {
+		if err := d.Write(collection, "a", map[string]int{"v": 1}); err != nil {
+			t.Fatal(err)
+		}
+		b.full = true
+		err := d.Write(collection, "a", map[string]int{"v": 2})
+		var full *DiskFullError
+		if !errors.Is(err, ErrDiskFull) || !errors.As(err, &full) || !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("Write to %s running out of space = %v, want a DiskFullError wrapping ENOSPC", collection, err)
+		}
+		b.full = false
+
+		var v map[string]int
+		if err := d.Read(collection, "a", &v); err != nil || v["v"] != 1 {
+			t.Errorf("record of %s after the failed write = %v, %v; want it as it was", collection, v, err)
+		}
+	}
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if strings.HasSuffix(p, ".tmp") {
+			t.Errorf("temp file %s left behind", p)
+		}
+		return nil
+	})
+}
+
+func TestIsNoSpace(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.ENOSPC, syscall.EDQUOT} {
+		if !isNoSpace(&fs.PathError{Op: "write", Path: "x", Err: errno}) {
+			t.Errorf("isNoSpace(%v) = false", errno)
+		}
+	}
+	if isNoSpace(syscall.EIO) {
+		t.Error("isNoSpace(EIO) = true")
+	}
+}
+
+func TestIsDiskFullErrno(t *testing.T) {
+	if err := fmt.Errorf("writing: %w", &fs.PathError{Op: "write", Path: "x", Err: syscall.ENOSPC}); !IsDiskFull(err) {
+		t.Errorf("IsDiskFull(%v) = false", err)
+	}
+	if IsDiskFull(&fs.PathError{Op: "write", Path: "x", Err: syscall.EIO}) {
+		t.Error("IsDiskFull(EIO) = true")
+	}
+}
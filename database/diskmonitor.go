@@ -0,0 +1,145 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const defaultDiskMonitorInterval = time.Minute
+
+// errDiskReadOnly refuses writes while a DiskMonitor with ReadOnly set
+// finds a threshold crossed.
+var errDiskReadOnly = fmt.Errorf("%w: %w", ErrReadOnly, ErrDiskFull)
+
+// DiskMonitor checks in the background how much the database takes and its
+// storage has free, so a service can shed load or alert before writes fail.
+type DiskMonitor struct {
+	// Interval is how often usage is checked, every minute by default.
+	Interval time.Duration
+	// MaxSize is the most bytes the database files should take, and
+	// MinFree the fewest bytes its storage should have free; zero leaves
+	// either unchecked. Free space is only known with a backend
+	// implementing SpaceBackend.
+	MaxSize int64
+	MinFree int64
+	// ReadOnly refuses writes, deletes included, with an error matching
+	// ErrReadOnly and ErrDiskFull while a threshold is crossed, until a
+	// later check finds usage back within them.
+	ReadOnly bool
+	// OnChange is called from the monitor with the usage whenever a check
+	// finds a threshold newly crossed, or none crossed any more. It should
+	// return quickly, as checks wait for it.
+	OnChange func(DiskUsage)
+}
+
+// DiskUsage is what a DiskMonitor check found.
+type DiskUsage struct {
+	Checked time.Time `json:"checked"`
+	// Size is the bytes the database files take.
+	Size int64 `json:"size"`
+	// Free is the bytes its storage has free, -1 when the backend cannot
+	// tell.
+	Free int64 `json:"free"`
+	// OverSize and LowFree report the MaxSize and MinFree thresholds
+	// crossed.
+	OverSize bool `json:"overSize"`
+	LowFree  bool `json:"lowFree"`
+}
+
+// Crossed reports whether any threshold was crossed.
+func (u DiskUsage) Crossed() bool {
+	return u.OverSize || u.LowFree
+}
+
+// DiskUsage returns how much the database takes and its storage has free
+// now, checked against the thresholds of Options.DiskMonitor if set.
+func (d *Driver) DiskUsage() (DiskUsage, error) {
+	if err := d.checkOpen(); err != nil {
+		return DiskUsage{}, err
+	}
+	u := DiskUsage{Checked: time.Now(), Free: -1}
+	var err error
+	if u.Size, err = d.storedSize("", true); err != nil {
+		return DiskUsage{}, err
+	}
+	if sb, ok := d.backend.(SpaceBackend); ok {
+		free, err := sb.Available()
+		if err == nil {
+			u.Free = free
+		} else if !errors.Is(err, errors.ErrUnsupported) {
+			return DiskUsage{}, err
+		}
+	}
+	if m := d.diskMonitor; m != nil {
+		u.OverSize = m.MaxSize > 0 && u.Size > m.MaxSize
+		u.LowFree = m.MinFree > 0 && u.Free >= 0 && u.Free < m.MinFree
+	}
+	return u, nil
+}
+
+func (m *DiskMonitor) validate() error {
+	if m.Interval < 0 {
+		return fmt.Errorf("invalid disk monitor interval %v - must not be negative", m.Interval)
+	}
+	if m.MaxSize < 0 || m.MinFree < 0 {
+		return fmt.Errorf("invalid disk monitor thresholds - must not be negative")
+	}
+	if m.MaxSize == 0 && m.MinFree == 0 {
+		return fmt.Errorf("disk monitor has no thresholds - set MaxSize or MinFree")
+	}
+	return nil
+}
+
+// startDiskMonitor checks disk usage before the Driver is handed out, so
+// one opened on a full disk starts degraded, and then every interval.
+func (d *Driver) startDiskMonitor() {
+	interval := d.diskMonitor.Interval
+	if interval == 0 {
+		interval = defaultDiskMonitorInterval
+	}
+	crossed := d.checkDiskUsage(false)
+	d.goBackground(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				crossed = d.checkDiskUsage(crossed)
+			}
+		}
+	})
+}
+
+// checkDiskUsage runs a check of the monitor, given whether the last one
+// found a threshold crossed, and returns whether this one did.
+func (d *Driver) checkDiskUsage(crossed bool) bool {
+	u, err := d.DiskUsage()
+	if err != nil {
+		if !errors.Is(err, ErrClosed) {
+			d.logEvent(slog.LevelError, "Checking disk usage failed", slog.Any("error", err))
+		}
+		return crossed
+	}
+	if u.Crossed() == crossed {
+		return crossed
+	}
+
+	m := d.diskMonitor
+	if u.Crossed() {
+		d.logEvent(slog.LevelWarn, "Disk usage threshold crossed", slog.Int64("size", u.Size), slog.Int64("free", u.Free),
+			slog.Bool("overSize", u.OverSize), slog.Bool("lowFree", u.LowFree), slog.Bool("readOnly", m.ReadOnly))
+	} else {
+		d.logEvent(slog.LevelInfo, "Disk usage back within thresholds", slog.Int64("size", u.Size), slog.Int64("free", u.Free))
+	}
+	if m.ReadOnly {
+		d.diskReadOnly.Store(u.Crossed())
+	}
+	if m.OnChange != nil {
+		m.OnChange(u)
+	}
+	return u.Crossed()
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventually polls cond until it holds or a second has passed.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestDiskMonitor(t *testing.T) {
+	var mutex sync.Mutex
+	var changes []DiskUsage
+	b := newSpaceBackend(10)
+	d, err := New("", &Options{
+		Backend: b,
+		DiskMonitor: &DiskMonitor{Interval: 5 * time.Millisecond, MinFree: 100, ReadOnly: true, OnChange: func(u DiskUsage) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			changes = append(changes, u)
+		}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// Opened on a full disk, the Driver starts refusing writes.
+	err = d.Write("users", "a", 1)
+	if !errors.Is(err, ErrReadOnly) || !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("Write below the free space threshold = %v, want ErrReadOnly and ErrDiskFull", err)
+	}
+	if u, err := d.DiskUsage(); err != nil || !u.LowFree || u.OverSize || u.Free != 10 {
+		t.Errorf("DiskUsage = %+v, %v", u, err)
+	}
+
+	b.free.Store(1000)
+	eventually(t, "writes to resume", func() bool { return d.Write("users", "a", 1) == nil })
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(changes) != 2 || !changes[0].Crossed() || changes[1].Crossed() {
+		t.Errorf("OnChange got %+v, want the threshold crossed and then not", changes)
+	}
+}
+
+func TestDiskMonitorMaxSize(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{DiskMonitor: &DiskMonitor{Interval: 5 * time.Millisecond, MaxSize: 10000, ReadOnly: true}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("notes", "big", strings.Repeat("x", 20000)); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "writes to be refused", func() bool { return errors.Is(d.Write("notes", "small", 1), ErrDiskFull) })
+	if u, err := d.DiskUsage(); err != nil || !u.OverSize || u.Size <= 10000 {
+		t.Errorf("DiskUsage = %+v, %v", u, err)
+	}
+
+	// Removing files outside the Driver brings it back under the limit.
+	if err := d.backend.Delete("notes/big.json"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "writes to resume", func() bool { return d.Write("notes", "small", 1) == nil })
+}
+
+func TestDiskMonitorOptions(t *testing.T) {
+	for name, m := range map[string]*DiskMonitor{
+		"no thresholds":      {},
+		"negative interval":  {Interval: -1, MaxSize: 1},
+		"negative threshold": {MinFree: -1},
+	} {
+		if d, err := New(t.TempDir(), &Options{DiskMonitor: m, TTLSweepInterval: -1}); err == nil {
+			d.Close()
+			t.Errorf("New with a disk monitor with %s succeeded", name)
+		}
+	}
+
+	// Without a SpaceBackend free space is unknown, and never too low.
+	d, err := NewMemory(&Options{DiskMonitor: &DiskMonitor{MinFree: 1 << 62, ReadOnly: true}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := d.DiskUsage(); err != nil || u.Free != -1 || u.LowFree || u.Size == 0 {
+		t.Errorf("DiskUsage = %+v, %v", u, err)
+	}
+}
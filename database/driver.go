@@ -0,0 +1,839 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jcelliott/lumber"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const Version = "1.0.0"
+
+type (
+	Logger interface {
+		Fatal(string, ...interface{})
+		Error(string, ...interface{})
+		Warn(string, ...interface{})
+		Info(string, ...interface{})
+		Debug(string, ...interface{})
+		Trace(string, ...interface{})
+	}
+
+	Driver struct {
+		mutex       sync.Mutex
+		mutexes     map[string]*sync.Mutex
+		collections map[string]CollectionOptions
+		// configured holds Options.Collections, to fall back on when a
+		// collection and its manifest go.
+		configured  map[string]CollectionOptions
+		manifests   map[string]Manifest
+		backend     Backend
+		keys        *keyring
+		fieldKey    []byte
+		readOnly    bool
+		role        *Role
+		lock        *os.File
+		fileMode    os.FileMode
+		keyCase     KeyCase
+		foldsCase   bool
+		caseIndexes map[string]map[string]string
+		codec       Codec
+		ext         string
+		compress    Compression
+		registered  string
+		closed      bool
+		done        chan struct{}
+		background  sync.WaitGroup
+		closers     []func() error
+		watchMutex  sync.Mutex
+		watchers    map[*watcher]bool
+		hookMutex   sync.RWMutex
+		hooks       []func(Op)
+		observers   []Observer
+		opSeq       atomic.Uint64
+		tracer      trace.Tracer
+		slowOp      time.Duration
+
+		writesInFlight   atomic.Int64
+		sweeperRuns      atomic.Int64
+		sweptRecords     atomic.Int64
+		lastSweep        atomic.Int64
+		lastVerify       atomic.Pointer[VerifyReport]
+		diskMonitor      *DiskMonitor
+		diskReadOnly     atomic.Bool
+		jobs             []*job
+		views            map[string]*View
+		viewsBySource    map[string][]*View
+		geoIndexes       map[string]*geoIndex
+		procedures       map[string]*procedure
+		counts           counters
+		workers          int
+		queueMaxAttempts int
+		seriesRetention  time.Duration
+		idempotencyTTL   time.Duration
+		retryPolicy      RetryPolicy
+
+		namespaceQuota  Quota
+		namespaceQuotas map[string]Quota
+		maxRecordSize   int64
+		minFreeSpace    int64
+		mmapThreshold   int64
+		usages          map[string]*usage
+		dir             string
+		log             Logger
+		recordMeta      bool
+	}
+)
+
+type Options struct {
+	Logger
+	Collections map[string]CollectionOptions
+	Backend     Backend
+	MasterKey   []byte
+	FieldKey    []byte
+	ReadOnly    bool
+	Role        *Role
+
+	DirMode  os.FileMode
+	FileMode os.FileMode
+	Group    string
+	KeyCase  KeyCase
+
+	// Codec encodes records, JSONCodec by default. Extension overrides the
+	// file suffix the codec would give record files.
+	Codec     Codec
+	Extension string
+
+	// Durability is how the database directory is written, buffered by
+	// the operating system by default. Backends given in Backend are left
+	// as they are.
+	Durability Durability
+
+	// Compression compresses the records of collections without
+	// CollectionOptions of their own.
+	Compression Compression
+
+	// MaxRecordSize caps the encoded size of a single record in bytes;
+	// zero means no limit.
+	MaxRecordSize int64
+
+	// MmapThreshold is the smallest record file ReadMapped maps into memory
+	// instead of copying; zero maps none.
+	MmapThreshold int64
+
+	// MinFreeSpace is how many bytes writes and backups leave free on the
+	// storage, failing with ErrDiskFull beforehand rather than eat into
+	// them. Zero only checks that large ones fit.
+	MinFreeSpace int64
+
+	// TTLSweepInterval is how often expired records are deleted, every
+	// minute by default; a negative interval disables the sweeper.
+	TTLSweepInterval time.Duration
+
+	// TracerProvider records a span for every operation; the global
+	// OpenTelemetry provider by default.
+	TracerProvider trace.TracerProvider
+
+	// SlowOpThreshold logs a warning for every operation taking at least
+	// this long; zero disables it.
+	SlowOpThreshold time.Duration
+
+	// Observers are told of every operation as it starts and ends, as
+	// those added with Observe are.
+	Observers []Observer
+
+	// Maintenance lists jobs to run in the background on a schedule, such
+	// as a nightly Compact.
+	Maintenance []MaintenanceJob
+
+	// Views lists collections the Driver maintains from others.
+	Views []View
+
+	// GeoIndexes lists the collections to index by location.
+	GeoIndexes []GeoIndex
+
+	// Procedures lists the scripts Call runs by name.
+	Procedures []Procedure
+
+	// Extensions names registered extensions to set the Driver up with, in
+	// order, once it is open.
+	Extensions []string
+
+	// MapReduceWorkers bounds the goroutines a MapReduce job runs on,
+	// GOMAXPROCS by default.
+	MapReduceWorkers int
+
+	// CounterFlushInterval is how often changed counters are written out,
+	// every second by default; a negative interval writes every change.
+	CounterFlushInterval time.Duration
+
+	// SeriesRetention is how long time series keep their points, in whole
+	// UTC days; zero keeps them forever.
+	SeriesRetention time.Duration
+
+	// QueueMaxAttempts is how many times a Queue delivers a message before
+	// dead-lettering it, 5 by default.
+	QueueMaxAttempts int
+
+	// DiskMonitor, if set, watches the size of the database and the free
+	// space of its storage in the background.
+	DiskMonitor *DiskMonitor
+
+	// Retry retries writes and deletes failing with transient storage
+	// errors; by default they are tried once.
+	Retry RetryPolicy
+
+	// WatchExternal watches the database directory for records other
+	// processes, or people editing the files, write and delete, bringing
+	// the key index, quota usage, views and geo indexes up to date with
+	// them and reporting them to watchers as External events. It needs the
+	// file backend.
+	WatchExternal bool
+
+	// RecordMetadata keeps when each record was created and last written,
+	// and the principal that wrote it, for ReadMeta and RecentlyUpdated.
+	// It costs every write a second file.
+	RecordMetadata bool
+
+	// IdempotencyTTL is how long WriteIdempotent remembers an idempotency
+	// key, a day by default.
+	IdempotencyTTL time.Duration
+
+	NamespaceQuota  Quota
+	NamespaceQuotas map[string]Quota
+}
+
+type CollectionOptions struct {
+	// Codec encodes the records of the collection, the codec the database
+	// is opened with by default. Records get its extension, so those
+	// written before it changed to one with another extension are not
+	// found.
+	Codec               Codec
+	Compression         Compression
+	Quota               Quota
+	EncryptedFields     []string
+	DeterministicFields []string
+	// IDs is how Insert generates keys, UUIDv7s by default.
+	IDs IDScheme
+	// Retention bounds how long records are kept, by ApplyRetention.
+	Retention Retention
+	// AppendOnly makes the records write once, as for a ledger of audit
+	// entries or events: writing over one fails with ErrAppendOnly, and so
+	// does deleting one, or the collection, other than with ForceDelete
+	// and ForceDropCollection.
+	// Records still expire, and are removed by Retention.
+	AppendOnly bool
+	// TTL is how long records written without an expiry of their own
+	// live, as if written with WriteTTL; zero keeps them.
+	TTL time.Duration
+	// Schema, if set, is an expression every record written must match,
+	// failing with ErrSchemaViolation otherwise.
+	Schema *Expr
+}
+
+func New(dir string, options *Options) (*Driver, error) {
+	dir = filepath.Clean(dir)
+
+	opts := Options{}
+
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
+	}
+
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+	if opts.Extension == "" {
+		opts.Extension = opts.Codec.Extension()
+	}
+	if err := validExtension(opts.Extension); err != nil {
+		return nil, err
+	}
+
+	driver := Driver{
+		dir:         dir,
+		mutexes:     make(map[string]*sync.Mutex),
+		collections: make(map[string]CollectionOptions),
+		configured:  make(map[string]CollectionOptions),
+		manifests:   make(map[string]Manifest),
+		backend:     opts.Backend,
+		fieldKey:    opts.FieldKey,
+		readOnly:    opts.ReadOnly,
+		role:        opts.Role,
+		keyCase:     opts.KeyCase,
+		caseIndexes: make(map[string]map[string]string),
+		codec:       opts.Codec,
+		ext:         opts.Extension,
+		compress:    opts.Compression,
+		done:        make(chan struct{}),
+
+		namespaceQuota:   opts.NamespaceQuota,
+		namespaceQuotas:  opts.NamespaceQuotas,
+		maxRecordSize:    opts.MaxRecordSize,
+		minFreeSpace:     opts.MinFreeSpace,
+		mmapThreshold:    opts.MmapThreshold,
+		slowOp:           opts.SlowOpThreshold,
+		workers:          opts.MapReduceWorkers,
+		queueMaxAttempts: opts.QueueMaxAttempts,
+		seriesRetention:  opts.SeriesRetention,
+		idempotencyTTL:   opts.IdempotencyTTL,
+		retryPolicy:      opts.Retry.withDefaults(),
+		usages:           make(map[string]*usage),
+		log:              opts.Logger,
+		recordMeta:       opts.RecordMetadata,
+		observers:        append([]Observer(nil), opts.Observers...),
+	}
+	if driver.workers <= 0 {
+		driver.workers = runtime.GOMAXPROCS(0)
+	}
+	if driver.queueMaxAttempts <= 0 {
+		driver.queueMaxAttempts = defaultQueueMaxAttempts
+	}
+	if driver.idempotencyTTL <= 0 {
+		driver.idempotencyTTL = defaultIdempotencyTTL
+	}
+	if opts.FieldKey != nil {
+		if err := checkKey(opts.FieldKey); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DiskMonitor != nil {
+		if err := opts.DiskMonitor.validate(); err != nil {
+			return nil, err
+		}
+		m := *opts.DiskMonitor
+		driver.diskMonitor = &m
+	}
+	if !opts.Compression.valid() {
+		return nil, fmt.Errorf("unknown compression %q", opts.Compression)
+	}
+	for name, c := range opts.Collections {
+		if !c.Compression.valid() {
+			return nil, fmt.Errorf("unknown compression %q for collection %s", c.Compression, name)
+		}
+		if c.Codec != nil {
+			if err := validExtension(c.Codec.Extension()); err != nil {
+				return nil, fmt.Errorf("codec of collection %s: %w", name, err)
+			}
+		}
+		if err := c.Retention.validate(name, opts.Collections); err != nil {
+			return nil, err
+		}
+		driver.collections[name] = c
+		driver.configured[name] = c
+	}
+
+	if driver.backend == nil {
+		if opts.DirMode == 0 {
+			opts.DirMode = 0755
+		}
+		if opts.FileMode == 0 {
+			opts.FileMode = 0644
+		}
+		gid, err := lookupGroup(opts.Group)
+		if err != nil {
+			return nil, err
+		}
+		files := newFileBackend(dir, opts.DirMode, opts.FileMode, gid)
+		files.sync = opts.Durability == DurabilitySync
+		driver.backend = files
+		driver.fileMode = opts.FileMode
+
+		if _, err := os.Stat(dir); err == nil {
+			opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
+		} else if opts.ReadOnly {
+			return nil, err
+		} else {
+			opts.Logger.Debug("Creating the database at '%s'...\n", dir)
+			if err := os.Mkdir(dir, opts.DirMode); err != nil {
+				return &driver, err
+			}
+			if err := files.chown(dir); err != nil {
+				return &driver, err
+			}
+		}
+
+		if err := driver.register(); err != nil {
+			return nil, err
+		}
+		if err := driver.acquireLock(); err != nil {
+			driver.Close()
+			return nil, err
+		}
+
+		if opts.KeyCase == KeyCaseDetect && !opts.ReadOnly {
+			folds, err := caseInsensitive(dir)
+			if err != nil {
+				driver.Close()
+				return nil, err
+			}
+			if folds {
+				opts.Logger.Debug("'%s' is case-insensitive, rejecting keys that differ only in case\n", dir)
+			}
+			driver.foldsCase = folds
+		}
+	}
+
+	if opts.MasterKey != nil {
+		if err := driver.openKeyring(opts.MasterKey); err != nil {
+			driver.Close()
+			return nil, err
+		}
+	}
+
+	if opts.TracerProvider != nil {
+		driver.tracer = opts.TracerProvider.Tracer(tracerName)
+	} else {
+		driver.tracer = otel.Tracer(tracerName)
+	}
+
+	if err := driver.openProcedures(opts.Procedures); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	if err := driver.openViews(opts.Views); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	if err := driver.loadManifests(); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	if err := driver.openGeoIndexes(append(opts.GeoIndexes, driver.manifestGeoIndexes()...)); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	if opts.WatchExternal {
+		if err := driver.startExternalWatch(); err != nil {
+			driver.Close()
+			return nil, err
+		}
+	}
+
+	if opts.TTLSweepInterval == 0 {
+		opts.TTLSweepInterval = defaultTTLSweepInterval
+	}
+	if opts.TTLSweepInterval > 0 && !opts.ReadOnly {
+		driver.sweepExpired(opts.TTLSweepInterval)
+	}
+	if driver.diskMonitor != nil {
+		driver.startDiskMonitor()
+	}
+	if opts.CounterFlushInterval == 0 {
+		opts.CounterFlushInterval = defaultCounterFlushInterval
+	}
+	if !opts.ReadOnly {
+		driver.startCounters(opts.CounterFlushInterval)
+	}
+	if err := driver.startMaintenance(opts.Maintenance); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	if err := driver.startExtensions(opts.Extensions); err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return &driver, nil
+}
+
+func (d *Driver) Write(collection string, resource string, v interface{}) error {
+	return d.write(context.Background(), collection, resource, v, time.Time{})
+}
+
+// WriteContext is Write with a context to trace the operation in.
+func (d *Driver) WriteContext(ctx context.Context, collection string, resource string, v interface{}) error {
+	return d.write(ctx, collection, resource, v, time.Time{})
+}
+
+func (d *Driver) write(ctx context.Context, collection string, resource string, v interface{}, expires time.Time) (err error) {
+	op := d.startOp(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return err
+	}
+
+	b, err := d.codecFor(collection).Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := d.checkRecordSize(collection, resource, b); err != nil {
+		return err
+	}
+	if err := d.checkSpace(d.recordPath(collection, resource), int64(len(b))); err != nil {
+		return err
+	}
+
+	return d.writeRecord(op, collection, resource, bytes.NewReader(b), expires)
+}
+
+func (d *Driver) Read(collection string, resource string, v interface{}) error {
+	return d.ReadContext(context.Background(), collection, resource, v)
+}
+
+// ReadContext is Read with a context to trace the operation in.
+func (d *Driver) ReadContext(ctx context.Context, collection string, resource string, v interface{}) (err error) {
+	op := d.startOp(ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return err
+	}
+
+	b, err := d.readRecord(collection, resource)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	op.addBytes(int64(len(b)))
+
+	return d.codecFor(collection).Unmarshal(b, v)
+}
+
+// readRecord returns the decoded bytes of a live record, or an error
+// matching fs.ErrNotExist.
+func (d *Driver) readRecord(collection, resource string) ([]byte, error) {
+	file, b, err := d.getLiveRecord(d.recordPath(collection, resource))
+	if err != nil {
+		return nil, err
+	}
+	if b, err = d.decodeRecord(file, b); err != nil {
+		return nil, err
+	}
+	return d.decryptFields(collection, resource, b)
+}
+
+func (d *Driver) ReadAll(collection string) ([]string, error) {
+	return d.ReadAllContext(context.Background(), collection)
+}
+
+// ReadAllContext is ReadAll with a context to trace the operation in.
+func (d *Driver) ReadAllContext(ctx context.Context, collection string) ([]string, error) {
+	return d.ReadAllWith(ctx, collection, ReadAllOptions{})
+}
+
+// ReadAllOptions tunes ReadAllWith.
+type ReadAllOptions struct {
+	// Parallelism is how many records are read and decoded at once. Zero
+	// or one reads them one after the other, and a negative value uses
+	// Options.MapReduceWorkers.
+	Parallelism int
+}
+
+// ReadAllWith is ReadAllContext with options, such as reading the records
+// of large collections concurrently. They are returned in the same order
+// however many are read at once.
+func (d *Driver) ReadAllWith(ctx context.Context, collection string, opts ReadAllOptions) (records []string, err error) {
+	op := d.startOp(ctx, "readall", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to read records")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	files, err := d.backend.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := d.expiredStems(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var recordFiles, names []string
+	for _, file := range files {
+		stem, ok := d.recordStem(collection, file)
+		if isDirName(file) || !ok || expired[stem] {
+			continue
+		}
+		recordFiles = append(recordFiles, file)
+		names = append(names, decodeKey(stem))
+	}
+	if len(recordFiles) == 0 {
+		return nil, nil
+	}
+
+	read := func(i int) (string, error) {
+		b, err := d.backend.Get(path.Join(collection, recordFiles[i]))
+		if err != nil {
+			return "", err
+		}
+		if b, err = d.decodeRecord(recordFiles[i], b); err != nil {
+			return "", err
+		}
+		if b, err = d.decryptFields(collection, names[i], b); err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	workers := opts.Parallelism
+	if workers < 0 {
+		workers = d.workers
+	}
+	if workers <= 1 {
+		for i := range recordFiles {
+			record, err := read(i)
+			if err != nil {
+				return nil, err
+			}
+			op.addBytes(int64(len(record)))
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	records = make([]string, len(recordFiles))
+	err = d.parallelN(ctx, workers, len(recordFiles), func(i int) error {
+		var err error
+		records[i], err = read(i)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		op.addBytes(int64(len(record)))
+	}
+	return records, nil
+}
+
+// Keys returns the keys of every record in a collection, sorted.
+func (d *Driver) Keys(collection string) ([]string, error) {
+	return d.KeysContext(context.Background(), collection)
+}
+
+// KeysContext is Keys with a context to trace the operation in.
+func (d *Driver) KeysContext(ctx context.Context, collection string) (keys []string, err error) {
+	op := d.startOp(ctx, "keys", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to read records")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	files, err := d.backend.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := d.expiredStems(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if stem, ok := d.recordStem(collection, file); ok && !isDirName(file) && !expired[stem] {
+			keys = append(keys, decodeKey(stem))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes a record, failing with an error matching fs.ErrNotExist if
+// there is none, so a nil error means exactly one record went. Whole
+// collections are removed with DropCollection, and several records at once
+// with DeleteMany or DeleteWhere, which count what they removed.
+func (d *Driver) Delete(collection string, resource string) error {
+	return d.DeleteContext(context.Background(), collection, resource)
+}
+
+// DeleteContext is Delete with a context to trace the operation in.
+func (d *Driver) DeleteContext(ctx context.Context, collection string, resource string) error {
+	return d.delete(ctx, collection, resource, false)
+}
+
+// delete removes a record, of an append-only collection only if force is
+// set.
+func (d *Driver) delete(ctx context.Context, collection, resource string, force bool) (err error) {
+	op := d.startOp(ctx, "delete", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - nothing to delete")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - use DropCollection to remove a whole collection")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return err
+	}
+
+	p := d.recordPath(collection, resource)
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if !force {
+		if err := d.checkDelete(collection); err != nil {
+			return err
+		}
+	}
+	if err := d.deleteRecord(collection, resource, p); !isNotExist(err) {
+		return err
+	}
+	// Never fall through to the directory named after the record: it
+	// holds the sub-collections DeleteTree removes.
+	return fmt.Errorf("unable to find file or directory named %v: %w", p, fs.ErrNotExist)
+}
+
+// deleteRecord removes the record at p with its attachments, field key,
+// expiry and metadata, reporting a missing record with an error matching
+// fs.ErrNotExist. Callers hold the collection lock.
+func (d *Driver) deleteRecord(collection, resource, p string) error {
+	if err := d.releaseQuota(collection, p); err != nil {
+		return err
+	}
+	n, err := d.removeRecordFiles(p, "")
+	if err != nil {
+		d.forgetUsage(collection)
+		return err
+	}
+	if n == 0 {
+		return notExist("remove", p)
+	}
+
+	d.forgetKeyCase(collection, path.Base(p))
+	for _, sidecar := range []string{p + attachmentsSuffix, d.recordKeyPath(collection, resource), ttlPath(p), metaPath(p)} {
+		if err := d.deleteFile(sidecar); err != nil && !isNotExist(err) {
+			return err
+		}
+	}
+	d.notify(EventDelete, collection, resource)
+	return nil
+}
+
+func (d *Driver) GetOrCreateMutex(collection string) *sync.Mutex {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	m, ok := d.mutexes[collection]
+	if !ok {
+		m = &sync.Mutex{}
+		d.mutexes[collection] = m
+	}
+	return m
+}
+
+func (d *Driver) collectionOptions(collection string) CollectionOptions {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if opts, ok := d.collections[collection]; ok {
+		return opts
+	}
+	if opts, ok := d.collections[baseCollection(collection)]; ok {
+		return opts
+	}
+	return CollectionOptions{Compression: d.compress}
+}
+
+// codecFor returns the codec the records of collection are encoded with.
+func (d *Driver) codecFor(collection string) Codec {
+	if c := d.collectionOptions(collection).Codec; c != nil {
+		return c
+	}
+	return d.codec
+}
+
+// extFor returns the extension the records of collection carry, before
+// any compression suffix.
+func (d *Driver) extFor(collection string) string {
+	if c := d.collectionOptions(collection).Codec; c != nil {
+		return c.Extension()
+	}
+	return d.ext
+}
+
+// recordFile returns the file holding the record at p (without extension)
+// when written with compression c.
+func (d *Driver) recordFile(p string, c Compression) string {
+	return p + d.extFor(path.Dir(p)) + c.ext()
+}
+
+func (d *Driver) encodeRecord(c Compression, b []byte) ([]byte, error) {
+	b, err := compress(c, b)
+	if err != nil {
+		return nil, err
+	}
+	return d.encrypt(b)
+}
+
+func (d *Driver) decodeRecord(name string, b []byte) ([]byte, error) {
+	b, err := d.decrypt(b)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(name, b)
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+)
+
+// DeleteReport describes what DeleteWhere or DropCollection removed, or
+// would have removed on a dry run.
+type DeleteReport struct {
+	DryRun bool `json:"dryRun"`
+	// Records holds the keys of the records removed from each collection.
+	Records map[string][]string `json:"records"`
+}
+
+// Count returns how many records were removed.
+func (r *DeleteReport) Count() int {
+	n := 0
+	for _, keys := range r.Records {
+		n += len(keys)
+	}
+	return n
+}
+
+// DeleteMany deletes the records of collection with keys, under one lock,
+// and returns how many there were; keys with no record are skipped.
+func (d *Driver) DeleteMany(collection string, keys []string) (int, error) {
+	return d.DeleteManyContext(context.Background(), collection, keys)
+}
+
+// DeleteManyContext is DeleteMany with a context to trace the operation in.
+func (d *Driver) DeleteManyContext(ctx context.Context, collection string, keys []string) (n int, err error) {
+	op := d.startOp(ctx, "delete", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+	if collection == "" {
+		return 0, fmt.Errorf("missing collection - nothing to delete")
+	}
+	if err := validCollection(collection); err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if key == "" {
+			return 0, fmt.Errorf("missing resource - use DropCollection to remove a whole collection")
+		}
+	}
+	if err := d.checkView(collection); err != nil {
+		return 0, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return 0, err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if err := d.checkDelete(collection); err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		err := d.deleteRecord(collection, key, d.recordPath(collection, key))
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	if n > 0 {
+		d.logEvent(slog.LevelInfo, "Deleted records", slog.String("collection", collection), slog.Int("records", n))
+	}
+	return n, nil
+}
+
+// DeleteWhere deletes the records of q.Collection that q finds, at most
+// q.Limit of them if it is set, in key order. A dry run only reports them,
+// failing as the deletes would, as on an append-only collection.
+func (d *Driver) DeleteWhere(ctx context.Context, q Query, dryRun bool) (report *DeleteReport, err error) {
+	op := d.startOp(ctx, "delete", q.Collection, "")
+	defer op.end(&err)
+
+	if dryRun {
+		err = d.checkOpen()
+	} else {
+		err = d.checkWritable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.checkView(q.Collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermRead|PermDelete); err != nil {
+		return nil, err
+	}
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+	withMeta := q.usesMeta()
+
+	mutex := d.GetOrCreateMutex(q.Collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if err := d.checkDelete(q.Collection); err != nil {
+		return nil, err
+	}
+	keys, err := d.liveKeys(q.Collection)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	matched := []string{}
+	for _, key := range keys {
+		if q.Limit > 0 && len(matched) == q.Limit {
+			break
+		}
+		b, err := d.readRecord(q.Collection, key)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc, err := d.decodeDocument(q.Collection, b)
+		if err != nil {
+			return nil, err
+		}
+		if withMeta {
+			if doc, err = d.addMeta(doc, q.Collection, key, b); err != nil {
+				return nil, err
+			}
+		}
+		if match(doc) {
+			matched = append(matched, key)
+		}
+	}
+
+	report = &DeleteReport{DryRun: dryRun, Records: map[string][]string{q.Collection: matched}}
+	if dryRun {
+		return report, nil
+	}
+	for _, key := range matched {
+		if err := d.deleteRecord(q.Collection, key, d.recordPath(q.Collection, key)); err != nil && !isNotExist(err) {
+			return report, err
+		}
+	}
+	if len(matched) > 0 {
+		d.logEvent(slog.LevelInfo, "Deleted records", slog.String("collection", q.Collection), slog.Int("records", len(matched)))
+	}
+	return report, nil
+}
+
+// DropCollection deletes a collection with its sub-collections, at any
+// depth, failing with an error matching fs.ErrNotExist if there is no such
+// collection. A dry run only reports the records that would go.
+func (d *Driver) DropCollection(ctx context.Context, collection string, dryRun bool) (*DeleteReport, error) {
+	return d.drop(ctx, collection, dryRun, false)
+}
+
+// drop removes a collection, an append-only one only if force is set.
+func (d *Driver) drop(ctx context.Context, collection string, dryRun, force bool) (report *DeleteReport, err error) {
+	op := d.startOp(ctx, "drop", collection, "")
+	defer op.end(&err)
+
+	if dryRun {
+		err = d.checkOpen()
+	} else {
+		err = d.checkWritable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - nothing to drop")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.checkView(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return nil, err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if _, err := d.backend.List(collection); err != nil {
+		if isNotExist(err) {
+			return nil, fmt.Errorf("unable to find collection %v: %w", collection, fs.ErrNotExist)
+		}
+		return nil, err
+	}
+	subs, err := d.collectionTree(collection)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		if err := d.authorizeContext(ctx, sub, PermDelete); err != nil {
+			return nil, err
+		}
+	}
+	all := append([]string{collection}, subs...)
+	if !force {
+		if err := d.checkDelete(all...); err != nil {
+			return nil, err
+		}
+	}
+	defer d.lockCollections(subs)()
+
+	report = &DeleteReport{DryRun: dryRun, Records: make(map[string][]string, len(all))}
+	for _, c := range all {
+		keys, err := d.liveKeys(c)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(keys)
+		report.Records[c] = append([]string{}, keys...)
+	}
+	if dryRun {
+		return report, nil
+	}
+	if err := d.removeCollection(collection, subs); err != nil {
+		return report, err
+	}
+	d.logEvent(slog.LevelInfo, "Dropped collection", slog.String("collection", collection), slog.Int("records", report.Count()))
+	return report, nil
+}
+
+// removeCollection deletes a collection and its sub-collections subs.
+// Callers hold their locks.
+func (d *Driver) removeCollection(collection string, subs []string) error {
+	if err := d.deleteFile(collection); err != nil {
+		if isNotExist(err) {
+			return fmt.Errorf("unable to find file or directory named %v: %w", collection, fs.ErrNotExist)
+		}
+		return err
+	}
+	d.forgetKeyCase(collection, "")
+	d.forgetUsage(collection)
+	d.forgetManifest(collection)
+	d.notify(EventDelete, collection, "")
+	for _, sub := range subs {
+		d.notify(EventDelete, sub, "")
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteWhere(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for i := 0; i < 6; i++ {
+		if err := d.Write("users", fmt.Sprintf("u%d", i), map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := Query{Collection: "users", Filter: "doc.n >= 2"}
+	report, err := d.DeleteWhere(context.Background(), q, true)
+	if want := []string{"u2", "u3", "u4", "u5"}; err != nil || !report.DryRun || !reflect.DeepEqual(report.Records["users"], want) {
+		t.Errorf("DeleteWhere dry run = %+v, %v; want %v", report, err, want)
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 6 {
+		t.Errorf("dry run deleted records: %v", keys)
+	}
+
+	q.Limit = 3
+	report, err = d.DeleteWhere(context.Background(), q, false)
+	if err != nil || report.DryRun || report.Count() != 3 {
+		t.Errorf("DeleteWhere of limit 3 = %+v, %v", report, err)
+	}
+	keys, err := d.Keys("users")
+	if want := []string{"u0", "u1", "u5"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys after DeleteWhere = %v, %v; want %v", keys, err, want)
+	}
+
+	if _, err := d.DeleteWhere(context.Background(), Query{Collection: "users", Filter: "doc.n >="}, false); err == nil {
+		t.Error("DeleteWhere of an invalid filter succeeded")
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, w := range []struct{ collection, key string }{
+		{"users", "ada"},
+		{"users", "bob"},
+		{"users/ada/posts", "p1"},
+		{"groups", "admins"},
+	} {
+		if err := d.Write(w.collection, w.key, map[string]int{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := d.DropCollection(context.Background(), "users", true)
+	want := map[string][]string{"users": {"ada", "bob"}, "users/ada/posts": {"p1"}}
+	if err != nil || !report.DryRun || !reflect.DeepEqual(report.Records, want) {
+		t.Errorf("DropCollection dry run = %+v, %v; want %v", report, err, want)
+	}
+	if keys, _ := d.Keys("users/ada/posts"); len(keys) != 1 {
+		t.Errorf("dry run dropped records: %v", keys)
+	}
+
+	report, err = d.DropCollection(context.Background(), "users", false)
+	if err != nil || report.Count() != 3 {
+		t.Fatalf("DropCollection = %+v, %v; want 3 records", report, err)
+	}
+	if _, err := d.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := d.Keys("users/ada/posts"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a dropped sub-collection = %v, want fs.ErrNotExist", err)
+	}
+	if keys, err := d.Keys("groups"); err != nil || len(keys) != 1 {
+		t.Errorf("Keys of another collection = %v, %v", keys, err)
+	}
+	if _, err := d.DropCollection(context.Background(), "users", false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DropCollection of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := d.Write("users", key, map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := d.DeleteMany("users", []string{"a", "b", "none"}); err != nil || n != 2 {
+		t.Errorf("DeleteMany = %d, %v; want 2", n, err)
+	}
+	if keys, err := d.Keys("users"); err != nil || !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Errorf("Keys after DeleteMany = %v, %v; want [c]", keys, err)
+	}
+	if _, err := d.DeleteMany("users", []string{"c", ""}); err == nil {
+		t.Error("DeleteMany of an empty key succeeded")
+	}
+	if err := d.Delete("users", ""); err == nil {
+		t.Error("Delete without a key succeeded")
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 1 {
+		t.Errorf("refused deletes removed records: %v", keys)
+	}
+}
+
+func TestNamespaceDropCollection(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	ns := d.Namespace("acme")
+	if err := ns.Write("users", "ada", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Write("users/ada/posts", "p1", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ns.DropCollection("users", false)
+	want := map[string][]string{"users": {"ada"}, "users/ada/posts": {"p1"}}
+	if err != nil || !reflect.DeepEqual(report.Records, want) {
+		t.Errorf("DropCollection = %+v, %v; want %v", report, err, want)
+	}
+	if collections, err := ns.Collections(); err != nil || len(collections) != 0 {
+		t.Errorf("Collections after dropping = %v, %v; want none", collections, err)
+	}
+	if _, err := ns.DropCollection("", false); err == nil {
+		t.Error("DropCollection of the whole tenant succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package database
+
+import "fmt"
+
+// Durability is how hard writes to the database directory try to survive
+// the machine crashing or losing power.
+type Durability int
+
+const (
+	// DurabilityBuffered leaves flushing writes to the operating system, so
+	// a crash can lose the last ones, never half a record.
+	DurabilityBuffered Durability = iota
+	// DurabilitySync flushes every file written, and the directory it is
+	// renamed in, to storage before the write returns.
+	DurabilitySync
+)
+
+func (m Durability) String() string {
+	switch m {
+	case DurabilityBuffered:
+		return "buffered"
+	case DurabilitySync:
+		return "sync"
+	}
+	return fmt.Sprintf("Durability(%d)", int(m))
+}
+
+func (m Durability) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Durability) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "buffered", "":
+		*m = DurabilityBuffered
+	case "sync":
+		*m = DurabilitySync
+	default:
+		return fmt.Errorf("unknown durability %q - must be buffered or sync", text)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package database
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+const keyringFile = ".keyring.json"
+
+// Encrypted records start with this magic followed by the big-endian
+// version of the data key they were sealed with.
+var encryptedMagic = []byte("\x00odbenc")
+
+var ErrInvalidMasterKey = errors.New("invalid master key - unable to unwrap data keys")
+
+type keyring struct {
+	mutex   sync.RWMutex
+	master  []byte
+	current uint32
+	keys    map[uint32][]byte
+}
+
+type keyringFileFormat struct {
+	Current uint32            `json:"current"`
+	Keys    map[uint32]string `json:"keys"`
+}
+
+func checkKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("invalid key size %d - must be 16, 24 or 32 bytes", len(key))
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func unseal(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (d *Driver) openKeyring(master []byte) error {
+	if err := checkKey(master); err != nil {
+		return err
+	}
+
+	kr := &keyring{master: master, keys: make(map[uint32][]byte)}
+	b, err := d.backend.Get(keyringFile)
+	switch {
+	case isNotExist(err) && d.readOnly:
+		return fmt.Errorf("database has no keyring and is opened read-only")
+	case isNotExist(err):
+		dek := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+			return err
+		}
+		kr.current = 1
+		kr.keys[1] = dek
+		d.log.Debug("Creating keyring in '%s'\n", d.dir)
+		if err := d.saveKeyring(kr); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		var f keyringFileFormat
+		if err := json.Unmarshal(b, &f); err != nil {
+			return err
+		}
+		kr.current = f.Current
+		for version, wrapped := range f.Keys {
+			raw, err := base64.StdEncoding.DecodeString(wrapped)
+			if err != nil {
+				return err
+			}
+			dek, err := unseal(master, raw)
+			if err != nil {
+				return ErrInvalidMasterKey
+			}
+			kr.keys[version] = dek
+		}
+		if _, ok := kr.keys[kr.current]; !ok {
+			return fmt.Errorf("keyring is missing current data key version %d", kr.current)
+		}
+	}
+
+	d.keys = kr
+	return nil
+}
+
+// saveKeyring wraps every data key with the keyring's master key and
+// atomically replaces the keyring file; callers hold kr.mutex.
+func (d *Driver) saveKeyring(kr *keyring) error {
+	f := keyringFileFormat{Current: kr.current, Keys: make(map[uint32]string)}
+	for version, dek := range kr.keys {
+		wrapped, err := seal(kr.master, dek)
+		if err != nil {
+			return err
+		}
+		f.Keys[version] = base64.StdEncoding.EncodeToString(wrapped)
+	}
+
+	b, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := keyringFile + ".tmp"
+	if err := d.backend.Put(tmpPath, append(b, '\n')); err != nil {
+		return err
+	}
+	return d.backend.Rename(tmpPath, keyringFile)
+}
+
+func (d *Driver) encrypt(b []byte) ([]byte, error) {
+	if d.keys == nil {
+		return b, nil
+	}
+
+	d.keys.mutex.RLock()
+	version, dek := d.keys.current, d.keys.keys[d.keys.current]
+	d.keys.mutex.RUnlock()
+
+	sealed, err := seal(dek, b)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+4+len(sealed))
+	out = append(out, encryptedMagic...)
+	out = binary.BigEndian.AppendUint32(out, version)
+	return append(out, sealed...), nil
+}
+
+func recordKeyVersion(b []byte) (uint32, bool) {
+	if !bytes.HasPrefix(b, encryptedMagic) || len(b) < len(encryptedMagic)+4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b[len(encryptedMagic):]), true
+}
+
+func (d *Driver) decrypt(b []byte) ([]byte, error) {
+	version, ok := recordKeyVersion(b)
+	if !ok {
+		return b, nil
+	}
+	if d.keys == nil {
+		return nil, fmt.Errorf("record is encrypted but no master key was provided")
+	}
+
+	d.keys.mutex.RLock()
+	dek, ok := d.keys.keys[version]
+	d.keys.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown data key version %d", version)
+	}
+
+	return unseal(dek, b[len(encryptedMagic)+4:])
+}
+
+// RotateKey re-wraps the data keys with newMasterKey and starts sealing new
+// writes with a fresh data key version. Existing records stay readable with
+// their original key version until Reencrypt rewrites them.
+func (d *Driver) RotateKey(newMasterKey []byte) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if d.keys == nil {
+		return fmt.Errorf("database is not encrypted - no key to rotate")
+	}
+	if err := d.authorize("*", PermAll); err != nil {
+		return err
+	}
+	if err := checkKey(newMasterKey); err != nil {
+		return err
+	}
+
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return err
+	}
+
+	d.keys.mutex.Lock()
+	defer d.keys.mutex.Unlock()
+
+	next := &keyring{master: newMasterKey, keys: make(map[uint32][]byte)}
+	for version, key := range d.keys.keys {
+		next.keys[version] = key
+		if version > next.current {
+			next.current = version
+		}
+	}
+	next.current++
+	next.keys[next.current] = dek
+
+	if err := d.saveKeyring(next); err != nil {
+		return err
+	}
+
+	d.keys.master, d.keys.current, d.keys.keys = next.master, next.current, next.keys
+	d.log.Debug("Rotated master key, data key version is now %d\n", next.current)
+	return nil
+}
+
+// Reencrypt rewrites every record sealed with an older data key version
+// and drops the versions no longer in use. It takes each collection lock in
+// turn, so it can run in the background while the database is in use.
+func (d *Driver) Reencrypt() error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if d.keys == nil {
+		return fmt.Errorf("database is not encrypted - nothing to re-encrypt")
+	}
+	if err := d.authorize("*", PermAll); err != nil {
+		return err
+	}
+
+	d.keys.mutex.RLock()
+	current := d.keys.current
+	d.keys.mutex.RUnlock()
+
+	collections, err := d.collectionPaths()
+	if err != nil {
+		return err
+	}
+	queues, err := d.queueCollections()
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range append(collections, queues...) {
+		if err := d.reencryptCollection(collection, current); err != nil {
+			return err
+		}
+	}
+	if err := d.reencryptQueries(); err != nil {
+		return err
+	}
+	if err := d.reencryptCounters(); err != nil {
+		return err
+	}
+	if err := d.reencryptSeries(); err != nil {
+		return err
+	}
+	if err := d.reencryptIdempotencyKeys(current); err != nil {
+		return err
+	}
+
+	d.keys.mutex.Lock()
+	defer d.keys.mutex.Unlock()
+
+	if d.keys.current != current {
+		// Rotated again while we were running; the newer pass cleans up.
+		return nil
+	}
+	for version := range d.keys.keys {
+		if version != current {
+			delete(d.keys.keys, version)
+		}
+	}
+	return d.saveKeyring(d.keys)
+}
+
+// reencryptCollection reseals the records of a collection and their
+// attachments. Its other files, such as the manifest, the sequence and the
+// sidecars of records, are stored as they are and left alone.
+func (d *Driver) reencryptCollection(collection string, current uint32) error {
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+	defer d.forgetUsage(collection)
+
+	files, err := d.backend.List(collection)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		p := path.Join(collection, strings.TrimSuffix(file, "/"))
+		if isDirName(file) {
+			if strings.HasSuffix(p, attachmentsSuffix) {
+				if err := d.reencryptAttachments(p, current); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := d.recordStem(collection, file); !ok {
+			continue
+		}
+		if err := d.reencryptFile(p, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reencryptAttachments reseals the attachments in dir, skipping the temp
+// files of those being put.
+func (d *Driver) reencryptAttachments(dir string, current uint32) error {
+	files, err := d.backend.List(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if isDirName(file) || strings.HasPrefix(file, ".") {
+			continue
+		}
+		if err := d.reencryptFile(path.Join(dir, file), current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reencryptFile reseals the file at p with the current data key, unless it
+// already is.
+func (d *Driver) reencryptFile(p string, current uint32) error {
+	b, err := d.backend.Get(p)
+	if err != nil {
+		return err
+	}
+	if version, ok := recordKeyVersion(b); ok && version == current {
+		return nil
+	}
+
+	if b, err = d.decrypt(b); err != nil {
+		return err
+	}
+	if b, err = d.encrypt(b); err != nil {
+		return err
+	}
+
+	if err := d.backend.Put(p+".tmp", b); err != nil {
+		return err
+	}
+	return d.backend.Rename(p+".tmp", p)
+}
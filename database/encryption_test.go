@@ -0,0 +1,188 @@
+package database
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testMasterKey = bytes.Repeat([]byte{1}, 32)
+	testNextKey   = bytes.Repeat([]byte{2}, 32)
+)
+
+func openEncrypted(t *testing.T, dir string, key []byte) *Driver {
+	t.Helper()
+	d, err := New(dir, &Options{MasterKey: key, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// rotate rotates the master key of d to testNextKey, reencrypts and
+// reopens the database with the new key.
+func rotate(t *testing.T, d *Driver, dir string) *Driver {
+	t.Helper()
+	if err := d.RotateKey(testNextKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reencrypt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return openEncrypted(t, dir, testNextKey)
+}
+
+func TestEncryptionAtRest(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada Lovelace"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "users", "ada.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, ok := recordKeyVersion(b); !ok || version != 1 || bytes.Contains(b, []byte("Lovelace")) {
+		t.Fatalf("record stored unsealed: %q", b)
+	}
+	d.Close()
+
+	if _, err := New(dir, &Options{MasterKey: testNextKey, TTLSweepInterval: -1}); err == nil {
+		t.Fatal("opened with the wrong master key")
+	}
+	d, err = New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err == nil {
+		t.Errorf("read a sealed record without the master key: %v", v)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RotateKey(testNextKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "bob", map[string]string{"name": "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d = openEncrypted(t, dir, testNextKey)
+	defer d.Close()
+	for key, version := range map[string]uint32{"ada": 1, "bob": 2} {
+		var v map[string]string
+		if err := d.Read("users", key, &v); err != nil {
+			t.Errorf("Read %s after RotateKey: %v", key, err)
+		}
+		b, err := d.backend.Get("users/" + key + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := recordKeyVersion(b); got != version {
+			t.Errorf("%s sealed with version %d, want %d", key, got, version)
+		}
+	}
+}
+
+func TestReencryptKeepsManifest(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	if err := d.SetManifest("users", Manifest{Schema: "has(doc.name)"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d = rotate(t, d, dir)
+	defer d.Close()
+	m, err := d.Manifest("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Schema != "has(doc.name)" {
+		t.Errorf("schema = %q after Reencrypt", m.Schema)
+	}
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Errorf("Read after Reencrypt = %v, %v", v, err)
+	}
+	if b, err := d.backend.Get("users/ada.json"); err != nil {
+		t.Fatal(err)
+	} else if version, ok := recordKeyVersion(b); !ok || version != 2 {
+		t.Errorf("record sealed with version %d, %v; want 2", version, ok)
+	}
+}
+
+func TestReencryptKeepsSequence(t *testing.T) {
+	dir := t.TempDir()
+	open := func(key []byte) *Driver {
+		d, err := New(dir, &Options{
+			MasterKey:        key,
+			Collections:      map[string]CollectionOptions{"orders": {IDs: IDSequence}},
+			TTLSweepInterval: -1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	d := open(testMasterKey)
+	if _, err := d.Insert("orders", map[string]int{"total": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.RotateKey(testNextKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reencrypt(); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	d = open(testNextKey)
+	defer d.Close()
+	key, err := d.Insert("orders", map[string]int{"total": 2})
+	if err != nil {
+		t.Fatalf("Insert after Reencrypt: %v", err)
+	}
+	if key != "00000000000000000002" {
+		t.Errorf("key = %q after Reencrypt, want the second in sequence", key)
+	}
+}
+
+func TestReencryptAttachments(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutAttachment("users", "ada", "avatar.png", strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+
+	d = rotate(t, d, dir)
+	defer d.Close()
+	rc, err := d.GetAttachment("users", "ada", "avatar.png")
+	if err != nil {
+		t.Fatalf("GetAttachment after Reencrypt: %v", err)
+	}
+	defer rc.Close()
+	if b, err := io.ReadAll(rc); err != nil || string(b) != "png" {
+		t.Errorf("attachment = %q, %v after Reencrypt", b, err)
+	}
+}
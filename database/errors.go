@@ -0,0 +1,36 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// The Is helpers classify the errors of the driver and of its backends,
+// which wrap the errors of the file system they hit, so callers can tell a
+// missing record from a file they may not read without matching messages.
+
+// IsNotFound reports whether err is of a record, collection, file or other
+// named thing that does not exist.
+func IsNotFound(err error) bool {
+	return isNotExist(err)
+}
+
+// IsPermission reports whether err is of the file system refusing access
+// or of the caller lacking a permission, as ErrPermissionDenied is.
+func IsPermission(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, ErrPermissionDenied)
+}
+
+// IsDiskFull reports whether err is of storage out of room, a DiskFullError
+// or a file system error the driver did not turn into one.
+func IsDiskFull(err error) bool {
+	return errors.Is(err, ErrDiskFull) || isNoSpace(err)
+}
+
+// IsConflict reports whether err is of a write losing to another: a
+// revision that moved on, a lease another holds, a key colliding with one
+// that differs only in case, or a file created since it was checked for.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict) || errors.Is(err, ErrLeaseHeld) || errors.Is(err, ErrKeyCollision) ||
+		errors.Is(err, fs.ErrExist)
+}
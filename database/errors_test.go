@@ -0,0 +1,69 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsHelpers(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("reading users/ada: %w", err) }
+	for _, c := range []struct {
+		err                                      error
+		notFound, permission, diskFull, conflict bool
+	}{
+		{err: wrap(fs.ErrNotExist), notFound: true},
+		{err: &fs.PathError{Op: "open", Path: "users/ada.json", Err: fs.ErrNotExist}, notFound: true},
+		{err: wrap(&fs.PathError{Op: "open", Path: "users/ada.json", Err: fs.ErrPermission}), permission: true},
+		{err: wrap(ErrPermissionDenied), permission: true},
+		{err: &DiskFullError{Path: "users/ada.json"}, diskFull: true},
+		{err: wrap(ErrDiskFull), diskFull: true},
+		{err: wrap(ErrConflict), conflict: true},
+		{err: wrap(ErrLeaseHeld), conflict: true},
+		{err: wrap(ErrKeyCollision), conflict: true},
+		{err: wrap(fs.ErrExist), conflict: true},
+		{err: ErrInvalidName},
+		{err: errors.New("corrupt record")},
+		{err: nil},
+	} {
+		if got := IsNotFound(c.err); got != c.notFound {
+			t.Errorf("IsNotFound(%v) = %v", c.err, got)
+		}
+		if got := IsPermission(c.err); got != c.permission {
+			t.Errorf("IsPermission(%v) = %v", c.err, got)
+		}
+		if got := IsDiskFull(c.err); got != c.diskFull {
+			t.Errorf("IsDiskFull(%v) = %v", c.err, got)
+		}
+		if got := IsConflict(c.err); got != c.conflict {
+			t.Errorf("IsConflict(%v) = %v", c.err, got)
+		}
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]string{"name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"Touch":            d.Touch("users", "nobody", time.Hour),
+		"PutAttachment":    d.PutAttachment("users", "nobody", "photo", strings.NewReader("jpeg")),
+		"DeleteAttachment": d.DeleteAttachment("users", "ada", "photo"),
+		"RebuildView":      d.RebuildView("none"),
+		"RebuildGeoIndex":  d.RebuildGeoIndex("users"),
+		"DropNamespace":    d.DropNamespace("none"),
+	} {
+		if !IsNotFound(err) {
+			t.Errorf("%s of a missing thing = %v, want IsNotFound", name, err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+)
+
+// ExportRecord is one line of an Export.
+type ExportRecord struct {
+	Collection string          `json:"collection"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value"`
+}
+
+// Export writes every record of the given collections, or of all
+// collections including namespaced ones, to w as JSON lines of decoded,
+// decrypted documents. It needs the JSON codec.
+func (d *Driver) Export(w io.Writer, collections ...string) (int, error) {
+	return d.export(w, collections, nil, nil)
+}
+
+// export is Export of only the records with keys, if set, and with every
+// document passed through transform, if set, which drops those it returns
+// nil for.
+func (d *Driver) export(w io.Writer, collections, keys []string, transform func(collection string, raw json.RawMessage) (json.RawMessage, error)) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	err := d.exportRecords(collections, keys, func(collection, key string, raw json.RawMessage) error {
+		var err error
+		if transform != nil {
+			if raw, err = transform(collection, raw); err != nil || raw == nil {
+				return err
+			}
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return err
+		}
+		if err := enc.Encode(ExportRecord{Collection: collection, Key: key, Value: compact.Bytes()}); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// exportRecords calls fn with every record of collections, or of all
+// collections including namespaced ones, or only those with keys if set,
+// as JSON.
+func (d *Driver) exportRecords(collections, keys []string, fn func(collection, key string, raw json.RawMessage) error) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if len(collections) == 0 {
+		if err := d.authorize("*", PermRead); err != nil {
+			return err
+		}
+		var err error
+		if collections, err = d.collectionPaths(); err != nil {
+			return err
+		}
+	}
+
+	for _, collection := range collections {
+		names := keys
+		if names == nil {
+			var err error
+			if names, err = d.Keys(collection); err != nil {
+				return err
+			}
+		}
+		for _, key := range names {
+			var raw json.RawMessage
+			if err := d.Read(collection, key, &raw); err != nil {
+				return err
+			}
+			if len(raw) == 0 {
+				// Missing, or expired since it was listed.
+				continue
+			}
+			if err := fn(collection, key, raw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportFormat is how ExportWith writes the records.
+type ExportFormat int
+
+const (
+	// ExportJSONLines writes them as Export does, for Import.
+	ExportJSONLines ExportFormat = iota
+	// ExportCSV writes a header line of _collection, _key and the fields,
+	// with dots for nested fields, then a line per record. Values other
+	// than strings are written as JSON, and missing fields are empty.
+	ExportCSV
+)
+
+// ExportOptions picks the records ExportWith writes, and their fields.
+type ExportOptions struct {
+	Format ExportFormat
+	// Collections are those exported, every one including namespaced ones
+	// if empty.
+	Collections []string
+	// Keys, if set, exports only the records with these keys.
+	Keys []string
+	// Where and Filter export only the records a Query with them would
+	// find.
+	Where  map[string][]string
+	Filter string
+	// Fields, if set, exports only these fields of each record, and Omit
+	// leaves these out, with dots for nested fields. The CSV columns are
+	// Fields, or every field of the records exported if it is empty.
+	Fields []string
+	Omit   []string
+}
+
+// ExportWith is Export of only the records and fields opts pick, as JSON
+// lines or CSV, for a partner that is to get some of the data, and not
+// all of it. Records that are not documents are exported only without
+// Where or Filter, and never as CSV.
+func (d *Driver) ExportWith(w io.Writer, opts ExportOptions) (int, error) {
+	fields := slices.Concat(opts.Fields, opts.Omit)
+	for field := range opts.Where {
+		fields = append(fields, field)
+	}
+	for _, field := range fields {
+		if err := validField(field); err != nil {
+			return 0, err
+		}
+	}
+	match, err := Query{Where: opts.Where, Filter: opts.Filter}.matcher()
+	if err != nil {
+		return 0, err
+	}
+
+	switch opts.Format {
+	case ExportJSONLines:
+		return d.export(w, opts.Collections, opts.Keys, func(collection string, raw json.RawMessage) (json.RawMessage, error) {
+			doc, ok, err := opts.pick(match, raw)
+			if err != nil || !ok {
+				return nil, err
+			}
+			if doc == nil {
+				return raw, nil
+			}
+			return json.Marshal(doc)
+		})
+	case ExportCSV:
+		return d.exportCSV(w, opts, match)
+	}
+	return 0, fmt.Errorf("unknown export format %d", opts.Format)
+}
+
+// pick reports whether opts export a record, and returns its document with
+// the fields they export, nil if it is not a document.
+func (opts ExportOptions) pick(match func(map[string]interface{}) bool, raw json.RawMessage) (map[string]interface{}, bool, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return nil, opts.Where == nil && opts.Filter == "", nil
+	}
+	if !match(doc) {
+		return nil, false, nil
+	}
+	// Decoded again keeping numbers exact, as they are written out.
+	doc = nil
+	if err := decodeExact(raw, &doc); err != nil {
+		return nil, false, err
+	}
+	if opts.Fields != nil {
+		doc = project(doc, opts.Fields)
+	}
+	for _, field := range opts.Omit {
+		deleteField(doc, field)
+	}
+	return doc, true, nil
+}
+
+// exportCSV writes the records opts pick as CSV, reading them twice to
+// find the columns unless opts name them.
+func (d *Driver) exportCSV(w io.Writer, opts ExportOptions, match func(map[string]interface{}) bool) (int, error) {
+	each := func(fn func(collection, key string, doc map[string]interface{}) error) error {
+		return d.exportRecords(opts.Collections, opts.Keys, func(collection, key string, raw json.RawMessage) error {
+			doc, ok, err := opts.pick(match, raw)
+			if err != nil || !ok || doc == nil {
+				return err
+			}
+			return fn(collection, key, doc)
+		})
+	}
+
+	columns := opts.Fields
+	if columns == nil {
+		seen := make(map[string]bool)
+		err := each(func(collection, key string, doc map[string]interface{}) error {
+			leafFields("", doc, seen)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		for field := range seen {
+			columns = append(columns, field)
+		}
+		sort.Strings(columns)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"_collection", "_key"}, columns...)); err != nil {
+		return 0, err
+	}
+	n := 0
+	row := make([]string, 2+len(columns))
+	err := each(func(collection, key string, doc map[string]interface{}) error {
+		row[0], row[1] = collection, key
+		for i, field := range columns {
+			v, ok := getField(doc, field)
+			var err error
+			if row[2+i], err = csvValue(v, ok); err != nil {
+				return err
+			}
+		}
+		n++
+		return cw.Write(row)
+	})
+	if err != nil {
+		return n, err
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
+
+// leafFields adds the dotted paths of the fields of doc that are not
+// objects to fields.
+func leafFields(prefix string, doc map[string]interface{}, fields map[string]bool) {
+	for name, v := range doc {
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			leafFields(joinPath(prefix, name), m, fields)
+			continue
+		}
+		fields[joinPath(prefix, name)] = true
+	}
+}
+
+func csvValue(v interface{}, ok bool) (string, error) {
+	if !ok {
+		return "", nil
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Import writes every record of an Export read from r and returns how many
+// were written. Existing records with the same keys are overwritten.
+func (d *Driver) Import(r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	n := 0
+	for dec.More() {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err != nil {
+			return n, fmt.Errorf("invalid export record %d: %w", n+1, err)
+		}
+		if err := d.Write(rec.Collection, rec.Key, rec.Value); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
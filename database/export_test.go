@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// writeCustomers writes the users the tests of ExportWith pick from.
+func writeCustomers(t *testing.T, d *Driver) {
+	t.Helper()
+	for key, v := range map[string]interface{}{
+		"a": map[string]interface{}{"country": "PK", "name": "Ali", "phone": "123", "address": map[string]interface{}{"city": "Lahore", "zip": 54000}},
+		"b": map[string]interface{}{"country": "US", "name": "Bob", "phone": "456"},
+		"c": map[string]interface{}{"country": "PK", "name": "Cara", "tags": []string{"x"}},
+		"s": "scalar",
+	} {
+		if err := d.Write("users", key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExportWith(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	writeCustomers(t, d)
+
+	var buf bytes.Buffer
+	n, err := d.ExportWith(&buf, ExportOptions{Collections: []string{"users"}, Filter: "doc.country == 'PK'", Omit: []string{"phone", "address.zip"}})
+	want := `{"collection":"users","key":"a","value":{"address":{"city":"Lahore"},"country":"PK","name":"Ali"}}
+{"collection":"users","key":"c","value":{"country":"PK","name":"Cara","tags":["x"]}}
+`
+	if err != nil || n != 2 || buf.String() != want {
+		t.Errorf("ExportWith of a filter = %d, %v:\n%s\nwant 2:\n%s", n, err, buf.String(), want)
+	}
+
+	buf.Reset()
+	n, err = d.ExportWith(&buf, ExportOptions{Keys: []string{"s", "none"}})
+	if want := `{"collection":"users","key":"s","value":"scalar"}` + "\n"; err != nil || n != 1 || buf.String() != want {
+		t.Errorf("ExportWith of keys = %d, %v:\n%s\nwant 1:\n%s", n, err, buf.String(), want)
+	}
+
+	if _, err := d.ExportWith(&buf, ExportOptions{Omit: []string{"bad..field"}}); err == nil {
+		t.Error("ExportWith omitting an invalid field succeeded")
+	}
+}
+
+func TestExportWithCSV(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	writeCustomers(t, d)
+
+	var buf bytes.Buffer
+	n, err := d.ExportWith(&buf, ExportOptions{Format: ExportCSV, Collections: []string{"users"}, Where: map[string][]string{"country": {"PK"}}, Omit: []string{"phone"}})
+	want := strings.Join([]string{
+		"_collection,_key,address.city,address.zip,country,name,tags",
+		"users,a,Lahore,54000,PK,Ali,",
+		`users,c,,,PK,Cara,"[""x""]"`,
+		"",
+	}, "\n")
+	if err != nil || n != 2 || buf.String() != want {
+		t.Errorf("ExportWith as CSV = %d, %v:\n%s\nwant 2:\n%s", n, err, buf.String(), want)
+	}
+
+	buf.Reset()
+	n, err = d.ExportWith(&buf, ExportOptions{Format: ExportCSV, Keys: []string{"b", "s"}, Fields: []string{"name", "address"}})
+	want = "_collection,_key,name,address\nusers,b,Bob,\n"
+	if err != nil || n != 1 || buf.String() != want {
+		t.Errorf("ExportWith of fields as CSV = %d, %v:\n%s\nwant 1:\n%s", n, err, buf.String(), want)
+	}
+}
@@ -0,0 +1,755 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidExpr = errors.New("invalid expression")
+
+// Expr is a compiled filter expression, a small subset of CEL evaluated
+// against a document named doc:
+//
+//	doc.age > 25 && doc.address.city == 'Karachi'
+//	doc.tags.size() > 0 || !has(doc.email)
+//	doc.name.startsWith("W") && doc.role in ["admin", "owner"]
+//
+// It has number, string, bool, null and list literals; the operators ||,
+// &&, !, ==, !=, <, <=, >, >=, in, +, -, *, / and %; fields and indexes
+// (doc.tags[0], doc["first name"]); has(field) and size(v); and the string
+// methods contains, startsWith, endsWith and matches (a regular expression).
+// Missing fields and indexes are null. Expressions are evaluated per
+// document, so a type error such as comparing a string with a number is
+// only found then.
+type Expr struct {
+	src  string
+	root *exprNode
+}
+
+type exprNode struct {
+	eval func(doc map[string]interface{}) (interface{}, error)
+	// A field selection keeps what it selects from, for has().
+	parent *exprNode
+	field  string
+	// literal is set on constants, with their value in value.
+	literal bool
+	value   interface{}
+}
+
+// Compile parses a filter expression.
+func Compile(src string) (*Expr, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against doc. Numbers come out as float64.
+func (e *Expr) Eval(doc map[string]interface{}) (interface{}, error) {
+	return e.root.eval(doc)
+}
+
+// Match reports whether the expression is true for doc. Errors and values
+// other than true do not match.
+func (e *Expr) Match(doc map[string]interface{}) bool {
+	v, err := e.Eval(doc)
+	b, ok := v.(bool)
+	return err == nil && ok && b
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func lexExpr(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' ||
+				src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d: bad number %q", ErrInvalidExpr, i, src[i:j])
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[i:j], num: n, pos: i})
+			i = j
+		case r == '\'' || r == '"':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d: %v", ErrInvalidExpr, i, err)
+			}
+			toks = append(toks, token{kind: tokString, text: s, pos: i})
+			i += n
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			if i+2 <= len(src) {
+				switch two := src[i : i+2]; two {
+				case "||", "&&", "==", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			if op == "" && strings.ContainsRune("!<>+-*/%()[],.", r) {
+				op = string(r)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%w at %d: unexpected %q", ErrInvalidExpr, i, r)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads the quoted string at the start of src, returning it and
+// the length of its source.
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i++; i == len(src) {
+				break
+			}
+			switch src[i] {
+			case '\\', '\'', '"':
+				b.WriteByte(src[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type exprParser struct {
+	toks []token
+	i    int
+}
+
+func (p *exprParser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *exprParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword op.
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %s", op, t)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%w at %d: %s", ErrInvalidExpr, t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right *exprNode
+		if right, err = p.parseAnd(); err == nil {
+			left = logical("||", left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.accept("&&") {
+		var right *exprNode
+		if right, err = p.parseComparison(); err == nil {
+			left = logical("&&", left, right)
+		}
+	}
+	return left, err
+}
+
+func logical(op string, left, right *exprNode) *exprNode {
+	or := op == "||"
+	return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+		for _, n := range []*exprNode{left, right} {
+			v, err := n.eval(doc)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s needs bools, not %s", op, typeName(v))
+			}
+			if b == or {
+				return b, nil
+			}
+		}
+		return !or, nil
+	}}
+}
+
+func (p *exprParser) parseComparison() (*exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode(op, left, right, compareOp(op)), nil
+	}
+	return left, nil
+}
+
+func compareOp(op string) func(a, b interface{}) (interface{}, error) {
+	return func(a, b interface{}) (interface{}, error) {
+		switch op {
+		case "==":
+			return compareValues(a, b) == 0, nil
+		case "!=":
+			return compareValues(a, b) != 0, nil
+		case "in":
+			switch b := b.(type) {
+			case []interface{}:
+				for _, e := range b {
+					if compareValues(a, e) == 0 {
+						return true, nil
+					}
+				}
+				return false, nil
+			case map[string]interface{}:
+				s, ok := a.(string)
+				if !ok {
+					return nil, fmt.Errorf("map keys are strings, not %s", typeName(a))
+				}
+				_, ok = b[s]
+				return ok, nil
+			}
+			return nil, fmt.Errorf("in needs a list or a map, not %s", typeName(b))
+		}
+		if r := typeRank(a); r != typeRank(b) || r != 2 && r != 3 {
+			return nil, fmt.Errorf("cannot compare %s with %s", typeName(a), typeName(b))
+		}
+		c := compareValues(a, b)
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+}
+
+func (p *exprParser) parseSum() (*exprNode, error) {
+	left, err := p.parseProduct()
+	for err == nil {
+		op := ""
+		switch {
+		case p.accept("+"):
+			op = "+"
+		case p.accept("-"):
+			op = "-"
+		default:
+			return left, nil
+		}
+		var right *exprNode
+		if right, err = p.parseProduct(); err == nil {
+			left = binaryNode(op, left, right, arithmetic(op))
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) parseProduct() (*exprNode, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		op := ""
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		case p.accept("%"):
+			op = "%"
+		default:
+			return left, nil
+		}
+		var right *exprNode
+		if right, err = p.parseUnary(); err == nil {
+			left = binaryNode(op, left, right, arithmetic(op))
+		}
+	}
+	return nil, err
+}
+
+func arithmetic(op string) func(a, b interface{}) (interface{}, error) {
+	return func(a, b interface{}) (interface{}, error) {
+		if op == "+" {
+			switch a := a.(type) {
+			case string:
+				if b, ok := b.(string); ok {
+					return a + b, nil
+				}
+			case []interface{}:
+				if b, ok := b.([]interface{}); ok {
+					return append(append([]interface{}{}, a...), b...), nil
+				}
+			}
+		}
+		x, ok := toFloat(a)
+		y, ok2 := toFloat(b)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("cannot apply %s to %s and %s", op, typeName(a), typeName(b))
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return math.Mod(x, y), nil
+	}
+}
+
+func binaryNode(op string, left, right *exprNode, fn func(a, b interface{}) (interface{}, error)) *exprNode {
+	return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+		a, err := left.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		return fn(a, b)
+	}}
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	switch {
+	case p.accept("!"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+			v, err := x.eval(doc)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("! needs a bool, not %s", typeName(v))
+			}
+			return !b, nil
+		}}, nil
+	case p.accept("-"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+			v, err := x.eval(doc)
+			if err != nil {
+				return nil, err
+			}
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot negate %s", typeName(v))
+			}
+			return -f, nil
+		}}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (*exprNode, error) {
+	n, err := p.parsePrimary()
+	for err == nil {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.errorf(t, "expected a field name, found %s", t)
+			}
+			if p.peek().text == "(" && p.peek().kind == tokOp {
+				n, err = p.parseMethod(n, t)
+			} else {
+				n = selectField(n, t.text)
+			}
+		case p.accept("["):
+			var index *exprNode
+			if index, err = p.parseOr(); err == nil {
+				if err = p.expect("]"); err == nil {
+					n = indexNode(n, index)
+				}
+			}
+		default:
+			return n, nil
+		}
+	}
+	return nil, err
+}
+
+func selectField(parent *exprNode, field string) *exprNode {
+	return &exprNode{parent: parent, field: field, eval: func(doc map[string]interface{}) (interface{}, error) {
+		v, err := parent.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			return v[field], nil
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no field %s on %s", field, typeName(v))
+	}}
+}
+
+func indexNode(parent, index *exprNode) *exprNode {
+	n := &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+		v, err := parent.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		i, err := index.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			s, ok := i.(string)
+			if !ok {
+				return nil, fmt.Errorf("map keys are strings, not %s", typeName(i))
+			}
+			return v[s], nil
+		case []interface{}:
+			f, ok := toFloat(i)
+			if !ok || f != math.Trunc(f) {
+				return nil, fmt.Errorf("list indexes are whole numbers, not %s", formatField(i))
+			}
+			if f < 0 || f >= float64(len(v)) {
+				return nil, nil
+			}
+			return v[int(f)], nil
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot index %s", typeName(v))
+	}}
+	if index.literal {
+		if s, ok := index.value.(string); ok {
+			n.parent, n.field = parent, s
+		}
+	}
+	return n
+}
+
+func (p *exprParser) parseMethod(recv *exprNode, name token) (*exprNode, error) {
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if name.text == "size" {
+		if len(args) != 0 {
+			return nil, p.errorf(name, "size() takes no arguments")
+		}
+		return sizeNode(recv), nil
+	}
+	var test func(s, arg string) bool
+	switch name.text {
+	case "contains":
+		test = strings.Contains
+	case "startsWith":
+		test = strings.HasPrefix
+	case "endsWith":
+		test = strings.HasSuffix
+	case "matches":
+		if len(args) == 1 && args[0].literal {
+			pattern, _ := args[0].value.(string)
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, p.errorf(name, "%v", err)
+			}
+			test = func(s, _ string) bool { return re.MatchString(s) }
+		} else {
+			test = func(s, pattern string) bool {
+				re, err := regexp.Compile(pattern)
+				return err == nil && re.MatchString(s)
+			}
+		}
+	default:
+		return nil, p.errorf(name, "unknown method %s", name.text)
+	}
+	if len(args) != 1 {
+		return nil, p.errorf(name, "%s() takes one argument", name.text)
+	}
+	method, arg := name.text, args[0]
+	return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+		v, err := recv.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		a, err := arg.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		as, ok2 := a.(string)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%s needs strings, not %s and %s", method, typeName(v), typeName(a))
+		}
+		return test(s, as), nil
+	}}, nil
+}
+
+func (p *exprParser) parseArgs() ([]*exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []*exprNode
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sizeNode(x *exprNode) *exprNode {
+	return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+		v, err := x.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("no size for %s", typeName(v))
+	}}
+}
+
+func literal(v interface{}) *exprNode {
+	return &exprNode{literal: true, value: v, eval: func(map[string]interface{}) (interface{}, error) { return v, nil }}
+}
+
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return literal(t.num), nil
+	case tokString:
+		return literal(t.text), nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal(true), nil
+		case "false":
+			return literal(false), nil
+		case "null":
+			return literal(nil), nil
+		case "doc":
+			return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) { return doc, nil }}, nil
+		case "size":
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != 1 {
+				return nil, p.errorf(t, "size() takes one argument")
+			}
+			return sizeNode(args[0]), nil
+		case "has":
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != 1 || args[0].parent == nil {
+				return nil, p.errorf(t, "has() takes a field, such as has(doc.email)")
+			}
+			parent, field := args[0].parent, args[0].field
+			return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+				v, err := parent.eval(doc)
+				if err != nil {
+					return nil, err
+				}
+				m, ok := v.(map[string]interface{})
+				if !ok {
+					return false, nil
+				}
+				_, ok = m[field]
+				return ok, nil
+			}}, nil
+		}
+		return nil, p.errorf(t, "unknown name %s", t.text)
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			var elems []*exprNode
+			for !p.accept("]") {
+				if len(elems) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				e, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, e)
+			}
+			return &exprNode{eval: func(doc map[string]interface{}) (interface{}, error) {
+				list := make([]interface{}, len(elems))
+				for i, e := range elems {
+					v, err := e.eval(doc)
+					if err != nil {
+						return nil, err
+					}
+					list[i] = v
+				}
+				return list, nil
+			}}, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
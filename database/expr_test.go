@@ -0,0 +1,124 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func exprDoc(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(`{"name": "Waqar", "age": 30, "active": true, "tags": ["a", "b"],
+		"address": {"city": "Karachi"}, "first name": "W", "n": null}`))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestExpr(t *testing.T) {
+	doc := exprDoc(t)
+	for _, tc := range []struct {
+		src  string
+		want interface{}
+	}{
+		{`doc.age > 25 && doc.address.city == 'Karachi'`, true},
+		{`doc.age > 25 && doc.address.city == "Lahore"`, false},
+		{`doc.tags.size() > 0 || !has(doc.email)`, true},
+		{`!has(doc.email)`, true},
+		{`has(doc.n)`, true},
+		{`has(doc.address.city)`, true},
+		{`has(doc["first name"])`, true},
+		{`doc.name.startsWith("W") && doc.name in ["Waqar", "Ali"]`, true},
+		{`doc.name.endsWith("r") && doc.name.contains("aqa")`, true},
+		{`doc.name.matches("^Wa.*r$")`, true},
+		{`doc.age + 1`, 31.0},
+		{`doc.age % 7 * 2 - -1`, 5.0},
+		{`doc.age / 4`, 7.5},
+		{`(1 + 2) * 3 == 9`, true},
+		{`doc.tags[1]`, "b"},
+		{`doc.tags[5] == null`, true},
+		{`doc.missing.deep == null`, true},
+		{`"a" in doc.tags`, true},
+		{`"city" in doc.address`, true},
+		{`size(doc.name) == 5`, true},
+		{`doc.name + "!"`, "Waqar!"},
+		{`doc.age == 30.0`, true},
+		{`doc.age != 30 || doc.name <= "X"`, true},
+		{`doc.active && !false`, true},
+		{`1e2 == 100`, true},
+		{`'it\'s'`, "it's"},
+		// The right side is not evaluated once the left decides.
+		{`false && doc.name > 1`, false},
+		{`true || doc.name > 1`, true},
+	} {
+		e, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("Compile(%s) = %v", tc.src, err)
+			continue
+		}
+		if got, err := e.Eval(doc); err != nil || got != tc.want {
+			t.Errorf("%s = %v, %v; want %v", tc.src, got, err, tc.want)
+		}
+		if e.String() != tc.src {
+			t.Errorf("String = %q, want %q", e.String(), tc.src)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, src := range []string{``, `doc.age >`, `foo.bar`, `doc.age = 1`, `has(1)`, `doc.name.nope()`, `"abc`, `(1`, `doc.name.matches("(")`, `1 2`, `[1,`} {
+		if _, err := Compile(src); !errors.Is(err, ErrInvalidExpr) {
+			t.Errorf("Compile(%q) = %v, want ErrInvalidExpr", src, err)
+		}
+	}
+
+	doc := exprDoc(t)
+	for _, src := range []string{`doc.name > 1`, `doc.age / 0`, `doc.name && true`, `doc.name.foo`, `-doc.name`} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q) = %v", src, err)
+		}
+		if _, err := e.Eval(doc); err == nil {
+			t.Errorf("%s evaluated", src)
+		}
+		if e.Match(doc) {
+			t.Errorf("%s matched despite failing", src)
+		}
+	}
+	if e, _ := Compile(`doc.age`); e.Match(doc) {
+		t.Error("a number matched")
+	}
+}
+
+func TestFilters(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for key, age := range map[string]int{"a": 30, "b": 20, "c": 40} {
+		if err := d.Write("users", key, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := d.Find(Query{Collection: "users", Filter: "doc.age >= 25", Where: map[string][]string{"age": {"30", "20"}}})
+	if err != nil || resultKeys(results) != "a" {
+		t.Errorf("Find with a filter = %v, %v", results, err)
+	}
+	if err := d.SaveQuery("bad", Query{Collection: "users", Filter: "doc.age >"}); !errors.Is(err, ErrInvalidExpr) {
+		t.Errorf("SaveQuery with a bad filter = %v, want ErrInvalidExpr", err)
+	}
+
+	docs, err := d.Aggregate("users", []Stage{{Sort: []string{"-age"}}, {Filter: "doc._key != 'a'"}, {Project: []string{"_key"}}})
+	if b, _ := json.Marshal(docs); err != nil || string(b) != `[{"_key":"c"},{"_key":"b"}]` {
+		t.Errorf("Aggregate with a filter = %s, %v", b, err)
+	}
+	if _, err := d.Aggregate("users", []Stage{{Filter: "doc.age >"}}); !errors.Is(err, ErrInvalidPipeline) || !errors.Is(err, ErrInvalidExpr) {
+		t.Errorf("Aggregate with a bad filter = %v, want ErrInvalidPipeline and ErrInvalidExpr", err)
+	}
+}
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// externalSettle is how long changes to the database directory are
+	// gathered before they are reconciled, so the steps of one write are
+	// taken together.
+	externalSettle = 50 * time.Millisecond
+	// externalStampAge is how long the Driver remembers the state it left a
+	// file in, to tell its own changes apart from other processes'.
+	externalStampAge = time.Minute
+)
+
+// externalWatch follows the changes made to the files of the database by
+// other processes, and people editing them, so the in-memory state of the
+// Driver can be brought up to date.
+type externalWatch struct {
+	root    string
+	watcher *fsnotify.Watcher
+	// dirs are the directories watched, only used by the watch goroutine.
+	dirs map[string]bool
+
+	mutex  sync.Mutex
+	stamps map[string]fileStamp
+}
+
+// fileStamp is the state the Driver left a path in.
+type fileStamp struct {
+	exists bool
+	dir    bool
+	size   int64
+	mod    time.Time
+	at     time.Time
+}
+
+func (s fileStamp) matches(o fileStamp) bool {
+	if s.exists != o.exists || s.dir != o.dir {
+		return false
+	}
+	// Directories change with their entries, which are stamped themselves.
+	return s.dir || s.size == o.size && s.mod.Equal(o.mod)
+}
+
+func (w *externalWatch) stat(p string) fileStamp {
+	fi, err := os.Lstat(filepath.Join(w.root, filepath.FromSlash(p)))
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, dir: fi.IsDir(), size: fi.Size(), mod: fi.ModTime()}
+}
+
+// touch records the state the Driver left p in.
+func (w *externalWatch) touch(p string) {
+	s := w.stat(p)
+	s.at = time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.stamps[p] = s
+}
+
+// own reports whether p is as the Driver left it, itself or deleted with a
+// directory above it.
+func (w *externalWatch) own(p string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if s, ok := w.stamps[p]; ok {
+		return s.matches(w.stat(p))
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if s, ok := w.stamps[dir]; ok && !s.exists {
+			return !w.stat(dir).exists
+		}
+	}
+	return false
+}
+
+func (w *externalWatch) prune() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for p, s := range w.stamps {
+		if time.Since(s.at) > externalStampAge {
+			delete(w.stamps, p)
+		}
+	}
+}
+
+// rel returns the backend path of a file system path under the root.
+func (w *externalWatch) rel(name string) (string, bool) {
+	rel, err := filepath.Rel(w.root, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	p := filepath.ToSlash(rel)
+	return p, !internalPath(p)
+}
+
+// internalPath reports whether p is, or is in, one of the files the Driver
+// keeps besides records, as temp files, sidecars and attachments.
+func internalPath(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".tmp") || strings.HasSuffix(part, attachmentsSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// add watches the directory p and every one below it, adding the files in
+// them to pending when it is set.
+func (w *externalWatch) add(p string, pending map[string]bool) error {
+	return filepath.WalkDir(filepath.Join(w.root, filepath.FromSlash(p)), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if isNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, ok := w.rel(name)
+		if !ok && name != w.root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			if pending != nil {
+				pending[rel] = true
+			}
+			return nil
+		}
+		if err := w.watcher.Add(name); err != nil {
+			return err
+		}
+		if rel != "" {
+			w.dirs[rel] = true
+		}
+		return nil
+	})
+}
+
+// startExternalWatch watches the database directory for Options.WatchExternal.
+func (d *Driver) startExternalWatch() error {
+	files, ok := d.backend.(*fileBackend)
+	if !ok {
+		return fmt.Errorf("watching for external changes needs the file backend")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w := &externalWatch{root: files.root, watcher: watcher, dirs: make(map[string]bool), stamps: make(map[string]fileStamp)}
+	if err := w.add("", nil); err != nil {
+		watcher.Close()
+		return err
+	}
+	files.changed = w.touch
+	d.onClose(watcher.Close)
+
+	d.goBackground(func(done <-chan struct{}) {
+		pending := make(map[string]bool)
+		var settle <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				p, ok := w.rel(e.Name)
+				if !ok {
+					continue
+				}
+				if e.Has(fsnotify.Create) && w.stat(p).dir {
+					if err := w.add(p, pending); err != nil {
+						d.logEvent(slog.LevelError, "Watching directory failed", slog.String("path", p), slog.Any("error", err))
+					}
+				}
+				pending[p] = true
+				if settle == nil {
+					settle = time.After(externalSettle)
+				}
+			case <-settle:
+				settle = nil
+				d.reconcileExternal(w, pending)
+				pending = make(map[string]bool)
+				w.prune()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				d.logEvent(slog.LevelError, "Watching for external changes failed", slog.Any("error", err))
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					d.forgetExternal()
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// reconcileExternal brings the Driver up to date with the changed paths,
+// skipping those it changed itself.
+func (d *Driver) reconcileExternal(w *externalWatch, pending map[string]bool) {
+	paths := make([]string, 0, len(pending))
+	for p := range pending {
+		paths = append(paths, p)
+	}
+	// Directories sort before what is in them.
+	sort.Strings(paths)
+
+	var removed []string
+	for _, p := range paths {
+		if inDirs(removed, p) {
+			continue
+		}
+		if w.dirs[p] && !w.stat(p).exists {
+			for dir := range w.dirs {
+				if dir == p || strings.HasPrefix(dir, p+"/") {
+					delete(w.dirs, dir)
+				}
+			}
+			removed = append(removed, p)
+			d.externalDelete(w, p)
+			continue
+		}
+		d.externalChange(w, p)
+	}
+}
+
+// inDirs reports whether p is below any of dirs.
+func inDirs(dirs []string, p string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// externalChange reconciles a record file another process wrote or
+// deleted. Writes through this Driver are seen to be its own under the
+// collection lock, once they are done.
+func (d *Driver) externalChange(w *externalWatch, p string) {
+	collection, file := path.Split(p)
+	collection = strings.TrimSuffix(collection, "/")
+	stem, ok := d.recordStem(collection, file)
+	if !ok || collection == "" || validCollection(collection) != nil {
+		return
+	}
+	key := decodeKey(stem)
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if w.own(p) {
+		return
+	}
+
+	op := EventPut
+	if _, err := d.readRecord(collection, key); isNotExist(err) {
+		op = EventDelete
+		d.forgetKeyCase(collection, stem)
+	} else {
+		d.learnKeyCase(collection, stem)
+	}
+	d.forgetUsage(collection)
+	d.logEvent(slog.LevelInfo, "Record changed externally", slog.String("collection", collection), slog.String("key", key), slog.String("op", op.String()))
+	d.notifyEvent(Event{Op: op, Collection: collection, Key: key, External: true})
+}
+
+// externalDelete reconciles a collection another process deleted.
+func (d *Driver) externalDelete(w *externalWatch, collection string) {
+	if validCollection(collection) != nil {
+		return
+	}
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if w.own(collection) {
+		return
+	}
+
+	d.forgetKeyCase(collection, "")
+	d.forgetUsage(collection)
+	d.logEvent(slog.LevelInfo, "Collection deleted externally", slog.String("collection", collection))
+	d.notifyEvent(Event{Op: EventDelete, Collection: collection, External: true})
+}
+
+// forgetExternal drops the indexes changes may have been missed for, when
+// the file system could not keep up reporting them.
+func (d *Driver) forgetExternal() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	clear(d.caseIndexes)
+	clear(d.usages)
+}
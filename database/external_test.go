@@ -0,0 +1,99 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the next event that is not of the view "active".
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	for {
+		select {
+		case e := <-events:
+			if e.Collection != "active" {
+				return e
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func TestWatchExternal(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{
+		WatchExternal:    true,
+		Views:            []View{{Name: "active", Source: "users"}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	events, stop, err := d.Watch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if err := d.Write("users", "own", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e != (Event{Op: EventPut, Collection: "users", Key: "own"}) {
+		t.Errorf("event of a write = %+v, want one not External", e)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "users", "ext.json"), []byte(`{"n":2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e != (Event{Op: EventPut, Collection: "users", Key: "ext", External: true}) {
+		t.Errorf("event of an external write = %+v, want an External put", e)
+	}
+	// Views are updated after the event of their source is published.
+	for e := (Event{}); e.Collection != "active" || e.Key != "ext"; {
+		select {
+		case e = <-events:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the view")
+		}
+	}
+	var v map[string]int
+	if err := d.Read("active", "ext", &v); err != nil || v["n"] != 2 {
+		t.Errorf("view of an external write = %v, %v", v, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "users", "own.json")); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e != (Event{Op: EventDelete, Collection: "users", Key: "own", External: true}) {
+		t.Errorf("event of an external delete = %+v, want an External delete", e)
+	}
+	if keys, err := d.Keys("users"); err != nil || len(keys) != 1 || keys[0] != "ext" {
+		t.Errorf("Keys after external changes = %v, %v; want [ext]", keys, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "places"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "places", "a.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e != (Event{Op: EventPut, Collection: "places", Key: "a", External: true}) {
+		t.Errorf("event of a write to a new collection = %+v, want an External put", e)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "places")); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e != (Event{Op: EventDelete, Collection: "places", External: true}) {
+		t.Errorf("event of an external collection delete = %+v, want an External delete of it all", e)
+	}
+}
+
+func TestWatchExternalNeedsFiles(t *testing.T) {
+	if _, err := New("", &Options{Backend: NewMemoryBackend(), WatchExternal: true, TTLSweepInterval: -1}); err == nil {
+		t.Error("New watched a memory backend for external changes")
+	}
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Encrypted field values are stored as strings carrying one of these
+// prefixes. Randomized values are sealed with a per-record key kept under
+// <collection>/.keys; deterministic values use a per-field key so equal
+// plaintexts produce equal ciphertexts and can be matched on.
+const (
+	encryptedFieldPrefix     = "$enc:"
+	deterministicFieldPrefix = "$det:"
+	recordKeysDir            = ".keys"
+)
+
+func (d *Driver) fieldOptions(collection string) (encrypted, deterministic []string) {
+	opts := d.collectionOptions(collection)
+	return opts.EncryptedFields, opts.DeterministicFields
+}
+
+func (d *Driver) deriveFieldKey(purpose, collection, field string) []byte {
+	mac := hmac.New(sha256.New, d.fieldKey)
+	mac.Write([]byte(purpose + "\x00" + collection + "\x00" + field))
+	return mac.Sum(nil)
+}
+
+func (d *Driver) recordKeyPath(collection, resource string) string {
+	return path.Join(collection, recordKeysDir, d.encodeKey(resource)+".key")
+}
+
+// recordKey loads the per-record field key, generating and persisting a new
+// one when create is set and none exists yet.
+func (d *Driver) recordKey(collection, resource string, create bool) ([]byte, error) {
+	p := d.recordKeyPath(collection, resource)
+	b, err := d.backend.Get(p)
+	if err == nil {
+		return unseal(d.fieldKey, b)
+	}
+	if !isNotExist(err) || !create {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(d.fieldKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, d.replaceFile(p, wrapped)
+}
+
+func sealDeterministic(encKey, macKey, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:gcm.NonceSize()]
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// EncryptedValue returns the stored form of value for a deterministically
+// encrypted field, for use in equality lookups against that field.
+func (d *Driver) EncryptedValue(collection, field string, value interface{}) (string, error) {
+	if d.fieldKey == nil {
+		return "", fmt.Errorf("field encryption is not configured - missing field key")
+	}
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := sealDeterministic(d.deriveFieldKey("enc", collection, field), d.deriveFieldKey("mac", collection, field), plaintext)
+	if err != nil {
+		return "", err
+	}
+	return deterministicFieldPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (d *Driver) encryptFields(collection, resource string, b []byte) ([]byte, error) {
+	encrypted, deterministic := d.fieldOptions(collection)
+	if len(encrypted)+len(deterministic) == 0 {
+		return b, nil
+	}
+	if d.fieldKey == nil {
+		return nil, fmt.Errorf("collection %s has encrypted fields but no field key was provided", collection)
+	}
+
+	doc, err := d.decodeDocument(collection, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) > 0 {
+		key, err := d.recordKey(collection, resource, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range encrypted {
+			value, ok := getField(doc, field)
+			if !ok || isEncryptedValue(value) {
+				continue
+			}
+			plaintext, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			sealed, err := seal(key, plaintext)
+			if err != nil {
+				return nil, err
+			}
+			setField(doc, field, encryptedFieldPrefix+base64.StdEncoding.EncodeToString(sealed))
+		}
+	}
+
+	for _, field := range deterministic {
+		value, ok := getField(doc, field)
+		if !ok || isEncryptedValue(value) {
+			continue
+		}
+		sealed, err := d.EncryptedValue(collection, field, value)
+		if err != nil {
+			return nil, err
+		}
+		setField(doc, field, sealed)
+	}
+
+	return d.encodeDocument(collection, doc)
+}
+
+func (d *Driver) decryptFields(collection, resource string, b []byte) ([]byte, error) {
+	encrypted, deterministic := d.fieldOptions(collection)
+	if len(encrypted)+len(deterministic) == 0 {
+		return b, nil
+	}
+	if d.fieldKey == nil {
+		return nil, fmt.Errorf("collection %s has encrypted fields but no field key was provided", collection)
+	}
+
+	doc, err := d.decodeDocument(collection, b)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(encrypted)+len(deterministic))
+	fields = append(append(fields, encrypted...), deterministic...)
+
+	var key []byte
+	for _, field := range fields {
+		value, ok := getField(doc, field)
+		s, isString := value.(string)
+		if !ok || !isString {
+			continue
+		}
+
+		var plaintext []byte
+		switch {
+		case strings.HasPrefix(s, encryptedFieldPrefix):
+			if key == nil {
+				if key, err = d.recordKey(collection, resource, false); err != nil {
+					return nil, fmt.Errorf("unable to load field key for %s/%s: %w", collection, resource, err)
+				}
+			}
+			sealed, err := base64.StdEncoding.DecodeString(s[len(encryptedFieldPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			if plaintext, err = unseal(key, sealed); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(s, deterministicFieldPrefix):
+			sealed, err := base64.StdEncoding.DecodeString(s[len(deterministicFieldPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			if plaintext, err = unseal(d.deriveFieldKey("enc", collection, field), sealed); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(plaintext))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		setField(doc, field, v)
+	}
+
+	return d.encodeDocument(collection, doc)
+}
+
+func isEncryptedValue(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && (strings.HasPrefix(s, encryptedFieldPrefix) || strings.HasPrefix(s, deterministicFieldPrefix))
+}
+
+// decodeDocument decodes a record of collection, keeping JSON numbers
+// exact so re-encoding it for field encryption does not change the fields
+// it leaves alone.
+func (d *Driver) decodeDocument(collection string, b []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	codec := d.codecFor(collection)
+	if _, ok := codec.(JSONCodec); !ok {
+		return doc, codec.Unmarshal(b, &doc)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (d *Driver) encodeDocument(collection string, doc map[string]interface{}) ([]byte, error) {
+	return d.codecFor(collection).Marshal(doc)
+}
+
+func getField(doc map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func setField(doc map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = v
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testFieldKey = bytes.Repeat([]byte{3}, 32)
+
+type patient struct {
+	Name    string `json:"name"`
+	SSN     string `json:"ssn"`
+	Email   string `json:"email"`
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+}
+
+func openFields(t *testing.T, dir string) *Driver {
+	t.Helper()
+	d, err := New(dir, &Options{
+		FieldKey: testFieldKey,
+		Collections: map[string]CollectionOptions{"patients": {
+			EncryptedFields:     []string{"ssn", "address.city"},
+			DeterministicFields: []string{"email"},
+		}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEncryptedFields(t *testing.T) {
+	dir := t.TempDir()
+	d := openFields(t, dir)
+	defer d.Close()
+
+	var p patient
+	p.Name, p.SSN, p.Email, p.Address.City = "Ada", "123-45-6789", "ada@example.com", "London"
+	if err := d.Write("patients", "ada", p); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "patients", "ada.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plaintext := range []string{"123-45-6789", "ada@example.com", "London"} {
+		if strings.Contains(string(b), plaintext) {
+			t.Errorf("%s stored in the clear: %s", plaintext, b)
+		}
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored["name"] != "Ada" {
+		t.Errorf("unencrypted field stored as %v", stored["name"])
+	}
+
+	var got patient
+	if err := d.Read("patients", "ada", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != p {
+		t.Errorf("Read = %+v, want %+v", got, p)
+	}
+}
+
+func TestDeterministicFields(t *testing.T) {
+	d := openFields(t, t.TempDir())
+	defer d.Close()
+
+	for _, key := range []string{"ada", "bob"} {
+		if err := d.Write("patients", key, map[string]string{"email": key + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := d.EncryptedValue("patients", "email", "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.backend.Get("patients/bob.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored["email"] != want {
+		t.Errorf("stored email %v, EncryptedValue %v", stored["email"], want)
+	}
+	if other, _ := d.EncryptedValue("patients", "email", "ada@example.com"); other == want {
+		t.Error("different emails encrypted alike")
+	}
+}
+
+func TestEncryptedFieldsNeedKey(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"patients": {EncryptedFields: []string{"ssn"}}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("patients", "ada", map[string]string{"ssn": "1"}); err == nil {
+		t.Error("wrote an encrypted field without a field key")
+	}
+}
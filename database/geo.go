@@ -0,0 +1,464 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// geoPrecision is the length of the geohashes points are indexed by,
+	// cells of about 150m square.
+	geoPrecision = 7
+	// geoMaxCells bounds the cells a query looks up, so wide queries use
+	// coarser cells rather than many fine ones.
+	geoMaxCells = 64
+
+	earthRadius = 6371008.8 // meters
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeoIndex indexes the records of a collection by location, for FindNear
+// and FindWithin. Records whose fields do not hold valid coordinates are
+// left out. Geo indexes are built when the Driver opens and then updated
+// with every change to their collection; they are kept in memory only.
+type GeoIndex struct {
+	// Collection is a top-level collection name.
+	Collection string
+	// Lat and Lng are the fields holding the latitude and longitude in
+	// degrees, with dots for nested fields, "lat" and "lng" by default.
+	Lat, Lng string
+}
+
+// GeoResult is a record found by FindNear or FindWithin.
+type GeoResult struct {
+	Key string `json:"key"`
+	// Distance is in meters from the point FindNear searched around, and
+	// zero for FindWithin.
+	Distance float64                `json:"distance"`
+	Value    map[string]interface{} `json:"value"`
+}
+
+// geoIndex maps geohash prefixes of every length up to geoPrecision to the
+// keys of the records in their cell, so a query looks up a few cells of the
+// size that suits it.
+type geoIndex struct {
+	GeoIndex
+	mutex  sync.RWMutex
+	points map[string]geoPoint
+	cells  [geoPrecision + 1]map[string]map[string]bool
+}
+
+type geoPoint struct {
+	lat, lng float64
+	hash     string
+}
+
+func (d *Driver) openGeoIndexes(indexes []GeoIndex) error {
+	d.geoIndexes = make(map[string]*geoIndex)
+	for _, gi := range indexes {
+		if err := validName("collection", gi.Collection); err != nil {
+			return err
+		}
+		if _, ok := d.geoIndexes[gi.Collection]; ok {
+			return fmt.Errorf("duplicate geo index on %s", gi.Collection)
+		}
+		if gi.Lat == "" {
+			gi.Lat = "lat"
+		}
+		if gi.Lng == "" {
+			gi.Lng = "lng"
+		}
+		for _, f := range []string{gi.Lat, gi.Lng} {
+			if err := validField(f); err != nil {
+				return fmt.Errorf("geo index on %s: %w", gi.Collection, err)
+			}
+		}
+		d.geoIndexes[gi.Collection] = &geoIndex{GeoIndex: gi}
+	}
+	for collection := range d.geoIndexes {
+		if err := d.RebuildGeoIndex(collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildGeoIndex reindexes all of a collection, for collections changed
+// outside the Driver.
+func (d *Driver) RebuildGeoIndex(collection string) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	gi, ok := d.geoIndexes[collection]
+	if !ok {
+		return fmt.Errorf("unable to find geo index on %v: %w", collection, fs.ErrNotExist)
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	gi.clear()
+	keys, err := d.liveKeys(collection)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := d.updateGeoIndex(gi, key); err != nil {
+			return err
+		}
+	}
+	gi.mutex.RLock()
+	n := len(gi.points)
+	gi.mutex.RUnlock()
+	d.logEvent(slog.LevelDebug, "Rebuilt geo index", slog.String("collection", collection), slog.Int("records", n))
+	return nil
+}
+
+// updateGeoIndexes applies a change to a collection to its geo index.
+// Callers hold the collection lock; a failure leaves the record out of the
+// index until it is written again or the index is rebuilt.
+func (d *Driver) updateGeoIndexes(op EventOp, collection, key string) {
+	gi, ok := d.geoIndexes[collection]
+	if !ok {
+		return
+	}
+	switch {
+	case key != "":
+		if err := d.updateGeoIndex(gi, key); err != nil {
+			gi.remove(key)
+			d.logEvent(slog.LevelError, "Updating geo index failed", slog.String("collection", collection), slog.String("key", key), slog.Any("error", err))
+		}
+	case op == EventDelete:
+		gi.clear()
+	}
+}
+
+// updateGeoIndex indexes a record where it is now, or drops it from the
+// index if it is gone or has no valid location.
+func (d *Driver) updateGeoIndex(gi *geoIndex, key string) error {
+	b, err := d.readRecord(gi.Collection, key)
+	if isNotExist(err) {
+		gi.remove(key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := d.decodeDocument(gi.Collection, b)
+	if err != nil {
+		return err
+	}
+	lat, lng, ok := gi.location(doc)
+	if !ok {
+		gi.remove(key)
+		return nil
+	}
+	gi.put(key, lat, lng)
+	return nil
+}
+
+func (gi *geoIndex) location(doc map[string]interface{}) (lat, lng float64, ok bool) {
+	v, ok := getField(doc, gi.Lat)
+	if !ok {
+		return 0, 0, false
+	}
+	if lat, ok = toFloat(v); !ok {
+		return 0, 0, false
+	}
+	if v, ok = getField(doc, gi.Lng); !ok {
+		return 0, 0, false
+	}
+	if lng, ok = toFloat(v); !ok {
+		return 0, 0, false
+	}
+	return lat, lng, validLocation(lat, lng)
+}
+
+func (gi *geoIndex) put(key string, lat, lng float64) {
+	gi.mutex.Lock()
+	defer gi.mutex.Unlock()
+	gi.removeLocked(key)
+	hash := geohash(lat, lng, geoPrecision)
+	gi.points[key] = geoPoint{lat: lat, lng: lng, hash: hash}
+	for p := 1; p <= geoPrecision; p++ {
+		cell := gi.cells[p][hash[:p]]
+		if cell == nil {
+			cell = make(map[string]bool)
+			gi.cells[p][hash[:p]] = cell
+		}
+		cell[key] = true
+	}
+}
+
+func (gi *geoIndex) remove(key string) {
+	gi.mutex.Lock()
+	defer gi.mutex.Unlock()
+	gi.removeLocked(key)
+}
+
+func (gi *geoIndex) removeLocked(key string) {
+	pt, ok := gi.points[key]
+	if !ok {
+		return
+	}
+	delete(gi.points, key)
+	for p := 1; p <= geoPrecision; p++ {
+		cell := gi.cells[p][pt.hash[:p]]
+		delete(cell, key)
+		if len(cell) == 0 {
+			delete(gi.cells[p], pt.hash[:p])
+		}
+	}
+}
+
+func (gi *geoIndex) clear() {
+	gi.mutex.Lock()
+	defer gi.mutex.Unlock()
+	gi.points = make(map[string]geoPoint)
+	for p := range gi.cells {
+		gi.cells[p] = make(map[string]map[string]bool)
+	}
+}
+
+// candidates returns the indexed points in the cells covering a box, which
+// may reach past it. minLng > maxLng is a box across the antimeridian.
+func (gi *geoIndex) candidates(minLat, minLng, maxLat, maxLng float64) map[string]geoPoint {
+	ranges := [][2]float64{{minLng, maxLng}}
+	if minLng > maxLng {
+		ranges = [][2]float64{{minLng, 180}, {-180, maxLng}}
+	}
+	gi.mutex.RLock()
+	defer gi.mutex.RUnlock()
+	found := make(map[string]geoPoint)
+	for _, r := range ranges {
+		for _, cell := range coveringCells(minLat, r[0], maxLat, r[1]) {
+			for key := range gi.cells[len(cell)][cell] {
+				found[key] = gi.points[key]
+			}
+		}
+	}
+	return found
+}
+
+// FindNear returns the records of a collection within radius meters of a
+// point, nearest first. The collection needs a geo index.
+func (d *Driver) FindNear(collection string, lat, lng, radius float64) ([]GeoResult, error) {
+	return d.FindNearContext(context.Background(), collection, lat, lng, radius)
+}
+
+// FindNearContext is FindNear with a context to trace the operation in.
+func (d *Driver) FindNearContext(ctx context.Context, collection string, lat, lng, radius float64) (results []GeoResult, err error) {
+	op := d.startOp(ctx, "findnear", collection, "")
+	defer op.end(&err)
+
+	gi, err := d.geoIndex(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	if !validLocation(lat, lng) {
+		return nil, fmt.Errorf("invalid location %v,%v", lat, lng)
+	}
+	if radius < 0 || math.IsNaN(radius) {
+		return nil, fmt.Errorf("invalid radius %v - must not be negative", radius)
+	}
+
+	// The box around the circle, in degrees; it takes every longitude once
+	// it reaches a pole.
+	dLat := radius / earthRadius * 180 / math.Pi
+	minLat, maxLat := lat-dLat, lat+dLat
+	minLng, maxLng := -180.0, 180.0
+	if minLat > -90 && maxLat < 90 {
+		dLng := math.Asin(math.Min(1, math.Sin(radius/earthRadius)/math.Cos(lat*math.Pi/180))) * 180 / math.Pi
+		if dLng < 180 {
+			minLng, maxLng = wrapLng(lng-dLng), wrapLng(lng+dLng)
+		}
+	}
+
+	var hits []GeoResult
+	for key, pt := range gi.candidates(math.Max(minLat, -90), minLng, math.Min(maxLat, 90), maxLng) {
+		if dist := haversine(lat, lng, pt.lat, pt.lng); dist <= radius {
+			hits = append(hits, GeoResult{Key: key, Distance: dist})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		return hits[i].Key < hits[j].Key
+	})
+	return d.readGeoResults(op, collection, hits)
+}
+
+// FindWithin returns the records of a collection inside a bounding box,
+// sorted by key. A box with minLng greater than maxLng crosses the
+// antimeridian. The collection needs a geo index.
+func (d *Driver) FindWithin(collection string, minLat, minLng, maxLat, maxLng float64) ([]GeoResult, error) {
+	return d.FindWithinContext(context.Background(), collection, minLat, minLng, maxLat, maxLng)
+}
+
+// FindWithinContext is FindWithin with a context to trace the operation in.
+func (d *Driver) FindWithinContext(ctx context.Context, collection string, minLat, minLng, maxLat, maxLng float64) (results []GeoResult, err error) {
+	op := d.startOp(ctx, "findwithin", collection, "")
+	defer op.end(&err)
+
+	gi, err := d.geoIndex(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	if !validLocation(minLat, minLng) || !validLocation(maxLat, maxLng) || minLat > maxLat {
+		return nil, fmt.Errorf("invalid bounding box %v,%v %v,%v", minLat, minLng, maxLat, maxLng)
+	}
+
+	var hits []GeoResult
+	for key, pt := range gi.candidates(minLat, minLng, maxLat, maxLng) {
+		inLng := pt.lng >= minLng && pt.lng <= maxLng
+		if minLng > maxLng {
+			inLng = pt.lng >= minLng || pt.lng <= maxLng
+		}
+		if inLng && pt.lat >= minLat && pt.lat <= maxLat {
+			hits = append(hits, GeoResult{Key: key})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Key < hits[j].Key })
+	return d.readGeoResults(op, collection, hits)
+}
+
+func (d *Driver) geoIndex(ctx context.Context, collection string) (*geoIndex, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+	gi, ok := d.geoIndexes[collection]
+	if !ok {
+		return nil, fmt.Errorf("no geo index on collection %s", collection)
+	}
+	return gi, nil
+}
+
+// readGeoResults fills in the records of hits, leaving out those deleted
+// since they were looked up.
+func (d *Driver) readGeoResults(op *opTimer, collection string, hits []GeoResult) ([]GeoResult, error) {
+	results := []GeoResult{}
+	for _, hit := range hits {
+		b, err := d.readRecord(collection, hit.Key)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		op.addBytes(int64(len(b)))
+		if hit.Value, err = d.decodeDocument(collection, b); err != nil {
+			return nil, err
+		}
+		results = append(results, hit)
+	}
+	return results, nil
+}
+
+func validLocation(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+func wrapLng(lng float64) float64 {
+	switch {
+	case lng < -180:
+		return lng + 360
+	case lng > 180:
+		return lng - 360
+	}
+	return lng
+}
+
+// haversine returns the great-circle distance between two points in
+// meters.
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// geohash encodes a point as a geohash of precision characters.
+func geohash(lat, lng float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	var ch, bits int
+	even := true
+	for len(hash) < precision {
+		if even {
+			if mid := (minLng + maxLng) / 2; lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch <<= 1
+				maxLng = mid
+			}
+		} else {
+			if mid := (minLat + maxLat) / 2; lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bits++; bits == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			ch, bits = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// geohashCell returns the size in degrees of the cells of a precision.
+func geohashCell(precision int) (latSize, lngSize float64) {
+	bits := 5 * precision
+	return 180 / math.Exp2(float64(bits/2)), 360 / math.Exp2(float64(bits-bits/2))
+}
+
+// coveringCells returns the geohashes of the cells covering a box, using
+// the finest cells that keep them under geoMaxCells.
+func coveringCells(minLat, minLng, maxLat, maxLng float64) []string {
+	precision := geoPrecision
+	var rows, cols [2]int
+	for ; ; precision-- {
+		latSize, lngSize := geohashCell(precision)
+		rows = cellSpan(minLat+90, maxLat+90, latSize, 180)
+		cols = cellSpan(minLng+180, maxLng+180, lngSize, 360)
+		if (rows[1]-rows[0]+1)*(cols[1]-cols[0]+1) <= geoMaxCells || precision == 1 {
+			break
+		}
+	}
+	latSize, lngSize := geohashCell(precision)
+	var cells []string
+	for i := rows[0]; i <= rows[1]; i++ {
+		for j := cols[0]; j <= cols[1]; j++ {
+			cells = append(cells, geohash(-90+(float64(i)+0.5)*latSize, -180+(float64(j)+0.5)*lngSize, precision))
+		}
+	}
+	return cells
+}
+
+// cellSpan returns the first and last cell of size covering [from, to],
+// offsets into a range of width total.
+func cellSpan(from, to, size, total float64) [2]int {
+	last := int(total/size) - 1
+	span := [2]int{int(from / size), int(to / size)}
+	for i := range span {
+		span[i] = max(0, min(span[i], last))
+	}
+	return span
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var testGeoIndexes = []GeoIndex{{Collection: "places", Lat: "loc.lat", Lng: "loc.lng"}}
+
+type place struct {
+	lat, lng float64
+}
+
+func writePlace(t *testing.T, d *Driver, key string, p place) {
+	t.Helper()
+	doc := map[string]interface{}{"loc": map[string]float64{"lat": p.lat, "lng": p.lng}}
+	if err := d.Write("places", key, doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkGeo compares FindNear and FindWithin with a scan of every place.
+func checkGeo(t *testing.T, d *Driver, places map[string]place) {
+	t.Helper()
+	for _, q := range []struct{ lat, lng, radius float64 }{
+		{51.5, -0.1, 2000}, {51.5, -0.1, 50}, {51.5, -0.1, 0},
+		// Across the antimeridian, near the poles and around the globe.
+		{0, 180, 100000}, {0, -179.9, 50000}, {89.9, 0, 500000}, {-89, 10, 3000000}, {10, 10, 20000000},
+	} {
+		results, err := d.FindNear("places", q.lat, q.lng, q.radius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		for _, p := range places {
+			if haversine(q.lat, q.lng, p.lat, p.lng) <= q.radius {
+				want++
+			}
+		}
+		if len(results) != want {
+			t.Errorf("FindNear(%v) found %d places, want %d", q, len(results), want)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Distance < results[i-1].Distance {
+				t.Errorf("FindNear(%v) results not nearest first", q)
+				break
+			}
+		}
+	}
+	for _, box := range [][4]float64{{51.45, -0.15, 51.55, -0.05}, {-1, 179.5, 1, -179.5}, {-90, -180, 90, 180}} {
+		results, err := d.FindWithin("places", box[0], box[1], box[2], box[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		for _, p := range places {
+			inLng := p.lng >= box[1] && p.lng <= box[3]
+			if box[1] > box[3] {
+				inLng = p.lng >= box[1] || p.lng <= box[3]
+			}
+			if inLng && p.lat >= box[0] && p.lat <= box[2] {
+				want++
+			}
+		}
+		if len(results) != want {
+			t.Errorf("FindWithin(%v) found %d places, want %d", box, len(results), want)
+		}
+	}
+}
+
+func TestGeoIndex(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{GeoIndexes: testGeoIndexes, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	places := make(map[string]place)
+	for i := 0; i < 3000; i++ {
+		var p place
+		switch i % 3 {
+		case 0:
+			p = place{r.Float64()*180 - 90, r.Float64()*360 - 180}
+		case 1:
+			// Around London.
+			p = place{51.4 + r.Float64()*0.2, -0.2 + r.Float64()*0.2}
+		case 2:
+			// Either side of the antimeridian.
+			p = place{r.Float64()*2 - 1, 179 + r.Float64()*2}
+			if p.lng > 180 {
+				p.lng -= 360
+			}
+		}
+		key := fmt.Sprintf("p%04d", i)
+		places[key] = p
+		writePlace(t, d, key, p)
+	}
+	if err := d.Write("places", "nowhere", map[string]string{"name": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("places", "p0001"); err != nil {
+		t.Fatal(err)
+	}
+	delete(places, "p0001")
+	checkGeo(t, d, places)
+
+	results, err := d.FindNear("places", 51.5, -0.1, 2000)
+	if err != nil || len(results) == 0 {
+		t.Fatalf("FindNear = %v, %v", results, err)
+	}
+	if loc, ok := results[0].Value["loc"].(map[string]interface{}); !ok || loc["lat"] == nil {
+		t.Errorf("FindNear result = %+v, want the record", results[0])
+	}
+	if _, err := d.FindNear("other", 0, 0, 1); err == nil {
+		t.Error("FindNear of a collection without a geo index succeeded")
+	}
+	if _, err := d.FindNear("places", 91, 0, 1); err == nil {
+		t.Error("FindNear of an invalid location succeeded")
+	}
+	if _, err := d.FindNear("places", 0, 0, -1); err == nil {
+		t.Error("FindNear with a negative radius succeeded")
+	}
+	if _, err := d.FindWithin("places", 10, 0, -10, 1); err == nil {
+		t.Error("FindWithin of an upside-down box succeeded")
+	}
+	d.Close()
+
+	// The index is built again when the Driver opens.
+	if d, err = New(dir, &Options{ReadOnly: true, GeoIndexes: testGeoIndexes, TTLSweepInterval: -1}); err != nil {
+		t.Fatal(err)
+	}
+	checkGeo(t, d, places)
+	d.Close()
+
+	if d, err = New(dir, &Options{GeoIndexes: testGeoIndexes, TTLSweepInterval: -1}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.DropCollection(context.Background(), "places", false); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := d.FindWithin("places", -90, -180, 90, 180); err != nil || len(results) != 0 {
+		t.Errorf("FindWithin of a dropped collection = %d places, %v; want none", len(results), err)
+	}
+}
+
+func TestRebuildGeoIndex(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{GeoIndexes: testGeoIndexes, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	writePlace(t, d, "london", place{51.5, -0.1})
+
+	// A record written outside the Driver is only found once rebuilt.
+	if err := d.backend.Put("places/paris.json", []byte(`{"loc":{"lat":48.86,"lng":2.35}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := d.FindWithin("places", -90, -180, 90, 180); len(results) != 1 {
+		t.Fatalf("FindWithin before rebuilding = %d places, want 1", len(results))
+	}
+	if err := d.RebuildGeoIndex("places"); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := d.FindWithin("places", -90, -180, 90, 180); len(results) != 2 {
+		t.Errorf("FindWithin after rebuilding = %d places, want 2", len(results))
+	}
+	if err := d.RebuildGeoIndex("other"); !isNotExist(err) {
+		t.Errorf("RebuildGeoIndex of a collection without one = %v, want not found", err)
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	if got := geohash(57.64911, 10.40744, 11); got != "u4pruydqqvj" {
+		t.Errorf("geohash = %s, want u4pruydqqvj", got)
+	}
+	if got := geohash(-90, -180, 4); got != "0000" {
+		t.Errorf("geohash of the south-west corner = %s, want 0000", got)
+	}
+}
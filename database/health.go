@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNotReady = errors.New("not ready")
+
+// readyProbe is written and removed again to check the data directory is
+// writable. Dot files at the top level are not collections.
+const readyProbe = ".ready.tmp"
+
+// Ready reports whether the Driver can serve requests: it is open, its
+// data directory is writable unless it was opened read-only, every
+// collection lock can be taken before ctx is done, and the last Verify, if
+// any, found no issues. Errors match ErrNotReady.
+func (d *Driver) Ready(ctx context.Context) error {
+	if err := d.checkOpen(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotReady, err)
+	}
+
+	if !d.readOnly {
+		if err := d.backend.Put(readyProbe, []byte("ok\n")); err != nil {
+			return fmt.Errorf("%w: data directory is not writable: %w", ErrNotReady, err)
+		}
+		if err := d.backend.Delete(readyProbe); err != nil {
+			return fmt.Errorf("%w: data directory is not writable: %w", ErrNotReady, err)
+		}
+	}
+
+	for c, m := range d.collectionMutexes() {
+		for !m.TryLock() {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: collection %s is locked: %w", ErrNotReady, c, ctx.Err())
+			case <-time.After(time.Millisecond):
+			}
+		}
+		m.Unlock()
+	}
+
+	if r := d.lastVerify.Load(); r != nil && len(r.Issues) > 0 {
+		return fmt.Errorf("%w: last verify found %d issues", ErrNotReady, len(r.Issues))
+	}
+	return nil
+}
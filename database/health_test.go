@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Ready(context.Background()); err != nil {
+		t.Fatalf("Ready = %v", err)
+	}
+
+	mutex := d.GetOrCreateMutex("users")
+	mutex.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	err = d.Ready(ctx)
+	cancel()
+	mutex.Unlock()
+	if !errors.Is(err, ErrNotReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Ready with a collection locked = %v, want ErrNotReady", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "users", "b.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Ready(context.Background()); !errors.Is(err, ErrNotReady) {
+		t.Errorf("Ready after a failed verify = %v, want ErrNotReady", err)
+	}
+
+	d.Close()
+	if err := d.Ready(context.Background()); !errors.Is(err, ErrNotReady) || !errors.Is(err, ErrClosed) {
+		t.Errorf("Ready after Close = %v, want ErrNotReady and ErrClosed", err)
+	}
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// The idempotency keys a write was made under live in a file each under
+// their own directory, named by a hash of the key, until they expire. The
+// files are encrypted like records, since they name the record written.
+const (
+	idempotencyDir        = ".idempotency"
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// ErrIdempotencyKeyReused is returned by WriteIdempotent when its
+// idempotency key was used for another write, of another record or value,
+// that has not yet expired.
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused")
+
+// idempotencyRecord is what a write made under an idempotency key did.
+type idempotencyRecord struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	// Digest hashes the value written, to tell a retry from a reuse.
+	Digest   string    `json:"digest"`
+	Revision string    `json:"revision"`
+	Expires  time.Time `json:"expires"`
+}
+
+func idempotencyPath(idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(idempotencyKey))
+	return path.Join(idempotencyDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// WriteIdempotent writes a record as Write does, under an idempotency key
+// such as the ID of a webhook delivery or payment request, and returns its
+// revision. Writing again under the same key, for Options.IdempotencyTTL,
+// writes nothing and returns the revision of the first write, so a
+// handler retried after a timeout persists its input once. Writing another
+// record or value under it fails with ErrIdempotencyKeyReused.
+func (d *Driver) WriteIdempotent(collection, resource string, v interface{}, idempotencyKey string) (string, error) {
+	return d.WriteIdempotentContext(context.Background(), collection, resource, v, idempotencyKey)
+}
+
+// WriteIdempotentContext is WriteIdempotent with a context to trace the
+// operation in.
+func (d *Driver) WriteIdempotentContext(ctx context.Context, collection, resource string, v interface{}, idempotencyKey string) (rev string, err error) {
+	op := d.startOp(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return "", err
+	}
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return "", fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if idempotencyKey == "" {
+		return "", fmt.Errorf("missing idempotency key")
+	}
+	if err := validCollection(collection); err != nil {
+		return "", err
+	}
+	if err := d.checkView(collection); err != nil {
+		return "", err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return "", err
+	}
+
+	b, err := d.codecFor(collection).Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if err := d.checkRecordSize(collection, resource, b); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	digest := hex.EncodeToString(sum[:])
+
+	// Held around the write, so of two submissions racing only one makes
+	// it. It is never taken with a collection lock held.
+	keys := d.GetOrCreateMutex(idempotencyDir)
+	op.lock(keys)
+	defer keys.Unlock()
+
+	p := idempotencyPath(idempotencyKey)
+	prev, err := d.readIdempotencyRecord(p)
+	if err != nil {
+		return "", err
+	}
+	if prev != nil && time.Now().Before(prev.Expires) {
+		if prev.Collection != collection || prev.Key != resource || prev.Digest != digest {
+			return "", fmt.Errorf("%w: %s wrote %s/%s", ErrIdempotencyKeyReused, idempotencyKey, prev.Collection, prev.Key)
+		}
+		return prev.Revision, nil
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if err := d.writeRecordLocked(op, collection, resource, bytes.NewReader(b), time.Time{}); err != nil {
+		return "", err
+	}
+	if rev, err = d.currentRevision(collection, resource); err != nil {
+		return "", err
+	}
+	rec, err := json.Marshal(idempotencyRecord{
+		Collection: collection,
+		Key:        resource,
+		Digest:     digest,
+		Revision:   rev,
+		Expires:    time.Now().Add(d.idempotencyTTL).UTC(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if rec, err = d.encrypt(append(rec, '\n')); err != nil {
+		return "", err
+	}
+	// The write stands if this fails; a retry writes the record again.
+	return rev, d.replaceFile(p, rec)
+}
+
+func (d *Driver) readIdempotencyRecord(p string) (*idempotencyRecord, error) {
+	b, err := d.backend.Get(p)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b, err = d.decrypt(b); err != nil {
+		return nil, fmt.Errorf("reading idempotency key %s: %w", path.Base(p), err)
+	}
+	var rec idempotencyRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("reading idempotency key %s: %w", path.Base(p), err)
+	}
+	return &rec, nil
+}
+
+// sweepIdempotencyKeys deletes the expired idempotency keys and returns
+// how many there were. The TTL sweeper runs it.
+func (d *Driver) sweepIdempotencyKeys() (int, error) {
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+	files, err := d.backend.List(idempotencyDir)
+	if isNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	mutex := d.GetOrCreateMutex(idempotencyDir)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	n := 0
+	now := time.Now()
+	for _, file := range files {
+		if isDirName(file) || !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		p := path.Join(idempotencyDir, file)
+		rec, err := d.readIdempotencyRecord(p)
+		if err != nil {
+			return n, err
+		}
+		if rec == nil || now.Before(rec.Expires) {
+			continue
+		}
+		if err := d.deleteFile(p); err != nil && !isNotExist(err) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// idempotencyPaths returns the files of the idempotency keys a record was
+// written under, for Purge. The caller holds the idempotency lock.
+func (d *Driver) idempotencyPaths(collection, resource string) ([]string, error) {
+	files, err := d.backend.List(idempotencyDir)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		if isDirName(file) || !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		p := path.Join(idempotencyDir, file)
+		rec, err := d.readIdempotencyRecord(p)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil && rec.Collection == collection && rec.Key == resource {
+			paths = append(paths, p, p+".tmp")
+		}
+	}
+	return paths, nil
+}
+
+// reencryptIdempotencyKeys reseals the idempotency keys with the current
+// data key.
+func (d *Driver) reencryptIdempotencyKeys(current uint32) error {
+	mutex := d.GetOrCreateMutex(idempotencyDir)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.backend.List(idempotencyDir)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if isDirName(file) || !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		if err := d.reencryptFile(path.Join(idempotencyDir, file), current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
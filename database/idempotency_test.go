@@ -0,0 +1,104 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWriteIdempotent(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1, IdempotencyTTL: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	rev, err := d.WriteIdempotent("payments", "a", map[string]int{"n": 1}, "evt_1")
+	if err != nil || rev == "" {
+		t.Fatalf("WriteIdempotent = %q, %v", rev, err)
+	}
+	if err := d.Write("payments", "a", map[string]int{"n": 9}); err != nil {
+		t.Fatal(err)
+	}
+	retried, err := d.WriteIdempotent("payments", "a", map[string]int{"n": 1}, "evt_1")
+	if err != nil || retried != rev {
+		t.Fatalf("retried WriteIdempotent = %q, %v; want %q", retried, err, rev)
+	}
+	var v map[string]int
+	if err := d.Read("payments", "a", &v); err != nil || v["n"] != 9 {
+		t.Fatalf("a retry rewrote the record: %v, %v", v, err)
+	}
+	if _, err := d.WriteIdempotent("payments", "b", map[string]int{"n": 1}, "evt_1"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("WriteIdempotent of another record = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n, err := d.sweepIdempotencyKeys(); err != nil || n != 1 {
+		t.Fatalf("sweepIdempotencyKeys = %d, %v; want 1", n, err)
+	}
+	if _, err := d.WriteIdempotent("payments", "b", map[string]int{"n": 1}, "evt_1"); err != nil {
+		t.Fatalf("WriteIdempotent under an expired key: %v", err)
+	}
+}
+
+func TestWriteIdempotentEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	rev, err := d.WriteIdempotent("payments", "alice", map[string]int{"n": 1}, "evt_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.backend.Get(idempotencyPath("evt_1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordKeyVersion(b); !ok || bytes.Contains(b, []byte("alice")) {
+		t.Fatalf("idempotency key stored unencrypted: %q", b)
+	}
+
+	d = rotate(t, d, dir)
+	defer d.Close()
+	if b, err := d.backend.Get(idempotencyPath("evt_1")); err != nil {
+		t.Fatal(err)
+	} else if version, _ := recordKeyVersion(b); version != 2 {
+		t.Errorf("idempotency key sealed with version %d after Reencrypt, want 2", version)
+	}
+	retried, err := d.WriteIdempotent("payments", "alice", map[string]int{"n": 1}, "evt_1")
+	if err != nil || retried != rev {
+		t.Fatalf("WriteIdempotent after Reencrypt = %q, %v; want %q", retried, err, rev)
+	}
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, key := range []string{"evt_1", "evt_2"} {
+		if _, err := d.WriteIdempotent("payments", "alice", map[string]string{"evt": key}, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.WriteIdempotent("payments", "bob", 1, "evt_3"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.Purge("payments", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Verified() {
+		t.Fatalf("purge left %v", report.Remaining)
+	}
+	for _, key := range []string{"evt_1", "evt_2"} {
+		if ok, err := d.pathExists(idempotencyPath(key)); err != nil || ok {
+			t.Errorf("idempotency key %s left after Purge: %v", key, err)
+		}
+	}
+	if ok, err := d.pathExists(idempotencyPath("evt_3")); err != nil || !ok {
+		t.Errorf("Purge removed the idempotency key of another record: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sequenceFile holds the last key Insert gave out in a collection using
+// IDSequence. It starts with a dot, so it cannot clash with a record.
+const sequenceFile = ".sequence"
+
+// IDScheme is how Insert generates the keys of a collection.
+type IDScheme int
+
+const (
+	// IDUUIDv7 keys are UUIDv7s in their canonical lower-case form.
+	IDUUIDv7 IDScheme = iota
+	// IDULID keys are ULIDs in their canonical upper-case form.
+	IDULID
+	// IDSequence keys count up from 1, zero-padded to 20 digits so they
+	// sort in insertion order. The last one is stored with the collection,
+	// so keys are never given out twice, though a crash can skip one.
+	IDSequence
+)
+
+// parseIDScheme reads the ids setting of a collection: uuidv7, the
+// default, ulid or sequence.
+func parseIDScheme(ids, collection string) (IDScheme, error) {
+	switch ids {
+	case "", "uuidv7":
+		return IDUUIDv7, nil
+	case "ulid":
+		return IDULID, nil
+	case "sequence":
+		return IDSequence, nil
+	}
+	return 0, fmt.Errorf("unknown ids %q for collection %s - must be uuidv7, ulid or sequence", ids, collection)
+}
+
+// Insert stores v under a key it generates with the collection's
+// CollectionOptions.IDs and returns the key. UUIDv7s and ULIDs sort by
+// the millisecond they were generated in.
+func (d *Driver) Insert(collection string, v interface{}) (string, error) {
+	return d.InsertContext(context.Background(), collection, v)
+}
+
+// InsertContext is Insert with a context to trace the operation in.
+func (d *Driver) InsertContext(ctx context.Context, collection string, v interface{}) (key string, err error) {
+	op := d.startOp(ctx, "insert", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return "", err
+	}
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to save record")
+	}
+	if err := validCollection(collection); err != nil {
+		return "", err
+	}
+	if err := d.checkView(collection); err != nil {
+		return "", err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return "", err
+	}
+
+	b, err := d.codecFor(collection).Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if key, err = d.newKey(collection); err != nil {
+		return "", err
+	}
+	if err := d.checkRecordSize(collection, key, b); err != nil {
+		return "", err
+	}
+	if err := d.writeRecordLocked(op, collection, key, bytes.NewReader(b), time.Time{}); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// newKey generates a key with the collection's CollectionOptions.IDs.
+// Callers hold the collection lock.
+func (d *Driver) newKey(collection string) (string, error) {
+	switch scheme := d.collectionOptions(collection).IDs; scheme {
+	case IDUUIDv7:
+		return newUUIDv7(time.Now())
+	case IDULID:
+		return newULID(time.Now())
+	case IDSequence:
+		return d.nextSequence(collection)
+	default:
+		return "", fmt.Errorf("unknown ID scheme %d", scheme)
+	}
+}
+
+// nextSequence gives out the next key of a collection using IDSequence,
+// skipping over keys taken by records written with Write. Callers hold the
+// collection lock.
+func (d *Driver) nextSequence(collection string) (string, error) {
+	p := path.Join(collection, sequenceFile)
+	var last uint64
+	b, err := d.backend.Get(p)
+	if err != nil && !isNotExist(err) {
+		return "", err
+	}
+	if err == nil {
+		if last, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return "", fmt.Errorf("reading sequence of %s: %w", collection, err)
+		}
+	}
+
+	var key string
+	for {
+		last++
+		key = fmt.Sprintf("%020d", last)
+		if _, err := d.readRecord(collection, key); isNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	// The sequence is stored before the record is written, so a crash in
+	// between skips the key rather than giving it out again.
+	tmpPath := p + ".tmp"
+	if err := d.backend.Put(tmpPath, []byte(strconv.FormatUint(last, 10)+"\n")); err != nil {
+		return "", err
+	}
+	if err := d.backend.Rename(tmpPath, p); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// newUUIDv7 returns a UUIDv7 for a key generated at now.
+func newUUIDv7(now time.Time) (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	ms := uint64(now.UnixMilli())
+	u[0], u[1], u[2], u[3], u[4], u[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID for a key generated at now.
+func newULID(now time.Time) (string, error) {
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[:8], uint64(now.UnixMilli())<<16)
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	// 26 characters of 5 bits hold the 128 bits with 2 to spare at the top.
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	s := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s), nil
+}
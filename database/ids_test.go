@@ -0,0 +1,112 @@
+package database
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	uuidv7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestInsert(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"events": {IDs: IDULID}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var keys []string
+	for i := 0; i < 3; i++ {
+		key, err := d.Insert("users", map[string]int{"n": i})
+		if err != nil || !uuidv7Pattern.MatchString(key) {
+			t.Fatalf("Insert = %q, %v; want a UUIDv7", key, err)
+		}
+		keys = append(keys, key)
+		time.Sleep(time.Millisecond)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Errorf("UUIDv7s %q do not sort by creation", keys)
+	}
+	var v map[string]int
+	if err := d.Read("users", keys[1], &v); err != nil || v["n"] != 1 {
+		t.Errorf("Read of an inserted record = %v, %v", v, err)
+	}
+	if key, err := d.Insert("events", 1); err != nil || !ulidPattern.MatchString(key) {
+		t.Errorf("Insert = %q, %v; want a ULID", key, err)
+	}
+
+	key, err := d.Namespace("acme").Insert("users", map[string]int{"n": 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Namespace("acme").Read("users", key, &v); err != nil || v["n"] != 9 {
+		t.Errorf("Namespace Read of an inserted record = %v, %v", v, err)
+	}
+}
+
+func TestInsertSequence(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{Collections: map[string]CollectionOptions{"orders": {IDs: IDSequence}}, TTLSweepInterval: -1}
+	d, err := New(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A key written by hand is skipped over.
+	if err := d.Write("orders", "00000000000000000002", 1); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var keys []string
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := d.Insert("orders", i)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mutex.Lock()
+			keys = append(keys, key)
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Strings(keys)
+	if len(keys) != 50 || keys[0] != "00000000000000000001" || keys[1] != "00000000000000000003" || keys[49] != "00000000000000000051" {
+		t.Fatalf("Insert keys = %q", keys)
+	}
+	d.Close()
+
+	if d, err = New(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if key, err := d.Insert("orders", 1); err != nil || key != "00000000000000000052" {
+		t.Errorf("Insert after reopening = %q, %v; want the sequence continued", key, err)
+	}
+	// The sequence file is neither a record nor a stray file.
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+	if records, err := d.ReadAll("orders"); err != nil || len(records) != 52 {
+		t.Errorf("ReadAll = %d records, %v; want 52", len(records), err)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	// The timestamp of the example in the ULID specification.
+	id, err := newULID(time.UnixMilli(1469918176385))
+	if err != nil || id[:10] != "01ARYZ6S41" {
+		t.Errorf("newULID = %q, %v; want the timestamp 01ARYZ6S41", id, err)
+	}
+}
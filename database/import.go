@@ -0,0 +1,366 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultImportBatchSize = 100
+
+// ImportFormat is how the source of ImportInto holds its records.
+type ImportFormat int
+
+const (
+	// ImportJSONLines holds a JSON object per line. Blank lines are
+	// skipped.
+	ImportJSONLines ImportFormat = iota
+	// ImportCSV holds a header line naming the fields, with dots for
+	// nested fields, then a record per line, all of whose values are
+	// strings.
+	ImportCSV
+)
+
+// ImportOptions tunes ImportInto.
+type ImportOptions struct {
+	Format ImportFormat
+	// KeyField is the field holding the key of each record, with dots for
+	// nested fields; it must be a string or a number. Records without it,
+	// and all of them if it is empty, get keys as Insert gives them out.
+	KeyField string
+	// BatchSize is how many records are written at a time, under one
+	// collection lock, 100 by default.
+	BatchSize int
+	// Progress, if set, is called after every batch.
+	Progress func(ImportProgress)
+	// Size is how many bytes the source holds, if known, to estimate how
+	// long the import has to go.
+	Size int64
+	// MaxErrors stops the import once that many records failed; zero goes
+	// on to the end.
+	MaxErrors int
+}
+
+// ImportProgress is how far along an import is.
+type ImportProgress struct {
+	// Records counts the records read so far, written or failed.
+	Records int
+	Written int
+	Failed  int
+	// Bytes counts the bytes read of the source.
+	Bytes   int64
+	Elapsed time.Duration
+	// ETA estimates the time left from the bytes read so far, zero unless
+	// ImportOptions.Size is set.
+	ETA time.Duration
+}
+
+// ImportReport is what ImportInto did.
+type ImportReport struct {
+	Written  int
+	Bytes    int64
+	Duration time.Duration
+	// Errors are the records that could not be written, in source order.
+	Errors []ImportError
+}
+
+// ImportError is a record ImportInto could not write.
+type ImportError struct {
+	// Line is where the record is in the source, counting from 1.
+	Line int
+	// Key is the key of the record, empty if it was not read.
+	Key string
+	Err error
+}
+
+func (e ImportError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("line %d (key %s): %v", e.Line, e.Key, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// importRecord is a record read from the source of an import.
+type importRecord struct {
+	line int
+	key  string
+	doc  map[string]interface{}
+	err  error
+}
+
+// ImportInto writes the records read from source into collection, in
+// batches, overwriting records with the same keys, for loading datasets
+// too large to write one by one. Records that cannot be read or written
+// are reported rather than ending the import, which stops only on errors
+// of the database itself, such as a full disk; the report then holds what
+// was done until then.
+func (d *Driver) ImportInto(collection string, source io.Reader, opts ImportOptions) (*ImportReport, error) {
+	return d.ImportIntoContext(context.Background(), collection, source, opts)
+}
+
+// ImportIntoContext is ImportInto with a context to trace the operation in
+// and cancel it between batches.
+func (d *Driver) ImportIntoContext(ctx context.Context, collection string, source io.Reader, opts ImportOptions) (report *ImportReport, err error) {
+	op := d.startOp(ctx, "import", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to save records")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.checkView(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return nil, err
+	}
+	if opts.KeyField != "" {
+		if err := validField(opts.KeyField); err != nil {
+			return nil, err
+		}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatchSize
+	}
+
+	counted := &countingReader{r: source}
+	var next func() (importRecord, error)
+	switch opts.Format {
+	case ImportJSONLines:
+		next = jsonLines(counted)
+	case ImportCSV:
+		next = csvRecords(counted)
+	default:
+		return nil, fmt.Errorf("unknown import format %d", opts.Format)
+	}
+
+	start := time.Now()
+	report = &ImportReport{Errors: []ImportError{}}
+	defer func() {
+		report.Bytes = counted.n
+		report.Duration = time.Since(start)
+	}()
+	batch := make([]importRecord, 0, opts.BatchSize)
+	for done := false; !done; {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		batch = batch[:0]
+		for len(batch) < opts.BatchSize {
+			rec, err := next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				return report, err
+			}
+			if rec.err == nil && opts.KeyField != "" {
+				rec.key, rec.err = importKey(rec.doc, opts.KeyField)
+			}
+			batch = append(batch, rec)
+		}
+		if err := d.importBatch(op, collection, batch, report); err != nil {
+			return report, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(importProgress(report, counted.n, opts.Size, start))
+		}
+		if opts.MaxErrors > 0 && len(report.Errors) >= opts.MaxErrors {
+			return report, fmt.Errorf("import stopped after %d records failed", len(report.Errors))
+		}
+	}
+	d.logEvent(slog.LevelInfo, "Imported records", slog.String("collection", collection),
+		slog.Int("records", report.Written), slog.Int("failed", len(report.Errors)))
+	return report, nil
+}
+
+// importBatch writes a batch of records, adding those that fail to the
+// report, and returns only errors that would fail any record.
+func (d *Driver) importBatch(op *opTimer, collection string, batch []importRecord, report *ImportReport) error {
+	encoded := make([][]byte, len(batch))
+	var size int64
+	for i := range batch {
+		rec := &batch[i]
+		if rec.err != nil {
+			continue
+		}
+		if encoded[i], rec.err = d.encodeDocument(collection, rec.doc); rec.err == nil {
+			size += int64(len(encoded[i]))
+		}
+	}
+	if size > 0 {
+		if err := d.checkSpace(collection, size); err != nil {
+			return err
+		}
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	for i, rec := range batch {
+		if rec.err == nil && rec.key == "" {
+			var err error
+			if rec.key, err = d.newKey(collection); err != nil {
+				return err
+			}
+		}
+		if rec.err == nil {
+			rec.err = d.checkRecordSize(collection, rec.key, encoded[i])
+		}
+		if rec.err == nil {
+			err := d.writeRecordLocked(op, collection, rec.key, bytes.NewReader(encoded[i]), time.Time{})
+			if err != nil && !recordError(err) {
+				return err
+			}
+			rec.err = err
+		}
+		if rec.err != nil {
+			report.Errors = append(report.Errors, ImportError{Line: rec.line, Key: rec.key, Err: rec.err})
+			continue
+		}
+		report.Written++
+	}
+	return nil
+}
+
+// recordError reports whether err, from writing a record, concerns that
+// record alone rather than the database.
+func recordError(err error) bool {
+	var tooLarge *RecordTooLargeError
+	return errors.As(err, &tooLarge) || errors.Is(err, ErrInvalidName) ||
+		errors.Is(err, ErrKeyCollision) || errors.Is(err, ErrAppendOnly) || errors.Is(err, ErrSchemaViolation)
+}
+
+func importProgress(report *ImportReport, n, size int64, start time.Time) ImportProgress {
+	p := ImportProgress{
+		Records: report.Written + len(report.Errors),
+		Written: report.Written,
+		Failed:  len(report.Errors),
+		Bytes:   n,
+		Elapsed: time.Since(start),
+	}
+	if size > 0 && n > 0 && n < size {
+		p.ETA = time.Duration(float64(p.Elapsed) * float64(size-n) / float64(n))
+	}
+	return p
+}
+
+// importKey reads the key of a record from field.
+func importKey(doc map[string]interface{}, field string) (string, error) {
+	v, ok := getField(doc, field)
+	if !ok {
+		return "", nil
+	}
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("empty key field %s", field)
+		}
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("key field %s is not a string or number", field)
+}
+
+// jsonLines reads a JSON object per line of r.
+func jsonLines(r io.Reader) func() (importRecord, error) {
+	br := bufio.NewReader(r)
+	line := 0
+	return func() (importRecord, error) {
+		for {
+			b, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return importRecord{}, err
+			}
+			if len(b) == 0 && err == io.EOF {
+				return importRecord{}, io.EOF
+			}
+			line++
+			if b = bytes.TrimSpace(b); len(b) == 0 {
+				continue
+			}
+			rec := importRecord{line: line}
+			if rec.err = json.Unmarshal(b, &rec.doc); rec.err == nil && rec.doc == nil {
+				rec.err = errors.New("not a JSON object")
+			}
+			return rec, nil
+		}
+	}
+}
+
+// csvRecords reads the records of a CSV file with a header line.
+func csvRecords(r io.Reader) func() (importRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var header []string
+	return func() (importRecord, error) {
+		if header == nil {
+			var err error
+			if header, err = cr.Read(); err != nil {
+				return importRecord{}, err
+			}
+			for _, field := range header {
+				if err := validField(field); err != nil {
+					return importRecord{}, fmt.Errorf("csv header: %w", err)
+				}
+			}
+		}
+		values, err := cr.Read()
+		line, _ := cr.FieldPos(0)
+		rec := importRecord{line: line}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rec.line, rec.err = parseErr.StartLine, parseErr.Err
+			return rec, nil
+		}
+		if err != nil {
+			return importRecord{}, err
+		}
+		if len(values) != len(header) {
+			rec.err = fmt.Errorf("%d values for %d fields", len(values), len(header))
+			return rec, nil
+		}
+		rec.doc = make(map[string]interface{}, len(header))
+		for i, field := range header {
+			setPath(rec.doc, field, values[i])
+		}
+		return rec, nil
+	}
+}
+
+// setPath sets the field at a dotted path of doc, creating the objects on
+// the way.
+func setPath(doc map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = v
+}
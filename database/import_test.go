@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportInto(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{MaxRecordSize: 200, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	source := `{"id": "a", "n": 1}
+{"id": 2, "n": 2}
+
+not json
+{"n": 3}
+[1, 2]
+{"id": "big", "s": "` + strings.Repeat("x", 300) + `"}
+`
+	var progress []ImportProgress
+	report, err := d.ImportInto("things", strings.NewReader(source), ImportOptions{
+		KeyField:  "id",
+		BatchSize: 2,
+		Size:      int64(len(source)),
+		Progress:  func(p ImportProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Written != 3 || report.Bytes != int64(len(source)) {
+		t.Errorf("report = %+v, want 3 records written of %d bytes", report, len(source))
+	}
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{4, 6, 7}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("errors on lines %v, want %v: %v", lines, want, report.Errors)
+	}
+	var tooLarge *RecordTooLargeError
+	if last := report.Errors[len(report.Errors)-1]; last.Key != "big" || !errors.As(last, &tooLarge) {
+		t.Errorf("error of the large record = %v, want a RecordTooLargeError of key big", last)
+	}
+	if len(progress) != 4 || progress[len(progress)-1].Records != 6 || progress[len(progress)-1].Failed != 3 {
+		t.Errorf("progress = %+v, want 4 batches ending at 6 records, 3 failed", progress)
+	}
+
+	keys, err := d.Keys("things")
+	if err != nil || len(keys) != 3 || keys[1] != "2" || keys[2] != "a" {
+		t.Errorf("Keys = %v, %v; want a generated key, 2 and a", keys, err)
+	}
+}
+
+func TestImportIntoCSV(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	source := "id,name,address.city\n1,Ann,Paris\n2,Bob\n3,Cy,Rome\n"
+	report, err := d.ImportInto("people", strings.NewReader(source), ImportOptions{Format: ImportCSV, KeyField: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Written != 2 || len(report.Errors) != 1 || report.Errors[0].Line != 3 {
+		t.Errorf("report = %+v, want 2 written and line 3 failed", report)
+	}
+	var v map[string]interface{}
+	if err := d.Read("people", "1", &v); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"id": "1", "name": "Ann", "address": map[string]interface{}{"city": "Paris"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("imported record = %v, want %v", v, want)
+	}
+
+	if _, err := d.ImportInto("people", strings.NewReader("id,bad..field\n1,2\n"), ImportOptions{Format: ImportCSV}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("ImportInto with an invalid header = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestImportIntoMaxErrors(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	source := strings.Repeat("not json\n", 5) + `{"id": "a"}` + "\n"
+	report, err := d.ImportInto("things", strings.NewReader(source), ImportOptions{KeyField: "id", BatchSize: 1, MaxErrors: 2})
+	if err == nil || report == nil || len(report.Errors) != 2 || report.Written != 0 {
+		t.Errorf("ImportInto = %+v, %v; want to stop after 2 errors", report, err)
+	}
+}
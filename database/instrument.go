@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/siraiwaqarali/golang-own-database/database"
+
+// Op describes a finished driver operation, as passed to the hooks added
+// with Instrument.
+type Op struct {
+	// Name is "write", "read", "readall", "keys", "delete" or "find".
+	Name       string
+	Collection string
+	// Key is empty for operations on a whole collection.
+	Key string
+	// ID numbers the operation within the Driver, pairing the OnOpStart
+	// and OnOpEnd of an Observer for it.
+	ID uint64
+	// Principal names the caller from the context, if any.
+	Principal string
+	// Bytes counts the encoded records written or read.
+	Bytes    int64
+	Duration time.Duration
+	// LockWait is the part of Duration spent waiting for the collection
+	// lock.
+	LockWait time.Duration
+	Err      error
+}
+
+// Instrument adds a hook called after every Write, WriteTTL, WriteRaw,
+// Read, ReadTo, ReadAll, Keys, Delete, DeleteTree and Find, including those
+// made through a Namespace, and after every record read by ReadResolved.
+// Hooks run on the calling goroutine and should be quick.
+//
+// The same operations are traced as OpenTelemetry spans; use the Context
+// variants of the methods to make them children of a request's span.
+func (d *Driver) Instrument(hook func(Op)) {
+	d.hookMutex.Lock()
+	defer d.hookMutex.Unlock()
+	d.hooks = append(d.hooks, hook)
+}
+
+// Observer is told of every operation the hooks added with Instrument
+// are, as it starts and as it ends, to feed metrics, logs or sampling of
+// one's own choosing. OnOpStart gets the Name, Collection, Key, ID and
+// Principal of the operation, and OnOpEnd all of it. Both run on the
+// calling goroutine, OnOpStart before the operation takes any lock, and
+// should be quick; operations run concurrently, so they may be called from
+// many goroutines at once.
+type Observer interface {
+	OnOpStart(op Op)
+	OnOpEnd(op Op)
+}
+
+// Observe adds an Observer of every operation from now on.
+func (d *Driver) Observe(o Observer) {
+	d.hookMutex.Lock()
+	defer d.hookMutex.Unlock()
+	d.observers = append(d.observers, o)
+}
+
+// opTimer measures one operation for the hooks, its trace span and the
+// slow operation log. It is nil when none of them wants it, and its methods
+// then do nothing beyond what they must.
+type opTimer struct {
+	d         *Driver
+	hooks     []func(Op)
+	observers []Observer
+	span      trace.Span
+	op        Op
+	start     time.Time
+}
+
+// startOp begins an operation in a span that is a child of any span in ctx.
+func (d *Driver) startOp(ctx context.Context, name, collection, key string) *opTimer {
+	d.hookMutex.RLock()
+	hooks, observers := d.hooks, d.observers
+	d.hookMutex.RUnlock()
+
+	_, span := d.tracer.Start(ctx, name+" "+collection)
+	recording := span.IsRecording()
+	// Record metadata names the principal of a write.
+	if !recording && len(hooks) == 0 && len(observers) == 0 && d.slowOp <= 0 && !d.recordMeta {
+		return nil
+	}
+	op := Op{Name: name, Collection: collection, Key: key, ID: d.opSeq.Add(1)}
+	if p := PrincipalFrom(ctx); p != nil {
+		op.Principal = p.Name
+	}
+	if recording {
+		span.SetAttributes(
+			attribute.String("db.system.name", "owndb"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.collection.name", collection),
+		)
+		if key != "" {
+			span.SetAttributes(attribute.String("owndb.key", key))
+		}
+		if op.Principal != "" {
+			span.SetAttributes(attribute.String("enduser.id", op.Principal))
+		}
+	}
+	for _, o := range observers {
+		o.OnOpStart(op)
+	}
+	return &opTimer{d: d, hooks: hooks, observers: observers, span: span, op: op, start: time.Now()}
+}
+
+func (t *opTimer) lock(m *sync.Mutex) {
+	if t == nil {
+		m.Lock()
+		return
+	}
+	start := time.Now()
+	m.Lock()
+	t.op.LockWait += time.Since(start)
+}
+
+// principal returns the name of the principal the operation runs as.
+func (t *opTimer) principal() string {
+	if t == nil {
+		return ""
+	}
+	return t.op.Principal
+}
+
+func (t *opTimer) addBytes(n int64) {
+	if t != nil {
+		t.op.Bytes += n
+	}
+}
+
+// end reports the operation to the hooks and ends its span; it is meant to
+// be deferred with the address of the named error result.
+func (t *opTimer) end(err *error) {
+	if t == nil {
+		return
+	}
+	t.op.Duration = time.Since(t.start)
+	t.op.Err = *err
+	for _, hook := range t.hooks {
+		hook(t.op)
+	}
+	for _, o := range t.observers {
+		o.OnOpEnd(t.op)
+	}
+	if t.d.slowOp > 0 && t.op.Duration >= t.d.slowOp {
+		t.d.logEvent(slog.LevelWarn, "Slow operation", opAttrs(t.op)...)
+	}
+
+	if t.span.IsRecording() {
+		t.span.SetAttributes(attribute.Int64("owndb.bytes", t.op.Bytes))
+		// A missing record is an answer, not a failure.
+		if t.op.Err != nil && !errors.Is(t.op.Err, fs.ErrNotExist) {
+			t.span.RecordError(t.op.Err)
+			t.span.SetStatus(codes.Error, t.op.Err.Error())
+		}
+	}
+	t.span.End()
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureLogger is a Logger keeping its messages, prefixed with their
+// level.
+type captureLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) log(level, format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, level+" "+strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (l *captureLogger) Fatal(format string, v ...interface{}) { l.log("FATAL", format, v...) }
+func (l *captureLogger) Error(format string, v ...interface{}) { l.log("ERROR", format, v...) }
+func (l *captureLogger) Warn(format string, v ...interface{})  { l.log("WARN", format, v...) }
+func (l *captureLogger) Info(format string, v ...interface{})  { l.log("INFO", format, v...) }
+func (l *captureLogger) Debug(format string, v ...interface{}) { l.log("DEBUG", format, v...) }
+func (l *captureLogger) Trace(format string, v ...interface{}) { l.log("TRACE", format, v...) }
+
+// matching returns the lines starting with prefix.
+func (l *captureLogger) matching(prefix string) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var lines []string
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestInstrument(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var mutex sync.Mutex
+	var ops []Op
+	d.Instrument(func(op Op) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ops = append(ops, op)
+	})
+
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadAll("users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("users", "nobody"); err == nil {
+		t.Fatal("Delete of a missing record succeeded")
+	}
+
+	want := []struct{ name, key string }{{"write", "ada"}, {"read", "ada"}, {"readall", ""}, {"delete", "nobody"}}
+	if len(ops) != len(want) {
+		t.Fatalf("hook saw %+v", ops)
+	}
+	for i, w := range want {
+		op := ops[i]
+		if op.Name != w.name || op.Collection != "users" || op.Key != w.key || op.Duration <= 0 {
+			t.Errorf("op %d = %+v, want %s of users/%s", i, op, w.name, w.key)
+		}
+	}
+	if ops[0].Bytes == 0 || ops[1].Bytes != ops[0].Bytes {
+		t.Errorf("write counted %d bytes and read %d", ops[0].Bytes, ops[1].Bytes)
+	}
+	if ops[0].Err != nil || ops[3].Err == nil {
+		t.Errorf("errors %v, %v; want only the delete to fail", ops[0].Err, ops[3].Err)
+	}
+	if ops[0].ID == 0 || ops[1].ID <= ops[0].ID {
+		t.Errorf("op IDs %d, %d", ops[0].ID, ops[1].ID)
+	}
+}
+
+func TestSlowOpThreshold(t *testing.T) {
+	l := &captureLogger{}
+	d, err := New(t.TempDir(), &Options{Logger: l, SlowOpThreshold: time.Nanosecond, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("users", "ada l", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	slow := l.matching("WARN Slow operation")
+	if len(slow) != 1 {
+		t.Fatalf("logged %q, want one slow operation", l.lines)
+	}
+	for _, want := range []string{" op=write", " collection=users", ` key="ada l"`, " duration=", " bytes="} {
+		if !strings.Contains(slow[0], want) {
+			t.Errorf("%q is missing %s", slow[0], want)
+		}
+	}
+
+	fast, err := New(t.TempDir(), &Options{Logger: l, SlowOpThreshold: time.Hour, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+	if err := fast.Write("users", "bob", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(l.matching("WARN Slow operation")); n != 1 {
+		t.Errorf("logged %d slow operations under the threshold", n-1)
+	}
+}
+
+// recordingObserver keeps the operations it is told of.
+type recordingObserver struct {
+	mutex        sync.Mutex
+	starts, ends []Op
+}
+
+func (o *recordingObserver) OnOpStart(op Op) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.starts = append(o.starts, op)
+}
+
+func (o *recordingObserver) OnOpEnd(op Op) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.ends = append(o.ends, op)
+}
+
+func TestObserver(t *testing.T) {
+	configured, added := &recordingObserver{}, &recordingObserver{}
+	d, err := New(t.TempDir(), &Options{Observers: []Observer{configured}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	ctx := WithPrincipal(context.Background(), &Principal{Name: "alice", Role: &Role{Name: "admin", Collections: map[string]Permission{"*": PermAll}}})
+	if err := d.WriteContext(ctx, "users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Observe(added)
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("users", "nobody"); err == nil {
+		t.Fatal("Delete of a missing record succeeded")
+	}
+
+	if len(configured.starts) != 3 || len(configured.ends) != 3 {
+		t.Fatalf("observer saw starts %+v and ends %+v, want 3 of each", configured.starts, configured.ends)
+	}
+	for i, name := range []string{"write", "read", "delete"} {
+		start, end := configured.starts[i], configured.ends[i]
+		if start.Name != name || start.Collection != "users" || start.ID == 0 || start.ID != end.ID {
+			t.Errorf("start %d = %+v, want the %s ending as %+v", i, start, name, end)
+		}
+		if start.Duration != 0 || start.Bytes != 0 || start.Err != nil {
+			t.Errorf("start %d = %+v; want only what is known before the operation", i, start)
+		}
+		if end.Name != name || end.Duration <= 0 {
+			t.Errorf("end %d = %+v, want the %s with its duration", i, end, name)
+		}
+	}
+	if start, end := configured.starts[0], configured.ends[0]; start.Principal != "alice" || end.Principal != "alice" || end.Bytes == 0 {
+		t.Errorf("write = %+v then %+v; want alice's write of some bytes", start, end)
+	}
+	if end := configured.ends[2]; end.Err == nil || end.Key != "nobody" {
+		t.Errorf("delete ended %+v, want its error", end)
+	}
+	if len(added.starts) != 2 || len(added.ends) != 2 || added.ends[0].ID != configured.ends[1].ID {
+		t.Errorf("observer added with Observe saw %+v, want the read and delete", added.ends)
+	}
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leases live under their own directory, a directory per lease holding a
+// file per generation: taking a lease over creates the next generation
+// exclusively, so of several processes racing for it exactly one wins.
+const leasesDir = ".leases"
+
+// ErrLeaseHeld is returned by AcquireLease while someone else holds the
+// lease.
+var ErrLeaseHeld = errors.New("lease held")
+
+// Lease is the right to a name until it expires, for running a job on one
+// process at a time or electing a leader among processes sharing the
+// storage. Leases change no records, so read-only Drivers, which can share
+// a directory, can take them too. They are exclusive between processes on
+// backends implementing CreateBackend, and otherwise only within this one.
+// Holders should renew well before Expires: processes whose clocks
+// disagree by more than the margin can both hold a lease.
+type Lease struct {
+	Name string
+	// Token identifies the holder.
+	Token string
+	// Generation goes up every time the lease changes hands or is renewed,
+	// so it fences off work done by a holder that lost the lease.
+	Generation int64
+	Expires    time.Time
+
+	d *Driver
+}
+
+type leaseRecord struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// leaseCreateTimeout is how long a lease file may stay empty before the
+// process creating it is taken to have crashed.
+const leaseCreateTimeout = time.Minute
+
+// leaseCreates serializes creating lease files on backends that cannot do
+// it atomically.
+var leaseCreates sync.Mutex
+
+// AcquireLease takes the lease on name for ttl, or fails with ErrLeaseHeld
+// if it is held and has not expired.
+func (d *Driver) AcquireLease(name string, ttl time.Duration) (*Lease, error) {
+	return d.AcquireLeaseContext(context.Background(), name, ttl)
+}
+
+// AcquireLeaseContext is AcquireLease with a context carrying the caller.
+func (d *Driver) AcquireLeaseContext(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := validName("lease", name); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lease duration %v - must be positive", ttl)
+	}
+	if err := d.authorizeContext(ctx, path.Join(leasesDir, name), PermWrite); err != nil {
+		return nil, err
+	}
+
+	gen, rec, err := d.currentLease(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if gen > 0 && rec.Expires.After(now) {
+		return nil, fmt.Errorf("%w: %s until %s", ErrLeaseHeld, name, rec.Expires.Format(time.RFC3339))
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	l := &Lease{Name: name, Token: hex.EncodeToString(token), Generation: gen + 1, Expires: now.Add(ttl), d: d}
+	b, err := json.Marshal(leaseRecord{Token: l.Token, Expires: l.Expires})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.createFile(l.path(), b); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("%w: %s was just taken", ErrLeaseHeld, name)
+		}
+		return nil, err
+	}
+
+	// The previous generation only takes up space now.
+	if gen > 0 {
+		if err := d.backend.Delete(leasePath(name, gen)); err != nil && !isNotExist(err) {
+			d.logEvent(slog.LevelWarn, "Removing an old lease failed", slog.String("lease", name), slog.Any("error", err))
+		}
+	}
+	return l, nil
+}
+
+// Renew extends the lease to ttl from now. It fails with ErrLeaseExpired
+// once the lease has expired or changed hands.
+func (l *Lease) Renew(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid lease duration %v - must be positive", ttl)
+	}
+	return l.advance(time.Now().Add(ttl))
+}
+
+// Release gives the lease up before it expires, so it can be taken again
+// at once.
+func (l *Lease) Release() error {
+	return l.advance(time.Time{})
+}
+
+// advance moves the lease on to the next generation, expiring then. The
+// generation is created exclusively, as AcquireLease does, so a lease
+// taken over since check is never written over.
+func (l *Lease) advance(expires time.Time) error {
+	if err := l.check(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(leaseRecord{Token: l.Token, Expires: expires})
+	if err != nil {
+		return err
+	}
+	gen := l.Generation
+	if err := l.d.createFile(leasePath(l.Name, gen+1), b); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrLeaseExpired, l.Name)
+		}
+		return err
+	}
+	l.Generation, l.Expires = gen+1, expires
+	if err := l.d.backend.Delete(leasePath(l.Name, gen)); err != nil && !isNotExist(err) {
+		l.d.logEvent(slog.LevelWarn, "Removing an old lease failed", slog.String("lease", l.Name), slog.Any("error", err))
+	}
+	return nil
+}
+
+// check makes sure the lease is still held by its holder.
+func (l *Lease) check() error {
+	if err := l.d.checkOpen(); err != nil {
+		return err
+	}
+	gen, rec, err := l.d.currentLease(l.Name)
+	if err != nil {
+		return err
+	}
+	if gen != l.Generation || rec.Token != l.Token || !rec.Expires.After(time.Now()) {
+		return fmt.Errorf("%w: %s", ErrLeaseExpired, l.Name)
+	}
+	return nil
+}
+
+func (l *Lease) path() string {
+	return leasePath(l.Name, l.Generation)
+}
+
+func leasePath(name string, gen int64) string {
+	return path.Join(leasesDir, name, fmt.Sprintf("%020d", gen))
+}
+
+// currentLease returns the latest generation of a lease and its record, or
+// generation 0 if it was never taken.
+func (d *Driver) currentLease(name string) (int64, leaseRecord, error) {
+	var rec leaseRecord
+	files, err := d.backend.List(path.Join(leasesDir, name))
+	if isNotExist(err) {
+		return 0, rec, nil
+	}
+	if err != nil {
+		return 0, rec, err
+	}
+	var gen int64
+	for _, file := range files {
+		if strings.HasSuffix(file, ".tmp") || isDirName(file) {
+			continue
+		}
+		if g, err := strconv.ParseInt(file, 10, 64); err == nil && g > gen {
+			gen = g
+		}
+	}
+	if gen == 0 {
+		return 0, rec, nil
+	}
+	b, err := d.backend.Get(leasePath(name, gen))
+	if err != nil {
+		return 0, rec, err
+	}
+	if len(b) == 0 {
+		// Created but not written yet, or left so by a crash if it has not
+		// been written for a while; that generation is then taken as expired.
+		if sb, ok := d.backend.(StatBackend); ok {
+			if _, mtime, err := sb.Stat(leasePath(name, gen)); err == nil && time.Since(mtime) > leaseCreateTimeout {
+				return gen, rec, nil
+			}
+		}
+		return 0, rec, fmt.Errorf("%w: %s is being taken", ErrLeaseHeld, name)
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return 0, rec, fmt.Errorf("reading lease %s: %w", name, err)
+	}
+	return gen, rec, nil
+}
+
+// createFile stores b at p unless a file is there already.
+func (d *Driver) createFile(p string, b []byte) error {
+	if cb, ok := d.backend.(CreateBackend); ok {
+		return cb.Create(p, b)
+	}
+	leaseCreates.Lock()
+	defer leaseCreates.Unlock()
+	if _, err := d.backend.Get(p); err == nil {
+		return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+	} else if !isNotExist(err) {
+		return err
+	}
+	return d.backend.Put(p, b)
+}
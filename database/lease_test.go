@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireLease(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	l, err := d.AcquireLease("job", 50*time.Millisecond)
+	if err != nil || l.Generation != 1 {
+		t.Fatalf("AcquireLease = %+v, %v", l, err)
+	}
+	if _, err := d.AcquireLease("job", time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("AcquireLease of a held lease = %v, want ErrLeaseHeld", err)
+	}
+	if err := l.Renew(50 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := l.Renew(time.Second); !errors.Is(err, ErrLeaseExpired) {
+		t.Fatalf("Renew of an expired lease = %v, want ErrLeaseExpired", err)
+	}
+
+	l2, err := d.AcquireLease("job", time.Second)
+	if err != nil || l2.Generation != l.Generation+1 {
+		t.Fatalf("AcquireLease after expiry = %+v, %v", l2, err)
+	}
+	if err := l.Release(); !errors.Is(err, ErrLeaseExpired) {
+		t.Fatalf("Release by the old holder = %v, want ErrLeaseExpired", err)
+	}
+	if err := l2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// staleListBackend misses a file in its listings, as a holder checking its
+// lease just before another process takes it over would.
+type staleListBackend struct {
+	Backend
+	missed string
+}
+
+func (b staleListBackend) List(dir string) ([]string, error) {
+	files, err := b.Backend.List(dir)
+	var kept []string
+	for _, f := range files {
+		if path.Join(dir, f) != b.missed {
+			kept = append(kept, f)
+		}
+	}
+	return kept, err
+}
+
+func TestRenewTakenOver(t *testing.T) {
+	backend := &staleListBackend{Backend: NewMemoryBackend()}
+	d, err := New("", &Options{Backend: backend, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	l, err := d.AcquireLease("job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Renew(time.Minute); err != nil || l.Generation != 2 {
+		t.Fatalf("Renew = %v, generation %d; want 2", err, l.Generation)
+	}
+	backend.missed = leasePath("job", 3)
+	if err := backend.Put(backend.missed, []byte(`{"token":"other"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Renew(time.Minute); !errors.Is(err, ErrLeaseExpired) {
+		t.Fatalf("Renew of a lease taken over = %v, want ErrLeaseExpired", err)
+	}
+	if b, err := backend.Get(backend.missed); err != nil || string(b) != `{"token":"other"}` {
+		t.Errorf("lease taken over = %s, %v; want it left alone", b, err)
+	}
+}
+
+func TestAcquireLeaseRace(t *testing.T) {
+	dir := t.TempDir()
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := New(dir, &Options{Backend: NewFileBackend(dir), TTLSweepInterval: -1})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer d.Close()
+			if _, err := d.AcquireLease("job", time.Minute); err == nil {
+				wins.Add(1)
+			} else if !errors.Is(err, ErrLeaseHeld) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := wins.Load(); n != 1 {
+		t.Fatalf("%d processes took the lease, want 1", n)
+	}
+}
+
+func TestAcquireLeaseEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// A process that crashed between creating its lease file and writing
+	// it, as one could before lease files were linked into place.
+	p := filepath.Join(dir, filepath.FromSlash(leasePath("job", 1)))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.AcquireLease("job", time.Minute); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("AcquireLease while the lease is being taken = %v, want ErrLeaseHeld", err)
+	}
+
+	old := time.Now().Add(-2 * leaseCreateTimeout)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatal(err)
+	}
+	l, err := d.AcquireLease("job", time.Minute)
+	if err != nil || l.Generation != 2 {
+		t.Fatalf("AcquireLease over a stale empty file = %+v, %v", l, err)
+	}
+}
+
+func TestFileBackendCreate(t *testing.T) {
+	dir := t.TempDir()
+	b := NewFileBackend(dir).(CreateBackend)
+	if err := b.Create("leases/job", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Create("leases/job", []byte("two")); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Create of an existing file = %v, want fs.ErrExist", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "leases", "job")); err != nil || string(got) != "one" {
+		t.Fatalf("file = %q, %v, want one", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "leases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Create left %d files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrClosed      = errors.New("database is closed")
+	ErrAlreadyOpen = errors.New("database is already open in this process")
+)
+
+var openDatabases = struct {
+	sync.Mutex
+	dirs map[string]bool
+}{dirs: make(map[string]bool)}
+
+func (d *Driver) register() error {
+	abs, err := filepath.Abs(d.dir)
+	if err != nil {
+		return err
+	}
+	openDatabases.Lock()
+	defer openDatabases.Unlock()
+	if openDatabases.dirs[abs] {
+		return ErrAlreadyOpen
+	}
+	openDatabases.dirs[abs] = true
+	d.registered = abs
+	return nil
+}
+
+func (d *Driver) checkOpen() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (d *Driver) checkWritable() error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if d.readOnly {
+		return ErrReadOnly
+	}
+	if d.diskReadOnly.Load() {
+		return errDiskReadOnly
+	}
+	return nil
+}
+
+// goBackground runs fn until the Driver is closed; fn must return once done
+// is closed. Close waits for every background goroutine to finish.
+func (d *Driver) goBackground(fn func(done <-chan struct{})) {
+	d.background.Add(1)
+	go func() {
+		defer d.background.Done()
+		fn(d.done)
+	}()
+}
+
+// onClose registers cleanup to run on Close after background goroutines
+// have stopped, in reverse order of registration.
+func (d *Driver) onClose(fn func() error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.closers = append(d.closers, fn)
+}
+
+// Close stops background work, waits for operations holding a collection
+// lock when it is called to release it, runs the registered flushes and
+// releases the database lock. Operations that have not taken a collection
+// lock yet, or take none, are not waited for, so callers should stop using
+// the Driver before closing it. Every later call on the Driver fails with
+// ErrClosed.
+func (d *Driver) Close() error {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return ErrClosed
+	}
+	d.closed = true
+	close(d.done)
+	mutexes := make([]*sync.Mutex, 0, len(d.mutexes))
+	for _, m := range d.mutexes {
+		mutexes = append(mutexes, m)
+	}
+	closers := d.closers
+	d.mutex.Unlock()
+
+	d.background.Wait()
+	for _, m := range mutexes {
+		m.Lock()
+		m.Unlock()
+	}
+	d.closeWatchers()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		errs = append(errs, closers[i]())
+	}
+
+	if d.lock != nil {
+		errs = append(errs, unlockFile(d.lock), d.lock.Close())
+		d.lock = nil
+	}
+	if d.registered != "" {
+		openDatabases.Lock()
+		delete(openDatabases.dirs, d.registered)
+		openDatabases.Unlock()
+	}
+
+	d.log.Debug("Closed the database at '%s'\n", d.dir)
+	return errors.Join(errs...)
+}
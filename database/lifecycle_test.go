@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]string
+	if err := d.Read("users", "ada", &v); !errors.Is(err, ErrClosed) {
+		t.Errorf("Read after Close = %v, want ErrClosed", err)
+	}
+	if err := d.Write("users", "bob", v); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close = %v, want ErrClosed", err)
+	}
+	if err := d.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+
+	// Closing releases the directory for the next open.
+	d, err = New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Read("users", "ada", &v); err != nil {
+		t.Errorf("Read after reopening: %v", err)
+	}
+}
+
+func TestDoubleOpen(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := New(dir, nil); !errors.Is(err, ErrAlreadyOpen) {
+		t.Errorf("second New = %v, want ErrAlreadyOpen", err)
+	}
+	if _, err := New(dir+"/.", nil); !errors.Is(err, ErrAlreadyOpen) {
+		t.Errorf("second New by another path = %v, want ErrAlreadyOpen", err)
+	}
+}
+
+func TestCloseWaitsForBackground(t *testing.T) {
+	d, err := NewMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped, closed := false, false
+	d.goBackground(func(done <-chan struct{}) {
+		<-done
+		stopped = true
+	})
+	d.onClose(func() error {
+		if !stopped {
+			t.Error("closer ran before the background goroutine stopped")
+		}
+		closed = true
+		return nil
+	})
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Error("closer not run")
+	}
+}
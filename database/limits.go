@@ -0,0 +1,53 @@
+package database
+
+import (
+	"fmt"
+	"io"
+)
+
+// RecordTooLargeError is returned by Write when an encoded record exceeds
+// Options.MaxRecordSize. Nothing is written in that case.
+type RecordTooLargeError struct {
+	Collection string
+	Resource   string
+	Size       int64
+	Limit      int64
+}
+
+func (e *RecordTooLargeError) Error() string {
+	return fmt.Sprintf("record %s/%s is %d bytes - larger than the %d byte limit", e.Collection, e.Resource, e.Size, e.Limit)
+}
+
+// limitRecord fails reads from r with a RecordTooLargeError once more than
+// Options.MaxRecordSize bytes came through. Size is then the number of
+// bytes read so far, not the full size of the record.
+func (d *Driver) limitRecord(collection, resource string, r io.Reader) io.Reader {
+	if d.maxRecordSize <= 0 {
+		return r
+	}
+	return &limitedRecord{r: r, remaining: d.maxRecordSize, err: RecordTooLargeError{Collection: collection, Resource: resource, Limit: d.maxRecordSize}}
+}
+
+type limitedRecord struct {
+	r         io.Reader
+	remaining int64
+	err       RecordTooLargeError
+}
+
+func (l *limitedRecord) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		e := l.err
+		e.Size = e.Limit - l.remaining
+		return n, &e
+	}
+	return n, err
+}
+
+func (d *Driver) checkRecordSize(collection, resource string, b []byte) error {
+	if d.maxRecordSize > 0 && int64(len(b)) > d.maxRecordSize {
+		return &RecordTooLargeError{Collection: collection, Resource: resource, Size: int64(len(b)), Limit: d.maxRecordSize}
+	}
+	return nil
+}
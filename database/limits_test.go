@@ -0,0 +1,38 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMaxRecordSize(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{MaxRecordSize: 64, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("notes", "small", map[string]string{"text": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Write("notes", "big", map[string]string{"text": strings.Repeat("x", 100)})
+	var tooLarge *RecordTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Write of a large record = %v, want a RecordTooLargeError", err)
+	}
+	if tooLarge.Collection != "notes" || tooLarge.Resource != "big" || tooLarge.Limit != 64 || tooLarge.Size <= 64 {
+		t.Errorf("error = %+v", tooLarge)
+	}
+	if keys, _ := d.Keys("notes"); len(keys) != 1 {
+		t.Errorf("a record too large was written: %v", keys)
+	}
+
+	err = d.WriteRaw("notes", "raw", strings.NewReader(`{"text":"`+strings.Repeat("x", 100)+`"}`))
+	if !errors.As(err, &tooLarge) || tooLarge.Resource != "raw" {
+		t.Fatalf("WriteRaw of a large record = %v, want a RecordTooLargeError", err)
+	}
+	if keys, _ := d.Keys("notes"); len(keys) != 1 {
+		t.Errorf("a raw record too large was written: %v", keys)
+	}
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const lockFileName = ".lock"
+
+var (
+	ErrReadOnly = errors.New("database is read-only")
+	ErrLocked   = errors.New("database is locked by another process")
+)
+
+// acquireLock takes the cross-process lock on a file-backed database:
+// exclusive for writers, shared for read-only opens so several readers can
+// coexist but never alongside a writer.
+func (d *Driver) acquireLock() error {
+	path := filepath.Join(d.dir, lockFileName)
+	flag := os.O_RDWR | os.O_CREATE
+	if d.readOnly {
+		flag = os.O_RDONLY
+	}
+
+	f, err := os.OpenFile(path, flag, d.fileMode)
+	if d.readOnly && os.IsNotExist(err) {
+		// Nothing to share a lock with, and the directory may be on
+		// read-only media where the lock file cannot be created.
+		d.log.Debug("No lock file in '%s', opening without a lock\n", d.dir)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := lockFile(f, d.readOnly); err != nil {
+		f.Close()
+		return err
+	}
+	d.lock = f
+	return nil
+}
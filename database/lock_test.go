@@ -0,0 +1,74 @@
+//go:build unix || windows
+
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	ro, err := New(dir, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	var v map[string]string
+	if err := ro.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+	if err := ro.Write("users", "bob", v); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write = %v, want ErrReadOnly", err)
+	}
+	if err := ro.Delete("users", "ada"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestReadOnlyMissingDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	if _, err := New(dir, &Options{ReadOnly: true}); err == nil {
+		t.Fatal("opened a missing database read-only")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("read-only open created the directory: %v", err)
+	}
+}
+
+// The lock is taken as another process would, on a file of its own.
+func TestLockExcludesOtherProcesses(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, lockFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := lockFile(f, true); !errors.Is(err, ErrLocked) {
+		t.Fatalf("shared lock beside a writer = %v, want ErrLocked", err)
+	}
+
+	d.Close()
+	if err := lockFile(f, true); err != nil {
+		t.Fatalf("shared lock once the writer closed: %v", err)
+	}
+	defer unlockFile(f)
+	if _, err := New(dir, &Options{TTLSweepInterval: -1}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("New beside a reader = %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package database
+
+func longPath(p string) string {
+	return p
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package database
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath opts out of MAX_PATH by using the \\?\ form, which disables
+// Win32 path parsing and so needs an absolute, backslash-separated path.
+func longPath(p string) string {
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrJobRunning = errors.New("maintenance job already running")
+
+// MaintenanceJob runs Task on a schedule in the background while the
+// Driver is open. A run never overlaps the previous one: runs that come due
+// while it is still going are skipped.
+type MaintenanceJob struct {
+	// Name identifies the job in logs, MaintenanceStats and RunMaintenance.
+	Name string
+	// Schedule is parsed with ParseSchedule, like "@every 6h" or
+	// "30 3 * * *".
+	Schedule string
+	// Jitter delays each run by a random duration up to Jitter, so that
+	// processes sharing a schedule do not all run at once.
+	Jitter time.Duration
+	// Task does the work. CompactTask, SweepTask, VerifyTask, BackupTask
+	// and RetentionTask cover the built-in maintenance. ctx is canceled
+	// when the Driver is closed.
+	Task func(ctx context.Context, d *Driver) error
+}
+
+// JobStats describes a maintenance job's runs so far.
+type JobStats struct {
+	Name     string
+	Runs     int64
+	Failures int64
+	// Skipped counts runs that came due while the job was still running.
+	Skipped      int64
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	// LastSuccess is when the last run that did not fail started, to alert
+	// on a job that has not succeeded for too long.
+	LastSuccess time.Time
+	NextRun     time.Time
+}
+
+type job struct {
+	MaintenanceJob
+	schedule Schedule
+
+	// running is held for the length of a run.
+	running sync.Mutex
+
+	mutex sync.Mutex
+	stats JobStats
+}
+
+func CompactTask(ctx context.Context, d *Driver) error {
+	_, err := d.Compact()
+	return err
+}
+
+func SweepTask(ctx context.Context, d *Driver) error {
+	_, err := d.SweepExpired()
+	return err
+}
+
+// VerifyTask fails when Verify finds issues, which also makes Ready fail
+// until a later Verify comes back clean.
+func VerifyTask(ctx context.Context, d *Driver) error {
+	report, err := d.Verify()
+	if err != nil {
+		return err
+	}
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("verify found %d issues, first %s: %s", len(report.Issues), report.Issues[0].Path, report.Issues[0].Problem)
+	}
+	return nil
+}
+
+// BackupTask returns a task writing a Backup to a timestamped file in dir
+// and deleting all but the newest keep of them; keep 0 keeps every backup.
+// It fails with a DiskFullError without starting when dir has less room
+// than the database takes. BackupTaskWith rotates them by day and week.
+func BackupTask(dir string, keep int) func(ctx context.Context, d *Driver) error {
+	return BackupTaskWith(BackupPolicy{Dir: dir, KeepLast: keep})
+}
+
+// startMaintenance validates jobs and schedules them.
+func (d *Driver) startMaintenance(jobs []MaintenanceJob) error {
+	seen := make(map[string]bool)
+	for _, mj := range jobs {
+		if mj.Name == "" || strings.TrimSpace(mj.Name) != mj.Name {
+			return fmt.Errorf("invalid maintenance job name %q", mj.Name)
+		}
+		if seen[mj.Name] {
+			return fmt.Errorf("duplicate maintenance job %s", mj.Name)
+		}
+		seen[mj.Name] = true
+		if mj.Task == nil {
+			return fmt.Errorf("maintenance job %s has no task", mj.Name)
+		}
+		schedule, err := ParseSchedule(mj.Schedule)
+		if err != nil {
+			return fmt.Errorf("maintenance job %s: %w", mj.Name, err)
+		}
+		d.jobs = append(d.jobs, &job{MaintenanceJob: mj, schedule: schedule, stats: JobStats{Name: mj.Name}})
+	}
+	for _, j := range d.jobs {
+		d.scheduleJob(j)
+	}
+	return nil
+}
+
+func (d *Driver) scheduleJob(j *job) {
+	d.goBackground(func(done <-chan struct{}) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		next := j.schedule.Next(time.Now())
+		for !next.IsZero() {
+			j.mutex.Lock()
+			j.stats.NextRun = next
+			j.mutex.Unlock()
+
+			wait := time.Until(next)
+			if j.Jitter > 0 {
+				wait += rand.N(j.Jitter)
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := d.runJob(ctx, j); errors.Is(err, ErrJobRunning) {
+				j.mutex.Lock()
+				j.stats.Skipped++
+				j.mutex.Unlock()
+			}
+
+			// Skip the runs that came due while this one went on.
+			now := time.Now()
+			for next = j.schedule.Next(next); !next.IsZero() && !next.After(now); next = j.schedule.Next(next) {
+				j.mutex.Lock()
+				j.stats.Skipped++
+				j.mutex.Unlock()
+			}
+		}
+	})
+}
+
+// runJob runs j now unless it is already running.
+func (d *Driver) runJob(ctx context.Context, j *job) error {
+	if !j.running.TryLock() {
+		return ErrJobRunning
+	}
+	defer j.running.Unlock()
+
+	start := time.Now()
+	j.mutex.Lock()
+	j.stats.Running = true
+	j.mutex.Unlock()
+
+	err := j.Task(ctx, d)
+
+	duration := time.Since(start)
+	j.mutex.Lock()
+	j.stats.Running = false
+	j.stats.Runs++
+	j.stats.LastRun = start
+	j.stats.LastDuration = duration
+	j.stats.LastError = ""
+	if err != nil {
+		j.stats.Failures++
+		j.stats.LastError = err.Error()
+	} else {
+		j.stats.LastSuccess = start
+	}
+	j.mutex.Unlock()
+
+	if err != nil {
+		d.logEvent(slog.LevelError, "Maintenance job failed", slog.String("job", j.Name), slog.Duration("duration", duration), slog.Any("error", err))
+	} else {
+		d.logEvent(slog.LevelDebug, "Maintenance job finished", slog.String("job", j.Name), slog.Duration("duration", duration))
+	}
+	return err
+}
+
+// RunMaintenance runs the named job now, outside its schedule, failing
+// with ErrJobRunning if it is already running.
+func (d *Driver) RunMaintenance(ctx context.Context, name string) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	for _, j := range d.jobs {
+		if j.Name == name {
+			return d.runJob(ctx, j)
+		}
+	}
+	return fmt.Errorf("no maintenance job %s", name)
+}
+
+// MaintenanceStats returns the stats of every maintenance job, in the
+// order they were configured.
+func (d *Driver) MaintenanceStats() []JobStats {
+	stats := make([]JobStats, 0, len(d.jobs))
+	for _, j := range d.jobs {
+		j.mutex.Lock()
+		stats = append(stats, j.stats)
+		j.mutex.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	var scheduled atomic.Int32
+	release := make(chan struct{})
+	d, err := New(t.TempDir(), &Options{
+		Maintenance: []MaintenanceJob{
+			{Name: "tick", Schedule: "@every 5ms", Task: func(ctx context.Context, d *Driver) error {
+				scheduled.Add(1)
+				return nil
+			}},
+			{Name: "slow", Schedule: "@daily", Task: func(ctx context.Context, d *Driver) error {
+				<-release
+				return errors.New("failed")
+			}},
+			{Name: "compact", Schedule: "@daily", Task: CompactTask},
+		},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); scheduled.Load() < 2; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled job did not run")
+		}
+	}
+
+	if err := d.RunMaintenance(context.Background(), "compact"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RunMaintenance(context.Background(), "nothing"); err == nil {
+		t.Error("RunMaintenance of an unknown job succeeded")
+	}
+
+	done := make(chan error)
+	go func() { done <- d.RunMaintenance(context.Background(), "slow") }()
+	for !d.MaintenanceStats()[1].Running {
+		time.Sleep(time.Millisecond)
+	}
+	if err := d.RunMaintenance(context.Background(), "slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("RunMaintenance of a running job = %v, want ErrJobRunning", err)
+	}
+	close(release)
+	if err := <-done; err == nil || err.Error() != "failed" {
+		t.Errorf("RunMaintenance = %v, want the task's error", err)
+	}
+
+	stats := d.MaintenanceStats()
+	if len(stats) != 3 || stats[0].Name != "tick" || stats[1].Name != "slow" || stats[2].Name != "compact" {
+		t.Fatalf("MaintenanceStats = %+v", stats)
+	}
+	if st := stats[1]; st.Runs != 1 || st.Failures != 1 || st.LastError != "failed" || !st.LastSuccess.IsZero() || st.Running {
+		t.Errorf("stats of the failed job = %+v", st)
+	}
+	if st := stats[2]; st.Runs != 1 || st.Failures != 0 || st.LastSuccess.IsZero() || !st.NextRun.After(time.Now()) {
+		t.Errorf("stats of compact = %+v", st)
+	}
+}
+
+func TestMaintenanceJobs(t *testing.T) {
+	task := func(ctx context.Context, d *Driver) error { return nil }
+	for _, jobs := range [][]MaintenanceJob{
+		{{Name: "", Schedule: "@daily", Task: task}},
+		{{Name: " a", Schedule: "@daily", Task: task}},
+		{{Name: "a", Schedule: "@daily"}},
+		{{Name: "a", Schedule: "often", Task: task}},
+		{{Name: "a", Schedule: "@daily", Task: task}, {Name: "a", Schedule: "@hourly", Task: task}},
+	} {
+		d, err := New(t.TempDir(), &Options{Maintenance: jobs, TTLSweepInterval: -1})
+		if err == nil {
+			d.Close()
+			t.Errorf("New with jobs %+v succeeded", jobs)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// A collection can declare how it behaves in a manifest stored in its
+// directory, so every program opening the database treats it the same
+// without repeating Options.Collections. It starts with a dot, so it
+// cannot clash with a record.
+const manifestFile = ".collection.json"
+
+// ErrSchemaViolation is returned when writing a record that does not match
+// the schema of its collection.
+var ErrSchemaViolation = errors.New("record does not match the collection schema")
+
+func manifestPath(collection string) string {
+	return path.Join(collection, manifestFile)
+}
+
+// Manifest is what the manifest of a collection declares. Its settings
+// take the place of those Options.Collections gives the collection, and
+// its tenants' copies, one by one; those left out stay as they are. Times
+// are durations as time.ParseDuration reads them, such as "720h".
+type Manifest struct {
+	// Codec names the registered codec the records are encoded with, as
+	// LookupCodec does, such as msgpack for a collection of events and the
+	// database's JSON for the rest.
+	Codec string `json:"codec,omitempty"`
+	// Compression is the top-level one by default, and none turns it off.
+	Compression Compression `json:"compression,omitempty"`
+	// Schema is an expression, as Compile takes it, records written must
+	// match, as CollectionOptions.Schema.
+	Schema string `json:"schema,omitempty"`
+	// GeoIndex indexes the records by location, as GeoIndex does. Indexes
+	// are built when the Driver opens, so a change to them takes effect the
+	// next time it does.
+	GeoIndex *ManifestGeoIndex `json:"geo_index,omitempty"`
+	// TTL is how long records written without an expiry live, as
+	// CollectionOptions.TTL.
+	TTL       string             `json:"ttl,omitempty"`
+	Retention *ManifestRetention `json:"retention,omitempty"`
+	// IDs is uuidv7, ulid or sequence.
+	IDs        string `json:"ids,omitempty"`
+	AppendOnly bool   `json:"append_only,omitempty"`
+}
+
+// ManifestGeoIndex is the geo index a manifest declares.
+type ManifestGeoIndex struct {
+	Lat string `json:"lat,omitempty"`
+	Lng string `json:"lng,omitempty"`
+}
+
+// ManifestRetention is the Retention a manifest declares.
+type ManifestRetention struct {
+	MaxAge  string `json:"max_age,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Archive string `json:"archive,omitempty"`
+}
+
+// manifestOptions returns the options of collection with m applied to
+// those Options.Collections gives it. Callers hold d.mutex.
+func (d *Driver) manifestOptions(collection string, m Manifest) (CollectionOptions, error) {
+	o, ok := d.configured[collection]
+	if !ok {
+		o = CollectionOptions{Compression: d.compress}
+	}
+	if m.Codec != "" {
+		codec, err := LookupCodec(m.Codec)
+		if err != nil {
+			return o, fmt.Errorf("manifest of collection %s: %w", collection, err)
+		}
+		if err := validExtension(codec.Extension()); err != nil {
+			return o, fmt.Errorf("codec of collection %s: %w", collection, err)
+		}
+		o.Codec = codec
+	}
+	switch m.Compression {
+	case CompressionNone:
+	case "none":
+		o.Compression = CompressionNone
+	default:
+		if !m.Compression.valid() {
+			return o, fmt.Errorf("unknown compression %q for collection %s", m.Compression, collection)
+		}
+		o.Compression = m.Compression
+	}
+	if m.Schema != "" {
+		schema, err := Compile(m.Schema)
+		if err != nil {
+			return o, fmt.Errorf("schema of collection %s: %w", collection, err)
+		}
+		o.Schema = schema
+	}
+	if m.GeoIndex != nil {
+		for _, f := range []string{m.GeoIndex.Lat, m.GeoIndex.Lng} {
+			if f == "" {
+				continue
+			}
+			if err := validField(f); err != nil {
+				return o, fmt.Errorf("geo index on %s: %w", collection, err)
+			}
+		}
+	}
+	if m.TTL != "" {
+		ttl, err := time.ParseDuration(m.TTL)
+		if err != nil || ttl <= 0 {
+			return o, fmt.Errorf("invalid ttl %q for collection %s - must be a positive duration", m.TTL, collection)
+		}
+		o.TTL = ttl
+	}
+	if r := m.Retention; r != nil {
+		retention := Retention{Field: r.Field, Archive: r.Archive}
+		if r.MaxAge != "" {
+			maxAge, err := time.ParseDuration(r.MaxAge)
+			if err != nil {
+				return o, fmt.Errorf("invalid retention %q for collection %s", r.MaxAge, collection)
+			}
+			retention.MaxAge = maxAge
+		}
+		if err := retention.validate(collection, d.collections); err != nil {
+			return o, err
+		}
+		o.Retention = retention
+	}
+	if m.IDs != "" {
+		ids, err := parseIDScheme(m.IDs, collection)
+		if err != nil {
+			return o, err
+		}
+		o.IDs = ids
+	}
+	if m.AppendOnly {
+		o.AppendOnly = true
+	}
+	return o, nil
+}
+
+// loadManifests reads the manifests of the top-level collections as the
+// Driver opens.
+func (d *Driver) loadManifests() error {
+	collections, err := d.listDirs("")
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		m, err := d.readManifest(collection)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		o, err := d.manifestOptions(collection, m)
+		if err == nil {
+			d.collections[collection] = o
+			d.manifests[collection] = m
+		}
+		d.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) readManifest(collection string) (Manifest, error) {
+	var m Manifest
+	b, err := d.backend.Get(manifestPath(collection))
+	if err != nil {
+		return m, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return m, fmt.Errorf("manifest of collection %s: %w", collection, err)
+	}
+	return m, nil
+}
+
+// manifestGeoIndexes returns the geo indexes the manifests declare.
+func (d *Driver) manifestGeoIndexes() []GeoIndex {
+	var indexes []GeoIndex
+	for collection, m := range d.manifests {
+		if m.GeoIndex != nil {
+			indexes = append(indexes, GeoIndex{Collection: collection, Lat: m.GeoIndex.Lat, Lng: m.GeoIndex.Lng})
+		}
+	}
+	return indexes
+}
+
+// Manifest returns the manifest of a top-level collection, failing with an
+// error matching fs.ErrNotExist if it has none.
+func (d *Driver) Manifest(collection string) (Manifest, error) {
+	return d.ManifestContext(context.Background(), collection)
+}
+
+// ManifestContext is Manifest with a context carrying the caller.
+func (d *Driver) ManifestContext(ctx context.Context, collection string) (Manifest, error) {
+	if err := d.checkOpen(); err != nil {
+		return Manifest{}, err
+	}
+	if err := validName("collection", collection); err != nil {
+		return Manifest{}, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return Manifest{}, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	m, ok := d.manifests[collection]
+	if !ok {
+		return Manifest{}, fmt.Errorf("unable to find manifest of collection %s: %w", collection, fs.ErrNotExist)
+	}
+	return m, nil
+}
+
+// SetManifest stores the manifest of a top-level collection, creating the
+// collection if needed, and applies it to every further operation on it.
+// Records already written are left as they are: MigrateCompression
+// rewrites them with a new compression, and ApplyRetention removes those
+// a new retention no longer keeps. A new codec giving records another
+// extension is refused while the collection holds records, which it would
+// no longer find. Changing how a collection behaves needs every permission
+// on it.
+func (d *Driver) SetManifest(collection string, m Manifest) error {
+	return d.SetManifestContext(context.Background(), collection, m)
+}
+
+// SetManifestContext is SetManifest with a context carrying the caller.
+func (d *Driver) SetManifestContext(ctx context.Context, collection string, m Manifest) (err error) {
+	op := d.startOp(ctx, "manifest", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := validName("collection", collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermAll); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	d.mutex.Lock()
+	o, err := d.manifestOptions(collection, m)
+	d.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := d.checkCodecChange(collection, o.Codec); err != nil {
+		return err
+	}
+	if err := d.storeManifest(collection, m); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	d.collections[collection] = o
+	d.manifests[collection] = m
+	d.mutex.Unlock()
+	return nil
+}
+
+// checkCodecChange fails if the records of collection would get another
+// extension with codec, or the database codec if nil, while it holds
+// records. Callers hold the collection lock.
+func (d *Driver) checkCodecChange(collection string, codec Codec) error {
+	ext := d.ext
+	if codec != nil {
+		ext = codec.Extension()
+	}
+	if ext == d.extFor(collection) {
+		return nil
+	}
+	keys, err := d.liveKeys(collection)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("collection %s holds %d records encoded with another codec - move them to a new collection", collection, len(keys))
+	}
+	return nil
+}
+
+// storeManifest writes the manifest of a collection. Callers hold the
+// collection lock.
+func (d *Driver) storeManifest(collection string, m Manifest) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Schemas compare with < and >, which people edit the file to change.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return d.replaceFile(manifestPath(collection), buf.Bytes())
+}
+
+// forgetManifest goes back to Options.Collections for a collection whose
+// manifest was deleted with it.
+func (d *Driver) forgetManifest(collection string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.manifests[collection]; !ok {
+		return
+	}
+	delete(d.manifests, collection)
+	if o, ok := d.configured[collection]; ok {
+		d.collections[collection] = o
+	} else {
+		delete(d.collections, collection)
+	}
+}
+
+// checkSchema fails with ErrSchemaViolation if the encoded record b does
+// not match schema, if set.
+func (d *Driver) checkSchema(collection, resource string, schema *Expr, b []byte) error {
+	if schema == nil {
+		return nil
+	}
+	doc, err := d.decodeDocument(collection, b)
+	if err != nil {
+		return err
+	}
+	if !schema.Match(doc) {
+		return fmt.Errorf("%w: %s/%s fails %s", ErrSchemaViolation, collection, resource, schema)
+	}
+	return nil
+}
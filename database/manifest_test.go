@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Manifest{
+		Compression: CompressionGzip,
+		Schema:      "doc.n > 0",
+		TTL:         "1h",
+		IDs:         "sequence",
+		GeoIndex:    &ManifestGeoIndex{},
+		Retention:   &ManifestRetention{MaxAge: "720h"},
+	}
+	if err := d.SetManifest("places", m); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("places", "a", map[string]int{"n": 0}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Write against the schema = %v, want ErrSchemaViolation", err)
+	}
+	if err := d.Write("places", "a", map[string]interface{}{"n": 1, "lat": 24.86, "lng": 67.01}); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := d.Insert("places", map[string]int{"n": 2}); err != nil || key != "00000000000000000001" {
+		t.Errorf("Insert = %q, %v; want the first of a sequence", key, err)
+	}
+	if at, err := d.ExpiresAt("places", "a"); err != nil || time.Until(at) <= 59*time.Minute {
+		t.Errorf("ExpiresAt = %v, %v; want in an hour by the manifest's TTL", at, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "places", "a.json.gz")); err != nil {
+		t.Errorf("record not compressed as the manifest declares: %v", err)
+	}
+	if keys, err := d.Keys("places"); err != nil || len(keys) != 2 {
+		t.Errorf("Keys = %v, %v; want the 2 records and not the manifest", keys, err)
+	}
+	d.Close()
+
+	// Another Driver, without the options, treats the collection the same.
+	d, err = New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got, err := d.Manifest("places"); err != nil || got.Schema != m.Schema || got.IDs != "sequence" {
+		t.Errorf("Manifest after reopening = %+v, %v; want %+v", got, err, m)
+	}
+	if err := d.Write("places", "b", map[string]int{"n": -1}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Write against the schema after reopening = %v, want ErrSchemaViolation", err)
+	}
+	if near, err := d.FindNear("places", 24.86, 67.01, 1000); err != nil || len(near) != 1 {
+		t.Errorf("FindNear = %v, %v; want a from the manifest's geo index", near, err)
+	}
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v; want no issues", report.Issues, err)
+	}
+
+	if err := d.MigrateCompression("places", CompressionNone); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := d.Manifest("places"); got.Compression != "none" {
+		t.Errorf("manifest compression after migrating = %q, want none", got.Compression)
+	}
+
+	if _, err := d.DropCollection(context.Background(), "places", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Manifest("places"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Manifest of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+	if err := d.Write("places", "b", map[string]int{"n": -1}); err != nil {
+		t.Errorf("Write after the manifest was dropped = %v", err)
+	}
+}
+
+func TestSetManifestErrors(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "ada", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gob := testName("codec")
+	RegisterCodec(gob, gobCodec{})
+	for _, m := range []Manifest{
+		{Codec: "nope"},
+		{Compression: "lz4"},
+		{Schema: "doc.n >"},
+		{TTL: "-1h"},
+		{IDs: "random"},
+		{GeoIndex: &ManifestGeoIndex{Lat: "bad..field"}},
+		// The records would no longer be found.
+		{Codec: gob},
+	} {
+		if err := d.SetManifest("users", m); err == nil {
+			t.Errorf("SetManifest(%+v) succeeded", m)
+		}
+	}
+	if _, err := d.Manifest("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Manifest after refused ones = %v, want fs.ErrNotExist", err)
+	}
+	if err := d.SetManifest("users/ada/posts", Manifest{TTL: "1h"}); err == nil {
+		t.Error("SetManifest of a sub-collection succeeded")
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.SetManifest("users", Manifest{TTL: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "users", manifestFile), []byte(`{"ttl": "soon"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := d.Verify()
+	if err != nil || len(report.Issues) != 1 || !strings.Contains(report.Issues[0].Problem, "soon") {
+		t.Errorf("Verify of a broken manifest = %+v, %v; want it reported", report.Issues, err)
+	}
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// MapFunc is called once for every record of a map-reduce job and emits
+// any number of values under output keys. JSON numbers are json.Number.
+type MapFunc func(key string, doc map[string]interface{}, emit func(key string, value interface{}))
+
+// ReduceFunc combines the values emitted under an output key, in the order
+// of the records that emitted them, into the record written under it.
+type ReduceFunc func(key string, values []interface{}) (interface{}, error)
+
+// MapReduce runs a map-reduce job over a collection and replaces the
+// records of out with its results. Records are mapped, and keys reduced, by
+// a pool of Options.MapReduceWorkers goroutines, so the functions must be
+// safe to call concurrently. Every emitted key is reduced, even one with a
+// single value. Nothing is written unless every call succeeds.
+func (d *Driver) MapReduce(collection string, mapFn MapFunc, reduceFn ReduceFunc, out string) error {
+	return d.MapReduceContext(context.Background(), collection, mapFn, reduceFn, out)
+}
+
+// MapReduceContext is MapReduce with a context to trace the operations in
+// and to cancel the job with.
+func (d *Driver) MapReduceContext(ctx context.Context, collection string, mapFn MapFunc, reduceFn ReduceFunc, out string) (err error) {
+	op := d.startOp(ctx, "mapreduce", collection, "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" || out == "" {
+		return fmt.Errorf("missing collection - no place to map from or reduce to")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := validCollection(out); err != nil {
+		return err
+	}
+	if out == collection {
+		return fmt.Errorf("map-reduce output %s must not be its input", out)
+	}
+	if err := d.checkView(out); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, out, PermWrite); err != nil {
+		return err
+	}
+
+	keys, err := d.liveKeys(collection)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	type emitted struct {
+		key   string
+		value interface{}
+	}
+	mapped := make([][]emitted, len(keys))
+	err = d.parallel(ctx, len(keys), func(i int) error {
+		b, err := d.readRecord(collection, keys[i])
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		op.addBytes(int64(len(b)))
+		doc, err := d.decodeDocument(collection, b)
+		if err != nil {
+			return err
+		}
+		mapFn(keys[i], doc, func(key string, value interface{}) {
+			mapped[i] = append(mapped[i], emitted{key, value})
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string][]interface{})
+	for _, records := range mapped {
+		for _, e := range records {
+			values[e.key] = append(values[e.key], e.value)
+		}
+	}
+	outKeys := make([]string, 0, len(values))
+	for key := range values {
+		if err := validName("key", key); err != nil {
+			return fmt.Errorf("map-reduce emitted %w", err)
+		}
+		outKeys = append(outKeys, key)
+	}
+	sort.Strings(outKeys)
+
+	reduced := make([]interface{}, len(outKeys))
+	err = d.parallel(ctx, len(outKeys), func(i int) error {
+		v, err := reduceFn(outKeys[i], values[outKeys[i]])
+		if err != nil {
+			return fmt.Errorf("reducing %s: %w", outKeys[i], err)
+		}
+		reduced[i] = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.DropCollection(ctx, out, false); err != nil && !isNotExist(err) {
+		return err
+	}
+	for i, key := range outKeys {
+		if err := d.WriteContext(ctx, out, key, reduced[i]); err != nil {
+			return err
+		}
+	}
+	d.logEvent(slog.LevelInfo, "Map-reduce finished", slog.String("collection", collection), slog.String("out", out),
+		slog.Int("records", len(keys)), slog.Int("results", len(outKeys)))
+	return nil
+}
+
+// parallel calls fn for every index below n on at most d.workers
+// goroutines, stopping at the first error or when ctx is done.
+func (d *Driver) parallel(ctx context.Context, n int, fn func(i int) error) error {
+	return d.parallelN(ctx, d.workers, n, fn)
+}
+
+// parallelN is parallel on at most workers goroutines.
+func (d *Driver) parallelN(ctx context.Context, workers, n int, fn func(i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					errMutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errMutex.Unlock()
+					cancel()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMapReduce(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{MapReduceWorkers: 3, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for i, city := range []string{"Karachi", "Lahore", "Karachi", "Quetta", "Karachi"} {
+		if err := d.Write("users", string(rune('a'+i)), map[string]interface{}{"city": city}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Write("counts", "stale", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var maps atomic.Int32
+	byCity := func(key string, doc map[string]interface{}, emit func(string, interface{})) {
+		maps.Add(1)
+		emit(doc["city"].(string), key)
+	}
+	count := func(key string, values []interface{}) (interface{}, error) {
+		return map[string]interface{}{"n": len(values), "keys": values}, nil
+	}
+	if err := d.MapReduce("users", byCity, count, "counts"); err != nil {
+		t.Fatal(err)
+	}
+	if maps.Load() != 5 {
+		t.Errorf("mapped %d records, want 5", maps.Load())
+	}
+	if got := joinedKeys(t, d, "counts"); got != "Karachi,Lahore,Quetta" {
+		t.Errorf("output keys = %s, want the cities without the stale record", got)
+	}
+	var v struct {
+		N    int      `json:"n"`
+		Keys []string `json:"keys"`
+	}
+	// Values are reduced in record order.
+	if err := d.Read("counts", "Karachi", &v); err != nil || v.N != 3 || strings.Join(v.Keys, ",") != "a,c,e" {
+		t.Errorf("Karachi = %+v, %v", v, err)
+	}
+
+	fail := func(key string, values []interface{}) (interface{}, error) { return nil, errors.New("boom") }
+	if err := d.MapReduce("users", byCity, fail, "counts"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("MapReduce with a failing reduce = %v", err)
+	}
+	if got := joinedKeys(t, d, "counts"); got != "Karachi,Lahore,Quetta" {
+		t.Errorf("output after a failed job = %s, want it unchanged", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.MapReduceContext(ctx, "users", byCity, count, "counts"); !errors.Is(err, context.Canceled) {
+		t.Errorf("MapReduce with a canceled context = %v", err)
+	}
+	if err := d.MapReduce("users", byCity, count, "users"); err == nil {
+		t.Error("MapReduce into its input succeeded")
+	}
+	if err := d.MapReduce("", byCity, count, "out"); err == nil {
+		t.Error("MapReduce without a collection succeeded")
+	}
+	if err := d.MapReduce("empty", byCity, count, "out"); err != nil {
+		t.Errorf("MapReduce of a missing collection = %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// maxMergeRenames bounds how many times a record is renamed looking for a
+// free key.
+const maxMergeRenames = 100
+
+// MergeStrategy decides what a merge does with a record both databases
+// hold under the same key with different values: it returns the key to
+// write the other database's record to and the value to write, or an empty
+// key to keep this database's record. A key other than the one given is
+// checked for conflicts in turn. Values are compact JSON.
+type MergeStrategy func(collection, key string, ours, theirs json.RawMessage) (string, json.RawMessage, error)
+
+var (
+	// MergeSkip keeps the records of this database.
+	MergeSkip MergeStrategy = func(collection, key string, ours, theirs json.RawMessage) (string, json.RawMessage, error) {
+		return "", nil, nil
+	}
+	// MergeOverwrite replaces them with the records of the other.
+	MergeOverwrite MergeStrategy = func(collection, key string, ours, theirs json.RawMessage) (string, json.RawMessage, error) {
+		return key, theirs, nil
+	}
+)
+
+// MergeRename keeps both records, writing the other database's under its
+// key with suffix appended, again for as long as that is taken too.
+func MergeRename(suffix string) MergeStrategy {
+	return func(collection, key string, ours, theirs json.RawMessage) (string, json.RawMessage, error) {
+		return key + suffix, theirs, nil
+	}
+}
+
+// MergeResolve replaces a record with what resolve makes of it and the
+// other database's, or keeps it if resolve returns nil.
+func MergeResolve(resolve func(collection, key string, ours, theirs json.RawMessage) (json.RawMessage, error)) MergeStrategy {
+	return func(collection, key string, ours, theirs json.RawMessage) (string, json.RawMessage, error) {
+		v, err := resolve(collection, key, ours, theirs)
+		if err != nil || v == nil {
+			return "", nil, err
+		}
+		return key, v, nil
+	}
+}
+
+// MergeReport counts what a merge did with the records of the other
+// database.
+type MergeReport struct {
+	// Added were written to keys free in this database, Identical held
+	// already.
+	Added     int
+	Identical int
+	// Skipped, Replaced and Renamed conflicted, and were dropped, written
+	// over this database's records, or written under other keys.
+	Skipped  int
+	Replaced int
+	Renamed  int
+}
+
+// MergeFrom folds the database in otherDir into this one, opening it read
+// only with the codec and field key of this one. Merge a database opened
+// with other options, such as a master key, with Merge.
+func (d *Driver) MergeFrom(otherDir string, strategy MergeStrategy) (*MergeReport, error) {
+	if filepath.Clean(otherDir) == d.dir {
+		return nil, errors.New("cannot merge a database into itself")
+	}
+	other, err := New(otherDir, &Options{
+		Logger:           d.log,
+		ReadOnly:         true,
+		Codec:            d.codec,
+		Extension:        d.ext,
+		FieldKey:         d.fieldKey,
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer other.Close()
+	return d.Merge(other, strategy)
+}
+
+// Merge writes every record of other, in every collection including
+// namespaced and sub-collections, into this database with its expiry and
+// attachments, leaving records it holds already alone and deciding those
+// that conflict by strategy. Like Export it needs the JSON codec, and
+// merging the same database twice writes nothing new.
+func (d *Driver) Merge(other *Driver, strategy MergeStrategy) (*MergeReport, error) {
+	return d.MergeContext(context.Background(), other, strategy)
+}
+
+// MergeContext is Merge with a context to trace the operation in.
+func (d *Driver) MergeContext(ctx context.Context, other *Driver, strategy MergeStrategy) (report *MergeReport, err error) {
+	op := d.startOp(ctx, "merge", "", "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := other.checkOpen(); err != nil {
+		return nil, err
+	}
+	collections, err := other.collectionPaths()
+	if err != nil {
+		return nil, err
+	}
+	report = &MergeReport{}
+	for _, collection := range collections {
+		keys, err := other.Keys(collection)
+		if err != nil {
+			return report, err
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := d.mergeRecord(ctx, other, collection, key, strategy, report); err != nil {
+				return report, fmt.Errorf("merging %s/%s: %w", collection, key, err)
+			}
+		}
+	}
+	d.logEvent(slog.LevelInfo, "Merged database", slog.String("from", other.dir),
+		slog.Int("added", report.Added), slog.Int("replaced", report.Replaced), slog.Int("renamed", report.Renamed))
+	return report, nil
+}
+
+func (d *Driver) mergeRecord(ctx context.Context, other *Driver, collection, key string, strategy MergeStrategy, report *MergeReport) error {
+	theirs, err := readCompact(ctx, other, collection, key)
+	if isNotExist(err) {
+		// Expired since it was listed.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target, value := key, theirs
+	for renames := 0; ; {
+		ours, err := readCompact(ctx, d, collection, target)
+		if isNotExist(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(ours, value) {
+			report.Identical++
+			return nil
+		}
+		next, resolved, err := strategy(collection, target, ours, value)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			report.Skipped++
+			return nil
+		}
+		if next == target {
+			if err := d.mergeWrite(ctx, other, collection, key, target, resolved); err != nil {
+				return err
+			}
+			report.Replaced++
+			return nil
+		}
+		if renames++; renames > maxMergeRenames {
+			return fmt.Errorf("no free key after %d renames", maxMergeRenames)
+		}
+		target, value = next, resolved
+	}
+
+	if err := d.mergeWrite(ctx, other, collection, key, target, value); err != nil {
+		return err
+	}
+	if target == key {
+		report.Added++
+	} else {
+		report.Renamed++
+	}
+	return nil
+}
+
+// mergeWrite writes value to target, with the expiry and attachments of the
+// other database's record at key.
+func (d *Driver) mergeWrite(ctx context.Context, other *Driver, collection, key, target string, value json.RawMessage) error {
+	if err := d.WriteContext(ctx, collection, target, value); err != nil {
+		return err
+	}
+	expires, err := other.ExpiresAt(collection, key)
+	if err != nil {
+		return err
+	}
+	if !expires.IsZero() {
+		// Past expiry now, it is still swept here.
+		if err := d.Touch(collection, target, max(time.Until(expires), time.Nanosecond)); err != nil {
+			return err
+		}
+	}
+	names, err := other.Attachments(collection, key)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := copyAttachment(other, d, collection, key, target, name); err != nil {
+			return fmt.Errorf("copying attachment %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func copyAttachment(from, to *Driver, collection, key, target, name string) error {
+	rc, err := from.GetAttachment(collection, key, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return to.PutAttachment(collection, target, name, rc)
+}
+
+// readCompact reads a record of d as compact JSON, or returns an error
+// matching fs.ErrNotExist, which Read does not.
+func readCompact(ctx context.Context, d *Driver, collection, key string) (json.RawMessage, error) {
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+	b, err := d.readRecord(collection, key)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := d.codecFor(collection).Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, err
+	}
+	return compact.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openMerged opens the databases "ours" and "theirs" in root, each holding
+// users "same", alike in both, and "diff", not. Theirs also holds users
+// "new" with an expiry and its orders, user "diff" has an attachment, and
+// namespace t1 holds a record. Theirs is closed, for MergeFrom to open.
+func openMerged(t *testing.T, root string) *Driver {
+	t.Helper()
+	open := func(name string) *Driver {
+		d, err := New(filepath.Join(root, name), &Options{TTLSweepInterval: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	write := func(d *Driver, collection, key string, v interface{}) {
+		t.Helper()
+		if err := d.Write(collection, key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ours, theirs := open("ours"), open("theirs")
+	defer theirs.Close()
+	write(ours, "users", "same", map[string]int{"n": 1})
+	write(theirs, "users", "same", map[string]int{"n": 1})
+	write(ours, "users", "diff", map[string]int{"n": 1})
+	write(theirs, "users", "diff", map[string]int{"n": 2})
+	write(theirs, "users", "new", map[string]int{"n": 3})
+	write(theirs, "users/new/orders", "o1", map[string]int{"n": 4})
+	if err := theirs.Namespace("t1").Write("notes", "k", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := theirs.Touch("users", "new", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := theirs.PutAttachment("users", "diff", "f.txt", strings.NewReader("hi")); err != nil {
+		t.Fatal(err)
+	}
+	return ours
+}
+
+func TestMergeFrom(t *testing.T) {
+	root := t.TempDir()
+	d := openMerged(t, root)
+	defer d.Close()
+	theirs := filepath.Join(root, "theirs")
+
+	r, err := d.MergeFrom(theirs, MergeRename("-theirs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *r != (MergeReport{Added: 3, Identical: 1, Renamed: 1}) {
+		t.Errorf("MergeFrom = %+v, want 3 added, 1 identical and 1 renamed", *r)
+	}
+	var v map[string]int
+	if err := d.Read("users", "diff", &v); err != nil || v["n"] != 1 {
+		t.Errorf("our conflicting record = %v, %v; want it kept", v, err)
+	}
+	if err := d.Read("users", "diff-theirs", &v); err != nil || v["n"] != 2 {
+		t.Errorf("their renamed record = %v, %v", v, err)
+	}
+	if names, err := d.Attachments("users", "diff-theirs"); err != nil || len(names) != 1 {
+		t.Errorf("attachments of the renamed record = %v, %v", names, err)
+	}
+	if expires, err := d.ExpiresAt("users", "new"); err != nil || expires.IsZero() {
+		t.Errorf("expiry of a merged record = %v, %v", expires, err)
+	}
+	if err := d.Read("users/new/orders", "o1", &v); err != nil || v["n"] != 4 {
+		t.Errorf("merged sub-collection record = %v, %v", v, err)
+	}
+	var n int
+	if err := d.Namespace("t1").Read("notes", "k", &n); err != nil || n != 5 {
+		t.Errorf("merged namespaced record = %d, %v", n, err)
+	}
+
+	// Merging again writes nothing new.
+	if r, err := d.MergeFrom(theirs, MergeRename("-theirs")); err != nil || *r != (MergeReport{Identical: 5}) {
+		t.Errorf("second MergeFrom = %+v, %v; want all 5 identical", r, err)
+	}
+	if _, err := d.MergeFrom(filepath.Join(root, "ours"), MergeSkip); err == nil {
+		t.Error("merged a database into itself")
+	}
+}
+
+func TestMergeStrategies(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		strategy MergeStrategy
+		want     MergeReport
+		n        int
+	}{
+		{"skip", MergeSkip, MergeReport{Added: 3, Identical: 1, Skipped: 1}, 1},
+		{"overwrite", MergeOverwrite, MergeReport{Added: 3, Identical: 1, Replaced: 1}, 2},
+		{"resolve", MergeResolve(func(collection, key string, ours, theirs json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(`{"n":99}`), nil
+		}), MergeReport{Added: 3, Identical: 1, Replaced: 1}, 99},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			d := openMerged(t, root)
+			defer d.Close()
+
+			r, err := d.MergeFrom(filepath.Join(root, "theirs"), c.strategy)
+			if err != nil || *r != c.want {
+				t.Errorf("MergeFrom = %+v, %v; want %+v", r, err, c.want)
+			}
+			var v map[string]int
+			if err := d.Read("users", "diff", &v); err != nil || v["n"] != c.n {
+				t.Errorf("conflicting record = %v, %v; want n %d", v, err, c.n)
+			}
+		})
+	}
+}
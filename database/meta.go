@@ -0,0 +1,216 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// With Options.RecordMetadata, when each record was created and last
+// written, and by whom, live in a sidecar per record under
+// <collection>/.meta, next to the expiry sidecars.
+const metaDir = ".meta"
+
+func metaPath(p string) string {
+	return path.Join(path.Dir(p), metaDir, path.Base(p)+".meta")
+}
+
+// RecordMeta is what the database knows of a record apart from its
+// contents.
+type RecordMeta struct {
+	// Created is when the record was first written and Updated when it
+	// was last, both zero for records written without
+	// Options.RecordMetadata.
+	Created time.Time `json:"created_at,omitzero"`
+	Updated time.Time `json:"updated_at,omitzero"`
+	// Revision is the revision of the record, as ReadRevision returns it.
+	Revision string `json:"revision"`
+	// Version counts the writes of the record since it has had metadata.
+	Version int `json:"version"`
+	// Writer is the name of the principal the record was last written as,
+	// empty if none.
+	Writer string `json:"writer,omitempty"`
+}
+
+// storedMeta is the sidecar of a record.
+type storedMeta struct {
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Version int       `json:"version"`
+	Writer  string    `json:"writer,omitempty"`
+}
+
+func (d *Driver) readMeta(p string) (storedMeta, error) {
+	var m storedMeta
+	b, err := d.backend.Get(metaPath(p))
+	if isNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	return m, json.Unmarshal(b, &m)
+}
+
+// touchMeta records a write of the record at p by writer, keeping when it
+// was created. Callers hold the collection lock.
+func (d *Driver) touchMeta(p, writer string) error {
+	m, err := d.readMeta(p)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if m.Created.IsZero() {
+		m.Created = now
+	}
+	m.Updated, m.Writer = now, writer
+	m.Version++
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return d.replaceFile(metaPath(p), append(b, '\n'))
+}
+
+// ReadMeta returns the metadata of a record, failing with an error
+// matching fs.ErrNotExist if it is missing.
+func (d *Driver) ReadMeta(collection, resource string) (RecordMeta, error) {
+	return d.ReadMetaContext(context.Background(), collection, resource)
+}
+
+// ReadMetaContext is ReadMeta with a context to trace the operation in.
+func (d *Driver) ReadMetaContext(ctx context.Context, collection, resource string) (meta RecordMeta, err error) {
+	op := d.startOp(ctx, "readmeta", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return RecordMeta{}, err
+	}
+	if collection == "" {
+		return RecordMeta{}, fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return RecordMeta{}, fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return RecordMeta{}, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return RecordMeta{}, err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	b, err := d.readRecord(collection, resource)
+	if isNotExist(err) {
+		return RecordMeta{}, fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+	}
+	if err != nil {
+		return RecordMeta{}, err
+	}
+	m, err := d.readMeta(d.recordPath(collection, resource))
+	if err != nil {
+		return RecordMeta{}, err
+	}
+	return RecordMeta{Created: m.Created, Updated: m.Updated, Revision: revision(b), Version: m.Version, Writer: m.Writer}, nil
+}
+
+// Queries, and aggregation pipelines, naming metaField in their fields or
+// filters see the metadata of each record as an object in that field of
+// its document, hiding any field of that name the document has:
+//
+//	doc._meta.updated_at < '2026-01-01' && doc._meta.version > 10
+//
+// It holds created_at and updated_at, in UTC with nanoseconds as in
+// 2026-01-02T15:04:05.000000000Z so they compare as strings, and writer,
+// version and revision, as ReadMeta returns them. The fields a record has
+// no metadata for are missing.
+const metaField = "_meta"
+
+// metaTimeFormat is RFC 3339 with a fixed width, so times compare in order
+// as strings.
+const metaTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func isMetaField(field string) bool {
+	return field == metaField || strings.HasPrefix(field, metaField+".")
+}
+
+// usesMeta reports whether q refers to the metadata of the records.
+func (q Query) usesMeta() bool {
+	for field := range q.Where {
+		if isMetaField(field) {
+			return true
+		}
+	}
+	for _, field := range q.Fields {
+		if isMetaField(field) {
+			return true
+		}
+	}
+	return strings.Contains(q.Filter, metaField)
+}
+
+// pipelineUsesMeta reports whether any stage of a pipeline refers to the
+// metadata of the records.
+func pipelineUsesMeta(pipeline []Stage) bool {
+	b, err := json.Marshal(pipeline)
+	return err == nil && bytes.Contains(b, []byte(metaField))
+}
+
+// addMeta sets the metaField of the document of a record, whose decoded
+// contents are b, creating the document if it is not an object.
+func (d *Driver) addMeta(doc map[string]interface{}, collection, key string, b []byte) (map[string]interface{}, error) {
+	m, err := d.readMeta(d.recordPath(collection, key))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"revision": revision(b),
+		"version":  float64(m.Version),
+	}
+	if !m.Created.IsZero() {
+		fields["created_at"] = m.Created.UTC().Format(metaTimeFormat)
+		fields["updated_at"] = m.Updated.UTC().Format(metaTimeFormat)
+	}
+	if m.Writer != "" {
+		fields["writer"] = m.Writer
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	doc[metaField] = fields
+	return doc, nil
+}
+
+// RecentlyUpdated returns the keys of a collection, the most recently
+// written first, and at most limit of them if limit is positive. Records
+// written without Options.RecordMetadata come last, in key order.
+func (d *Driver) RecentlyUpdated(collection string, limit int) ([]string, error) {
+	keys, err := d.Keys(collection)
+	if err != nil {
+		return nil, err
+	}
+	updated := make(map[string]time.Time, len(keys))
+	for _, key := range keys {
+		m, err := d.readMeta(d.recordPath(collection, key))
+		if err != nil {
+			return nil, err
+		}
+		updated[key] = m.Updated
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return updated[keys[i]].After(updated[keys[j]])
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordMetadata(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{RecordMetadata: true, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	ctx := WithPrincipal(context.Background(), &Principal{Name: "alice", Role: &Role{Name: "admin", Collections: map[string]Permission{"*": PermAll}}})
+	if err := d.WriteContext(ctx, "users", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := d.ReadMeta("users", "a")
+	if err != nil || first.Created.IsZero() || !first.Updated.Equal(first.Created) || first.Writer != "alice" || first.Version != 1 {
+		t.Fatalf("ReadMeta after a write = %+v, %v; want a creation by alice", first, err)
+	}
+	var v map[string]int
+	rev, err := d.ReadRevision("users", "a", &v)
+	if err != nil || first.Revision != rev {
+		t.Errorf("Revision = %q, want %q of ReadRevision (%v)", first.Revision, rev, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := d.Write("users", "a", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.ReadMeta("users", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Created.Equal(first.Created) || !second.Updated.After(first.Updated) {
+		t.Errorf("times after a second write = %v, %v; want %v and later than %v", second.Created, second.Updated, first.Created, first.Updated)
+	}
+	if second.Writer != "" || second.Version != 2 || second.Revision == first.Revision {
+		t.Errorf("ReadMeta after a write without a principal = %+v", second)
+	}
+
+	if keys, err := d.Keys("users"); err != nil || !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("Keys = %v, %v; want only the record", keys, err)
+	}
+	if err := d.Delete("users", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadMeta("users", "a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadMeta of a deleted record = %v, want fs.ErrNotExist", err)
+	}
+	report, err := d.Verify()
+	if err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v; want no issues", report.Issues, err)
+	}
+}
+
+func TestReadMetaWithoutMetadata(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("users", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.ReadMeta("users", "a")
+	if err != nil || !m.Created.IsZero() || !m.Updated.IsZero() || m.Version != 0 || m.Revision == "" {
+		t.Errorf("ReadMeta = %+v, %v; want only a revision", m, err)
+	}
+	if _, err := d.ReadMeta("users", "none"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadMeta of a missing record = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestRecentlyUpdated(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "old", 0); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err = New(dir, &Options{RecordMetadata: true, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"a", "b", "c", "a"} {
+		time.Sleep(5 * time.Millisecond)
+		if err := d.Write("users", k, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := d.RecentlyUpdated("users", 0)
+	if want := []string{"a", "c", "b", "old"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("RecentlyUpdated = %v, %v; want %v", keys, err, want)
+	}
+	keys, err = d.RecentlyUpdated("users", 2)
+	if want := []string{"a", "c"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("RecentlyUpdated of limit 2 = %v, %v; want %v", keys, err, want)
+	}
+}
+
+func TestQueryMetadata(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{RecordMetadata: true, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Write("users", "old", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	mark := time.Now().UTC().Format(metaTimeFormat)
+	for i := 0; i < 5; i++ {
+		if err := d.Write("users", "busy", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := d.Find(Query{Collection: "users", Filter: "doc._meta.updated_at < '" + mark + "'"})
+	if err != nil || len(results) != 1 || results[0].Key != "old" {
+		t.Fatalf("Find by updated_at = %v, %v; want old", results, err)
+	}
+	if _, ok := results[0].Value[metaField]; ok {
+		t.Errorf("Find added the metadata to a document not asking for it: %v", results[0].Value)
+	}
+	results, err = d.Find(Query{Collection: "users", Filter: "doc._meta.version > 3", Fields: []string{"n", "_meta.version"}})
+	if err != nil || len(results) != 1 || results[0].Key != "busy" {
+		t.Fatalf("Find by version = %v, %v; want busy", results, err)
+	}
+	if meta, _ := results[0].Value[metaField].(map[string]interface{}); meta["version"] != float64(5) {
+		t.Errorf("projected metadata = %v, want version 5", results[0].Value)
+	}
+	results, err = d.Find(Query{Collection: "users", Where: map[string][]string{"_meta.version": {"1"}}})
+	if err != nil || len(results) != 1 || results[0].Key != "old" {
+		t.Errorf("Find where version is 1 = %v, %v; want old", results, err)
+	}
+	page, _, err := d.FindPage(Query{Collection: "users", Filter: "doc._meta.version == 5"}, Cursor{})
+	if err != nil || len(page) != 1 || page[0].Key != "busy" {
+		t.Errorf("FindPage by version = %v, %v; want busy", page, err)
+	}
+
+	docs, err := d.Aggregate("users", []Stage{{Sort: []string{"-_meta.updated_at"}}, {Project: []string{"_key"}}})
+	if err != nil || len(docs) != 2 || docs[0]["_key"] != "busy" || docs[1]["_key"] != "old" {
+		t.Errorf("Aggregate sorted by updated_at = %v, %v; want busy then old", docs, err)
+	}
+}
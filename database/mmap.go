@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+)
+
+// MapBackend is implemented by backends that can map a stored file into
+// memory instead of reading it. The bytes are only valid until release
+// unmaps them.
+type MapBackend interface {
+	Map(path string) (b []byte, release func() error, err error)
+}
+
+// MappedRecord is the encoded content of a record returned by ReadMapped,
+// shared with the operating system's page cache where the file could be
+// mapped rather than copied. Its bytes must not be modified, and not used
+// after Release.
+type MappedRecord struct {
+	b       []byte
+	release func() error
+}
+
+// Bytes returns the content of the record.
+func (m *MappedRecord) Bytes() []byte {
+	return m.b
+}
+
+// Mapped reports whether the content is mapped rather than a copy.
+func (m *MappedRecord) Mapped() bool {
+	return m.release != nil
+}
+
+// Release unmaps the content. Later calls do nothing.
+func (m *MappedRecord) Release() error {
+	release := m.release
+	m.b, m.release = nil, nil
+	if release == nil {
+		return nil
+	}
+	return release()
+}
+
+// ReadMapped returns a record's encoded content, as ReadTo writes it,
+// without copying it where it can: from a memory mapping of its file when
+// that is at least Options.MmapThreshold bytes, holds the record as it is,
+// not compressed or encrypted, and the backend implements MapBackend.
+// Other records are read into a copy. Callers Release the result once done
+// with it. On Windows, writes replacing a mapped record fail until then.
+// A missing record is reported with an error matching fs.ErrNotExist.
+func (d *Driver) ReadMapped(collection string, resource string) (*MappedRecord, error) {
+	return d.ReadMappedContext(context.Background(), collection, resource)
+}
+
+// ReadMappedContext is ReadMapped with a context to trace the operation in.
+func (d *Driver) ReadMappedContext(ctx context.Context, collection string, resource string) (m *MappedRecord, err error) {
+	op := d.startOp(ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	p := d.recordPath(collection, resource)
+	expired, err := d.expired(p)
+	if err != nil {
+		return nil, err
+	}
+	if !expired {
+		if m, err = d.mapRecord(collection, p); err != nil {
+			return nil, err
+		}
+	}
+	if m == nil {
+		b, err := d.readRecord(collection, resource)
+		if isNotExist(err) {
+			return nil, fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m = &MappedRecord{b: b}
+	}
+	op.addBytes(int64(len(m.b)))
+	return m, nil
+}
+
+// mapRecord maps the file of the record at p, or returns nil if it cannot
+// be mapped.
+func (d *Driver) mapRecord(collection, p string) (*MappedRecord, error) {
+	mb, ok := d.backend.(MapBackend)
+	if !ok || d.mmapThreshold <= 0 || d.keys != nil {
+		return nil, nil
+	}
+	stat, ok := d.backend.(StatBackend)
+	if !ok {
+		return nil, nil
+	}
+	if encrypted, deterministic := d.fieldOptions(collection); len(encrypted)+len(deterministic) > 0 {
+		return nil, nil
+	}
+
+	// Uncompressed files come first among the variants of a record, as
+	// getRecord looks for them.
+	file := d.recordFile(p, CompressionNone)
+	size, _, err := stat.Stat(file)
+	if isNotExist(err) || err == nil && size < d.mmapThreshold {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, release, err := mb.Map(file)
+	if isNotExist(err) {
+		// Deleted, or rewritten compressed, since.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := recordKeyVersion(b); ok {
+		// Encrypted by another Driver with a master key; reading it
+		// reports that.
+		release()
+		return nil, nil
+	}
+	return &MappedRecord{b: b, release: release}, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// openMapped opens a Driver mapping records of 64KiB and more, with the
+// "archive" collection compressed.
+func openMapped(t *testing.T, dir string) *Driver {
+	t.Helper()
+	d, err := New(dir, &Options{
+		MmapThreshold:    1 << 16,
+		Collections:      map[string]CollectionOptions{"archive": {Compression: CompressionGzip}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestReadMapped(t *testing.T) {
+	d := openMapped(t, t.TempDir())
+	defer d.Close()
+
+	big := strings.Repeat("y", 1<<20)
+	for _, collection := range []string{"docs", "archive"} {
+		if err := d.Write(collection, "big", big); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Write("docs", "small", "s"); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	if err := d.ReadTo("docs", "big", &want); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := d.ReadMapped("docs", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Mapped() || !bytes.Equal(m.Bytes(), want.Bytes()) {
+		t.Errorf("ReadMapped of a large record = mapped %v, %d bytes; want mapped, the %d of ReadTo", m.Mapped(), len(m.Bytes()), want.Len())
+	}
+	if err := m.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Release(); err != nil {
+		t.Errorf("second Release = %v", err)
+	}
+
+	for _, c := range []struct{ collection, key string }{{"docs", "small"}, {"archive", "big"}} {
+		m, err := d.ReadMapped(c.collection, c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		if err := d.ReadTo(c.collection, c.key, &want); err != nil {
+			t.Fatal(err)
+		}
+		if m.Mapped() || !bytes.Equal(m.Bytes(), want.Bytes()) {
+			t.Errorf("ReadMapped(%s, %s) = mapped %v, %d bytes; want a copy of the %d of ReadTo", c.collection, c.key, m.Mapped(), len(m.Bytes()), want.Len())
+		}
+		m.Release()
+	}
+
+	if _, err := d.ReadMapped("docs", "none"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadMapped of a missing record = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestReadMappedEncrypted(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{MmapThreshold: 1, MasterKey: testMasterKey, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	big := strings.Repeat("y", 1<<20)
+	if err := d.Write("docs", "big", big); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.ReadMapped("docs", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Release()
+	if m.Mapped() || !strings.Contains(string(m.Bytes()), big) {
+		t.Errorf("ReadMapped of an encrypted record = mapped %v, %d bytes; want a decrypted copy", m.Mapped(), len(m.Bytes()))
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func (f *fileBackend) Map(path string) ([]byte, func() error, error) {
+	p, err := f.path(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		// Empty files cannot be mapped.
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("file %s is too large to map", path)
+	}
+	b, err := unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return b, func() error { return unix.Munmap(b) }, nil
+}
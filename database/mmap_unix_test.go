@@ -0,0 +1,42 @@
+//go:build unix
+
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadMappedOverwrite(t *testing.T) {
+	d := openMapped(t, t.TempDir())
+	defer d.Close()
+
+	big := strings.Repeat("y", 1<<20)
+	if err := d.Write("docs", "big", big); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.ReadMapped("docs", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Clone(m.Bytes())
+
+	// Writes replace the file, leaving the mapped one as it was.
+	if err := d.Write("docs", "big", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Bytes(), want) {
+		t.Error("mapped record changed by an overwrite")
+	}
+	m.Release()
+
+	m, err = d.ReadMapped("docs", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Release()
+	if m.Mapped() || strings.TrimSpace(string(m.Bytes())) != `"new"` {
+		t.Errorf("ReadMapped after the overwrite = mapped %v, %q; want a copy of \"new\"", m.Mapped(), m.Bytes())
+	}
+}
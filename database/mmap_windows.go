@@ -0,0 +1,50 @@
+//go:build windows
+
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func (f *fileBackend) Map(path string) ([]byte, func() error, error) {
+	p, err := f.path(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		// Empty files cannot be mapped.
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("file %s is too large to map", path)
+	}
+	h, err := windows.CreateFileMapping(windows.Handle(file.Fd()), nil, windows.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	// The view keeps the mapping open once its handle is closed.
+	defer windows.CloseHandle(h)
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	// The view is not memory the Go runtime manages, so reading its address
+	// as a pointer is safe.
+	b := unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), int(size))
+	return b, func() error { return windows.UnmapViewOfFile(addr) }, nil
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var ErrInvalidName = errors.New("invalid name")
+
+func validName(kind, name string) error {
+	switch {
+	case name == "", name == ".", name == "..":
+		return fmt.Errorf("%w: %s %q", ErrInvalidName, kind, name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		return fmt.Errorf("%w: %s %q must not contain path separators or NUL", ErrInvalidName, kind, name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("%w: %s %q must not start with a dot", ErrInvalidName, kind, name)
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " "):
+		return fmt.Errorf("%w: %s %q must not end with a dot or space", ErrInvalidName, kind, name)
+	case reservedName(name):
+		return fmt.Errorf("%w: %s %q is a reserved device name on Windows", ErrInvalidName, kind, name)
+	}
+	return nil
+}
+
+// reservedName reports whether Windows treats name as a device, which it
+// does for the reserved stems regardless of case or extension ("aux.json").
+func reservedName(name string) bool {
+	stem := name
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	switch strings.ToUpper(strings.TrimRight(stem, " ")) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM0", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT0", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+	return false
+}
+
+// validCollection accepts a plain collection name, a sub-collection path
+// alternating collection names and record keys ("users/Waqar/orders"), or
+// either of them namespaced as built by Namespace.
+func validCollection(collection string) error {
+	parts := strings.Split(collection, "/")
+	if len(parts) >= 3 && parts[0] == namespacesDir {
+		if err := validNamespace(parts[1]); err != nil {
+			return err
+		}
+		parts = parts[2:]
+	}
+	if len(parts)%2 == 0 {
+		return fmt.Errorf("%w: collection %q must not contain path separators outside sub-collection paths", ErrInvalidName, collection)
+	}
+	for i, part := range parts {
+		if i%2 == 1 {
+			if err := validParentKey(part); err != nil {
+				return err
+			}
+		} else if err := validName("collection", part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validParentKey checks a record key in a sub-collection path. The key
+// names the directory holding the record's sub-collections as it is, so it
+// must be a key that needs no encoding and must not clash with the record's
+// attachments.
+func validParentKey(key string) error {
+	if err := validName("key", key); err != nil {
+		return err
+	}
+	if encodeKey(key, KeyCaseSensitive) != key || strings.HasSuffix(key, attachmentsSuffix) {
+		return fmt.Errorf("%w: key %q cannot have sub-collections", ErrInvalidName, key)
+	}
+	return nil
+}
+
+const safeKeyPunctuation = "-_.~@+=,()!' "
+
+// encodeKey percent-encodes every byte of a resource key that is not
+// portable in a file name, plus a leading dot so keys never become hidden
+// files or collide with the Driver's own dot-files. Non-ASCII bytes are
+// encoded too, since some file systems normalize Unicode names, and so
+// are the first byte of Windows device names and a trailing dot or space,
+// which Windows silently strips. This is why resource keys need no
+// validation beyond being non-empty, and why the mapping is the same on
+// every OS.
+func encodeKey(key string, keyCase KeyCase) string {
+	reserved := reservedName(key)
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		safe := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			strings.IndexByte(safeKeyPunctuation, c) >= 0
+		switch {
+		case i == 0 && (c == '.' || reserved):
+			safe = false
+		case i == len(key)-1 && (c == '.' || c == ' '):
+			safe = false
+		case keyCase == KeyCaseEncode && 'A' <= c && c <= 'Z':
+			safe = false
+		}
+		if safe {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func decodeKey(name string) string {
+	key, err := url.PathUnescape(name)
+	if err != nil {
+		return name
+	}
+	return key
+}
+
+func (d *Driver) encodeKey(key string) string {
+	return encodeKey(key, d.keyCase)
+}
+
+func (d *Driver) recordPath(collection, resource string) string {
+	return path.Join(collection, d.encodeKey(resource))
+}
+
+// recordStem strips the record extension of collection from a file name,
+// or returns false if the name does not carry it.
+func (d *Driver) recordStem(collection, file string) (string, bool) {
+	if strings.HasPrefix(file, ".") {
+		// The Driver's own files, such as manifests: keys are encoded
+		// never to start with a dot.
+		return "", false
+	}
+	recordExt := d.extFor(collection)
+	for _, c := range compressions {
+		if ext := recordExt + c.ext(); strings.HasSuffix(file, ext) {
+			return strings.TrimSuffix(file, ext), true
+		}
+	}
+	return "", false
+}
+
+// recordName returns the decoded key stored in a record file name.
+func (d *Driver) recordName(collection, file string) (string, bool) {
+	stem, ok := d.recordStem(collection, file)
+	return decodeKey(stem), ok
+}
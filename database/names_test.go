@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidCollection(t *testing.T) {
+	for _, collection := range []string{
+		"users",
+		"users/ada/orders",
+		".namespaces/acme/users",
+		".namespaces/acme/users/ada/orders",
+	} {
+		if err := validCollection(collection); err != nil {
+			t.Errorf("validCollection(%q) = %v", collection, err)
+		}
+	}
+	for _, collection := range []string{
+		"", ".", "..", "../etc", "users/..", "users/ada", "/users", `users\ada`,
+		".keys", "users/../../etc", "users\x00", ".namespaces/../users",
+	} {
+		if err := validCollection(collection); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("validCollection(%q) = %v, want ErrInvalidName", collection, err)
+		}
+	}
+}
+
+func TestWriteOutsideDatabase(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "db")
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("../escaped", "x", 1); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Write to ../escaped = %v, want ErrInvalidName", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("Write escaped the database directory: %v", err)
+	}
+	b := NewFileBackend(dir)
+	if err := b.Put("../escaped.json", nil); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Put of ../escaped.json = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"ada":        "ada",
+		"Ada Smith":  "Ada Smith",
+		"a/b":        "a%2Fb",
+		"../etc":     "%2E.%2Fetc",
+		".hidden":    "%2Ehidden",
+		"100%":       "100%25",
+		"café":       "caf%C3%A9",
+		`back\slash`: "back%5Cslash",
+	} {
+		got := encodeKey(key, KeyCaseSensitive)
+		if got != want {
+			t.Errorf("encodeKey(%q) = %q, want %q", key, got, want)
+		}
+		if back := decodeKey(got); back != key {
+			t.Errorf("decodeKey(%q) = %q, want %q", got, back, key)
+		}
+	}
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	keys := []string{"../../etc/passwd", "a/b", ".keys", "100%", "café", "with space"}
+	for _, key := range keys {
+		if err := d.Write("users", key, map[string]string{"key": key}); err != nil {
+			t.Fatalf("Write %q: %v", key, err)
+		}
+	}
+	for _, key := range keys {
+		var v map[string]string
+		if err := d.Read("users", key, &v); err != nil || v["key"] != key {
+			t.Errorf("Read %q = %v, %v", key, v, err)
+		}
+	}
+	got, err := d.Keys("users")
+	if err != nil || len(got) != len(keys) {
+		t.Fatalf("Keys = %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(keys) {
+		t.Errorf("%d entries in the collection directory, want %d", len(entries), len(keys))
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	for _, name := range []string{"con", "CON", "aux.json", "Lpt1", "nul ", "COM9.txt"} {
+		if !reservedName(name) {
+			t.Errorf("reservedName(%q) = false", name)
+		}
+		if err := validName("collection", name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("collection %q = %v, want ErrInvalidName", name, err)
+		}
+	}
+	for _, name := range []string{"console", "auxiliary", "com10", "lpt"} {
+		if reservedName(name) {
+			t.Errorf("reservedName(%q) = true", name)
+		}
+	}
+	for key, want := range map[string]string{
+		"con":     "%63on",
+		"AUX.txt": "%41UX.txt",
+		"end.":    "end%2E",
+		"end ":    "end%20",
+	} {
+		if got := encodeKey(key, KeyCaseSensitive); got != want {
+			t.Errorf("encodeKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	namespacesDir = ".namespaces"
+	trashDir      = ".trash"
+)
+
+// Namespace scopes collections to a single tenant. Its records live under
+// their own directory and never mix with top-level collections or other
+// tenants.
+type Namespace struct {
+	d    *Driver
+	name string
+}
+
+func validNamespace(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("%w: namespace %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+func (d *Driver) Namespace(name string) *Namespace {
+	return &Namespace{d: d, name: name}
+}
+
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+func (n *Namespace) dir() string {
+	return path.Join(namespacesDir, n.name)
+}
+
+func (n *Namespace) collection(collection string) (string, error) {
+	if err := validNamespace(n.name); err != nil {
+		return "", err
+	}
+	if collection == "" {
+		return "", nil
+	}
+	return path.Join(n.dir(), collection), nil
+}
+
+func (n *Namespace) quota() Quota {
+	if q, ok := n.d.namespaceQuotas[n.name]; ok {
+		return q
+	}
+	return n.d.namespaceQuota
+}
+
+func (n *Namespace) Write(collection string, resource string, v interface{}) error {
+	c, err := n.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	// Writes to different collections of the tenant share its quota, so they
+	// are serialized on the tenant lock.
+	if q := n.quota(); q.enabled() {
+		mutex := n.d.GetOrCreateMutex(n.dir())
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+
+	return n.d.Write(c, resource, v)
+}
+
+func (n *Namespace) Insert(collection string, v interface{}) (string, error) {
+	c, err := n.collection(collection)
+	if err != nil {
+		return "", err
+	}
+
+	if q := n.quota(); q.enabled() {
+		mutex := n.d.GetOrCreateMutex(n.dir())
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+
+	return n.d.Insert(c, v)
+}
+
+func (n *Namespace) Read(collection string, resource string, v interface{}) error {
+	c, err := n.collection(collection)
+	if err != nil {
+		return err
+	}
+	return n.d.Read(c, resource, v)
+}
+
+func (n *Namespace) ReadAll(collection string) ([]string, error) {
+	c, err := n.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return n.d.ReadAll(c)
+}
+
+func (n *Namespace) Delete(collection string, resource string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection - use DropNamespace to remove a whole tenant")
+	}
+	c, err := n.collection(collection)
+	if err != nil {
+		return err
+	}
+	return n.d.Delete(c, resource)
+}
+
+// DropCollection deletes a collection of the tenant as
+// Driver.DropCollection does, reporting the collections by their names in
+// the tenant.
+func (n *Namespace) DropCollection(collection string, dryRun bool) (*DeleteReport, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - use DropNamespace to remove a whole tenant")
+	}
+	c, err := n.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	report, err := n.d.DropCollection(context.Background(), c, dryRun)
+	if report != nil {
+		records := make(map[string][]string, len(report.Records))
+		for c, keys := range report.Records {
+			records[strings.TrimPrefix(c, n.dir()+"/")] = keys
+		}
+		report.Records = records
+	}
+	return report, err
+}
+
+func (n *Namespace) Collections() ([]string, error) {
+	if err := validNamespace(n.name); err != nil {
+		return nil, err
+	}
+	return n.d.listDirs(n.dir())
+}
+
+// Usage returns the tenant's record count and stored bytes.
+func (n *Namespace) Usage() (records int, bytes int64, err error) {
+	if err := validNamespace(n.name); err != nil {
+		return 0, 0, err
+	}
+	var u usage
+	if n.quota().enabled() {
+		u, err = n.d.usage(n.dir())
+	} else {
+		u, err = n.d.walkUsage(n.dir())
+	}
+	return u.records, u.bytes, err
+}
+
+// Collections returns the top-level collections, sorted.
+func (d *Driver) Collections() ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	names, err := d.listDirs("")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *Driver) Namespaces() ([]string, error) {
+	names, err := d.listDirs(namespacesDir)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	return names, err
+}
+
+// DropNamespace removes a tenant and all its collections. The tenant
+// directory is first renamed out of the way, so readers see either the whole
+// tenant or nothing, and only then deleted.
+func (d *Driver) DropNamespace(name string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := validNamespace(name); err != nil {
+		return err
+	}
+	if err := d.authorize("*", PermDelete); err != nil {
+		return err
+	}
+
+	n := d.Namespace(name)
+	collections, err := n.Collections()
+	if isNotExist(err) {
+		return fmt.Errorf("unable to find namespace named %v: %w", name, fs.ErrNotExist)
+	}
+	if err != nil {
+		return err
+	}
+
+	mutexes := []*sync.Mutex{d.GetOrCreateMutex(n.dir())}
+	sort.Strings(collections)
+	for _, c := range collections {
+		mutexes = append(mutexes, d.GetOrCreateMutex(path.Join(n.dir(), c)))
+	}
+	for _, m := range mutexes {
+		m.Lock()
+	}
+	defer func() {
+		for _, m := range mutexes {
+			m.Unlock()
+		}
+	}()
+
+	d.forgetKeyCase(n.dir(), "")
+	d.forgetUsage(n.dir())
+	trash := path.Join(trashDir, name+"-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := d.backend.Rename(n.dir(), trash); err != nil {
+		return err
+	}
+	for _, c := range collections {
+		d.notify(EventDelete, path.Join(n.dir(), c), "")
+	}
+	d.log.Debug("Dropped namespace '%s'\n", name)
+	return d.backend.Delete(trash)
+}
+
+func (d *Driver) listDirs(dir string) ([]string, error) {
+	entries, err := d.backend.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if isDirName(entry) && !strings.HasPrefix(entry, ".") {
+			dirs = append(dirs, strings.TrimSuffix(entry, "/"))
+		}
+	}
+	return dirs, nil
+}
+
+// collectionPaths returns every collection in the database, including the
+// ones inside namespaces and the sub-collections, as paths usable with the
+// Driver methods. Collections come before their sub-collections.
+func (d *Driver) collectionPaths() ([]string, error) {
+	top, err := d.listDirs("")
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := d.Namespaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range namespaces {
+		collections, err := d.Namespace(name).Collections()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range collections {
+			top = append(top, path.Join(namespacesDir, name, c))
+		}
+	}
+
+	var paths []string
+	for _, c := range top {
+		tree, err := d.collectionTree(c)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, c)
+		paths = append(paths, tree...)
+	}
+	return paths, nil
+}
+
+// baseCollection strips the namespace from a collection path, so tenants
+// share the per-collection options of the top-level collection name.
+func baseCollection(collection string) string {
+	if parts := strings.SplitN(collection, "/", 3); len(parts) == 3 && parts[0] == namespacesDir {
+		return parts[2]
+	}
+	return collection
+}
+
+// rootCollection returns the top-level collection name at the root of a
+// sub-collection path, namespace stripped.
+func rootCollection(collection string) string {
+	root, _, _ := strings.Cut(baseCollection(collection), "/")
+	return root
+}
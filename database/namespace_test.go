@@ -0,0 +1,97 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	acme, globex := d.Namespace("acme"), d.Namespace("globex")
+	if err := acme.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := globex.Write("users", "bob", map[string]string{"name": "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := acme.Read("users", "ada", &v); err != nil || v["name"] != "Ada" {
+		t.Fatalf("Read = %v, %v", v, err)
+	}
+	// Read leaves v alone for a missing record.
+	var other map[string]string
+	if err := acme.Read("users", "bob", &other); err != nil || other != nil {
+		t.Errorf("read another tenant's record: %v, %v", other, err)
+	}
+	if collections, err := d.Collections(); err != nil || len(collections) != 0 {
+		t.Errorf("tenant collections listed at the top level: %v, %v", collections, err)
+	}
+	if names, err := d.Namespaces(); err != nil || !reflect.DeepEqual(names, []string{"acme", "globex"}) {
+		t.Errorf("Namespaces = %v, %v", names, err)
+	}
+	if records, _, err := acme.Usage(); err != nil || records != 1 {
+		t.Errorf("Usage = %d records, %v; want 1", records, err)
+	}
+
+	if err := d.DropNamespace("acme"); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := acme.ReadAll("users"); len(records) != 0 {
+		t.Errorf("ReadAll after DropNamespace = %v, %v", records, err)
+	}
+	if err := globex.Read("users", "bob", &v); err != nil {
+		t.Errorf("DropNamespace removed another tenant: %v", err)
+	}
+	if err := d.DropNamespace("acme"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DropNamespace of a missing tenant = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestNamespaceInvalidName(t *testing.T) {
+	d, err := NewMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, name := range []string{"", ".", "..", ".hidden", "a/b", `a\b`} {
+		if err := d.Namespace(name).Write("users", "ada", 1); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Write in namespace %q = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		NamespaceQuota:   Quota{MaxRecords: 2},
+		NamespaceQuotas:  map[string]Quota{"big": {MaxRecords: 10}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	acme := d.Namespace("acme")
+	if err := acme.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Write("orders", "b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Write("users", "c", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write past the tenant quota = %v, want ErrQuotaExceeded", err)
+	}
+	big := d.Namespace("big")
+	for _, key := range []string{"a", "b", "c"} {
+		if err := big.Write("users", key, 1); err != nil {
+			t.Errorf("write within a tenant's own quota: %v", err)
+		}
+	}
+}
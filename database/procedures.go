@@ -0,0 +1,433 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// ErrProcedureFailed is returned when a procedure raises an error.
+var ErrProcedureFailed = errors.New("procedure failed")
+
+// Procedure is a named Lua script run by Call. The script sees the call's
+// argument as args and a db table to work on its collections with:
+//
+//	db.read(collection, key)         the record, or nil if there is none
+//	db.write(collection, key, value) replace the record
+//	db.delete(collection, key)       delete the record, true if there was one
+//	db.keys(collection)              the keys of the collection, sorted
+//
+// and returns the call's result. JSON arrays are Lua tables numbered from 1
+// and objects tables with string keys; an empty table is an object unless it
+// was read as an array.
+//
+//	local from = db.read("accounts", args.from)
+//	local to = db.read("accounts", args.to)
+//	if from.balance < args.amount then error("insufficient funds") end
+//	from.balance = from.balance - args.amount
+//	to.balance = to.balance + args.amount
+//	db.write("accounts", args.from, from)
+//	db.write("accounts", args.to, to)
+//	return from.balance
+type Procedure struct {
+	Name string
+	// Collections lists the only collections the procedure may use, all
+	// top-level. They are locked for the whole call.
+	Collections []string
+	Source      string
+}
+
+type procedure struct {
+	*Procedure
+	proto *lua.FunctionProto
+}
+
+func (d *Driver) openProcedures(procs []Procedure) error {
+	d.procedures = make(map[string]*procedure)
+	for i := range procs {
+		p := &procs[i]
+		if err := validName("procedure", p.Name); err != nil {
+			return err
+		}
+		if _, ok := d.procedures[p.Name]; ok {
+			return fmt.Errorf("duplicate procedure %s", p.Name)
+		}
+		for _, c := range p.Collections {
+			if err := validName("collection", c); err != nil {
+				return fmt.Errorf("procedure %s: %w", p.Name, err)
+			}
+		}
+		chunk, err := parse.Parse(strings.NewReader(p.Source), p.Name)
+		if err != nil {
+			return fmt.Errorf("procedure %s: %w", p.Name, err)
+		}
+		proto, err := lua.Compile(chunk, p.Name)
+		if err != nil {
+			return fmt.Errorf("procedure %s: %w", p.Name, err)
+		}
+		d.procedures[p.Name] = &procedure{Procedure: p, proto: proto}
+	}
+	return nil
+}
+
+// Procedures returns the names of the procedures, sorted.
+func (d *Driver) Procedures() []string {
+	names := make([]string, 0, len(d.procedures))
+	for name := range d.procedures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call runs the procedure named name with args, which must encode to JSON
+// values, and returns its result. The procedure's collections are locked
+// while it runs, so no other writer changes them in between its reads and
+// its writes, and its writes are only made once it returns without error.
+// They are made one by one, so a crash while making them can leave some
+// undone.
+func (d *Driver) Call(name string, args interface{}) (interface{}, error) {
+	return d.CallContext(context.Background(), name, args)
+}
+
+// CallContext is Call with a context to trace the operation in and to
+// cancel the procedure with.
+func (d *Driver) CallContext(ctx context.Context, name string, args interface{}) (result interface{}, err error) {
+	op := d.startOp(ctx, "call", "", name)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	p, ok := d.procedures[name]
+	if !ok {
+		return nil, fmt.Errorf("unable to find procedure named %v: %w", name, fs.ErrNotExist)
+	}
+	// Views are left unlocked: procedures cannot write them, and writes to
+	// their sources lock them to update them.
+	var locked []string
+	for _, c := range p.Collections {
+		if _, ok := d.views[c]; !ok {
+			locked = append(locked, c)
+		}
+	}
+	defer d.lockCollections(locked)()
+
+	call := &procedureCall{d: d, ctx: ctx, p: p}
+	if result, err = call.run(args); err != nil {
+		return nil, err
+	}
+	if err := call.commit(op); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// procedureCall is the state of one Call, with the writes it will make.
+type procedureCall struct {
+	d      *Driver
+	ctx    context.Context
+	p      *procedure
+	writes writeSet
+}
+
+// goError carries a Go error through a Lua error.
+type goError struct{ err error }
+
+func (c *procedureCall) run(args interface{}) (interface{}, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{{lua.BaseLibName, lua.OpenBase}, {lua.TabLibName, lua.OpenTable}, {lua.StringLibName, lua.OpenString}, {lua.MathLibName, lua.OpenMath}} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// Procedures get no access to files or output.
+	for _, name := range []string{"dofile", "loadfile", "module", "require", "print", "_printregs"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetContext(c.ctx)
+
+	errMeta := L.NewTypeMetatable("goerror")
+	L.SetField(errMeta, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		ud := L.CheckUserData(1)
+		L.Push(lua.LString(ud.Value.(goError).err.Error()))
+		return 1
+	}))
+	raise := func(L *lua.LState, err error) int {
+		ud := L.NewUserData()
+		ud.Value = goError{err}
+		L.SetMetatable(ud, errMeta)
+		L.Error(ud, 1)
+		return 0
+	}
+
+	arg, err := toLua(L, args)
+	if err != nil {
+		return nil, fmt.Errorf("procedure %s arguments: %w", c.p.Name, err)
+	}
+	L.SetGlobal("args", arg)
+	db := L.NewTable()
+	db.RawSetString("read", L.NewFunction(func(L *lua.LState) int {
+		v, err := c.read(L.CheckString(1), L.CheckString(2))
+		if err == nil {
+			var lv lua.LValue
+			if lv, err = toLua(L, v); err == nil {
+				L.Push(lv)
+				return 1
+			}
+		}
+		return raise(L, err)
+	}))
+	db.RawSetString("write", L.NewFunction(func(L *lua.LState) int {
+		v, err := fromLua(L, L.CheckAny(3))
+		if err == nil {
+			err = c.write(L.CheckString(1), L.CheckString(2), v, false)
+		}
+		if err != nil {
+			return raise(L, err)
+		}
+		return 0
+	}))
+	db.RawSetString("delete", L.NewFunction(func(L *lua.LState) int {
+		collection, key := L.CheckString(1), L.CheckString(2)
+		v, err := c.read(collection, key)
+		if err == nil {
+			err = c.write(collection, key, nil, true)
+		}
+		if err != nil {
+			return raise(L, err)
+		}
+		L.Push(lua.LBool(v != nil))
+		return 1
+	}))
+	db.RawSetString("keys", L.NewFunction(func(L *lua.LState) int {
+		keys, err := c.keys(L.CheckString(1))
+		if err != nil {
+			return raise(L, err)
+		}
+		t := L.NewTable()
+		for _, key := range keys {
+			t.Append(lua.LString(key))
+		}
+		L.SetMetatable(t, arrayMeta(L))
+		L.Push(t)
+		return 1
+	}))
+	L.SetGlobal("db", db)
+
+	L.Push(L.NewFunctionFromProto(c.p.proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		if ctxErr := c.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if ud, ok := apiErr.Object.(*lua.LUserData); ok {
+				if e, ok := ud.Value.(goError); ok {
+					return nil, e.err
+				}
+			}
+			return nil, fmt.Errorf("%w: %s: %s", ErrProcedureFailed, c.p.Name, apiErr.Object.String())
+		}
+		return nil, fmt.Errorf("%w: %s: %v", ErrProcedureFailed, c.p.Name, err)
+	}
+	result, err := fromLua(L, L.Get(-1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s result: %v", ErrProcedureFailed, c.p.Name, err)
+	}
+	return result, nil
+}
+
+func (c *procedureCall) check(collection string, perm Permission) error {
+	for _, allowed := range c.p.Collections {
+		if collection == allowed {
+			return c.d.authorizeContext(c.ctx, collection, perm)
+		}
+	}
+	return fmt.Errorf("%w: procedure %s does not use collection %s", ErrPermissionDenied, c.p.Name, collection)
+}
+
+func (c *procedureCall) read(collection, key string) (interface{}, error) {
+	if err := c.check(collection, PermRead); err != nil {
+		return nil, err
+	}
+	// Keys are encoded as Driver.Write encodes them.
+	if key == "" {
+		return nil, fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if w, ok := c.writes.get(collection, key); ok {
+		if w.deleted {
+			return nil, nil
+		}
+		return c.d.decodeDocument(collection, w.b)
+	}
+	b, err := c.d.readRecord(collection, key)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.d.decodeDocument(collection, b)
+}
+
+func (c *procedureCall) write(collection, key string, v interface{}, deleted bool) error {
+	perm := PermWrite
+	if deleted {
+		perm = PermDelete
+	}
+	if err := c.check(collection, perm); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if err := c.d.checkView(collection); err != nil {
+		return err
+	}
+	w := pendingWrite{collection: collection, key: key, deleted: deleted}
+	if !deleted {
+		b, err := c.d.codecFor(collection).Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := c.d.checkRecordSize(collection, key, b); err != nil {
+			return err
+		}
+		w.b = b
+	}
+	c.writes.put(w)
+	return nil
+}
+
+func (c *procedureCall) keys(collection string) ([]string, error) {
+	if err := c.check(collection, PermRead); err != nil {
+		return nil, err
+	}
+	keys, err := c.d.liveKeys(collection)
+	if err != nil {
+		return nil, err
+	}
+	return c.writes.keys(collection, keys), nil
+}
+
+// commit makes the writes of a call that succeeded; callers hold the
+// collection locks.
+func (c *procedureCall) commit(op *opTimer) error {
+	n, err := c.d.commitWrites(op, &c.writes)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		c.d.logEvent(slog.LevelDebug, "Procedure committed", slog.String("procedure", c.p.Name), slog.Int("writes", n))
+	}
+	return nil
+}
+
+// arrayMeta marks tables made from arrays, so empty ones stay arrays.
+func arrayMeta(L *lua.LState) *lua.LTable {
+	return L.NewTypeMetatable("array")
+}
+
+func toLua(L *lua.LState, v interface{}) (lua.LValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case string:
+		return lua.LString(v), nil
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, e := range v {
+			lv, err := toLua(L, e)
+			if err != nil {
+				return nil, err
+			}
+			t.Append(lv)
+		}
+		L.SetMetatable(t, arrayMeta(L))
+		return t, nil
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for k, e := range v {
+			lv, err := toLua(L, e)
+			if err != nil {
+				return nil, err
+			}
+			t.RawSetString(k, lv)
+		}
+		return t, nil
+	}
+	if f, ok := toFloat(v); ok {
+		return lua.LNumber(f), nil
+	}
+	// Anything else goes through JSON, as it would be stored.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return toLua(L, decoded)
+}
+
+func fromLua(L *lua.LState, lv lua.LValue) (interface{}, error) {
+	switch lv := lv.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(lv), nil
+	case lua.LNumber:
+		return float64(lv), nil
+	case lua.LString:
+		return string(lv), nil
+	case *lua.LTable:
+		n := lv.MaxN()
+		isArray := n > 0 || lv.Metatable == lua.LValue(arrayMeta(L))
+		count := 0
+		lv.ForEach(func(lua.LValue, lua.LValue) { count++ })
+		if isArray && count == n {
+			list := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				e, err := fromLua(L, lv.RawGetInt(i))
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, e)
+			}
+			return list, nil
+		}
+		doc := make(map[string]interface{}, count)
+		var err error
+		lv.ForEach(func(k, e lua.LValue) {
+			if err != nil {
+				return
+			}
+			key, ok := k.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("table key %v is not a string", k)
+				return
+			}
+			doc[string(key)], err = fromLua(L, e)
+		})
+		return doc, err
+	}
+	return nil, fmt.Errorf("cannot store a Lua %s", lv.Type())
+}
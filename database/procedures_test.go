@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const transfer = `
+local from = db.read("accounts", args.from)
+local to = db.read("accounts", args.to)
+if from.balance < args.amount then error("insufficient funds") end
+from.balance = from.balance - args.amount
+to.balance = to.balance + args.amount
+db.write("accounts", args.from, from)
+db.write("accounts", args.to, to)
+return from.balance`
+
+func openProcedures(t *testing.T, procs ...Procedure) *Driver {
+	t.Helper()
+	procs = append(procs, Procedure{Name: "transfer", Collections: []string{"accounts"}, Source: transfer})
+	d, err := New(t.TempDir(), &Options{Procedures: procs, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	for key, balance := range map[string]int{"a": 100, "b": 0, "c": 0} {
+		if err := d.Write("accounts", key, map[string]interface{}{"balance": balance, "tags": []string{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+func balance(t *testing.T, d *Driver, key string) string {
+	t.Helper()
+	var v map[string]interface{}
+	if err := d.Read("accounts", key, &v); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(v["balance"])
+}
+
+func TestCall(t *testing.T) {
+	d := openProcedures(t)
+
+	result, err := d.Call("transfer", map[string]interface{}{"from": "a", "to": "b", "amount": 30})
+	if err != nil || fmt.Sprint(result) != "70" {
+		t.Fatalf("Call = %v, %v", result, err)
+	}
+	_, err = d.Call("transfer", map[string]interface{}{"from": "a", "to": "b", "amount": 300})
+	if !errors.Is(err, ErrProcedureFailed) || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("Call raising an error = %v, want ErrProcedureFailed", err)
+	}
+	if a, b := balance(t, d, "a"), balance(t, d, "b"); a != "70" || b != "30" {
+		t.Errorf("balances = %s, %s; want 70, 30", a, b)
+	}
+
+	// Calls do not race with each other.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.Call("transfer", map[string]interface{}{"from": "a", "to": "b", "amount": 1}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if a, b := balance(t, d, "a"), balance(t, d, "b"); a != "20" || b != "80" {
+		t.Errorf("balances after concurrent calls = %s, %s; want 20, 80", a, b)
+	}
+
+	if _, err := d.Call("nope", nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Call of an unknown procedure = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestProcedureAPI(t *testing.T) {
+	d := openProcedures(t,
+		Procedure{Name: "misc", Collections: []string{"accounts", "log"}, Source: `
+			local k = db.keys("accounts")
+			db.write("log", "x", {keys = k, empty = {}, tags = db.read("accounts", "a").tags})
+			db.delete("accounts", "c")
+			return {before = #k, after = #db.keys("accounts"), gone = db.read("accounts", "c") == nil, had = db.delete("accounts", "zzz")}`},
+		Procedure{Name: "outside", Collections: []string{"accounts"}, Source: `return db.read("users", "a")`},
+		Procedure{Name: "caught", Collections: []string{"accounts"}, Source: `local ok, e = pcall(db.read, "users", "a"); return ok`},
+		Procedure{Name: "sandbox", Source: `return dofile == nil and io == nil and os == nil and print == nil and require == nil`},
+		Procedure{Name: "badret", Source: `return function() end`},
+	)
+
+	result, err := d.Call("misc", nil)
+	if fmt.Sprint(result) != "map[after:2 before:3 gone:true had:false]" || err != nil {
+		t.Errorf("Call = %v, %v", result, err)
+	}
+	var log map[string]interface{}
+	if err := d.Read("log", "x", &log); err != nil || fmt.Sprint(log) != "map[empty:map[] keys:[a b c] tags:[]]" {
+		t.Errorf("written record = %v, %v", log, err)
+	}
+
+	if _, err := d.Call("outside", nil); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Call using an undeclared collection = %v, want ErrPermissionDenied", err)
+	}
+	if result, err := d.Call("caught", nil); err != nil || result != false {
+		t.Errorf("Call catching a denied read = %v, %v", result, err)
+	}
+	if result, err := d.Call("sandbox", nil); err != nil || result != true {
+		t.Errorf("sandbox = %v, %v; want no file, os or module access", result, err)
+	}
+	if _, err := d.Call("badret", nil); !errors.Is(err, ErrProcedureFailed) {
+		t.Errorf("Call returning a function = %v, want ErrProcedureFailed", err)
+	}
+	if got := strings.Join(d.Procedures(), ","); got != "badret,caught,misc,outside,sandbox,transfer" {
+		t.Errorf("Procedures = %s", got)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	d := openProcedures(t, Procedure{Name: "spin", Collections: []string{"accounts"}, Source: `while true do end`})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := d.CallContext(ctx, "spin", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call past its deadline = %v, want context.DeadlineExceeded", err)
+	}
+	// The collection is unlocked again.
+	if err := d.Write("accounts", "a", 1); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcedureOptions(t *testing.T) {
+	for _, procs := range [][]Procedure{
+		{{Name: "x", Source: "return +"}},
+		{{Name: "x/y", Source: "return 1"}},
+		{{Name: "x", Source: "return 1"}, {Name: "x", Source: "return 2"}},
+		{{Name: "x", Collections: []string{"users/a/orders"}, Source: "return 1"}},
+	} {
+		if d, err := New(t.TempDir(), &Options{Procedures: procs, TTLSweepInterval: -1}); err == nil {
+			d.Close()
+			t.Errorf("New with procedures %+v succeeded", procs)
+		}
+	}
+}
+
+func TestProcedureEncodedKeys(t *testing.T) {
+	d := openProcedures(t, Procedure{Name: "bump", Collections: []string{"accounts"}, Source: `
+		local a = db.read("accounts", args.key)
+		a.balance = a.balance + 1
+		db.write("accounts", args.key, a)
+		return a.balance`},
+		Procedure{Name: "drop", Collections: []string{"accounts"}, Source: `return db.delete("accounts", args.key)`},
+	)
+	for _, key := range []string{"a/b", ".hidden", "CON", "x.", "y "} {
+		if err := d.Write("accounts", key, map[string]int{"balance": 1}); err != nil {
+			t.Fatal(err)
+		}
+		if result, err := d.Call("bump", map[string]interface{}{"key": key}); err != nil || fmt.Sprint(result) != "2" {
+			t.Errorf("Call over %q = %v, %v; want 2", key, result, err)
+		}
+		if b := balance(t, d, key); b != "2" {
+			t.Errorf("balance of %q = %s, want 2", key, b)
+		}
+		if result, err := d.Call("drop", map[string]interface{}{"key": key}); err != nil || result != true {
+			t.Errorf("Call deleting %q = %v, %v", key, result, err)
+		}
+	}
+	if _, err := d.Call("bump", map[string]interface{}{"key": ""}); err == nil {
+		t.Error("Call over the empty key succeeded")
+	}
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+)
+
+// PurgeReport records what Purge destroyed, for answering an erasure
+// request.
+type PurgeReport struct {
+	Collection string    `json:"collection"`
+	Key        string    `json:"key"`
+	Purged     time.Time `json:"purged"`
+	// Removed lists the storage paths deleted.
+	Removed []string `json:"removed"`
+	// KeyShredded reports that the record's field key was destroyed, so
+	// its encrypted fields cannot be read from any backup or copy either.
+	KeyShredded bool `json:"keyShredded"`
+	// Views lists the views the record was removed from.
+	Views []string `json:"views"`
+	// Remaining lists the paths still holding the record once Purge
+	// checked; it is empty when the purge is verified.
+	Remaining []string `json:"remaining"`
+}
+
+// Verified reports whether nothing of the record was left.
+func (r *PurgeReport) Verified() bool {
+	return len(r.Remaining) == 0
+}
+
+// Purge irreversibly destroys a record: its field key first, when
+// collection has encrypted fields, then its files in every compression,
+// left-over temp files, attachments, expiry and the idempotency keys it was
+// written under, and its projections in views and entries in geo indexes.
+// It then checks that none of them is left. Purging a record that is
+// already gone cleans up what it may have left behind and is not an error.
+//
+// Backups and exports taken before keep their copy of the record; only its
+// encrypted fields are lost to them, with the key. Sub-collections of the
+// record hold records of their own, for DeleteTree.
+func (d *Driver) Purge(collection, resource string) (*PurgeReport, error) {
+	return d.PurgeContext(context.Background(), collection, resource)
+}
+
+// PurgeContext is Purge with a context to trace the operation in.
+func (d *Driver) PurgeContext(ctx context.Context, collection, resource string) (report *PurgeReport, err error) {
+	op := d.startOp(ctx, "purge", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - nothing to purge")
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("missing resource - unable to purge record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.checkView(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return nil, err
+	}
+
+	// Taken before the collection lock, as WriteIdempotent does.
+	keys := d.GetOrCreateMutex(idempotencyDir)
+	op.lock(keys)
+	defer keys.Unlock()
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	idempotent, err := d.idempotencyPaths(collection, resource)
+	if err != nil {
+		return nil, err
+	}
+	paths := append(d.purgePaths(collection, resource), idempotent...)
+
+	report = &PurgeReport{Collection: collection, Key: resource, Removed: []string{}, Views: []string{}, Remaining: []string{}}
+	views := d.viewsHolding(collection, resource)
+
+	p := d.recordPath(collection, resource)
+	if err := d.releaseQuota(collection, p); err != nil {
+		return nil, err
+	}
+	if report.KeyShredded, err = d.removePath(d.recordKeyPath(collection, resource), report); err != nil {
+		return nil, err
+	}
+	for _, file := range paths {
+		if _, err := d.removePath(file, report); err != nil {
+			return nil, err
+		}
+	}
+	d.forgetKeyCase(collection, path.Base(p))
+	// Deletes the record from the views and geo indexes.
+	d.notify(EventDelete, collection, resource)
+
+	for _, file := range paths {
+		ok, err := d.pathExists(file)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			report.Remaining = append(report.Remaining, file)
+		}
+	}
+	for _, v := range views {
+		report.Views = append(report.Views, v)
+		if _, err := d.readRecord(v, resource); err == nil {
+			report.Remaining = append(report.Remaining, d.recordPath(v, resource))
+		} else if !isNotExist(err) {
+			return nil, err
+		}
+	}
+	if gi, ok := d.geoIndexes[collection]; ok {
+		gi.mutex.RLock()
+		_, indexed := gi.points[resource]
+		gi.mutex.RUnlock()
+		if indexed {
+			report.Remaining = append(report.Remaining, "geo index of "+collection)
+		}
+	}
+	report.Purged = time.Now().UTC()
+
+	// The key is left out: it may be the very personal data being erased.
+	level := slog.LevelInfo
+	if !report.Verified() {
+		level = slog.LevelError
+	}
+	d.logEvent(level, "Record purged", slog.String("collection", collection), slog.Int("removed", len(report.Removed)),
+		slog.Bool("keyShredded", report.KeyShredded), slog.Int("remaining", len(report.Remaining)))
+	return report, nil
+}
+
+// purgePaths lists every path that can hold any of a record: its files in
+// each compression and their temp files, its field key, attachments,
+// expiry, metadata and their temp files.
+func (d *Driver) purgePaths(collection, resource string) []string {
+	p := d.recordPath(collection, resource)
+	var paths []string
+	for _, c := range compressions {
+		file := d.recordFile(p, c)
+		paths = append(paths, file, file+".tmp")
+	}
+	keyPath := d.recordKeyPath(collection, resource)
+	return append(paths, keyPath, keyPath+".tmp", p+attachmentsSuffix, ttlPath(p), ttlPath(p)+".tmp", metaPath(p), metaPath(p)+".tmp")
+}
+
+// viewsHolding returns the views, and views of those, holding a record
+// with key resource built from collection.
+func (d *Driver) viewsHolding(collection, resource string) []string {
+	var views []string
+	for _, v := range d.viewsBySource[collection] {
+		if _, err := d.readRecord(v.Name, resource); err != nil {
+			continue
+		}
+		views = append(views, v.Name)
+		views = append(views, d.viewsHolding(v.Name, resource)...)
+	}
+	return views
+}
+
+// removePath deletes a file or directory, noting it in the report, and
+// reports whether there was one.
+func (d *Driver) removePath(p string, report *PurgeReport) (bool, error) {
+	if ok, err := d.pathExists(p); err != nil || !ok {
+		return false, err
+	}
+	if err := d.backend.Delete(p); err != nil && !isNotExist(err) {
+		return false, err
+	}
+	report.Removed = append(report.Removed, p)
+	return true, nil
+}
+
+func (d *Driver) pathExists(p string) (bool, error) {
+	_, err := d.backend.Get(p)
+	if err == nil {
+		return true, nil
+	}
+	if !isNotExist(err) {
+		// A directory, on backends that cannot read one as a file.
+		if _, err := d.backend.List(p); err == nil {
+			return true, nil
+		}
+		return false, err
+	}
+	files, err := d.backend.List(p)
+	if isNotExist(err) {
+		return false, nil
+	}
+	return err == nil && len(files) > 0, err
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	for _, memory := range []bool{false, true} {
+		opts := &Options{
+			FieldKey: testFieldKey,
+			Collections: map[string]CollectionOptions{
+				"users":  {EncryptedFields: []string{"email"}, Compression: CompressionGzip},
+				"emails": {EncryptedFields: []string{"email"}},
+			},
+			Views:            []View{{Name: "emails", Source: "users"}, {Name: "addresses", Source: "emails"}},
+			GeoIndexes:       []GeoIndex{{Collection: "users"}},
+			TTLSweepInterval: -1,
+		}
+		if memory {
+			opts.Backend = NewMemoryBackend()
+		}
+		d, err := New(t.TempDir(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bob := map[string]interface{}{"email": "bob@example.com", "lat": 1.0, "lng": 2.0}
+		if err := d.WriteTTL("users", "bob", bob, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.WriteIdempotent("users", "bob", bob, "request-1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Write("users", "al", map[string]interface{}{"email": "al@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.PutAttachment("users", "bob", "avatar", strings.NewReader("jpeg")); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := d.Purge("users", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Verified() || !report.KeyShredded || len(report.Views) != 2 || len(report.Removed) < 4 {
+			t.Errorf("Purge (memory %v) = %+v", memory, report)
+		}
+		if keys, _ := d.Keys("users"); len(keys) != 1 {
+			t.Errorf("Keys after Purge = %q, want al", keys)
+		}
+		if keys, _ := d.Keys("addresses"); len(keys) != 1 {
+			t.Errorf("Keys of a view of a view after Purge = %q, want al", keys)
+		}
+		if names, _ := d.Attachments("users", "bob"); len(names) != 0 {
+			t.Errorf("Attachments after Purge = %q", names)
+		}
+		if results, _ := d.FindNear("users", 1, 2, 10); len(results) != 0 {
+			t.Errorf("FindNear after Purge = %v", results)
+		}
+
+		// A second purge finds nothing left.
+		report, err = d.Purge("users", "bob")
+		if err != nil || len(report.Removed) != 0 || !report.Verified() {
+			t.Errorf("second Purge = %+v, %v", report, err)
+		}
+		if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+			t.Errorf("Verify after Purge = %+v, %v", report, err)
+		}
+		if _, err := d.Purge("emails", "al"); err == nil {
+			t.Error("Purge of a view succeeded")
+		}
+		d.Close()
+	}
+}
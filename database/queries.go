@@ -0,0 +1,375 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Saved queries live in one file at the root, encrypted like records when
+// the database is.
+const queriesFile = ".queries.json"
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query selects the records of a collection whose fields match Where and
+// for which Filter is true, in key order, projected to Fields and at most
+// Limit of them. Where maps a field, with dots for nested fields, to the
+// values it may have, written the way a query string would: "Karachi",
+// "42", "true" or "null". Filter is an expression as compiled by Compile.
+type Query struct {
+	Collection string              `json:"collection"`
+	Where      map[string][]string `json:"where,omitempty"`
+	Filter     string              `json:"filter,omitempty"`
+	Fields     []string            `json:"fields,omitempty"`
+	Limit      int                 `json:"limit,omitempty"`
+}
+
+type QueryResult struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (q Query) validate() error {
+	if q.Collection == "" {
+		return fmt.Errorf("%w: missing collection - no place to query", ErrInvalidQuery)
+	}
+	if err := validCollection(q.Collection); err != nil {
+		return err
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit %d must not be negative", ErrInvalidQuery, q.Limit)
+	}
+	for field := range q.Where {
+		if err := validField(field); err != nil {
+			return err
+		}
+	}
+	for _, field := range q.Fields {
+		if err := validField(field); err != nil {
+			return err
+		}
+	}
+	if q.Filter != "" {
+		if _, err := Compile(q.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validField(field string) error {
+	if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") || strings.Contains(field, "..") {
+		return fmt.Errorf("%w: field %q", ErrInvalidName, field)
+	}
+	return nil
+}
+
+// Match reports whether doc has, for every field in Where, one of its
+// values, and passes Filter. An invalid Filter matches nothing.
+func (q Query) Match(doc map[string]interface{}) bool {
+	match, err := q.matcher()
+	return err == nil && match(doc)
+}
+
+// matcher compiles the Filter of q once for matching many documents.
+func (q Query) matcher() (func(doc map[string]interface{}) bool, error) {
+	if q.Filter == "" {
+		return q.matchWhere, nil
+	}
+	e, err := Compile(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc map[string]interface{}) bool {
+		return q.matchWhere(doc) && e.Match(doc)
+	}, nil
+}
+
+func (q Query) matchWhere(doc map[string]interface{}) bool {
+	for field, values := range q.Where {
+		v, ok := getField(doc, field)
+		if !ok {
+			return false
+		}
+		s := formatField(v)
+		found := false
+		for _, want := range values {
+			if s == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// formatField renders a field value the way it would be written in a
+// query string.
+func formatField(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Find runs a query.
+func (d *Driver) Find(q Query) ([]QueryResult, error) {
+	return d.FindContext(context.Background(), q)
+}
+
+// FindContext is Find with a context to trace the operation in.
+func (d *Driver) FindContext(ctx context.Context, q Query) (results []QueryResult, err error) {
+	op := d.startOp(ctx, "find", q.Collection, "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+	withMeta := q.usesMeta()
+	keys, err := d.liveKeys(q.Collection)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	results = []QueryResult{}
+	for _, key := range keys {
+		if q.Limit > 0 && len(results) == q.Limit {
+			break
+		}
+		b, err := d.readRecord(q.Collection, key)
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		op.addBytes(int64(len(b)))
+		doc, err := d.decodeDocument(q.Collection, b)
+		if err != nil {
+			return nil, err
+		}
+		if withMeta {
+			if doc, err = d.addMeta(doc, q.Collection, key, b); err != nil {
+				return nil, err
+			}
+		}
+		if !match(doc) {
+			continue
+		}
+		if q.Fields != nil {
+			doc = project(doc, q.Fields)
+		} else if withMeta {
+			delete(doc, metaField)
+		}
+		results = append(results, QueryResult{Key: key, Value: doc})
+	}
+	return results, nil
+}
+
+// SaveQuery stores a query under name, replacing any query of that name,
+// so it can be run by name with RunQuery.
+func (d *Driver) SaveQuery(name string, q Query) error {
+	return d.SaveQueryContext(context.Background(), name, q)
+}
+
+// SaveQueryContext is SaveQuery with a context carrying the caller.
+func (d *Driver) SaveQueryContext(ctx context.Context, name string, q Query) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := validName("query", name); err != nil {
+		return err
+	}
+	if err := q.validate(); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermWrite); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(queriesFile)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	queries, err := d.loadQueries()
+	if err != nil {
+		return err
+	}
+	if old, ok := queries[name]; ok {
+		if err := d.authorizeContext(ctx, old.Collection, PermWrite); err != nil {
+			return err
+		}
+	}
+	queries[name] = q
+	return d.saveQueries(queries)
+}
+
+// SavedQuery returns the query saved under name.
+func (d *Driver) SavedQuery(name string) (Query, error) {
+	return d.SavedQueryContext(context.Background(), name)
+}
+
+func (d *Driver) SavedQueryContext(ctx context.Context, name string) (Query, error) {
+	if err := d.checkOpen(); err != nil {
+		return Query{}, err
+	}
+	queries, err := d.loadQueries()
+	if err != nil {
+		return Query{}, err
+	}
+	q, ok := queries[name]
+	if !ok {
+		return Query{}, fmt.Errorf("unable to find query named %v: %w", name, fs.ErrNotExist)
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermRead); err != nil {
+		return Query{}, err
+	}
+	return q, nil
+}
+
+// SavedQueries returns the names of the saved queries over collections the
+// caller can read, sorted.
+func (d *Driver) SavedQueries() ([]string, error) {
+	return d.SavedQueriesContext(context.Background())
+}
+
+func (d *Driver) SavedQueriesContext(ctx context.Context) ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	queries, err := d.loadQueries()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(queries))
+	for name, q := range queries {
+		if d.authorizeContext(ctx, q.Collection, PermRead) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *Driver) DeleteQuery(name string) error {
+	return d.DeleteQueryContext(context.Background(), name)
+}
+
+func (d *Driver) DeleteQueryContext(ctx context.Context, name string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(queriesFile)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	queries, err := d.loadQueries()
+	if err != nil {
+		return err
+	}
+	q, ok := queries[name]
+	if !ok {
+		return fmt.Errorf("unable to find query named %v: %w", name, fs.ErrNotExist)
+	}
+	if err := d.authorizeContext(ctx, q.Collection, PermWrite); err != nil {
+		return err
+	}
+	delete(queries, name)
+	return d.saveQueries(queries)
+}
+
+// RunQuery runs the query saved under name.
+func (d *Driver) RunQuery(name string) ([]QueryResult, error) {
+	return d.RunQueryContext(context.Background(), name)
+}
+
+func (d *Driver) RunQueryContext(ctx context.Context, name string) ([]QueryResult, error) {
+	q, err := d.SavedQueryContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return d.FindContext(ctx, q)
+}
+
+func (d *Driver) loadQueries() (map[string]Query, error) {
+	queries := make(map[string]Query)
+	b, err := d.backend.Get(queriesFile)
+	if isNotExist(err) {
+		return queries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b, err = d.decrypt(b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, fmt.Errorf("reading saved queries: %w", err)
+	}
+	return queries, nil
+}
+
+// saveQueries atomically replaces the saved queries; callers hold the
+// queries lock.
+func (d *Driver) saveQueries(queries map[string]Query) error {
+	b, err := json.MarshalIndent(queries, "", "\t")
+	if err != nil {
+		return err
+	}
+	if b, err = d.encrypt(append(b, '\n')); err != nil {
+		return err
+	}
+	tmpPath := queriesFile + ".tmp"
+	if err := d.backend.Put(tmpPath, b); err != nil {
+		return err
+	}
+	return d.backend.Rename(tmpPath, queriesFile)
+}
+
+// reencryptQueries rewrites the saved queries with the current data key.
+func (d *Driver) reencryptQueries() error {
+	mutex := d.GetOrCreateMutex(queriesFile)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := d.backend.Get(queriesFile); isNotExist(err) {
+		return nil
+	}
+	queries, err := d.loadQueries()
+	if err != nil {
+		return err
+	}
+	return d.saveQueries(queries)
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var activeKarachi = Query{
+	Collection: "users",
+	Where:      map[string][]string{"active": {"true"}, "address.city": {"Karachi", "Quetta"}},
+	Fields:     []string{"name", "age"},
+}
+
+func writeUsers(t *testing.T, d *Driver) {
+	t.Helper()
+	for key, u := range map[string]map[string]interface{}{
+		"a": {"name": "A", "active": true, "age": 30, "address": map[string]string{"city": "Karachi"}},
+		"b": {"name": "B", "active": true, "age": 25, "address": map[string]string{"city": "Lahore"}},
+		"c": {"name": "C", "active": false, "age": 41, "address": map[string]string{"city": "Karachi"}},
+		"d": {"name": "D", "active": true, "age": 52, "address": map[string]string{"city": "Quetta"}},
+		"e": {"name": "E", "active": true, "age": nil},
+	} {
+		if err := d.Write("users", key, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func resultKeys(results []QueryResult) string {
+	keys := make([]string, len(results))
+	for i, r := range results {
+		keys[i] = r.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestFind(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	writeUsers(t, d)
+
+	results, err := d.Find(activeKarachi)
+	if err != nil || resultKeys(results) != "a,d" {
+		t.Fatalf("Find = %v, %v; want a and d", results, err)
+	}
+	if v := results[0].Value; len(v) != 2 || v["name"] != "A" || formatField(v["age"]) != "30" {
+		t.Errorf("projected result = %v", v)
+	}
+
+	for _, tc := range []struct {
+		q    Query
+		want string
+	}{
+		{Query{Collection: "users", Where: map[string][]string{"active": {"true"}}, Limit: 2}, "a,b"},
+		{Query{Collection: "users", Where: map[string][]string{"age": {"null"}}}, "e"},
+		{Query{Collection: "users", Where: map[string][]string{"age": {"25", "41"}}}, "b,c"},
+		{Query{Collection: "users", Where: map[string][]string{"nothing": {"null"}}}, ""},
+		{Query{Collection: "users"}, "a,b,c,d,e"},
+	} {
+		if results, err := d.Find(tc.q); err != nil || resultKeys(results) != tc.want {
+			t.Errorf("Find(%+v) = %s, %v; want %s", tc.q, resultKeys(results), err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		q    Query
+		want error
+	}{
+		{Query{}, ErrInvalidQuery},
+		{Query{Collection: ".users"}, ErrInvalidName},
+		{Query{Collection: "users", Limit: -1}, ErrInvalidQuery},
+		{Query{Collection: "users", Where: map[string][]string{"a..b": {"1"}}}, ErrInvalidName},
+		{Query{Collection: "users", Fields: []string{"address."}}, ErrInvalidName},
+	} {
+		if _, err := d.Find(tc.q); !errors.Is(err, tc.want) {
+			t.Errorf("Find(%+v) = %v, want %v", tc.q, err, tc.want)
+		}
+	}
+
+	if !activeKarachi.Match(map[string]interface{}{"active": true, "address": map[string]interface{}{"city": "Quetta"}}) {
+		t.Error("Match = false for a matching document")
+	}
+	if activeKarachi.Match(map[string]interface{}{"active": "true"}) {
+		t.Error("Match = true for a document without the city")
+	}
+}
+
+func TestSavedQueries(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	writeUsers(t, d)
+
+	if err := d.SaveQuery("active_karachi_users", activeKarachi); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveQuery("all", Query{Collection: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := d.SavedQueries(); err != nil || strings.Join(names, ",") != "active_karachi_users,all" {
+		t.Errorf("SavedQueries = %q, %v", names, err)
+	}
+	if results, err := d.RunQuery("active_karachi_users"); err != nil || resultKeys(results) != "a,d" {
+		t.Errorf("RunQuery = %v, %v", results, err)
+	}
+
+	if err := d.SaveQuery("bad/name", activeKarachi); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("SaveQuery with a bad name = %v, want ErrInvalidName", err)
+	}
+	if err := d.SaveQuery("bad", Query{Collection: "users", Fields: []string{"a..b"}}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("SaveQuery of a bad query = %v, want ErrInvalidName", err)
+	}
+	if _, err := d.RunQuery("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RunQuery of a missing query = %v, want fs.ErrNotExist", err)
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dir, queriesFile)); err != nil || strings.Contains(string(b), "Karachi") {
+		t.Errorf("saved queries stored as %q, %v; want them encrypted", b, err)
+	}
+
+	// Saved queries are reencrypted with the records and survive
+	// reopening.
+	d = rotate(t, d, dir)
+	defer d.Close()
+	q, err := d.SavedQuery("active_karachi_users")
+	if err != nil || q.Collection != "users" || strings.Join(q.Fields, ",") != "name,age" {
+		t.Fatalf("SavedQuery after reopening = %+v, %v", q, err)
+	}
+	if err := d.DeleteQuery("active_karachi_users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteQuery("active_karachi_users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteQuery of a deleted query = %v, want fs.ErrNotExist", err)
+	}
+	if names, _ := d.SavedQueries(); strings.Join(names, ",") != "all" {
+		t.Errorf("SavedQueries after deleting = %q", names)
+	}
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+}
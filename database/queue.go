@@ -0,0 +1,359 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	queuesDir = ".queues"
+	// deadLetters is the directory of a queue holding its dead letters. It
+	// starts with a dot, so it cannot clash with a message key.
+	deadLetters = ".dead"
+
+	defaultQueueMaxAttempts = 5
+)
+
+var (
+	// ErrQueueEmpty is returned by Dequeue when no message is visible.
+	ErrQueueEmpty = errors.New("queue empty")
+	// ErrLeaseExpired is returned by Ack for a message that was delivered
+	// again after its visibility timeout ran out, and by Lease methods once
+	// the lease is lost.
+	ErrLeaseExpired = errors.New("lease expired")
+)
+
+// Queue is a durable FIFO work queue. Its messages are records kept in key
+// order, so a queue survives restarts and crashes like any collection. A
+// dequeued message stays in the queue, hidden for its visibility timeout,
+// until it is acknowledged with Ack; a message that is not is delivered
+// again, and after Options.QueueMaxAttempts deliveries moved to the
+// DeadLetters queue instead.
+//
+// Roles grant access to every queue as the collection ".queues", or to one
+// as ".queues/<name>".
+type Queue struct {
+	d    *Driver
+	name string
+	dead bool
+}
+
+// Message is a message delivered by Dequeue.
+type Message struct {
+	ID string
+	// Attempts counts the deliveries of the message, this one included.
+	Attempts int
+	Enqueued time.Time
+	// Visible is when the message is delivered again unless it is
+	// acknowledged.
+	Visible time.Time
+
+	body  interface{}
+	codec Codec
+}
+
+// Decode unmarshals the message body into v.
+func (m *Message) Decode(v interface{}) error {
+	b, err := m.codec.Marshal(m.body)
+	if err != nil {
+		return err
+	}
+	return m.codec.Unmarshal(b, v)
+}
+
+// queueRecord is a message as it is stored.
+type queueRecord struct {
+	Body     interface{} `json:"body"`
+	Enqueued time.Time   `json:"enqueued"`
+	Attempts int         `json:"attempts"`
+	Visible  time.Time   `json:"visible"`
+}
+
+func (d *Driver) Queue(name string) *Queue {
+	return &Queue{d: d, name: name}
+}
+
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// DeadLetters returns the queue of the messages that ran out of delivery
+// attempts, oldest first. Its own messages are never dead-lettered.
+func (q *Queue) DeadLetters() *Queue {
+	return &Queue{d: q.d, name: q.name, dead: true}
+}
+
+func (q *Queue) collection() (string, error) {
+	if err := validName("queue", q.name); err != nil {
+		return "", err
+	}
+	if q.dead {
+		return path.Join(queuesDir, q.name, deadLetters), nil
+	}
+	return path.Join(queuesDir, q.name), nil
+}
+
+// Enqueue adds a message with body v to the back of the queue and returns
+// its ID.
+func (q *Queue) Enqueue(v interface{}) (string, error) {
+	return q.EnqueueContext(context.Background(), v)
+}
+
+// EnqueueContext is Enqueue with a context to trace the operation in.
+func (q *Queue) EnqueueContext(ctx context.Context, v interface{}) (id string, err error) {
+	op := q.d.startOp(ctx, "enqueue", path.Join(queuesDir, q.name), "")
+	defer op.end(&err)
+
+	collection, err := q.prepare(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	mutex := q.d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	keys, err := q.keys(collection)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	id = messageID(keys, now)
+	if err := q.d.putMessage(op, collection, id, queueRecord{Body: v, Enqueued: now}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Dequeue delivers the message at the front of the queue and hides it for
+// visibility, or returns ErrQueueEmpty if every message is hidden.
+func (q *Queue) Dequeue(visibility time.Duration) (*Message, error) {
+	return q.DequeueContext(context.Background(), visibility)
+}
+
+// DequeueContext is Dequeue with a context to trace the operation in.
+func (q *Queue) DequeueContext(ctx context.Context, visibility time.Duration) (m *Message, err error) {
+	op := q.d.startOp(ctx, "dequeue", path.Join(queuesDir, q.name), "")
+	defer op.end(&err)
+
+	if visibility <= 0 {
+		return nil, fmt.Errorf("invalid visibility timeout %v - must be positive", visibility)
+	}
+	collection, err := q.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mutex := q.d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	keys, err := q.keys(collection)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, key := range keys {
+		rec, err := q.d.getMessage(op, collection, key)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Visible.After(now) {
+			continue
+		}
+		if !q.dead && rec.Attempts >= q.d.queueMaxAttempts {
+			if err := q.deadLetter(op, collection, key, rec); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rec.Attempts++
+		rec.Visible = now.Add(visibility)
+		if err := q.d.putMessage(op, collection, key, rec); err != nil {
+			return nil, err
+		}
+		return &Message{ID: key, Attempts: rec.Attempts, Enqueued: rec.Enqueued, Visible: rec.Visible, body: rec.Body, codec: q.d.codecFor(collection)}, nil
+	}
+	return nil, ErrQueueEmpty
+}
+
+// Ack removes a delivered message from the queue. It fails with
+// ErrLeaseExpired if the message was delivered again since, and with an
+// error matching fs.ErrNotExist if it is gone.
+func (q *Queue) Ack(m *Message) error {
+	return q.AckContext(context.Background(), m)
+}
+
+// AckContext is Ack with a context to trace the operation in.
+func (q *Queue) AckContext(ctx context.Context, m *Message) (err error) {
+	op := q.d.startOp(ctx, "ack", path.Join(queuesDir, q.name), m.ID)
+	defer op.end(&err)
+
+	collection, err := q.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validName("message", m.ID); err != nil {
+		return err
+	}
+
+	mutex := q.d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	rec, err := q.d.getMessage(op, collection, m.ID)
+	if err != nil {
+		return err
+	}
+	if rec.Attempts != m.Attempts {
+		return fmt.Errorf("%w: message %s was delivered again", ErrLeaseExpired, m.ID)
+	}
+	return q.d.deleteRecord(collection, m.ID, q.d.recordPath(collection, m.ID))
+}
+
+// Len returns the number of messages in the queue, hidden ones included.
+func (q *Queue) Len() (int, error) {
+	return q.LenContext(context.Background())
+}
+
+// LenContext is Len with a context carrying the caller.
+func (q *Queue) LenContext(ctx context.Context) (int, error) {
+	if err := q.d.checkOpen(); err != nil {
+		return 0, err
+	}
+	collection, err := q.collection()
+	if err != nil {
+		return 0, err
+	}
+	if err := q.d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return 0, err
+	}
+	keys, err := q.d.liveKeys(collection)
+	return len(keys), err
+}
+
+// prepare checks that the caller may use the queue, which takes every
+// permission since each operation both reads and changes it, and returns
+// its collection.
+func (q *Queue) prepare(ctx context.Context) (string, error) {
+	if err := q.d.checkWritable(); err != nil {
+		return "", err
+	}
+	collection, err := q.collection()
+	if err != nil {
+		return "", err
+	}
+	if err := q.d.authorizeContext(ctx, collection, PermAll); err != nil {
+		return "", err
+	}
+	return collection, nil
+}
+
+// keys returns the message keys in queue order. Callers hold the queue
+// lock.
+func (q *Queue) keys(collection string) ([]string, error) {
+	keys, err := q.d.liveKeys(collection)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// deadLetter moves a message to the dead letters. Callers hold the queue
+// lock, which is taken before the dead letters' lock.
+func (q *Queue) deadLetter(op *opTimer, collection, key string, rec queueRecord) error {
+	dead := path.Join(collection, deadLetters)
+	mutex := q.d.GetOrCreateMutex(dead)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	keys, err := q.keys(dead)
+	if err != nil {
+		return err
+	}
+	id := messageID(keys, time.Now())
+	if err := q.d.putMessage(op, dead, id, queueRecord{Body: rec.Body, Enqueued: rec.Enqueued, Attempts: rec.Attempts}); err != nil {
+		return err
+	}
+	if err := q.d.deleteRecord(collection, key, q.d.recordPath(collection, key)); err != nil {
+		return err
+	}
+	q.d.logEvent(slog.LevelWarn, "Message dead-lettered", slog.String("queue", q.name), slog.String("message", key),
+		slog.String("id", id), slog.Int("attempts", rec.Attempts))
+	return nil
+}
+
+// messageID returns the key for a message enqueued at now behind the
+// messages with sorted keys. Keys are zero-padded nanosecond times, so they
+// sort in queue order, and always after the last one so a clock stepping
+// back cannot reorder the queue.
+func messageID(keys []string, now time.Time) string {
+	next := now.UnixNano()
+	if len(keys) > 0 {
+		if last, err := strconv.ParseInt(keys[len(keys)-1], 10, 64); err == nil && last >= next {
+			next = last + 1
+		}
+	}
+	return fmt.Sprintf("%020d", next)
+}
+
+func (d *Driver) getMessage(op *opTimer, collection, key string) (queueRecord, error) {
+	var rec queueRecord
+	b, err := d.readRecord(collection, key)
+	if err != nil {
+		return rec, err
+	}
+	op.addBytes(int64(len(b)))
+	return rec, d.codecFor(collection).Unmarshal(b, &rec)
+}
+
+// putMessage stores a message. Callers hold the collection lock.
+func (d *Driver) putMessage(op *opTimer, collection, key string, rec queueRecord) error {
+	b, err := d.codecFor(collection).Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := d.checkRecordSize(collection, key, b); err != nil {
+		return err
+	}
+	return d.writeRecordLocked(op, collection, key, bytes.NewReader(b), time.Time{})
+}
+
+// queueCollections returns the collections of every queue and of the dead
+// letters of those that have any, which collectionPaths leaves out.
+func (d *Driver) queueCollections() ([]string, error) {
+	names, err := d.listDirs(queuesDir)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var collections []string
+	for _, name := range names {
+		collection := path.Join(queuesDir, name)
+		collections = append(collections, collection)
+		entries, err := d.backend.List(collection)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry == deadLetters+"/" {
+				collections = append(collections, path.Join(collection, deadLetters))
+			}
+		}
+	}
+	return collections, nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type queueJob struct {
+	N int `json:"n"`
+}
+
+func dequeueJob(t *testing.T, q *Queue, visibility time.Duration) (*Message, int) {
+	t.Helper()
+	m, err := q.Dequeue(visibility)
+	if err != nil {
+		t.Fatalf("Dequeue = %v", err)
+	}
+	var j queueJob
+	if err := m.Decode(&j); err != nil {
+		t.Fatal(err)
+	}
+	return m, j.N
+}
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{QueueMaxAttempts: 2, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := d.Queue("jobs")
+	for i := 0; i < 3; i++ {
+		if _, err := q.Enqueue(queueJob{N: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, n := dequeueJob(t, q, 50*time.Millisecond)
+	if n != 0 || first.Attempts != 1 {
+		t.Fatalf("first message = %d after %d attempts, want 0 after 1", n, first.Attempts)
+	}
+	second, n := dequeueJob(t, q, time.Minute)
+	if n != 1 {
+		t.Fatalf("second message = %d, want 1", n)
+	}
+	if err := q.Ack(second); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	again, _ := dequeueJob(t, q, 10*time.Millisecond)
+	if again.ID != first.ID || again.Attempts != 2 {
+		t.Fatalf("redelivered %s after %d attempts, want %s after 2", again.ID, again.Attempts, first.ID)
+	}
+	if err := q.Ack(first); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Ack of a redelivered message = %v, want ErrLeaseExpired", err)
+	}
+
+	// The first message has had its attempts, so it is dead-lettered.
+	time.Sleep(20 * time.Millisecond)
+	if _, n := dequeueJob(t, q, time.Minute); n != 2 {
+		t.Fatalf("third message = %d, want 2", n)
+	}
+	if _, err := q.Dequeue(time.Minute); !errors.Is(err, ErrQueueEmpty) {
+		t.Errorf("Dequeue of an empty queue = %v, want ErrQueueEmpty", err)
+	}
+	if n, err := q.DeadLetters().Len(); err != nil || n != 1 {
+		t.Errorf("DeadLetters().Len = %d, %v; want 1", n, err)
+	}
+	if r, err := d.Verify(); err != nil {
+		t.Fatalf("Verify = %v, %v", r, err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	q = d.Queue("jobs")
+	if n, err := q.Len(); err != nil || n != 1 {
+		t.Errorf("Len after reopening = %d, %v; want the unacknowledged message", n, err)
+	}
+	dead, n := dequeueJob(t, q.DeadLetters(), time.Minute)
+	if n != 0 || dead.Attempts != 3 {
+		t.Errorf("dead letter = %d after %d attempts, want 0 after 3", n, dead.Attempts)
+	}
+	if cs, _ := d.Collections(); len(cs) != 0 {
+		t.Errorf("Collections = %q, want queues left out", cs)
+	}
+	if _, err := d.Queue("../x").Enqueue(1); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Enqueue to an invalid queue = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestQueueConcurrent(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	q := d.Queue("jobs")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Enqueue(queueJob{N: i}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var mutex sync.Mutex
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, err := q.Dequeue(time.Minute)
+				if errors.Is(err, ErrQueueEmpty) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mutex.Lock()
+				if seen[m.ID] {
+					t.Errorf("message %s delivered twice", m.ID)
+				}
+				seen[m.ID] = true
+				mutex.Unlock()
+				if err := q.Ack(m); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 50 {
+		t.Errorf("delivered %d messages, want 50", len(seen))
+	}
+}
+
+func TestQueueReencrypt(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{MasterKey: testMasterKey, QueueMaxAttempts: 1, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := d.Queue("jobs")
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(queueJob{N: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Dequeue(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := q.Dequeue(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	d = rotate(t, d, dir)
+	defer d.Close()
+	q = d.Queue("jobs")
+	if _, n := dequeueJob(t, q.DeadLetters(), time.Minute); n != 0 {
+		t.Errorf("dead letter = %d after rotating, want 0", n)
+	}
+	if n, err := q.Len(); err != nil || n != 1 {
+		t.Errorf("Len after rotating = %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type Quota struct {
+	MaxRecords int
+	MaxBytes   int64
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxRecords > 0 || q.MaxBytes > 0
+}
+
+// usage is the record count and stored bytes of a collection or namespace.
+// It is computed once by walking the scope and then kept up to date by
+// Write and Delete.
+type usage struct {
+	records int
+	bytes   int64
+}
+
+type quotaScope struct {
+	kind  string
+	name  string
+	dir   string
+	quota Quota
+}
+
+// quotaScopes returns the quotas a write to collection is charged against:
+// the collection's own and, for namespaced collections, the tenant's.
+func (d *Driver) quotaScopes(collection string) []quotaScope {
+	var scopes []quotaScope
+	if q := d.collectionOptions(collection).Quota; q.enabled() {
+		scopes = append(scopes, quotaScope{"collection", collection, collection, q})
+	}
+	if parts := strings.Split(collection, "/"); len(parts) >= 3 && parts[0] == namespacesDir {
+		n := d.Namespace(parts[1])
+		if q := n.quota(); q.enabled() {
+			scopes = append(scopes, quotaScope{"namespace", n.name, n.dir(), q})
+		}
+	}
+	return scopes
+}
+
+// Usage returns a collection's record count and stored bytes.
+func (d *Driver) Usage(collection string) (records int, bytes int64, err error) {
+	return d.UsageContext(context.Background(), collection)
+}
+
+// UsageContext is Usage with a context carrying the caller.
+func (d *Driver) UsageContext(ctx context.Context, collection string) (records int, bytes int64, err error) {
+	if err := d.checkOpen(); err != nil {
+		return 0, 0, err
+	}
+	if collection == "" {
+		return 0, 0, fmt.Errorf("missing collection - no usage to report")
+	}
+	if err := validCollection(collection); err != nil {
+		return 0, 0, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return 0, 0, err
+	}
+
+	var u usage
+	if d.collectionOptions(collection).Quota.enabled() {
+		u, err = d.usage(collection)
+	} else {
+		u, err = d.walkUsage(collection)
+	}
+	return u.records, u.bytes, err
+}
+
+// usage returns the tracked usage of a quota scope, walking it the first
+// time. Only scopes returned by quotaScopes are kept up to date.
+func (d *Driver) usage(dir string) (usage, error) {
+	d.mutex.Lock()
+	u, ok := d.usages[dir]
+	d.mutex.Unlock()
+	if ok {
+		return *u, nil
+	}
+
+	walked, err := d.walkUsage(dir)
+	if err != nil {
+		return usage{}, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if u, ok := d.usages[dir]; ok {
+		return *u, nil
+	}
+	d.usages[dir] = &walked
+	return walked, nil
+}
+
+// walkUsage computes the usage of a collection or namespace from the
+// records stored in it. A namespace counts its sub-collections too.
+func (d *Driver) walkUsage(dir string) (usage, error) {
+	var walked usage
+	collections := []string{dir}
+	if strings.HasPrefix(dir, namespacesDir+"/") && strings.Count(dir, "/") == 1 {
+		names, err := d.listDirs(dir)
+		if err != nil && !isNotExist(err) {
+			return usage{}, err
+		}
+		collections = collections[:0]
+		for _, name := range names {
+			tree, err := d.collectionTree(path.Join(dir, name))
+			if err != nil {
+				return usage{}, err
+			}
+			collections = append(collections, path.Join(dir, name))
+			collections = append(collections, tree...)
+		}
+	}
+	for _, c := range collections {
+		files, err := d.backend.List(c)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return usage{}, err
+		}
+		for _, file := range files {
+			if _, ok := d.recordName(c, file); isDirName(file) || !ok {
+				continue
+			}
+			b, err := d.backend.Get(path.Join(c, file))
+			if err != nil {
+				return usage{}, err
+			}
+			walked.records++
+			walked.bytes += int64(len(b))
+		}
+	}
+	return walked, nil
+}
+
+// reserveQuota charges writing size bytes to the record at p against every
+// quota covering collection. The returned func undoes the charge and must be
+// called if the write then fails. Callers hold the collection lock.
+func (d *Driver) reserveQuota(collection, p string, size int64) (func(), error) {
+	scopes := d.quotaScopes(collection)
+	if len(scopes) == 0 {
+		return func() {}, nil
+	}
+
+	var delta usage
+	_, old, err := d.getRecord(p)
+	switch {
+	case isNotExist(err):
+		delta = usage{records: 1, bytes: size}
+	case err != nil:
+		return nil, err
+	default:
+		delta = usage{bytes: size - int64(len(old))}
+	}
+
+	for _, s := range scopes {
+		if _, err := d.usage(s.dir); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, s := range scopes {
+		u, ok := d.usages[s.dir]
+		if !ok {
+			continue
+		}
+		if s.quota.MaxRecords > 0 && delta.records > 0 && u.records+delta.records > s.quota.MaxRecords {
+			return nil, fmt.Errorf("%s %s: %w (%d records)", s.kind, s.name, ErrQuotaExceeded, s.quota.MaxRecords)
+		}
+		if s.quota.MaxBytes > 0 && delta.bytes > 0 && u.bytes+delta.bytes > s.quota.MaxBytes {
+			return nil, fmt.Errorf("%s %s: %w (%d bytes)", s.kind, s.name, ErrQuotaExceeded, s.quota.MaxBytes)
+		}
+	}
+	d.chargeUsage(scopes, delta)
+
+	return func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		d.chargeUsage(scopes, usage{records: -delta.records, bytes: -delta.bytes})
+	}, nil
+}
+
+// releaseQuota credits the record at p, which the caller is about to
+// delete, back to the quotas covering collection.
+func (d *Driver) releaseQuota(collection, p string) error {
+	scopes := d.quotaScopes(collection)
+	if len(scopes) == 0 {
+		return nil
+	}
+	_, old, err := d.getRecord(p)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.chargeUsage(scopes, usage{records: -1, bytes: -int64(len(old))})
+	return nil
+}
+
+// chargeUsage applies delta to scopes whose usage is loaded; callers hold
+// d.mutex.
+func (d *Driver) chargeUsage(scopes []quotaScope, delta usage) {
+	for _, s := range scopes {
+		if u, ok := d.usages[s.dir]; ok {
+			u.records += delta.records
+			u.bytes += delta.bytes
+		}
+	}
+}
+
+// forgetUsage drops the tracked usage of dir, everything below it and the
+// namespace containing it, so the next quota check walks them again.
+func (d *Driver) forgetUsage(dir string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for k := range d.usages {
+		if k == dir || strings.HasPrefix(k, dir+"/") || strings.HasPrefix(dir, k+"/") {
+			delete(d.usages, k)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuotaRecords(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"users": {Quota: Quota{MaxRecords: 2}}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, key := range []string{"a", "b"} {
+		if err := d.Write("users", key, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Write("users", "c", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Write past the quota = %v, want ErrQuotaExceeded", err)
+	}
+	if err := d.Write("users", "a", 2); err != nil {
+		t.Errorf("overwrite at the quota: %v", err)
+	}
+	if err := d.Write("orders", "c", 1); err != nil {
+		t.Errorf("Write to a collection without a quota: %v", err)
+	}
+	if err := d.Delete("users", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "c", 1); err != nil {
+		t.Errorf("Write once a record was deleted: %v", err)
+	}
+	if records, _, err := d.Usage("users"); err != nil || records != 2 {
+		t.Errorf("Usage = %d records, %v; want 2", records, err)
+	}
+}
+
+func TestQuotaBytes(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		Collections:      map[string]CollectionOptions{"notes": {Quota: Quota{MaxBytes: 100}}},
+		TTLSweepInterval: -1,
+	}
+	d, err := New(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("notes", "a", strings.Repeat("x", 40)); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	// Usage is walked again after reopening.
+	d, err = New(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, bytes, err := d.Usage("notes"); err != nil || bytes == 0 {
+		t.Fatalf("Usage = %d bytes, %v", bytes, err)
+	}
+	if err := d.Write("notes", "b", strings.Repeat("x", 80)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Write past the byte quota = %v, want ErrQuotaExceeded", err)
+	}
+	if err := d.Write("notes", "b", strings.Repeat("x", 20)); err != nil {
+		t.Errorf("Write within the byte quota: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadAllWith(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"fs": {TTLSweepInterval: -1},
+		"memory": {
+			Backend:  NewMemoryBackend(),
+			FieldKey: testFieldKey,
+			Collections: map[string]CollectionOptions{"users": {
+				Compression:     CompressionGzip,
+				EncryptedFields: []string{"n"},
+			}},
+			TTLSweepInterval: -1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if opts.Backend != nil {
+				dir = ""
+			}
+			d, err := New(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			for i := 0; i < 500; i++ {
+				if err := d.Write("users", fmt.Sprintf("k%03d", i), map[string]int{"n": i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.WriteTTL("users", "expired", map[string]int{"n": -1}, time.Nanosecond); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+
+			want, err := d.ReadAll("users")
+			if err != nil || len(want) != 500 {
+				t.Fatalf("ReadAll = %d records, %v; want 500", len(want), err)
+			}
+			for _, p := range []int{-1, 2, 8, 64} {
+				got, err := d.ReadAllWith(context.Background(), "users", ReadAllOptions{Parallelism: p})
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("ReadAllWith of parallelism %d = %d records, %v; want the %d of ReadAll in order", p, len(got), err, len(want))
+				}
+			}
+
+			if _, err := d.ReadAllWith(context.Background(), "none", ReadAllOptions{Parallelism: 4}); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("ReadAllWith of a missing collection = %v, want fs.ErrNotExist", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := d.ReadAllWith(ctx, "users", ReadAllOptions{Parallelism: 4}); err == nil {
+				t.Error("ReadAllWith with a canceled context succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Ref refers to another record from inside a document, stored as
+// {"$ref":"users/Waqar"}: the collection path, a slash and the key. Keys
+// containing a slash cannot be referred to. References in namespaced
+// records refer to records of the same namespace.
+type Ref struct {
+	Ref string `json:"$ref"`
+}
+
+func RefTo(collection, resource string) Ref {
+	return Ref{Ref: collection + "/" + resource}
+}
+
+// Target returns the collection and key the Ref refers to.
+func (r Ref) Target() (collection, resource string, err error) {
+	i := strings.LastIndexByte(r.Ref, '/')
+	if i <= 0 || i == len(r.Ref)-1 {
+		return "", "", fmt.Errorf("%w: reference %q must be a collection and a key", ErrInvalidName, r.Ref)
+	}
+	return r.Ref[:i], r.Ref[i+1:], nil
+}
+
+// ReadResolved reads a record into v like Read, with every reference in it
+// replaced by the document it refers to, up to depth references deep.
+// References left at the depth limit, ones back to a document being
+// resolved, malformed ones and ones to missing records stay as they are.
+// Unlike Read, a missing record is an error matching fs.ErrNotExist.
+// Referenced records are read with the same permissions as the record
+// itself.
+func (d *Driver) ReadResolved(collection, resource string, v interface{}, depth int) error {
+	return d.ReadResolvedContext(context.Background(), collection, resource, v, depth)
+}
+
+// ReadResolvedContext is ReadResolved with a context to trace the
+// operations in.
+func (d *Driver) ReadResolvedContext(ctx context.Context, collection, resource string, v interface{}, depth int) error {
+	r := d.newResolver(ctx, collection)
+	doc, err := r.resolveRecord(collection, resource, depth)
+	if err != nil {
+		return err
+	}
+	return r.decode(doc, v)
+}
+
+// ReadAllResolved is ReadAll with the references of every record resolved
+// as by ReadResolved. Records referred to more than once are read once.
+func (d *Driver) ReadAllResolved(collection string, depth int) ([]string, error) {
+	return d.ReadAllResolvedContext(context.Background(), collection, depth)
+}
+
+func (d *Driver) ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error) {
+	keys, err := d.KeysContext(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	r := d.newResolver(ctx, collection)
+	records := make([]string, 0, len(keys))
+	for _, key := range keys {
+		doc, err := r.resolveRecord(collection, key, depth)
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.codecFor(collection).Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, string(b))
+	}
+	return records, nil
+}
+
+// resolver resolves the references of documents read in one call, caching
+// the records it reads.
+type resolver struct {
+	d   *Driver
+	ctx context.Context
+	// namespace prefixes the collections references refer to.
+	namespace string
+	records   map[string][]byte
+	visiting  map[string]bool
+}
+
+func (d *Driver) newResolver(ctx context.Context, collection string) *resolver {
+	r := &resolver{d: d, ctx: ctx, records: make(map[string][]byte), visiting: make(map[string]bool)}
+	if base := baseCollection(collection); base != collection {
+		r.namespace = strings.TrimSuffix(collection, base)
+	}
+	return r
+}
+
+func (r *resolver) resolveRecord(collection, resource string, depth int) (interface{}, error) {
+	ref := RefTo(collection, resource).Ref
+	b, err := r.read(collection, resource)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := r.d.codecFor(collection).Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	r.visiting[ref] = true
+	defer delete(r.visiting, ref)
+	return r.resolve(doc, depth)
+}
+
+func (r *resolver) read(collection, resource string) (b []byte, err error) {
+	ref := RefTo(collection, resource).Ref
+	if b, ok := r.records[ref]; ok {
+		return b, nil
+	}
+
+	d := r.d
+	op := d.startOp(r.ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(r.ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+	b, err = d.readRecord(collection, resource)
+	if isNotExist(err) {
+		return nil, fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	op.addBytes(int64(len(b)))
+	r.records[ref] = b
+	return b, nil
+}
+
+// resolve replaces the references in v, which it may modify, with the
+// documents they refer to.
+func (r *resolver) resolve(v interface{}, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := refOf(v); ok {
+			return r.resolveRef(v, ref, depth)
+		}
+		for k, e := range v {
+			resolved, err := r.resolve(e, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+	case []interface{}:
+		for i, e := range v {
+			resolved, err := r.resolve(e, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return v, nil
+}
+
+func (r *resolver) resolveRef(v map[string]interface{}, ref Ref, depth int) (interface{}, error) {
+	collection, resource, err := ref.Target()
+	if err != nil || validCollection(collection) != nil {
+		return v, nil
+	}
+	collection = r.namespace + collection
+	if depth <= 0 || r.visiting[RefTo(collection, resource).Ref] {
+		return v, nil
+	}
+	doc, err := r.resolveRecord(collection, resource, depth-1)
+	if isNotExist(err) {
+		return v, nil
+	}
+	return doc, err
+}
+
+// refOf reports whether a decoded document is a Ref, an object whose only
+// field is a string "$ref".
+func refOf(v map[string]interface{}) (Ref, bool) {
+	if len(v) != 1 {
+		return Ref{}, false
+	}
+	s, ok := v["$ref"].(string)
+	return Ref{Ref: s}, ok
+}
+
+func (r *resolver) decode(doc interface{}, v interface{}) error {
+	b, err := r.d.codec.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return r.d.codec.Unmarshal(b, v)
+}
+
+func (n *Namespace) ReadResolved(collection, resource string, v interface{}, depth int) error {
+	c, err := n.collection(collection)
+	if err != nil {
+		return err
+	}
+	return n.d.ReadResolved(c, resource, v, depth)
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+type order struct {
+	By    map[string]interface{}   `json:"by"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+func TestRefTarget(t *testing.T) {
+	collection, key, err := RefTo("users/Waqar/orders", "o1").Target()
+	if err != nil || collection != "users/Waqar/orders" || key != "o1" {
+		t.Errorf("Target = %q, %q, %v", collection, key, err)
+	}
+	for _, bad := range []string{"", "users", "/users", "users/"} {
+		if _, _, err := (Ref{Ref: bad}).Target(); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Target of %q = %v, want ErrInvalidName", bad, err)
+		}
+	}
+}
+
+func TestReadResolved(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, w := range []struct {
+		collection, key string
+		v               interface{}
+	}{
+		{"users", "Waqar", map[string]interface{}{"name": "Waqar", "best": RefTo("users", "Ali")}},
+		{"users", "Ali", map[string]interface{}{"name": "Ali", "best": RefTo("users", "Waqar")}},
+		{"products", "p1", map[string]interface{}{"name": "pen"}},
+		{"orders", "o1", map[string]interface{}{
+			"by":    RefTo("users", "Waqar"),
+			"items": []interface{}{RefTo("products", "p1"), RefTo("products", "missing"), Ref{Ref: "bad"}},
+		}},
+	} {
+		if err := d.Write(w.collection, w.key, w.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var o order
+	if err := d.ReadResolved("orders", "o1", &o, 0); err != nil || o.By["$ref"] != "users/Waqar" {
+		t.Fatalf("ReadResolved to depth 0 = %+v, %v; want the references", o, err)
+	}
+
+	o = order{}
+	if err := d.ReadResolved("orders", "o1", &o, 1); err != nil {
+		t.Fatal(err)
+	}
+	if o.By["name"] != "Waqar" {
+		t.Errorf("by = %v, want Waqar's record", o.By)
+	}
+	if best, _ := o.By["best"].(map[string]interface{}); best["$ref"] != "users/Ali" {
+		t.Errorf("reference past the depth limit = %v, want it left", o.By["best"])
+	}
+	if len(o.Items) != 3 || o.Items[0]["name"] != "pen" || o.Items[1]["$ref"] != "products/missing" || o.Items[2]["$ref"] != "bad" {
+		t.Errorf("items = %v, want the pen and the missing and malformed references left", o.Items)
+	}
+
+	// The cycle back to Waqar is left as a reference.
+	o = order{}
+	if err := d.ReadResolved("orders", "o1", &o, 5); err != nil {
+		t.Fatal(err)
+	}
+	best, _ := o.By["best"].(map[string]interface{})
+	if back, _ := best["best"].(map[string]interface{}); best["name"] != "Ali" || back["$ref"] != "users/Waqar" {
+		t.Errorf("best = %v, want Ali referring back to Waqar", best)
+	}
+
+	var v interface{}
+	if err := d.ReadResolved("orders", "nope", &v, 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadResolved of a missing record = %v, want fs.ErrNotExist", err)
+	}
+
+	all, err := d.ReadAllResolved("users", 1)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ReadAllResolved = %q, %v", all, err)
+	}
+	for _, record := range all {
+		var u map[string]interface{}
+		if err := json.Unmarshal([]byte(record), &u); err != nil {
+			t.Fatal(err)
+		}
+		if best, _ := u["best"].(map[string]interface{}); best["name"] == nil {
+			t.Errorf("ReadAllResolved record %s, want best resolved", record)
+		}
+	}
+}
+
+func TestReadResolvedNamespace(t *testing.T) {
+	d, err := NewMemory(&Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "Waqar", map[string]string{"name": "global"}); err != nil {
+		t.Fatal(err)
+	}
+	n := d.Namespace("tenant")
+	if err := n.Write("users", "Waqar", map[string]string{"name": "tenant"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Write("orders", "o1", map[string]interface{}{"by": RefTo("users", "Waqar")}); err != nil {
+		t.Fatal(err)
+	}
+	var o order
+	if err := n.ReadResolved("orders", "o1", &o, 1); err != nil || o.By["name"] != "tenant" {
+		t.Errorf("ReadResolved in a namespace = %+v, %v; want the namespace's record", o, err)
+	}
+}
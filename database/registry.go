@@ -0,0 +1,155 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codecs, backends and extensions can be registered by name, so that
+// configuration such as dbserver's flags can pick them. Packages offering
+// one register it from an init function and programs enable it by
+// importing the package, as with database/sql drivers:
+//
+//	import _ "example.com/owndb-msgpack"
+//
+//	codec, err := database.LookupCodec("msgpack")
+//
+// The registry holds "json"; "file", "memory" and "bolt" backends; and no
+// extensions.
+
+// BackendFactory opens a backend at a location such as a path or URL.
+type BackendFactory func(location string) (Backend, error)
+
+// Extension sets up a Driver named in Options.Extensions when it opens, for
+// instance adding a hook with Instrument or starting a Watch.
+type Extension func(d *Driver) error
+
+var registry = struct {
+	sync.RWMutex
+	codecs     map[string]Codec
+	backends   map[string]BackendFactory
+	extensions map[string]Extension
+}{
+	codecs: map[string]Codec{"json": JSONCodec{}},
+	backends: map[string]BackendFactory{
+		"file":   func(location string) (Backend, error) { return NewFileBackend(location), nil },
+		"memory": func(string) (Backend, error) { return NewMemoryBackend(), nil },
+		"bolt": func(location string) (Backend, error) {
+			b, err := NewBoltBackend(location)
+			if err != nil {
+				return nil, err
+			}
+			return b, nil
+		},
+	},
+	extensions: make(map[string]Extension),
+}
+
+// RegisterCodec makes a codec available by name. It panics if the name is
+// taken or the codec is nil.
+func RegisterCodec(name string, c Codec) {
+	if c == nil {
+		panic("database: RegisterCodec codec is nil")
+	}
+	register(registry.codecs, "codec", name, c)
+}
+
+// RegisterBackend makes a backend available by name to OpenBackend. It
+// panics if the name is taken or open is nil.
+func RegisterBackend(name string, open BackendFactory) {
+	if open == nil {
+		panic("database: RegisterBackend factory is nil")
+	}
+	register(registry.backends, "backend", name, open)
+}
+
+// RegisterExtension makes an extension available by name to
+// Options.Extensions. It panics if the name is taken or ext is nil.
+func RegisterExtension(name string, ext Extension) {
+	if ext == nil {
+		panic("database: RegisterExtension extension is nil")
+	}
+	register(registry.extensions, "extension", name, ext)
+}
+
+func register[T any](m map[string]T, kind, name string, v T) {
+	registry.Lock()
+	defer registry.Unlock()
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("database: invalid %s name %q", kind, name))
+	}
+	if _, ok := m[name]; ok {
+		panic(fmt.Sprintf("database: %s %s registered twice", kind, name))
+	}
+	m[name] = v
+}
+
+func lookup[T any](m map[string]T, kind, name string) (T, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	v, ok := m[name]
+	if !ok {
+		return v, fmt.Errorf("unknown %s %q - registered: %s", kind, name, strings.Join(names(m), ", "))
+	}
+	return v, nil
+}
+
+func names[T any](m map[string]T) []string {
+	list := make([]string, 0, len(m))
+	for name := range m {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, error) {
+	return lookup(registry.codecs, "codec", name)
+}
+
+// OpenBackend opens a backend from a spec of a registered backend name,
+// optionally followed by a colon and its location, such as
+// "bolt:/var/lib/owndb.bolt" or "memory".
+func OpenBackend(spec string) (Backend, error) {
+	name, location, _ := strings.Cut(spec, ":")
+	open, err := lookup(registry.backends, "backend", name)
+	if err != nil {
+		return nil, err
+	}
+	return open(location)
+}
+
+// Codecs, Backends and Extensions return the registered names, sorted.
+func Codecs() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return names(registry.codecs)
+}
+
+func Backends() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return names(registry.backends)
+}
+
+func Extensions() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return names(registry.extensions)
+}
+
+func (d *Driver) startExtensions(exts []string) error {
+	for _, name := range exts {
+		ext, err := lookup(registry.extensions, "extension", name)
+		if err != nil {
+			return err
+		}
+		if err := ext(d); err != nil {
+			return fmt.Errorf("extension %s: %w", name, err)
+		}
+	}
+	return nil
+}
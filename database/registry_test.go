@@ -0,0 +1,112 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Registrations cannot be undone, so every run takes its own names.
+func testName(kind string) string {
+	return fmt.Sprintf("test-%s-%d", kind, time.Now().UnixNano())
+}
+
+func mustPanic(t *testing.T, what string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", what)
+		}
+	}()
+	fn()
+}
+
+func TestRegistry(t *testing.T) {
+	codec := testName("codec")
+	RegisterCodec(codec, JSONCodec{})
+	if c, err := LookupCodec(codec); err != nil || c != (JSONCodec{}) {
+		t.Errorf("LookupCodec = %v, %v", c, err)
+	}
+	if _, err := LookupCodec("nope"); err == nil || !strings.Contains(err.Error(), "json") {
+		t.Errorf("LookupCodec of an unknown codec = %v, want the registered ones listed", err)
+	}
+	if !slices.Contains(Codecs(), codec) || !slices.Contains(Codecs(), "json") {
+		t.Errorf("Codecs = %q", Codecs())
+	}
+
+	mustPanic(t, "registering a codec twice", func() { RegisterCodec(codec, JSONCodec{}) })
+	mustPanic(t, "registering a nil codec", func() { RegisterCodec(testName("codec"), nil) })
+	mustPanic(t, "registering a codec named with a colon", func() { RegisterCodec("a:b", JSONCodec{}) })
+	mustPanic(t, "registering a nil backend", func() { RegisterBackend(testName("backend"), nil) })
+	mustPanic(t, "registering an unnamed extension", func() { RegisterExtension("", func(*Driver) error { return nil }) })
+}
+
+func TestOpenBackend(t *testing.T) {
+	dir := t.TempDir()
+	for _, spec := range []string{"memory", "file:" + dir, "bolt:" + filepath.Join(dir, "db.bolt")} {
+		b, err := OpenBackend(spec)
+		if err != nil {
+			t.Errorf("OpenBackend(%q) = %v", spec, err)
+			continue
+		}
+		if err := b.Put("users/a.json", []byte("1")); err != nil {
+			t.Errorf("%s: Put = %v", spec, err)
+		}
+		if c, ok := b.(interface{ Close() error }); ok {
+			c.Close()
+		}
+	}
+	if _, err := OpenBackend("tape:/dev/st0"); err == nil {
+		t.Error("OpenBackend of an unknown backend succeeded")
+	}
+
+	name := testName("backend")
+	var location string
+	RegisterBackend(name, func(l string) (Backend, error) {
+		location = l
+		return NewMemoryBackend(), nil
+	})
+	if _, err := OpenBackend(name + ":a:b"); err != nil || location != "a:b" {
+		t.Errorf("OpenBackend = %v with location %q, want a:b", err, location)
+	}
+	if !slices.Contains(Backends(), name) {
+		t.Errorf("Backends = %q", Backends())
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	name, failing := testName("extension"), testName("extension")
+	var instrumented *Driver
+	RegisterExtension(name, func(d *Driver) error {
+		instrumented = d
+		return nil
+	})
+	RegisterExtension(failing, func(d *Driver) error { return errors.New("boom") })
+	if !slices.Contains(Extensions(), name) {
+		t.Errorf("Extensions = %q", Extensions())
+	}
+
+	d, err := NewMemory(&Options{Extensions: []string{name}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if instrumented != d {
+		t.Error("extension not run on the Driver")
+	}
+
+	if d, err := NewMemory(&Options{Extensions: []string{failing}, TTLSweepInterval: -1}); err == nil || !strings.Contains(err.Error(), "boom") {
+		if d != nil {
+			d.Close()
+		}
+		t.Errorf("New with a failing extension = %v", err)
+	}
+	if d, err := NewMemory(&Options{Extensions: []string{"nope"}, TTLSweepInterval: -1}); err == nil {
+		d.Close()
+		t.Error("New with an unknown extension succeeded")
+	}
+}
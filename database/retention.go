@@ -0,0 +1,285 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"time"
+)
+
+// Retention limits how long a collection keeps its records.
+type Retention struct {
+	// MaxAge is how old records get before ApplyRetention removes them;
+	// zero keeps them forever.
+	MaxAge time.Duration
+	// Field holds when a record was made, with dots for nested fields, as
+	// an RFC 3339 string or Unix seconds. Records without a valid one are
+	// kept. When empty, the time the record was last written is used,
+	// which needs a backend implementing StatBackend.
+	Field string
+	// Archive, if set, is the collection records are moved to rather than
+	// deleted. It needs no retention of its own, and must not archive in
+	// turn.
+	Archive string
+}
+
+func (r Retention) validate(collection string, collections map[string]CollectionOptions) error {
+	if r.MaxAge < 0 {
+		return fmt.Errorf("invalid retention %v for collection %s - must not be negative", r.MaxAge, collection)
+	}
+	if r.Field != "" {
+		if err := validField(r.Field); err != nil {
+			return fmt.Errorf("retention of collection %s: %w", collection, err)
+		}
+	}
+	if r.Archive == "" {
+		return nil
+	}
+	if err := validName("collection", r.Archive); err != nil {
+		return err
+	}
+	if r.Archive == collection {
+		return fmt.Errorf("collection %s cannot archive to itself", collection)
+	}
+	if collections[r.Archive].Retention.Archive != "" {
+		return fmt.Errorf("archive collection %s of %s must not archive in turn", r.Archive, collection)
+	}
+	return nil
+}
+
+// RetentionReport describes what ApplyRetention removed, or would have
+// removed on a dry run.
+type RetentionReport struct {
+	DryRun  bool              `json:"dryRun"`
+	Results []RetentionResult `json:"results"`
+}
+
+// RetentionResult is the outcome of a retention policy on a collection.
+type RetentionResult struct {
+	Collection string `json:"collection"`
+	// Archive is where the records went, empty when they were deleted.
+	Archive string `json:"archive,omitempty"`
+	// Expired lists the keys of the records past their retention.
+	Expired []string `json:"expired"`
+	// Skipped counts records kept for having no valid timestamp field.
+	Skipped int `json:"skipped"`
+}
+
+// RetentionTask returns a maintenance task applying the retention policies,
+// or on a dry run only logging what they would remove.
+func RetentionTask(dryRun bool) func(ctx context.Context, d *Driver) error {
+	return func(ctx context.Context, d *Driver) error {
+		report, err := d.ApplyRetention(ctx, dryRun)
+		if err != nil {
+			return err
+		}
+		for _, r := range report.Results {
+			if dryRun && len(r.Expired) > 0 {
+				d.logEvent(slog.LevelInfo, "Retention would remove records", slog.String("collection", r.Collection),
+					slog.Int("records", len(r.Expired)), slog.String("archive", r.Archive))
+			}
+		}
+		return nil
+	}
+}
+
+// ApplyRetention removes the records of every collection with a
+// CollectionOptions.Retention that are older than it allows, moving them
+// to its archive collection if it has one, in namespaces too. A dry run
+// only reports them.
+func (d *Driver) ApplyRetention(ctx context.Context, dryRun bool) (report *RetentionReport, err error) {
+	op := d.startOp(ctx, "retention", "", "")
+	defer op.end(&err)
+
+	if dryRun {
+		err = d.checkOpen()
+	} else {
+		err = d.checkWritable()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	policies := make(map[string]Retention)
+	for name, c := range d.collections {
+		if c.Retention.MaxAge > 0 {
+			policies[name] = c.Retention
+		}
+	}
+	d.mutex.Unlock()
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	namespaces, err := d.listDirs(namespacesDir)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	dirs := []string{""}
+	for _, ns := range namespaces {
+		dirs = append(dirs, path.Join(namespacesDir, ns))
+	}
+
+	report = &RetentionReport{DryRun: dryRun, Results: []RetentionResult{}}
+	now := time.Now()
+	for _, name := range names {
+		for _, dir := range dirs {
+			r, err := d.applyRetention(ctx, op, policies[name], path.Join(dir, name), dir, now, dryRun)
+			if err != nil {
+				return nil, err
+			}
+			if r.Expired != nil || r.Skipped > 0 {
+				report.Results = append(report.Results, r)
+			}
+		}
+	}
+	if !dryRun {
+		for _, r := range report.Results {
+			if len(r.Expired) > 0 {
+				d.logEvent(slog.LevelInfo, "Applied retention", slog.String("collection", r.Collection),
+					slog.Int("records", len(r.Expired)), slog.String("archive", r.Archive))
+			}
+		}
+	}
+	return report, nil
+}
+
+func (d *Driver) applyRetention(ctx context.Context, op *opTimer, policy Retention, collection, dir string, now time.Time, dryRun bool) (RetentionResult, error) {
+	r := RetentionResult{Collection: collection}
+	if policy.Archive != "" {
+		r.Archive = path.Join(dir, policy.Archive)
+	}
+	keys, err := d.liveKeys(collection)
+	if err != nil || len(keys) == 0 {
+		return r, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return r, err
+	}
+	if r.Archive != "" {
+		if err := d.authorizeContext(ctx, r.Archive, PermWrite); err != nil {
+			return r, err
+		}
+	}
+	run := retentionRun{d: d, op: op, policy: policy, collection: collection, archive: r.Archive, cutoff: now.Add(-policy.MaxAge), dryRun: dryRun}
+	if policy.Field == "" {
+		var ok bool
+		if run.stat, ok = d.backend.(StatBackend); !ok {
+			return r, fmt.Errorf("retention of collection %s needs a timestamp field - the backend cannot report when records were written", collection)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		expired, skipped, err := run.retire(key)
+		if err != nil {
+			return r, err
+		}
+		if expired {
+			r.Expired = append(r.Expired, key)
+		}
+		if skipped {
+			r.Skipped++
+		}
+	}
+	return r, nil
+}
+
+// retentionRun applies a retention policy to one collection.
+type retentionRun struct {
+	d                   *Driver
+	op                  *opTimer
+	policy              Retention
+	stat                StatBackend
+	collection, archive string
+	cutoff              time.Time
+	dryRun              bool
+}
+
+// retire checks the age of a record and, unless on a dry run, archives or
+// deletes it if it is past the cutoff.
+func (run *retentionRun) retire(key string) (expired, skipped bool, err error) {
+	d := run.d
+	mutex := d.GetOrCreateMutex(run.collection)
+	run.op.lock(mutex)
+	defer mutex.Unlock()
+
+	p := d.recordPath(run.collection, key)
+	b, err := d.readRecord(run.collection, key)
+	if isNotExist(err) {
+		// Deleted since it was listed.
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	var written time.Time
+	if run.policy.Field != "" {
+		doc, err := d.decodeDocument(run.collection, b)
+		if err != nil {
+			return false, false, err
+		}
+		v, _ := getField(doc, run.policy.Field)
+		var ok bool
+		if written, ok = timestamp(v); !ok {
+			return false, true, nil
+		}
+	} else if written, err = d.recordModTime(run.stat, p); err != nil {
+		return false, false, err
+	}
+	if !written.Before(run.cutoff) {
+		return false, false, nil
+	}
+	if run.dryRun {
+		return true, false, nil
+	}
+
+	// The archive's lock is taken with the collection's held, which is why
+	// archives must not archive in turn.
+	if run.archive != "" {
+		if err := d.checkRecordSize(run.archive, key, b); err != nil {
+			return false, false, err
+		}
+		if err := d.writeRecord(run.op, run.archive, key, bytes.NewReader(b), time.Time{}); err != nil {
+			return false, false, err
+		}
+	}
+	if err := d.deleteRecord(run.collection, key, p); err != nil && !isNotExist(err) {
+		return false, false, err
+	}
+	return true, false, nil
+}
+
+// recordModTime returns when the record at p was last written.
+func (d *Driver) recordModTime(stat StatBackend, p string) (time.Time, error) {
+	var err error
+	for _, c := range compressions {
+		var mod time.Time
+		if _, mod, err = stat.Stat(d.recordFile(p, c)); err == nil {
+			return mod, nil
+		}
+		if !isNotExist(err) {
+			return time.Time{}, err
+		}
+	}
+	return time.Time{}, err
+}
+
+// timestamp reads a field value as an RFC 3339 string or Unix seconds.
+func timestamp(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	}
+	if f, ok := toFloat(v); ok {
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyRetention(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{
+		Collections: map[string]CollectionOptions{
+			"events": {Retention: Retention{MaxAge: 24 * time.Hour, Field: "at"}},
+			"logs":   {Retention: Retention{MaxAge: time.Hour, Archive: "oldlogs"}},
+		},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for key, at := range map[string]interface{}{"rfc3339": old.Format(time.RFC3339), "unix": old.Unix(), "recent": time.Now().Unix()} {
+		if err := d.Write("events", key, map[string]interface{}{"at": at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Write("events", "undated", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"l1", "l2"} {
+		if err := d.Write("logs", key, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(dir, "logs", "l1.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Namespace("acme").Write("events", "z", map[string]interface{}{"at": old.Unix()}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.ApplyRetention(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := 0
+	for _, r := range report.Results {
+		expired += len(r.Expired)
+	}
+	if !report.DryRun || expired != 4 {
+		t.Errorf("dry run = %+v, want 4 records expired", report)
+	}
+	if keys, _ := d.Keys("events"); len(keys) != 4 {
+		t.Errorf("Keys after a dry run = %q, want every record kept", keys)
+	}
+
+	if err := RetentionTask(false)(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := d.Keys("events"); strings.Join(keys, ",") != "recent,undated" {
+		t.Errorf("events kept = %q, want recent,undated", keys)
+	}
+	if keys, _ := d.Keys("logs"); strings.Join(keys, ",") != "l2" {
+		t.Errorf("logs kept = %q, want l2", keys)
+	}
+	if keys, _ := d.Keys("oldlogs"); strings.Join(keys, ",") != "l1" {
+		t.Errorf("logs archived = %q, want l1", keys)
+	}
+	if records, _ := d.Namespace("acme").ReadAll("events"); len(records) != 0 {
+		t.Errorf("namespaced events kept = %q, want none", records)
+	}
+}
+
+func TestRetentionOptions(t *testing.T) {
+	for name, collections := range map[string]map[string]CollectionOptions{
+		"negative":          {"a": {Retention: Retention{MaxAge: -1}}},
+		"archive to itself": {"a": {Retention: Retention{MaxAge: 1, Archive: "a"}}},
+		"chained archives":  {"a": {Retention: Retention{MaxAge: 1, Archive: "b"}}, "b": {Retention: Retention{Archive: "c"}}},
+		"invalid field":     {"a": {Retention: Retention{MaxAge: 1, Field: ".at"}}},
+	} {
+		if d, err := New(t.TempDir(), &Options{Collections: collections, TTLSweepInterval: -1}); err == nil {
+			d.Close()
+			t.Errorf("New with a %s retention succeeded", name)
+		}
+	}
+
+	// Without a field, the memory backend has no times to go by.
+	d, err := NewMemory(&Options{Collections: map[string]CollectionOptions{"logs": {Retention: Retention{MaxAge: 1}}}, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("logs", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ApplyRetention(context.Background(), true); err == nil {
+		t.Error("ApplyRetention by write time on the memory backend succeeded")
+	}
+}
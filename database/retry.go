@@ -0,0 +1,109 @@
+package database
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 10 * time.Millisecond
+	defaultMaxRetryBackoff = time.Second
+)
+
+// RetryPolicy retries the storage operations of writes and deletes that
+// fail with errors going away on their own, as network file systems and
+// virus scanners holding files open cause.
+type RetryPolicy struct {
+	// Attempts is how many times an operation is tried in all; zero or one
+	// tries it once.
+	Attempts int
+	// Backoff is the wait before the first retry, doubling for each one
+	// after up to MaxBackoff; 10ms and a second by default.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable reports whether an error is worth retrying, IsTransient by
+	// default.
+	Retryable func(error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Backoff <= 0 {
+		p.Backoff = defaultRetryBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxRetryBackoff
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsTransient
+	}
+	return p
+}
+
+// retry runs fn, again as the retry policy allows while it fails with a
+// retryable error, and returns its last error.
+func (d *Driver) retry(action, p string, fn func() error) error {
+	return d.retryIf(action, p, nil, fn)
+}
+
+// retryIf is retry calling again before every retry, which gives up when
+// it returns false.
+func (d *Driver) retryIf(action, p string, again func() bool, fn func() error) error {
+	err := fn()
+	backoff := d.retryPolicy.Backoff
+	for attempt := 1; err != nil && attempt < d.retryPolicy.Attempts && d.retryPolicy.Retryable(err); attempt++ {
+		if again != nil && !again() {
+			break
+		}
+		d.logEvent(slog.LevelWarn, "Retrying storage operation", slog.String("op", action), slog.String("path", p),
+			slog.Int("attempt", attempt), slog.String("error", err.Error()))
+		select {
+		case <-time.After(backoff):
+		case <-d.done:
+			return err
+		}
+		backoff = min(2*backoff, d.retryPolicy.MaxBackoff)
+		err = fn()
+	}
+	return err
+}
+
+// deleteFile deletes a file of a record, retrying as the policy allows.
+func (d *Driver) deleteFile(p string) error {
+	return d.retry("delete", p, func() error { return d.backend.Delete(p) })
+}
+
+// replaceFile stores b at p through a temp file renamed over it, retrying
+// both as the policy allows. A failed write leaves p as it was.
+func (d *Driver) replaceFile(p string, b []byte) error {
+	tmpPath := p + ".tmp"
+	err := d.retry("write", tmpPath, func() error { return d.backend.Put(tmpPath, b) })
+	if err == nil {
+		err = d.retry("rename", tmpPath, func() error { return d.backend.Rename(tmpPath, p) })
+	}
+	if err != nil {
+		d.backend.Delete(tmpPath)
+	}
+	return d.noSpace(p, err)
+}
+
+// rewinder returns a function winding a record being written from r back
+// to where it was, resetting counted to read it again. It reports false
+// for a reader it cannot seek, as the streams handed to WriteRaw.
+func (d *Driver) rewinder(collection, resource string, r io.Reader, counted *countingReader) func() bool {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return func() bool { return false }
+	}
+	start, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return func() bool { return false }
+	}
+	return func() bool {
+		if _, err := s.Seek(start, io.SeekStart); err != nil {
+			return false
+		}
+		counted.r, counted.n = d.limitRecord(collection, resource, r), 0
+		return true
+	}
+}
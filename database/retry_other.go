@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package database
+
+// IsTransient reports whether err is a storage error that may not happen
+// again; none are known on this platform.
+func IsTransient(err error) bool {
+	return false
+}
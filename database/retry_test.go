@@ -0,0 +1,166 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky storage")
+
+// flakyBackend fails the next operations it was told to, as a network file
+// system having a bad moment.
+type flakyBackend struct {
+	Backend
+	mutex sync.Mutex
+	fails map[string]int
+}
+
+func newFlakyBackend() *flakyBackend {
+	return &flakyBackend{Backend: NewMemoryBackend(), fails: make(map[string]int)}
+}
+
+func (f *flakyBackend) failNext(op string, n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.fails[op] = n
+}
+
+func (f *flakyBackend) fail(op string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.fails[op] > 0 {
+		f.fails[op]--
+		return errFlaky
+	}
+	return nil
+}
+
+func (f *flakyBackend) Put(p string, b []byte) error {
+	if err := f.fail("put"); err != nil {
+		return err
+	}
+	return f.Backend.Put(p, b)
+}
+
+func (f *flakyBackend) Rename(from, to string) error {
+	if err := f.fail("rename"); err != nil {
+		return err
+	}
+	return f.Backend.Rename(from, to)
+}
+
+func (f *flakyBackend) Delete(p string) error {
+	// Only records, so the clean-up of temp files is not counted.
+	if strings.HasSuffix(p, ".json") || strings.HasSuffix(p, ".json.gz") {
+		if err := f.fail("delete"); err != nil {
+			return err
+		}
+	}
+	return f.Backend.Delete(p)
+}
+
+func openFlaky(t *testing.T, collection CollectionOptions) (*Driver, *flakyBackend) {
+	t.Helper()
+	b := newFlakyBackend()
+	d, err := New("", &Options{
+		Backend:          b,
+		FieldKey:         testFieldKey,
+		Retry:            RetryPolicy{Attempts: 4, Backoff: time.Millisecond, Retryable: func(err error) bool { return errors.Is(err, errFlaky) }},
+		Collections:      map[string]CollectionOptions{"users": collection},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, b
+}
+
+func TestRetry(t *testing.T) {
+	for name, collection := range map[string]CollectionOptions{
+		"plain":            {},
+		"compressed":       {Compression: CompressionGzip},
+		"encrypted fields": {EncryptedFields: []string{"name"}},
+		"both":             {Compression: CompressionGzip, EncryptedFields: []string{"name"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, b := openFlaky(t, collection)
+			long := strings.Repeat("waqar", 10000)
+			b.failNext("put", 3)
+			b.failNext("rename", 3)
+			if err := d.Write("users", "a", map[string]string{"name": long}); err != nil {
+				t.Fatalf("Write failing three times in four attempts = %v", err)
+			}
+			var v map[string]string
+			if err := d.Read("users", "a", &v); err != nil || v["name"] != long {
+				t.Fatalf("Read of a retried write = %d bytes, %v", len(v["name"]), err)
+			}
+
+			b.failNext("put", 4)
+			if err := d.Write("users", "b", map[string]string{"name": "x"}); !errors.Is(err, errFlaky) {
+				t.Errorf("Write failing every attempt = %v, want the last error", err)
+			}
+			b.failNext("put", 0)
+
+			b.failNext("delete", 2)
+			if err := d.Delete("users", "a"); err != nil {
+				t.Fatalf("Delete failing twice = %v", err)
+			}
+			if keys, _ := d.Keys("users"); len(keys) != 0 {
+				t.Errorf("Keys after the retried delete = %q, want none", keys)
+			}
+		})
+	}
+}
+
+func TestRetryStream(t *testing.T) {
+	// A stream read once cannot be retried, unless it was buffered to
+	// encrypt its fields.
+	for name, collection := range map[string]CollectionOptions{
+		"plain":            {},
+		"encrypted fields": {EncryptedFields: []string{"name"}},
+	} {
+		d, b := openFlaky(t, collection)
+		b.failNext("put", 1)
+		pr, pw := io.Pipe()
+		go func() {
+			pw.Write([]byte(`{"name":"ada"}`))
+			pw.Close()
+		}()
+		err := d.WriteRaw("users", "s", pr)
+		if collection.EncryptedFields == nil && !errors.Is(err, errFlaky) {
+			t.Errorf("WriteRaw of a %s stream failing once = %v, want no retry", name, err)
+		}
+		if collection.EncryptedFields != nil && err != nil {
+			t.Errorf("WriteRaw of a %s stream failing once = %v, want it retried", name, err)
+		}
+	}
+
+	// A seekable one is wound back.
+	d, b := openFlaky(t, CollectionOptions{Compression: CompressionGzip})
+	b.failNext("put", 2)
+	if err := d.WriteRaw("users", "s", strings.NewReader(`{"name":"ada"}`)); err != nil {
+		t.Fatalf("WriteRaw of a seekable stream failing twice = %v", err)
+	}
+	var v map[string]string
+	if err := d.Read("users", "s", &v); err != nil || v["name"] != "ada" {
+		t.Errorf("Read of a retried stream = %v, %v", v, err)
+	}
+}
+
+func TestRetryDefault(t *testing.T) {
+	b := newFlakyBackend()
+	d, err := New("", &Options{Backend: b, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	b.failNext("rename", 1)
+	if err := d.Write("users", "a", 1); !errors.Is(err, errFlaky) {
+		t.Errorf("Write without a retry policy = %v, want the first error", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package database
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsTransient reports whether err is a storage error that may not happen
+// again: a busy file, an interrupted system call or a resource that is
+// temporarily unavailable.
+func IsTransient(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN)
+}
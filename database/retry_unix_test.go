@@ -0,0 +1,20 @@
+//go:build unix
+
+package database
+
+import (
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.EINTR, syscall.EAGAIN} {
+		if err := (&fs.PathError{Op: "open", Path: "x", Err: errno}); !IsTransient(err) {
+			t.Errorf("IsTransient(%v) = false", err)
+		}
+	}
+	if IsTransient(&fs.PathError{Op: "open", Path: "x", Err: syscall.ENOENT}) {
+		t.Error("IsTransient of a missing file = true")
+	}
+}
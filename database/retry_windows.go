@@ -0,0 +1,18 @@
+//go:build windows
+
+package database
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// IsTransient reports whether err is a storage error that may not happen
+// again: a file another process has open or locked, as virus scanners and
+// indexers do for a moment after a file is written, which Windows also
+// reports as access being denied.
+func IsTransient(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION) ||
+		errors.Is(err, windows.ERROR_ACCESS_DENIED)
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// ErrConflict is returned by WriteIfRevision and DeleteIfRevision when the
+// record is not at the revision the caller expected.
+var ErrConflict = errors.New("revision conflict")
+
+// A revision names the contents of a record: it is a hash of the decoded
+// record, so it changes with every write that changes the record and
+// survives reencryption or recompression. The revision of a missing record
+// is "".
+func revision(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// currentRevision returns the revision of a record, or "" if it is
+// missing. Callers hold the collection lock.
+func (d *Driver) currentRevision(collection, resource string) (string, error) {
+	b, err := d.readRecord(collection, resource)
+	if isNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return revision(b), nil
+}
+
+// ReadRevision reads a record as Read does and returns its revision, for a
+// later WriteIfRevision or DeleteIfRevision. Unlike Read, it fails with an
+// error matching fs.ErrNotExist if the record is missing.
+func (d *Driver) ReadRevision(collection, resource string, v interface{}) (string, error) {
+	return d.ReadRevisionContext(context.Background(), collection, resource, v)
+}
+
+// ReadRevisionContext is ReadRevision with a context to trace the operation
+// in.
+func (d *Driver) ReadRevisionContext(ctx context.Context, collection, resource string, v interface{}) (rev string, err error) {
+	op := d.startOp(ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return "", err
+	}
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return "", fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return "", err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return "", err
+	}
+
+	b, err := d.readRecord(collection, resource)
+	if isNotExist(err) {
+		return "", fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+	}
+	if err != nil {
+		return "", err
+	}
+	op.addBytes(int64(len(b)))
+	if err := d.codecFor(collection).Unmarshal(b, v); err != nil {
+		return "", err
+	}
+	return revision(b), nil
+}
+
+// WriteIfRevision replaces a record as Write does, provided it is still at
+// revision rev, and returns its new revision. An empty rev only creates a
+// record that does not exist yet. It fails with ErrConflict otherwise, so
+// of several clients updating a record they each read, only the first
+// succeeds and the others can read it again and retry.
+func (d *Driver) WriteIfRevision(collection, resource string, v interface{}, rev string) (string, error) {
+	return d.WriteIfRevisionContext(context.Background(), collection, resource, v, rev)
+}
+
+// WriteIfRevisionContext is WriteIfRevision with a context to trace the
+// operation in.
+func (d *Driver) WriteIfRevisionContext(ctx context.Context, collection, resource string, v interface{}, rev string) (newRev string, err error) {
+	op := d.startOp(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return "", err
+	}
+	if collection == "" {
+		return "", fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return "", fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return "", err
+	}
+	if err := d.checkView(collection); err != nil {
+		return "", err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return "", err
+	}
+
+	b, err := d.codecFor(collection).Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if err := d.checkRecordSize(collection, resource, b); err != nil {
+		return "", err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if err := d.checkRevision(collection, resource, rev); err != nil {
+		return "", err
+	}
+	if err := d.writeRecordLocked(op, collection, resource, bytes.NewReader(b), time.Time{}); err != nil {
+		return "", err
+	}
+	// Read back rather than hashed as written: field encryption can change
+	// how the record reads.
+	return d.currentRevision(collection, resource)
+}
+
+// DeleteIfRevision deletes a record as Delete does, provided it is still at
+// revision rev, and fails with ErrConflict otherwise.
+func (d *Driver) DeleteIfRevision(collection, resource, rev string) error {
+	return d.DeleteIfRevisionContext(context.Background(), collection, resource, rev)
+}
+
+// DeleteIfRevisionContext is DeleteIfRevision with a context to trace the
+// operation in.
+func (d *Driver) DeleteIfRevisionContext(ctx context.Context, collection, resource, rev string) (err error) {
+	op := d.startOp(ctx, "delete", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - nothing to delete")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to delete record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if err := d.checkDelete(collection); err != nil {
+		return err
+	}
+	if err := d.checkRevision(collection, resource, rev); err != nil {
+		return err
+	}
+	return d.deleteRecord(collection, resource, d.recordPath(collection, resource))
+}
+
+// checkRevision fails with ErrConflict unless a record is at revision rev.
+// Callers hold the collection lock.
+func (d *Driver) checkRevision(collection, resource, rev string) error {
+	cur, err := d.currentRevision(collection, resource)
+	if err != nil {
+		return err
+	}
+	if cur == rev {
+		return nil
+	}
+	if cur == "" {
+		return fmt.Errorf("%w: %s/%s does not exist", ErrConflict, collection, resource)
+	}
+	return fmt.Errorf("%w: %s/%s is at revision %s", ErrConflict, collection, resource, cur)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+)
+
+type tally struct {
+	N int `json:"n"`
+}
+
+func TestRevisions(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var v tally
+	if _, err := d.ReadRevision("tallies", "a", &v); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadRevision of a missing record = %v, want fs.ErrNotExist", err)
+	}
+	rev, err := d.WriteIfRevision("tallies", "a", tally{N: 1}, "")
+	if err != nil || rev == "" {
+		t.Fatalf("WriteIfRevision creating = %q, %v", rev, err)
+	}
+	if _, err := d.WriteIfRevision("tallies", "a", tally{N: 1}, ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteIfRevision creating an existing record = %v, want ErrConflict", err)
+	}
+	if got, err := d.ReadRevision("tallies", "a", &v); err != nil || got != rev || v.N != 1 {
+		t.Errorf("ReadRevision = %q, %+v, %v; want %q", got, v, err, rev)
+	}
+
+	next, err := d.WriteIfRevision("tallies", "a", tally{N: 2}, rev)
+	if err != nil || next == rev {
+		t.Fatalf("WriteIfRevision = %q, %v; want a new revision", next, err)
+	}
+	if _, err := d.WriteIfRevision("tallies", "a", tally{N: 3}, rev); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteIfRevision at a stale revision = %v, want ErrConflict", err)
+	}
+	// Writing the same contents keeps the revision.
+	if err := d.Write("tallies", "a", tally{N: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := d.ReadRevision("tallies", "a", &v); got != next {
+		t.Errorf("revision after rewriting the same record = %q, want %q", got, next)
+	}
+
+	if err := d.DeleteIfRevision("tallies", "a", rev); !errors.Is(err, ErrConflict) {
+		t.Errorf("DeleteIfRevision at a stale revision = %v, want ErrConflict", err)
+	}
+	if err := d.DeleteIfRevision("tallies", "a", next); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteIfRevision("tallies", "a", next); !errors.Is(err, ErrConflict) {
+		t.Errorf("DeleteIfRevision of a deleted record = %v, want ErrConflict", err)
+	}
+}
+
+func TestRevisionsConcurrent(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("tallies", "a", tally{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every increment is retried until it wins, so none is lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var v tally
+				rev, err := d.ReadRevision("tallies", "a", &v)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				v.N++
+				_, err = d.WriteIfRevision("tallies", "a", v, rev)
+				if err == nil {
+					return
+				}
+				if !errors.Is(err, ErrConflict) {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	var v tally
+	if err := d.Read("tallies", "a", &v); err != nil || v.N != 20 {
+		t.Errorf("Read = %+v, %v; want 20 increments", v, err)
+	}
+}
+
+func TestRevisionSurvivesReencrypt(t *testing.T) {
+	dir := t.TempDir()
+	d := openEncrypted(t, dir, testMasterKey)
+	rev, err := d.WriteIfRevision("tallies", "a", tally{N: 1}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = rotate(t, d, dir)
+	defer d.Close()
+	var v tally
+	if got, err := d.ReadRevision("tallies", "a", &v); err != nil || got != rev {
+		t.Errorf("ReadRevision after rotating = %q, %v; want %q", got, err, rev)
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a maintenance job next runs.
+type Schedule interface {
+	// Next returns the first run time after t, or the zero time if there
+	// is none.
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule holds one bit per allowed value of each field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// A day matches either restricted day field, as in cron.
+	domAny, dowAny bool
+}
+
+// cronSearchYears bounds the search for the next run of a schedule that
+// matches very rarely, like February 29 on a Monday.
+const cronSearchYears = 30
+
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses "@every <duration>", @hourly, @daily, @weekly,
+// @monthly or a five-field cron expression: minute, hour, day of month,
+// month and day of week (0 or 7 is Sunday), each "*", a value, a range
+// "a-b" or a comma-separated list of them, optionally stepped with "/n".
+// Cron times are in the local time zone.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if every <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q - interval must be positive", spec)
+		}
+		return everySchedule(every), nil
+	}
+	if expr, ok := cronMacros[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q - want 5 cron fields or @every <duration>", spec)
+	}
+	c := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q - it never runs", spec)
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loText)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiText); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiText)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 10, 15, 30, 0, time.Local) // a Wednesday
+	for _, tc := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 90s", start.Add(90 * time.Second)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{"@weekly", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{"30 3 * * *", time.Date(2024, time.February, 1, 3, 30, 0, 0, time.Local)},
+		{"*/20 * * * *", time.Date(2024, time.January, 31, 10, 20, 0, 0, time.Local)},
+		{"0 9-17/4 * * *", time.Date(2024, time.January, 31, 13, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.Local)},
+		// Either restricted day field matches, as in cron.
+		{"0 0 15 * 5", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.Local)},
+	} {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) = %v", tc.spec, err)
+			continue
+		}
+		if next := s.Next(start); !next.Equal(tc.next) {
+			t.Errorf("%q: Next = %v, want %v", tc.spec, next, tc.next)
+		}
+	}
+
+	for _, spec := range []string{
+		"", "@every", "@every -1m", "@every soon", "@yearly",
+		"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *",
+		"0 0 31 2 *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Seed loads fixtures into the database: every file <key>.json in a
+// directory of fsys is a record of the collection named by the directory's
+// path, so "users/Waqar.json" is the record Waqar of users and
+// "users/Waqar/orders/1.json" one of its sub-collection. Files starting
+// with a dot or not ending in .json are ignored. Records that exist
+// already are left alone, so seeding at every start is safe and keeps
+// changes made since. Seed returns how many records it wrote.
+func (d *Driver) Seed(fsys fs.FS) (int, error) {
+	return d.seed(context.Background(), fsys, false)
+}
+
+// SeedIfEmpty is Seed only writing to collections without any records, so
+// records deleted since they were seeded stay deleted.
+func (d *Driver) SeedIfEmpty(fsys fs.FS) (int, error) {
+	return d.seed(context.Background(), fsys, true)
+}
+
+func (d *Driver) seed(ctx context.Context, fsys fs.FS, ifEmpty bool) (n int, err error) {
+	op := d.startOp(ctx, "seed", "", "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	// The fixtures of each collection, by key.
+	fixtures := make(map[string]map[string]string)
+	err = fs.WalkDir(fsys, ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		key, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			return nil
+		}
+		collection := path.Dir(p)
+		if collection == "." {
+			return fmt.Errorf("fixture %s is not in a collection directory", p)
+		}
+		if err := validCollection(collection); err != nil {
+			return fmt.Errorf("fixture %s: %w", p, err)
+		}
+		if err := validName("key", key); err != nil {
+			return fmt.Errorf("fixture %s: %w", p, err)
+		}
+		if fixtures[collection] == nil {
+			fixtures[collection] = make(map[string]string)
+		}
+		fixtures[collection][key] = p
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	collections := make([]string, 0, len(fixtures))
+	for collection := range fixtures {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		written, err := d.seedCollection(ctx, op, fsys, collection, fixtures[collection], ifEmpty)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+	d.logEvent(slog.LevelInfo, "Seeded database", slog.Int("records", n), slog.Int("collections", len(collections)))
+	return n, nil
+}
+
+func (d *Driver) seedCollection(ctx context.Context, op *opTimer, fsys fs.FS, collection string, fixtures map[string]string, ifEmpty bool) (int, error) {
+	if err := d.checkView(collection); err != nil {
+		return 0, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return 0, err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	if ifEmpty {
+		keys, err := d.liveKeys(collection)
+		if err != nil || len(keys) > 0 {
+			return 0, err
+		}
+	}
+	keys := make([]string, 0, len(fixtures))
+	for key := range fixtures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	n := 0
+	for _, key := range keys {
+		if _, err := d.readRecord(collection, key); err == nil {
+			continue
+		} else if !isNotExist(err) {
+			return n, err
+		}
+
+		raw, err := fs.ReadFile(fsys, fixtures[key])
+		if err != nil {
+			return n, err
+		}
+		// Decoded rather than copied, so the record is stored with the
+		// Driver's codec.
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return n, fmt.Errorf("fixture %s: %w", fixtures[key], err)
+		}
+		b, err := d.codecFor(collection).Marshal(v)
+		if err != nil {
+			return n, err
+		}
+		if err := d.checkRecordSize(collection, key, b); err != nil {
+			return n, err
+		}
+		if err := d.writeRecordLocked(op, collection, key, bytes.NewReader(b), time.Time{}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
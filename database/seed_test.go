@@ -0,0 +1,76 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testFixtures = fstest.MapFS{
+	"users/waqar.json":          {Data: []byte(`{"name":"Waqar","age":30}`)},
+	"users/ali.json":            {Data: []byte(`{"name":"Ali","id":12345678901234567890}`)},
+	"users/waqar/orders/1.json": {Data: []byte(`{"total":5}`)},
+	"users/README.md":           {Data: []byte(`not a fixture`)},
+	".git/config.json":          {Data: []byte(`not a fixture`)},
+	"settings/site.json":        {Data: []byte(`"hello"`)},
+}
+
+func TestSeed(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if n, err := d.Seed(testFixtures); err != nil || n != 4 {
+		t.Fatalf("Seed = %d, %v; want 4 records", n, err)
+	}
+	var order map[string]int
+	if err := d.Read("users/waqar/orders", "1", &order); err != nil || order["total"] != 5 {
+		t.Errorf("seeded sub-collection record = %v, %v", order, err)
+	}
+	// Fixtures are stored as they are, numbers too large for a float too.
+	var b bytes.Buffer
+	if err := d.ReadTo("users", "ali", &b); err != nil || !strings.Contains(b.String(), "12345678901234567890") {
+		t.Errorf("seeded record = %s, %v", b.String(), err)
+	}
+
+	// Seeding again keeps the changes made since.
+	if err := d.Write("users", "waqar", map[string]int{"age": 31}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.Seed(testFixtures); err != nil || n != 0 {
+		t.Errorf("second Seed = %d, %v; want none written", n, err)
+	}
+	var v map[string]interface{}
+	if err := d.Read("users", "waqar", &v); err != nil || v["age"] != 31.0 {
+		t.Errorf("changed record after seeding = %v, %v", v, err)
+	}
+
+	if err := d.Delete("users", "ali"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.SeedIfEmpty(testFixtures); err != nil || n != 0 {
+		t.Errorf("SeedIfEmpty of collections with records = %d, %v; want none written", n, err)
+	}
+	if n, err := d.Seed(testFixtures); err != nil || n != 1 {
+		t.Errorf("Seed after a delete = %d, %v; want the record written again", n, err)
+	}
+}
+
+func TestSeedErrors(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for name, fsys := range map[string]fstest.MapFS{
+		"outside a collection": {"x.json": {Data: []byte(`1`)}},
+		"invalid JSON":         {"c/x.json": {Data: []byte(`{`)}},
+	} {
+		if _, err := d.Seed(fsys); err == nil {
+			t.Errorf("Seed of a fixture %s succeeded", name)
+		}
+	}
+}
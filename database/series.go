@@ -0,0 +1,456 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Time series live under their own directory, one file of JSON lines per
+// UTC day, whatever the codec. Appending adds a line to the day's file and
+// retention deletes whole days. In encrypted databases every line is sealed
+// on its own, so appending never rewrites a file.
+const (
+	seriesDir       = ".series"
+	partitionExt    = ".jsonl"
+	partitionLayout = "2006-01-02"
+)
+
+// Point is a measurement in a time series.
+type Point struct {
+	Time  time.Time         `json:"time"`
+	Value float64           `json:"value"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+func validSeries(series string) error {
+	return validName("series", series)
+}
+
+func seriesCollection(series string) string {
+	return path.Join(seriesDir, series)
+}
+
+func partitionPath(series string, t time.Time) string {
+	return path.Join(seriesDir, series, t.UTC().Format(partitionLayout)+partitionExt)
+}
+
+// Append adds a point to a time series, creating the series if need be.
+// Points may be appended in any order. Roles grant access to every series
+// as the collection ".series", or to one as ".series/<name>".
+func (d *Driver) Append(series string, p Point) error {
+	return d.AppendContext(context.Background(), series, p)
+}
+
+// AppendContext is Append with a context to trace the operation in.
+func (d *Driver) AppendContext(ctx context.Context, series string, p Point) (err error) {
+	op := d.startOp(ctx, "append", seriesCollection(series), "")
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := validSeries(series); err != nil {
+		return err
+	}
+	if p.Time.IsZero() {
+		return fmt.Errorf("missing time - unable to append point")
+	}
+	if err := d.authorizeContext(ctx, seriesCollection(series), PermWrite); err != nil {
+		return err
+	}
+
+	line, err := d.encodePoint(p)
+	if err != nil {
+		return err
+	}
+	op.addBytes(int64(len(line)))
+
+	mutex := d.GetOrCreateMutex(seriesCollection(series))
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	file := partitionPath(series, p.Time)
+	if ab, ok := d.backend.(AppendBackend); ok {
+		return ab.Append(file, line)
+	}
+	b, err := d.backend.Get(file)
+	if err != nil && !isNotExist(err) {
+		return err
+	}
+	return d.backend.Put(file, append(b, line...))
+}
+
+// Range returns the points of a time series from from up to but not
+// including to, oldest first. A missing series has no points.
+func (d *Driver) Range(series string, from, to time.Time) ([]Point, error) {
+	return d.RangeContext(context.Background(), series, from, to)
+}
+
+// RangeContext is Range with a context to trace the operation in.
+func (d *Driver) RangeContext(ctx context.Context, series string, from, to time.Time) (points []Point, err error) {
+	op := d.startOp(ctx, "range", seriesCollection(series), "")
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := validSeries(series); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, seriesCollection(series), PermRead); err != nil {
+		return nil, err
+	}
+
+	mutex := d.GetOrCreateMutex(seriesCollection(series))
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	days, err := d.partitions(series)
+	if err != nil {
+		return nil, err
+	}
+	points = []Point{}
+	for _, day := range days {
+		if !day.Before(to) || !day.Add(24*time.Hour).After(from) {
+			continue
+		}
+		b, err := d.backend.Get(partitionPath(series, day))
+		if err != nil {
+			return nil, err
+		}
+		op.addBytes(int64(len(b)))
+		ps, err := d.decodePartition(series, b)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if !p.Time.Before(from) && p.Time.Before(to) {
+				points = append(points, p)
+			}
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+// Rollup is Range downsampled with Downsample.
+func (d *Driver) Rollup(series string, from, to time.Time, interval time.Duration, fn string) ([]Point, error) {
+	return d.RollupContext(context.Background(), series, from, to, interval, fn)
+}
+
+// RollupContext is Rollup with a context to trace the read in.
+func (d *Driver) RollupContext(ctx context.Context, series string, from, to time.Time, interval time.Duration, fn string) ([]Point, error) {
+	if _, err := downsampler(fn); err != nil {
+		return nil, err
+	}
+	points, err := d.RangeContext(ctx, series, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return Downsample(points, interval, fn)
+}
+
+// Downsample combines the points falling in each interval, counted from
+// the zero time, into one point at its start with fn - "count", "sum",
+// "avg", "min", "max", "first" or "last" - of their values, oldest first.
+// Tags are dropped. The points must be sorted by time.
+func Downsample(points []Point, interval time.Duration, fn string) ([]Point, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %v - must be positive", interval)
+	}
+	combine, err := downsampler(fn)
+	if err != nil {
+		return nil, err
+	}
+	out := []Point{}
+	for start := 0; start < len(points); {
+		bucket := points[start].Time.Truncate(interval)
+		end := start + 1
+		for end < len(points) && points[end].Time.Truncate(interval).Equal(bucket) {
+			end++
+		}
+		out = append(out, Point{Time: bucket, Value: combine(points[start:end])})
+		start = end
+	}
+	return out, nil
+}
+
+func downsampler(fn string) (func([]Point) float64, error) {
+	switch fn {
+	case "count":
+		return func(ps []Point) float64 { return float64(len(ps)) }, nil
+	case "sum", "avg":
+		return func(ps []Point) float64 {
+			var sum float64
+			for _, p := range ps {
+				sum += p.Value
+			}
+			if fn == "avg" {
+				return sum / float64(len(ps))
+			}
+			return sum
+		}, nil
+	case "min", "max":
+		return func(ps []Point) float64 {
+			v := ps[0].Value
+			for _, p := range ps[1:] {
+				if fn == "min" {
+					v = math.Min(v, p.Value)
+				} else {
+					v = math.Max(v, p.Value)
+				}
+			}
+			return v
+		}, nil
+	case "first":
+		return func(ps []Point) float64 { return ps[0].Value }, nil
+	case "last":
+		return func(ps []Point) float64 { return ps[len(ps)-1].Value }, nil
+	}
+	return nil, fmt.Errorf("unknown downsampling %q - use count, sum, avg, min, max, first or last", fn)
+}
+
+// SeriesNames returns the time series, sorted.
+func (d *Driver) SeriesNames() ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	names, err := d.listDirs(seriesDir)
+	if isNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DropSeries deletes a time series with all its points.
+func (d *Driver) DropSeries(series string) error {
+	return d.DropSeriesContext(context.Background(), series)
+}
+
+// DropSeriesContext is DropSeries with a context carrying the caller.
+func (d *Driver) DropSeriesContext(ctx context.Context, series string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if err := validSeries(series); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, seriesCollection(series), PermDelete); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(seriesCollection(series))
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := d.backend.Delete(seriesCollection(series)); err != nil {
+		if isNotExist(err) {
+			return fmt.Errorf("unable to find series named %v: %w", series, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// PruneSeries deletes the days of every time series that ended more than
+// Options.SeriesRetention ago and returns how many it deleted. It runs with
+// the expired record sweeper.
+func (d *Driver) PruneSeries() (int, error) {
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+	if d.seriesRetention <= 0 {
+		return 0, nil
+	}
+	names, err := d.SeriesNames()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-d.seriesRetention)
+	pruned := 0
+	for _, series := range names {
+		n, err := d.pruneSeries(series, cutoff)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+func (d *Driver) pruneSeries(series string, cutoff time.Time) (int, error) {
+	mutex := d.GetOrCreateMutex(seriesCollection(series))
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	days, err := d.partitions(series)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, day := range days {
+		if day.Add(24 * time.Hour).After(cutoff) {
+			break
+		}
+		if err := d.backend.Delete(partitionPath(series, day)); err != nil && !isNotExist(err) {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// partitions returns the days a series has points in, sorted. Callers hold
+// the series lock.
+func (d *Driver) partitions(series string) ([]time.Time, error) {
+	files, err := d.backend.List(seriesCollection(series))
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var days []time.Time
+	for _, file := range files {
+		stem, ok := strings.CutSuffix(file, partitionExt)
+		if !ok {
+			continue
+		}
+		day, err := time.Parse(partitionLayout, stem)
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// encodePoint returns the line storing p, sealed and base64-encoded when
+// the database is encrypted. Plain lines are JSON objects, so they never
+// start like base64.
+func (d *Driver) encodePoint(p Point) ([]byte, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if d.keys != nil {
+		sealed, err := d.encrypt(b)
+		if err != nil {
+			return nil, err
+		}
+		b = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+	return append(b, '\n'), nil
+}
+
+// decodePartition parses the lines of a partition. A line that cannot be
+// parsed, which is what a crash in the middle of an append leaves behind,
+// is skipped with a warning.
+func (d *Driver) decodePartition(series string, b []byte) ([]Point, error) {
+	var points []Point
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		p, err := d.decodePoint(line)
+		if err != nil {
+			d.logEvent(slog.LevelWarn, "Skipped a damaged series point", slog.String("series", series), slog.Any("error", err))
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func (d *Driver) decodePoint(line []byte) (Point, error) {
+	var p Point
+	if line[0] != '{' {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return p, err
+		}
+		if line, err = d.decrypt(sealed); err != nil {
+			return p, err
+		}
+	}
+	err := json.Unmarshal(line, &p)
+	return p, err
+}
+
+// seriesCollections returns the collection locks of every time series.
+func (d *Driver) seriesCollections() ([]string, error) {
+	names, err := d.SeriesNames()
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]string, len(names))
+	for i, name := range names {
+		collections[i] = seriesCollection(name)
+	}
+	return collections, nil
+}
+
+// reencryptSeries rewrites every point with the current data key.
+func (d *Driver) reencryptSeries() error {
+	names, err := d.SeriesNames()
+	if err != nil {
+		return err
+	}
+	for _, series := range names {
+		if err := d.reencryptPartitions(series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) reencryptPartitions(series string) error {
+	mutex := d.GetOrCreateMutex(seriesCollection(series))
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	days, err := d.partitions(series)
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		file := partitionPath(series, day)
+		b, err := d.backend.Get(file)
+		if err != nil {
+			return err
+		}
+		points, err := d.decodePartition(series, b)
+		if err != nil {
+			return err
+		}
+		var out []byte
+		for _, p := range points {
+			line, err := d.encodePoint(p)
+			if err != nil {
+				return err
+			}
+			out = append(out, line...)
+		}
+		if err := d.backend.Put(file+".tmp", out); err != nil {
+			return err
+		}
+		if err := d.backend.Rename(file+".tmp", file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSeries(t *testing.T) {
+	for _, key := range [][]byte{nil, testMasterKey} {
+		dir := t.TempDir()
+		opts := &Options{MasterKey: key, SeriesRetention: 48 * time.Hour, TTLSweepInterval: -1}
+		d, err := New(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now().UTC().Truncate(time.Hour)
+		// One point a day for ten days, appended newest first.
+		for i := 0; i < 10; i++ {
+			if err := d.Append("cpu", Point{Time: now.Add(-time.Duration(i) * 24 * time.Hour), Value: float64(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Append("cpu", Point{Time: now.Add(time.Minute), Value: 100, Tags: map[string]string{"host": "a"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		ps, err := d.Range("cpu", now.Add(-3*24*time.Hour), now.Add(time.Hour))
+		if err != nil || len(ps) != 5 {
+			t.Fatalf("Range = %v, %v; want 5 points", ps, err)
+		}
+		if ps[0].Value != 3 || ps[4].Value != 100 || ps[4].Tags["host"] != "a" {
+			t.Errorf("Range = %v, want the points oldest first with their tags", ps)
+		}
+		if r, err := d.Rollup("cpu", time.Time{}, now.Add(time.Hour), 100*365*24*time.Hour, "sum"); err != nil || len(r) != 1 || r[0].Value != 145 {
+			t.Errorf("Rollup = %v, %v; want one point of 145", r, err)
+		}
+		if _, err := d.Rollup("cpu", time.Time{}, now, time.Hour, "median"); err == nil {
+			t.Error("Rollup with an unknown function succeeded")
+		}
+		if _, err := d.Range("../cpu", time.Time{}, now); err == nil {
+			t.Error("Range of an invalid series succeeded")
+		}
+
+		if n, err := d.PruneSeries(); err != nil || n < 7 {
+			t.Errorf("PruneSeries = %d, %v; want the days past retention deleted", n, err)
+		}
+		if names, err := d.SeriesNames(); err != nil || len(names) != 1 || names[0] != "cpu" {
+			t.Errorf("SeriesNames = %q, %v", names, err)
+		}
+		if key != nil {
+			d = rotate(t, d, dir)
+			opts.MasterKey = testNextKey
+		}
+
+		// A crash in the middle of an append leaves a torn last line.
+		f, err := os.OpenFile(filepath.Join(dir, filepath.FromSlash(partitionPath("cpu", now))), os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(`{"time":"20`)
+		f.Close()
+		if err := d.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if d, err = New(dir, opts); err != nil {
+			t.Fatal(err)
+		}
+		ps, err = d.Range("cpu", time.Time{}, now.Add(time.Hour))
+		if err != nil || len(ps) < 2 || ps[len(ps)-1].Value != 100 {
+			t.Errorf("Range after reopening = %v, %v; want the torn line skipped", ps, err)
+		}
+		if err := d.DropSeries("cpu"); err != nil {
+			t.Fatal(err)
+		}
+		if ps, err := d.Range("cpu", time.Time{}, now.Add(time.Hour)); err != nil || len(ps) != 0 {
+			t.Errorf("Range of a dropped series = %v, %v; want none", ps, err)
+		}
+		d.Close()
+	}
+}
+
+func TestSeriesMemory(t *testing.T) {
+	d, err := NewMemory(&Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := d.Append("x", Point{Time: now.Add(time.Duration(i) * time.Second), Value: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ps, err := d.Range("x", time.Time{}, now.Add(time.Hour)); err != nil || len(ps) != 2 {
+		t.Errorf("Range = %v, %v; want both points", ps, err)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var points []Point
+	for i, v := range []float64{4, 1, 3, 10, 20} {
+		points = append(points, Point{Time: start.Add(time.Duration(i*20) * time.Minute), Value: v})
+	}
+	for fn, want := range map[string][]float64{
+		"count": {3, 2},
+		"sum":   {8, 30},
+		"avg":   {8.0 / 3, 15},
+		"min":   {1, 10},
+		"max":   {4, 20},
+		"first": {4, 10},
+		"last":  {3, 20},
+	} {
+		got, err := Downsample(points, time.Hour, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Value != want[0] || got[1].Value != want[1] || !got[1].Time.Equal(start.Add(time.Hour)) {
+			t.Errorf("Downsample %s = %v, want %v", fn, got, want)
+		}
+	}
+	if _, err := Downsample(points, 0, "sum"); err == nil {
+		t.Error("Downsample with no interval succeeded")
+	}
+}
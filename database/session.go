@@ -0,0 +1,330 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ErrSessionClosed is returned by the methods of a Session that was
+// committed or rolled back.
+var ErrSessionClosed = errors.New("session is closed")
+
+// pendingWrite is a write held back until a procedure or session commits,
+// encoded when it was made.
+type pendingWrite struct {
+	collection, key string
+	b               []byte
+	deleted         bool
+}
+
+// writeSet is the writes of a procedure call or session in the order they
+// were made. Later writes to a record replace earlier ones when committed,
+// and dropping the tail of the log undoes the writes made since.
+type writeSet struct {
+	log []pendingWrite
+	// latest indexes the last write to every record in log.
+	latest map[string]int
+}
+
+func (ws *writeSet) get(collection, key string) (pendingWrite, bool) {
+	i, ok := ws.latest[RefTo(collection, key).Ref]
+	if !ok {
+		return pendingWrite{}, false
+	}
+	return ws.log[i], true
+}
+
+func (ws *writeSet) put(w pendingWrite) {
+	if ws.latest == nil {
+		ws.latest = make(map[string]int)
+	}
+	ws.latest[RefTo(w.collection, w.key).Ref] = len(ws.log)
+	ws.log = append(ws.log, w)
+}
+
+// truncate undoes every write after the first n.
+func (ws *writeSet) truncate(n int) {
+	ws.log = ws.log[:n]
+	ws.latest = make(map[string]int, n)
+	for i, w := range ws.log {
+		ws.latest[RefTo(w.collection, w.key).Ref] = i
+	}
+}
+
+// writes returns the last write to every record, in the order the records
+// were first written.
+func (ws *writeSet) writes() []pendingWrite {
+	var writes []pendingWrite
+	seen := make(map[string]bool, len(ws.latest))
+	for _, w := range ws.log {
+		ref := RefTo(w.collection, w.key).Ref
+		if !seen[ref] {
+			seen[ref] = true
+			writes = append(writes, ws.log[ws.latest[ref]])
+		}
+	}
+	return writes
+}
+
+// keys applies the writes to the keys of a collection and sorts them.
+func (ws *writeSet) keys(collection string, keys []string) []string {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	for _, w := range ws.log {
+		if w.collection == collection {
+			set[w.key] = !w.deleted
+		}
+	}
+	keys = keys[:0]
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// commitWrites makes the writes of ws; callers hold the collection locks.
+func (d *Driver) commitWrites(op *opTimer, ws *writeSet) (int, error) {
+	writes := ws.writes()
+	if len(writes) == 0 {
+		return 0, nil
+	}
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+	// Refused before any is made, as the writes are made one by one.
+	for _, w := range writes {
+		var err error
+		if w.deleted {
+			err = d.checkDelete(w.collection)
+		} else if err = d.checkOverwrite(w.collection, d.recordPath(w.collection, w.key)); err == nil {
+			err = d.checkSchema(w.collection, w.key, d.collectionOptions(w.collection).Schema, w.b)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, w := range writes {
+		if w.deleted {
+			if err := d.deleteRecord(w.collection, w.key, d.recordPath(w.collection, w.key)); err != nil && !isNotExist(err) {
+				return 0, err
+			}
+			continue
+		}
+		if err := d.writeRecordLocked(op, w.collection, w.key, bytes.NewReader(w.b), time.Time{}); err != nil {
+			return 0, err
+		}
+	}
+	return len(writes), nil
+}
+
+// Session is a transaction over a set of collections, which it keeps
+// locked from Begin until it is committed or rolled back, so no other
+// writer changes them in between. Its reads see its own writes, which are
+// only made on Commit; like a procedure's, they are made one by one, so a
+// crash while making them can leave some undone. Savepoints mark where
+// RollbackTo can undo the writes made since, to retry part of the work.
+// Close waits for open sessions to end. A Session must not be used from
+// several goroutines at once.
+type Session struct {
+	d           *Driver
+	ctx         context.Context
+	collections map[string]bool
+	unlock      func()
+	writes      writeSet
+	savepoints  []Savepoint
+	nextID      int
+	closed      bool
+}
+
+// Savepoint marks the writes a Session had made when it was taken.
+type Savepoint struct {
+	id, writes int
+}
+
+// Begin starts a session over the given collections, waiting for any
+// other session or writer working on them.
+func (d *Driver) Begin(collections ...string) (*Session, error) {
+	return d.BeginContext(context.Background(), collections...)
+}
+
+// BeginContext is Begin with a context carrying the caller, who must be
+// allowed to read the collections.
+func (d *Driver) BeginContext(ctx context.Context, collections ...string) (*Session, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("missing collection - no place for the session to work")
+	}
+	s := &Session{d: d, ctx: ctx, collections: make(map[string]bool, len(collections))}
+	// Views are left unlocked: sessions cannot write them, and writes to
+	// their sources lock them to update them.
+	var locked []string
+	for _, c := range collections {
+		if err := validCollection(c); err != nil {
+			return nil, err
+		}
+		if err := d.authorizeContext(ctx, c, PermRead); err != nil {
+			return nil, err
+		}
+		if !s.collections[c] {
+			s.collections[c] = true
+			if _, ok := d.views[c]; !ok {
+				locked = append(locked, c)
+			}
+		}
+	}
+	s.unlock = d.lockCollections(locked)
+	return s, nil
+}
+
+func (s *Session) check(collection, key string, perm Permission) error {
+	if s.closed {
+		return ErrSessionClosed
+	}
+	if !s.collections[collection] {
+		return fmt.Errorf("%w: session did not begin with collection %s", ErrPermissionDenied, collection)
+	}
+	// Keys are encoded as Driver.Write encodes them, so any but the
+	// empty key is one.
+	if key == "" {
+		return fmt.Errorf("missing resource - unable to use record (no name)")
+	}
+	return s.d.authorizeContext(s.ctx, collection, perm)
+}
+
+// Read reads a record as the session's writes left it. Unlike Driver.Read,
+// a missing record is an error matching fs.ErrNotExist.
+func (s *Session) Read(collection, key string, v interface{}) error {
+	if err := s.check(collection, key, PermRead); err != nil {
+		return err
+	}
+	p := s.d.recordPath(collection, key)
+	if w, ok := s.writes.get(collection, key); ok {
+		if w.deleted {
+			return fmt.Errorf("unable to find record named %v: %w", p, fs.ErrNotExist)
+		}
+		return s.d.codecFor(collection).Unmarshal(w.b, v)
+	}
+	b, err := s.d.readRecord(collection, key)
+	if err != nil {
+		return err
+	}
+	return s.d.codecFor(collection).Unmarshal(b, v)
+}
+
+// Write replaces a record on Commit.
+func (s *Session) Write(collection, key string, v interface{}) error {
+	if err := s.check(collection, key, PermWrite); err != nil {
+		return err
+	}
+	if err := s.d.checkView(collection); err != nil {
+		return err
+	}
+	b, err := s.d.codecFor(collection).Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := s.d.checkRecordSize(collection, key, b); err != nil {
+		return err
+	}
+	s.writes.put(pendingWrite{collection: collection, key: key, b: b})
+	return nil
+}
+
+// Delete deletes a record on Commit. Deleting a missing record is not an
+// error.
+func (s *Session) Delete(collection, key string) error {
+	if err := s.check(collection, key, PermDelete); err != nil {
+		return err
+	}
+	if err := s.d.checkView(collection); err != nil {
+		return err
+	}
+	s.writes.put(pendingWrite{collection: collection, key: key, deleted: true})
+	return nil
+}
+
+// Keys returns the keys of a collection as the session's writes left it,
+// sorted.
+func (s *Session) Keys(collection string) ([]string, error) {
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	if !s.collections[collection] {
+		return nil, fmt.Errorf("%w: session did not begin with collection %s", ErrPermissionDenied, collection)
+	}
+	if err := s.d.authorizeContext(s.ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+	keys, err := s.d.liveKeys(collection)
+	if err != nil {
+		return nil, err
+	}
+	return s.writes.keys(collection, keys), nil
+}
+
+// Savepoint marks the session's writes so far.
+func (s *Session) Savepoint() Savepoint {
+	s.nextID++
+	sp := Savepoint{id: s.nextID, writes: len(s.writes.log)}
+	s.savepoints = append(s.savepoints, sp)
+	return sp
+}
+
+// RollbackTo undoes the writes made since sp was taken. sp stays usable,
+// while the savepoints taken after it are released.
+func (s *Session) RollbackTo(sp Savepoint) error {
+	if s.closed {
+		return ErrSessionClosed
+	}
+	for i, held := range s.savepoints {
+		if held == sp {
+			s.writes.truncate(sp.writes)
+			s.savepoints = s.savepoints[:i+1]
+			return nil
+		}
+	}
+	return errors.New("unknown savepoint - released by rolling back past it")
+}
+
+// Commit makes the session's writes and ends it.
+func (s *Session) Commit() (err error) {
+	if s.closed {
+		return ErrSessionClosed
+	}
+	op := s.d.startOp(s.ctx, "commit", "", "")
+	defer op.end(&err)
+
+	defer s.end()
+	n, err := s.d.commitWrites(op, &s.writes)
+	if err != nil {
+		return err
+	}
+	s.d.logEvent(slog.LevelDebug, "Session committed", slog.Int("writes", n))
+	return nil
+}
+
+// Rollback ends the session without making its writes. It does nothing to
+// a session that already ended, so it can be deferred right after Begin.
+func (s *Session) Rollback() {
+	if !s.closed {
+		s.end()
+	}
+}
+
+func (s *Session) end() {
+	s.closed = true
+	s.unlock()
+}
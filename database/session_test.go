@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type account struct {
+	Balance int `json:"balance"`
+}
+
+func openAccounts(t *testing.T) *Driver {
+	t.Helper()
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	for key, balance := range map[string]int{"a": 100, "b": 0} {
+		if err := d.Write("accounts", key, account{Balance: balance}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+func TestSession(t *testing.T) {
+	d := openAccounts(t)
+	s, err := d.Begin("accounts", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Rollback()
+
+	var a account
+	if err := s.Read("accounts", "a", &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("accounts", "a", account{Balance: a.Balance - 10}); err != nil {
+		t.Fatal(err)
+	}
+	sp := s.Savepoint()
+	s.Write("accounts", "a", account{Balance: 0})
+	s.Write("log", "1", map[string]string{"note": "emptied"})
+	if err := s.Delete("accounts", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Read("accounts", "a", &a); !isNotExist(err) {
+		t.Errorf("Read of a record the session deleted = %v, want not found", err)
+	}
+	if keys, err := s.Keys("log"); err != nil || len(keys) != 1 {
+		t.Errorf("Keys = %q, %v; want the session's write", keys, err)
+	}
+	if err := d.Read("accounts", "a", &a); err != nil || a.Balance != 100 {
+		t.Errorf("Driver.Read during the session = %+v, %v; want the writes held back", a, err)
+	}
+
+	later := s.Savepoint()
+	if err := s.RollbackTo(sp); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RollbackTo(later); err == nil {
+		t.Error("RollbackTo of a released savepoint succeeded")
+	}
+	if err := s.Read("accounts", "a", &a); err != nil || a.Balance != 90 {
+		t.Errorf("Read after RollbackTo = %+v, %v; want 90", a, err)
+	}
+	if keys, _ := s.Keys("log"); len(keys) != 0 {
+		t.Errorf("Keys after RollbackTo = %q, want none", keys)
+	}
+	if err := s.RollbackTo(sp); err != nil {
+		t.Errorf("second RollbackTo of the same savepoint = %v", err)
+	}
+	if err := s.Write("other", "k", 1); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Write outside the session's collections = %v, want ErrPermissionDenied", err)
+	}
+
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("accounts", "a", 1); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Write after Commit = %v, want ErrSessionClosed", err)
+	}
+	if err := d.Read("accounts", "a", &a); err != nil || a.Balance != 90 {
+		t.Errorf("Read after Commit = %+v, %v; want 90", a, err)
+	}
+}
+
+func TestSessionRollback(t *testing.T) {
+	d := openAccounts(t)
+	s, err := d.Begin("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write("accounts", "a", account{Balance: 1})
+	s.Rollback()
+	s.Rollback()
+	if err := s.Commit(); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Commit after Rollback = %v, want ErrSessionClosed", err)
+	}
+	var a account
+	if err := d.Read("accounts", "a", &a); err != nil || a.Balance != 100 {
+		t.Errorf("Read after Rollback = %+v, %v; want 100", a, err)
+	}
+	// The collection lock was released.
+	if err := d.Write("accounts", "a", account{Balance: 5}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	d := openAccounts(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := d.Begin("accounts")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer s.Rollback()
+			var a, b account
+			if err := s.Read("accounts", "a", &a); err != nil {
+				t.Error(err)
+			}
+			if err := s.Read("accounts", "b", &b); err != nil {
+				t.Error(err)
+			}
+			s.Write("accounts", "a", account{Balance: a.Balance - 1})
+			s.Write("accounts", "b", account{Balance: b.Balance + 1})
+			if err := s.Commit(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var a, b account
+	d.Read("accounts", "a", &a)
+	d.Read("accounts", "b", &b)
+	if a.Balance != 70 || b.Balance != 30 {
+		t.Errorf("balances = %d and %d, want 70 and 30", a.Balance, b.Balance)
+	}
+}
+
+func TestSessionEncodedKeys(t *testing.T) {
+	d := openAccounts(t)
+	keys := []string{"a/b", ".hidden", "CON", "x.", "y "}
+	for _, key := range keys {
+		if err := d.Write("accounts", key, account{Balance: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := d.Begin("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Rollback()
+	for _, key := range keys {
+		var a account
+		if err := s.Read("accounts", key, &a); err != nil || a.Balance != 1 {
+			t.Errorf("Read of %q = %+v, %v; want the record written outside the session", key, a, err)
+		}
+		if err := s.Write("accounts", key, account{Balance: 2}); err != nil {
+			t.Errorf("Write of %q = %v", key, err)
+		}
+	}
+	if err := s.Delete("accounts", ".hidden"); err != nil {
+		t.Errorf("Delete of %q = %v", ".hidden", err)
+	}
+	if err := s.Read("accounts", "", &account{}); err == nil {
+		t.Error("Read of the empty key succeeded")
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		var a account
+		if key == ".hidden" {
+			continue
+		}
+		if err := d.Read("accounts", key, &a); err != nil || a.Balance != 2 {
+			t.Errorf("Read of %q after Commit = %+v, %v; want 2", key, a, err)
+		}
+	}
+	if keys, err := d.Keys("accounts"); err != nil || len(keys) != 6 {
+		t.Errorf("Keys after Commit = %q, %v; want a, b and four of those written", keys, err)
+	}
+}
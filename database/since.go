@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+// ErrNoModTimes is returned by the Since reads when a record has no
+// metadata and the backend cannot tell when its file was written.
+var ErrNoModTimes = errors.New("backend does not report modification times")
+
+// ReadAllSince is ReadAll returning only the records written after t, so
+// an incremental sync or export reads only what changed since its last
+// run. When a record was written is taken from its metadata with
+// Options.RecordMetadata, or else from the modification time of its file,
+// which the backend must report as a StatBackend. Deleted records are not
+// reported; Watch or Diff for those.
+func (d *Driver) ReadAllSince(collection string, t time.Time) ([]string, error) {
+	return d.ReadAllSinceContext(context.Background(), collection, t)
+}
+
+// ReadAllSinceContext is ReadAllSince with a context to trace the
+// operation in.
+func (d *Driver) ReadAllSinceContext(ctx context.Context, collection string, t time.Time) (records []string, err error) {
+	op := d.startOp(ctx, "readall", collection, "")
+	defer op.end(&err)
+
+	files, names, err := d.changedSince(ctx, collection, t)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		b, err := d.backend.Get(path.Join(collection, file))
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b, err = d.decodeRecord(file, b); err != nil {
+			return nil, err
+		}
+		if b, err = d.decryptFields(collection, names[i], b); err != nil {
+			return nil, err
+		}
+		op.addBytes(int64(len(b)))
+		records = append(records, string(b))
+	}
+	return records, nil
+}
+
+// KeysSince is Keys returning only the keys of the records written after
+// t, as ReadAllSince tells.
+func (d *Driver) KeysSince(collection string, t time.Time) ([]string, error) {
+	_, names, err := d.changedSince(context.Background(), collection, t)
+	return names, err
+}
+
+// changedSince returns the files and keys of the live records of a
+// collection written after t, in the order ReadAll returns them.
+func (d *Driver) changedSince(ctx context.Context, collection string, t time.Time) (files, names []string, err error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, nil, err
+	}
+	if collection == "" {
+		return nil, nil, fmt.Errorf("missing collection - no place to read records")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, nil, err
+	}
+
+	list, err := d.backend.List(collection)
+	if err != nil {
+		return nil, nil, err
+	}
+	expired, err := d.expiredStems(collection)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, _ := d.backend.(StatBackend)
+	for _, file := range list {
+		stem, ok := d.recordStem(collection, file)
+		if isDirName(file) || !ok || expired[stem] {
+			continue
+		}
+		var written time.Time
+		if d.recordMeta {
+			m, err := d.readMeta(path.Join(collection, stem))
+			if err != nil {
+				return nil, nil, err
+			}
+			written = m.Updated
+		}
+		if written.IsZero() {
+			if stat == nil {
+				return nil, nil, ErrNoModTimes
+			}
+			_, mod, err := stat.Stat(path.Join(collection, file))
+			if isNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			written = mod
+		}
+		if written.After(t) {
+			files = append(files, file)
+			names = append(names, decodeKey(stem))
+		}
+	}
+	return files, names, nil
+}
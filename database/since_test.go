@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadAllSince(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"mod times": {TTLSweepInterval: -1},
+		"metadata":  {RecordMetadata: true, TTLSweepInterval: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, err := New(t.TempDir(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			for _, k := range []string{"a", "b"} {
+				if err := d.Write("users", k, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			mark := time.Now()
+			time.Sleep(20 * time.Millisecond)
+			if err := d.Write("users", "b", 2); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Write("users", "c", 3); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := d.ReadAllSince("users", mark)
+			if err != nil || len(records) != 2 || strings.TrimSpace(records[0]) != "2" || strings.TrimSpace(records[1]) != "3" {
+				t.Errorf("ReadAllSince = %q, %v; want the records of b and c", records, err)
+			}
+			keys, err := d.KeysSince("users", mark)
+			if err != nil || !reflect.DeepEqual(keys, []string{"b", "c"}) {
+				t.Errorf("KeysSince = %v, %v; want [b c]", keys, err)
+			}
+			if all, err := d.ReadAllSince("users", time.Time{}); err != nil || len(all) != 3 {
+				t.Errorf("ReadAllSince of the zero time = %d records, %v; want 3", len(all), err)
+			}
+		})
+	}
+}
+
+func TestReadAllSinceInMemory(t *testing.T) {
+	d, err := NewMemory(&Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadAllSince("users", time.Time{}); !errors.Is(err, ErrNoModTimes) {
+		t.Errorf("ReadAllSince without mod times = %v, want ErrNoModTimes", err)
+	}
+
+	d, err = NewMemory(&Options{RecordMetadata: true, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := d.ReadAllSince("users", time.Time{}); err != nil || len(records) != 1 {
+		t.Errorf("ReadAllSince with metadata = %q, %v; want the record", records, err)
+	}
+}
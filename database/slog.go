@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Levels for the Logger methods slog has no level for.
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+)
+
+// slogLogger adapts a slog.Logger to the Logger interface. Drivers given
+// one also log their operation events with structured attributes.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to l, for Options.Logger. The
+// printf-style messages become slog messages, and operation events such as
+// slow operations carry op, collection, key, duration, bytes and error
+// attributes.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+func (s slogLogger) log(level slog.Level, format string, v ...interface{}) {
+	if !s.l.Enabled(context.Background(), level) {
+		return
+	}
+	s.l.Log(context.Background(), level, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (s slogLogger) Fatal(format string, v ...interface{}) { s.log(LevelFatal, format, v...) }
+func (s slogLogger) Error(format string, v ...interface{}) { s.log(slog.LevelError, format, v...) }
+func (s slogLogger) Warn(format string, v ...interface{})  { s.log(slog.LevelWarn, format, v...) }
+func (s slogLogger) Info(format string, v ...interface{})  { s.log(slog.LevelInfo, format, v...) }
+func (s slogLogger) Debug(format string, v ...interface{}) { s.log(slog.LevelDebug, format, v...) }
+func (s slogLogger) Trace(format string, v ...interface{}) { s.log(LevelTrace, format, v...) }
+
+// logEvent logs msg with attributes, structured on a slog Logger and as
+// key=value pairs after the message on any other.
+func (d *Driver) logEvent(level slog.Level, msg string, attrs ...slog.Attr) {
+	if s, ok := d.log.(slogLogger); ok {
+		s.l.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, a := range attrs {
+		v := a.Value.String()
+		if v == "" || strings.ContainsAny(v, " \"=") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, " %s=%s", a.Key, v)
+	}
+	b.WriteByte('\n')
+
+	switch {
+	case level >= LevelFatal:
+		d.log.Fatal("%s", b.String())
+	case level >= slog.LevelError:
+		d.log.Error("%s", b.String())
+	case level >= slog.LevelWarn:
+		d.log.Warn("%s", b.String())
+	case level >= slog.LevelInfo:
+		d.log.Info("%s", b.String())
+	case level >= slog.LevelDebug:
+		d.log.Debug("%s", b.String())
+	default:
+		d.log.Trace("%s", b.String())
+	}
+}
+
+// opAttrs returns the attributes describing op in log events.
+func opAttrs(op Op) []slog.Attr {
+	attrs := []slog.Attr{slog.String("op", op.Name), slog.String("collection", op.Collection)}
+	if op.Key != "" {
+		attrs = append(attrs, slog.String("key", op.Key))
+	}
+	if op.Principal != "" {
+		attrs = append(attrs, slog.String("principal", op.Principal))
+	}
+	attrs = append(attrs, slog.Duration("duration", op.Duration), slog.Int64("bytes", op.Bytes))
+	if op.LockWait > 0 {
+		attrs = append(attrs, slog.Duration("lock_wait", op.LockWait))
+	}
+	if op.Err != nil {
+		attrs = append(attrs, slog.Any("error", op.Err))
+	}
+	return attrs
+}
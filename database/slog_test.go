@@ -0,0 +1,81 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: LevelTrace}))
+	d, err := New(t.TempDir(), &Options{Logger: NewSlogLogger(l), SlowOpThreshold: time.Nanosecond, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	buf.Reset()
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Write(".users", "ada", 1)
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if e["msg"] == "Slow operation" {
+			events = append(events, e)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("logged %s, want two slow operations", buf.String())
+	}
+	e := events[0]
+	if e["level"] != "WARN" || e["op"] != "write" || e["collection"] != "users" || e["key"] != "ada" {
+		t.Errorf("event = %v", e)
+	}
+	if _, ok := e["duration"].(float64); !ok || e["bytes"].(float64) == 0 {
+		t.Errorf("event duration %v and bytes %v", e["duration"], e["bytes"])
+	}
+	if _, failed := e["error"]; failed {
+		t.Errorf("successful write logged with an error: %v", e)
+	}
+	if events[1]["error"] == nil {
+		t.Errorf("failed write logged without its error: %v", events[1])
+	}
+}
+
+func TestSlogLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	l.Debug("hidden %d\n", 1)
+	l.Info("shown %d\n", 2)
+	l.Fatal("fatal %d\n", 3)
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("debug message logged at info: %s", out)
+	}
+	if !strings.Contains(out, `level=INFO msg="shown 2"`) || !strings.Contains(out, `level=ERROR+4 msg="fatal 3"`) {
+		t.Errorf("logged %s", out)
+	}
+}
+
+func TestLogEventPlainLogger(t *testing.T) {
+	l := &captureLogger{}
+	d, err := New(t.TempDir(), &Options{Logger: l, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.logEvent(slog.LevelError, "Something", slog.String("collection", "users"), slog.String("key", ""))
+	if lines := l.matching("ERROR"); len(lines) != 1 || lines[0] != `ERROR Something collection=users key=""` {
+		t.Errorf("logged %q", l.lines)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build unix && !linux && !darwin && !freebsd
+
+package database
+
+import "errors"
+
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
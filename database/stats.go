@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the driver's internal state and counters.
+type Stats struct {
+	// OpenCollections counts the collections used since the Driver was
+	// opened, which each hold a lock.
+	OpenCollections int
+	// CachedKeys counts the record names held by the case-folding indexes.
+	CachedKeys int
+	// TrackedUsages counts the quota scopes whose usage is kept in memory.
+	TrackedUsages  int
+	WritesInFlight int64
+	Watchers       int
+	SweeperRuns    int64
+	SweptRecords   int64
+	LastSweep      time.Time
+	Maintenance    []JobStats
+}
+
+func (d *Driver) Stats() Stats {
+	var s Stats
+
+	d.mutex.Lock()
+	s.OpenCollections = len(d.mutexes)
+	for _, index := range d.caseIndexes {
+		s.CachedKeys += len(index)
+	}
+	s.TrackedUsages = len(d.usages)
+	d.mutex.Unlock()
+
+	d.watchMutex.Lock()
+	s.Watchers = len(d.watchers)
+	d.watchMutex.Unlock()
+
+	s.WritesInFlight = d.writesInFlight.Load()
+	s.SweeperRuns = d.sweeperRuns.Load()
+	s.SweptRecords = d.sweptRecords.Load()
+	if t := d.lastSweep.Load(); t != 0 {
+		s.LastSweep = time.Unix(0, t)
+	}
+	s.Maintenance = d.MaintenanceStats()
+	return s
+}
+
+// Publish exports Stats through expvar under name, such as "owndb". Like
+// expvar.Publish, it panics if the name is already in use.
+func (d *Driver) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return d.Stats() }))
+}
+
+// HeldLocks returns the collections whose lock is taken at the moment,
+// sorted, for diagnosing stuck or contended writers. Probing takes each
+// free lock for an instant.
+func (d *Driver) HeldLocks() []string {
+	held := []string{}
+	for c, m := range d.collectionMutexes() {
+		if m.TryLock() {
+			m.Unlock()
+		} else {
+			held = append(held, c)
+		}
+	}
+	sort.Strings(held)
+	return held
+}
+
+// collectionMutexes returns a snapshot of the collection locks.
+func (d *Driver) collectionMutexes() map[string]*sync.Mutex {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	mutexes := make(map[string]*sync.Mutex, len(d.mutexes))
+	for c, m := range d.mutexes {
+		mutexes[c] = m
+	}
+	return mutexes
+}
+
+// CollectionStats describes what one collection stores.
+type CollectionStats struct {
+	Records int
+	// Bytes is the stored size of the records, after compression and
+	// encryption.
+	Bytes       int64
+	AverageSize int64
+	// Oldest and Newest are the earliest and latest times a record was
+	// written. They are zero on backends that do not implement StatBackend.
+	Oldest time.Time
+	Newest time.Time
+	// Expiring counts the records with a TTL.
+	Expiring int
+	// Indexes maps the collection's in-memory indexes, such as "casefold",
+	// to their entry counts. Indexes not loaded yet are left out.
+	Indexes map[string]int
+}
+
+// CollectionStats returns the statistics of a collection. On backends that
+// implement StatBackend the records are stat'ed rather than read; on others
+// the counts come from the usage kept for quotas where there is one.
+func (d *Driver) CollectionStats(collection string) (CollectionStats, error) {
+	return d.CollectionStatsContext(context.Background(), collection)
+}
+
+// CollectionStatsContext is CollectionStats with a context carrying the
+// caller.
+func (d *Driver) CollectionStatsContext(ctx context.Context, collection string) (CollectionStats, error) {
+	st := CollectionStats{Indexes: map[string]int{}}
+	if err := d.checkOpen(); err != nil {
+		return st, err
+	}
+	if collection == "" {
+		return st, fmt.Errorf("missing collection - no stats to report")
+	}
+	if err := validCollection(collection); err != nil {
+		return st, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return st, err
+	}
+
+	if sb, ok := d.backend.(StatBackend); ok {
+		if err := d.statRecords(sb, collection, &st); err != nil {
+			return st, err
+		}
+	} else {
+		records, bytes, err := d.Usage(collection)
+		if err != nil {
+			return st, err
+		}
+		st.Records, st.Bytes = records, bytes
+	}
+	if st.Records > 0 {
+		st.AverageSize = st.Bytes / int64(st.Records)
+	}
+
+	sidecars, err := d.backend.List(path.Join(collection, ttlDir))
+	if err != nil && !isNotExist(err) {
+		return st, err
+	}
+	for _, file := range sidecars {
+		if strings.HasSuffix(file, ".ttl") && !isDirName(file) {
+			st.Expiring++
+		}
+	}
+
+	d.mutex.Lock()
+	if index, ok := d.caseIndexes[collection]; ok {
+		st.Indexes["casefold"] = len(index)
+	}
+	d.mutex.Unlock()
+	return st, nil
+}
+
+func (d *Driver) statRecords(sb StatBackend, collection string, st *CollectionStats) error {
+	files, err := d.backend.List(collection)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, ok := d.recordStem(collection, file); isDirName(file) || !ok {
+			continue
+		}
+		size, modTime, err := sb.Stat(path.Join(collection, file))
+		if isNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		st.Records++
+		st.Bytes += size
+		if st.Oldest.IsZero() || modTime.Before(st.Oldest) {
+			st.Oldest = modTime
+		}
+		if modTime.After(st.Newest) {
+			st.Newest = modTime
+		}
+	}
+	return nil
+}
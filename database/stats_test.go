@@ -0,0 +1,155 @@
+package database
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, collection := range []string{"users", "orders"} {
+		if err := d.Write(collection, "a", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.WriteTTL("users", "b", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	_, stop, err := d.Watch("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := d.SweepExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := d.Stats()
+	if s.OpenCollections < 2 || s.Watchers != 1 || s.WritesInFlight != 0 {
+		t.Errorf("Stats = %+v", s)
+	}
+	if s.SweeperRuns != 1 || s.SweptRecords != 1 || s.LastSweep.IsZero() {
+		t.Errorf("sweeper stats %d runs, %d swept, last %v", s.SweeperRuns, s.SweptRecords, s.LastSweep)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Write("users", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// expvar names cannot be unpublished, so every run takes its own.
+	name := fmt.Sprintf("owndb_test_%d", time.Now().UnixNano())
+	d.Publish(name)
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatal("stats not published")
+	}
+	var s Stats
+	if err := json.Unmarshal([]byte(v.String()), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.OpenCollections != 1 {
+		t.Errorf("published %s", v)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("publishing under a name in use did not panic")
+		}
+	}()
+	d.Publish(name)
+}
+
+func TestHeldLocks(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, collection := range []string{"users", "orders", "logs"} {
+		if err := d.Write(collection, "a", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if held := d.HeldLocks(); len(held) != 0 {
+		t.Errorf("HeldLocks with no lock taken = %v", held)
+	}
+	for _, collection := range []string{"users", "logs"} {
+		mutex := d.GetOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+	if held := d.HeldLocks(); len(held) != 2 || held[0] != "logs" || held[1] != "users" {
+		t.Errorf("HeldLocks = %v, want logs and users", held)
+	}
+}
+
+func TestCollectionStats(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) (*Driver, error)
+		// stat is whether the backend is a StatBackend, which reports times.
+		stat bool
+	}{
+		{"fs", func(t *testing.T) (*Driver, error) { return New(t.TempDir(), &Options{TTLSweepInterval: -1}) }, true},
+		{"memory", func(t *testing.T) (*Driver, error) { return NewMemory(&Options{TTLSweepInterval: -1}) }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := tc.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			before := time.Now().Add(-time.Second)
+			for _, key := range []string{"a", "b", "c"} {
+				if err := d.Write("users", key, map[string]string{"name": key}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.WriteTTL("users", "d", 1, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			st, err := d.CollectionStats("users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Records != 4 || st.Expiring != 1 {
+				t.Errorf("Records, Expiring = %d, %d; want 4, 1", st.Records, st.Expiring)
+			}
+			if st.Bytes <= 0 || st.AverageSize != st.Bytes/4 {
+				t.Errorf("Bytes, AverageSize = %d, %d", st.Bytes, st.AverageSize)
+			}
+			if !tc.stat {
+				if !st.Oldest.IsZero() || !st.Newest.IsZero() {
+					t.Errorf("Oldest, Newest = %v, %v; want zero without StatBackend", st.Oldest, st.Newest)
+				}
+			} else if st.Oldest.Before(before) || st.Newest.Before(st.Oldest) || st.Newest.After(time.Now().Add(time.Second)) {
+				t.Errorf("Oldest, Newest = %v, %v", st.Oldest, st.Newest)
+			}
+
+			if st, err := d.CollectionStats("nothing"); err != nil || st.Records != 0 || st.Bytes != 0 {
+				t.Errorf("CollectionStats of a missing collection = %+v, %v", st, err)
+			}
+			if _, err := d.CollectionStats(""); err == nil {
+				t.Error("CollectionStats of no collection succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// WriteRaw stores content read from r as the record, streaming it to the
+// backend instead of buffering it. The content is stored as is and must
+// already be encoded with the database codec. Collections with encrypted
+// fields or a schema still buffer, since the document has to be parsed.
+// WriteRawWith writes one with another codec or compression.
+func (d *Driver) WriteRaw(collection string, resource string, r io.Reader) error {
+	return d.WriteRawContext(context.Background(), collection, resource, r)
+}
+
+// WriteRawContext is WriteRaw with a context carrying the caller.
+func (d *Driver) WriteRawContext(ctx context.Context, collection string, resource string, r io.Reader) (err error) {
+	op := d.startOp(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return err
+	}
+	return d.writeRecord(op, collection, resource, r, time.Time{})
+}
+
+// RawOptions overrides how WriteRawWith stores a single record.
+type RawOptions struct {
+	// Codec is the codec the content is encoded with, that of the
+	// collection by default. Content encoded with another is buffered,
+	// decoded and stored encoded with that of the collection.
+	Codec Codec
+	// Compression is the collection's by default, and none stores the
+	// record uncompressed. Reads find the record whichever it is, and the
+	// next write of it goes back to the collection's.
+	Compression Compression
+}
+
+// WriteRawWith is WriteRaw with options, for one-off records such as a
+// JSON document written into a msgpack collection, or a large record
+// compressed in a collection that is not.
+func (d *Driver) WriteRawWith(ctx context.Context, collection, resource string, r io.Reader, opts RawOptions) (err error) {
+	op := d.startOp(ctx, "write", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to save record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to save record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return err
+	}
+
+	compression := d.collectionOptions(collection).Compression
+	switch opts.Compression {
+	case CompressionNone:
+	case "none":
+		compression = CompressionNone
+	default:
+		if !opts.Compression.valid() {
+			return fmt.Errorf("unknown compression %q", opts.Compression)
+		}
+		compression = opts.Compression
+	}
+	if opts.Codec != nil {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if b, err = transcode(opts.Codec, d.codecFor(collection), b); err != nil {
+			return err
+		}
+		if err := d.checkRecordSize(collection, resource, b); err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+	return d.writeRecordCompressed(op, collection, resource, r, time.Time{}, compression)
+}
+
+// transcode re-encodes a record encoded with from as to encodes it,
+// keeping JSON numbers exact.
+func transcode(from, to Codec, b []byte) ([]byte, error) {
+	var v interface{}
+	if _, ok := from.(JSONCodec); ok {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+	} else if err := from.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return to.Marshal(v)
+}
+
+// writeRecord streams an encoded record into a temp file and renames it
+// over the record, removing variants left by other compression settings.
+// The record expires at expires, or never if it is zero.
+func (d *Driver) writeRecord(op *opTimer, collection, resource string, r io.Reader, expires time.Time) error {
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+	return d.writeRecordLocked(op, collection, resource, r, expires)
+}
+
+// writeRecordLocked is writeRecord for callers holding the collection lock.
+func (d *Driver) writeRecordLocked(op *opTimer, collection, resource string, r io.Reader, expires time.Time) error {
+	return d.writeRecordCompressed(op, collection, resource, r, expires, d.collectionOptions(collection).Compression)
+}
+
+// writeRecordCompressed is writeRecordLocked storing the record with
+// compression rather than the collection's.
+func (d *Driver) writeRecordCompressed(op *opTimer, collection, resource string, r io.Reader, expires time.Time, compression Compression) error {
+	d.writesInFlight.Add(1)
+	defer d.writesInFlight.Add(-1)
+
+	opts := d.collectionOptions(collection)
+	base := d.recordPath(collection, resource)
+	fnlPath := d.recordFile(base, compression)
+	tmpPath := fnlPath + ".tmp"
+
+	if err := d.checkKeyCase(collection, path.Base(base)); err != nil {
+		return err
+	}
+	if err := d.checkOverwrite(collection, base); err != nil {
+		return err
+	}
+
+	counted := &countingReader{r: d.limitRecord(collection, resource, r)}
+	defer func() { op.addBytes(counted.n) }()
+	body := io.Reader(counted)
+	rewind := d.rewinder(collection, resource, r, counted)
+	if len(opts.EncryptedFields)+len(opts.DeterministicFields) > 0 || opts.Schema != nil {
+		b, err := io.ReadAll(counted)
+		if err != nil {
+			return err
+		}
+		if err := d.checkSchema(collection, resource, opts.Schema, b); err != nil {
+			return err
+		}
+		if b, err = d.encryptFields(collection, resource, b); err != nil {
+			return err
+		}
+		buffered := bytes.NewReader(b)
+		body = buffered
+		rewind = func() bool {
+			buffered.Reset(b)
+			return true
+		}
+	}
+
+	var size int64
+	var compressed *compressedStream
+	// The compression of a failed attempt has to stop reading the record
+	// before it is rewound.
+	again := func() bool {
+		compressed.wait()
+		return rewind()
+	}
+	err := d.retryIf("write", tmpPath, again, func() error {
+		compressed = compressStream(compression, body)
+		defer compressed.Close()
+		var err error
+		size, err = d.putStream(tmpPath, compressed)
+		return err
+	})
+	if err != nil {
+		d.backend.Delete(tmpPath)
+		return d.noSpace(tmpPath, err)
+	}
+
+	release, err := d.reserveQuota(collection, base, size)
+	if err != nil {
+		d.backend.Delete(tmpPath)
+		return err
+	}
+
+	if err := d.retry("rename", tmpPath, func() error { return d.backend.Rename(tmpPath, fnlPath) }); err != nil {
+		release()
+		d.backend.Delete(tmpPath)
+		return d.noSpace(fnlPath, err)
+	}
+	d.learnKeyCase(collection, path.Base(base))
+
+	if _, err := d.removeRecordFiles(base, fnlPath); err != nil {
+		return err
+	}
+	if expires.IsZero() && opts.TTL > 0 {
+		expires = time.Now().Add(opts.TTL)
+	}
+	if err := d.setExpiry(base, expires); err != nil {
+		return err
+	}
+	if d.recordMeta {
+		if err := d.touchMeta(base, op.principal()); err != nil {
+			return err
+		}
+	}
+	d.notify(EventPut, collection, resource)
+	return nil
+}
+
+// ReadTo copies a record's encoded content to w without unmarshaling it,
+// for serving records as they are. A missing record is reported with an error
+// matching fs.ErrNotExist. Collections with encrypted fields are buffered to
+// decrypt them.
+func (d *Driver) ReadTo(collection string, resource string, w io.Writer) error {
+	return d.ReadToContext(context.Background(), collection, resource, w)
+}
+
+// ReadToContext is ReadTo with a context to trace the operation in.
+func (d *Driver) ReadToContext(ctx context.Context, collection string, resource string, w io.Writer) (err error) {
+	op := d.startOp(ctx, "read", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no place to read record")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to read record (no name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return err
+	}
+
+	p := d.recordPath(collection, resource)
+	expired, err := d.expired(p)
+	if err != nil {
+		return err
+	}
+	if expired {
+		return fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+	}
+	file, rc, err := d.openRecord(p)
+	if isNotExist(err) {
+		return fmt.Errorf("unable to find record %s/%s: %w", collection, resource, fs.ErrNotExist)
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	r, err := decompressStream(file, rc)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if encrypted, deterministic := d.fieldOptions(collection); len(encrypted)+len(deterministic) > 0 {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if b, err = d.decryptFields(collection, resource, b); err != nil {
+			return err
+		}
+		n, err := w.Write(b)
+		op.addBytes(int64(n))
+		return err
+	}
+
+	n, err := io.Copy(w, r)
+	op.addBytes(n)
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
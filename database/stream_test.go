@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRaw(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	const doc = `{"name":"Ada","langs":["en","fr"]}`
+	if err := d.WriteRaw("users", "ada", strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "users", "ada.json"))
+	if err != nil || string(b) != doc {
+		t.Fatalf("stored %q, %v; want the content as is", b, err)
+	}
+	var v struct {
+		Name  string   `json:"name"`
+		Langs []string `json:"langs"`
+	}
+	if err := d.Read("users", "ada", &v); err != nil || v.Name != "Ada" || len(v.Langs) != 2 {
+		t.Fatalf("Read = %+v, %v", v, err)
+	}
+	if err := d.WriteRaw("users", "", strings.NewReader(doc)); err == nil {
+		t.Error("WriteRaw without a resource succeeded")
+	}
+}
+
+func TestWriteRawEncryptedFields(t *testing.T) {
+	dir := t.TempDir()
+	d := openFields(t, dir)
+	defer d.Close()
+
+	if err := d.WriteRaw("patients", "ada", strings.NewReader(`{"name":"Ada","ssn":"123-45-6789"}`)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "patients", "ada.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "123-45-6789") {
+		t.Errorf("encrypted field of a raw record stored in the clear: %s", b)
+	}
+	var p patient
+	if err := d.Read("patients", "ada", &p); err != nil || p.SSN != "123-45-6789" {
+		t.Fatalf("Read = %+v, %v", p, err)
+	}
+}
+
+func TestReadTo(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{
+		Collections:      map[string]CollectionOptions{"logs": {Compression: CompressionGzip}},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	const doc = `{"msg":"hello"}`
+	for _, collection := range []string{"users", "logs"} {
+		if err := d.WriteRaw(collection, "a", strings.NewReader(doc)); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := d.ReadTo(collection, "a", &buf); err != nil || buf.String() != doc {
+			t.Errorf("ReadTo %s = %q, %v; want %q", collection, buf.String(), err, doc)
+		}
+	}
+	var buf bytes.Buffer
+	if err := d.ReadTo("users", "missing", &buf); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadTo of a missing record = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestReadToEncryptedFields(t *testing.T) {
+	d := openFields(t, t.TempDir())
+	defer d.Close()
+
+	if err := d.Write("patients", "ada", map[string]string{"ssn": "123-45-6789"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := d.ReadTo("patients", "ada", &buf); err != nil || !strings.Contains(buf.String(), "123-45-6789") {
+		t.Errorf("ReadTo = %q, %v; want the field decrypted", buf.String(), err)
+	}
+}
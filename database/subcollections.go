@@ -0,0 +1,247 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// Sub-collections nest collections under records, so "users/Waqar/orders"
+// is the orders collection of the record Waqar in users and works with every
+// method taking a collection. A record's sub-collections live in a directory
+// named after its key, next to the record file, and outlive the record
+// unless it is deleted with DeleteTree. Keys differing only in case share
+// that directory on file systems that fold case.
+
+// SubCollection returns the path of the sub-collection sub of a record.
+func SubCollection(collection, resource, sub string) string {
+	return path.Join(collection, resource, sub)
+}
+
+// SubCollections returns the names of the sub-collections of a record,
+// sorted.
+func (d *Driver) SubCollections(collection, resource string) ([]string, error) {
+	return d.SubCollectionsContext(context.Background(), collection, resource)
+}
+
+// SubCollectionsContext is SubCollections with a context carrying the
+// caller.
+func (d *Driver) SubCollectionsContext(ctx context.Context, collection, resource string) ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to find sub-collections")
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("missing resource - unable to find sub-collections (no record name)")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := validParentKey(resource); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+
+	names, err := d.listDirs(path.Join(collection, resource))
+	if isNotExist(err) {
+		return nil, nil
+	}
+	sort.Strings(names)
+	return names, err
+}
+
+// DeleteTree deletes a record together with its sub-collections, at any
+// depth. It fails with an error matching fs.ErrNotExist only if there is
+// neither.
+func (d *Driver) DeleteTree(collection, resource string) error {
+	return d.DeleteTreeContext(context.Background(), collection, resource)
+}
+
+// DeleteTreeContext is DeleteTree with a context to trace the operation in.
+func (d *Driver) DeleteTreeContext(ctx context.Context, collection, resource string) (err error) {
+	op := d.startOp(ctx, "delete", collection, resource)
+	defer op.end(&err)
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - nothing to delete")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - use DropCollection to remove a whole collection")
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := validParentKey(resource); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermDelete); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	op.lock(mutex)
+	defer mutex.Unlock()
+
+	dir := path.Join(collection, resource)
+	subs, err := d.nestedCollections(dir)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := d.authorizeContext(ctx, sub, PermDelete); err != nil {
+			return err
+		}
+	}
+	if err := d.checkDelete(append([]string{collection}, subs...)...); err != nil {
+		return err
+	}
+	defer d.lockCollections(subs)()
+
+	err = d.deleteRecord(collection, resource, d.recordPath(collection, resource))
+	if err != nil && !isNotExist(err) {
+		return err
+	}
+	found := err == nil
+	if err := d.backend.Delete(dir); err != nil && !isNotExist(err) {
+		return err
+	}
+	if !found && len(subs) == 0 {
+		return fmt.Errorf("unable to find record or sub-collections named %v: %w", dir, fs.ErrNotExist)
+	}
+	d.forgetKeyCase(dir, "")
+	d.forgetUsage(dir)
+	for _, sub := range subs {
+		d.notify(EventDelete, sub, "")
+	}
+	return nil
+}
+
+func (n *Namespace) SubCollections(collection, resource string) ([]string, error) {
+	c, err := n.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return n.d.SubCollections(c, resource)
+}
+
+func (n *Namespace) DeleteTree(collection, resource string) error {
+	c, err := n.collection(collection)
+	if err != nil {
+		return err
+	}
+	return n.d.DeleteTree(c, resource)
+}
+
+// CollectionTree returns the paths of the sub-collections of every record
+// in collection, at any depth, each before its own sub-collections. Those
+// of records that were deleted, or never written, are included.
+func (d *Driver) CollectionTree(collection string) ([]string, error) {
+	return d.CollectionTreeContext(context.Background(), collection)
+}
+
+// CollectionTreeContext is CollectionTree with a context carrying the
+// caller.
+func (d *Driver) CollectionTreeContext(ctx context.Context, collection string) ([]string, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection - no place to find sub-collections")
+	}
+	if err := validCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return nil, err
+	}
+	return d.collectionTree(collection)
+}
+
+// collectionTree returns the sub-collections of every record in collection,
+// at any depth, each before its own sub-collections.
+func (d *Driver) collectionTree(collection string) ([]string, error) {
+	dirs, err := d.listDirs(collection)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tree []string
+	for _, dir := range dirs {
+		// Other directories hold the sidecars of the records.
+		if validParentKey(dir) != nil {
+			continue
+		}
+		subs, err := d.nestedCollections(path.Join(collection, dir))
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, subs...)
+	}
+	return tree, nil
+}
+
+// nestedCollections returns the sub-collections in the directory of a
+// record, at any depth, each before its own sub-collections.
+func (d *Driver) nestedCollections(dir string) ([]string, error) {
+	names, err := d.listDirs(dir)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var subs []string
+	for _, name := range names {
+		if validName("collection", name) != nil {
+			continue
+		}
+		sub := path.Join(dir, name)
+		tree, err := d.collectionTree(sub)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+		subs = append(subs, tree...)
+	}
+	return subs, nil
+}
+
+// lockCollections locks the collections in path order, the order every
+// caller locking several collections uses, and returns the func unlocking
+// them. Views come after their sources, whose changes lock them.
+func (d *Driver) lockCollections(collections []string) func() {
+	collections = append([]string(nil), collections...)
+	sort.Slice(collections, func(i, j int) bool {
+		if ri, rj := d.viewRank(collections[i]), d.viewRank(collections[j]); ri != rj {
+			return ri < rj
+		}
+		return collections[i] < collections[j]
+	})
+	mutexes := make([]*sync.Mutex, 0, len(collections))
+	for _, c := range collections {
+		mutexes = append(mutexes, d.GetOrCreateMutex(c))
+	}
+	for _, m := range mutexes {
+		m.Lock()
+	}
+	return func() {
+		for _, m := range mutexes {
+			m.Unlock()
+		}
+	}
+}
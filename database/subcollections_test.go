@@ -0,0 +1,145 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestSubCollections(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	orders := SubCollection("users", "Waqar", "orders")
+	if orders != "users/Waqar/orders" {
+		t.Fatalf("SubCollection = %q", orders)
+	}
+	for _, w := range []struct{ collection, key string }{
+		{"users", "Waqar"},
+		{orders, "o1"},
+		{"users/Waqar/orders/o1/items", "i1"},
+		{"users/Waqar/notes", "n1"},
+		// A sub-collection needs no parent record.
+		{"users/Bob/orders", "o2"},
+	} {
+		if err := d.Write(w.collection, w.key, map[string]string{"key": w.key}); err != nil {
+			t.Fatalf("Write %s/%s: %v", w.collection, w.key, err)
+		}
+	}
+
+	if keys, err := d.Keys("users"); err != nil || strings.Join(keys, ",") != "Waqar" {
+		t.Errorf("Keys of the parent = %q, %v; want only its records", keys, err)
+	}
+	var v map[string]string
+	if err := d.Read(orders, "o1", &v); err != nil || v["key"] != "o1" {
+		t.Errorf("Read in a sub-collection = %v, %v", v, err)
+	}
+	if subs, err := d.SubCollections("users", "Waqar"); err != nil || strings.Join(subs, ",") != "notes,orders" {
+		t.Errorf("SubCollections = %q, %v", subs, err)
+	}
+	if subs, err := d.SubCollections("users", "nobody"); err != nil || len(subs) != 0 {
+		t.Errorf("SubCollections of a missing record = %q, %v", subs, err)
+	}
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+
+	for _, bad := range []string{"users/Waqar", "users/.x/o", "users/a%b/o", "users/W.attachments/o", "users//o", "users/Waqar/orders/"} {
+		if err := d.Write(bad, "k", 1); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Write to %q = %v, want ErrInvalidName", bad, err)
+		}
+	}
+
+	// Deleting the record leaves its sub-collections.
+	if err := d.Delete("users", "Waqar"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := d.Keys(orders); len(keys) != 1 {
+		t.Errorf("sub-collection after deleting its record = %q", keys)
+	}
+}
+
+func TestDeleteTree(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, collection := range []string{"users", "users/Waqar/orders", "users/Waqar/orders/o1/items", "users/Bob/orders"} {
+		if err := d.Write(collection, "Waqar", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, stop, err := d.Watch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if err := d.DeleteTree("users", "Waqar"); err != nil {
+		t.Fatal(err)
+	}
+	for _, collection := range []string{"users", "users/Waqar/orders", "users/Waqar/orders/o1/items"} {
+		if keys, _ := d.Keys(collection); len(keys) != 0 {
+			t.Errorf("%s after DeleteTree = %q", collection, keys)
+		}
+	}
+	if keys, _ := d.Keys("users/Bob/orders"); len(keys) != 1 {
+		t.Errorf("DeleteTree removed another record's sub-collection: %q", keys)
+	}
+	for i := 0; i < 3; i++ {
+		if e := <-events; e.Op != EventDelete {
+			t.Errorf("event %+v, want a delete", e)
+		}
+	}
+
+	if err := d.DeleteTree("users", "Waqar"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteTree of nothing = %v, want fs.ErrNotExist", err)
+	}
+	// Sub-collections alone are enough.
+	if err := d.DeleteTree("users", "Bob"); err != nil {
+		t.Errorf("DeleteTree of a record with only sub-collections = %v", err)
+	}
+	if _, err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if paths, err := d.collectionPaths(); err != nil || len(paths) != 0 {
+		t.Errorf("collections left after compacting = %q, %v", paths, err)
+	}
+}
+
+func TestSubCollectionAccess(t *testing.T) {
+	role := &Role{Name: "r", Collections: map[string]Permission{"users": PermRead}}
+	for _, tc := range []struct {
+		collection string
+		perm       Permission
+		want       bool
+	}{
+		{"users/A/orders", PermRead, true},
+		{"users/A/orders", PermWrite, false},
+		{".namespaces/t/users/A/orders", PermRead, true},
+		{"orders", PermRead, false},
+	} {
+		if got := role.Allows(tc.collection, tc.perm); got != tc.want {
+			t.Errorf("Allows(%q, %v) = %v, want %v", tc.collection, tc.perm, got, tc.want)
+		}
+	}
+
+	d, err := NewMemory(&Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	n := d.Namespace("t")
+	if err := n.Write("users/A/orders", "o", 1); err != nil {
+		t.Fatal(err)
+	}
+	if records, _, err := n.Usage(); err != nil || records != 1 {
+		t.Errorf("namespace Usage = %d, %v; want the sub-collection's record", records, err)
+	}
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recorder is a TracerProvider keeping the spans started with it.
+type recorder struct {
+	noop.TracerProvider
+	mutex sync.Mutex
+	spans []*span
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	r *recorder
+}
+
+type span struct {
+	noop.Span
+	name   string
+	parent trace.SpanContext
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	errors int
+	ended  bool
+}
+
+func (r *recorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{r: r}
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &span{name: name, parent: trace.SpanContextFromContext(ctx), attrs: map[attribute.Key]attribute.Value{}}
+	t.r.mutex.Lock()
+	t.r.spans = append(t.r.spans, s)
+	t.r.mutex.Unlock()
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *span) IsRecording() bool { return !s.ended }
+
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *span) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *span) RecordError(error, ...trace.EventOption) { s.errors++ }
+
+func (s *span) End(...trace.SpanEndOption) { s.ended = true }
+
+func TestTracing(t *testing.T) {
+	r := &recorder{}
+	d, err := New(t.TempDir(), &Options{TracerProvider: r, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, TraceFlags: trace.FlagsSampled, Remote: true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), parent)
+	if err := d.WriteContext(ctx, "users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := d.ReadContext(ctx, "users", "missing", &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteContext(ctx, "users", "missing"); err == nil {
+		t.Fatal("Delete of a missing record succeeded")
+	}
+	if err := d.WriteContext(ctx, ".users", "ada", 1); err == nil {
+		t.Fatal("Write to an invalid collection succeeded")
+	}
+
+	if len(r.spans) != 4 {
+		t.Fatalf("recorded %d spans, want 4", len(r.spans))
+	}
+	write := r.spans[0]
+	if write.name != "write users" || !write.ended || write.parent.TraceID() != parent.TraceID() {
+		t.Errorf("write span %q, ended %v, parent %v", write.name, write.ended, write.parent)
+	}
+	if write.attrs["db.collection.name"].AsString() != "users" || write.attrs["owndb.key"].AsString() != "ada" || write.attrs["owndb.bytes"].AsInt64() == 0 {
+		t.Errorf("write span attributes %v", write.attrs)
+	}
+	// Missing records are answers, not failures.
+	for _, s := range r.spans[1:3] {
+		if s.status == codes.Error || s.errors > 0 {
+			t.Errorf("%s of a missing record marked failed", s.name)
+		}
+	}
+	if failed := r.spans[3]; failed.status != codes.Error || failed.errors != 1 {
+		t.Errorf("failed write span status %v with %d errors", failed.status, failed.errors)
+	}
+}
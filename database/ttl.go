@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+)
+
+// Expiry times live in a sidecar per record under <collection>/.ttl.
+// Expired records read as missing until the sweeper removes them.
+const (
+	ttlDir                  = ".ttl"
+	defaultTTLSweepInterval = time.Minute
+)
+
+func ttlPath(p string) string {
+	return path.Join(path.Dir(p), ttlDir, path.Base(p)+".ttl")
+}
+
+// expiresAt returns when the record at p expires, or the zero time if it
+// does not.
+func (d *Driver) expiresAt(p string) (time.Time, error) {
+	b, err := d.backend.Get(ttlPath(p))
+	if isNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b)))
+}
+
+func (d *Driver) expired(p string) (bool, error) {
+	t, err := d.expiresAt(p)
+	return err == nil && !t.IsZero() && !time.Now().Before(t), err
+}
+
+// setExpiry records when the record at p expires; the zero time clears
+// the expiry. Callers hold the collection lock.
+func (d *Driver) setExpiry(p string, t time.Time) error {
+	if t.IsZero() {
+		if err := d.deleteFile(ttlPath(p)); err != nil && !isNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return d.replaceFile(ttlPath(p), []byte(t.UTC().Format(time.RFC3339Nano)+"\n"))
+}
+
+// expiredStems returns the file stems of the collection's expired records.
+func (d *Driver) expiredStems(collection string) (map[string]bool, error) {
+	files, err := d.backend.List(path.Join(collection, ttlDir))
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stems := make(map[string]bool)
+	for _, file := range files {
+		stem, ok := strings.CutSuffix(file, ".ttl")
+		if !ok || isDirName(file) {
+			continue
+		}
+		expired, err := d.expired(path.Join(collection, stem))
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			stems[stem] = true
+		}
+	}
+	return stems, nil
+}
+
+// WriteTTL is Write for a record that expires after ttl.
+func (d *Driver) WriteTTL(collection string, resource string, v interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl %v - must be positive", ttl)
+	}
+	return d.write(context.Background(), collection, resource, v, time.Now().Add(ttl))
+}
+
+// Touch makes an existing record expire after ttl from now, or never if ttl
+// is zero.
+func (d *Driver) Touch(collection string, resource string, ttl time.Duration) error {
+	return d.TouchContext(context.Background(), collection, resource, ttl)
+}
+
+// TouchContext is Touch with a context carrying the caller.
+func (d *Driver) TouchContext(ctx context.Context, collection string, resource string, ttl time.Duration) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if collection == "" {
+		return fmt.Errorf("missing collection - no record to touch")
+	}
+	if resource == "" {
+		return fmt.Errorf("missing resource - unable to touch record (no name)")
+	}
+	if ttl < 0 {
+		return fmt.Errorf("invalid ttl %v - must not be negative", ttl)
+	}
+	if err := validCollection(collection); err != nil {
+		return err
+	}
+	if err := d.checkView(collection); err != nil {
+		return err
+	}
+	if err := d.authorizeContext(ctx, collection, PermWrite); err != nil {
+		return err
+	}
+
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	p := d.recordPath(collection, resource)
+	if _, _, err := d.getLiveRecord(p); err != nil {
+		if isNotExist(err) {
+			return fmt.Errorf("unable to find record %s/%s to touch: %w", collection, resource, fs.ErrNotExist)
+		}
+		return err
+	}
+
+	var t time.Time
+	if ttl > 0 {
+		t = time.Now().Add(ttl)
+	}
+	if err := d.setExpiry(p, t); err != nil {
+		return err
+	}
+	d.updateViews(EventPut, collection, resource)
+	return nil
+}
+
+// ExpiresAt returns when a record expires, or the zero time if it does not.
+func (d *Driver) ExpiresAt(collection string, resource string) (time.Time, error) {
+	return d.ExpiresAtContext(context.Background(), collection, resource)
+}
+
+// ExpiresAtContext is ExpiresAt with a context carrying the caller.
+func (d *Driver) ExpiresAtContext(ctx context.Context, collection string, resource string) (time.Time, error) {
+	if err := d.checkOpen(); err != nil {
+		return time.Time{}, err
+	}
+	if collection == "" || resource == "" {
+		return time.Time{}, fmt.Errorf("missing collection or resource")
+	}
+	if err := validCollection(collection); err != nil {
+		return time.Time{}, err
+	}
+	if err := d.authorizeContext(ctx, collection, PermRead); err != nil {
+		return time.Time{}, err
+	}
+	return d.expiresAt(d.recordPath(collection, resource))
+}
+
+// getLiveRecord is getRecord that reports expired records as missing.
+func (d *Driver) getLiveRecord(p string) (string, []byte, error) {
+	expired, err := d.expired(p)
+	if err != nil {
+		return "", nil, err
+	}
+	if expired {
+		return "", nil, notExist("open", p)
+	}
+	return d.getRecord(p)
+}
+
+// sweepExpired deletes expired records until the Driver is closed.
+func (d *Driver) sweepExpired(interval time.Duration) {
+	d.goBackground(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if n, err := d.SweepExpired(); err != nil {
+					d.logEvent(slog.LevelError, "Sweeping expired records failed", slog.Any("error", err))
+				} else if n > 0 {
+					d.logEvent(slog.LevelDebug, "Swept expired records", slog.Int("records", n))
+				}
+				if n, err := d.PruneSeries(); err != nil {
+					d.logEvent(slog.LevelError, "Pruning time series failed", slog.Any("error", err))
+				} else if n > 0 {
+					d.logEvent(slog.LevelDebug, "Pruned time series", slog.Int("days", n))
+				}
+				if n, err := d.sweepIdempotencyKeys(); err != nil {
+					d.logEvent(slog.LevelError, "Sweeping idempotency keys failed", slog.Any("error", err))
+				} else if n > 0 {
+					d.logEvent(slog.LevelDebug, "Swept idempotency keys", slog.Int("keys", n))
+				}
+			}
+		}
+	})
+}
+
+// SweepExpired deletes every expired record now and returns how many were
+// removed. It runs in the background every Options.TTLSweepInterval.
+func (d *Driver) SweepExpired() (int, error) {
+	if err := d.checkWritable(); err != nil {
+		return 0, err
+	}
+	collections, err := d.collectionPaths()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	d.sweeperRuns.Add(1)
+	defer func() {
+		d.sweptRecords.Add(int64(n))
+		d.lastSweep.Store(time.Now().UnixNano())
+	}()
+	for _, collection := range collections {
+		stems, err := d.expiredStems(collection)
+		if err != nil {
+			return n, err
+		}
+		for stem := range stems {
+			removed, err := d.sweepRecord(collection, stem)
+			if err != nil {
+				return n, err
+			}
+			if removed {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (d *Driver) sweepRecord(collection, stem string) (bool, error) {
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Rewritten or touched since it was listed.
+	p := path.Join(collection, stem)
+	if expired, err := d.expired(p); err != nil || !expired {
+		return false, err
+	}
+	return true, d.deleteRecord(collection, decodeKey(stem), p)
+}
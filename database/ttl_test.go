@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestWriteTTL(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteTTL("sessions", "a", map[string]int{"n": 1}, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("sessions", "b", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := d.ExpiresAt("sessions", "a"); err != nil || at.IsZero() {
+		t.Errorf("ExpiresAt = %v, %v", at, err)
+	}
+	if at, err := d.ExpiresAt("sessions", "b"); err != nil || !at.IsZero() {
+		t.Errorf("ExpiresAt of a record without a TTL = %v, %v", at, err)
+	}
+	if err := d.WriteTTL("sessions", "c", 1, 0); err == nil {
+		t.Error("WriteTTL with a zero TTL succeeded")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	var v map[string]int
+	if err := d.Read("sessions", "a", &v); err != nil || v != nil {
+		t.Errorf("Read of an expired record = %v, %v", v, err)
+	}
+	if keys, err := d.Keys("sessions"); err != nil || len(keys) != 1 || keys[0] != "b" {
+		t.Errorf("Keys = %v, %v; want only b", keys, err)
+	}
+	if n, err := d.SweepExpired(); err != nil || n != 1 {
+		t.Fatalf("SweepExpired = %d, %v; want 1", n, err)
+	}
+	if n, err := d.SweepExpired(); err != nil || n != 0 {
+		t.Errorf("second SweepExpired = %d, %v", n, err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteTTL("sessions", "a", 1, 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Touch("sessions", "a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	var v int
+	if err := d.Read("sessions", "a", &v); err != nil || v != 1 {
+		t.Fatalf("Read after Touch = %v, %v", v, err)
+	}
+	if err := d.Touch("sessions", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := d.ExpiresAt("sessions", "a"); err != nil || !at.IsZero() {
+		t.Errorf("ExpiresAt after Touch with no TTL = %v, %v", at, err)
+	}
+	if err := d.Touch("sessions", "missing", time.Hour); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Touch of a missing record = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestTTLSweeper(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{TTLSweepInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteTTL("sessions", "a", 1, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	file := d.recordFile(d.recordPath("sessions", "a"), CompressionNone)
+	if ok, err := d.pathExists(file); err != nil || !ok {
+		t.Fatalf("record not written: %v", err)
+	}
+	for i := 0; ; i++ {
+		if ok, err := d.pathExists(file); err != nil || !ok {
+			break
+		}
+		if i == 100 {
+			t.Fatal("expired record never swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
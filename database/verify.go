@@ -0,0 +1,169 @@
+package database
+
+import (
+	"path"
+	"strings"
+)
+
+type Issue struct {
+	Path    string
+	Problem string
+}
+
+type VerifyReport struct {
+	Records int
+	Issues  []Issue
+}
+
+func (r *VerifyReport) add(p, problem string) {
+	r.Issues = append(r.Issues, Issue{Path: p, Problem: problem})
+}
+
+// Verify reads back every record of every collection and reports records
+// that no longer decode, leftover temp files, stray files and sidecars whose
+// record is gone. It changes nothing.
+func (d *Driver) Verify() (*VerifyReport, error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := d.authorize("*", PermRead); err != nil {
+		return nil, err
+	}
+
+	collections, err := d.collectionPaths()
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{}
+	for _, collection := range collections {
+		if err := d.verifyCollection(collection, report); err != nil {
+			return nil, err
+		}
+	}
+	d.lastVerify.Store(report)
+	return report, nil
+}
+
+func (d *Driver) verifyCollection(collection string, report *VerifyReport) error {
+	mutex := d.GetOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.backend.List(collection)
+	if err != nil {
+		return err
+	}
+
+	stems := make(map[string]bool)
+	var sidecars []string
+	for _, file := range files {
+		p := path.Join(collection, file)
+		stem, isRecord := d.recordStem(collection, file)
+		switch {
+		case isDirName(file):
+			sidecars = append(sidecars, file)
+		case strings.HasSuffix(file, ".tmp"):
+			report.add(p, "leftover temp file")
+		case file == sequenceFile:
+		case file == manifestFile:
+			if err := d.verifyManifest(collection); err != nil {
+				report.add(p, err.Error())
+			}
+		case !isRecord:
+			report.add(p, "stray file")
+		case stems[stem]:
+			report.add(p, "record is also stored with another compression")
+		default:
+			stems[stem] = true
+			report.Records++
+			if err := d.verifyRecord(collection, stem, file); err != nil {
+				report.add(p, err.Error())
+			}
+		}
+	}
+
+	for _, dir := range sidecars {
+		dir = strings.TrimSuffix(dir, "/")
+		switch {
+		case dir == recordKeysDir:
+			d.verifySidecars(collection, dir, ".key", stems, "field key of a deleted record", report)
+		case dir == ttlDir:
+			d.verifySidecars(collection, dir, ".ttl", stems, "expiry of a deleted record", report)
+		case dir == metaDir:
+			d.verifySidecars(collection, dir, ".meta", stems, "metadata of a deleted record", report)
+		case strings.HasSuffix(dir, attachmentsSuffix):
+			if !stems[strings.TrimSuffix(dir, attachmentsSuffix)] {
+				report.add(path.Join(collection, dir), "attachments of a deleted record")
+			}
+		case validParentKey(dir) == nil:
+			d.verifyRecordDir(path.Join(collection, dir), report)
+		default:
+			report.add(path.Join(collection, dir), "unexpected directory")
+		}
+	}
+	return nil
+}
+
+func (d *Driver) verifyManifest(collection string) error {
+	m, err := d.readManifest(collection)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, err = d.manifestOptions(collection, m)
+	return err
+}
+
+func (d *Driver) verifyRecord(collection, stem, file string) error {
+	b, err := d.backend.Get(path.Join(collection, file))
+	if err != nil {
+		return err
+	}
+	if b, err = d.decodeRecord(file, b); err != nil {
+		return err
+	}
+	if b, err = d.decryptFields(collection, decodeKey(stem), b); err != nil {
+		return err
+	}
+	var v interface{}
+	return d.codecFor(collection).Unmarshal(b, &v)
+}
+
+// verifyRecordDir checks that the directory of a record holds nothing but
+// its sub-collections, which are verified on their own.
+func (d *Driver) verifyRecordDir(dir string, report *VerifyReport) {
+	files, err := d.backend.List(dir)
+	if err != nil {
+		report.add(dir, err.Error())
+		return
+	}
+	for _, file := range files {
+		p := path.Join(dir, file)
+		switch {
+		case !isDirName(file):
+			report.add(p, "stray file")
+		case validName("collection", strings.TrimSuffix(file, "/")) != nil:
+			report.add(p, "unexpected directory")
+		}
+	}
+}
+
+func (d *Driver) verifySidecars(collection, dir, ext string, stems map[string]bool, problem string, report *VerifyReport) {
+	files, err := d.backend.List(path.Join(collection, dir))
+	if err != nil {
+		report.add(path.Join(collection, dir), err.Error())
+		return
+	}
+	for _, file := range files {
+		p := path.Join(collection, dir, file)
+		switch stem, ok := strings.CutSuffix(file, ext); {
+		case strings.HasSuffix(file, ".tmp"):
+			report.add(p, "leftover temp file")
+		case !ok || isDirName(file):
+			report.add(p, "stray file")
+		case !stems[stem]:
+			report.add(p, problem)
+		}
+	}
+}
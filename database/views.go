@@ -0,0 +1,263 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// View is a collection the Driver keeps filled with a projection of the
+// records of another collection that pass a filter. Views are rebuilt when
+// the Driver opens and then updated with every change to their source, in
+// the same order, so readers of a view never need to scan the source.
+// Their records have the keys and expiry of the source records.
+//
+// A view is read-only. Its records are written with the view collection's
+// own options, so give it EncryptedFields to keep projected fields
+// encrypted.
+type View struct {
+	// Name is the view collection, Source the collection it is built from.
+	// Both are top-level collection names, and Source may be a view.
+	Name   string
+	Source string
+	// Filter picks the source records in the view, all of them if nil.
+	// JSON numbers are json.Number.
+	Filter func(doc map[string]interface{}) bool
+	// Fields lists the fields to keep, with dots for nested fields, or nil
+	// to keep whole documents.
+	Fields []string
+}
+
+func (d *Driver) openViews(views []View) error {
+	d.views = make(map[string]*View)
+	d.viewsBySource = make(map[string][]*View)
+	for i := range views {
+		v := &views[i]
+		if err := validName("collection", v.Name); err != nil {
+			return err
+		}
+		if err := validName("collection", v.Source); err != nil {
+			return err
+		}
+		if _, ok := d.views[v.Name]; ok {
+			return fmt.Errorf("duplicate view %s", v.Name)
+		}
+		for _, f := range v.Fields {
+			if err := validField(f); err != nil {
+				return fmt.Errorf("view %s: %w", v.Name, err)
+			}
+		}
+		d.views[v.Name] = v
+		d.viewsBySource[v.Source] = append(d.viewsBySource[v.Source], v)
+	}
+	for name := range d.views {
+		if d.viewRank(name) < 0 {
+			return fmt.Errorf("view %s is built from itself", name)
+		}
+	}
+
+	if d.readOnly {
+		return nil
+	}
+	names := make([]string, 0, len(d.views))
+	for name := range d.views {
+		names = append(names, name)
+	}
+	// Views of views are rebuilt after their source.
+	sort.Slice(names, func(i, j int) bool { return d.viewRank(names[i]) < d.viewRank(names[j]) })
+	for _, name := range names {
+		if err := d.RebuildView(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// viewRank is 0 for collections that are not views and one more than the
+// rank of its source for a view, or -1 for a view built from itself.
+func (d *Driver) viewRank(collection string) int {
+	rank := 0
+	for v, ok := d.views[collection]; ok; v, ok = d.views[v.Source] {
+		if rank++; rank > len(d.views) {
+			return -1
+		}
+	}
+	return rank
+}
+
+func (d *Driver) checkView(collection string) error {
+	if _, ok := d.views[collection]; ok {
+		return fmt.Errorf("%w: collection %s is a view", ErrReadOnly, collection)
+	}
+	return nil
+}
+
+// RebuildView recomputes a view from all of its source, for views whose
+// source changed while the Driver was closed or outside it.
+func (d *Driver) RebuildView(name string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	v, ok := d.views[name]
+	if !ok {
+		return fmt.Errorf("unable to find view named %v: %w", name, fs.ErrNotExist)
+	}
+
+	mutex := d.GetOrCreateMutex(v.Source)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	keys, err := d.liveKeys(v.Source)
+	if err != nil {
+		return err
+	}
+	source := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		source[key] = true
+		if err := d.updateView(v, key); err != nil {
+			return err
+		}
+	}
+
+	stale, err := d.liveKeys(v.Name)
+	if err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if !source[key] {
+			if err := d.deleteViewRecord(v, key); err != nil {
+				return err
+			}
+		}
+	}
+	d.logEvent(slog.LevelDebug, "Rebuilt view", slog.String("view", v.Name), slog.Int("records", len(keys)))
+	return nil
+}
+
+func (d *Driver) liveKeys(collection string) ([]string, error) {
+	files, err := d.backend.List(collection)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	expired, err := d.expiredStems(collection)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, file := range files {
+		if stem, ok := d.recordStem(collection, file); ok && !isDirName(file) && !expired[stem] {
+			keys = append(keys, decodeKey(stem))
+		}
+	}
+	return keys, nil
+}
+
+// updateViews applies a change to a collection to the views built from it.
+// Callers hold the collection lock, which keeps the view in step with it;
+// a failure leaves the view stale until it is rebuilt.
+func (d *Driver) updateViews(op EventOp, collection, key string) {
+	for _, v := range d.viewsBySource[collection] {
+		var err error
+		switch {
+		case key != "":
+			err = d.updateView(v, key)
+		case op == EventDelete:
+			err = d.clearView(v)
+		}
+		if err != nil {
+			d.logEvent(slog.LevelError, "Updating view failed", slog.String("view", v.Name), slog.String("key", key), slog.Any("error", err))
+		}
+	}
+}
+
+// updateView writes the projection of a source record to the view, or
+// deletes it from the view if the record is gone or no longer passes the
+// filter.
+func (d *Driver) updateView(v *View, key string) error {
+	p := d.recordPath(v.Source, key)
+	b, err := d.readRecord(v.Source, key)
+	if isNotExist(err) {
+		return d.deleteViewRecord(v, key)
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := d.decodeDocument(v.Source, b)
+	if err != nil {
+		return err
+	}
+	if v.Filter != nil && !v.Filter(doc) {
+		return d.deleteViewRecord(v, key)
+	}
+
+	if v.Fields != nil {
+		doc = project(doc, v.Fields)
+	}
+	if b, err = d.encodeDocument(v.Name, doc); err != nil {
+		return err
+	}
+	expires, err := d.expiresAt(p)
+	if err != nil {
+		return err
+	}
+	// Rebuilds leave records that are already up to date alone.
+	if old, err := d.readRecord(v.Name, key); err == nil && bytes.Equal(old, b) {
+		if t, err := d.expiresAt(d.recordPath(v.Name, key)); err == nil && t.Equal(expires) {
+			return nil
+		}
+	}
+	return d.writeRecord(nil, v.Name, key, bytes.NewReader(b), expires)
+}
+
+func (d *Driver) deleteViewRecord(v *View, key string) error {
+	mutex := d.GetOrCreateMutex(v.Name)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err := d.deleteRecord(v.Name, key, d.recordPath(v.Name, key)); !isNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *Driver) clearView(v *View) error {
+	mutex := d.GetOrCreateMutex(v.Name)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err := d.backend.Delete(v.Name); err != nil && !isNotExist(err) {
+		return err
+	}
+	d.forgetKeyCase(v.Name, "")
+	d.forgetUsage(v.Name)
+	d.notify(EventDelete, v.Name, "")
+	return nil
+}
+
+// project copies the fields of doc at the given paths into a new document,
+// keeping their nesting.
+func project(doc map[string]interface{}, fields []string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, f := range fields {
+		val, ok := getField(doc, f)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(f, ".")
+		m := out
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := m[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				m[part] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = val
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testViews() []View {
+	return []View{
+		{Name: "active_users", Source: "users", Filter: func(doc map[string]interface{}) bool {
+			return doc["active"] == true
+		}, Fields: []string{"name", "address.city"}},
+		{Name: "karachi", Source: "active_users", Filter: func(doc map[string]interface{}) bool {
+			a, _ := doc["address"].(map[string]interface{})
+			return a["city"] == "Karachi"
+		}},
+	}
+}
+
+func viewUser(name string, active bool, city string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "active": active, "age": 30, "address": map[string]interface{}{"city": city, "zip": "75500"}}
+}
+
+func joinedKeys(t *testing.T, d *Driver, collection string) string {
+	t.Helper()
+	keys, err := d.Keys(collection)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestViews(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{Views: testViews(), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for key, u := range map[string]map[string]interface{}{
+		"a": viewUser("A", true, "Karachi"),
+		"b": viewUser("B", false, "Karachi"),
+		"c": viewUser("C", true, "Lahore"),
+	} {
+		if err := d.Write("users", key, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := joinedKeys(t, d, "active_users"); got != "a,c" {
+		t.Errorf("active_users = %s, want a,c", got)
+	}
+	if got := joinedKeys(t, d, "karachi"); got != "a" {
+		t.Errorf("view of a view = %s, want a", got)
+	}
+	var v map[string]interface{}
+	if err := d.Read("active_users", "a", &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 2 || v["name"] != "A" || v["address"].(map[string]interface{})["zip"] != nil {
+		t.Errorf("projected record = %v, want the name and city only", v)
+	}
+
+	if err := d.Write("users", "a", viewUser("A", false, "Karachi")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("users", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := joinedKeys(t, d, "active_users"); got != "" {
+		t.Errorf("active_users after deactivating and deleting = %s", got)
+	}
+	if got := joinedKeys(t, d, "karachi"); got != "" {
+		t.Errorf("karachi after deactivating = %s", got)
+	}
+
+	if err := d.Write("active_users", "x", 1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write to a view = %v, want ErrReadOnly", err)
+	}
+	if err := d.Delete("karachi", "a"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete in a view = %v, want ErrReadOnly", err)
+	}
+
+	if err := d.WriteTTL("users", "t", viewUser("T", true, "Quetta"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	source, _ := d.ExpiresAt("users", "t")
+	view, err := d.ExpiresAt("active_users", "t")
+	if err != nil || !view.Equal(source) {
+		t.Errorf("view record expires at %v, %v; want %v", view, err, source)
+	}
+
+	if _, err := d.DropCollection(context.Background(), "users", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := joinedKeys(t, d, "active_users"); got != "" {
+		t.Errorf("active_users after dropping users = %s", got)
+	}
+}
+
+func TestRebuildViews(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{Views: testViews(), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "z", viewUser("Z", true, "Karachi")); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	// Change the source without the views.
+	d, err = New(dir, &Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("users", "z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "y", viewUser("Y", true, "Karachi")); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err = New(dir, &Options{Views: testViews(), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got := joinedKeys(t, d, "active_users"); got != "y" {
+		t.Errorf("active_users after reopening = %s, want y", got)
+	}
+	if got := joinedKeys(t, d, "karachi"); got != "y" {
+		t.Errorf("karachi after reopening = %s, want y", got)
+	}
+	if report, err := d.Verify(); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+	if err := d.RebuildView("nothing"); err == nil {
+		t.Error("RebuildView of an unknown view succeeded")
+	}
+}
+
+func TestViewOptions(t *testing.T) {
+	for _, views := range [][]View{
+		{{Name: "a", Source: "b"}, {Name: "b", Source: "a"}},
+		{{Name: "a", Source: "a"}},
+		{{Name: "a", Source: "users"}, {Name: "a", Source: "posts"}},
+		{{Name: ".a", Source: "users"}},
+		{{Name: "a", Source: "users", Fields: []string{"address..city"}}},
+	} {
+		if d, err := New(t.TempDir(), &Options{Views: views, TTLSweepInterval: -1}); err == nil {
+			d.Close()
+			t.Errorf("New with views %+v succeeded", views)
+		}
+	}
+}
+
+func TestViewsConcurrentWrites(t *testing.T) {
+	d, err := New(t.TempDir(), &Options{Views: testViews(), TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := d.Write("users", "k", viewUser(fmt.Sprint(i), i%2 == 0, "Karachi")); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var source, view map[string]interface{}
+	if err := d.Read("users", "k", &source); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Read("active_users", "k", &view)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case source["active"] == true && view["name"] != source["name"]:
+		t.Errorf("view has %v, source %v", view["name"], source["name"])
+	case source["active"] != true && view != nil:
+		t.Errorf("view has inactive %v", view)
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// watchBuffer is how many events a watcher may fall behind before it is
+// dropped.
+const watchBuffer = 256
+
+type EventOp int
+
+const (
+	EventPut EventOp = iota + 1
+	EventDelete
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("EventOp(%d)", int(op))
+}
+
+// Event reports a change to a record. Deleting a whole collection is
+// reported as a single EventDelete with an empty Key.
+type Event struct {
+	Op         EventOp
+	Collection string
+	Key        string
+	// External reports a change another process made to the files, found
+	// with Options.WatchExternal.
+	External bool
+}
+
+type watcher struct {
+	collection string
+	ch         chan Event
+}
+
+// Watch streams the changes made through this Driver to collection, or to
+// every collection if it is empty, until stop is called or the Driver is
+// closed. Events of one collection arrive in the order they were applied.
+// A watcher that falls too far behind is dropped; either way the channel is
+// closed, and a consumer that needs to stay in sync should re-read the
+// collection after that.
+func (d *Driver) Watch(collection string) (events <-chan Event, stop func(), err error) {
+	return d.WatchContext(context.Background(), collection)
+}
+
+// WatchContext is Watch on behalf of the Principal in ctx, if any.
+func (d *Driver) WatchContext(ctx context.Context, collection string) (events <-chan Event, stop func(), err error) {
+	if err := d.checkOpen(); err != nil {
+		return nil, nil, err
+	}
+	scope := "*"
+	if collection != "" {
+		if err := validCollection(collection); err != nil {
+			return nil, nil, err
+		}
+		scope = collection
+	}
+	if err := d.authorizeContext(ctx, scope, PermRead); err != nil {
+		return nil, nil, err
+	}
+
+	w := &watcher{collection: collection, ch: make(chan Event, watchBuffer)}
+	d.watchMutex.Lock()
+	if d.watchers == nil {
+		d.watchers = make(map[*watcher]bool)
+	}
+	d.watchers[w] = true
+	d.watchMutex.Unlock()
+
+	var once sync.Once
+	return w.ch, func() { once.Do(func() { d.unwatch(w) }) }, nil
+}
+
+func (d *Driver) unwatch(w *watcher) {
+	d.watchMutex.Lock()
+	defer d.watchMutex.Unlock()
+	if d.watchers[w] {
+		delete(d.watchers, w)
+		close(w.ch)
+	}
+}
+
+// notify publishes a change to the watchers and applies it to the views
+// built from the collection and its geo index. Callers hold the collection
+// lock, which keeps the events of a collection in order.
+func (d *Driver) notify(op EventOp, collection, key string) {
+	d.notifyEvent(Event{Op: op, Collection: collection, Key: key})
+}
+
+func (d *Driver) notifyEvent(e Event) {
+	d.publish(e)
+	d.updateViews(e.Op, e.Collection, e.Key)
+	d.updateGeoIndexes(e.Op, e.Collection, e.Key)
+}
+
+func (d *Driver) publish(e Event) {
+	d.watchMutex.Lock()
+	defer d.watchMutex.Unlock()
+	for w := range d.watchers {
+		if w.collection != "" && w.collection != e.Collection {
+			continue
+		}
+		select {
+		case w.ch <- e:
+		default:
+			d.logEvent(slog.LevelWarn, "Dropping watcher that fell behind", slog.String("collection", w.collection), slog.Int("events", watchBuffer))
+			delete(d.watchers, w)
+			close(w.ch)
+		}
+	}
+}
+
+func (d *Driver) closeWatchers() {
+	d.watchMutex.Lock()
+	defer d.watchMutex.Unlock()
+	for w := range d.watchers {
+		close(w.ch)
+	}
+	d.watchers = nil
+}
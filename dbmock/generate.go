@@ -0,0 +1,7 @@
+// Package dbmock replaces a database.Database in unit tests: DatabaseMock
+// calls the functions a test sets and records the calls, and Nop does
+// nothing and finds nothing. Regenerate the mock after changing the
+// interface.
+package dbmock
+
+//go:generate moq -pkg dbmock -out mock.go ../database Database
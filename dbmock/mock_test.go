@@ -0,0 +1,34 @@
+package dbmock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// signup stands for application code depending on a database.Database.
+func signup(db database.Database, name string) error {
+	if err := db.Read("users", name, &struct{}{}); err == nil {
+		return errors.New("taken")
+	}
+	return db.Write("users", name, map[string]string{"name": name})
+}
+
+func TestDatabaseMock(t *testing.T) {
+	missing := errors.New("not found")
+	m := &DatabaseMock{
+		ReadFunc:  func(collection, resource string, v interface{}) error { return missing },
+		WriteFunc: func(collection, resource string, v interface{}) error { return nil },
+	}
+	if err := signup(m, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	calls := m.WriteCalls()
+	if len(calls) != 1 || calls[0].Collection != "users" || calls[0].Resource != "ada" {
+		t.Errorf("WriteCalls = %+v", calls)
+	}
+	if len(m.ReadCalls()) != 1 {
+		t.Errorf("ReadCalls = %+v", m.ReadCalls())
+	}
+}
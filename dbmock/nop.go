@@ -0,0 +1,160 @@
+package dbmock
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// Nop is a database.Database holding nothing: writes and deletes succeed
+// and are forgotten, reads of a record or saved query fail with
+// fs.ErrNotExist, listings are empty, and watches never get an event.
+type Nop struct{}
+
+var _ database.Database = Nop{}
+
+func notFound(collection, resource string) error {
+	return fmt.Errorf("unable to find file or directory named %v: %w", collection+"/"+resource, fs.ErrNotExist)
+}
+
+func (Nop) Write(collection, resource string, v interface{}) error { return nil }
+
+func (Nop) WriteContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return nil
+}
+
+func (Nop) Read(collection, resource string, v interface{}) error {
+	return notFound(collection, resource)
+}
+
+func (Nop) ReadContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return notFound(collection, resource)
+}
+
+func (Nop) ReadResolved(collection, resource string, v interface{}, depth int) error {
+	return notFound(collection, resource)
+}
+
+func (Nop) ReadResolvedContext(ctx context.Context, collection, resource string, v interface{}, depth int) error {
+	return notFound(collection, resource)
+}
+
+func (Nop) ReadAll(collection string) ([]string, error) { return []string{}, nil }
+
+func (Nop) ReadAllContext(ctx context.Context, collection string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (Nop) ReadAllResolved(collection string, depth int) ([]string, error) { return []string{}, nil }
+
+func (Nop) ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error) {
+	return []string{}, nil
+}
+
+func (Nop) Keys(collection string) ([]string, error) { return []string{}, nil }
+
+func (Nop) KeysContext(ctx context.Context, collection string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (Nop) Delete(collection, resource string) error { return nil }
+
+func (Nop) DeleteContext(ctx context.Context, collection, resource string) error { return nil }
+
+// DropCollection reports no records.
+func (Nop) DropCollection(ctx context.Context, collection string, dryRun bool) (*database.DeleteReport, error) {
+	return &database.DeleteReport{DryRun: dryRun, Records: map[string][]string{}}, nil
+}
+
+func (Nop) ReadRevision(collection, resource string, v interface{}) (string, error) {
+	return "", notFound(collection, resource)
+}
+
+func (Nop) ReadRevisionContext(ctx context.Context, collection, resource string, v interface{}) (string, error) {
+	return "", notFound(collection, resource)
+}
+
+// WriteIfRevision only succeeds creating a record, rev "", as no record
+// exists to match any other revision.
+func (n Nop) WriteIfRevision(collection, resource string, v interface{}, rev string) (string, error) {
+	return n.WriteIfRevisionContext(context.Background(), collection, resource, v, rev)
+}
+
+// WriteIfRevisionContext is WriteIfRevision with a context.
+func (Nop) WriteIfRevisionContext(ctx context.Context, collection, resource string, v interface{}, rev string) (string, error) {
+	if rev != "" {
+		return "", database.ErrConflict
+	}
+	return "", nil
+}
+
+// DeleteIfRevision always fails with database.ErrConflict, as no record
+// exists at any revision.
+func (Nop) DeleteIfRevision(collection, resource, rev string) error { return database.ErrConflict }
+
+// DeleteIfRevisionContext is DeleteIfRevision with a context.
+func (Nop) DeleteIfRevisionContext(ctx context.Context, collection, resource, rev string) error {
+	return database.ErrConflict
+}
+
+func (Nop) Aggregate(collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{}, nil
+}
+
+func (Nop) AggregateContext(ctx context.Context, collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{}, nil
+}
+
+func (Nop) SaveQuery(name string, q database.Query) error { return nil }
+
+func (Nop) SaveQueryContext(ctx context.Context, name string, q database.Query) error { return nil }
+
+func (Nop) SavedQuery(name string) (database.Query, error) {
+	return database.Query{}, fmt.Errorf("unable to find query named %v: %w", name, fs.ErrNotExist)
+}
+
+func (n Nop) SavedQueryContext(ctx context.Context, name string) (database.Query, error) {
+	return n.SavedQuery(name)
+}
+
+func (Nop) SavedQueries() ([]string, error) { return []string{}, nil }
+
+func (Nop) SavedQueriesContext(ctx context.Context) ([]string, error) { return []string{}, nil }
+
+func (Nop) DeleteQuery(name string) error { return nil }
+
+func (Nop) DeleteQueryContext(ctx context.Context, name string) error { return nil }
+
+func (n Nop) RunQuery(name string) ([]database.QueryResult, error) {
+	_, err := n.SavedQuery(name)
+	return nil, err
+}
+
+func (n Nop) RunQueryContext(ctx context.Context, name string) ([]database.QueryResult, error) {
+	return n.RunQuery(name)
+}
+
+// Watch returns a channel that only closes when stop is called.
+func (n Nop) Watch(collection string) (<-chan database.Event, func(), error) {
+	return n.WatchContext(context.Background(), collection)
+}
+
+// WatchContext is Watch with a context; the channel also closes when ctx
+// is done.
+func (Nop) WatchContext(ctx context.Context, collection string) (<-chan database.Event, func(), error) {
+	events := make(chan database.Event)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		close(events)
+	}()
+	return events, stop, nil
+}
@@ -0,0 +1,59 @@
+package dbmock
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestNop(t *testing.T) {
+	var n Nop
+	if err := n.Write("users", "a", 1); err != nil {
+		t.Errorf("Write = %v", err)
+	}
+	if err := n.Read("users", "a", nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read = %v, want fs.ErrNotExist", err)
+	}
+	if keys, err := n.Keys("users"); err != nil || keys == nil || len(keys) != 0 {
+		t.Errorf("Keys = %#v, %v; want an empty listing", keys, err)
+	}
+	if _, err := n.WriteIfRevision("users", "a", 1, "rev"); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("WriteIfRevision at a revision = %v, want ErrConflict", err)
+	}
+	if _, err := n.WriteIfRevision("users", "a", 1, ""); err != nil {
+		t.Errorf("WriteIfRevision creating = %v", err)
+	}
+	if report, err := n.DropCollection(context.Background(), "users", true); err != nil || !report.DryRun || report.Count() != 0 {
+		t.Errorf("DropCollection = %+v, %v; want an empty dry run", report, err)
+	}
+	if _, err := n.RunQuery("adults"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RunQuery = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestNopWatch(t *testing.T) {
+	var n Nop
+	ctx, cancel := context.WithCancel(context.Background())
+	events, stop, err := n.WatchContext(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Nop watch got an event")
+	}
+	stop()
+	stop()
+
+	events, stop, err = n.Watch("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if _, ok := <-events; ok {
+		t.Error("Nop watch got an event")
+	}
+}
@@ -0,0 +1,52 @@
+// Package dbtest helps test code using the database: New opens a Driver
+// that is removed when the test ends, the golden helpers compare
+// collections with expected contents, and Race runs concurrent workers to
+// shake out data races under go test -race.
+package dbtest
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// New returns a Driver on a fresh temporary directory, logging to the test
+// log, and closes it when the test and its subtests end.
+func New(t testing.TB) *database.Driver {
+	t.Helper()
+	return NewWithOptions(t, nil)
+}
+
+// NewWithOptions is New with options. A nil Logger logs to the test log.
+func NewWithOptions(t testing.TB, opts *database.Options) *database.Driver {
+	t.Helper()
+	o := database.Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Logger == nil {
+		o.Logger = database.NewSlogLogger(slog.New(slog.NewTextHandler(logWriter{t}, nil)))
+	}
+	d, err := database.New(t.TempDir(), &o)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := d.Close(); err != nil {
+			t.Errorf("closing database: %v", err)
+		}
+	})
+	return d
+}
+
+// logWriter writes each log line to the test log.
+type logWriter struct {
+	t testing.TB
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
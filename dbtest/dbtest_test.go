@@ -0,0 +1,34 @@
+package dbtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// failures records the errors a helper reports instead of failing the
+// test running it.
+type failures struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failures) Helper() {}
+
+func (f *failures) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNew(t *testing.T) {
+	var d *database.Driver
+	t.Run("open", func(t *testing.T) {
+		d = NewWithOptions(t, &database.Options{TTLSweepInterval: -1})
+		if err := d.Write("users", "a", 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := d.Keys("users"); err == nil {
+		t.Error("Driver still open once its test ended")
+	}
+}
@@ -0,0 +1,117 @@
+package dbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+var update = flag.Bool("dbtest.update", false, "rewrite the golden files of AssertGolden with the actual contents")
+
+// Contents returns the records of a collection by key, decoded as JSON
+// with json.Number numbers.
+func Contents(t testing.TB, d *database.Driver, collection string) map[string]interface{} {
+	t.Helper()
+	keys, err := d.Keys(collection)
+	if err != nil {
+		t.Fatalf("listing %s: %v", collection, err)
+	}
+	contents := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		var raw json.RawMessage
+		if err := d.Read(collection, key, &raw); err != nil {
+			t.Fatalf("reading %s/%s: %v", collection, key, err)
+		}
+		contents[key] = decode(t, raw)
+	}
+	return contents
+}
+
+// AssertCollection fails the test unless a collection holds exactly the
+// records of want, compared as JSON.
+func AssertCollection(t testing.TB, d *database.Driver, collection string, want map[string]interface{}) {
+	t.Helper()
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("encoding the expected contents of %s: %v", collection, err)
+	}
+	got := Contents(t, d, collection)
+	if expected := decode(t, b); !reflect.DeepEqual(got, expected) {
+		t.Errorf("collection %s:\n got: %s\nwant: %s", collection, marshal(t, got), marshal(t, expected))
+	}
+}
+
+// AssertGolden fails the test unless the collections hold what the golden
+// file records: a JSON object mapping each collection to its records by
+// key. Running the tests with -dbtest.update rewrites the file instead.
+func AssertGolden(t testing.TB, d *database.Driver, golden string, collections ...string) {
+	t.Helper()
+	snapshot := make(map[string]interface{}, len(collections))
+	for _, collection := range collections {
+		snapshot[collection] = Contents(t, d, collection)
+	}
+	got := append(marshal(t, snapshot), '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run with -dbtest.update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("contents differ from %s (run with -dbtest.update to accept them):\n%s", golden, firstDifference(string(got), string(want)))
+	}
+}
+
+func decode(t testing.TB, b []byte) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("decoding %s: %v", b, err)
+	}
+	return v
+}
+
+func marshal(t testing.TB, v interface{}) []byte {
+	t.Helper()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// firstDifference describes the first line where got and want differ.
+func firstDifference(got, want string) string {
+	g, w := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl string
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if gl != wl {
+			return "line " + strconv.Itoa(i+1) + ":\n got: " + gl + "\nwant: " + wl
+		}
+	}
+	return ""
+}
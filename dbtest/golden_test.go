@@ -0,0 +1,46 @@
+package dbtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertCollection(t *testing.T) {
+	d := New(t)
+	if err := d.Write("users", "a", map[string]interface{}{"n": 1, "tags": []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	AssertCollection(t, d, "users", map[string]interface{}{"a": map[string]interface{}{"n": 1, "tags": []string{"x"}}})
+
+	f := &failures{TB: t}
+	AssertCollection(f, d, "users", map[string]interface{}{"a": map[string]interface{}{"n": 2}})
+	if len(f.errors) != 1 || !strings.Contains(f.errors[0], "collection users") {
+		t.Errorf("AssertCollection of different contents reported %q", f.errors)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	d := New(t)
+	if err := d.Write("users", "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join(t.TempDir(), "testdata", "users.json")
+	*update = true
+	AssertGolden(t, d, golden, "users")
+	*update = false
+	if b, err := os.ReadFile(golden); err != nil || !strings.Contains(string(b), `"n": 1`) {
+		t.Fatalf("golden file = %s, %v", b, err)
+	}
+	AssertGolden(t, d, golden, "users")
+
+	if err := d.Write("users", "a", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+	f := &failures{TB: t}
+	AssertGolden(f, d, golden, "users")
+	if len(f.errors) != 1 || !strings.Contains(f.errors[0], `want:       "n": 1`) {
+		t.Errorf("AssertGolden of changed contents reported %q", f.errors)
+	}
+}
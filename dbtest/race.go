@@ -0,0 +1,45 @@
+package dbtest
+
+import (
+	"sync"
+	"testing"
+)
+
+// Race runs fn on workers goroutines released at the same moment, so they
+// contend as much as they can, and fails the test with every error they
+// return. Run it under go test -race to also catch unsynchronized access.
+func Race(t testing.TB, workers int, fn func(worker int) error) {
+	t.Helper()
+	start := make(chan struct{})
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("worker %d: %v", i, err)
+		}
+	}
+}
+
+// RaceN is Race with every worker calling fn iterations times, stopping at
+// its first error.
+func RaceN(t testing.TB, workers, iterations int, fn func(worker, iteration int) error) {
+	t.Helper()
+	Race(t, workers, func(worker int) error {
+		for i := 0; i < iterations; i++ {
+			if err := fn(worker, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
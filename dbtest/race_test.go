@@ -0,0 +1,28 @@
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRace(t *testing.T) {
+	d := New(t)
+	RaceN(t, 8, 20, func(worker, iteration int) error {
+		return d.Write("items", fmt.Sprintf("%d-%d", worker, iteration), map[string]int{"worker": worker})
+	})
+	if keys, err := d.Keys("items"); err != nil || len(keys) != 160 {
+		t.Errorf("Keys = %d keys, %v; want 160", len(keys), err)
+	}
+
+	f := &failures{TB: t}
+	RaceN(f, 4, 10, func(worker, iteration int) error {
+		if worker%2 == 1 && iteration == 3 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if len(f.errors) != 2 || f.errors[0] != "worker 1: boom" || f.errors[1] != "worker 3: boom" {
+		t.Errorf("RaceN reported %q, want the errors of workers 1 and 3", f.errors)
+	}
+}
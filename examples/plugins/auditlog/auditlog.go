@@ -0,0 +1,28 @@
+// Package auditlog registers the "auditlog" extension, as an example of a
+// hook plugin: it logs every write and delete with the principal that made
+// it to the default slog logger. Enable it with
+//
+//	import _ "github.com/siraiwaqarali/golang-own-database/examples/plugins/auditlog"
+//
+// and database.Options{Extensions: []string{"auditlog"}}, or dbserver's
+// -extensions flag.
+package auditlog
+
+import (
+	"log/slog"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func init() {
+	database.RegisterExtension("auditlog", func(d *database.Driver) error {
+		d.Instrument(func(op database.Op) {
+			if op.Name != "write" && op.Name != "delete" {
+				return
+			}
+			slog.Info("Audit", slog.String("op", op.Name), slog.String("collection", op.Collection),
+				slog.String("key", op.Key), slog.String("principal", op.Principal), slog.Bool("ok", op.Err == nil))
+		})
+		return nil
+	})
+}
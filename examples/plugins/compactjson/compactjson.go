@@ -0,0 +1,36 @@
+// Package compactjson registers the "compact-json" codec, JSON without
+// indentation, as an example of a codec plugin. Enable it with
+//
+//	import _ "github.com/siraiwaqarali/golang-own-database/examples/plugins/compactjson"
+//
+// and pick it with database.LookupCodec("compact-json"), or dbserver's
+// -codec flag. Its records read back with the default codec too.
+package compactjson
+
+import (
+	"encoding/json"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func init() {
+	database.RegisterCodec("compact-json", Codec{})
+}
+
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (Codec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+func (Codec) Extension() string {
+	return ".json"
+}
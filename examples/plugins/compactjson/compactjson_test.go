@@ -0,0 +1,26 @@
+package compactjson
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestCodec(t *testing.T) {
+	c, err := database.LookupCodec("compact-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Marshal(map[string]string{"name": "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte("{\"name\":\"Ada\"}\n")) {
+		t.Errorf("Marshal = %q, want compact JSON", b)
+	}
+	var v map[string]string
+	if err := (database.JSONCodec{}).Unmarshal(b, &v); err != nil || v["name"] != "Ada" {
+		t.Errorf("Unmarshal with the default codec = %v, %v", v, err)
+	}
+}
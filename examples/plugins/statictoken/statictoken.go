@@ -0,0 +1,35 @@
+// Package statictoken registers the "static-token" authentication
+// provider, as an example of an auth plugin. Its configuration lists
+// bearer tokens and the names of their callers, who keep the server's
+// role:
+//
+//	static-token:s3cret=ci,0ther=dashboard
+//
+// Enable it with
+//
+//	import _ "github.com/siraiwaqarali/golang-own-database/examples/plugins/statictoken"
+//
+// and dbserver's -auth flag, or auth.Open.
+package statictoken
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func init() {
+	auth.Register("static-token", func(config string) (auth.Authenticator, error) {
+		keys := make(map[string]*database.Principal)
+		for _, pair := range strings.Split(config, ",") {
+			token, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || token == "" || name == "" {
+				return nil, fmt.Errorf("invalid token %q - want <token>=<name>", pair)
+			}
+			keys[token] = &database.Principal{Name: name}
+		}
+		return auth.NewAPIKeys(keys), nil
+	})
+}
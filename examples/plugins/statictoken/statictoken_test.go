@@ -0,0 +1,23 @@
+package statictoken
+
+import (
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+)
+
+func TestProvider(t *testing.T) {
+	a, err := auth.Open("static-token:s3cret=ci, 0ther=dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := a.Authenticate(auth.Credentials{Token: "0ther"}); err != nil || p == nil || p.Name != "dashboard" {
+		t.Errorf("Authenticate = %v, %v", p, err)
+	}
+	if p, err := a.Authenticate(auth.Credentials{Token: "nope"}); err != nil || p != nil {
+		t.Errorf("Authenticate of an unknown token = %v, %v; want neither", p, err)
+	}
+	if _, err := auth.Open("static-token:s3cret"); err == nil {
+		t.Error("Open with a token missing its name succeeded")
+	}
+}
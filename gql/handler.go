@@ -0,0 +1,256 @@
+// Package gql serves collections through a GraphQL endpoint.
+//
+// Every collection becomes two query fields: the collection name, returning
+// a page of records, and <name>ByKey. Records carry their key as _key. The
+// page field takes limit (default 100, at most 1000), after (the last key
+// of the previous page) and where, an object of field values to match that
+// nests like the records do:
+//
+//	{ users(where: {address: {city: "Karachi"}}) { _key name address { city } } }
+//
+// Names that are not valid in GraphQL have their invalid characters
+// replaced with underscores.
+package gql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	defaultLimit      = 100
+	maxLimit          = 1000
+	defaultSampleSize = 100
+)
+
+type Options struct {
+	// Collections to expose; every top-level collection if empty.
+	Collections []string
+	// Schemas gives the record type of a collection. Collections without
+	// one have it inferred from up to SampleSize of their records.
+	Schemas    map[string]*Type
+	SampleSize int
+}
+
+type Handler struct {
+	db     *database.Driver
+	schema graphql.Schema
+}
+
+// NewHandler builds the GraphQL schema from the current collections. The
+// schema is fixed once built; build a new Handler to pick up new
+// collections or fields.
+func NewHandler(db *database.Driver, opts *Options) (*Handler, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.SampleSize <= 0 {
+		o.SampleSize = defaultSampleSize
+	}
+	if len(o.Collections) == 0 {
+		collections, err := db.Collections()
+		if err != nil {
+			return nil, err
+		}
+		o.Collections = collections
+	}
+
+	h := &Handler{db: db}
+	b := &builder{names: make(map[string]bool)}
+	query := graphql.Fields{}
+	for _, collection := range o.Collections {
+		t := o.Schemas[collection]
+		if t == nil {
+			var err error
+			if t, err = h.sample(collection, o.SampleSize); err != nil {
+				return nil, err
+			}
+		}
+		name := b.unique(gqlName(collection))
+		record := b.object(typeName(name), t, true)
+		listArgs := graphql.FieldConfigArgument{
+			"limit": &graphql.ArgumentConfig{Type: graphql.Int},
+			"after": &graphql.ArgumentConfig{Type: graphql.String},
+		}
+		if where := b.where(typeName(name)+"Where", t); where != nil {
+			listArgs["where"] = &graphql.ArgumentConfig{Type: where}
+		}
+		query[name] = &graphql.Field{Type: graphql.NewList(record), Args: listArgs, Resolve: h.list(collection, t)}
+		query[name+"ByKey"] = &graphql.Field{
+			Type:    record,
+			Args:    graphql.FieldConfigArgument{"key": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: h.get(collection),
+		}
+	}
+	if len(query) == 0 {
+		return nil, fmt.Errorf("no collections to expose")
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query})})
+	if err != nil {
+		return nil, err
+	}
+	h.schema = schema
+	return h, nil
+}
+
+func (h *Handler) sample(collection string, n int) (*Type, error) {
+	keys, err := h.db.Keys(collection)
+	if err != nil {
+		return nil, err
+	}
+	t := &Type{Kind: Object, Fields: make(map[string]*Type)}
+	for _, key := range keys[:min(n, len(keys))] {
+		doc, err := h.read(context.Background(), collection, key)
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil {
+			t = merge(t, infer(doc))
+		}
+	}
+	return t, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := h.Do(r.Context(), req.Query, req.OperationName, req.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Do executes a query against the schema without going through HTTP.
+func (h *Handler) Do(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  query,
+		OperationName:  operationName,
+		VariableValues: variables,
+		Context:        ctx,
+	})
+}
+
+// read returns the decoded record, or nil if there is none.
+func (h *Handler) read(ctx context.Context, collection, key string) (map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := h.db.ReadContext(ctx, collection, key, &raw); err != nil || len(raw) == 0 {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("record %s/%s is not a JSON object: %w", collection, key, err)
+	}
+	doc[keyField] = key
+	return doc, nil
+}
+
+func (h *Handler) get(collection string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		doc, err := h.read(p.Context, collection, p.Args["key"].(string))
+		if doc == nil {
+			return nil, err
+		}
+		return doc, err
+	}
+}
+
+func (h *Handler) list(collection string, t *Type) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		limit := defaultLimit
+		if v, ok := p.Args["limit"].(int); ok {
+			if v < 1 {
+				return nil, fmt.Errorf("invalid limit %d", v)
+			}
+			limit = min(v, maxLimit)
+		}
+		after, _ := p.Args["after"].(string)
+		where, _ := p.Args["where"].(map[string]interface{})
+
+		keys, err := h.db.KeysContext(p.Context, collection)
+		if err != nil {
+			return nil, err
+		}
+		start := sort.SearchStrings(keys, after)
+		if start < len(keys) && keys[start] == after {
+			start++
+		}
+
+		docs := []interface{}{}
+		for _, key := range keys[start:] {
+			if len(docs) == limit {
+				break
+			}
+			if err := p.Context.Err(); err != nil {
+				return nil, err
+			}
+			doc, err := h.read(p.Context, collection, key)
+			if err != nil {
+				return nil, err
+			}
+			if doc != nil && matches(doc, where, t) {
+				docs = append(docs, doc)
+			}
+		}
+		return docs, nil
+	}
+}
+
+const keyField = "_key"
+
+var invalidName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func gqlName(name string) string {
+	name = invalidName.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	return name
+}
+
+func typeName(name string) string {
+	name = strings.TrimLeft(name, "_")
+	if name == "" {
+		return "Record"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,118 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func openDB(t *testing.T) *database.Driver {
+	t.Helper()
+	db, err := database.New(t.TempDir(), &database.Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for key, city := range map[string]string{"ada": "Karachi", "bob": "Lahore", "cy": "Karachi"} {
+		v := map[string]interface{}{"name": key, "age": len(key), "address": map[string]string{"city": city}}
+		if err := db.Write("users", key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// do runs query and returns its data encoded as JSON.
+func do(t *testing.T, h *Handler, query string) string {
+	t.Helper()
+	result := h.Do(context.Background(), query, "", nil)
+	if result.HasErrors() {
+		t.Fatalf("%s: %v", query, result.Errors)
+	}
+	b, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestQuery(t *testing.T) {
+	h, err := NewHandler(openDB(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := do(t, h, `{ users(where: {address: {city: "Karachi"}}) { _key age address { city } } }`)
+	want := `{"users":[{"_key":"ada","address":{"city":"Karachi"},"age":3},{"_key":"cy","address":{"city":"Karachi"},"age":2}]}`
+	if got != want {
+		t.Errorf("filtered query = %s, want %s", got, want)
+	}
+	if got := do(t, h, `{ users(limit: 1, after: "ada") { _key } }`); got != `{"users":[{"_key":"bob"}]}` {
+		t.Errorf("paged query = %s", got)
+	}
+	if got := do(t, h, `{ usersByKey(key: "bob") { name } missing: usersByKey(key: "zed") { name } }`); got != `{"missing":null,"usersByKey":{"name":"bob"}}` {
+		t.Errorf("query by key = %s", got)
+	}
+	if result := h.Do(context.Background(), `{ users { nope } }`, "", nil); !result.HasErrors() {
+		t.Error("query of an unknown field succeeded")
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	db := openDB(t)
+	if err := db.Write("users", "dee", map[string]interface{}{"name": "dee", "age": 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(db, &Options{Collections: []string{"users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := do(t, h, `{ usersByKey(key: "dee") { age } }`); got != `{"usersByKey":{"age":1.5}}` {
+		t.Errorf("field of ints and floats inferred as %s", got)
+	}
+
+	schema := &Type{Kind: Object, Fields: map[string]*Type{"name": {Kind: String}}}
+	h, err = NewHandler(db, &Options{Schemas: map[string]*Type{"users": schema}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := h.Do(context.Background(), `{ users { age } }`, "", nil); !result.HasErrors() {
+		t.Error("query of a field left out of the supplied schema succeeded")
+	}
+	empty, err := database.NewMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Close()
+	if _, err := NewHandler(empty, nil); err == nil {
+		t.Error("NewHandler of an empty database succeeded")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	h, err := NewHandler(openDB(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`query($k: String!) { usersByKey(key: $k) { name } }`)+"&variables="+url.QueryEscape(`{"k":"ada"}`), nil),
+		httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ usersByKey(key: \"ada\") { name } }"}`)),
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"data":{"usersByKey":{"name":"ada"}}}` {
+			t.Errorf("%s = %d %s", req.Method, rec.Code, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("DELETE", "/graphql", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %d", rec.Code)
+	}
+}
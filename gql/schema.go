@@ -0,0 +1,183 @@
+package gql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+)
+
+// builder turns Types into GraphQL types, keeping type names unique.
+type builder struct {
+	names map[string]bool
+}
+
+func (b *builder) unique(name string) string {
+	n := name
+	for i := 2; b.names[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	b.names[n] = true
+	return n
+}
+
+// fieldNames maps the GraphQL name of every field of t to its record name,
+// dropping fields whose names clash once made valid.
+func fieldNames(t *Type) map[string]string {
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make(map[string]string)
+	for _, name := range names {
+		g := gqlName(name)
+		if _, clash := fields[g]; !clash && g != keyField {
+			fields[g] = name
+		}
+	}
+	return fields
+}
+
+func (b *builder) output(name string, t *Type) graphql.Output {
+	switch t.Kind {
+	case Int:
+		return graphql.Int
+	case Float:
+		return graphql.Float
+	case Boolean:
+		return graphql.Boolean
+	case List:
+		return graphql.NewList(b.output(name+"Item", t.Elem))
+	case Object:
+		if len(t.Fields) > 0 {
+			return b.object(name, t, false)
+		}
+	}
+	return graphql.String
+}
+
+func (b *builder) object(name string, t *Type, record bool) *graphql.Object {
+	fields := graphql.Fields{}
+	if record {
+		fields[keyField] = &graphql.Field{Type: graphql.String}
+	}
+	for g, field := range fieldNames(t) {
+		ft := t.Fields[field]
+		fields[g] = &graphql.Field{Type: b.output(name+typeName(g), ft), Resolve: resolveField(field, ft)}
+	}
+	if len(fields) == 0 {
+		fields[keyField] = &graphql.Field{Type: graphql.String}
+	}
+	return graphql.NewObject(graphql.ObjectConfig{Name: b.unique(name), Fields: fields})
+}
+
+// where builds the filter input for t, or nil if it has nothing to filter
+// on. Lists cannot be filtered.
+func (b *builder) where(name string, t *Type) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for g, field := range fieldNames(t) {
+		var it graphql.Input
+		switch ft := t.Fields[field]; ft.Kind {
+		case String:
+			it = graphql.String
+		case Int:
+			it = graphql.Int
+		case Float:
+			it = graphql.Float
+		case Boolean:
+			it = graphql.Boolean
+		case Object:
+			if nested := b.where(name+typeName(g), ft); nested != nil {
+				it = nested
+			}
+		}
+		if it != nil {
+			fields[g] = &graphql.InputObjectFieldConfig{Type: it}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: b.unique(name), Fields: fields})
+}
+
+func resolveField(field string, t *Type) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		doc, ok := p.Source.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return convert(doc[field], t), nil
+	}
+}
+
+// convert turns a decoded JSON value into what the GraphQL type of t
+// serializes.
+func convert(v interface{}, t *Type) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch t.Kind {
+	case Int:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i
+			}
+		}
+	case Float:
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				return f
+			}
+		}
+	case Boolean:
+		return v
+	case List:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = convert(item, t.Elem)
+		}
+		return out
+	case Object:
+		if _, ok := v.(map[string]interface{}); ok && len(t.Fields) > 0 {
+			return v
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// matches reports whether doc has every value in where, which uses the
+// GraphQL field names of t.
+func matches(doc map[string]interface{}, where map[string]interface{}, t *Type) bool {
+	names := fieldNames(t)
+	for g, want := range where {
+		field := names[g]
+		got, ok := doc[field]
+		if !ok {
+			return false
+		}
+		if nested, ok := want.(map[string]interface{}); ok {
+			sub, isObject := got.(map[string]interface{})
+			if !isObject || !matches(sub, nested, t.Fields[field]) {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(convert(got, t.Fields[field])) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package gql
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type Kind int
+
+const (
+	// String is also used for values whose type differs between records;
+	// they are served as their JSON encoding.
+	String Kind = iota
+	Int
+	Float
+	Boolean
+	Object
+	List
+)
+
+// Type is the shape of a record or of one of its fields. Fields is set for
+// objects and Elem for lists.
+type Type struct {
+	Kind   Kind
+	Fields map[string]*Type
+	Elem   *Type
+}
+
+// infer returns the type of a decoded JSON value, nil for null.
+func infer(v interface{}) *Type {
+	switch v := v.(type) {
+	case string:
+		return &Type{Kind: String}
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return &Type{Kind: Float}
+		}
+		return &Type{Kind: Int}
+	case bool:
+		return &Type{Kind: Boolean}
+	case map[string]interface{}:
+		t := &Type{Kind: Object, Fields: make(map[string]*Type)}
+		for name, field := range v {
+			if ft := infer(field); ft != nil {
+				t.Fields[name] = ft
+			}
+		}
+		return t
+	case []interface{}:
+		var elem *Type
+		for _, e := range v {
+			elem = merge(elem, infer(e))
+		}
+		if elem == nil {
+			elem = &Type{Kind: String}
+		}
+		return &Type{Kind: List, Elem: elem}
+	}
+	return nil
+}
+
+// merge combines the types seen for the same value in different records.
+func merge(a, b *Type) *Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.Kind == Int && b.Kind == Float, a.Kind == Float && b.Kind == Int:
+		return &Type{Kind: Float}
+	case a.Kind != b.Kind:
+		return &Type{Kind: String}
+	case a.Kind == Object:
+		t := &Type{Kind: Object, Fields: make(map[string]*Type)}
+		for name, f := range a.Fields {
+			t.Fields[name] = f
+		}
+		for name, f := range b.Fields {
+			t.Fields[name] = merge(t.Fields[name], f)
+		}
+		return t
+	case a.Kind == List:
+		return &Type{Kind: List, Elem: merge(a.Elem, b.Elem)}
+	}
+	return a
+}
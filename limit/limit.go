@@ -0,0 +1,125 @@
+// Package limit admits server requests under global and per-client rate
+// limits and a cap on the requests in progress, so one client cannot starve
+// the others of the file system.
+package limit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrTooManyActive = errors.New("too many concurrent requests")
+)
+
+// clientIdle is how long a client's limiter is kept after its last
+// request. It only needs to outlast the time the bucket takes to refill.
+const clientIdle = 5 * time.Minute
+
+type Limits struct {
+	// Rate and Burst limit the requests per second of all clients together;
+	// a zero Rate means no limit. Burst defaults to Rate, rounded up.
+	Rate  float64
+	Burst int
+	// ClientRate and ClientBurst limit each client the same way.
+	ClientRate  float64
+	ClientBurst int
+	// MaxActive caps the requests in progress at once; zero means no cap.
+	MaxActive int
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type Limiter struct {
+	limits Limits
+	global *rate.Limiter
+	active chan struct{}
+
+	mutex     sync.Mutex
+	clients   map[string]*client
+	lastPrune time.Time
+}
+
+func New(l Limits) *Limiter {
+	lim := &Limiter{limits: l, clients: make(map[string]*client), lastPrune: time.Now()}
+	if l.Rate > 0 {
+		lim.global = rate.NewLimiter(rate.Limit(l.Rate), burst(l.Rate, l.Burst))
+	}
+	if l.MaxActive > 0 {
+		lim.active = make(chan struct{}, l.MaxActive)
+	}
+	return lim
+}
+
+func burst(r float64, b int) int {
+	if b > 0 {
+		return b
+	}
+	return int(r + 0.999)
+}
+
+// Acquire admits a request from client, typically its IP address, or
+// reports why it is refused. release must be called once the request is
+// done; it does nothing for a nil Limiter, as does Acquire.
+func (l *Limiter) Acquire(client string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if err := l.Allow(client); err != nil {
+		return nil, err
+	}
+	if l.active == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.active <- struct{}{}:
+		return func() { <-l.active }, nil
+	default:
+		return nil, ErrTooManyActive
+	}
+}
+
+// Allow applies only the rate limits, for requests such as long-lived
+// streams that should not hold one of the MaxActive slots.
+func (l *Limiter) Allow(client string) error {
+	if l == nil {
+		return nil
+	}
+	if l.limits.ClientRate > 0 && !l.clientLimiter(client).Allow() {
+		return ErrRateLimited
+	}
+	if l.global != nil && !l.global.Allow() {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+func (l *Limiter) clientLimiter(key string) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > clientIdle {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > clientIdle {
+				delete(l.clients, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rate.Limit(l.limits.ClientRate), burst(l.limits.ClientRate, l.limits.ClientBurst))}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.limiter
+}
@@ -0,0 +1,63 @@
+package limit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClientRate(t *testing.T) {
+	l := New(Limits{ClientRate: 1, ClientBurst: 2})
+	for i := 0; i < 2; i++ {
+		if err := l.Allow("a"); err != nil {
+			t.Fatalf("request %d of the burst refused: %v", i, err)
+		}
+	}
+	if err := l.Allow("a"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("request past the burst = %v, want ErrRateLimited", err)
+	}
+	if err := l.Allow("b"); err != nil {
+		t.Errorf("another client refused: %v", err)
+	}
+}
+
+func TestGlobalRate(t *testing.T) {
+	l := New(Limits{Rate: 1})
+	if err := l.Allow("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow("b"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("request past the global burst = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestMaxActive(t *testing.T) {
+	l := New(Limits{MaxActive: 1})
+	release, err := l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire("b"); !errors.Is(err, ErrTooManyActive) {
+		t.Errorf("second active request = %v, want ErrTooManyActive", err)
+	}
+	if err := l.Allow("b"); err != nil {
+		t.Errorf("Allow counted against MaxActive: %v", err)
+	}
+	release()
+	release, err = l.Acquire("b")
+	if err != nil {
+		t.Fatalf("request after release refused: %v", err)
+	}
+	release()
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	release, err := l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if err := l.Allow("a"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,31 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
 
-	"github.com/jcelliott/lumber"
-)
-
-const Version = "1.0.0"
-
-type (
-	Logger interface {
-		Fatal(string, ...interface{})
-		Error(string, ...interface{})
-		Warn(string, ...interface{})
-		Info(string, ...interface{})
-		Debug(string, ...interface{})
-		Trace(string, ...interface{})
-	}
-
-	Driver struct {
-		mutex   sync.Mutex
-		mutexes map[string]*sync.Mutex
-		dir     string
-		log     Logger
-	}
+	"github.com/siraiwaqarali/golang-own-database/database"
 )
 
 type User struct {
@@ -45,15 +22,12 @@ type Address struct {
 	PostalCode json.Number `json:"postal_code"`
 }
 
-type Options struct {
-	Logger
-}
-
 func main() {
 	dir := "./"
 
-	db, err := New(dir, nil)
+	db, err := database.New(dir, nil)
 	handleErr(err)
+	defer db.Close()
 
 	users := []User{
 		{"Waqar", "23", "12345678912", "Google", Address{"Karachi", "Sindh", "Pakistan", "12345"}},
@@ -88,156 +62,11 @@ func main() {
 	// 	fmt.Println("Error Deleting User:", err)
 	// }
 
-	// if err := db.Delete("users", ""); err != nil {
+	// if _, err := db.DropCollection(context.Background(), "users", false); err != nil {
 	// 	fmt.Println("Error Deleting All Users:", err)
 	// }
 }
 
-func New(dir string, options *Options) (*Driver, error) {
-	dir = filepath.Clean(dir)
-
-	opts := Options{}
-
-	if options != nil {
-		opts = *options
-	}
-
-	if opts.Logger == nil {
-		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
-	}
-
-	driver := Driver{
-		dir:     dir,
-		mutexes: make(map[string]*sync.Mutex),
-		log:     opts.Logger,
-	}
-
-	if _, err := os.Stat(dir); err == nil {
-		opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
-		return &driver, nil
-	}
-
-	opts.Logger.Debug("Creating the database at '%s'...\n", dir)
-	return &driver, os.Mkdir(dir, 0755)
-}
-
-func (d *Driver) Write(collection string, resource string, v interface{}) error {
-	if collection == "" {
-		return fmt.Errorf("missing collection - no place to save record")
-	}
-	if resource == "" {
-		return fmt.Errorf("missing resource - unable to save record (no name)")
-	}
-
-	mutex := d.GetOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	dir := filepath.Join(d.dir, collection)
-	fnlPath := filepath.Join(dir, resource+".json")
-	tmpPath := fnlPath + ".tmp"
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	b, err := json.MarshalIndent(v, "", "\t")
-	if err != nil {
-		return err
-	}
-	b = append(b, byte('\n'))
-
-	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
-		return err
-	}
-
-	return os.Rename(tmpPath, fnlPath)
-}
-
-func (d *Driver) Read(collection string, resource string, v interface{}) error {
-	if collection == "" {
-		return fmt.Errorf("missing collection - no place to read record")
-	}
-	if resource == "" {
-		return fmt.Errorf("missing resource - unable to read record (no name)")
-	}
-
-	record := filepath.Join(d.dir, collection, resource)
-	if _, err := stat(record); err != nil {
-		return nil
-	}
-
-	b, err := os.ReadFile(record + ".json")
-	if err != nil {
-		return err
-	}
-
-	return json.Unmarshal(b, &v)
-}
-
-func (d *Driver) ReadAll(collection string) ([]string, error) {
-	if collection == "" {
-		return nil, fmt.Errorf("missing collection - no place to read records")
-	}
-	dir := filepath.Join(d.dir, collection)
-	if _, err := stat(dir); err != nil {
-		return nil, err
-	}
-
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	var records []string
-	for _, file := range files {
-		b, err := os.ReadFile(filepath.Join(dir, file.Name()))
-		if err != nil {
-			return nil, err
-		}
-
-		records = append(records, string(b))
-	}
-
-	return records, nil
-}
-
-func (d *Driver) Delete(collection string, resource string) error {
-	path := filepath.Join(collection, resource)
-	mutex := d.GetOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	dir := filepath.Join(d.dir, path)
-	switch fi, err := stat(dir); {
-	case fi == nil, err != nil:
-		return fmt.Errorf("unable to find file or directory named %v", path)
-	case fi.Mode().IsDir():
-		return os.RemoveAll(dir)
-	case fi.Mode().IsRegular():
-		return os.RemoveAll(dir + ".json")
-	}
-	return nil
-}
-
-func (d *Driver) GetOrCreateMutex(collection string) *sync.Mutex {
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
-	m, ok := d.mutexes[collection]
-	if !ok {
-		m = &sync.Mutex{}
-		d.mutexes[collection] = m
-	}
-	return m
-}
-
-func stat(path string) (fi os.FileInfo, err error) {
-	if fi, err = os.Stat(path); os.IsNotExist(err) {
-		fi, err = os.Stat(path + ".json")
-	}
-	return
-}
-
 func handleErr(err error) {
 	if err != nil {
 		fmt.Println("Error:", err)
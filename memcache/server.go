@@ -0,0 +1,368 @@
+// Package memcache serves a collection over the memcached text protocol,
+// so memcached clients can persist to the database unchanged.
+//
+// Supported commands are get, gets, set, add, replace, delete, touch,
+// version and quit. Items are stored as {"flags": n, "value": base64}
+// records; exptime follows memcached: seconds from now, an absolute unix
+// time past 30 days, and no expiry for 0. CAS is not supported and gets
+// always reports a CAS value of 0.
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	// DefaultCollection holds the items when no collection is given.
+	DefaultCollection = "memcached"
+
+	maxKeyLength = 250
+	maxValueSize = 1 << 20
+	// maxLineLength bounds a command line, long enough for a get of a
+	// couple hundred keys.
+	maxLineLength = 64 << 10
+	// Memcached reads exptimes larger than this as unix times.
+	relativeExpiryLimit = 30 * 24 * 60 * 60
+)
+
+type item struct {
+	Flags uint32 `json:"flags"`
+	Value []byte `json:"value"`
+}
+
+type Server struct {
+	db         *database.Driver
+	collection string
+
+	// storeMutex serializes the commands that store, so add, replace and
+	// touch see no other store between checking an item and writing it.
+	storeMutex sync.Mutex
+
+	mutex sync.Mutex
+	conns map[net.Conn]bool
+
+	// TLSConfig, if set, makes ListenAndServe accept only TLS connections.
+	TLSConfig *tls.Config
+}
+
+func New(db *database.Driver, collection string) *Server {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	return &Server{db: db, collection: collection, conns: make(map[net.Conn]bool)}
+}
+
+// ListenAndServe serves on addr until ctx is done, then closes the
+// listener and every client connection.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(l) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	l.Close()
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	if err := <-errc; !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReaderSize(conn, maxLineLength)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// The rest of the line is skipped, not buffered.
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = r.ReadSlice('\n')
+			}
+			if err != nil {
+				return
+			}
+			fmt.Fprint(w, "CLIENT_ERROR line too long\r\n")
+			if err := w.Flush(); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			fmt.Fprint(w, "ERROR\r\n")
+		} else if !s.command(strings.ToLower(fields[0]), fields[1:], r, w) {
+			w.Flush()
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// command runs one request and reports whether to keep the connection.
+func (s *Server) command(name string, args []string, r *bufio.Reader, w *bufio.Writer) bool {
+	switch name {
+	case "get", "gets":
+		if len(args) == 0 {
+			fmt.Fprint(w, "ERROR\r\n")
+			return true
+		}
+		for _, key := range args {
+			it, err := s.get(key)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					fmt.Fprintf(w, "SERVER_ERROR %s\r\n", oneLine(err))
+					return true
+				}
+				continue
+			}
+			if name == "gets" {
+				fmt.Fprintf(w, "VALUE %s %d %d 0\r\n", key, it.Flags, len(it.Value))
+			} else {
+				fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.Flags, len(it.Value))
+			}
+			w.Write(it.Value)
+			w.WriteString("\r\n")
+		}
+		fmt.Fprint(w, "END\r\n")
+
+	case "set", "add", "replace":
+		return s.store(name, args, r, w)
+
+	case "delete":
+		noreply := len(args) > 1 && args[len(args)-1] == "noreply"
+		if len(args) < 1 || !validKey(args[0]) {
+			fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		err := s.db.Delete(s.collection, args[0])
+		switch {
+		case err == nil:
+			reply(w, noreply, "DELETED")
+		case errors.Is(err, fs.ErrNotExist):
+			reply(w, noreply, "NOT_FOUND")
+		default:
+			reply(w, noreply, "SERVER_ERROR "+oneLine(err))
+		}
+
+	case "touch":
+		noreply := len(args) > 2 && args[2] == "noreply"
+		if len(args) < 2 || !validKey(args[0]) {
+			fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		s.storeMutex.Lock()
+		defer s.storeMutex.Unlock()
+		if _, err := s.get(args[0]); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				reply(w, noreply, "NOT_FOUND")
+			} else {
+				reply(w, noreply, "SERVER_ERROR "+oneLine(err))
+			}
+			return true
+		}
+		ttl, expired := expiry(exptime)
+		if expired {
+			err = s.db.Delete(s.collection, args[0])
+		} else {
+			err = s.db.Touch(s.collection, args[0], ttl)
+		}
+		if err != nil {
+			reply(w, noreply, "SERVER_ERROR "+oneLine(err))
+		} else {
+			reply(w, noreply, "TOUCHED")
+		}
+
+	case "version":
+		fmt.Fprintf(w, "VERSION %s\r\n", database.Version)
+
+	case "quit":
+		return false
+
+	default:
+		fmt.Fprint(w, "ERROR\r\n")
+	}
+	return true
+}
+
+// store handles set, add and replace:
+//
+//	<command> <key> <flags> <exptime> <bytes> [noreply]\r\n<data>\r\n
+func (s *Server) store(name string, args []string, r *bufio.Reader, w *bufio.Writer) bool {
+	if len(args) < 4 {
+		fmt.Fprint(w, "ERROR\r\n")
+		return true
+	}
+	noreply := len(args) > 4 && args[4] == "noreply"
+	flags, ferr := strconv.ParseUint(args[1], 10, 32)
+	exptime, eerr := strconv.ParseInt(args[2], 10, 64)
+	size, serr := strconv.Atoi(args[3])
+	if ferr != nil || eerr != nil || serr != nil || size < 0 {
+		fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	if size > maxValueSize {
+		// The data block cannot be skipped reliably, so the connection goes.
+		fmt.Fprint(w, "SERVER_ERROR object too large for cache\r\n")
+		return false
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false
+	}
+	if string(data[size:]) != "\r\n" {
+		fmt.Fprint(w, "CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+	key := args[0]
+	if !validKey(key) {
+		fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+	if name != "set" {
+		_, err := s.get(key)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			reply(w, noreply, "SERVER_ERROR "+oneLine(err))
+			return true
+		}
+		if exists := err == nil; exists != (name == "replace") {
+			reply(w, noreply, "NOT_STORED")
+			return true
+		}
+	}
+
+	it := item{Flags: uint32(flags), Value: data[:size]}
+	ttl, expired := expiry(exptime)
+	var err error
+	switch {
+	case expired:
+		// Stored and immediately expired, as memcached does.
+		err = s.db.Delete(s.collection, key)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	case ttl > 0:
+		err = s.db.WriteTTL(s.collection, key, it, ttl)
+	default:
+		err = s.db.Write(s.collection, key, it)
+	}
+	if err != nil {
+		reply(w, noreply, "SERVER_ERROR "+oneLine(err))
+	} else {
+		reply(w, noreply, "STORED")
+	}
+	return true
+}
+
+func (s *Server) get(key string) (item, error) {
+	if !validKey(key) {
+		return item{}, fs.ErrNotExist
+	}
+	// Read leaves stored nil if there is no such record.
+	var stored *item
+	if err := s.db.Read(s.collection, key, &stored); err != nil {
+		return item{}, err
+	}
+	if stored == nil {
+		return item{}, fs.ErrNotExist
+	}
+	return *stored, nil
+}
+
+// expiry converts a memcached exptime into a TTL; zero means no expiry.
+func expiry(exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= relativeExpiryLimit:
+		return time.Duration(exptime) * time.Second, false
+	}
+	ttl = time.Until(time.Unix(exptime, 0))
+	return ttl, ttl <= 0
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func reply(w *bufio.Writer, noreply bool, msg string) {
+	if !noreply {
+		w.WriteString(msg + "\r\n")
+	}
+}
+
+func oneLine(err error) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+}
@@ -0,0 +1,149 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+type conn struct {
+	t *testing.T
+	c net.Conn
+	r *bufio.Reader
+}
+
+// dial serves db on one end of a pipe and returns the other.
+func dial(t *testing.T, db *database.Driver) *conn {
+	t.Helper()
+	client, server := net.Pipe()
+	go New(db, "").serveConn(server)
+	t.Cleanup(func() { client.Close() })
+	return &conn{t: t, c: client, r: bufio.NewReader(client)}
+}
+
+// do sends request and reads lines of the response until one of them
+// is end.
+func (c *conn) do(request, end string) string {
+	c.t.Helper()
+	c.c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.c.Write([]byte(request)); err != nil {
+		c.t.Fatal(err)
+	}
+	var resp strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("%q: %v after %q", request, err, resp.String())
+		}
+		resp.WriteString(line)
+		if strings.TrimSpace(line) == end || end == "" {
+			return resp.String()
+		}
+	}
+}
+
+func openDB(t *testing.T) *database.Driver {
+	t.Helper()
+	db, err := database.New(t.TempDir(), &database.Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCommands(t *testing.T) {
+	db := openDB(t)
+	c := dial(t, db)
+
+	for _, tt := range []struct{ request, end, want string }{
+		{"set a 5 0 5\r\nhello\r\n", "", "STORED\r\n"},
+		{"get a b\r\n", "END", "VALUE a 5 5\r\nhello\r\nEND\r\n"},
+		{"gets a\r\n", "END", "VALUE a 5 5 0\r\nhello\r\nEND\r\n"},
+		{"add a 0 0 1\r\nx\r\n", "", "NOT_STORED\r\n"},
+		{"replace b 0 0 1\r\nx\r\n", "", "NOT_STORED\r\n"},
+		{"add b 0 0 1\r\nx\r\n", "", "STORED\r\n"},
+		{"replace b 0 0 2\r\nxy\r\n", "", "STORED\r\n"},
+		{"get b\r\n", "END", "VALUE b 0 2\r\nxy\r\nEND\r\n"},
+		{"delete b\r\n", "", "DELETED\r\n"},
+		{"delete b\r\n", "", "NOT_FOUND\r\n"},
+		{"set c 0 0 1 noreply\r\nz\r\nget c\r\n", "END", "VALUE c 0 1\r\nz\r\nEND\r\n"},
+		// The rest of the mismatched chunk is read as a command.
+		{"set d 0 0 3\r\nabcd\r\n", "ERROR", "CLIENT_ERROR bad data chunk\r\nERROR\r\n"},
+		{"bogus\r\n", "", "ERROR\r\n"},
+		{"version\r\n", "", "VERSION " + database.Version + "\r\n"},
+	} {
+		if got := c.do(tt.request, tt.end); got != tt.want {
+			t.Errorf("%q = %q, want %q", tt.request, got, tt.want)
+		}
+	}
+	keys, err := db.Keys(DefaultCollection)
+	if err != nil || len(keys) != 2 {
+		t.Errorf("stored records %v, %v; want a and c", keys, err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	db := openDB(t)
+	c := dial(t, db)
+
+	if got := c.do("set a 0 100 1\r\nx\r\n", ""); got != "STORED\r\n" {
+		t.Fatalf("set = %q", got)
+	}
+	if at, err := db.ExpiresAt(DefaultCollection, "a"); err != nil || time.Until(at) < 90*time.Second {
+		t.Errorf("ExpiresAt after a relative exptime = %v, %v", at, err)
+	}
+	if got := c.do("touch a 0\r\n", ""); got != "TOUCHED\r\n" {
+		t.Fatalf("touch = %q", got)
+	}
+	if at, err := db.ExpiresAt(DefaultCollection, "a"); err != nil || !at.IsZero() {
+		t.Errorf("ExpiresAt after touching with no expiry = %v, %v", at, err)
+	}
+	if got := c.do("touch a -1\r\n", ""); got != "TOUCHED\r\n" {
+		t.Fatalf("touch = %q", got)
+	}
+	if got := c.do("get a\r\n", "END"); got != "END\r\n" {
+		t.Errorf("get after expiring = %q", got)
+	}
+	if got := c.do("touch a 10\r\n", ""); got != "NOT_FOUND\r\n" {
+		t.Errorf("touch of a missing item = %q", got)
+	}
+}
+
+func TestLineTooLong(t *testing.T) {
+	c := dial(t, openDB(t))
+	long := "get " + strings.Repeat("k", maxLineLength) + "\r\n"
+	if got := c.do(long, ""); got != "CLIENT_ERROR line too long\r\n" {
+		t.Errorf("long line = %q, want CLIENT_ERROR line too long", got)
+	}
+	if got := c.do("version\r\n", ""); got != "VERSION "+database.Version+"\r\n" {
+		t.Errorf("version after a long line = %q", got)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	c := dial(t, openDB(t))
+	c.c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.c.Write([]byte("quit\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("connection still open after quit")
+	}
+}
+
+func TestExpiryTimes(t *testing.T) {
+	if ttl, expired := expiry(60); ttl != time.Minute || expired {
+		t.Errorf("expiry(60) = %v, %v", ttl, expired)
+	}
+	if ttl, expired := expiry(time.Now().Add(time.Hour).Unix()); expired || ttl < 59*time.Minute || ttl > time.Hour {
+		t.Errorf("expiry of a unix time an hour away = %v, %v", ttl, expired)
+	}
+	if _, expired := expiry(time.Now().Add(-time.Hour).Unix()); !expired {
+		t.Error("unix time in the past not expired")
+	}
+}
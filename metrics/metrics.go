@@ -0,0 +1,189 @@
+// Package metrics exposes a database to Prometheus: operation counts,
+// latencies, errors, bytes and lock waits by operation and collection, the
+// record counts and stored bytes of every collection and namespace, and the
+// runs of the maintenance jobs.
+//
+//	c := metrics.New(db)
+//	http.Handle("/metrics", c.Handler())
+//
+// Collector can also be registered with an existing registry instead.
+package metrics
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const namespace = "owndb"
+
+type Collector struct {
+	db *database.Driver
+
+	ops      *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	lockWait *prometheus.HistogramVec
+
+	records          *prometheus.Desc
+	storedBytes      *prometheus.Desc
+	namespaceRecords *prometheus.Desc
+	namespaceBytes   *prometheus.Desc
+	jobRuns          *prometheus.Desc
+	jobFailures      *prometheus.Desc
+	jobSkipped       *prometheus.Desc
+	jobDuration      *prometheus.Desc
+	jobLastRun       *prometheus.Desc
+	jobLastSuccess   *prometheus.Desc
+}
+
+// New instruments db and returns a Collector reporting on it. Call it once
+// per Driver.
+func New(db *database.Driver) *Collector {
+	labels := []string{"op", "collection"}
+	c := &Collector{
+		db: db,
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "operations_total",
+			Help: "Driver operations by type and collection.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "operation_errors_total",
+			Help: "Driver operations that failed, not counting reads of missing records.",
+		}, labels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "operation_bytes_total",
+			Help: "Encoded record bytes written and read.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "operation_duration_seconds",
+			Help:    "Driver operation latency.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, labels),
+		lockWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "lock_wait_seconds",
+			Help:    "Time writes and deletes spent waiting for the collection lock.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, labels),
+		records: prometheus.NewDesc(namespace+"_records",
+			"Records stored in a top-level collection.", []string{"collection"}, nil),
+		storedBytes: prometheus.NewDesc(namespace+"_stored_bytes",
+			"Bytes stored for the records of a top-level collection.", []string{"collection"}, nil),
+		namespaceRecords: prometheus.NewDesc(namespace+"_namespace_records",
+			"Records stored in a namespace.", []string{"namespace"}, nil),
+		namespaceBytes: prometheus.NewDesc(namespace+"_namespace_stored_bytes",
+			"Bytes stored for the records of a namespace.", []string{"namespace"}, nil),
+		jobRuns: prometheus.NewDesc(namespace+"_maintenance_runs_total",
+			"Runs of a maintenance job.", []string{"job"}, nil),
+		jobFailures: prometheus.NewDesc(namespace+"_maintenance_failures_total",
+			"Runs of a maintenance job that failed.", []string{"job"}, nil),
+		jobSkipped: prometheus.NewDesc(namespace+"_maintenance_skipped_total",
+			"Runs of a maintenance job skipped because the previous one was still going.", []string{"job"}, nil),
+		jobDuration: prometheus.NewDesc(namespace+"_maintenance_last_duration_seconds",
+			"How long the last run of a maintenance job took.", []string{"job"}, nil),
+		jobLastRun: prometheus.NewDesc(namespace+"_maintenance_last_run_timestamp_seconds",
+			"When the last run of a maintenance job started.", []string{"job"}, nil),
+		jobLastSuccess: prometheus.NewDesc(namespace+"_maintenance_last_success_timestamp_seconds",
+			"When the last run of a maintenance job that succeeded started.", []string{"job"}, nil),
+	}
+	db.Instrument(c.observe)
+	return c
+}
+
+func (c *Collector) observe(op database.Op) {
+	c.ops.WithLabelValues(op.Name, op.Collection).Inc()
+	if op.Err != nil && !errors.Is(op.Err, fs.ErrNotExist) {
+		c.errors.WithLabelValues(op.Name, op.Collection).Inc()
+	}
+	if op.Bytes > 0 {
+		c.bytes.WithLabelValues(op.Name, op.Collection).Add(float64(op.Bytes))
+	}
+	c.duration.WithLabelValues(op.Name, op.Collection).Observe(op.Duration.Seconds())
+	if op.Name == "write" || op.Name == "delete" {
+		c.lockWait.WithLabelValues(op.Name, op.Collection).Observe(op.LockWait.Seconds())
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.ops.Describe(ch)
+	c.errors.Describe(ch)
+	c.bytes.Describe(ch)
+	c.duration.Describe(ch)
+	c.lockWait.Describe(ch)
+	ch <- c.records
+	ch <- c.storedBytes
+	ch <- c.namespaceRecords
+	ch <- c.namespaceBytes
+	ch <- c.jobRuns
+	ch <- c.jobFailures
+	ch <- c.jobSkipped
+	ch <- c.jobDuration
+	ch <- c.jobLastRun
+	ch <- c.jobLastSuccess
+}
+
+// Collect reports the operation metrics and walks the database for the
+// record counts and sizes.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.ops.Collect(ch)
+	c.errors.Collect(ch)
+	c.bytes.Collect(ch)
+	c.duration.Collect(ch)
+	c.lockWait.Collect(ch)
+
+	collections, err := c.db.Collections()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.records, err)
+	}
+	for _, collection := range collections {
+		records, bytes, err := c.db.Usage(collection)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.records, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.records, prometheus.GaugeValue, float64(records), collection)
+		ch <- prometheus.MustNewConstMetric(c.storedBytes, prometheus.GaugeValue, float64(bytes), collection)
+	}
+
+	namespaces, err := c.db.Namespaces()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.namespaceRecords, err)
+	}
+	for _, name := range namespaces {
+		records, bytes, err := c.db.Namespace(name).Usage()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.namespaceRecords, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.namespaceRecords, prometheus.GaugeValue, float64(records), name)
+		ch <- prometheus.MustNewConstMetric(c.namespaceBytes, prometheus.GaugeValue, float64(bytes), name)
+	}
+
+	for _, job := range c.db.MaintenanceStats() {
+		ch <- prometheus.MustNewConstMetric(c.jobRuns, prometheus.CounterValue, float64(job.Runs), job.Name)
+		ch <- prometheus.MustNewConstMetric(c.jobFailures, prometheus.CounterValue, float64(job.Failures), job.Name)
+		ch <- prometheus.MustNewConstMetric(c.jobSkipped, prometheus.CounterValue, float64(job.Skipped), job.Name)
+		if !job.LastRun.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.jobDuration, prometheus.GaugeValue, job.LastDuration.Seconds(), job.Name)
+			ch <- prometheus.MustNewConstMetric(c.jobLastRun, prometheus.GaugeValue, float64(job.LastRun.UnixNano())/1e9, job.Name)
+		}
+		if !job.LastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.jobLastSuccess, prometheus.GaugeValue, float64(job.LastSuccess.UnixNano())/1e9, job.Name)
+		}
+	}
+}
+
+// Handler serves the Collector together with the Go runtime and process
+// metrics in the Prometheus text format.
+func (c *Collector) Handler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestCollector(t *testing.T) {
+	db, err := database.New(t.TempDir(), &database.Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := New(db)
+
+	for _, key := range []string{"ada", "bob"} {
+		if err := db.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var v map[string]string
+	db.Read("users", "nobody", &v)
+	db.Write(".bad", "a", 1)
+	if err := db.Namespace("tenant").Write("notes", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, _ := io.ReadAll(rec.Body)
+	body := string(b)
+	for _, want := range []string{
+		`owndb_operations_total{collection="users",op="write"} 2`,
+		`owndb_operations_total{collection="users",op="read"} 1`,
+		`owndb_operation_errors_total{collection=".bad",op="write"} 1`,
+		`owndb_records{collection="users"} 2`,
+		`owndb_namespace_records{namespace="tenant"} 1`,
+		`owndb_operation_duration_seconds_count{collection="users",op="write"} 2`,
+		`owndb_lock_wait_seconds_count{collection="users",op="write"} 2`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+	if strings.Contains(body, `owndb_operation_errors_total{collection="users",op="read"}`) {
+		t.Error("read of a missing record counted as an error")
+	}
+	if strings.Contains(body, `owndb_stored_bytes{collection="users"} 0`) {
+		t.Error("stored bytes of users not counted")
+	}
+}
+
+func TestMaintenanceMetrics(t *testing.T) {
+	db, err := database.New(t.TempDir(), &database.Options{
+		Maintenance: []database.MaintenanceJob{
+			{Name: "compact", Schedule: "@daily", Task: database.CompactTask},
+			{Name: "broken", Schedule: "@daily", Task: func(ctx context.Context, d *database.Driver) error {
+				return errors.New("broken")
+			}},
+		},
+		TTLSweepInterval: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := New(db)
+	db.RunMaintenance(context.Background(), "compact")
+	db.RunMaintenance(context.Background(), "broken")
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`owndb_maintenance_runs_total{job="compact"} 1`,
+		`owndb_maintenance_failures_total{job="compact"} 0`,
+		`owndb_maintenance_failures_total{job="broken"} 1`,
+		`owndb_maintenance_skipped_total{job="broken"} 0`,
+		`owndb_maintenance_last_duration_seconds{job="compact"}`,
+		`owndb_maintenance_last_run_timestamp_seconds{job="broken"}`,
+		`owndb_maintenance_last_success_timestamp_seconds{job="compact"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+	if strings.Contains(body, `owndb_maintenance_last_success_timestamp_seconds{job="broken"}`) {
+		t.Error("last success reported of a job that never succeeded")
+	}
+}
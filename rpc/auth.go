@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// UnaryAuth returns an interceptor running calls as the principal a
+// identifies from the "authorization" (Bearer) or "x-api-key" metadata and
+// a verified TLS client certificate. Others fail with Unauthenticated.
+func UnaryAuth(a auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth is UnaryAuth for streams.
+func StreamAuth(a auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, a auth.Authenticator) (context.Context, error) {
+	var c auth.Credentials
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		if token, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+			c.Token = strings.TrimSpace(token)
+		}
+	} else if v := md.Get("x-api-key"); len(v) > 0 {
+		c.Token = v[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			c.Certificates = info.State.VerifiedChains[0]
+		}
+	}
+
+	principal, err := auth.Require(a, c)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return database.WithPrincipal(ctx, principal), nil
+}
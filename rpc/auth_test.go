@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestAuth(t *testing.T) {
+	db := openDB(t)
+	if err := db.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	a := auth.NewAPIKeys(map[string]*database.Principal{"k": {Name: "alice", Role: database.ReadOnlyRole("reader")}})
+	c := dial(t, db, grpc.ChainUnaryInterceptor(UnaryAuth(a)), grpc.ChainStreamInterceptor(StreamAuth(a)))
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, &GetRequest{Collection: "users", Key: "ada"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Get without a key = %v, want Unauthenticated", err)
+	}
+	bearer := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer k")
+	if _, err := c.Get(bearer, &GetRequest{Collection: "users", Key: "ada"}); err != nil {
+		t.Errorf("Get with a bearer key = %v", err)
+	}
+	apiKey := metadata.AppendToOutgoingContext(ctx, "x-api-key", "k")
+	if _, err := c.Get(apiKey, &GetRequest{Collection: "users", Key: "ada"}); err != nil {
+		t.Errorf("Get with an x-api-key = %v", err)
+	}
+	if _, err := c.Put(bearer, &PutRequest{Collection: "users", Key: "bob", Value: []byte(`{}`)}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Put by a reader = %v, want PermissionDenied", err)
+	}
+
+	stream, err := c.Watch(ctx, &WatchRequest{Collection: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Watch without a key = %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package rpc serves a database over gRPC. The service is defined in
+// database.proto; regenerate the bindings after changing it.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative database.proto
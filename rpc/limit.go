@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+// UnaryLimit returns an interceptor admitting calls through l by the peer's
+// IP address. Refused calls fail with ResourceExhausted.
+func UnaryLimit(l *limit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		release, err := l.Acquire(peerHost(ctx))
+		if err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamLimit returns an interceptor applying l's rate limits to new
+// streams. Streams such as Watch can stay open indefinitely, so they do not
+// count against the cap on active requests.
+func StreamLimit(l *limit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.Allow(peerHost(ss.Context())); err != nil {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return handler(srv, ss)
+	}
+}
+
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+func TestLimit(t *testing.T) {
+	l := limit.New(limit.Limits{Rate: 1, Burst: 2})
+	c := dial(t, openDB(t), grpc.UnaryInterceptor(UnaryLimit(l)), grpc.StreamInterceptor(StreamLimit(l)))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.List(ctx, &ListRequest{Collection: "users"}); status.Code(err) == codes.ResourceExhausted {
+			t.Fatalf("call %d of the burst refused: %v", i, err)
+		}
+	}
+	if _, err := c.List(ctx, &ListRequest{Collection: "users"}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("call past the limit = %v, want ResourceExhausted", err)
+	}
+	stream, err := c.Watch(ctx, &WatchRequest{Collection: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("stream past the limit = %v, want ResourceExhausted", err)
+	}
+}
@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Server implements the Database service on top of a Driver. Register it
+// with RegisterDatabaseServer.
+type Server struct {
+	UnimplementedDatabaseServer
+	db *database.Driver
+}
+
+func NewServer(db *database.Driver) *Server {
+	return &Server{db: db}
+}
+
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing key")
+	}
+	var buf bytes.Buffer
+	if err := s.db.ReadToContext(ctx, req.Collection, req.Key, &buf); err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetResponse{Record: &Record{Key: req.Key, Value: buf.Bytes()}}, nil
+}
+
+func (s *Server) Put(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing key")
+	}
+	if !json.Valid(req.Value) {
+		return nil, status.Error(codes.InvalidArgument, "value is not a JSON document")
+	}
+	if err := s.db.WriteContext(ctx, req.Collection, req.Key, json.RawMessage(req.Value)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &PutResponse{}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing key")
+	}
+	if err := s.db.DeleteContext(ctx, req.Collection, req.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+func (s *Server) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	limit := defaultLimit
+	if req.Limit < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid limit %d", req.Limit)
+	}
+	if req.Limit > 0 {
+		limit = min(int(req.Limit), maxLimit)
+	}
+
+	keys, err := s.db.KeysContext(ctx, req.Collection)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	start := sort.SearchStrings(keys, req.After)
+	if start < len(keys) && keys[start] == req.After {
+		start++
+	}
+
+	resp := &ListResponse{}
+	for _, key := range keys[start:] {
+		if len(resp.Records) == limit {
+			resp.Next = resp.Records[len(resp.Records)-1].Key
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		var raw json.RawMessage
+		if err := s.db.ReadContext(ctx, req.Collection, key, &raw); err != nil {
+			return nil, toStatus(err)
+		}
+		if len(raw) > 0 {
+			resp.Records = append(resp.Records, &Record{Key: key, Value: raw})
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) Watch(req *WatchRequest, stream Database_WatchServer) error {
+	ctx := stream.Context()
+	events, stop, err := s.db.WatchContext(ctx, req.Collection)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "watch ended - resynchronize with List")
+			}
+			msg := &WatchEvent{Collection: e.Collection, Key: e.Key}
+			switch e.Op {
+			case database.EventPut:
+				msg.Op = WatchEvent_OP_PUT
+				if req.IncludeValues {
+					var buf bytes.Buffer
+					err := s.db.ReadToContext(ctx, e.Collection, e.Key, &buf)
+					if errors.Is(err, fs.ErrNotExist) {
+						// Deleted again since; the delete event follows.
+						continue
+					}
+					if err != nil {
+						return toStatus(err)
+					}
+					msg.Value = buf.Bytes()
+				}
+			case database.EventDelete:
+				msg.Op = WatchEvent_OP_DELETE
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toStatus(err error) error {
+	var tooLarge *database.RecordTooLargeError
+	code := codes.Internal
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		code = codes.NotFound
+	case errors.Is(err, database.ErrInvalidName), errors.Is(err, database.ErrSchemaViolation):
+		code = codes.InvalidArgument
+	case database.IsPermission(err):
+		code = codes.PermissionDenied
+	case errors.As(err, &tooLarge), errors.Is(err, database.ErrQuotaExceeded), database.IsDiskFull(err):
+		// Before ErrReadOnly, which a DiskMonitor refusing writes matches
+		// too.
+		code = codes.ResourceExhausted
+	case errors.Is(err, database.ErrReadOnly), errors.Is(err, database.ErrAppendOnly):
+		code = codes.FailedPrecondition
+	case errors.Is(err, database.ErrKeyCollision):
+		code = codes.AlreadyExists
+	case errors.Is(err, database.ErrClosed):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
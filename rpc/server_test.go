@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// dial serves db over gRPC on a loopback port with opts and returns a
+// client of it.
+func dial(t *testing.T, db *database.Driver, opts ...grpc.ServerOption) DatabaseClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := grpc.NewServer(opts...)
+	RegisterDatabaseServer(g, NewServer(db))
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewDatabaseClient(conn)
+}
+
+func openDB(t *testing.T) *database.Driver {
+	t.Helper()
+	db, err := database.New(t.TempDir(), &database.Options{TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecords(t *testing.T) {
+	c := dial(t, openDB(t))
+	ctx := context.Background()
+
+	if _, err := c.Put(ctx, &PutRequest{Collection: "users", Key: "ada", Value: []byte(`{"name":"Ada"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(ctx, &GetRequest{Collection: "users", Key: "ada"})
+	if err != nil || resp.GetRecord().GetKey() != "ada" || len(resp.GetRecord().GetValue()) == 0 {
+		t.Fatalf("Get = %v, %v", resp, err)
+	}
+	if _, err := c.Delete(ctx, &DeleteRequest{Collection: "users", Key: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, &GetRequest{Collection: "users", Key: "ada"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Get of a deleted record = %v, want NotFound", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := dial(t, openDB(t))
+	ctx := context.Background()
+
+	if _, err := c.Put(ctx, &PutRequest{Collection: "users", Key: "ada", Value: []byte(`nope`)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Put of a value that is not JSON = %v, want InvalidArgument", err)
+	}
+	if _, err := c.Put(ctx, &PutRequest{Collection: ".users", Key: "ada", Value: []byte(`{}`)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Put to an invalid collection = %v, want InvalidArgument", err)
+	}
+	if _, err := c.Get(ctx, &GetRequest{Collection: "users"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Get without a key = %v, want InvalidArgument", err)
+	}
+	if _, err := c.Delete(ctx, &DeleteRequest{Collection: "users"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Delete without a key = %v, want InvalidArgument", err)
+	}
+	if _, err := c.List(ctx, &ListRequest{Collection: "users", Limit: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("List with a negative limit = %v, want InvalidArgument", err)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want codes.Code
+	}{
+		{database.ErrReadOnly, codes.FailedPrecondition},
+		{&database.DiskFullError{Path: "users/ada.json"}, codes.ResourceExhausted},
+		// A DiskMonitor refusing writes reports both.
+		{fmt.Errorf("%w: %w", database.ErrReadOnly, database.ErrDiskFull), codes.ResourceExhausted},
+		{fmt.Errorf("record users/ada is already written: %w", database.ErrAppendOnly), codes.FailedPrecondition},
+		{fmt.Errorf("record users/ada: %w", database.ErrSchemaViolation), codes.InvalidArgument},
+		{&fs.PathError{Op: "open", Path: "users/ada.json", Err: fs.ErrPermission}, codes.PermissionDenied},
+		{fmt.Errorf("unable to find view named v: %w", fs.ErrNotExist), codes.NotFound},
+	} {
+		if got := status.Code(toStatus(c.err)); got != c.want {
+			t.Errorf("toStatus(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	db := openDB(t)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := db.Write("users", key, map[string]string{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := dial(t, db)
+
+	var keys []string
+	req := &ListRequest{Collection: "users", Limit: 2}
+	for {
+		resp, err := c.List(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.Records {
+			keys = append(keys, r.Key)
+		}
+		if resp.Next == "" {
+			break
+		}
+		req.After = resp.Next
+	}
+	if len(keys) != 3 || keys[0] != "a" || keys[2] != "c" {
+		t.Errorf("pages listed %v", keys)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := openDB(t)
+	c := dial(t, db)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := c.Watch(ctx, &WatchRequest{Collection: "users", IncludeValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The watch is only registered once the server handles the stream, so
+	// keep writing until an event comes through.
+	done := make(chan struct{})
+	go func() {
+		for {
+			db.Write("users", "ada", map[string]string{"name": "Ada"})
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+	e, err := stream.Recv()
+	close(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Op != WatchEvent_OP_PUT || e.Key != "ada" || len(e.Value) == 0 {
+		t.Fatalf("event = %v, want a put of ada with its value", e)
+	}
+
+	if err := db.Delete("users", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Op == WatchEvent_OP_DELETE {
+			if e.Key != "ada" {
+				t.Errorf("delete event of %q", e.Key)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+//go:embed admin
+var adminFiles embed.FS
+
+type collectionStats struct {
+	Name    string     `json:"name"`
+	Records int        `json:"records"`
+	Bytes   int64      `json:"bytes"`
+	Newest  *time.Time `json:"newest,omitempty"`
+}
+
+type namespaceStats struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	Bytes   int64  `json:"bytes"`
+}
+
+type stats struct {
+	Collections []collectionStats `json:"collections"`
+	Namespaces  []namespaceStats  `json:"namespaces"`
+}
+
+// EnableAdmin serves a dashboard at /admin/ for browsing and editing
+// records through the REST routes. Its query page uses the GraphQL
+// endpoint at /graphql when one is mounted.
+func (s *Server) EnableAdmin() {
+	static, err := fs.Sub(adminFiles, "admin")
+	if err != nil {
+		panic(err)
+	}
+	s.mux.Handle("GET /admin/", http.StripPrefix("/admin/", http.FileServerFS(static)))
+	s.mux.HandleFunc("GET /admin/api/stats", s.stats)
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	st := stats{Collections: []collectionStats{}, Namespaces: []namespaceStats{}}
+
+	collections, err := s.db.Collections()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	principal := database.PrincipalFrom(r.Context())
+	for _, c := range collections {
+		if principal != nil && !principal.Role.Allows(c, database.PermRead) {
+			continue
+		}
+		cs, err := s.db.CollectionStatsContext(r.Context(), c)
+		if errors.Is(err, database.ErrPermissionDenied) {
+			continue
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		row := collectionStats{Name: c, Records: cs.Records, Bytes: cs.Bytes}
+		if !cs.Newest.IsZero() {
+			row.Newest = &cs.Newest
+		}
+		st.Collections = append(st.Collections, row)
+	}
+
+	namespaces, err := s.db.Namespaces()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	// Namespace totals span collections the caller may not read.
+	if principal != nil && !principal.Role.Allows("*", database.PermRead) {
+		namespaces = nil
+	}
+	for _, name := range namespaces {
+		records, bytes, err := s.db.Namespace(name).Usage()
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		st.Namespaces = append(st.Namespaces, namespaceStats{Name: name, Records: records, Bytes: bytes})
+	}
+
+	writeJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,195 @@
+"use strict";
+
+const view = document.getElementById("view");
+const errorBox = document.getElementById("error");
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k.startsWith("on")) e.addEventListener(k.slice(2), v);
+    else e.setAttribute(k, v);
+  }
+  e.append(...children);
+  return e;
+}
+
+function showError(msg) {
+  errorBox.textContent = msg;
+  errorBox.hidden = !msg;
+}
+
+// send is fetch with the API key or token the server asked for, which is
+// kept for the rest of the session.
+async function send(url, options) {
+  for (;;) {
+    const token = sessionStorage.getItem("token");
+    const headers = { ...options.headers };
+    if (token) headers.Authorization = "Bearer " + token;
+    const res = await fetch(url, { ...options, headers });
+    if (res.status !== 401) return res;
+    const entered = prompt("API key or token");
+    if (!entered) return res;
+    sessionStorage.setItem("token", entered);
+  }
+}
+
+async function api(method, url, body) {
+  const res = await send(url, {
+    method,
+    headers: body === undefined ? {} : { "Content-Type": "application/json" },
+    body,
+  });
+  if (res.status === 204) return null;
+  const text = await res.text();
+  let data;
+  try {
+    data = JSON.parse(text);
+  } catch {
+    data = { error: text };
+  }
+  if (!res.ok) throw new Error(data.error || res.statusText);
+  return data;
+}
+
+function recordURL(collection, key) {
+  return "/collections/" + encodeURIComponent(collection) + "/" + encodeURIComponent(key);
+}
+
+function link(text, hash) {
+  return el("a", { href: "#" + hash }, text);
+}
+
+function formatBytes(n) {
+  const units = ["B", "KiB", "MiB", "GiB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i ? n.toFixed(1) : n) + " " + units[i];
+}
+
+async function collectionsView() {
+  const st = await api("GET", "api/stats");
+  const rows = st.collections.map((c) =>
+    el("tr", {},
+      el("td", {}, link(c.name, "c/" + encodeURIComponent(c.name))),
+      el("td", { class: "num" }, String(c.records)),
+      el("td", { class: "num" }, formatBytes(c.bytes)),
+      el("td", {}, c.newest ? new Date(c.newest).toLocaleString() : "")));
+  view.replaceChildren(
+    el("h2", {}, "Collections"),
+    el("table", {}, el("tr", {}, el("th", {}, "Collection"), el("th", { class: "num" }, "Records"), el("th", { class: "num" }, "Size"), el("th", {}, "Last write")), ...rows),
+  );
+  if (st.namespaces.length) {
+    const ns = st.namespaces.map((n) =>
+      el("tr", {}, el("td", {}, n.name), el("td", { class: "num" }, String(n.records)), el("td", { class: "num" }, formatBytes(n.bytes))));
+    view.append(
+      el("h2", {}, "Namespaces"),
+      el("table", {}, el("tr", {}, el("th", {}, "Namespace"), el("th", { class: "num" }, "Records"), el("th", { class: "num" }, "Size")), ...ns),
+    );
+  }
+}
+
+async function collectionView(collection, params) {
+  const query = new URLSearchParams(params);
+  const page = await api("GET", "/collections/" + encodeURIComponent(collection) + "?" + query);
+
+  const filter = el("input", { type: "text", placeholder: "field=value&other.field=value", value: [...query].filter(([k]) => k !== "after").map(([k, v]) => k + "=" + v).join("&") });
+  const form = el("form", { onsubmit: (e) => {
+    e.preventDefault();
+    location.hash = "c/" + encodeURIComponent(collection) + (filter.value ? "?" + filter.value : "");
+  } }, filter, " ", el("button", {}, "Filter"));
+
+  const rows = page.items.map((item) =>
+    el("tr", {},
+      el("td", {}, link(item.key, "r/" + encodeURIComponent(collection) + "/" + encodeURIComponent(item.key))),
+      el("td", {}, el("pre", {}, JSON.stringify(item.value, null, 2)))));
+
+  view.replaceChildren(
+    el("h2", {}, collection),
+    el("p", {}, link("New record", "r/" + encodeURIComponent(collection) + "/")),
+    form,
+    el("table", {}, el("tr", {}, el("th", {}, "Key"), el("th", {}, "Value")), ...rows),
+  );
+  if (page.next) {
+    query.set("after", page.next);
+    view.append(el("p", {}, link("Next page", "c/" + encodeURIComponent(collection) + "?" + query)));
+  }
+}
+
+async function recordView(collection, key) {
+  const keyInput = el("input", { type: "text", value: key });
+  const editor = el("textarea", { spellcheck: "false" });
+  if (key) {
+    editor.value = JSON.stringify(await api("GET", recordURL(collection, key)), null, 2);
+  } else {
+    editor.value = "{\n}";
+  }
+
+  const save = async () => {
+    let value;
+    try {
+      value = JSON.stringify(JSON.parse(editor.value));
+    } catch (e) {
+      throw new Error("invalid JSON: " + e.message);
+    }
+    await api("PUT", recordURL(collection, keyInput.value), value);
+    location.hash = "r/" + encodeURIComponent(collection) + "/" + encodeURIComponent(keyInput.value);
+    showError("");
+  };
+  const remove = async () => {
+    if (!confirm("Delete " + key + "?")) return;
+    await api("DELETE", recordURL(collection, key));
+    location.hash = "c/" + encodeURIComponent(collection);
+  };
+
+  view.replaceChildren(
+    el("h2", {}, link(collection, "c/" + encodeURIComponent(collection)), " / ", key || "new record"),
+    el("p", {}, "Key ", keyInput),
+    editor,
+    el("p", {}, el("button", { onclick: () => save().catch((e) => showError(e.message)) }, "Save"),
+      key ? el("button", { onclick: () => remove().catch((e) => showError(e.message)) }, "Delete") : ""),
+  );
+}
+
+function queryView() {
+  const editor = el("textarea", { spellcheck: "false" });
+  editor.value = sessionStorage.getItem("query") || "{\n  \n}";
+  const result = el("pre", { style: "max-height: none" });
+  const run = async () => {
+    sessionStorage.setItem("query", editor.value);
+    const res = await send("/graphql", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ query: editor.value }),
+    });
+    if (res.status === 404) throw new Error("no GraphQL endpoint - start the server with -graphql");
+    result.textContent = JSON.stringify(await res.json(), null, 2);
+  };
+  view.replaceChildren(
+    el("h2", {}, "Query"),
+    el("p", {}, "GraphQL, e.g. ", el("code", {}, "{ users(where: {name: \"Waqar\"}) { _key name } }")),
+    editor,
+    el("p", {}, el("button", { onclick: () => run().catch((e) => showError(e.message)) }, "Run")),
+    result,
+  );
+}
+
+async function route() {
+  showError("");
+  const hash = location.hash.slice(1);
+  const [path, params] = hash.split(/\?(.*)/s);
+  const parts = path.split("/");
+  try {
+    if (parts[0] === "c") await collectionView(decodeURIComponent(parts[1]), params || "");
+    else if (parts[0] === "r") await recordView(decodeURIComponent(parts[1]), decodeURIComponent(parts.slice(2).join("/")));
+    else if (parts[0] === "query") queryView();
+    else await collectionsView();
+  } catch (e) {
+    showError(e.message);
+  }
+}
+
+window.addEventListener("hashchange", route);
+route();
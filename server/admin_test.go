@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	s, d := newServer(t)
+	if rec := serve(s, "GET", "/admin/", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("dashboard served before EnableAdmin: %d", rec.Code)
+	}
+	s.EnableAdmin()
+
+	rec := serve(s, "GET", "/admin/", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Fatalf("GET /admin/ = %d %.100s", rec.Code, rec.Body)
+	}
+	for _, asset := range []string{"app.js", "style.css"} {
+		if rec := serve(s, "GET", "/admin/"+asset, ""); rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET /admin/%s = %d", asset, rec.Code)
+		}
+	}
+
+	for _, key := range []string{"ada", "bob"} {
+		if err := d.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Namespace("tenant").Write("notes", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(s, "GET", "/admin/api/stats", "")
+	var st stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/api/stats = %d %s", rec.Code, rec.Body)
+	}
+	var users *collectionStats
+	for i, c := range st.Collections {
+		if c.Name == "users" {
+			users = &st.Collections[i]
+		}
+	}
+	if users == nil || users.Records != 2 || users.Bytes == 0 || users.Newest == nil {
+		t.Errorf("stats of users = %+v", users)
+	}
+	if len(st.Namespaces) != 1 || st.Namespaces[0].Name != "tenant" || st.Namespaces[0].Records != 1 {
+		t.Errorf("namespace stats = %+v", st.Namespaces)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func (s *Server) aggregate(w http.ResponseWriter, r *http.Request) {
+	var pipeline []database.Stage
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pipeline); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid pipeline: "+err.Error())
+		return
+	}
+	docs, err := s.db.AggregateContext(r.Context(), r.PathValue("collection"), pipeline)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]map[string]interface{}{"items": docs})
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	s, d := newServer(t)
+	for key, age := range map[string]int{"a": 30, "b": 20} {
+		if err := d.Write("users", key, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := serve(s, "POST", "/collections/users/aggregate", `[{"group": {"fields": {"total": {"op": "sum", "field": "age"}}}}]`)
+	if rec.Code != http.StatusOK || rec.Body.String() != "{\"items\":[{\"_key\":null,\"total\":50}]}\n" {
+		t.Errorf("POST aggregate = %d %s", rec.Code, rec.Body)
+	}
+	for _, body := range []string{`[{}]`, `[{"unwind": "x"}]`, `{`} {
+		if rec := serve(s, "POST", "/collections/users/aggregate", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST aggregate %s = %d %s, want 400", body, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// credentials collects what the client presented: a bearer token or
+// X-API-Key header, and a client certificate the TLS handshake verified.
+func credentials(r *http.Request) auth.Credentials {
+	var c auth.Credentials
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		c.Token = strings.TrimSpace(v)
+	} else {
+		c.Token = r.Header.Get("X-API-Key")
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		c.Certificates = r.TLS.VerifiedChains[0]
+	}
+	return c
+}
+
+// public reports whether a request may skip authentication. The admin
+// dashboard's static files are, so a browser can load it and then ask for
+// a key.
+func public(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/admin/") && !strings.HasPrefix(r.URL.Path, "/admin/api/")
+}
+
+// authenticate returns r with the principal its credentials identify, or
+// writes 401 Unauthorized and returns nil.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) *http.Request {
+	if s.Auth == nil || public(r) {
+		return r
+	}
+	p, err := auth.Require(s.Auth, credentials(r))
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="owndb"`)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return nil
+	}
+	return r.WithContext(database.WithPrincipal(r.Context(), p))
+}
+
+// requireAdmin limits h to principals allowed everything, or to everyone
+// when the server does not authenticate.
+func requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p := database.PrincipalFrom(r.Context()); p != nil && !p.Role.Allows("*", database.PermAll) {
+			writeError(w, http.StatusForbidden, p.Name+" is not an administrator")
+			return
+		}
+		h(w, r)
+	}
+}
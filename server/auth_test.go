@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestAuth(t *testing.T) {
+	s, d := newServer(t)
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Auth = auth.NewAPIKeys(map[string]*database.Principal{
+		"reader": {Name: "alice", Role: database.ReadOnlyRole("reader")},
+		"admin":  {Name: "root", Role: database.AdminRole("admin")},
+	})
+	s.EnableAdmin()
+	s.EnableDebug()
+
+	request := func(method, target, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("GET", "/collections/users/ada", "", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("request without a key = %d", rec.Code)
+	}
+	if rec := request("GET", "/collections/users/ada", "", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with a wrong key = %d", rec.Code)
+	}
+	if rec := request("GET", "/collections/users/ada", "", "reader"); rec.Code != http.StatusOK {
+		t.Errorf("read by a reader = %d %s", rec.Code, rec.Body)
+	}
+	if rec := request("PUT", "/collections/users/bob", `{}`, "reader"); rec.Code != http.StatusForbidden {
+		t.Errorf("write by a reader = %d %s", rec.Code, rec.Body)
+	}
+	if rec := request("PUT", "/collections/users/bob", `{}`, "admin"); rec.Code != http.StatusNoContent {
+		t.Errorf("write by an admin = %d %s", rec.Code, rec.Body)
+	}
+
+	req := httptest.NewRequest("GET", "/collections/users/ada", nil)
+	req.Header.Set("X-API-Key", "reader")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("read with X-API-Key = %d", rec.Code)
+	}
+
+	if rec := request("GET", "/admin/", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("dashboard page without a key = %d", rec.Code)
+	}
+	if rec := request("GET", "/admin/api/stats", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("dashboard stats without a key = %d", rec.Code)
+	}
+	if rec := request("GET", "/debug/db", "", "reader"); rec.Code != http.StatusForbidden {
+		t.Errorf("debug info for a reader = %d", rec.Code)
+	}
+	if rec := request("GET", "/debug/db", "", "admin"); rec.Code != http.StatusOK {
+		t.Errorf("debug info for an admin = %d", rec.Code)
+	}
+}
+
+func TestAuthPrincipal(t *testing.T) {
+	s, d := newServer(t)
+	var principals []string
+	d.Instrument(func(op database.Op) {
+		if op.Name == "write" {
+			principals = append(principals, op.Principal)
+		}
+	})
+	writer := &database.Role{Name: "writer", Collections: map[string]database.Permission{"notes": database.PermReadWrite}}
+	s.Auth = auth.NewAPIKeys(map[string]*database.Principal{"k": {Name: "carol", Role: writer}})
+
+	req := httptest.NewRequest("PUT", "/collections/notes/a", strings.NewReader(`{}`))
+	req.Header.Set("X-API-Key", "k")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+	if len(principals) != 1 || principals[0] != "carol" {
+		t.Errorf("hooks saw principals %q, want carol", principals)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// etag quotes a record revision as a strong entity tag.
+func etag(rev string) string {
+	return `"` + rev + `"`
+}
+
+// matchETag reports whether an If-Match or If-None-Match header lists the
+// entity tag of revision rev, "" for a missing record, which only "*"
+// does not match. If-None-Match compares weakly, ignoring W/ prefixes.
+func matchETag(header, rev string, weak bool) bool {
+	if rev == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag(rev) {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditions returns whether the If-Match and If-None-Match headers of
+// a write hold for a record at revision rev.
+func preconditions(r *http.Request, rev string) bool {
+	if im := r.Header.Get("If-Match"); im != "" && !matchETag(im, rev, false) {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchETag(inm, rev, true) {
+		return false
+	}
+	return true
+}
+
+func conditional(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != ""
+}
+
+// revision returns the revision of a record for checking the preconditions
+// of a write to it, or "" if it is missing.
+func (s *Server) revision(r *http.Request, collection, key string) (string, error) {
+	var raw json.RawMessage
+	rev, err := s.db.ReadRevisionContext(r.Context(), collection, key, &raw)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return rev, err
+}
+
+func writePreconditionFailed(w http.ResponseWriter) {
+	writeError(w, http.StatusPreconditionFailed, "precondition failed")
+}
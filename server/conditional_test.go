@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveHeader(s http.Handler, method, target, body, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(header, value)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConditional(t *testing.T) {
+	s, _ := newServer(t)
+
+	// If-None-Match: * only creates a record.
+	rec := serveHeader(s, "PUT", "/collections/users/ada", `{"n":1}`, "If-None-Match", "*")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("ETag") == "" {
+		t.Fatalf("PUT creating = %d %s, ETag %q", rec.Code, rec.Body, rec.Header().Get("ETag"))
+	}
+	if rec := serveHeader(s, "PUT", "/collections/users/ada", `{"n":1}`, "If-None-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT creating an existing record = %d, want 412", rec.Code)
+	}
+
+	rec = serve(s, "GET", "/collections/users/ada", "")
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(tag, `"`) {
+		t.Fatalf("GET = %d, ETag %q", rec.Code, tag)
+	}
+	if rec := serveHeader(s, "GET", "/collections/users/ada", "", "If-None-Match", `W/"x", W/`+tag); rec.Code != http.StatusNotModified {
+		t.Errorf("GET matching If-None-Match weakly = %d, want 304", rec.Code)
+	}
+	if rec := serveHeader(s, "GET", "/collections/users/ada", "", "If-Match", `"stale"`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("GET failing If-Match = %d, want 412", rec.Code)
+	}
+
+	rec = serveHeader(s, "PUT", "/collections/users/ada", `{"n":2}`, "If-Match", tag)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("ETag") == tag {
+		t.Fatalf("PUT at the current revision = %d %s, ETag %q", rec.Code, rec.Body, rec.Header().Get("ETag"))
+	}
+	if rec := serveHeader(s, "PUT", "/collections/users/ada", `{"n":3}`, "If-Match", tag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT at a stale revision = %d, want 412", rec.Code)
+	}
+	if rec := serveHeader(s, "PUT", "/collections/users/bob", `{"n":1}`, "If-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT If-Match: * of a missing record = %d, want 412", rec.Code)
+	}
+
+	if rec := serveHeader(s, "DELETE", "/collections/users/ada", "", "If-Match", tag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE at a stale revision = %d, want 412", rec.Code)
+	}
+	if rec := serveHeader(s, "DELETE", "/collections/users", "", "If-Match", "*"); rec.Code != http.StatusBadRequest {
+		t.Errorf("conditional DELETE of a collection = %d, want 400", rec.Code)
+	}
+	if rec := serveHeader(s, "DELETE", "/collections/users/ada", "", "If-Match", "*"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE If-Match: * = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(s, "GET", "/collections/users/ada", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after the conditional DELETE = %d, want 404", rec.Code)
+	}
+}
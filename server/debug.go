@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+type debugInfo struct {
+	Stats     database.Stats `json:"stats"`
+	HeldLocks []string       `json:"held_locks"`
+}
+
+// EnableDebug mounts the net/http/pprof profiles at /debug/pprof/ and a
+// dump of the driver's locks and caches at /debug/db. Only enable it where
+// the listener is not reachable by untrusted clients.
+func (s *Server) EnableDebug() {
+	s.mux.HandleFunc("GET /debug/pprof/", requireAdmin(pprof.Index))
+	s.mux.HandleFunc("GET /debug/pprof/cmdline", requireAdmin(pprof.Cmdline))
+	s.mux.HandleFunc("GET /debug/pprof/profile", requireAdmin(pprof.Profile))
+	s.mux.HandleFunc("GET /debug/pprof/symbol", requireAdmin(pprof.Symbol))
+	s.mux.HandleFunc("POST /debug/pprof/symbol", requireAdmin(pprof.Symbol))
+	s.mux.HandleFunc("GET /debug/pprof/trace", requireAdmin(pprof.Trace))
+	s.mux.HandleFunc("GET /debug/db", requireAdmin(s.debugDB))
+}
+
+func (s *Server) debugDB(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, debugInfo{Stats: s.db.Stats(), HeldLocks: s.db.HeldLocks()})
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	s, d := newServer(t)
+	if rec := serve(s, "GET", "/debug/db", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("/debug/db served before EnableDebug: %d", rec.Code)
+	}
+	s.EnableDebug()
+
+	if err := d.Write("users", "ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	mutex := d.GetOrCreateMutex("users")
+	mutex.Lock()
+	rec := serve(s, "GET", "/debug/db", "")
+	mutex.Unlock()
+	var info debugInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /debug/db = %d %s", rec.Code, rec.Body)
+	}
+	if len(info.HeldLocks) != 1 || info.HeldLocks[0] != "users" || info.Stats.OpenCollections != 1 {
+		t.Errorf("debug info = %+v", info)
+	}
+
+	rec = serve(s, "GET", "/debug/pprof/", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("GET /debug/pprof/ = %d", rec.Code)
+	}
+	if rec := serve(s, "GET", "/debug/pprof/cmdline", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /debug/pprof/cmdline = %d", rec.Code)
+	}
+}
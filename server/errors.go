@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// leaderError is a write refused off the leader of a cluster, naming the
+// server of the leader, if there is one.
+type leaderError interface {
+	error
+	LeaderURL() string
+}
+
+func status(err error) int {
+	var tooLarge *database.RecordTooLargeError
+	var leader leaderError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrInvalidName), errors.Is(err, database.ErrInvalidPipeline),
+		errors.Is(err, database.ErrInvalidExpr), errors.Is(err, database.ErrInvalidCursor),
+		errors.Is(err, database.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case database.IsDiskFull(err):
+		// Before ErrReadOnly, which a DiskMonitor refusing writes matches
+		// too.
+		return http.StatusInsufficientStorage
+	case database.IsPermission(err), errors.Is(err, database.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrKeyCollision), errors.Is(err, database.ErrAppendOnly):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusPreconditionFailed
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, database.ErrProcedureFailed), errors.Is(err, database.ErrSchemaViolation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, database.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, database.ErrClosed), errors.As(err, &leader):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeDBError(w http.ResponseWriter, err error) {
+	writeError(w, status(err), err.Error())
+}
+
+// writeWriteError is writeDBError for writes, which are redirected to the
+// leader of a cluster when made on a follower.
+func writeWriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var leader leaderError
+	if errors.As(err, &leader) {
+		if u := leader.LeaderURL(); u != "" {
+			http.Redirect(w, r, strings.TrimSuffix(u, "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+		// Until a leader is elected.
+		w.Header().Set("Retry-After", "1")
+	}
+	writeDBError(w, err)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
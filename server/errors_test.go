@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestStatus(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want int
+	}{
+		{database.ErrReadOnly, http.StatusForbidden},
+		{&database.DiskFullError{Path: "users/ada.json"}, http.StatusInsufficientStorage},
+		// A DiskMonitor refusing writes reports both.
+		{fmt.Errorf("%w: %w", database.ErrReadOnly, database.ErrDiskFull), http.StatusInsufficientStorage},
+		{database.ErrQuotaExceeded, http.StatusInsufficientStorage},
+		{fmt.Errorf("record users/ada is already written: %w", database.ErrAppendOnly), http.StatusConflict},
+		{fmt.Errorf("record users/ada: %w", database.ErrSchemaViolation), http.StatusUnprocessableEntity},
+		{fmt.Errorf("%w: unknown version 2", database.ErrInvalidCursor), http.StatusBadRequest},
+		{fmt.Errorf("writing: %w", notLeader{}), http.StatusServiceUnavailable},
+		{&fs.PathError{Op: "open", Path: "users/ada.json", Err: fs.ErrPermission}, http.StatusForbidden},
+	} {
+		if got := status(c.err); got != c.want {
+			t.Errorf("status(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+}
+
+// notLeader is a write refused off the leader of a cluster, as the errors
+// of cluster nodes are.
+type notLeader struct {
+	url string
+}
+
+func (e notLeader) Error() string     { return "not the cluster leader" }
+func (e notLeader) LeaderURL() string { return e.url }
+
+// follower takes writes as a cluster follower does, refusing them all.
+type follower struct {
+	*database.Driver
+	leader string
+}
+
+func (f follower) WriteContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return notLeader{f.leader}
+}
+
+func (f follower) DeleteContext(ctx context.Context, collection, resource string) error {
+	return notLeader{f.leader}
+}
+
+func TestWritesRedirect(t *testing.T) {
+	s, d := newServer(t)
+	s.Writes = follower{Driver: d, leader: "http://leader:8080/"}
+
+	for _, method := range []string{"PUT", "DELETE"} {
+		rec := serve(s, method, "/collections/users/ada?x=1", `{}`)
+		if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != "http://leader:8080/collections/users/ada?x=1" {
+			t.Errorf("%s on a follower = %d, Location %q; want a redirect to the leader", method, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	s.Writes = follower{Driver: d}
+	rec := serve(s, "PUT", "/collections/users/ada", `{}`)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("PUT while a leader is elected = %d, Retry-After %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 0 {
+		t.Errorf("write refused by Writes made on the database: %v", keys)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// matcher returns a function reporting whether a record has, for every
+// filtered field, one of the requested values and passes the filter
+// expression, if any.
+func matcher(filters url.Values, expr string) (func(raw json.RawMessage) (bool, error), error) {
+	var e *database.Expr
+	if expr != "" {
+		var err error
+		if e, err = database.Compile(expr); err != nil {
+			return nil, err
+		}
+	}
+	where := database.Query{Where: filters}
+	return func(raw json.RawMessage) (bool, error) {
+		if len(filters) == 0 && e == nil {
+			return true, nil
+		}
+
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return false, err
+		}
+		m, ok := doc.(map[string]interface{})
+		return ok && where.Match(m) && (e == nil || e.Match(m)), nil
+	}, nil
+}
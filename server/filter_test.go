@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	s, d := newServer(t)
+	for key, age := range map[string]int{"a": 30, "b": 20, "c": 40} {
+		if err := d.Write("users", key, map[string]interface{}{"age": age, "city": "Karachi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"filter=" + url.QueryEscape("doc.age > 25"), "a,c"},
+		{"city=Karachi&filter=" + url.QueryEscape("doc.age < 35"), "a,b"},
+		{"city=Lahore&filter=" + url.QueryEscape("doc.age < 35"), ""},
+	} {
+		rec := serve(s, "GET", "/collections/users?"+tc.query, "")
+		var p page
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("GET ?%s = %d %s", tc.query, rec.Code, rec.Body)
+		}
+		var keys []string
+		for _, it := range p.Items {
+			keys = append(keys, it.Key)
+		}
+		if strings.Join(keys, ",") != tc.want {
+			t.Errorf("GET ?%s = %v, want %s", tc.query, keys, tc.want)
+		}
+	}
+
+	rec := serve(s, "GET", "/collections/users?filter="+url.QueryEscape("doc.age >"), "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid expression") {
+		t.Errorf("GET with a bad filter = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds how long /readyz waits for a collection lock.
+const readyTimeout = 2 * time.Second
+
+// probe reports whether r is a liveness or readiness probe, which skip rate
+// limiting, authentication and tracing so load balancers and Kubernetes
+// can call them freely.
+func probe(r *http.Request) bool {
+	return r.Method == http.MethodGet && (r.URL.Path == "/healthz" || r.URL.Path == "/readyz")
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := s.db.Ready(ctx); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if s.Ready != nil {
+		if err := s.Ready(ctx); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+func TestHealth(t *testing.T) {
+	s, d := newServer(t)
+	// Probes skip authentication and rate limits.
+	s.Auth = auth.NewAPIKeys(map[string]*database.Principal{})
+	s.Limiter = limit.New(limit.Limits{Rate: 1, Burst: 1})
+
+	for i := 0; i < 3; i++ {
+		if rec := serve(s, "GET", "/healthz", ""); rec.Code != http.StatusOK {
+			t.Fatalf("GET /healthz = %d %s", rec.Code, rec.Body)
+		}
+		if rec := serve(s, "GET", "/readyz", ""); rec.Code != http.StatusOK {
+			t.Fatalf("GET /readyz = %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	s.Ready = func(context.Context) error { return errors.New("following too far behind") }
+	if rec := serve(s, "GET", "/readyz", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz with Ready failing = %d", rec.Code)
+	}
+	s.Ready = nil
+
+	d.Close()
+	if rec := serve(s, "GET", "/readyz", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz of a closed database = %d", rec.Code)
+	}
+	if rec := serve(s, "GET", "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz of a closed database = %d", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+func TestLimiter(t *testing.T) {
+	s, d := newServer(t)
+	if err := d.Write("users", "ada", 1); err != nil {
+		t.Fatal(err)
+	}
+	s.Limiter = limit.New(limit.Limits{ClientRate: 1, ClientBurst: 1})
+
+	if rec := serve(s, "GET", "/collections/users/ada", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first request = %d %s", rec.Code, rec.Body)
+	}
+	rec := serve(s, "GET", "/collections/users/ada", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request past the limit = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestProcedures(t *testing.T) {
+	s, _ := newServerWith(t, &database.Options{
+		Procedures: []database.Procedure{
+			{Name: "inc", Collections: []string{"counters"}, Source: `
+				local r = db.read("counters", "n") or {v = 0}
+				r.v = r.v + (args and args.by or 1)
+				db.write("counters", "n", r)
+				return r.v`},
+			{Name: "fail", Source: `error("nope")`},
+		},
+		TTLSweepInterval: -1,
+	})
+
+	if rec := serve(s, "GET", "/procedures", ""); rec.Body.String() != "{\"procedures\":[\"fail\",\"inc\"]}\n" {
+		t.Errorf("GET /procedures = %d %s", rec.Code, rec.Body)
+	}
+	for _, tc := range []struct {
+		body, want string
+	}{
+		{`{"by": 5}`, "{\"result\":5}\n"},
+		{``, "{\"result\":6}\n"},
+	} {
+		if rec := serve(s, "POST", "/procedures/inc", tc.body); rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Errorf("POST /procedures/inc %s = %d %s, want %s", tc.body, rec.Code, rec.Body, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		target, body string
+		code         int
+	}{
+		{"/procedures/fail", "", http.StatusUnprocessableEntity},
+		{"/procedures/none", "", http.StatusNotFound},
+		{"/procedures/inc", "{", http.StatusBadRequest},
+	} {
+		if rec := serve(s, "POST", tc.target, tc.body); rec.Code != tc.code {
+			t.Errorf("POST %s %s = %d %s, want %d", tc.target, tc.body, rec.Code, rec.Body, tc.code)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func (s *Server) listQueries(w http.ResponseWriter, r *http.Request) {
+	names, err := s.db.SavedQueriesContext(r.Context())
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"queries": names})
+}
+
+func (s *Server) getQuery(w http.ResponseWriter, r *http.Request) {
+	q, err := s.db.SavedQueryContext(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func (s *Server) putQuery(w http.ResponseWriter, r *http.Request) {
+	var q database.Query
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&q); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query: "+err.Error())
+		return
+	}
+	if err := s.writes().SaveQueryContext(r.Context(), r.PathValue("name"), q); err != nil {
+		writeWriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteQuery(w http.ResponseWriter, r *http.Request) {
+	if err := s.writes().DeleteQueryContext(r.Context(), r.PathValue("name")); err != nil {
+		writeWriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) runQuery(w http.ResponseWriter, r *http.Request) {
+	results, err := s.db.RunQueryContext(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]database.QueryResult{"items": results})
+}
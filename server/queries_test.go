@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestQueries(t *testing.T) {
+	s, d := newServer(t)
+	for key, city := range map[string]string{"a": "Karachi", "b": "Lahore", "c": "Karachi"} {
+		if err := d.Write("users", key, map[string]interface{}{"name": key, "address": map[string]string{"city": city}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := `{"collection": "users", "where": {"address.city": ["Karachi"]}, "fields": ["name"]}`
+	if rec := serve(s, "PUT", "/queries/karachi", q); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(s, "GET", "/queries", ""); rec.Body.String() != "{\"queries\":[\"karachi\"]}\n" {
+		t.Errorf("GET /queries = %d %s", rec.Code, rec.Body)
+	}
+	rec := serve(s, "GET", "/queries/karachi", "")
+	var saved struct {
+		Collection string `json:"collection"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &saved); err != nil || saved.Collection != "users" {
+		t.Errorf("GET /queries/karachi = %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(s, "GET", "/queries/karachi/results", "")
+	var results struct {
+		Items []struct {
+			Key   string            `json:"key"`
+			Value map[string]string `json:"value"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || len(results.Items) != 2 ||
+		results.Items[0].Key != "a" || results.Items[1].Value["name"] != "c" {
+		t.Errorf("GET results = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tc := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"PUT", "/queries/bad", `{"collection": "users", "sort": "name"}`, http.StatusBadRequest},
+		{"PUT", "/queries/bad", `{"collection": ""}`, http.StatusBadRequest},
+		{"PUT", "/queries/.bad", q, http.StatusBadRequest},
+		{"GET", "/queries/nope", "", http.StatusNotFound},
+		{"GET", "/queries/nope/results", "", http.StatusNotFound},
+		{"DELETE", "/queries/karachi", "", http.StatusNoContent},
+		{"DELETE", "/queries/karachi", "", http.StatusNotFound},
+	} {
+		if rec := serve(s, tc.method, tc.target, tc.body); rec.Code != tc.code {
+			t.Errorf("%s %s = %d %s, want %d", tc.method, tc.target, rec.Code, rec.Body, tc.code)
+		}
+	}
+}
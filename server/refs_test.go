@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func TestResolve(t *testing.T) {
+	s, d := newServer(t)
+	if err := d.Write("users", "Waqar", map[string]string{"city": "Karachi"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"o1", "o2"} {
+		if err := d.Write("orders", key, map[string]interface{}{"by": database.RefTo("users", "Waqar")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Write("orders", "o3", map[string]interface{}{"by": database.RefTo("users", "nobody")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(s, "GET", "/collections/orders/o1?resolve=1", "")
+	var o struct {
+		By map[string]string `json:"by"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &o); err != nil || o.By["city"] != "Karachi" {
+		t.Errorf("GET ?resolve=1 = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(s, "GET", "/collections/orders/nope?resolve=1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET ?resolve=1 of a missing record = %d", rec.Code)
+	}
+
+	// Filters apply to the resolved records.
+	rec = serve(s, "GET", "/collections/orders?resolve=2&by.city=Karachi", "")
+	var p page
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || len(p.Items) != 2 {
+		t.Errorf("filtered resolved listing = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, bad := range []string{"-1", "9", "x"} {
+		if rec := serve(s, "GET", "/collections/orders/o1?resolve="+bad, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET ?resolve=%s = %d, want 400", bad, rec.Code)
+		}
+		if rec := serve(s, "GET", "/collections/orders?resolve="+bad, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("listing with ?resolve=%s = %d, want 400", bad, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+// Package server exposes a database over HTTP.
+//
+//	GET    /collections/{collection}            list records
+//	GET    /collections/{collection}/{key}      read a record
+//	PUT    /collections/{collection}/{key}      write a record from the JSON body
+//	DELETE /collections/{collection}/{key}      delete a record
+//	DELETE /collections/{collection}            delete a collection, listing its records
+//	POST   /collections/{collection}/aggregate  run the JSON pipeline body
+//	GET    /queries                             list the saved queries
+//	GET    /queries/{name}                      read a saved query
+//	PUT    /queries/{name}                      save a query from the JSON body
+//	DELETE /queries/{name}                      delete a saved query
+//	GET    /queries/{name}/results              run a saved query
+//	GET    /procedures                          list the procedures
+//	POST   /procedures/{name}                   call a procedure with the JSON body as its arguments
+//	GET    /watch[/{collection}]                stream changes as server-sent events
+//	GET    /healthz                             200 while the process is up
+//	GET    /readyz                              200 while the database, and Ready if set, can serve
+//
+// Listings are paged with ?limit=N (default 100, at most 1000) and
+// ?after=<key>, the "next" value of the previous page. Instead of after,
+// ?cursor= pages as database.FindPage does, leaving out records added with
+// keys past the end of the listing since it started: empty to start, then
+// the "cursor" value of the previous page until it is done. Any other query
+// parameter filters on a record field, with dots for nested fields:
+// ?address.city=Karachi. Repeating a parameter matches any of its values.
+// ?filter= takes an expression as compiled by database.Compile instead:
+// ?filter=doc.age > 25 && doc.active.
+// Reads and listings replace references with the records they refer to
+// with ?resolve=N, N references deep (at most 8), and filters then apply to
+// the resolved records.
+// Reads of a record without ?resolve send its revision as an ETag, and
+// reads, writes and deletes of a record honor If-Match and If-None-Match:
+// a write is only made if the record is still at the revision its
+// preconditions were checked against, and 412 Precondition Failed is
+// returned otherwise.
+// Deleting a collection responds with the keys it held, and its
+// sub-collections, as a database.DeleteReport, deleting nothing with
+// ?dry_run=true.
+// Errors are returned as {"error": "..."}. The server assumes the database
+// uses the JSON codec. With Writes set to a cluster node, writes made on a
+// follower are redirected to the leader with 307 Temporary Redirect, and
+// fail with 503 Service Unavailable while it is elected. EnableAdmin adds
+// a web dashboard at /admin/ and EnableDebug the pprof and /debug/db
+// diagnostics. With Auth set, requests run as the caller's principal and
+// the diagnostics are for administrators.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/siraiwaqarali/golang-own-database/auth"
+	"github.com/siraiwaqarali/golang-own-database/database"
+	"github.com/siraiwaqarali/golang-own-database/limit"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+	maxResolve   = 8
+)
+
+type Server struct {
+	db      *database.Driver
+	mux     *http.ServeMux
+	handler http.Handler
+
+	// shutdown is closed when ListenAndServe starts shutting down, ending
+	// change streams that would otherwise hold it up.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+
+	// ShutdownTimeout bounds how long ListenAndServe waits for in-flight
+	// requests once its context is done.
+	ShutdownTimeout time.Duration
+
+	// Limiter, if set, admits requests by client IP address; refused ones
+	// get 429 Too Many Requests.
+	Limiter *limit.Limiter
+
+	// Auth, if set, must identify the client of every request, which then
+	// runs as the principal it returns; others get 401 Unauthorized.
+	Auth auth.Authenticator
+
+	// TLSConfig, if set, makes ListenAndServe serve HTTPS with it.
+	TLSConfig *tls.Config
+
+	// Writes, if set, takes the writes and deletes of records and saved
+	// queries instead of the database, as a cluster node replicating them
+	// does; reads still go to the database. Writes it refuses with an error
+	// naming a leader, by a LeaderURL() string method, are redirected there.
+	Writes database.Database
+	// Ready, if set, must pass too for /readyz to report ready, as a
+	// cluster node following its leader closely enough does.
+	Ready func(context.Context) error
+}
+
+func New(db *database.Driver) *Server {
+	s := &Server{db: db, mux: http.NewServeMux(), shutdown: make(chan struct{}), ShutdownTimeout: 10 * time.Second}
+	s.mux.HandleFunc("GET /collections/{collection}", s.list)
+	s.mux.HandleFunc("GET /collections/{collection}/{key...}", s.get)
+	s.mux.HandleFunc("PUT /collections/{collection}/{key...}", s.put)
+	s.mux.HandleFunc("DELETE /collections/{collection}", s.drop)
+	s.mux.HandleFunc("DELETE /collections/{collection}/{key...}", s.delete)
+	s.mux.HandleFunc("POST /collections/{collection}/aggregate", s.aggregate)
+	s.mux.HandleFunc("GET /queries", s.listQueries)
+	s.mux.HandleFunc("GET /queries/{name}", s.getQuery)
+	s.mux.HandleFunc("PUT /queries/{name}", s.putQuery)
+	s.mux.HandleFunc("DELETE /queries/{name}", s.deleteQuery)
+	s.mux.HandleFunc("GET /queries/{name}/results", s.runQuery)
+	s.mux.HandleFunc("GET /procedures", s.listProcedures)
+	s.mux.HandleFunc("POST /procedures/{name}", s.call)
+	s.mux.HandleFunc("GET /watch", s.watch)
+	s.mux.HandleFunc("GET /watch/{collection}", s.watch)
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+
+	// Requests are traced, continuing any trace the client propagated, and
+	// the database calls made for them show up as child spans.
+	s.handler = otelhttp.NewHandler(s.mux, "owndb",
+		otelhttp.WithFilter(func(r *http.Request) bool { return r.URL.Path != "/metrics" }))
+	return s
+}
+
+// writes returns where writes go, Writes or the database.
+func (s *Server) writes() database.Database {
+	if s.Writes != nil {
+		return s.Writes
+	}
+	return s.db
+}
+
+// Handle mounts an additional handler, such as a GraphQL endpoint, next to
+// the REST routes.
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if probe(r) {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	// Change streams do not count against the cap on active requests.
+	release := func() {}
+	if watching(r) {
+		err = s.Limiter.Allow(client)
+	} else {
+		release, err = s.Limiter.Acquire(client)
+	}
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	defer release()
+
+	if r = s.authenticate(w, r); r == nil {
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is done, then stops accepting
+// connections and lets in-flight requests finish.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second, TLSConfig: s.TLSConfig}
+	srv.RegisterOnShutdown(func() { s.shutdownOnce.Do(func() { close(s.shutdown) }) })
+
+	errc := make(chan error, 1)
+	go func() {
+		if s.TLSConfig != nil {
+			// The certificate comes from TLSConfig.
+			errc <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type item struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type page struct {
+	Items []item `json:"items"`
+	Next  string `json:"next,omitempty"`
+	// Cursor is where to go on from, for lists asked for with a cursor.
+	Cursor *database.Cursor `json:"cursor,omitempty"`
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	collection := r.PathValue("collection")
+	query := r.URL.Query()
+
+	limit := defaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit "+strconv.Quote(v))
+			return
+		}
+		limit = min(n, maxLimit)
+	}
+	after := query.Get("after")
+	var cursor *database.Cursor
+	if query.Has("cursor") {
+		c, err := database.ParseCursor(query.Get("cursor"))
+		if err == nil && c.Collection != "" && c.Collection != collection {
+			err = fmt.Errorf("%w: cursor of collection %s used to list %s", database.ErrInvalidCursor, c.Collection, collection)
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		cursor = &c
+	}
+	resolve, err := resolveDepth(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := query.Get("filter")
+	query.Del("limit")
+	query.Del("after")
+	query.Del("cursor")
+	query.Del("resolve")
+	query.Del("filter")
+	matches, err := matcher(query, filter)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	keys, err := s.db.KeysContext(r.Context(), collection)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	end := len(keys)
+	if cursor != nil {
+		if cursor.Collection == "" {
+			cursor.Collection = collection
+			if len(keys) == 0 {
+				cursor.Done = true
+			} else {
+				cursor.Until = keys[len(keys)-1]
+			}
+		}
+		after = cursor.After
+		end = sort.SearchStrings(keys, cursor.Until)
+		if end < len(keys) && keys[end] == cursor.Until {
+			end++
+		}
+		if cursor.Done {
+			end = 0
+		}
+	}
+	start := sort.SearchStrings(keys, after)
+	if start < len(keys) && keys[start] == after {
+		start++
+	}
+
+	p := page{Items: []item{}, Cursor: cursor}
+	i := start
+	for ; i < end; i++ {
+		if len(p.Items) == limit {
+			p.Next = p.Items[len(p.Items)-1].Key
+			break
+		}
+		if cursor != nil {
+			cursor.After = keys[i]
+		}
+
+		var raw json.RawMessage
+		if resolve > 0 {
+			err = s.db.ReadResolvedContext(r.Context(), collection, keys[i], &raw, resolve)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = s.db.ReadContext(r.Context(), collection, keys[i], &raw)
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		if len(raw) == 0 {
+			// Deleted since it was listed.
+			continue
+		}
+		ok, err := matches(raw)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		if ok {
+			p.Items = append(p.Items, item{Key: keys[i], Value: raw})
+		}
+	}
+	if cursor != nil && i >= end {
+		cursor.Done = true
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	collection, key := r.PathValue("collection"), r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+
+	resolve, err := resolveDepth(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resolve > 0 {
+		var raw json.RawMessage
+		if err := s.db.ReadResolvedContext(r.Context(), collection, key, &raw, resolve); err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, raw)
+		return
+	}
+
+	var raw json.RawMessage
+	rev, err := s.db.ReadRevisionContext(r.Context(), collection, key, &raw)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(rev))
+	if im := r.Header.Get("If-Match"); im != "" && !matchETag(im, rev, false) {
+		writePreconditionFailed(w)
+		return
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchETag(inm, rev, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+func resolveDepth(query url.Values) (int, error) {
+	v := query.Get("resolve")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > maxResolve {
+		return 0, fmt.Errorf("invalid resolve %q - must be 0 to %d", v, maxResolve)
+	}
+	return n, nil
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request) {
+	collection, key := r.PathValue("collection"), r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if !conditional(r) {
+		if err := s.writes().WriteContext(r.Context(), collection, key, raw); err != nil {
+			writeWriteError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// The preconditions are checked against the revision read here, and
+	// the write only made if the record is still at it.
+	rev, err := s.revision(r, collection, key)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !preconditions(r, rev) {
+		writePreconditionFailed(w)
+		return
+	}
+	if rev, err = s.writes().WriteIfRevisionContext(r.Context(), collection, key, raw, rev); err != nil {
+		writeWriteError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(rev))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	collection, key := r.PathValue("collection"), r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+
+	if !conditional(r) {
+		if err := s.writes().DeleteContext(r.Context(), collection, key); err != nil {
+			writeWriteError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	rev, err := s.revision(r, collection, key)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !preconditions(r, rev) {
+		writePreconditionFailed(w)
+		return
+	}
+	if err := s.writes().DeleteIfRevisionContext(r.Context(), collection, key, rev); err != nil {
+		writeWriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// drop deletes a whole collection and responds with the records it held,
+// only listing them with ?dry_run=true.
+func (s *Server) drop(w http.ResponseWriter, r *http.Request) {
+	collection := r.PathValue("collection")
+	if conditional(r) {
+		writeError(w, http.StatusBadRequest, "conditional delete of a whole collection")
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid dry_run %q", v))
+			return
+		}
+	}
+	report, err := s.writes().DropCollection(r.Context(), collection, dryRun)
+	if err != nil {
+		writeWriteError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
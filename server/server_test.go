@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+func newServer(t *testing.T) (*Server, *database.Driver) {
+	t.Helper()
+	return newServerWith(t, &database.Options{TTLSweepInterval: -1})
+}
+
+func newServerWith(t *testing.T, opts *database.Options) (*Server, *database.Driver) {
+	t.Helper()
+	d, err := database.New(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return New(d), d
+}
+
+func serve(s http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestRecords(t *testing.T) {
+	s, _ := newServer(t)
+
+	if rec := serve(s, "PUT", "/collections/users/ada", `{"name":"Ada"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+	rec := serve(s, "GET", "/collections/users/ada", "")
+	var v map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil || rec.Code != http.StatusOK || v["name"] != "Ada" {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if rec := serve(s, "DELETE", "/collections/users/ada", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(s, "GET", "/collections/users/ada", "")
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusNotFound || body["error"] == "" {
+		t.Errorf("GET of a deleted record = %d %s, want a 404 JSON error", rec.Code, rec.Body)
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	s, d := newServer(t)
+	for _, key := range []string{"ada", "bob"} {
+		if err := d.Write("users", key, map[string]string{"name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serve(s, "DELETE", "/collections/users?dry_run=true", "")
+	var report database.DeleteReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK || !report.DryRun || report.Count() != 2 {
+		t.Fatalf("DELETE dry run = %d %s", rec.Code, rec.Body)
+	}
+	if keys, _ := d.Keys("users"); len(keys) != 2 {
+		t.Errorf("dry run deleted records: %v", keys)
+	}
+
+	rec = serve(s, "DELETE", "/collections/users", "")
+	report = database.DeleteReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK || report.DryRun || strings.Join(report.Records["users"], ",") != "ada,bob" {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(s, "DELETE", "/collections/users", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a dropped collection = %d %s, want 404", rec.Code, rec.Body)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s, _ := newServer(t)
+	for _, tt := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"PUT", "/collections/users/ada", `{"name":`, http.StatusBadRequest},
+		{"PUT", "/collections/.users/ada", `{}`, http.StatusBadRequest},
+		{"GET", "/collections/users?limit=0", "", http.StatusBadRequest},
+		{"GET", "/collections/users/nobody", "", http.StatusNotFound},
+		{"DELETE", "/collections/users/", "", http.StatusBadRequest},
+		{"DELETE", "/collections/users?dry_run=maybe", "", http.StatusBadRequest},
+	} {
+		rec := serve(s, tt.method, tt.target, tt.body)
+		var body map[string]string
+		if rec.Code != tt.code || json.Unmarshal(rec.Body.Bytes(), &body) != nil || body["error"] == "" {
+			t.Errorf("%s %s = %d %s, want %d with a JSON error", tt.method, tt.target, rec.Code, rec.Body, tt.code)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	s, d := newServer(t)
+	for i, city := range []string{"Karachi", "Lahore", "Karachi", "Quetta", "Karachi"} {
+		v := map[string]interface{}{"n": i, "address": map[string]string{"city": city}}
+		if err := d.Write("users", fmt.Sprintf("u%d", i), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("listing never ended")
+		}
+		rec := serve(s, "GET", "/collections/users?limit=2&after="+after, "")
+		var p page
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET = %d %s", rec.Code, rec.Body)
+		}
+		for _, it := range p.Items {
+			keys = append(keys, it.Key)
+		}
+		if p.Next == "" {
+			break
+		}
+		after = p.Next
+	}
+	if strings.Join(keys, ",") != "u0,u1,u2,u3,u4" {
+		t.Errorf("pages listed %v", keys)
+	}
+
+	rec := serve(s, "GET", "/collections/users?address.city=Karachi&address.city=Quetta", "")
+	var p page
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	keys = nil
+	for _, it := range p.Items {
+		keys = append(keys, it.Key)
+	}
+	if strings.Join(keys, ",") != "u0,u2,u3,u4" {
+		t.Errorf("filtered listing = %v", keys)
+	}
+
+	if rec := serve(s, "GET", "/collections/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("listing of a missing collection = %d %s, want 404", rec.Code, rec.Body)
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	s, d := newServer(t)
+	for i := 0; i < 5; i++ {
+		if err := d.Write("users", fmt.Sprintf("u%d", i), map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("listing never ended")
+		}
+		rec := serve(s, "GET", "/collections/users?limit=2&cursor="+cursor, "")
+		var p page
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || rec.Code != http.StatusOK || p.Cursor == nil {
+			t.Fatalf("GET = %d %s", rec.Code, rec.Body)
+		}
+		for _, it := range p.Items {
+			keys = append(keys, it.Key)
+		}
+		// Added past the end of the listing, so left out of it.
+		if err := d.Write("users", "z", map[string]int{}); err != nil {
+			t.Fatal(err)
+		}
+		cursor = p.Cursor.String()
+		if p.Cursor.Done {
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "u0,u1,u2,u3,u4" {
+		t.Errorf("pages listed %v", keys)
+	}
+
+	if err := d.Write("groups", "g", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(s, "GET", "/collections/groups?cursor="+cursor, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("listing with the cursor of another collection = %d %s, want 400", rec.Code, rec.Body)
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	s, d := newServer(t)
+	if err := d.Write("users", "ada", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- s.ListenAndServe(ctx, addr) }()
+
+	var resp *http.Response
+	for i := 0; ; i++ {
+		if resp, err = http.Get("http://" + addr + "/collections/users"); err == nil {
+			break
+		}
+		if i == 50 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET = %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("ListenAndServe = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return after its context was done")
+	}
+	if _, err := http.Get("http://" + addr + "/collections/users"); err == nil {
+		t.Errorf("server still accepting after shutdown: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// traceIDs is a TracerProvider keeping the trace of every span started.
+type traceIDs struct {
+	noop.TracerProvider
+	mutex sync.Mutex
+	ids   []trace.TraceID
+}
+
+func (p *traceIDs) Tracer(string, ...trace.TracerOption) trace.Tracer { return traceIDsTracer{p: p} }
+
+type traceIDsTracer struct {
+	noop.Tracer
+	p *traceIDs
+}
+
+func (t traceIDsTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.p.mutex.Lock()
+	t.p.ids = append(t.p.ids, trace.SpanContextFromContext(ctx).TraceID())
+	t.p.mutex.Unlock()
+	return t.Tracer.Start(ctx, name, opts...)
+}
+
+func TestTracePropagation(t *testing.T) {
+	was := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(was)
+
+	p := &traceIDs{}
+	d, err := database.New(t.TempDir(), &database.Options{TracerProvider: p, TTLSweepInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	s := New(d)
+
+	req := httptest.NewRequest("PUT", "/collections/users/ada", strings.NewReader(`{"name":"Ada"}`))
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+
+	want, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	if len(p.ids) == 0 {
+		t.Fatal("no driver span started for the request")
+	}
+	for _, id := range p.ids {
+		if id != want {
+			t.Errorf("driver span in trace %v, want the request's %v", id, want)
+		}
+	}
+}
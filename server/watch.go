@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type event struct {
+	Op         string `json:"op"`
+	Collection string `json:"collection"`
+	Key        string `json:"key,omitempty"`
+	External   bool   `json:"external,omitempty"`
+}
+
+// watching reports whether r opens a change stream, which can stay open
+// indefinitely.
+func watching(r *http.Request) bool {
+	return r.URL.Path == "/watch" || strings.HasPrefix(r.URL.Path, "/watch/")
+}
+
+// watch streams changes as server-sent events, one {"op", "collection",
+// "key", "external"} object per event. The stream ends with a "reset"
+// event when the watcher falls behind; clients should re-read the
+// collection then.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	events, stop, err := s.db.WatchContext(r.Context(), r.PathValue("collection"))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case e, ok := <-events:
+			if !ok {
+				fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			data, err := json.Marshal(event{Op: e.Op.String(), Collection: e.Collection, Key: e.Key, External: e.External})
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
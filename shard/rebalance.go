@@ -0,0 +1,129 @@
+package shard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// RebalanceReport counts what Rebalance did. Records moved to a shard it
+// scans later are scanned again there.
+type RebalanceReport struct {
+	Scanned int
+	Moved   int
+}
+
+// Rebalance moves every record not on the shard it belongs on there, with
+// its attachments and expiry, as needed after adding or removing a shard.
+// Until it is done, the records left to move are not found where the DB
+// looks for them; listings still return them. A record found on two
+// shards, as when a Rebalance was interrupted, is kept on the one it
+// belongs on.
+func (db *DB) Rebalance(ctx context.Context) (RebalanceReport, error) {
+	var report RebalanceReport
+	for _, d := range db.shards {
+		collections, err := d.Collections()
+		if err != nil {
+			return report, err
+		}
+		for _, top := range collections {
+			tree, err := d.CollectionTree(top)
+			if err != nil {
+				return report, err
+			}
+			for _, collection := range append([]string{top}, tree...) {
+				if err := db.rebalanceCollection(ctx, d, collection, &report); err != nil {
+					return report, fmt.Errorf("rebalancing %s: %w", collection, err)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+func (db *DB) rebalanceCollection(ctx context.Context, d *database.Driver, collection string, report *RebalanceReport) error {
+	keys, err := d.KeysContext(ctx, collection)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Scanned++
+		target := db.Shard(collection, key)
+		if target == d {
+			continue
+		}
+		moved, err := move(ctx, d, target, collection, key)
+		if err != nil {
+			return fmt.Errorf("moving %s: %w", key, err)
+		}
+		if moved {
+			report.Moved++
+		}
+	}
+	return nil
+}
+
+// move moves a record from one shard to another, keeping the copy already
+// on the target if there is one.
+func move(ctx context.Context, from, to *database.Driver, collection, key string) (bool, error) {
+	var existing bytes.Buffer
+	err := to.ReadToContext(ctx, collection, key, &existing)
+	if err == nil {
+		return false, from.DeleteContext(ctx, collection, key)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	var b bytes.Buffer
+	if err := from.ReadToContext(ctx, collection, key, &b); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted or expired since it was listed.
+			return false, nil
+		}
+		return false, err
+	}
+	expires, err := from.ExpiresAt(collection, key)
+	if err != nil {
+		return false, err
+	}
+	if err := to.WriteRaw(collection, key, &b); err != nil {
+		return false, err
+	}
+	if !expires.IsZero() {
+		// Past expiry now, it is still swept on the target.
+		if err := to.Touch(collection, key, max(time.Until(expires), time.Nanosecond)); err != nil {
+			return false, err
+		}
+	}
+	names, err := from.Attachments(collection, key)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if err := copyAttachment(from, to, collection, key, name); err != nil {
+			return false, fmt.Errorf("copying attachment %s: %w", name, err)
+		}
+	}
+	return true, from.DeleteContext(ctx, collection, key)
+}
+
+func copyAttachment(from, to *database.Driver, collection, key, name string) error {
+	rc, err := from.GetAttachment(collection, key, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return to.PutAttachment(collection, key, name, rc)
+}
@@ -0,0 +1,59 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRebalance(t *testing.T) {
+	root := t.TempDir()
+	db := openShards(t, root, []string{"a", "b"}, nil)
+	writeUsers(t, db, 50)
+	if err := db.Shard("users", "u01").PutAttachment("users", "u01", "pic", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db = openShards(t, root, []string{"a", "b", "c"}, nil)
+	defer db.Close()
+	if keys, err := db.Keys("users"); err != nil || len(keys) != 50 {
+		t.Errorf("Keys before rebalancing = %d keys, %v; want 50", len(keys), err)
+	}
+
+	report, err := db.Rebalance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned < 50 || report.Moved == 0 || report.Moved >= 50 {
+		t.Errorf("Rebalance = %+v, want some of the 50 records moved", report)
+	}
+	if report, err := db.Rebalance(context.Background()); err != nil || report.Moved != 0 {
+		t.Errorf("second Rebalance = %+v, %v; want nothing moved", report, err)
+	}
+
+	for i := 0; i < 50; i++ {
+		var v map[string]any
+		if err := db.Read("users", fmt.Sprintf("u%02d", i), &v); err != nil || v == nil {
+			t.Errorf("Read of u%02d after rebalancing = %v, %v", i, v, err)
+		}
+	}
+	for i, d := range db.Shards() {
+		keys, _ := d.Keys("users")
+		for _, key := range keys {
+			if db.Shard("users", key) != d {
+				t.Errorf("%s left on shard %d", key, i)
+			}
+		}
+	}
+	r, err := db.Shard("users", "u01").GetAttachment("users", "u01", "pic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if b, err := io.ReadAll(r); err != nil || string(b) != "hello" {
+		t.Errorf("attachment after rebalancing = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,133 @@
+package shard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// ReadResolved reads a record with its references resolved as the
+// Driver's ReadResolved does, following them to whichever shard holds the
+// records they refer to.
+func (db *DB) ReadResolved(collection, resource string, v interface{}, depth int) error {
+	return db.ReadResolvedContext(context.Background(), collection, resource, v, depth)
+}
+
+func (db *DB) ReadResolvedContext(ctx context.Context, collection, resource string, v interface{}, depth int) error {
+	r := db.newResolver(ctx)
+	doc, err := r.resolveRecord(collection, resource, depth)
+	if err != nil {
+		return err
+	}
+	b, err := db.codec.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return db.codec.Unmarshal(b, v)
+}
+
+// ReadAllResolved is ReadAll with the references of every record resolved
+// as by ReadResolved.
+func (db *DB) ReadAllResolved(collection string, depth int) ([]string, error) {
+	return db.ReadAllResolvedContext(context.Background(), collection, depth)
+}
+
+func (db *DB) ReadAllResolvedContext(ctx context.Context, collection string, depth int) ([]string, error) {
+	keys, err := db.KeysContext(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	r := db.newResolver(ctx)
+	records := make([]string, 0, len(keys))
+	for _, key := range keys {
+		doc, err := r.resolveRecord(collection, key, depth)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := db.codec.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, string(b))
+	}
+	return records, nil
+}
+
+// resolver resolves the references of documents read in one call across
+// the shards, caching the records it reads.
+type resolver struct {
+	db       *DB
+	ctx      context.Context
+	records  map[string][]byte
+	visiting map[string]bool
+}
+
+func (db *DB) newResolver(ctx context.Context) *resolver {
+	return &resolver{db: db, ctx: ctx, records: make(map[string][]byte), visiting: make(map[string]bool)}
+}
+
+func (r *resolver) resolveRecord(collection, resource string, depth int) (interface{}, error) {
+	ref := database.RefTo(collection, resource).Ref
+	b, ok := r.records[ref]
+	if !ok {
+		var buf bytes.Buffer
+		if err := r.db.Shard(collection, resource).ReadToContext(r.ctx, collection, resource, &buf); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+		r.records[ref] = b
+	}
+	var doc interface{}
+	if err := r.db.codec.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	r.visiting[ref] = true
+	defer delete(r.visiting, ref)
+	return r.resolve(doc, depth)
+}
+
+// resolve replaces the references in v, which it may modify, with the
+// documents they refer to.
+func (r *resolver) resolve(v interface{}, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s, ok := v["$ref"].(string); ok && len(v) == 1 {
+			return r.resolveRef(v, database.Ref{Ref: s}, depth)
+		}
+		for k, e := range v {
+			resolved, err := r.resolve(e, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+	case []interface{}:
+		for i, e := range v {
+			resolved, err := r.resolve(e, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return v, nil
+}
+
+func (r *resolver) resolveRef(v map[string]interface{}, ref database.Ref, depth int) (interface{}, error) {
+	collection, resource, err := ref.Target()
+	if err != nil || depth <= 0 || r.visiting[ref.Ref] {
+		return v, nil
+	}
+	doc, err := r.resolveRecord(collection, resource, depth-1)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, database.ErrInvalidName) {
+		return v, nil
+	}
+	return doc, err
+}
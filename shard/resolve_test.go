@@ -0,0 +1,29 @@
+package shard
+
+import (
+	"testing"
+)
+
+func TestReadResolved(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b"}, nil)
+	defer db.Close()
+	writeUsers(t, db, 50)
+
+	var v map[string]any
+	if err := db.ReadResolved("users", "u00", &v, 2); err != nil {
+		t.Fatal(err)
+	}
+	friend, _ := v["friend"].(map[string]any)
+	next, _ := friend["friend"].(map[string]any)
+	if friend["n"] != float64(1) || next["n"] != float64(2) {
+		t.Errorf("ReadResolved = %v, want friends u01 and u02 resolved", v)
+	}
+	if _, ok := next["friend"].(map[string]any)["$ref"]; !ok {
+		t.Errorf("reference past the depth resolved: %v", next)
+	}
+
+	records, err := db.ReadAllResolved("users", 1)
+	if err != nil || len(records) != 50 {
+		t.Errorf("ReadAllResolved = %d records, %v; want 50", len(records), err)
+	}
+}
@@ -0,0 +1,62 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+const defaultVirtualNodes = 128
+
+// ring places names on shards by consistent hashing: every shard takes
+// several points on a circle of hashes, and a name belongs to the shard of
+// the first point at or after its own hash. Adding a shard only moves the
+// names falling just before its points onto it.
+type ring struct {
+	points []uint64
+	shards []int
+}
+
+func newRing(names []string, virtualNodes int) ring {
+	type point struct {
+		hash  uint64
+		shard int
+	}
+	points := make([]point, 0, len(names)*virtualNodes)
+	for i, name := range names {
+		for v := 0; v < virtualNodes; v++ {
+			points = append(points, point{hash: hash(name + "#" + strconv.Itoa(v)), shard: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	r := ring{points: make([]uint64, len(points)), shards: make([]int, len(points))}
+	for i, p := range points {
+		r.points[i], r.shards[i] = p.hash, p.shard
+	}
+	return r
+}
+
+// locate returns the shard name belongs to.
+func (r ring) locate(name string) int {
+	h := hash(name)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.shards[i]
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// FNV barely mixes the last bytes, which tell the points of a shard
+	// apart; the finalizer of splitmix64 spreads them over the circle.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,36 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	r := newRing(names, defaultVirtualNodes)
+	counts := make([]int, len(names))
+	for i := 0; i < 3000; i++ {
+		counts[r.locate(fmt.Sprintf("users/u%d", i))]++
+	}
+	for i, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("shard %s got %d of 3000 names, want about 1000", names[i], n)
+		}
+	}
+
+	// Adding a shard only moves names onto it.
+	grown := newRing(append(names, "d"), defaultVirtualNodes)
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		name := fmt.Sprintf("users/u%d", i)
+		if from, to := r.locate(name), grown.locate(name); from != to {
+			moved++
+			if to != 3 {
+				t.Fatalf("%s moved from shard %d to %d, not to the new one", name, from, to)
+			}
+		}
+	}
+	if moved < 450 || moved > 1050 {
+		t.Errorf("adding a fourth shard moved %d of 3000 names, want about 750", moved)
+	}
+}
@@ -0,0 +1,505 @@
+// Package shard spreads one database over several directories, such as one
+// per disk or mount, so a large store can share its IO out between them
+// behind the API of a single one. Records are placed on the directories by
+// consistent hashing, so adding one only moves the records that now belong
+// on it, which Rebalance does.
+//
+//	db, err := shard.Open([]shard.Shard{{Dir: "/mnt/a/db"}, {Dir: "/mnt/b/db"}}, nil)
+package shard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+var ErrNoShards = errors.New("no shards")
+
+// Placement is what decides the shard of a record.
+type Placement int
+
+const (
+	// ByKey places every record by its collection and key, spreading each
+	// collection over all the shards. Listings and queries read them all.
+	ByKey Placement = iota
+	// ByCollection keeps every top-level collection, with its
+	// sub-collections, whole on one shard.
+	ByCollection
+)
+
+// Shard is one of the directories of a sharded database.
+type Shard struct {
+	// Name places records on the shard, the directory by default. Keep it
+	// when the directory moves, as records would otherwise be looked for
+	// elsewhere until a Rebalance.
+	Name string
+	Dir  string
+}
+
+type Options struct {
+	Placement Placement
+	// VirtualNodes is how many points every shard takes on the hash ring,
+	// 128 by default. More spread records more evenly.
+	VirtualNodes int
+	// Database sets up the Driver of every shard. Views are not supported,
+	// as each shard would only see the records on it.
+	Database *database.Options
+}
+
+// DB is a database sharded over several directories. Saved queries are
+// kept on the first shard.
+type DB struct {
+	placement Placement
+	ring      ring
+	names     []string
+	shards    []*database.Driver
+	codec     database.Codec
+}
+
+var _ database.Database = (*DB)(nil)
+
+// Open opens a Driver for every shard, all with the same options.
+func Open(shards []Shard, options *Options) (*DB, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+	if opts.VirtualNodes < 0 {
+		return nil, fmt.Errorf("invalid virtual nodes %d - must not be negative", opts.VirtualNodes)
+	}
+	if opts.VirtualNodes == 0 {
+		opts.VirtualNodes = defaultVirtualNodes
+	}
+	dbOpts := database.Options{}
+	if opts.Database != nil {
+		dbOpts = *opts.Database
+	}
+	if len(dbOpts.Views) > 0 {
+		return nil, fmt.Errorf("views are not supported on a sharded database")
+	}
+	if dbOpts.Backend != nil {
+		return nil, fmt.Errorf("a sharded database keeps every shard in its directory - do not set a backend")
+	}
+
+	db := &DB{placement: opts.Placement, codec: dbOpts.Codec}
+	if db.codec == nil {
+		db.codec = database.JSONCodec{}
+	}
+	seen := make(map[string]bool)
+	for _, s := range shards {
+		name := s.Name
+		if name == "" {
+			name = s.Dir
+		}
+		if seen[name] {
+			db.Close()
+			return nil, fmt.Errorf("shard %q given twice", name)
+		}
+		seen[name] = true
+		d, err := database.New(s.Dir, &dbOpts)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("opening shard %s: %w", name, err)
+		}
+		db.names = append(db.names, name)
+		db.shards = append(db.shards, d)
+	}
+	db.ring = newRing(db.names, opts.VirtualNodes)
+	return db, nil
+}
+
+// Close closes the Driver of every shard.
+func (db *DB) Close() error {
+	var errs []error
+	for _, d := range db.shards {
+		errs = append(errs, d.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Shards returns the Drivers of the shards, in the order given to Open.
+func (db *DB) Shards() []*database.Driver {
+	return append([]*database.Driver(nil), db.shards...)
+}
+
+// Shard returns the Driver of the shard holding a record, for what the DB
+// does not offer itself.
+func (db *DB) Shard(collection, resource string) *database.Driver {
+	return db.shards[db.locate(collection, resource)]
+}
+
+func (db *DB) locate(collection, resource string) int {
+	if db.placement == ByCollection {
+		top, _, _ := strings.Cut(collection, "/")
+		return db.ring.locate(top)
+	}
+	return db.ring.locate(database.RefTo(collection, resource).Ref)
+}
+
+// holding returns the shards that may hold records of collection.
+func (db *DB) holding(collection string) []*database.Driver {
+	if db.placement == ByCollection && collection != "" {
+		return []*database.Driver{db.Shard(collection, "")}
+	}
+	return db.shards
+}
+
+func (db *DB) Write(collection, resource string, v interface{}) error {
+	return db.WriteContext(context.Background(), collection, resource, v)
+}
+
+func (db *DB) WriteContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return db.Shard(collection, resource).WriteContext(ctx, collection, resource, v)
+}
+
+func (db *DB) Read(collection, resource string, v interface{}) error {
+	return db.ReadContext(context.Background(), collection, resource, v)
+}
+
+func (db *DB) ReadContext(ctx context.Context, collection, resource string, v interface{}) error {
+	return db.Shard(collection, resource).ReadContext(ctx, collection, resource, v)
+}
+
+func (db *DB) Delete(collection, resource string) error {
+	return db.DeleteContext(context.Background(), collection, resource)
+}
+
+func (db *DB) DeleteContext(ctx context.Context, collection, resource string) error {
+	return db.Shard(collection, resource).DeleteContext(ctx, collection, resource)
+}
+
+// DropCollection deletes a collection from every shard holding part of it,
+// reporting the records of all of them.
+func (db *DB) DropCollection(ctx context.Context, collection string, dryRun bool) (*database.DeleteReport, error) {
+	var missing error
+	var report *database.DeleteReport
+	for _, d := range db.holding(collection) {
+		r, err := d.DropCollection(ctx, collection, dryRun)
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = err
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		if report == nil {
+			report = &database.DeleteReport{DryRun: dryRun, Records: make(map[string][]string)}
+		}
+		for c, keys := range r.Records {
+			report.Records[c] = append(report.Records[c], keys...)
+		}
+	}
+	if report == nil {
+		return nil, missing
+	}
+	for _, keys := range report.Records {
+		sort.Strings(keys)
+	}
+	return report, nil
+}
+
+func (db *DB) ReadRevision(collection, resource string, v interface{}) (string, error) {
+	return db.ReadRevisionContext(context.Background(), collection, resource, v)
+}
+
+func (db *DB) ReadRevisionContext(ctx context.Context, collection, resource string, v interface{}) (string, error) {
+	return db.Shard(collection, resource).ReadRevisionContext(ctx, collection, resource, v)
+}
+
+func (db *DB) WriteIfRevision(collection, resource string, v interface{}, rev string) (string, error) {
+	return db.WriteIfRevisionContext(context.Background(), collection, resource, v, rev)
+}
+
+func (db *DB) WriteIfRevisionContext(ctx context.Context, collection, resource string, v interface{}, rev string) (string, error) {
+	return db.Shard(collection, resource).WriteIfRevisionContext(ctx, collection, resource, v, rev)
+}
+
+func (db *DB) DeleteIfRevision(collection, resource, rev string) error {
+	return db.DeleteIfRevisionContext(context.Background(), collection, resource, rev)
+}
+
+func (db *DB) DeleteIfRevisionContext(ctx context.Context, collection, resource, rev string) error {
+	return db.Shard(collection, resource).DeleteIfRevisionContext(ctx, collection, resource, rev)
+}
+
+// Keys returns the keys of a collection from every shard holding part of
+// it, sorted. Like the Driver's, it fails with an error matching
+// fs.ErrNotExist for a collection no shard has.
+func (db *DB) Keys(collection string) ([]string, error) {
+	return db.KeysContext(context.Background(), collection)
+}
+
+func (db *DB) KeysContext(ctx context.Context, collection string) ([]string, error) {
+	keys, err := db.keys(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]string, 0, len(keys))
+	for _, k := range keys {
+		all = append(all, k.key)
+	}
+	return all, nil
+}
+
+type shardKey struct {
+	key   string
+	shard *database.Driver
+}
+
+// keys lists the keys of collection on the shards holding it, sorted, with
+// the shard of each.
+func (db *DB) keys(ctx context.Context, collection string) ([]shardKey, error) {
+	var keys []shardKey
+	var missing error
+	found := false
+	for _, d := range db.holding(collection) {
+		shardKeys, err := d.KeysContext(ctx, collection)
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, k := range shardKeys {
+			keys = append(keys, shardKey{key: k, shard: d})
+		}
+	}
+	if !found {
+		return nil, missing
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+	// A record on two shards, halfway through a Rebalance, is listed once,
+	// from the shard it belongs on.
+	unique := keys[:0]
+	for _, k := range keys {
+		if n := len(unique); n > 0 && unique[n-1].key == k.key {
+			if k.shard == db.Shard(collection, k.key) {
+				unique[n-1] = k
+			}
+			continue
+		}
+		unique = append(unique, k)
+	}
+	return unique, nil
+}
+
+// ReadAll returns the records of a collection in key order, from every
+// shard holding part of it.
+func (db *DB) ReadAll(collection string) ([]string, error) {
+	return db.ReadAllContext(context.Background(), collection)
+}
+
+func (db *DB) ReadAllContext(ctx context.Context, collection string) ([]string, error) {
+	if db.placement == ByCollection {
+		return db.Shard(collection, "").ReadAllContext(ctx, collection)
+	}
+	keys, err := db.keys(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]string, 0, len(keys))
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.Reset()
+		err := k.shard.ReadToContext(ctx, collection, k.key, &buf)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, buf.String())
+	}
+	return records, nil
+}
+
+// Find runs a query over every shard holding part of its collection,
+// returning the results in key order.
+func (db *DB) Find(q database.Query) ([]database.QueryResult, error) {
+	return db.FindContext(context.Background(), q)
+}
+
+func (db *DB) FindContext(ctx context.Context, q database.Query) ([]database.QueryResult, error) {
+	var results []database.QueryResult
+	for _, d := range db.holding(q.Collection) {
+		r, err := d.FindContext(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	// Each shard returned its first q.Limit results.
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	if results == nil {
+		results = []database.QueryResult{}
+	}
+	return results, nil
+}
+
+// Aggregate runs a pipeline over a collection, applying its leading match
+// and filter stages on every shard holding part of the collection, and the
+// rest to the documents they keep.
+func (db *DB) Aggregate(collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	return db.AggregateContext(context.Background(), collection, pipeline)
+}
+
+func (db *DB) AggregateContext(ctx context.Context, collection string, pipeline []database.Stage) ([]map[string]interface{}, error) {
+	if db.placement == ByCollection {
+		return db.Shard(collection, "").AggregateContext(ctx, collection, pipeline)
+	}
+	// Check the whole pipeline before reading any shard.
+	if _, err := database.ApplyPipeline(nil, pipeline); err != nil {
+		return nil, err
+	}
+	n := 0
+	for n < len(pipeline) && (pipeline[n].Match != nil || pipeline[n].Filter != "") {
+		n++
+	}
+	pushed := pipeline[:n]
+	if rest, _ := json.Marshal(pipeline[n:]); bytes.Contains(rest, []byte("_meta")) {
+		// The shards add the record metadata only to pipelines naming it,
+		// and the documents are sorted by key again below.
+		pushed = append(slices.Clip(pushed), database.Stage{Sort: []string{"_meta.revision"}})
+	}
+	var docs []map[string]interface{}
+	for _, d := range db.shards {
+		shardDocs, err := d.AggregateContext(ctx, collection, pushed)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, shardDocs...)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		ki, _ := docs[i]["_key"].(string)
+		kj, _ := docs[j]["_key"].(string)
+		return ki < kj
+	})
+	if docs == nil {
+		docs = []map[string]interface{}{}
+	}
+	return database.ApplyPipeline(docs, pipeline[n:])
+}
+
+func (db *DB) SaveQuery(name string, q database.Query) error {
+	return db.SaveQueryContext(context.Background(), name, q)
+}
+
+func (db *DB) SaveQueryContext(ctx context.Context, name string, q database.Query) error {
+	return db.shards[0].SaveQueryContext(ctx, name, q)
+}
+
+func (db *DB) SavedQuery(name string) (database.Query, error) {
+	return db.SavedQueryContext(context.Background(), name)
+}
+
+func (db *DB) SavedQueryContext(ctx context.Context, name string) (database.Query, error) {
+	return db.shards[0].SavedQueryContext(ctx, name)
+}
+
+func (db *DB) SavedQueries() ([]string, error) {
+	return db.SavedQueriesContext(context.Background())
+}
+
+func (db *DB) SavedQueriesContext(ctx context.Context) ([]string, error) {
+	return db.shards[0].SavedQueriesContext(ctx)
+}
+
+func (db *DB) DeleteQuery(name string) error {
+	return db.DeleteQueryContext(context.Background(), name)
+}
+
+func (db *DB) DeleteQueryContext(ctx context.Context, name string) error {
+	return db.shards[0].DeleteQueryContext(ctx, name)
+}
+
+// RunQuery runs the query saved under name over its whole collection.
+func (db *DB) RunQuery(name string) ([]database.QueryResult, error) {
+	return db.RunQueryContext(context.Background(), name)
+}
+
+func (db *DB) RunQueryContext(ctx context.Context, name string) ([]database.QueryResult, error) {
+	q, err := db.SavedQueryContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return db.FindContext(ctx, q)
+}
+
+// Watch streams the changes to collection, or to every collection if it
+// is empty, from every shard holding part of it. Events of one record
+// arrive in order, those of different records of the collection may not.
+// Once one shard drops the watcher for falling behind, the channel is
+// closed.
+func (db *DB) Watch(collection string) (events <-chan database.Event, stop func(), err error) {
+	return db.WatchContext(context.Background(), collection)
+}
+
+func (db *DB) WatchContext(ctx context.Context, collection string) (events <-chan database.Event, stop func(), err error) {
+	shards := db.holding(collection)
+	if len(shards) == 1 {
+		return shards[0].WatchContext(ctx, collection)
+	}
+	var sources []<-chan database.Event
+	var stops []func()
+	for _, d := range shards {
+		events, stop, err := d.WatchContext(ctx, collection)
+		if err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return nil, nil, err
+		}
+		sources = append(sources, events)
+		stops = append(stops, stop)
+	}
+
+	out := make(chan database.Event)
+	done := make(chan struct{})
+	var once sync.Once
+	stopAll := func() {
+		once.Do(func() {
+			close(done)
+			for _, stop := range stops {
+				stop()
+			}
+		})
+	}
+	var wg sync.WaitGroup
+	for _, events := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stopAll()
+			for e := range events {
+				select {
+				case out <- e:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, stopAll, nil
+}
@@ -0,0 +1,188 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/siraiwaqarali/golang-own-database/database"
+)
+
+// openShards opens a DB over the directories named in root.
+func openShards(t *testing.T, root string, names []string, opts *Options) *DB {
+	t.Helper()
+	var shards []Shard
+	for _, name := range names {
+		shards = append(shards, Shard{Name: name, Dir: filepath.Join(root, name)})
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.Database == nil {
+		opts.Database = &database.Options{TTLSweepInterval: -1}
+	}
+	db, err := Open(shards, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// writeUsers writes the users u00 to u<n-1>, each with its number and a
+// reference to the next one.
+func writeUsers(t *testing.T, db *DB, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		user := map[string]any{"n": i, "friend": database.RefTo("users", fmt.Sprintf("u%02d", (i+1)%n))}
+		if err := db.Write("users", fmt.Sprintf("u%02d", i), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestShard(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b"}, nil)
+	defer db.Close()
+
+	if _, err := db.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a missing collection = %v, want fs.ErrNotExist", err)
+	}
+	writeUsers(t, db, 50)
+	for i, d := range db.Shards() {
+		if keys, err := d.Keys("users"); err != nil || len(keys) == 0 {
+			t.Errorf("shard %d holds %d users, %v", i, len(keys), err)
+		}
+	}
+
+	keys, err := db.Keys("users")
+	if err != nil || len(keys) != 50 || keys[0] != "u00" || keys[49] != "u49" {
+		t.Errorf("Keys = %v, %v; want u00 to u49 in order", keys, err)
+	}
+	if all, err := db.ReadAll("users"); err != nil || len(all) != 50 {
+		t.Errorf("ReadAll = %d records, %v; want 50", len(all), err)
+	}
+	var v map[string]any
+	if err := db.Read("users", "u07", &v); err != nil || v["n"] != float64(7) {
+		t.Errorf("Read = %v, %v", v, err)
+	}
+
+	results, err := db.Find(database.Query{Collection: "users", Filter: "doc.n >= 10", Limit: 5})
+	if err != nil || len(results) != 5 || results[0].Key != "u10" {
+		t.Errorf("Find = %v, %v; want 5 results from u10", results, err)
+	}
+	docs, err := db.Aggregate("users", []database.Stage{{Filter: "doc.n < 10"}, {Sort: []string{"-n"}}, {Limit: 2}})
+	if err != nil || len(docs) != 2 || docs[0]["_key"] != "u09" || docs[1]["_key"] != "u08" {
+		t.Errorf("Aggregate = %v, %v; want u09 and u08", docs, err)
+	}
+
+	if err := db.Delete("users", "u07"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("users", "u07"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete of a deleted record = %v, want fs.ErrNotExist", err)
+	}
+	if keys, _ := db.Keys("users"); len(keys) != 49 {
+		t.Errorf("Keys after a delete = %d keys, want 49", len(keys))
+	}
+}
+
+func TestAggregateMetadata(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b"}, &Options{Database: &database.Options{RecordMetadata: true, TTLSweepInterval: -1}})
+	defer db.Close()
+
+	writeUsers(t, db, 20)
+	for i := 0; i < 3; i++ {
+		if err := db.Write("users", "u13", map[string]int{"n": 13}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs, err := db.Aggregate("users", []database.Stage{{Sort: []string{"-_meta.version"}}, {Limit: 1}})
+	if err != nil || len(docs) != 1 || docs[0]["_key"] != "u13" {
+		t.Errorf("Aggregate sorted by version = %v, %v; want u13", docs, err)
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b"}, nil)
+	defer db.Close()
+
+	writeUsers(t, db, 20)
+	report, err := db.DropCollection(context.Background(), "users", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 20 {
+		t.Errorf("DropCollection report = %+v, want 20 records", report)
+	}
+	if _, err := db.Keys("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Keys of a dropped collection = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b"}, nil)
+	defer db.Close()
+
+	events, stop, err := db.Watch("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	writeUsers(t, db, 20)
+	seen := make(map[string]bool)
+	for timeout := time.After(2 * time.Second); len(seen) < 20; {
+		select {
+		case e := <-events:
+			seen[e.Key] = true
+		case <-timeout:
+			t.Fatalf("got events of %d records, want 20", len(seen))
+		}
+	}
+}
+
+func TestByCollection(t *testing.T) {
+	db := openShards(t, t.TempDir(), []string{"a", "b", "c"}, &Options{Placement: ByCollection})
+	defer db.Close()
+
+	writeUsers(t, db, 20)
+	if err := db.Write("users/u01/posts", "p", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+	holding := 0
+	for _, d := range db.Shards() {
+		if keys, _ := d.Keys("users"); len(keys) > 0 {
+			holding++
+			if len(keys) != 20 {
+				t.Errorf("shard holds %d users, want all 20", len(keys))
+			}
+		}
+	}
+	if holding != 1 {
+		t.Errorf("users spread over %d shards, want 1", holding)
+	}
+	if db.Shard("users/u01/posts", "p") != db.Shard("users", "u01") {
+		t.Error("sub-collection placed away from its collection")
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Open(nil, nil); !errors.Is(err, ErrNoShards) {
+		t.Errorf("Open without shards = %v, want ErrNoShards", err)
+	}
+	twice := []Shard{{Name: "a", Dir: filepath.Join(root, "a")}, {Name: "a", Dir: filepath.Join(root, "b")}}
+	if _, err := Open(twice, nil); err == nil {
+		t.Error("Open accepted a shard given twice")
+	}
+	one := []Shard{{Dir: filepath.Join(root, "c")}}
+	if _, err := Open(one, &Options{Database: &database.Options{Views: []database.View{{Name: "v", Source: "users"}}}}); err == nil {
+		t.Error("Open accepted views")
+	}
+	if _, err := Open(one, &Options{VirtualNodes: -1}); err == nil {
+		t.Error("Open accepted negative virtual nodes")
+	}
+}